@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"etlgo/internal/domain"
+	"etlgo/internal/infrastructure"
+	"etlgo/internal/storagetest"
+	"etlgo/internal/usecase"
+	"etlgo/pkg/config"
+	"etlgo/pkg/logger"
+)
+
+const usage = `etlctl is the operator CLI for the ETL service.
+
+Usage:
+  etlctl <command> [flags]
+
+Commands:
+  migrate           apply pending schema migrations to the storage backend
+  migrate-storage   copy ads, CRM and metrics data between storage backends
+  check-storage     run the storage conformance checks against a scratch database
+
+Run "etlctl <command> -h" for the flags of a command.
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	var err error
+	switch os.Args[1] {
+	case "migrate":
+		err = migrate(ctx, os.Args[2:])
+	case "migrate-storage":
+		err = migrateStorage(ctx, os.Args[2:])
+	case "check-storage":
+		err = checkStorage(ctx, os.Args[2:])
+	case "-h", "--help", "help":
+		fmt.Print(usage)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+func migrate(ctx context.Context, args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	backend := fs.String("backend", cfg.Storage.Backend, "storage backend ("+strings.Join(infrastructure.StorageBackends(), ", ")+")")
+	dsn := fs.String("dsn", cfg.Storage.DSN, "data source name")
+	statusOnly := fs.Bool("status", false, "report the schema version without migrating")
+	logLevel := fs.String("log-level", "info", "log level")
+	fs.Parse(args)
+
+	log := logger.New(*logLevel)
+	storage, err := infrastructure.OpenStorage(*backend, infrastructure.StorageOptions{DSN: *dsn}, log)
+	if err != nil {
+		return err
+	}
+	defer storage.Close()
+
+	service := usecase.NewStorageService(storage, log)
+	var status domain.StorageStatus
+	if *statusOnly {
+		status, err = service.Status(ctx)
+	} else {
+		status, err = service.Migrate(ctx)
+	}
+	if err != nil {
+		return err
+	}
+
+	if status.Schema == nil {
+		fmt.Printf("%s storage has no schema to migrate\n", status.Backend)
+		return nil
+	}
+	fmt.Printf("backend: %s\nversion: %d\nlatest:  %d\npending: %d\n", status.Backend, status.Schema.Version, status.Schema.Latest, status.Schema.Pending)
+	return nil
+}
+
+func migrateStorage(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("migrate-storage", flag.ExitOnError)
+	backends := strings.Join(infrastructure.StorageBackends(), ", ")
+	from := fs.String("from", "", "source storage backend ("+backends+")")
+	fromDSN := fs.String("from-dsn", "", "source data source name")
+	to := fs.String("to", "", "destination storage backend ("+backends+")")
+	toDSN := fs.String("to-dsn", "", "destination data source name")
+	start := fs.String("start", "", "first day to copy (YYYY-MM-DD)")
+	end := fs.String("end", time.Now().UTC().Format("2006-01-02"), "last day to copy (YYYY-MM-DD)")
+	datasets := fs.String("datasets", strings.Join(domain.StorageDatasets, ","), "comma separated datasets to copy")
+	dryRun := fs.Bool("dry-run", false, "count source records without writing")
+	jsonOutput := fs.Bool("json", false, "print the verification report as JSON")
+	logLevel := fs.String("log-level", "warn", "log level")
+	fs.Parse(args)
+
+	if *from == "" || *to == "" || *start == "" {
+		fs.Usage()
+		return fmt.Errorf("--from, --to and --start are required")
+	}
+	if *from == *to && *fromDSN == *toDSN {
+		return fmt.Errorf("source and destination are the same storage")
+	}
+
+	opts := domain.MigrationOptions{DryRun: *dryRun}
+	var err error
+	if opts.Start, err = time.Parse("2006-01-02", *start); err != nil {
+		return fmt.Errorf("invalid --start: %w", err)
+	}
+	if opts.End, err = time.Parse("2006-01-02", *end); err != nil {
+		return fmt.Errorf("invalid --end: %w", err)
+	}
+	for _, dataset := range strings.Split(*datasets, ",") {
+		if dataset = strings.TrimSpace(dataset); dataset != "" {
+			opts.Datasets = append(opts.Datasets, dataset)
+		}
+	}
+
+	log := logger.New(*logLevel)
+
+	source, err := infrastructure.OpenStorage(*from, infrastructure.StorageOptions{DSN: *fromDSN}, log)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	destination, err := infrastructure.OpenStorage(*to, infrastructure.StorageOptions{DSN: *toDSN}, log)
+	if err != nil {
+		return err
+	}
+	defer destination.Close()
+
+	migrator := usecase.NewStorageMigrator(source, destination, log)
+	reports, err := migrator.Migrate(ctx, opts, func(p domain.MigrationProgress) {
+		if p.Records > 0 || p.Day == p.Days {
+			fmt.Fprintf(os.Stderr, "%-8s %s  day %d/%d  %d records\n", p.Dataset, p.Date.Format("2006-01-02"), p.Day, p.Days, p.Records)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(reports); err != nil {
+			return err
+		}
+	} else {
+		printMigrationReports(reports, opts.DryRun)
+	}
+
+	for _, report := range reports {
+		if !report.Verified() {
+			return fmt.Errorf("verification failed for %s on %d days", report.Dataset, len(report.Mismatches))
+		}
+	}
+	return nil
+}
+
+func checkStorage(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("check-storage", flag.ExitOnError)
+	backend := fs.String("backend", "", "storage backend ("+strings.Join(infrastructure.StorageBackends(), ", ")+")")
+	dsn := fs.String("dsn", "", "data source name of an empty scratch database")
+	jsonOutput := fs.Bool("json", false, "print the results as JSON")
+	logLevel := fs.String("log-level", "warn", "log level")
+	fs.Parse(args)
+
+	if *backend == "" {
+		fs.Usage()
+		return fmt.Errorf("--backend is required")
+	}
+
+	log := logger.New(*logLevel)
+	storage, err := infrastructure.OpenStorage(*backend, infrastructure.StorageOptions{DSN: *dsn}, log)
+	if err != nil {
+		return err
+	}
+	defer storage.Close()
+
+	if _, err := usecase.NewStorageService(storage, log).Migrate(ctx); err != nil {
+		return err
+	}
+	results, err := storagetest.Check(ctx, storage, log)
+	if err != nil {
+		return err
+	}
+
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(results); err != nil {
+			return err
+		}
+	} else {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "CHECK\tDATASET\tSTATUS")
+		for _, result := range results {
+			status := "passed"
+			if !result.Passed {
+				status = "failed: " + result.Error
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", result.Check, result.Dataset, status)
+		}
+		w.Flush()
+	}
+
+	failed := 0
+	for _, result := range results {
+		if !result.Passed {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d conformance checks failed on %s storage", failed, len(results), storage.Backend)
+	}
+	return nil
+}
+
+func printMigrationReports(reports []domain.MigrationReport, dryRun bool) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "DATASET\tDAYS\tSOURCE\tCOPIED\tDESTINATION\tSTATUS")
+	for _, report := range reports {
+		status := "verified"
+		switch {
+		case dryRun:
+			status = "dry run"
+		case !report.Verified():
+			status = fmt.Sprintf("%d mismatched days", len(report.Mismatches))
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%s\n", report.Dataset, report.Days, report.Source, report.Copied, report.Destination, status)
+	}
+	w.Flush()
+
+	for _, report := range reports {
+		for _, day := range report.Mismatches {
+			fmt.Fprintf(os.Stdout, "mismatch: %s %s\n", report.Dataset, day)
+		}
+	}
+}
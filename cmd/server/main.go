@@ -1,11 +1,16 @@
 package main
 
 import (
+	"cmp"
 	"context"
 	"etlgo/internal/delivery"
+	"etlgo/internal/delivery/middleware"
+	"etlgo/internal/domain"
 	"etlgo/internal/infrastructure"
 	"etlgo/internal/usecase"
+	"etlgo/pkg/buildinfo"
 	"etlgo/pkg/config"
+	"etlgo/pkg/cpulimit"
 	"etlgo/pkg/logger"
 	"etlgo/pkg/metrics"
 	"fmt"
@@ -24,54 +29,911 @@ func main() {
 	}
 
 	log := logger.New(cfg.Logging.Level)
-	log.Info("Starting server")
+	build := buildinfo.Get()
+	log.WithFields(map[string]any{
+		"version":    build.Version,
+		"commit":     build.Commit,
+		"build_date": build.BuildDate,
+		"go_version": build.GoVersion,
+	}).Info("Starting server")
 
-	metrics := metrics.New()
+	// Size the worker pool for the CPUs the container may use rather than
+	// the host's, unless configured
+	cpus := cpulimit.Detect()
+	workerPoolSource := "config"
+	if cfg.ETL.WorkerPoolSize <= 0 {
+		cfg.ETL.WorkerPoolSize = cpus.GOMAXPROCS
+		workerPoolSource = "gomaxprocs"
+	}
+	log.WithFields(map[string]any{
+		"num_cpu":            cpus.NumCPU,
+		"cpu_quota":          cpus.Quota,
+		"gomaxprocs":         cpus.GOMAXPROCS,
+		"gomaxprocs_source":  cpus.Source,
+		"worker_pool_size":   cfg.ETL.WorkerPoolSize,
+		"worker_pool_source": workerPoolSource,
+	}).Info("CPU limits")
+
+	metrics := metrics.New(metrics.Options{
+		TenantLimit: cfg.Telemetry.TenantLabelLimit,
+		TopTenants:  cfg.Telemetry.TopTenants,
+	})
+
+	// The clock services tell the time by, pinned or shifted for tests and
+	// backfills
+	var frozenAt *time.Time
+	if cfg.Server.ClockFrozenAt != "" {
+		if cfg.Server.Environment == "production" {
+			log.Fatal("A frozen clock cannot be used in production")
+		}
+		at, err := time.Parse(time.RFC3339, cfg.Server.ClockFrozenAt)
+		if err != nil {
+			log.WithError(err).Fatal("Invalid CLOCK_FROZEN_AT, expected an RFC 3339 time")
+		}
+		frozenAt = &at
+	}
+	clock := domain.NewClock(frozenAt, cfg.Server.ClockOffset)
+	if clock != domain.SystemClock {
+		log.WithFields(map[string]any{
+			"frozen_at": cfg.Server.ClockFrozenAt,
+			"offset":    cfg.Server.ClockOffset.String(),
+			"now":       clock.Now().Format(time.RFC3339),
+		}).Warn("Service clock differs from the system clock")
+	}
+	// Run and ingest job IDs, time-sortable as ULIDs
+	ids, err := domain.NewIDGenerator(cfg.Server.IDFormat, clock)
+	if err != nil {
+		log.WithError(err).Fatal("Invalid ID_FORMAT")
+	}
 
 	// Initialize repositories
-	adRepo := infrastructure.NewAdRepository(log)
-	crmRepo := infrastructure.NewCRMRepository(log)
-	metricsRepo := infrastructure.NewMetricsRepository(log)
+	storage, err := infrastructure.OpenStorage(cfg.Storage.Backend, infrastructure.StorageOptions{
+		DSN:           cfg.Storage.DSN,
+		ReadDSNs:      cfg.Storage.ReadDSNs,
+		MaxReplicaLag: cfg.Storage.MaxReplicaLag,
+
+		MaxOpenConns:    cfg.Storage.MaxOpenConns,
+		MaxIdleConns:    cfg.Storage.MaxIdleConns,
+		ConnMaxLifetime: cfg.Storage.ConnMaxLifetime,
+
+		Clock: clock,
+	}, log)
+	if err != nil {
+		log.WithError(err).Fatal("Failed to open storage")
+	}
+	defer storage.Close()
+
+	// Fault injection for resilience testing in staging
+	var faults *infrastructure.FaultInjector
+	if cfg.Faults.Enabled {
+		if cfg.Server.Environment == "production" {
+			log.Fatal("Fault injection cannot be enabled in production")
+		}
+		faultOptions := infrastructure.FaultOptions{
+			FailPercent:  cfg.Faults.FailPercent,
+			DelayPercent: cfg.Faults.DelayPercent,
+			MaxDelay:     cfg.Faults.MaxDelay,
+			Targets:      cfg.Faults.Targets,
+		}
+		if err := faultOptions.Validate(); err != nil {
+			log.WithError(err).Fatal("Invalid fault injection configuration")
+		}
+		faults = infrastructure.NewFaultInjector(faultOptions, log, metrics)
+		faults.WrapStorage(storage)
+		log.WithFields(map[string]any{
+			"fail_percent":  faultOptions.FailPercent,
+			"delay_percent": faultOptions.DelayPercent,
+			"targets":       cfg.Faults.Targets,
+		}).Warn("Fault injection enabled")
+	}
+
+	// Limits concurrent reads and writes so dashboards and loads share storage
+	infrastructure.NewStorageBulkhead(infrastructure.BulkheadOptions{
+		MaxReads:         cfg.Storage.MaxConcurrentReads,
+		ReadQueue:        cfg.Storage.ReadQueue,
+		ReadQueueTimeout: cfg.Storage.ReadQueueTimeout,
+		MaxWrites:        cfg.Storage.MaxConcurrentWrites,
+	}, log, metrics).WrapStorage(storage)
+
+	storageService := usecase.NewStorageService(storage, log)
+	if cfg.Storage.AutoMigrate {
+		if _, err := storageService.Migrate(context.Background()); err != nil {
+			log.WithError(err).Fatal("Failed to migrate storage schema")
+		}
+	} else if status, err := storageService.Status(context.Background()); err != nil {
+		log.WithError(err).Fatal("Failed to read storage schema version")
+	} else if status.Schema != nil && status.Schema.Pending > 0 {
+		log.WithField("pending", status.Schema.Pending).Warn("Storage schema has pending migrations, run etlctl migrate")
+	}
+
+	adRepo := storage.Ads
+	crmRepo := storage.CRM
+	metricsRepo := storage.Metrics
+	pipelineRepo := infrastructure.NewPipelineRepository(clock, log)
+	quarantineRepo := infrastructure.NewQuarantineRepository(cfg.ETL.QuarantineMaxRecords, clock, log)
+	runRepo := infrastructure.NewRunRepository(log)
+	checkpointRepo, err := infrastructure.NewCheckpointRepository(cfg.ETL.CheckpointFile, log)
+	if err != nil {
+		log.WithError(err).Fatal("Failed to load extraction checkpoints")
+	}
+	objectRepo, err := infrastructure.NewSourceObjectRepository(cfg.External.IngestedObjectsFile, log)
+	if err != nil {
+		log.WithError(err).Fatal("Failed to load ingested objects")
+	}
+	restatementRepo := infrastructure.NewRestatementRepository(log)
+	eventLog := infrastructure.NewEventLogRepository(clock, log)
+	analyticsRepo := infrastructure.NewAnalyticsRepository(log)
+	exportHoldRepo := infrastructure.NewExportHoldRepository(log)
+
+	// Load upstream field mappings
+	fieldMapper, err := infrastructure.LoadFieldMapper(cfg.External.FieldMappingFile)
+	if err != nil {
+		log.WithError(err).Fatal("Invalid field mapping configuration")
+	}
+
+	// Recorded upstream responses for reproducible runs without the live APIs
+	cassette, err := infrastructure.NewCassette(cfg.External.CassetteMode, cfg.External.CassetteDir, log)
+	if err != nil {
+		log.WithError(err).Fatal("Invalid upstream cassette configuration")
+	}
 
 	// Initialize HTTP client
+	transportOptions := infrastructure.TransportOptions{
+		HTTP2:               cfg.External.HTTP2,
+		KeepAlive:           cfg.External.KeepAlive,
+		IdleConnTimeout:     cfg.External.IdleConnTimeout,
+		MaxIdleConnsPerHost: cfg.External.MaxIdleConnsPerHost,
+		Prewarm:             cfg.External.Prewarm,
+		HedgeDelay:          cfg.External.HedgeDelay,
+		Faults:              faults,
+	}
+	rateLimits := infrastructure.RateLimitOptions{
+		PerSecond: cfg.ETL.RateLimitPerSecond,
+		Burst:     cfg.ETL.RateLimitBurst,
+	}
+	if rateLimits.Limits, err = domain.ParseQuotaLimits(cfg.ETL.UpstreamRateLimits); err != nil {
+		log.WithError(err).Fatal("Invalid rate limit configuration")
+	}
+	if rateLimits.Bursts, err = domain.ParseQuotaLimits(cfg.ETL.UpstreamRateBursts); err != nil {
+		log.WithError(err).Fatal("Invalid rate limit configuration")
+	}
+	if err := rateLimits.Validate(); err != nil {
+		log.WithError(err).Fatal("Invalid rate limit configuration")
+	}
 	httpClient := infrastructure.NewHTTPClient(
 		cfg.External.AdsAPIURL,
 		cfg.External.CRMAPIURL,
+		cfg.External.KeywordsAPIURL,
+		cfg.External.SinceParam,
 		cfg.External.SinkURL,
 		cfg.External.SinkSecret,
+		infrastructure.SinkOptions{
+			Compression:     cfg.Export.SinkCompression,
+			ChunkSize:       cfg.Export.SinkChunkSize,
+			MaxRetries:      cfg.ETL.MaxRetries,
+			RetryBackoff:    cfg.ETL.RetryBackoff,
+			Workers:         cfg.ETL.WorkerPoolSize,
+			UnorderedChunks: cfg.Export.SinkUnorderedChunks,
+		},
+		transportOptions,
+		rateLimits,
+		cassette,
+		fieldMapper,
 		cfg.ETL.RequestTimeout,
 		log,
 		metrics,
 	)
 
+	// Optional GA4 sessions source; runs extract it only when configured
+	var analyticsClient domain.AnalyticsClient
+	ga4Client, err := infrastructure.NewGA4Client(
+		cfg.External.GA4PropertyID,
+		cfg.External.GA4CredentialsFile,
+		cfg.External.GA4APIURL,
+		cfg.External.GA4ConversionMetric,
+		transportOptions,
+		cassette,
+		cfg.ETL.RequestTimeout,
+		log,
+		metrics,
+	)
+	if err != nil {
+		log.WithError(err).Fatal("Invalid GA4 configuration")
+	}
+
 	// Initialize services
+	parsePolicy := domain.ParsePolicy{
+		Mode:            domain.ParseMode(cfg.ETL.ParseMode),
+		MaxErrors:       cfg.ETL.ParseMaxErrors,
+		MaxErrorPercent: cfg.ETL.ParseMaxErrorPercent,
+	}
+	if err := parsePolicy.Validate(); err != nil {
+		log.WithError(err).Fatal("Invalid parse policy configuration")
+	}
+
+	valuePolicies, err := infrastructure.LoadValuePolicies(cfg.ETL.ValuePolicyFile)
+	if err != nil {
+		log.WithError(err).Fatal("Invalid value policy configuration")
+	}
+
+	datePolicy := domain.DateSkewPolicy{
+		Action:    domain.DateSkewAction(cfg.ETL.DateSkewPolicy),
+		MaxFuture: cfg.ETL.DateMaxFuture,
+		MaxAge:    cfg.ETL.DateMaxAge,
+	}
+	if err := datePolicy.Validate(); err != nil {
+		log.WithError(err).Fatal("Invalid date skew policy configuration")
+	}
+
+	fingerprinter, err := infrastructure.NewHashFingerprinter(cfg.ETL.FingerprintAlgorithm)
+	if err != nil {
+		log.WithError(err).Fatal("Invalid fingerprint configuration")
+	}
+
+	attribution := domain.AttributionModel{
+		Mode:     cfg.ETL.AttributionMode,
+		HalfLife: cfg.ETL.AttributionHalfLife,
+	}
+	if err := attribution.Validate(); err != nil {
+		log.WithError(err).Fatal("Invalid attribution configuration")
+	}
+
+	recognition, err := domain.ParseRevenueRecognition(cfg.ETL.RevenueRecognition)
+	if err != nil {
+		log.WithError(err).Fatal("Invalid revenue recognition configuration")
+	}
+	utmMatcher := domain.UTMMatcher{Threshold: cfg.ETL.FuzzyUTMThreshold}
+	if err := utmMatcher.Validate(); err != nil {
+		log.WithError(err).Fatal("Invalid fuzzy UTM matching configuration")
+	}
+	funnel, err := domain.ParseFunnel(cfg.ETL.FunnelStages)
+	if err != nil {
+		log.WithError(err).Fatal("Invalid funnel configuration")
+	}
+	probabilities, err := domain.ParseStageProbabilities(cfg.ETL.StageProbabilities, funnel)
+	if err != nil {
+		log.WithError(err).Fatal("Invalid stage probability configuration")
+	}
+
+	// Stored amounts are in the base currency, opportunities reported in
+	// others are converted with the stored FX rates
+	baseCurrency, err := domain.NormalizeCurrency(cfg.Reporting.BaseCurrency)
+	if err != nil {
+		log.WithError(err).Fatal("Invalid base currency")
+	}
+	fxRateRepo := infrastructure.NewFXRateRepository(log)
+
+	spendPolicy := domain.SpendAnomalyPolicy{
+		Factor:       cfg.Export.HoldSpendFactor,
+		BaselineDays: cfg.Export.HoldBaselineDays,
+	}
+	if err := spendPolicy.Validate(); err != nil {
+		log.WithError(err).Fatal("Invalid export hold configuration")
+	}
+
+	actionPolicy := domain.ActionPolicy{
+		CPACap:        domain.MoneyFromFloat(cfg.Actions.CPACap),
+		DailySpendCap: domain.MoneyFromFloat(cfg.Actions.DailySpendCap),
+		Days:          cfg.Actions.CapDays,
+	}
+	if err := actionPolicy.Validate(); err != nil {
+		log.WithError(err).Fatal("Invalid action configuration")
+	}
+
+	flagProvider, err := infrastructure.NewConfigFlagProvider(cfg.Flags.File, cfg.Server.Environment, cfg.Flags.Overrides, log)
+	if err != nil {
+		log.WithError(err).Fatal("Invalid feature flag configuration")
+	}
+
+	upstreamQuotas, err := domain.ParseQuotaLimits(cfg.Quota.UpstreamCallsPerDay)
+	if err != nil {
+		log.WithError(err).Fatal("Invalid upstream quota configuration")
+	}
+	recordQuotas, err := domain.ParseQuotaLimits(cfg.Quota.RecordsPerMonth)
+	if err != nil {
+		log.WithError(err).Fatal("Invalid record quota configuration")
+	}
+	metrics.RegisterTenants(domain.DefaultTenant)
+	for tenant := range recordQuotas {
+		if tenant != domain.QuotaWildcard {
+			metrics.RegisterTenants(tenant)
+		}
+	}
+	quotaRepo, err := infrastructure.NewQuotaRepository(cfg.Quota.UsageFile, log)
+	if err != nil {
+		log.WithError(err).Fatal("Failed to load quota usage")
+	}
+	quotaService := usecase.NewQuotaService(
+		quotaRepo,
+		upstreamQuotas,
+		recordQuotas,
+		clock,
+		log,
+		metrics,
+	)
+	if ga4Client != nil {
+		analyticsClient = quotaService.AnalyticsClient(ga4Client)
+	}
+
+	// Ads and CRM each come from the APIs, the CSV files they export or
+	// the files they drop in an object store
+	csvExtractor := infrastructure.NewCSVExtractor(cfg.External.AdsCSVPath, cfg.External.CRMCSVPath, fieldMapper, metrics)
+	var objectExtractor *infrastructure.ObjectStoreExtractor
+	if cfg.External.AdsExtractor == "object" || cfg.External.CRMExtractor == "object" {
+		if cfg.External.ObjectStoreBucket == "" {
+			log.Fatal("The object extractor needs OBJECT_STORE_BUCKET")
+		}
+		options := infrastructure.S3Options{
+			Bucket:    cfg.External.ObjectStoreBucket,
+			Region:    cfg.External.ObjectStoreRegion,
+			Endpoint:  cfg.External.ObjectStoreEndpoint,
+			AccessKey: cfg.External.ObjectStoreAccessKey,
+			SecretKey: cfg.External.ObjectStoreSecretKey,
+		}
+		switch cfg.External.ObjectStoreProvider {
+		case "s3":
+			options.Region = cmp.Or(options.Region, "us-east-1")
+		case "gcs":
+			options.Region = cmp.Or(options.Region, "auto")
+			options.Endpoint = cmp.Or(options.Endpoint, "https://storage.googleapis.com")
+		default:
+			log.WithField("provider", cfg.External.ObjectStoreProvider).Fatal("OBJECT_STORE_PROVIDER must be s3 or gcs")
+		}
+		objectExtractor = infrastructure.NewObjectStoreExtractor(
+			infrastructure.NewS3Client(options, cfg.ETL.RequestTimeout, metrics),
+			cfg.External.AdsObjectPrefix,
+			cfg.External.CRMObjectPrefix,
+			objectRepo,
+			fieldMapper,
+			log,
+			metrics,
+		)
+	}
+	extractorFor := func(source, name, csvPath string) domain.ExternalAPIClient {
+		switch name {
+		case "api":
+			return httpClient
+		case "csv":
+			if csvPath == "" {
+				log.WithField("source", source).Fatal("The csv extractor needs the source's CSV path")
+			}
+			return csvExtractor
+		case "object":
+			return objectExtractor
+		}
+		log.WithFields(map[string]any{"source": source, "extractor": name}).Fatal("Extractors are api, csv or object")
+		return nil
+	}
+
+	// Runs extract every registered connector: the built-in sources first,
+	// then the native Meta Ads and Salesforce ones and those declared in
+	// CONNECTORS_FILE
+	extractor := infrastructure.NewConnectorRegistry()
+	extractor.Register(
+		domain.ConnectorInfo{Name: domain.SourceAds, Type: domain.SourceAds, BuiltIn: true, Extractor: cfg.External.AdsExtractor},
+		extractorFor(domain.SourceAds, cfg.External.AdsExtractor, cfg.External.AdsCSVPath),
+	)
+	extractor.Register(
+		domain.ConnectorInfo{Name: domain.SourceCRM, Type: domain.SourceCRM, BuiltIn: true, Extractor: cfg.External.CRMExtractor},
+		extractorFor(domain.SourceCRM, cfg.External.CRMExtractor, cfg.External.CRMCSVPath),
+	)
+	metaClient, err := infrastructure.NewMetaAdsClient(
+		infrastructure.MetaOptions{
+			AdAccountID:     cfg.External.MetaAdAccountID,
+			AccessToken:     cfg.External.MetaAccessToken,
+			AppID:           cfg.External.MetaAppID,
+			AppSecret:       cfg.External.MetaAppSecret,
+			APIURL:          cfg.External.MetaAPIURL,
+			TokenFile:       cfg.External.MetaTokenFile,
+			ThrottlePercent: cfg.External.MetaThrottlePercent,
+			MaxBackoff:      cfg.External.MetaMaxBackoff,
+			MaxRetries:      cfg.ETL.MaxRetries,
+			RetryBackoff:    cfg.ETL.RetryBackoff,
+		},
+		transportOptions,
+		cassette,
+		cfg.ETL.RequestTimeout,
+		log,
+		metrics,
+	)
+	if err != nil {
+		log.WithError(err).Fatal("Invalid Meta configuration")
+	}
+	if metaClient != nil {
+		extractor.Register(domain.ConnectorInfo{Name: "meta", Type: domain.SourceAds, Extractor: "meta", URL: metaClient.URL()}, metaClient)
+	}
+	salesforceStages, err := domain.ParseStageMapping(cfg.External.SalesforceStageMap, funnel)
+	if err != nil {
+		log.WithError(err).Fatal("Invalid Salesforce stage mapping")
+	}
+	salesforceClient, err := infrastructure.NewSalesforceClient(
+		infrastructure.SalesforceOptions{
+			ClientID:       cfg.External.SalesforceClientID,
+			Username:       cfg.External.SalesforceUsername,
+			PrivateKeyFile: cfg.External.SalesforcePrivateKeyFile,
+			LoginURL:       cfg.External.SalesforceLoginURL,
+			APIVersion:     cfg.External.SalesforceAPIVersion,
+			Query:          cfg.External.SalesforceQuery,
+			Fields:         cfg.External.SalesforceFields,
+			Stages:         salesforceStages,
+			MaxRetries:     cfg.ETL.MaxRetries,
+			RetryBackoff:   cfg.ETL.RetryBackoff,
+		},
+		transportOptions,
+		cassette,
+		cfg.ETL.RequestTimeout,
+		log,
+		metrics,
+	)
+	if err != nil {
+		log.WithError(err).Fatal("Invalid Salesforce configuration")
+	}
+	if salesforceClient != nil {
+		extractor.Register(domain.ConnectorInfo{Name: "salesforce", Type: domain.SourceCRM, Extractor: "salesforce", URL: salesforceClient.URL()}, salesforceClient)
+	}
+	connectors, err := infrastructure.LoadConnectors(cfg.External.ConnectorsFile)
+	if err != nil {
+		log.WithError(err).Fatal("Failed to load connectors")
+	}
+	for _, connector := range connectors {
+		client, err := httpClient.Connector(connector)
+		if err == nil {
+			err = extractor.Register(connector.Info(), client)
+		}
+		if err != nil {
+			log.WithError(err).Fatal("Failed to register connector")
+		}
+	}
+	if cfg.External.UploadMaxBytes <= 0 {
+		log.Fatal("UPLOAD_MAX_BYTES must be positive")
+	}
+
+	// Optional keyword level ads feed, kept apart from the campaign ads
+	var keywordClient domain.KeywordClient
+	if feed := httpClient.Keywords(); feed != nil {
+		keywordClient = quotaService.KeywordClient(feed)
+	}
+
+	// Run notifications link to the run detail endpoint
+	notificationChannels, err := infrastructure.LoadNotificationChannels(cfg.Notify.ChannelsFile)
+	if err != nil {
+		log.WithError(err).Fatal("Invalid notification channel configuration")
+	}
+	baseURL := cfg.Notify.BaseURL
+	if baseURL == "" {
+		baseURL = "http://localhost:" + cfg.Server.Port
+	}
+	notificationService, err := usecase.NewNotificationService(
+		notificationChannels,
+		infrastructure.NewNotificationSender(infrastructure.SMTPOptions{
+			Addr:     cfg.Notify.SMTPAddr,
+			From:     cfg.Notify.SMTPFrom,
+			Username: cfg.Notify.SMTPUsername,
+			Password: cfg.Notify.SMTPPassword,
+		}, cfg.ETL.RequestTimeout),
+		baseURL,
+		log,
+		metrics,
+	)
+	if err != nil {
+		log.WithError(err).Fatal("Invalid notification template")
+	}
+
+	if err := domain.ValidateGapPolicy(cfg.ETL.GapPolicy); err != nil {
+		log.WithError(err).Fatal("Invalid gap policy configuration")
+	}
+	certifier, err := infrastructure.NewRunCertifier(cfg.Certification.SigningKey, cfg.Certification.KeyID, cfg.Hash())
+	if err != nil {
+		log.WithError(err).Fatal("Invalid run certification configuration")
+	}
+	exportDestinations, err := infrastructure.LoadExportDestinations(cfg.Export.DestinationsFile)
+	if err != nil {
+		log.WithError(err).Fatal("Invalid export destination configuration")
+	}
+	if err := domain.ValidateExportMode(cfg.Export.Mode); err != nil {
+		log.WithError(err).Fatal("Invalid export mode configuration")
+	}
+	exportSnapshotRepo, err := infrastructure.NewExportSnapshotRepository(cfg.Export.SnapshotFile, log)
+	if err != nil {
+		log.WithError(err).Fatal("Failed to load export snapshots")
+	}
+	// Exports to sinks that process them asynchronously await a signed ack
+	if cfg.Export.AckTimeout < 0 || cfg.Export.AckTimeout > 0 && cfg.Export.AckSecret == "" {
+		log.WithField("ack_timeout", cfg.Export.AckTimeout.String()).Fatal("Invalid export ack configuration, a positive EXPORT_ACK_TIMEOUT requires EXPORT_ACK_SECRET or SINK_SECRET")
+	}
+	for _, destination := range exportDestinations {
+		if cfg.Export.AckTimeout > 0 && destination.Secret == "" {
+			log.WithField("destination", destination.Name).Fatal("Invalid export ack configuration, a positive EXPORT_ACK_TIMEOUT requires a secret for every export destination")
+		}
+	}
+	exportAcks := usecase.NewExportAckService(
+		infrastructure.NewExportDeliveryRepository(log),
+		cfg.Export.AckTimeout,
+		cfg.Export.AckSecret,
+		exportDestinations,
+		notificationService,
+		clock,
+		ids,
+		log,
+		metrics,
+	)
+	derivedModels, err := infrastructure.LoadDerivedModels(cfg.Reporting.ModelsFile)
+	if err != nil {
+		log.WithError(err).Fatal("Invalid derived model configuration")
+	}
+	modelService := usecase.NewModelService(
+		derivedModels,
+		metricsRepo,
+		infrastructure.NewDerivedModelRepository(log),
+		httpClient,
+		exportDestinations,
+		exportAcks,
+		clock,
+		log,
+		metrics,
+	)
 	etlService := usecase.NewETLService(
 		adRepo,
 		crmRepo,
 		metricsRepo,
-		httpClient,
+		quarantineRepo,
+		runRepo,
+		checkpointRepo,
+		objectRepo,
+		restatementRepo,
+		eventLog,
+		analyticsRepo,
+		infrastructure.NewKeywordRepository(log),
+		exportHoldRepo,
+		flagProvider,
+		quotaService,
+		notificationService,
+		modelService,
+		quotaService.Client(extractor),
+		analyticsClient,
+		keywordClient,
+		csvExtractor,
 		log,
 		metrics,
 		cfg.ETL.WorkerPoolSize,
 		cfg.ETL.BatchSize,
+		cfg.ETL.PushMaxRecords,
+		parsePolicy,
+		valuePolicies,
+		datePolicy,
+		attribution,
+		recognition,
+		utmMatcher,
+		cfg.ETL.CRMOnlyMetrics,
+		funnel,
+		probabilities,
+		fxRateRepo,
+		baseCurrency,
+		spendPolicy,
+		actionPolicy,
+		cfg.ETL.GapPolicy,
+		fingerprinter,
+		infrastructure.NewShadowRepository(log),
+		infrastructure.NewDatasetRepository(log),
+		certifier,
+		clock,
+		ids,
 	)
 
+	queryBudgets, err := domain.ParseQuotaLimits(cfg.Quota.QueryRowBudgets)
+	if err != nil {
+		log.WithError(err).Fatal("Invalid query budget configuration")
+	}
+	if err := domain.ValidateQueryBudgetMode(cfg.Quota.QueryBudgetMode); err != nil {
+		log.WithError(err).Fatal("Invalid query budget configuration")
+	}
+	roleList, err := infrastructure.LoadRoles(cfg.Reporting.RolesFile)
+	if err != nil {
+		log.WithError(err).Fatal("Failed to load roles")
+	}
+	roles, err := domain.NewRoles(roleList)
+	if err != nil {
+		log.WithError(err).Fatal("Invalid role configuration")
+	}
 	metricsService := usecase.NewMetricsService(
 		metricsRepo,
+		runRepo,
+		exportHoldRepo,
+		exportSnapshotRepo,
+		httpClient,
+		exportDestinations,
+		cfg.Export.Mode,
+		queryBudgets,
+		cfg.Quota.QueryBudgetMode,
+		roles,
+		fxRateRepo,
+		baseCurrency,
+		funnel,
+		cfg.ETL.PushMaxRecords,
+		exportAcks,
+		clock,
+		log,
+		metrics,
+	)
+	fxRates, err := infrastructure.LoadFXRates(cfg.Reporting.FXRatesFile)
+	if err != nil {
+		log.WithError(err).Fatal("Failed to load FX rates")
+	}
+	if len(fxRates) > 0 {
+		if err := metricsService.PutFXRates(context.Background(), fxRates); err != nil {
+			log.WithError(err).Fatal("Failed to load FX rates")
+		}
+	}
+
+	// Optional object storage destination with client-side encryption
+	var s3Client *infrastructure.S3Client
+	if cfg.Export.S3Bucket != "" {
+		s3Client = infrastructure.NewS3Client(infrastructure.S3Options{
+			Bucket:    cfg.Export.S3Bucket,
+			Region:    cfg.Export.S3Region,
+			Endpoint:  cfg.Export.S3Endpoint,
+			Prefix:    cfg.Export.S3Prefix,
+			AccessKey: cfg.Export.S3AccessKey,
+			SecretKey: cfg.Export.S3SecretKey,
+		}, cfg.ETL.RequestTimeout, metrics)
+	}
+
+	var encryptor *infrastructure.PayloadEncryptor
+	if cfg.Export.EncryptionKey != "" {
+		encryptor, err = infrastructure.NewPayloadEncryptor(cfg.Export.EncryptionKey, cfg.Export.EncryptionKeyID)
+		if err != nil {
+			log.WithError(err).Fatal("Invalid export encryption configuration")
+		}
+	}
+
+	rawExportService := usecase.NewRawExportService(
+		adRepo,
+		crmRepo,
+		infrastructure.NewRawExporter(httpClient, s3Client, encryptor, cfg.Export.RawExportDir, cfg.ETL.WorkerPoolSize, log),
+		flagProvider,
+		log,
+		metrics,
+	)
+
+	// Pipeline schedules and the calendars they skip
+	scheduleLocation, err := time.LoadLocation(cfg.Schedule.Timezone)
+	if err != nil {
+		log.WithError(err).Fatal("Invalid scheduler timezone")
+	}
+	if cfg.Schedule.LateDataDays < 0 {
+		log.WithField("late_data_days", cfg.Schedule.LateDataDays).Fatal("Invalid scheduler late data window")
+	}
+	holidayCalendars, err := infrastructure.LoadHolidayCalendars(cfg.Schedule.HolidayCalendars)
+	if err != nil {
+		log.WithError(err).Fatal("Invalid holiday calendar configuration")
+	}
+
+	pipelineService := usecase.NewPipelineService(
+		pipelineRepo,
+		metricsRepo,
+		etlService,
+		metricsService,
+		holidayCalendars,
+		scheduleLocation,
+		cfg.Schedule.LateDataDays,
+		clock,
+		log,
+		metrics,
+	)
+
+	// Pipelines defined in configuration
+	configuredPipelines, err := infrastructure.LoadPipelines(cfg.Schedule.PipelinesFile)
+	if err != nil {
+		log.WithError(err).Fatal("Invalid pipeline configuration")
+	}
+	if cfg.Schedule.ETLSchedule != "" {
+		// the full ETL as RunETL runs it, on the ETL_SCHEDULE cadence
+		sources := []string{domain.SourceAds, domain.SourceCRM}
+		if analyticsClient != nil {
+			sources = append(sources, domain.SourceGA4)
+		}
+		if keywordClient != nil {
+			sources = append(sources, domain.SourceKeywords)
+		}
+		configuredPipelines = append(configuredPipelines, domain.Pipeline{
+			Name:        domain.ScheduledETLPipeline,
+			Description: "Full ETL run on ETL_SCHEDULE",
+			Sources:     sources,
+			Schedule:    cfg.Schedule.ETLSchedule,
+		})
+	}
+	for _, pipeline := range configuredPipelines {
+		if _, err := pipelineService.CreatePipeline(context.Background(), pipeline, "config"); err != nil {
+			log.WithError(err).WithField("pipeline", pipeline.Name).Fatal("Invalid pipeline configuration")
+		}
+	}
+
+	flagService := usecase.NewFeatureFlagService(flagProvider, cfg.Server.Environment, log)
+
+	jobQueue := usecase.NewJobQueue(
+		map[domain.JobType]int{
+			domain.JobTypeIngest: cfg.Jobs.IngestConcurrency,
+			domain.JobTypeExport: cfg.Jobs.ExportConcurrency,
+		},
+		cfg.Jobs.MaxConcurrency,
+		time.Duration(cfg.Jobs.BackfillDays)*24*time.Hour,
+		clock,
+		log,
+		metrics,
+	)
+
+	scheduleHistory, err := infrastructure.NewScheduleHistoryRepository(cfg.Schedule.HistoryFile, log)
+	if err != nil {
+		log.WithError(err).Fatal("Failed to load schedule history")
+	}
+	watchdog := usecase.NewWatchdog(cfg.Jobs.WatchdogDeadline, infrastructure.NewJobIncidentRepository(log), ids, log, metrics)
+	scheduler := usecase.NewPipelineScheduler(
+		pipelineService,
+		jobQueue,
+		watchdog,
+		scheduleHistory,
+		cfg.Schedule.CatchUpLookback,
+		clock,
+		log,
+		metrics,
+	)
+
+	// Ingest runs requested through the API run in the background
+	ingestJobs := usecase.NewIngestJobService(
+		infrastructure.NewIngestJobRepository(log),
+		etlService,
+		pipelineService,
+		jobQueue,
+		baseURL,
+		cfg.Notify.LogsURL,
+		clock,
+		ids,
+		log,
+	)
+
+	// Optional streaming ingestion from Kafka topics
+	var streamConsumer domain.StreamConsumer
+	if cfg.External.KafkaRESTURL != "" {
+		kafkaConsumer, err := infrastructure.NewKafkaConsumer(infrastructure.KafkaOptions{
+			URL:         cfg.External.KafkaRESTURL,
+			Group:       cfg.External.KafkaGroup,
+			AdsTopic:    cfg.External.KafkaAdsTopic,
+			CRMTopic:    cfg.External.KafkaCRMTopic,
+			Username:    cfg.External.KafkaUsername,
+			Password:    cfg.External.KafkaPassword,
+			PollTimeout: cfg.External.KafkaPollTimeout,
+			MaxBytes:    cfg.External.KafkaMaxBytes,
+		}, fieldMapper, cfg.ETL.RequestTimeout, log, metrics)
+		if err != nil {
+			log.WithError(err).Fatal("Invalid Kafka configuration")
+		}
+		streamConsumer = kafkaConsumer
+	}
+	streamService := usecase.NewStreamService(
+		streamConsumer,
+		etlService,
+		jobQueue,
+		cfg.External.KafkaGroup,
+		cfg.External.StreamBatchSize,
+		cfg.External.StreamBatchWait,
+		cfg.External.StreamRetryBackoff,
+		clock,
+		ids,
+		log,
+		metrics,
+	)
+
+	maintenanceService := usecase.NewMaintenanceService(jobQueue, cfg.Jobs.MaintenanceRetryAfter, clock, log, metrics, scheduler)
+
+	if err := domain.ValidateApprovalActions(cfg.Jobs.ApprovalRequiredActions); err != nil {
+		log.WithError(err).Fatal("Invalid approval configuration")
+	}
+	approvalService := usecase.NewApprovalService(
+		infrastructure.NewApprovalRepository(log),
+		etlService,
+		pipelineService,
+		jobQueue,
+		notificationService,
+		cfg.Jobs.ApprovalRequiredActions,
+		clock,
+		ids,
+		log,
+		metrics,
+	)
+
+	// Settings operators may change while the service runs
+	configService := usecase.NewConfigService(
+		cfg.Redacted(),
 		httpClient,
+		etlService,
+		scheduler,
+		infrastructure.NewConfigChangeRepository(log),
+		clock,
+		ids,
 		log,
 		metrics,
 	)
 
+	if cfg.Reporting.ShareTokenMaxTTL <= 0 {
+		log.Fatal("SHARE_TOKEN_MAX_TTL must be positive")
+	}
+	shareTokenRevocations, err := infrastructure.NewShareTokenRevocationRepository(cfg.Reporting.ShareTokenRevocationsFile, log)
+	if err != nil {
+		log.WithError(err).Fatal("Failed to load share token revocations")
+	}
+	shareTokens := usecase.NewShareTokenService(cfg.Reporting.ShareTokenSecret, cfg.Reporting.ShareTokenMaxTTL, roles, shareTokenRevocations, clock, ids, log, metrics)
+	if cfg.External.WebhookTolerance <= 0 {
+		log.Fatal("Invalid WEBHOOK_TOLERANCE, expected a positive duration")
+	}
+	webhooks := usecase.NewWebhookService(etlService, cfg.External.WebhookAdsSecret, cfg.External.WebhookCRMSecret, cfg.External.WebhookTolerance, log, metrics)
+
 	handlers := delivery.NewHTTPHandlers(
 		etlService,
+		ingestJobs,
+		streamService,
+		webhooks,
 		metricsService,
+		modelService,
+		rawExportService,
+		exportAcks,
+		shareTokens,
+		pipelineService,
+		scheduler,
+		flagService,
+		quotaService,
+		notificationService,
+		maintenanceService,
+		storageService,
+		approvalService,
+		configService,
+		jobQueue,
+		watchdog,
+		int64(cfg.External.UploadMaxBytes),
+		clock,
 		log,
 		metrics,
 	)
 
 	// Initialize router
-	router := delivery.NewHTTPRouter(handlers, log, metrics)
+	apiKeys, err := domain.ParseAPIKeys(cfg.Reporting.APIKeys)
+	if err != nil {
+		log.WithError(err).Fatal("Invalid reporting API key configuration")
+	}
+	scopedKeys, err := infrastructure.LoadAPIKeys(cfg.Reporting.APIKeysFile)
+	if err != nil {
+		log.WithError(err).Fatal("Failed to load API keys")
+	}
+	for _, key := range scopedKeys {
+		if _, ok := roles[key.Role]; key.Role != "" && !ok {
+			log.WithFields(map[string]any{"key": key.Name, "role": key.Role}).Fatal("API key has an unknown role")
+		}
+		if err := apiKeys.Add(key); err != nil {
+			log.WithError(err).Fatal("Invalid API key configuration")
+		}
+		if key.Tenant != "" {
+			metrics.RegisterTenants(key.Tenant)
+		}
+	}
+	adminKeys, err := domain.ParseAPIKeys(cfg.Admin.APIKeys)
+	if err != nil {
+		log.WithError(err).Fatal("Invalid admin API key configuration")
+	}
+	producerKeys, err := domain.ParseAPIKeys(cfg.Reporting.ProducerKeys)
+	if err != nil {
+		log.WithError(err).Fatal("Invalid metrics producer key configuration")
+	}
+	if cfg.Server.LoadShedMaxInFlight < 0 || cfg.Server.LoadShedMaxP99 < 0 {
+		log.Fatal("Load shedding thresholds must not be negative")
+	}
+	// Turns away ingest runs and long unaggregated queries under load
+	shedder := middleware.NewLoadShedder(middleware.LoadShedOptions{
+		MaxInFlight: cfg.Server.LoadShedMaxInFlight,
+		MaxP99:      cfg.Server.LoadShedMaxP99,
+		Window:      cfg.Server.LoadShedWindow,
+		RetryAfter:  cfg.Server.LoadShedRetryAfter,
+		LongRange:   cfg.Server.LoadShedLongRange,
+	}, log, metrics)
+	router := delivery.NewHTTPRouter(handlers, apiKeys, adminKeys, producerKeys, shedder, ids, log, metrics)
 	server := &http.Server{
 		Addr:         ":" + cfg.Server.Port,
 		Handler:      router.SetupRoutes(),
@@ -80,6 +942,48 @@ func main() {
 		IdleTimeout:  30 * time.Second,
 	}
 
+	// Background jobs run under the watchdog, which restarts them when they
+	// stop beating for longer than WATCHDOG_DEADLINE
+
+	// Pick up flag file changes without a restart
+	flagCtx, stopFlagWatch := context.WithCancel(context.Background())
+	defer stopFlagWatch()
+	if cfg.Flags.ReloadInterval > 0 {
+		watchdog.Go(flagCtx, domain.BackgroundJobFlagReload, func(ctx context.Context, heartbeat domain.Heartbeat) {
+			flagProvider.Watch(ctx, cfg.Flags.ReloadInterval, heartbeat)
+		})
+	}
+
+	// Compact the ingest event log to the latest versions past its retention
+	compactionCtx, stopCompaction := context.WithCancel(context.Background())
+	defer stopCompaction()
+	if cfg.ETL.EventLogCompactInterval > 0 {
+		watchdog.Go(compactionCtx, domain.BackgroundJobEventCompaction, func(ctx context.Context, heartbeat domain.Heartbeat) {
+			etlService.WatchEventCompaction(ctx, cfg.ETL.EventLogCompactInterval, cfg.ETL.EventLogRetention, heartbeat)
+		})
+	}
+
+	// Flag exports not acknowledged by their sink within EXPORT_ACK_TIMEOUT
+	exportAckCtx, stopExportAcks := context.WithCancel(context.Background())
+	defer stopExportAcks()
+	if exportAcks.Enabled() {
+		watchdog.Go(exportAckCtx, domain.BackgroundJobExportAcks, exportAcks.WatchStuck)
+	}
+
+	// Load the Kafka topics continuously
+	streamCtx, stopStream := context.WithCancel(context.Background())
+	defer stopStream()
+	if streamService.Enabled() {
+		watchdog.Go(streamCtx, domain.BackgroundJobStreamConsumer, streamService.Consume)
+	}
+
+	// Run pipelines on their schedules
+	schedulerCtx, stopScheduler := context.WithCancel(context.Background())
+	defer stopScheduler()
+	if cfg.Schedule.Enabled || cfg.Schedule.ETLSchedule != "" {
+		watchdog.Go(schedulerCtx, domain.BackgroundJobScheduler, scheduler.Start)
+	}
+
 	// Start the server
 	go func() {
 		log.WithField("port", cfg.Server.Port).Info("Starting HTTP server")
@@ -93,7 +997,7 @@ func main() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
-	log.Info("Shutting down server...")
+	log.WithField("in_flight", shedder.InFlight()).Info("Shutting down server...")
 
 	// Create a deadline for shutdown
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
@@ -101,9 +1005,21 @@ func main() {
 
 	// Attempt graceful shutdown
 	if err := server.Shutdown(ctx); err != nil {
-		log.WithError(err).Error("Server forced to shutdown")
+		log.WithError(err).WithField("in_flight", shedder.InFlight()).Error("Server forced to shutdown")
 		os.Exit(1)
 	}
 
+	// Stop consuming the stream; a batch not loaded yet isn't committed and
+	// is consumed again after the restart
+	stopStream()
+	streamService.Wait()
+
+	// Let queued and running ingest jobs finish, cancelling them at the
+	// deadline
+	ingestJobs.Shutdown(ctx)
+
+	// Let notifications of finished runs go out
+	notificationService.Wait()
+
 	log.Info("Server exited")
 }
@@ -0,0 +1,54 @@
+package delivery
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetActions returns the campaigns the action policy suggests pausing
+func (h *HTTPHandlers) GetActions(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
+	const endpoint = "/actions"
+
+	// The last complete day by default
+	through := h.clock.Now().UTC().AddDate(0, 0, -1)
+	if throughStr := c.Query("through"); throughStr != "" {
+		parsed, err := time.Parse("2006-01-02", throughStr)
+		if err != nil {
+			h.metrics.RecordHTTPRequest("GET", endpoint, "400", time.Since(start))
+			c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_date_format"))
+			return
+		}
+		through = parsed
+	}
+
+	feed, err := h.etlService.SuggestActions(ctx, through)
+	if err != nil {
+		status, code := errorStatus(err, "actions_failed")
+		h.metrics.RecordHTTPRequest("GET", endpoint, strconv.Itoa(status), time.Since(start))
+		if status >= http.StatusInternalServerError {
+			h.logger.WithContext(ctx).WithError(err).Error("Failed to suggest actions")
+			c.JSON(status, errorBody(c, requestID, code))
+			return
+		}
+		c.JSON(status, errorBody(c, requestID, code, err.Error()))
+		return
+	}
+
+	h.metrics.RecordHTTPRequest("GET", endpoint, "200", time.Since(start))
+	c.JSON(http.StatusOK, gin.H{
+		"data":       feed.Actions,
+		"total":      len(feed.Actions),
+		"through":    feed.Through.Format("2006-01-02"),
+		"policy":     feed.Policy,
+		"request_id": requestID,
+	})
+}
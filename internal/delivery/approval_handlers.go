@@ -0,0 +1,223 @@
+package delivery
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"etlgo/internal/domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+// body of POST /approvals/:id/reject
+type rejectRequest struct {
+	Reason string `json:"reason"`
+}
+
+// ListApprovals returns operations waiting for, or decided by, a second
+// operator, most recent first
+func (h *HTTPHandlers) ListApprovals(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
+
+	filter := domain.ApprovalFilter{Status: c.Query("status"), Action: c.Query("action"), Limit: 100}
+	switch filter.Status {
+	case "", domain.ApprovalPending, domain.ApprovalApproved, domain.ApprovalRejected, domain.ApprovalExecuted, domain.ApprovalFailed:
+	default:
+		h.metrics.RecordHTTPRequest("GET", "/approvals", "400", time.Since(start))
+		c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_status", "pending, approved, rejected, executed, failed"))
+		return
+	}
+	if filter.Action != "" && filter.Action != domain.ActionRollback && filter.Action != domain.ActionBackfill {
+		h.metrics.RecordHTTPRequest("GET", "/approvals", "400", time.Since(start))
+		c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_action", "rollback, backfill"))
+		return
+	}
+
+	if limitStr := c.Query("limit"); limitStr != "" {
+		parsed, err := strconv.Atoi(limitStr)
+		if err != nil || parsed <= 0 || parsed > 1000 {
+			h.metrics.RecordHTTPRequest("GET", "/approvals", "400", time.Since(start))
+			c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_limit", 1, 1000))
+			return
+		}
+		filter.Limit = parsed
+	}
+
+	requests, err := h.approvalService.List(ctx, filter)
+	if err != nil {
+		h.metrics.RecordHTTPRequest("GET", "/approvals", "500", time.Since(start))
+		h.logger.WithContext(ctx).WithError(err).Error("Failed to list approval requests")
+		c.JSON(http.StatusInternalServerError, errorBody(c, requestID, "approval_list_failed"))
+		return
+	}
+
+	h.metrics.RecordHTTPRequest("GET", "/approvals", "200", time.Since(start))
+	c.JSON(http.StatusOK, gin.H{
+		"data":       requests,
+		"total":      len(requests),
+		"request_id": requestID,
+	})
+}
+
+// GetApproval returns an approval request and, once executed, its outcome
+func (h *HTTPHandlers) GetApproval(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
+	const endpoint = "/approvals/:id"
+
+	request, err := h.approvalService.Get(ctx, c.Param("id"))
+	if err != nil {
+		status, code := errorStatus(err, "approval_list_failed")
+		h.metrics.RecordHTTPRequest("GET", endpoint, strconv.Itoa(status), time.Since(start))
+		if status >= http.StatusInternalServerError {
+			h.logger.WithContext(ctx).WithError(err).Error("Failed to get approval request")
+		}
+		c.JSON(status, errorBody(c, requestID, code, err.Error()))
+		return
+	}
+
+	h.metrics.RecordHTTPRequest("GET", endpoint, "200", time.Since(start))
+	c.JSON(http.StatusOK, gin.H{
+		"data":       request,
+		"request_id": requestID,
+	})
+}
+
+// ApproveRequest approves a pending request as a second operator and
+// executes the operation
+func (h *HTTPHandlers) ApproveRequest(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
+	const endpoint = "/approvals/:id/approve"
+
+	request, err := h.approvalService.Approve(ctx, c.Param("id"), operatorOf(c))
+	if errors.Is(err, domain.ErrMaintenance) {
+		h.maintenanceError(c, "POST", endpoint, requestID, start, err)
+		return
+	}
+	if errors.Is(err, domain.ErrQuotaExceeded) {
+		h.quotaError(c, "POST", endpoint, requestID, start, err)
+		return
+	}
+	if errors.Is(err, domain.ErrJobQueueTimeout) {
+		h.metrics.RecordHTTPRequest("POST", endpoint, "503", time.Since(start))
+		c.JSON(http.StatusServiceUnavailable, errorBody(c, requestID, "job_queue_busy", err.Error()))
+		return
+	}
+	if err != nil {
+		status, code := errorStatus(err, "approval_failed")
+		h.metrics.RecordHTTPRequest("POST", endpoint, strconv.Itoa(status), time.Since(start))
+		if status >= http.StatusInternalServerError {
+			h.logger.WithContext(ctx).WithError(err).Error("Approved operation failed")
+		}
+		body := errorBody(c, requestID, code, err.Error())
+		if request != nil {
+			body["data"] = request
+		}
+		c.JSON(status, body)
+		return
+	}
+
+	h.metrics.RecordHTTPRequest("POST", endpoint, "200", time.Since(start))
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Request approved and executed",
+		"data":       request,
+		"request_id": requestID,
+	})
+}
+
+// RejectRequest rejects a pending request; its requester may reject it to
+// withdraw it
+func (h *HTTPHandlers) RejectRequest(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
+	const endpoint = "/approvals/:id/reject"
+
+	// The body is optional
+	var req rejectRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		h.metrics.RecordHTTPRequest("POST", endpoint, "400", time.Since(start))
+		c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_request_body", err.Error()))
+		return
+	}
+
+	request, err := h.approvalService.Reject(ctx, c.Param("id"), operatorOf(c), req.Reason)
+	if err != nil {
+		status, code := errorStatus(err, "approval_failed")
+		h.metrics.RecordHTTPRequest("POST", endpoint, strconv.Itoa(status), time.Since(start))
+		if status >= http.StatusInternalServerError {
+			h.logger.WithContext(ctx).WithError(err).Error("Failed to reject approval request")
+		}
+		c.JSON(status, errorBody(c, requestID, code, err.Error()))
+		return
+	}
+
+	h.metrics.RecordHTTPRequest("POST", endpoint, "200", time.Since(start))
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Request rejected",
+		"data":       request,
+		"request_id": requestID,
+	})
+}
+
+// returns the name of the admin API key the caller authenticated with,
+// which approvals are requested and decided under, or "" without one.
+// Unlike X-User it cannot be claimed, so operators cannot pass for a
+// second one.
+func operatorOf(c *gin.Context) string {
+	return c.GetString("api_key")
+}
+
+// submitApproval queues an operation that requires approval instead of
+// running it and responds with 202 and the pending request. The requester
+// must authenticate with an admin API key, so the approver can be told
+// apart from them.
+func (h *HTTPHandlers) submitApproval(c *gin.Context, endpoint, requestID string, start time.Time, request domain.ApprovalRequest) {
+	ctx := c.Request.Context()
+
+	operator := operatorOf(c)
+	if operator == "" {
+		h.metrics.RecordHTTPRequest("POST", endpoint, "401", time.Since(start))
+		c.Header("WWW-Authenticate", "Bearer")
+		c.JSON(http.StatusUnauthorized, errorBody(c, requestID, "invalid_api_key"))
+		return
+	}
+
+	submitted, err := h.approvalService.Submit(ctx, request, operator)
+	if err != nil {
+		status, code := errorStatus(err, "approval_failed")
+		h.metrics.RecordHTTPRequest("POST", endpoint, strconv.Itoa(status), time.Since(start))
+		if status >= http.StatusInternalServerError {
+			h.logger.WithContext(ctx).WithError(err).Error("Failed to submit approval request")
+		}
+		c.JSON(status, errorBody(c, requestID, code, err.Error()))
+		return
+	}
+
+	h.metrics.RecordHTTPRequest("POST", endpoint, "202", time.Since(start))
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":    "Awaiting approval by a second operator",
+		"approval":   submitted,
+		"request_id": requestID,
+	})
+}
@@ -0,0 +1,87 @@
+package delivery
+
+import (
+	"net/http"
+	"time"
+
+	"etlgo/internal/domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListCheckpoints returns the sources' last successful extractions, which
+// runs without a since filter resume from
+func (h *HTTPHandlers) ListCheckpoints(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
+
+	checkpoints, err := h.etlService.ListCheckpoints(ctx)
+	if err != nil {
+		h.metrics.RecordHTTPRequest("GET", "/ingest/checkpoints", "500", time.Since(start))
+		h.logger.WithContext(ctx).WithError(err).Error("Failed to list checkpoints")
+		c.JSON(http.StatusInternalServerError, errorBody(c, requestID, "internal_error"))
+		return
+	}
+
+	h.metrics.RecordHTTPRequest("GET", "/ingest/checkpoints", "200", time.Since(start))
+	c.JSON(http.StatusOK, gin.H{
+		"data":       checkpoints,
+		"total":      len(checkpoints),
+		"request_id": requestID,
+	})
+}
+
+// ListSourceObjects returns the object store files already ingested, of
+// the source when set
+func (h *HTTPHandlers) ListSourceObjects(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
+
+	source := c.Query("source")
+	if source != "" && source != domain.SourceAds && source != domain.SourceCRM {
+		h.metrics.RecordHTTPRequest("GET", "/ingest/objects", "400", time.Since(start))
+		c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_source", "ads, crm"))
+		return
+	}
+
+	objects, err := h.etlService.ListSourceObjects(ctx, source)
+	if err != nil {
+		h.metrics.RecordHTTPRequest("GET", "/ingest/objects", "500", time.Since(start))
+		h.logger.WithContext(ctx).WithError(err).Error("Failed to list ingested objects")
+		c.JSON(http.StatusInternalServerError, errorBody(c, requestID, "internal_error"))
+		return
+	}
+
+	h.metrics.RecordHTTPRequest("GET", "/ingest/objects", "200", time.Since(start))
+	c.JSON(http.StatusOK, gin.H{
+		"data":       objects,
+		"total":      len(objects),
+		"request_id": requestID,
+	})
+}
+
+// ListConnectors returns the connectors runs extract ads and CRM from, the
+// built-in sources and those declared in CONNECTORS_FILE
+func (h *HTTPHandlers) ListConnectors(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+	connectors := h.etlService.ListConnectors()
+
+	h.metrics.RecordHTTPRequest("GET", "/ingest/connectors", "200", time.Since(start))
+	c.JSON(http.StatusOK, gin.H{
+		"data":       connectors,
+		"total":      len(connectors),
+		"request_id": requestID,
+	})
+}
@@ -0,0 +1,105 @@
+package delivery
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"etlgo/internal/domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetAdminConfig returns the effective startup configuration without its
+// secrets and the current runtime settings
+func (h *HTTPHandlers) GetAdminConfig(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+
+	h.metrics.RecordHTTPRequest("GET", "/admin/config", "200", time.Since(start))
+	c.JSON(http.StatusOK, gin.H{
+		"config":     h.configService.Settings(),
+		"runtime":    h.configService.Runtime(),
+		"request_id": requestID,
+	})
+}
+
+// PatchAdminConfig changes runtime settings. The change is rejected as a
+// whole when any setting is invalid.
+func (h *HTTPHandlers) PatchAdminConfig(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
+	const endpoint = "/admin/config"
+
+	var patch domain.RuntimeConfigPatch
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		h.metrics.RecordHTTPRequest("PATCH", endpoint, "400", time.Since(start))
+		c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_request_body", err.Error()))
+		return
+	}
+
+	change, err := h.configService.Update(ctx, patch, requestActor(c), c.GetString("api_key"))
+	if err != nil {
+		status, code := errorStatus(err, "config_update_failed")
+		h.metrics.RecordHTTPRequest("PATCH", endpoint, strconv.Itoa(status), time.Since(start))
+		if status >= http.StatusInternalServerError {
+			h.logger.WithContext(ctx).WithError(err).Error("Failed to change runtime configuration")
+			c.JSON(status, errorBody(c, requestID, code))
+			return
+		}
+		c.JSON(status, errorBody(c, requestID, code, err.Error()))
+		return
+	}
+
+	h.metrics.RecordHTTPRequest("PATCH", endpoint, "200", time.Since(start))
+	c.JSON(http.StatusOK, gin.H{
+		"change":     change,
+		"runtime":    h.configService.Runtime(),
+		"request_id": requestID,
+	})
+}
+
+// ListConfigChanges returns the audit log of runtime configuration
+// changes, most recent first
+func (h *HTTPHandlers) ListConfigChanges(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
+	const endpoint = "/admin/config/changes"
+
+	limit := 100
+	if limitStr := c.Query("limit"); limitStr != "" {
+		parsed, err := strconv.Atoi(limitStr)
+		if err != nil || parsed <= 0 || parsed > 1000 {
+			h.metrics.RecordHTTPRequest("GET", endpoint, "400", time.Since(start))
+			c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_limit", 1, 1000))
+			return
+		}
+		limit = parsed
+	}
+
+	changes, err := h.configService.Changes(ctx, limit)
+	if err != nil {
+		h.metrics.RecordHTTPRequest("GET", endpoint, "500", time.Since(start))
+		h.logger.WithContext(ctx).WithError(err).Error("Failed to list configuration changes")
+		c.JSON(http.StatusInternalServerError, errorBody(c, requestID, "config_changes_list_failed"))
+		return
+	}
+
+	h.metrics.RecordHTTPRequest("GET", endpoint, "200", time.Since(start))
+	c.JSON(http.StatusOK, gin.H{
+		"data":       changes,
+		"total":      len(changes),
+		"request_id": requestID,
+	})
+}
@@ -0,0 +1,103 @@
+package delivery
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PromoteShadowResult makes the metrics of a shadow result the active
+// dataset served by the metrics endpoints
+func (h *HTTPHandlers) PromoteShadowResult(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
+	const endpoint = "/shadows/:label/results/:id/promote"
+
+	promotion, err := h.etlService.PromoteShadowResult(ctx, c.Param("label"), c.Param("id"), operatorOf(c))
+	if err != nil {
+		h.datasetError(c, ctx, "POST", endpoint, requestID, start, err)
+		return
+	}
+
+	h.metrics.RecordHTTPRequest("POST", endpoint, "200", time.Since(start))
+	c.JSON(http.StatusOK, gin.H{
+		"data":       promotion,
+		"request_id": requestID,
+	})
+}
+
+// DemoteDataset rolls back the most recent promotion
+func (h *HTTPHandlers) DemoteDataset(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
+
+	promotion, err := h.etlService.DemoteDataset(ctx, operatorOf(c))
+	if err != nil {
+		h.datasetError(c, ctx, "POST", "/datasets/demote", requestID, start, err)
+		return
+	}
+
+	h.metrics.RecordHTTPRequest("POST", "/datasets/demote", "200", time.Since(start))
+	c.JSON(http.StatusOK, gin.H{
+		"data":       promotion,
+		"request_id": requestID,
+	})
+}
+
+// ListDatasetPromotions returns the dataset promotions, most recent first
+func (h *HTTPHandlers) ListDatasetPromotions(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
+	const endpoint = "/datasets"
+
+	limit := 100
+	if limitStr := c.Query("limit"); limitStr != "" {
+		parsed, err := strconv.Atoi(limitStr)
+		if err != nil || parsed <= 0 || parsed > 1000 {
+			h.metrics.RecordHTTPRequest("GET", endpoint, "400", time.Since(start))
+			c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_limit", 1, 1000))
+			return
+		}
+		limit = parsed
+	}
+
+	promotions, err := h.etlService.ListDatasetPromotions(ctx, limit)
+	if err != nil {
+		h.datasetError(c, ctx, "GET", endpoint, requestID, start, err)
+		return
+	}
+
+	h.metrics.RecordHTTPRequest("GET", endpoint, "200", time.Since(start))
+	c.JSON(http.StatusOK, gin.H{
+		"data":       promotions,
+		"total":      len(promotions),
+		"request_id": requestID,
+	})
+}
+
+// datasetError maps dataset promotion errors to HTTP responses
+func (h *HTTPHandlers) datasetError(c *gin.Context, ctx context.Context, method, endpoint, requestID string, start time.Time, err error) {
+	status, code := errorStatus(err, "internal_error")
+	h.metrics.RecordHTTPRequest(method, endpoint, strconv.Itoa(status), time.Since(start))
+	if status >= http.StatusInternalServerError {
+		h.logger.WithContext(ctx).WithError(err).Error("Dataset operation failed")
+		c.JSON(status, errorBody(c, requestID, code))
+		return
+	}
+	c.JSON(status, errorBody(c, requestID, code, err.Error()))
+}
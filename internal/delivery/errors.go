@@ -0,0 +1,57 @@
+package delivery
+
+import (
+	"errors"
+	"net/http"
+
+	"etlgo/internal/domain"
+	"etlgo/pkg/i18n"
+
+	"github.com/gin-gonic/gin"
+)
+
+// returns the ID the RequestID middleware gave the request, which the
+// request context already carries into the logs, so responses, logs and
+// the X-Request-ID header agree
+func requestIDOf(c *gin.Context) string {
+	return c.GetString("request_id")
+}
+
+// builds an error response body in the language negotiated by the Language
+// middleware. The code is the same in every language so clients can match
+// on it; error and message are for people.
+func errorBody(c *gin.Context, requestID, code string, args ...any) gin.H {
+	lang := c.GetString("language")
+	if code == "storage_busy" {
+		// the read queue drains within seconds
+		c.Header("Retry-After", "1")
+	}
+	return gin.H{
+		"code":       code,
+		"error":      i18n.Error(lang, code),
+		"message":    i18n.Message(lang, code, args...),
+		"request_id": requestID,
+	}
+}
+
+// maps a domain error category to its HTTP status and error code. Errors
+// outside every category are internal and get the fallback code with a 500.
+func errorStatus(err error, fallback string) (int, string) {
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		return http.StatusNotFound, "not_found"
+	case errors.Is(err, domain.ErrQueryTooExpensive):
+		return http.StatusUnprocessableEntity, "query_too_expensive"
+	case errors.Is(err, domain.ErrValidation):
+		return http.StatusBadRequest, "validation_failed"
+	case errors.Is(err, domain.ErrConflict):
+		return http.StatusConflict, "conflict"
+	case errors.Is(err, domain.ErrForbidden):
+		return http.StatusForbidden, "forbidden"
+	case errors.Is(err, domain.ErrUpstreamUnavailable):
+		return http.StatusBadGateway, "upstream_unavailable"
+	case errors.Is(err, domain.ErrOverloaded):
+		return http.StatusServiceUnavailable, "storage_busy"
+	}
+	return http.StatusInternalServerError, fallback
+}
@@ -0,0 +1,153 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"etlgo/internal/domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListEvents returns versions of ingested records from the event log
+func (h *HTTPHandlers) ListEvents(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
+
+	filter := domain.EventFilter{
+		Source:      c.Query("source"),
+		Key:         c.Query("key"),
+		IngestRunID: c.Query("ingest_run_id"),
+		Limit:       100,
+	}
+	if filter.Source != "" && filter.Source != domain.SourceAds && filter.Source != domain.SourceCRM {
+		h.metrics.RecordHTTPRequest("GET", "/events", "400", time.Since(start))
+		c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_source", "ads, crm"))
+		return
+	}
+	tags, err := domain.ParseRunTagFilter(c.QueryArray("tag"))
+	if err != nil {
+		h.metrics.RecordHTTPRequest("GET", "/events", "400", time.Since(start))
+		c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_run_tags", err.Error()))
+		return
+	}
+	filter.RunTags = tags
+	if limitStr := c.Query("limit"); limitStr != "" {
+		parsed, err := strconv.Atoi(limitStr)
+		if err != nil || parsed <= 0 || parsed > 1000 {
+			h.metrics.RecordHTTPRequest("GET", "/events", "400", time.Since(start))
+			c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_limit", 1, 1000))
+			return
+		}
+		filter.Limit = parsed
+	}
+
+	events, err := h.etlService.ListEvents(ctx, filter)
+	if err != nil {
+		h.metrics.RecordHTTPRequest("GET", "/events", "500", time.Since(start))
+		h.logger.WithContext(ctx).WithError(err).Error("Failed to list ingest events")
+		c.JSON(http.StatusInternalServerError, errorBody(c, requestID, "event_list_failed"))
+		return
+	}
+
+	h.metrics.RecordHTTPRequest("GET", "/events", "200", time.Since(start))
+	c.JSON(http.StatusOK, gin.H{
+		"data":       events,
+		"total":      len(events),
+		"request_id": requestID,
+	})
+}
+
+// GetEventSnapshot returns the latest version of every ingested record as
+// of a time or the end of an ingest run
+func (h *HTTPHandlers) GetEventSnapshot(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
+
+	var asOf time.Time
+	if asOfStr := c.Query("as_of"); asOfStr != "" {
+		parsed, err := time.Parse(time.RFC3339, asOfStr)
+		if err != nil {
+			h.metrics.RecordHTTPRequest("GET", "/events/snapshot", "400", time.Since(start))
+			c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_as_of"))
+			return
+		}
+		asOf = parsed
+	}
+
+	snapshot, err := h.etlService.EventSnapshot(ctx, asOf, c.Query("ingest_run_id"))
+	if err != nil {
+		status, code := errorStatus(err, "event_snapshot_failed")
+		h.metrics.RecordHTTPRequest("GET", "/events/snapshot", strconv.Itoa(status), time.Since(start))
+		if status >= http.StatusInternalServerError {
+			h.logger.WithContext(ctx).WithError(err).Error("Failed to read ingest event snapshot")
+		}
+		c.JSON(status, errorBody(c, requestID, code, err.Error()))
+		return
+	}
+
+	h.metrics.RecordHTTPRequest("GET", "/events/snapshot", "200", time.Since(start))
+	c.JSON(http.StatusOK, gin.H{
+		"data":       snapshot,
+		"request_id": requestID,
+	})
+}
+
+// RollbackIngest restores the records an ingest run or push changed to
+// their previous versions and recalculates the affected metrics
+func (h *HTTPHandlers) RollbackIngest(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
+	const endpoint = "/ingest/runs/:id/rollback"
+
+	if h.approvalService.Required(domain.ActionRollback) {
+		h.submitApproval(c, endpoint, requestID, start, domain.NewRollbackApproval(c.Param("id")))
+		return
+	}
+
+	var rollback *domain.EventRollback
+	err := h.jobQueue.Run(ctx, domain.JobTypeIngest, domain.PriorityNormal, func(ctx context.Context) error {
+		var err error
+		rollback, err = h.etlService.RollbackIngest(ctx, c.Param("id"))
+		return err
+	})
+	if errors.Is(err, domain.ErrMaintenance) {
+		h.maintenanceError(c, "POST", endpoint, requestID, start, err)
+		return
+	}
+	if errors.Is(err, domain.ErrJobQueueTimeout) {
+		h.metrics.RecordHTTPRequest("POST", endpoint, "503", time.Since(start))
+		c.JSON(http.StatusServiceUnavailable, errorBody(c, requestID, "job_queue_busy", err.Error()))
+		return
+	}
+	if err != nil {
+		status, code := errorStatus(err, "rollback_failed")
+		h.metrics.RecordHTTPRequest("POST", endpoint, strconv.Itoa(status), time.Since(start))
+		if status >= http.StatusInternalServerError {
+			h.logger.WithContext(ctx).WithError(err).Error("Failed to roll back ingestion")
+		}
+		c.JSON(status, errorBody(c, requestID, code, err.Error()))
+		return
+	}
+
+	h.metrics.RecordHTTPRequest("POST", endpoint, "200", time.Since(start))
+	c.JSON(http.StatusOK, gin.H{
+		"data":       rollback,
+		"request_id": requestID,
+	})
+}
@@ -0,0 +1,142 @@
+package delivery
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"etlgo/internal/domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+// largest ack body accepted from a sink
+const maxExportAckBytes = 64 << 10
+
+// AcknowledgeExport receives the callback of a sink or destination that
+// processed an export, signed like the exports it received, and confirms or
+// rejects the export's delivery
+func (h *HTTPHandlers) AcknowledgeExport(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
+
+	payload, err := io.ReadAll(io.LimitReader(c.Request.Body, maxExportAckBytes+1))
+	if err != nil || len(payload) > maxExportAckBytes {
+		h.metrics.RecordHTTPRequest("POST", "/export/acks", "400", time.Since(start))
+		c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_request_body", "ack body must be a JSON object of up to 64KiB"))
+		return
+	}
+
+	delivery, err := h.exportAcks.Acknowledge(ctx, c.GetHeader("X-Export-Destination"), payload, c.GetHeader("X-Signature"))
+	if errors.Is(err, domain.ErrExportAcksNotConfigured) {
+		h.metrics.RecordHTTPRequest("POST", "/export/acks", "400", time.Since(start))
+		c.JSON(http.StatusBadRequest, errorBody(c, requestID, "export_acks_not_configured"))
+		return
+	}
+	if errors.Is(err, domain.ErrInvalidAckSignature) {
+		h.metrics.RecordHTTPRequest("POST", "/export/acks", "401", time.Since(start))
+		c.JSON(http.StatusUnauthorized, errorBody(c, requestID, "invalid_signature"))
+		return
+	}
+	if err != nil {
+		status, code := errorStatus(err, "export_ack_failed")
+		h.metrics.RecordHTTPRequest("POST", "/export/acks", strconv.Itoa(status), time.Since(start))
+		if status >= http.StatusInternalServerError {
+			h.logger.WithContext(ctx).WithError(err).Error("Failed to acknowledge export")
+			c.JSON(status, errorBody(c, requestID, code))
+			return
+		}
+		c.JSON(status, errorBody(c, requestID, code, err.Error()))
+		return
+	}
+
+	h.metrics.RecordHTTPRequest("POST", "/export/acks", "200", time.Since(start))
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Export " + delivery.State,
+		"data":       delivery,
+		"request_id": requestID,
+	})
+}
+
+// ListExportDeliveries returns the exports awaiting or having received their
+// sink's ack, most recent first
+func (h *HTTPHandlers) ListExportDeliveries(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
+
+	filter := domain.ExportDeliveryFilter{
+		State:    c.Query("state"),
+		ExportID: c.Query("export_id"),
+		Stuck:    c.Query("stuck") == "true",
+		Limit:    100,
+	}
+	if err := filter.Validate(); err != nil {
+		h.metrics.RecordHTTPRequest("GET", "/export/deliveries", "400", time.Since(start))
+		c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_state", "delivered, confirmed, rejected"))
+		return
+	}
+	if limitStr := c.Query("limit"); limitStr != "" {
+		parsed, err := strconv.Atoi(limitStr)
+		if err != nil || parsed <= 0 || parsed > 1000 {
+			h.metrics.RecordHTTPRequest("GET", "/export/deliveries", "400", time.Since(start))
+			c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_limit", 1, 1000))
+			return
+		}
+		filter.Limit = parsed
+	}
+
+	deliveries, err := h.exportAcks.ListDeliveries(ctx, filter)
+	if err != nil {
+		h.metrics.RecordHTTPRequest("GET", "/export/deliveries", "500", time.Since(start))
+		h.logger.WithContext(ctx).WithError(err).Error("Failed to list export deliveries")
+		c.JSON(http.StatusInternalServerError, errorBody(c, requestID, "export_delivery_list_failed"))
+		return
+	}
+
+	h.metrics.RecordHTTPRequest("GET", "/export/deliveries", "200", time.Since(start))
+	c.JSON(http.StatusOK, gin.H{
+		"data":       deliveries,
+		"total":      len(deliveries),
+		"request_id": requestID,
+	})
+}
+
+// GetExportDelivery returns an export delivery and the state of its ack
+func (h *HTTPHandlers) GetExportDelivery(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
+	const endpoint = "/export/deliveries/:id"
+
+	delivery, err := h.exportAcks.GetDelivery(ctx, c.Param("id"))
+	if err != nil {
+		status, code := errorStatus(err, "export_delivery_get_failed")
+		h.metrics.RecordHTTPRequest("GET", endpoint, strconv.Itoa(status), time.Since(start))
+		if status >= http.StatusInternalServerError {
+			h.logger.WithContext(ctx).WithError(err).Error("Failed to get export delivery")
+			c.JSON(status, errorBody(c, requestID, code))
+			return
+		}
+		c.JSON(status, errorBody(c, requestID, code, err.Error()))
+		return
+	}
+
+	h.metrics.RecordHTTPRequest("GET", endpoint, "200", time.Since(start))
+	c.JSON(http.StatusOK, gin.H{
+		"data":       delivery,
+		"request_id": requestID,
+	})
+}
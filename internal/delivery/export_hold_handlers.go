@@ -0,0 +1,101 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"etlgo/internal/domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListExportHolds returns the dates whose exports were held because of
+// anomalous spend, most recent first
+func (h *HTTPHandlers) ListExportHolds(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
+
+	status := c.Query("status")
+	if status != "" && status != domain.ExportHoldPending && status != domain.ExportHoldApproved {
+		h.metrics.RecordHTTPRequest("GET", "/export/approvals", "400", time.Since(start))
+		c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_status", "pending, approved"))
+		return
+	}
+
+	limit := 100
+	if limitStr := c.Query("limit"); limitStr != "" {
+		parsed, err := strconv.Atoi(limitStr)
+		if err != nil || parsed <= 0 || parsed > 1000 {
+			h.metrics.RecordHTTPRequest("GET", "/export/approvals", "400", time.Since(start))
+			c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_limit", 1, 1000))
+			return
+		}
+		limit = parsed
+	}
+
+	holds, err := h.metricsService.ListExportHolds(ctx, status, limit)
+	if err != nil {
+		h.metrics.RecordHTTPRequest("GET", "/export/approvals", "500", time.Since(start))
+		h.logger.WithContext(ctx).WithError(err).Error("Failed to list export holds")
+		c.JSON(http.StatusInternalServerError, errorBody(c, requestID, "export_hold_list_failed"))
+		return
+	}
+
+	h.metrics.RecordHTTPRequest("GET", "/export/approvals", "200", time.Since(start))
+	c.JSON(http.StatusOK, gin.H{
+		"data":       holds,
+		"total":      len(holds),
+		"request_id": requestID,
+	})
+}
+
+// ApproveExportHold releases a held date and exports its metrics, recording
+// the admin key it was approved with
+func (h *HTTPHandlers) ApproveExportHold(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
+	const endpoint = "/export/approvals/:id"
+
+	var hold *domain.ExportHold
+	err := h.jobQueue.Run(ctx, domain.JobTypeExport, domain.PriorityHigh, func(ctx context.Context) error {
+		var err error
+		hold, err = h.metricsService.ApproveExportHold(ctx, c.Param("id"), operatorOf(c))
+		return err
+	})
+	if errors.Is(err, domain.ErrMaintenance) {
+		h.maintenanceError(c, "POST", endpoint, requestID, start, err)
+		return
+	}
+	if errors.Is(err, domain.ErrJobQueueTimeout) {
+		h.metrics.RecordHTTPRequest("POST", endpoint, "503", time.Since(start))
+		c.JSON(http.StatusServiceUnavailable, errorBody(c, requestID, "job_queue_busy", err.Error()))
+		return
+	}
+	if err != nil {
+		status, code := errorStatus(err, "export_approval_failed")
+		h.metrics.RecordHTTPRequest("POST", endpoint, strconv.Itoa(status), time.Since(start))
+		if status >= http.StatusInternalServerError {
+			h.logger.WithContext(ctx).WithError(err).Error("Failed to export approved date")
+		}
+		c.JSON(status, errorBody(c, requestID, code, err.Error()))
+		return
+	}
+
+	h.metrics.RecordHTTPRequest("POST", endpoint, "200", time.Since(start))
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Export approved",
+		"data":       hold,
+		"request_id": requestID,
+	})
+}
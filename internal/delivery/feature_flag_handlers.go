@@ -0,0 +1,59 @@
+package delivery
+
+import (
+	"net/http"
+	"time"
+
+	"etlgo/internal/domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListFeatureFlags returns every flag and whether it is active for the
+// environment and the tenant in X-Tenant-ID
+func (h *HTTPHandlers) ListFeatureFlags(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
+
+	flags := h.flagService.ListFlags(ctx)
+
+	h.metrics.RecordHTTPRequest("GET", "/flags", "200", time.Since(start))
+	c.JSON(http.StatusOK, gin.H{
+		"environment": h.flagService.Environment(),
+		"tenant":      domain.TenantFromContext(ctx),
+		"data":        flags,
+		"total":       len(flags),
+		"request_id":  requestID,
+	})
+}
+
+// ReloadFeatureFlags re-reads the flag configuration file
+func (h *HTTPHandlers) ReloadFeatureFlags(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
+
+	if err := h.flagService.ReloadFlags(ctx); err != nil {
+		h.metrics.RecordHTTPRequest("POST", "/flags/reload", "500", time.Since(start))
+		h.logger.WithContext(ctx).WithError(err).Error("Failed to reload feature flags")
+		c.JSON(http.StatusInternalServerError, errorBody(c, requestID, "flag_reload_failed", err.Error()))
+		return
+	}
+
+	flags := h.flagService.ListFlags(ctx)
+
+	h.metrics.RecordHTTPRequest("POST", "/flags/reload", "200", time.Since(start))
+	c.JSON(http.StatusOK, gin.H{
+		"environment": h.flagService.Environment(),
+		"data":        flags,
+		"total":       len(flags),
+		"request_id":  requestID,
+	})
+}
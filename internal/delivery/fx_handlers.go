@@ -0,0 +1,92 @@
+package delivery
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"etlgo/internal/domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PutFXRates stores the daily FX rates of the request body
+func (h *HTTPHandlers) PutFXRates(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
+	const endpoint = "/fx/rates"
+
+	var rates []domain.FXRate
+	if err := c.ShouldBindJSON(&rates); err != nil {
+		h.metrics.RecordHTTPRequest("PUT", endpoint, "400", time.Since(start))
+		c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_request_body", err.Error()))
+		return
+	}
+
+	if err := h.metricsService.PutFXRates(ctx, rates); err != nil {
+		status, code := errorStatus(err, "fx_rates_failed")
+		h.metrics.RecordHTTPRequest("PUT", endpoint, strconv.Itoa(status), time.Since(start))
+		if status >= http.StatusInternalServerError {
+			h.logger.WithContext(ctx).WithError(err).Error("Failed to store FX rates")
+			c.JSON(status, errorBody(c, requestID, code))
+			return
+		}
+		c.JSON(status, errorBody(c, requestID, code, err.Error()))
+		return
+	}
+
+	h.metrics.RecordHTTPRequest("PUT", endpoint, "200", time.Since(start))
+	c.JSON(http.StatusOK, gin.H{
+		"stored":     len(rates),
+		"request_id": requestID,
+	})
+}
+
+// ListFXRates returns the stored daily rates of a currency
+func (h *HTTPHandlers) ListFXRates(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
+	const endpoint = "/fx/rates"
+
+	currency := c.Query("currency")
+	if currency == "" {
+		h.metrics.RecordHTTPRequest("GET", endpoint, "400", time.Since(start))
+		c.JSON(http.StatusBadRequest, errorBody(c, requestID, "missing_parameter", "currency"))
+		return
+	}
+
+	from, to, err := h.parseDateRange(c)
+	if err != nil {
+		h.metrics.RecordHTTPRequest("GET", endpoint, "400", time.Since(start))
+		c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_parameters", err.Error()))
+		return
+	}
+
+	rates, err := h.metricsService.ListFXRates(ctx, currency, from, to)
+	if err != nil {
+		status, code := errorStatus(err, "fx_rates_failed")
+		h.metrics.RecordHTTPRequest("GET", endpoint, strconv.Itoa(status), time.Since(start))
+		if status >= http.StatusInternalServerError {
+			h.logger.WithContext(ctx).WithError(err).Error("Failed to list FX rates")
+			c.JSON(status, errorBody(c, requestID, code))
+			return
+		}
+		c.JSON(status, errorBody(c, requestID, code, err.Error()))
+		return
+	}
+
+	h.metrics.RecordHTTPRequest("GET", endpoint, "200", time.Since(start))
+	c.JSON(http.StatusOK, gin.H{
+		"data":       rates,
+		"total":      len(rates),
+		"request_id": requestID,
+	})
+}
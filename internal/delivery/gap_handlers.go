@@ -0,0 +1,48 @@
+package delivery
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetGaps reports the days each source has no data for
+func (h *HTTPHandlers) GetGaps(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
+	const endpoint = "/gaps"
+
+	from, to, err := h.parseDateRange(c)
+	if err != nil {
+		h.metrics.RecordHTTPRequest("GET", endpoint, "400", time.Since(start))
+		c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_parameters", err.Error()))
+		return
+	}
+
+	report, err := h.etlService.DetectGaps(ctx, from, to, c.Query("source"))
+	if err != nil {
+		status, code := errorStatus(err, "gaps_failed")
+		h.metrics.RecordHTTPRequest("GET", endpoint, strconv.Itoa(status), time.Since(start))
+		if status >= http.StatusInternalServerError {
+			h.logger.WithContext(ctx).WithError(err).Error("Failed to detect gaps")
+			c.JSON(status, errorBody(c, requestID, code))
+			return
+		}
+		c.JSON(status, errorBody(c, requestID, code, err.Error()))
+		return
+	}
+
+	h.metrics.RecordHTTPRequest("GET", endpoint, "200", time.Since(start))
+	c.JSON(http.StatusOK, gin.H{
+		"data":       report.Sources,
+		"from":       report.From,
+		"to":         report.To,
+		"request_id": requestID,
+	})
+}
@@ -2,50 +2,115 @@ package delivery
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
+	"etlgo/internal/domain"
 	"etlgo/internal/usecase"
+	"etlgo/pkg/buildinfo"
+	"etlgo/pkg/i18n"
 	"etlgo/pkg/logger"
 	"etlgo/pkg/metrics"
 
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
 )
 
 // handles HTTP requests
 type HTTPHandlers struct {
-	etlService     *usecase.ETLService
-	metricsService *usecase.MetricsService
-	logger         *logger.Logger
-	metrics        *metrics.Metrics
+	etlService         *usecase.ETLService
+	ingestJobs         *usecase.IngestJobService
+	streamService      *usecase.StreamService
+	webhooks           *usecase.WebhookService
+	metricsService     *usecase.MetricsService
+	modelService       *usecase.ModelService
+	rawExportService   *usecase.RawExportService
+	exportAcks         *usecase.ExportAckService
+	shareTokens        *usecase.ShareTokenService
+	pipelineService    *usecase.PipelineService
+	scheduler          *usecase.PipelineScheduler
+	flagService        *usecase.FeatureFlagService
+	quotaService       *usecase.QuotaService
+	notifier           *usecase.NotificationService
+	maintenanceService *usecase.MaintenanceService
+	storageService     *usecase.StorageService
+	approvalService    *usecase.ApprovalService
+	configService      *usecase.ConfigService
+	jobQueue           *usecase.JobQueue
+	watchdog           *usecase.Watchdog
+	uploadMaxBytes     int64
+	clock              domain.Clock
+	logger             *logger.Logger
+	metrics            *metrics.Metrics
 }
 
 // creates new HTTP handlers
 func NewHTTPHandlers(
 	etlService *usecase.ETLService,
+	ingestJobs *usecase.IngestJobService,
+	streamService *usecase.StreamService,
+	webhooks *usecase.WebhookService,
 	metricsService *usecase.MetricsService,
+	modelService *usecase.ModelService,
+	rawExportService *usecase.RawExportService,
+	exportAcks *usecase.ExportAckService,
+	shareTokens *usecase.ShareTokenService,
+	pipelineService *usecase.PipelineService,
+	scheduler *usecase.PipelineScheduler,
+	flagService *usecase.FeatureFlagService,
+	quotaService *usecase.QuotaService,
+	notifier *usecase.NotificationService,
+	maintenanceService *usecase.MaintenanceService,
+	storageService *usecase.StorageService,
+	approvalService *usecase.ApprovalService,
+	configService *usecase.ConfigService,
+	jobQueue *usecase.JobQueue,
+	watchdog *usecase.Watchdog,
+	uploadMaxBytes int64,
+	clock domain.Clock,
 	logger *logger.Logger,
 	metrics *metrics.Metrics,
 ) *HTTPHandlers {
 	return &HTTPHandlers{
-		etlService:     etlService,
-		metricsService: metricsService,
-		logger:         logger,
-		metrics:        metrics,
+		etlService:         etlService,
+		ingestJobs:         ingestJobs,
+		streamService:      streamService,
+		webhooks:           webhooks,
+		metricsService:     metricsService,
+		modelService:       modelService,
+		rawExportService:   rawExportService,
+		exportAcks:         exportAcks,
+		shareTokens:        shareTokens,
+		pipelineService:    pipelineService,
+		scheduler:          scheduler,
+		flagService:        flagService,
+		quotaService:       quotaService,
+		notifier:           notifier,
+		maintenanceService: maintenanceService,
+		storageService:     storageService,
+		approvalService:    approvalService,
+		configService:      configService,
+		jobQueue:           jobQueue,
+		watchdog:           watchdog,
+		uploadMaxBytes:     uploadMaxBytes,
+		clock:              clock,
+		logger:             logger,
+		metrics:            metrics,
 	}
 }
 
-// triggers the ETL pipeline
+// triggers the ETL pipeline, in the background unless wait=true
 func (h *HTTPHandlers) IngestRun(c *gin.Context) {
 	start := time.Now()
 	h.metrics.IncHTTPRequestsInFlight()
 	defer h.metrics.DecHTTPRequestsInFlight()
 
 	// Generate request ID for tracing
-	requestID := uuid.New().String()
-	ctx := context.WithValue(c.Request.Context(), logger.RequestIDKey, requestID)
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
 
 	log := h.logger.WithContext(ctx)
 	log.Info("Starting ETL ingestion")
@@ -55,26 +120,166 @@ func (h *HTTPHandlers) IngestRun(c *gin.Context) {
 	if sinceStr := c.Query("since"); sinceStr != "" {
 		if parsedSince, err := time.Parse("2006-01-02", sinceStr); err != nil {
 			h.metrics.RecordHTTPRequest("POST", "/ingest/run", "400", time.Since(start))
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error":      "Invalid date format",
-				"message":    "Date must be in YYYY-MM-DD format",
-				"request_id": requestID,
-			})
+			c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_date_format"))
 			return
 		} else {
 			since = &parsedSince
 		}
 	}
 
+	parsePolicy, err := parseParsePolicy(c)
+	if err != nil {
+		h.metrics.RecordHTTPRequest("POST", "/ingest/run", "400", time.Since(start))
+		c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_parse_policy", err.Error()))
+		return
+	}
+
+	forceFull := c.Query("force_full") == "true"
+
+	var req domain.RunRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			h.metrics.RecordHTTPRequest("POST", "/ingest/run", "400", time.Since(start))
+			c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_request_body", err.Error()))
+			return
+		}
+	}
+	if err := domain.ValidateRunTags(req.Tags); err != nil {
+		h.metrics.RecordHTTPRequest("POST", "/ingest/run", "400", time.Since(start))
+		c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_run_tags", err.Error()))
+		return
+	}
+	if err := domain.ValidateRunKey(req.RunKey); err != nil {
+		h.metrics.RecordHTTPRequest("POST", "/ingest/run", "400", time.Since(start))
+		c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_parameters", err.Error()))
+		return
+	}
+
+	// wait=true runs synchronously; a duration queues the job and long-polls it
+	wait := c.Query("wait") == "true"
+	var longPoll time.Duration
+	if !wait {
+		var ok bool
+		if longPoll, ok = parseLongPoll(c.Query("wait")); !ok {
+			h.metrics.RecordHTTPRequest("POST", "/ingest/run", "400", time.Since(start))
+			c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_wait", maxLongPoll))
+			return
+		}
+	}
+	if wait && req.RunKey != "" {
+		h.metrics.RecordHTTPRequest("POST", "/ingest/run", "400", time.Since(start))
+		c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_parameters", "run_key needs a queued job; long-poll it with a wait duration instead of wait=true"))
+		return
+	}
+
+	pipelineName := c.Query("pipeline")
+	if pipelineName != "" && (since != nil || parsePolicy != nil || forceFull) {
+		h.metrics.RecordHTTPRequest("POST", "/ingest/run", "400", time.Since(start))
+		c.JSON(http.StatusBadRequest, errorBody(c, requestID, "conflicting_parameters"))
+		return
+	}
+
+	opts := domain.RunOptions{Since: since, ForceFull: forceFull, Tags: req.Tags}
+	if parsePolicy != nil {
+		opts.Parsing = map[string]domain.ParsePolicy{
+			domain.SourceAds: *parsePolicy,
+			domain.SourceCRM: *parsePolicy,
+		}
+	}
+
+	// A run is a backfill when the window it extracts, its pipeline's or
+	// resumed from the checkpoints, reaches back past the backfill window
+	runOpts := opts
+	if pipelineName != "" {
+		runOpts, err = h.pipelineService.PipelineRunOptions(ctx, pipelineName, req.Tags)
+	}
+	var window *time.Time
+	if err == nil {
+		window, err = h.etlService.RunWindow(ctx, runOpts)
+	}
+	if errors.Is(err, domain.ErrPipelineNotFound) {
+		h.metrics.RecordHTTPRequest("POST", "/ingest/run", "404", time.Since(start))
+		c.JSON(http.StatusNotFound, errorBody(c, requestID, "pipeline_not_found", err.Error()))
+		return
+	}
+	if err != nil {
+		status, code := errorStatus(err, "ingestion_failed")
+		h.metrics.RecordHTTPRequest("POST", "/ingest/run", strconv.Itoa(status), time.Since(start))
+		log.WithError(err).Error("Failed to resolve the ETL run window")
+		c.JSON(status, errorBody(c, requestID, code, err.Error()))
+		return
+	}
+
+	priority, ok := h.parsePriority(c, h.jobQueue.IngestPriority(window))
+	if !ok {
+		h.metrics.RecordHTTPRequest("POST", "/ingest/run", "400", time.Since(start))
+		c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_priority"))
+		return
+	}
+
+	// Backfills wait for a second operator
+	if h.jobQueue.IsBackfill(window) && h.approvalService.Required(domain.ActionBackfill) {
+		h.submitApproval(c, "/ingest/run", requestID, start, domain.NewBackfillApproval(pipelineName, since, parsePolicy, req.Tags))
+		return
+	}
+
+	// Runs are queued in the background unless the caller waits for them
+	if !wait {
+		h.submitIngestJob(c, ctx, requestID, start, domain.IngestJobRequest{
+			RunKey:   req.RunKey,
+			Pipeline: pipelineName,
+			Options:  opts,
+			Priority: priority,
+		}, longPoll)
+		return
+	}
+
 	// Run ETL pipeline
-	if err := h.etlService.RunETL(ctx, since); err != nil {
-		h.metrics.RecordHTTPRequest("POST", "/ingest/run", "500", time.Since(start))
-		log.WithError(err).Error("ETL ingestion failed")
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":      "ETL ingestion failed",
-			"message":    err.Error(),
-			"request_id": requestID,
-		})
+	var pipeline *domain.Pipeline
+	var summary *domain.RunSummary
+	err = h.jobQueue.Run(ctx, domain.JobTypeIngest, priority, func(ctx context.Context) error {
+		var err error
+		if pipelineName != "" {
+			pipeline, summary, err = h.pipelineService.RunPipeline(ctx, pipelineName, req.Tags)
+			return err
+		}
+		summary, err = h.etlService.RunETLWithOptions(ctx, opts)
+		return err
+	})
+	if errors.Is(err, domain.ErrMaintenance) {
+		h.maintenanceError(c, "POST", "/ingest/run", requestID, start, err)
+		return
+	}
+	if errors.Is(err, domain.ErrQuotaExceeded) {
+		h.quotaError(c, "POST", "/ingest/run", requestID, start, err)
+		return
+	}
+	if errors.Is(err, domain.ErrJobQueueTimeout) {
+		h.metrics.RecordHTTPRequest("POST", "/ingest/run", "503", time.Since(start))
+		log.WithError(err).Warn("ETL ingestion not admitted")
+		c.JSON(http.StatusServiceUnavailable, errorBody(c, requestID, "job_queue_busy", err.Error()))
+		return
+	}
+	if errors.Is(err, domain.ErrPipelineNotFound) {
+		h.metrics.RecordHTTPRequest("POST", "/ingest/run", "404", time.Since(start))
+		c.JSON(http.StatusNotFound, errorBody(c, requestID, "pipeline_not_found", err.Error()))
+		return
+	}
+	if errors.Is(err, domain.ErrParseThreshold) {
+		h.metrics.RecordHTTPRequest("POST", "/ingest/run", "422", time.Since(start))
+		log.WithError(err).Warn("ETL ingestion rejected by parse policy")
+		body := errorBody(c, requestID, "parse_policy_violated", err.Error())
+		body["summary"] = summary
+		c.JSON(http.StatusUnprocessableEntity, body)
+		return
+	}
+	if err != nil {
+		status, code := errorStatus(err, "ingestion_failed")
+		h.metrics.RecordHTTPRequest("POST", "/ingest/run", strconv.Itoa(status), time.Since(start))
+		if status >= http.StatusInternalServerError {
+			log.WithError(err).Error("ETL ingestion failed")
+		}
+		c.JSON(status, errorBody(c, requestID, code, err.Error()))
 		return
 	}
 
@@ -82,12 +287,17 @@ func (h *HTTPHandlers) IngestRun(c *gin.Context) {
 
 	response := gin.H{
 		"message":    "ETL ingestion completed successfully",
+		"summary":    summary,
 		"request_id": requestID,
 	}
 
 	if since != nil {
 		response["since"] = since.Format("2006-01-02")
 	}
+	if pipeline != nil {
+		response["pipeline"] = pipeline.Name
+		response["pipeline_version"] = pipeline.Version
+	}
 
 	c.JSON(http.StatusOK, response)
 }
@@ -98,12 +308,12 @@ func (h *HTTPHandlers) GetAPIInfo(c *gin.Context) {
 	h.metrics.IncHTTPRequestsInFlight()
 	defer h.metrics.DecHTTPRequestsInFlight()
 
-	requestID := uuid.New().String()
+	requestID := requestIDOf(c)
 
 	apiInfo := gin.H{
 		"api_version": "v1",
 		"service":     "ETL Service",
-		"version":     "1.0.0",
+		"version":     buildinfo.Version,
 		"description": "ETL service for processing Ads and CRM data into business metrics",
 		"endpoints": gin.H{
 			"ingest": gin.H{
@@ -112,27 +322,147 @@ func (h *HTTPHandlers) GetAPIInfo(c *gin.Context) {
 				"endpoints": gin.H{
 					"run": gin.H{
 						"path":        "/api/v1/ingest/run",
-						"description": "Run ETL pipeline with optional date filter",
+						"description": "Queue an ETL run with optional date filter; returns 202 with a job_id to poll",
 						"parameters": gin.H{
-							"since": "Optional date filter (YYYY-MM-DD format)",
+							"since":             "Optional date filter (YYYY-MM-DD format)",
+							"pipeline":          "Optional: name of a pipeline preset to run",
+							"parse_mode":        "Optional: lenient (default), strict or threshold",
+							"max_errors":        "Optional: rejected rows that fail a strict run (default 1)",
+							"max_error_percent": "Optional: rejected row percentage that fails a threshold run",
+							"priority":          "Optional: low, normal or high (default: low for backfills, normal otherwise)",
+							"wait":              "Optional: true to wait for the run and get its summary instead of a 202 with a job_id, or a duration of up to 25s to long-poll the job",
+							"force_full":        "Optional: true to extract everything instead of resuming from the checkpoints",
 						},
+						"body":    "Optional JSON object with a run_key submitting the run once and tags to record it with, e.g. {\"run_key\": \"dag_run_1\", \"tags\": {\"trigger\": \"airflow\"}}",
 						"example": "/api/v1/ingest/run?since=2025-01-01",
 					},
+					"jobs": gin.H{
+						"path":        "/api/v1/ingest/jobs",
+						"method":      "GET",
+						"description": "Ingest jobs started by /api/v1/ingest/run, most recent first",
+						"parameters": gin.H{
+							"limit": "Optional: max jobs to return (1-1000, default 100)",
+						},
+					},
+					"job_detail": gin.H{
+						"path":        "/api/v1/ingest/jobs/:id",
+						"method":      "GET",
+						"description": "State of an ingest job: queued, running with its stage and records so far, succeeded with the run summary, or failed with the error and its error_code",
+						"parameters": gin.H{
+							"wait": "Optional: duration of up to 25s to long-poll until the job is terminal",
+						},
+					},
+					"checkpoints": gin.H{
+						"path":        "/api/v1/ingest/checkpoints",
+						"method":      "GET",
+						"description": "Last successful extraction per source; runs without a since filter only extract records from its day on",
+					},
+					"objects": gin.H{
+						"path":        "/api/v1/ingest/objects",
+						"method":      "GET",
+						"description": "Object store files already ingested, which later runs skip unless their ETag changes",
+						"parameters": gin.H{
+							"source": "Optional: ads or crm",
+						},
+					},
+					"connectors": gin.H{
+						"path":        "/api/v1/ingest/connectors",
+						"method":      "GET",
+						"description": "Connectors runs extract ads and CRM from: the built-in sources and those declared in CONNECTORS_FILE, without their credentials",
+					},
+					"stream": gin.H{
+						"path":        "/api/v1/ingest/stream",
+						"method":      "GET",
+						"description": "State of streaming ingestion from the Kafka topics of KAFKA_REST_URL: batches loaded, the last failure and the offsets committed per partition",
+					},
+					"upload": gin.H{
+						"path":        "/api/v1/ingest/upload",
+						"description": "Run the pipeline on uploaded ads and/or CRM CSV files instead of the upstreams and return its summary",
+						"body":        "multipart/form-data with ads and/or crm files and an optional tags JSON object",
+						"parameters": gin.H{
+							"priority": "Optional: low, normal or high (default normal)",
+						},
+					},
+					"push": gin.H{
+						"path":        "/api/v1/ingest/push",
+						"description": "Apply pushed ads and CRM records and recalculate the metrics of the UTMs they touch immediately",
+						"body":        "JSON object with ads and/or opportunities arrays in the source API row format",
+					},
+					"webhook": gin.H{
+						"path":        "/api/v1/ingest/webhook/{ads|crm}",
+						"description": "Apply records pushed by an upstream system and update the affected metrics immediately; signed with X-Timestamp and X-Signature (WEBHOOK_ADS_SECRET, WEBHOOK_CRM_SECRET, WEBHOOK_TOLERANCE)",
+						"body":        "One record or a JSON array of records in the source API row format",
+					},
+					"run_compare": gin.H{
+						"path":        "/api/v1/ingest/runs/compare",
+						"method":      "GET",
+						"description": "Differences in record counts, skipped records and per-channel metrics between two runs over the same window",
+						"parameters": gin.H{
+							"a": "Required: ID of the baseline run",
+							"b": "Required: ID of the run compared against a",
+						},
+					},
+					"runs": gin.H{
+						"path":        "/api/v1/ingest/runs",
+						"method":      "GET",
+						"description": "Recorded runs, most recently completed first",
+						"parameters": gin.H{
+							"status":   "Optional: completed or failed",
+							"pipeline": "Optional: runs of one pipeline preset",
+							"tag":      "Optional, repeatable: key:value for runs with the tag, or key for runs with the tag set",
+							"limit":    "Optional: max runs to return (1-1000, default 50)",
+						},
+					},
+					"run_detail": gin.H{
+						"path":        "/api/v1/ingest/runs/:id",
+						"method":      "GET",
+						"description": "Finished run with its stats, cost, anomalies and error",
+					},
+					"run_certification": gin.H{
+						"path":        "/api/v1/ingest/runs/:id/certification",
+						"method":      "GET",
+						"description": "Download the signed certification of a completed run: record counts, checksums, validation results, config hash and build",
+					},
+					"run_join_report": gin.H{
+						"path":        "/api/v1/ingest/runs/:id/join-report",
+						"method":      "GET",
+						"description": "Matched and unmatched UTM keys of ads and opportunities in the run's metrics window, with samples of the unmatched",
+					},
+					"notification_preview": gin.H{
+						"path":        "/api/v1/ingest/runs/:id/notifications/:channel",
+						"method":      "GET",
+						"description": "Render the notification a channel sends for the run without sending it",
+					},
+					"rollback": gin.H{
+						"path":        "/api/v1/ingest/runs/:id/rollback",
+						"method":      "POST",
+						"description": "Restore the records a run or push changed to their previous versions and recalculate the affected metrics; 202 with a pending approval when rollbacks require one",
+					},
+					"restatements": gin.H{
+						"path":        "/api/v1/ingest/restatements",
+						"method":      "GET",
+						"description": "Opportunities ingested again with changed amounts, stages, dates or UTMs and the metrics they restated, most recent first",
+						"parameters": gin.H{
+							"opportunity_id": "Optional: restatements of one opportunity",
+							"limit":          "Optional: max restatements to return (1-1000, default 100)",
+						},
+					},
 				},
 			},
 			"metrics": gin.H{
-				"description": "Query business metrics with various filters",
-				"methods":     []string{"GET"},
+				"description": "Query business metrics with various filters; an optional API key restricts them to its scope, and row queries are held to QUERY_ROW_BUDGETS",
+				"methods":     []string{"GET", "POST"},
 				"endpoints": gin.H{
 					"channel": gin.H{
 						"path":        "/api/v1/metrics/channel",
 						"description": "Get metrics filtered by channel",
 						"parameters": gin.H{
-							"channel": "Required: Channel name (e.g., google_ads)",
-							"from":    "Optional: Start date (YYYY-MM-DD)",
-							"to":      "Optional: End date (YYYY-MM-DD)",
-							"limit":   "Optional: Number of results (default: 100)",
-							"offset":  "Optional: Pagination offset (default: 0)",
+							"channel":  "Required: Channel name (e.g., google_ads)",
+							"from":     "Optional: Start date (YYYY-MM-DD)",
+							"to":       "Optional: End date (YYYY-MM-DD)",
+							"limit":    "Optional: Number of results (default: 100)",
+							"offset":   "Optional: Pagination offset (default: 0)",
+							"currency": "Optional: convert amounts from BASE_CURRENCY with stored FX rates (e.g. EUR)",
 						},
 						"example": "/api/v1/metrics/channel?channel=google_ads&from=2025-01-01&to=2025-01-31",
 					},
@@ -145,39 +475,380 @@ func (h *HTTPHandlers) GetAPIInfo(c *gin.Context) {
 							"to":           "Optional: End date (YYYY-MM-DD)",
 							"limit":        "Optional: Number of results (default: 100)",
 							"offset":       "Optional: Pagination offset (default: 0)",
+							"currency":     "Optional: convert amounts from BASE_CURRENCY with stored FX rates (e.g. EUR)",
 						},
 						"example": "/api/v1/metrics/funnel?utm_campaign=back_to_school&from=2025-01-01&to=2025-01-31",
 					},
+					"dimension_values": gin.H{
+						"path":        "/api/v1/metrics/dimensions/:name/values",
+						"description": "Get distinct values of a dimension with row counts (for filter dropdowns)",
+						"parameters": gin.H{
+							"name": "Required: channel, campaign_id, utm_campaign, utm_source, utm_medium or ad_group_id",
+							"from": "Optional: Start date (YYYY-MM-DD)",
+							"to":   "Optional: End date (YYYY-MM-DD)",
+						},
+						"example": "/api/v1/metrics/dimensions/channel/values?from=2025-01-01&to=2025-01-31",
+					},
+					"timeseries": gin.H{
+						"path":        "/api/v1/metrics/timeseries",
+						"description": "Get a metric over time as aligned arrays of timestamps and values per series, for charting",
+						"parameters": gin.H{
+							"metric":   "Required: e.g. clicks, cost, leads, revenue, cpc, cpa, roas or cvr_click_to_lead",
+							"group_by": "Optional: channel, campaign_id, utm_campaign, utm_source, utm_medium or ad_group_id",
+							"interval": "Optional: day, week or month (default: day)",
+							"fill":     "Optional: zero or null for gaps (default: zero)",
+							"from":     "Optional: Start date (YYYY-MM-DD)",
+							"to":       "Optional: End date (YYYY-MM-DD)",
+						},
+						"example": "/api/v1/metrics/timeseries?metric=roas&group_by=channel&interval=week",
+					},
+					"catalog": gin.H{
+						"path":        "/api/v1/metrics/catalog",
+						"description": "Definitions of every metric: name, formula, unit, type, dimensions and the version it is available since",
+					},
+					"revenue": gin.H{
+						"path":        "/api/v1/metrics/revenue",
+						"description": "Get closed won revenue bucketed by the date it is recognized on",
+						"parameters": gin.H{
+							"recognition": "Optional: created or closed (default: REVENUE_RECOGNITION)",
+							"interval":    "Optional: day, week or month (default: month)",
+							"from":        "Optional: Start date (YYYY-MM-DD)",
+							"to":          "Optional: End date (YYYY-MM-DD)",
+						},
+						"example": "/api/v1/metrics/revenue?recognition=closed&from=2025-01-01&to=2025-06-30",
+					},
+					"keywords": gin.H{
+						"path":        "/api/v1/metrics/keywords",
+						"description": "Get spend, clicks and CPC per keyword and match type from the keyword feed (KEYWORDS_API_URL)",
+						"parameters": gin.H{
+							"from":        "Optional: Start date (YYYY-MM-DD)",
+							"to":          "Optional: End date (YYYY-MM-DD)",
+							"channel":     "Optional: Filter by channel",
+							"campaign_id": "Optional: Filter by campaign ID",
+							"ad_group_id": "Optional: Filter by ad group ID",
+							"match_type":  "Optional: exact, phrase or broad",
+							"keyword":     "Optional: Filter by keyword",
+						},
+						"example": "/api/v1/metrics/keywords?match_type=exact&from=2025-01-01",
+					},
+					"models": gin.H{
+						"path":        "/api/v1/metrics/models",
+						"description": "List the derived models of DERIVED_MODELS_FILE with their latest materialization",
+					},
+					"model": gin.H{
+						"path":        "/api/v1/metrics/models/:name",
+						"description": "Get the latest rows of a derived model",
+						"example":     "/api/v1/metrics/models/weekly_channel",
+					},
 					"summary": gin.H{
 						"path":        "/api/v1/metrics/summary",
-						"description": "Get aggregated metrics summary for the last 30 days",
-						"parameters":  gin.H{},
-						"example":     "/api/v1/metrics/summary",
+						"description": "Get aggregated metrics summary for the last 60 days, partial with warnings when some ranges cannot be read",
+						"parameters": gin.H{
+							"currency": "Optional: convert amounts from BASE_CURRENCY with stored FX rates (e.g. EUR)",
+						},
+						"example": "/api/v1/metrics/summary?currency=EUR",
+					},
+					"batch": gin.H{
+						"path":        "/api/v1/metrics/batch",
+						"method":      "POST",
+						"description": "Write pre-computed metrics of another pipeline, {\"metrics\": [...]}, tagged with the name of its METRICS_PRODUCER_KEYS key; the whole batch is rejected when a row is invalid",
 					},
 				},
 			},
 			"export": gin.H{
 				"description": "Export processed data to external systems",
-				"methods":     []string{"POST"},
+				"methods":     []string{"GET", "POST"},
 				"endpoints": gin.H{
 					"run": gin.H{
 						"path":        "/api/v1/export/run",
 						"description": "Export metrics for a specific date",
 						"parameters": gin.H{
-							"date": "Required: Date to export (YYYY-MM-DD format)",
+							"date":        "Required: Date to export (YYYY-MM-DD format)",
+							"priority":    "Optional: low, normal or high (default: high)",
+							"currency":    "Optional: convert amounts from BASE_CURRENCY with stored FX rates (e.g. EUR)",
+							"destination": "Optional: a destination of EXPORT_DESTINATIONS_FILE, shaped by its transforms (default: the sink)",
+							"mode":        "Optional: full, or diff to send only the rows changed since the last export of the date to the destination, marked in _change (default: the destination's mode or EXPORT_MODE)",
 						},
 						"example": "/api/v1/export/run?date=2025-01-01",
 					},
+					"raw": gin.H{
+						"path":        "/api/v1/export/raw",
+						"description": "Export processed ads and CRM records (PII masked) for a date range",
+						"parameters": gin.H{
+							"from":        "Optional: Start date (YYYY-MM-DD)",
+							"to":          "Optional: End date (YYYY-MM-DD)",
+							"format":      "Optional: ndjson, csv or parquet (default: ndjson)",
+							"destination": "Optional: file, sink or s3 (default: file)",
+							"dataset":     "Optional: ads or crm (default: both)",
+							"priority":    "Optional: low, normal or high (default: high)",
+						},
+						"example": "/api/v1/export/raw?from=2025-01-01&to=2025-01-31&format=csv",
+					},
+					"model": gin.H{
+						"path":        "/api/v1/export/models/:name",
+						"description": "Export the latest rows of a derived model",
+						"parameters": gin.H{
+							"destination": "Optional: a destination of EXPORT_DESTINATIONS_FILE, without its transforms (default: the sink)",
+						},
+					},
+					"verify": gin.H{
+						"path":        "/api/v1/export/verify",
+						"description": "Send a signed synthetic payload to the sink and check it is accepted and unsigned payloads are rejected",
+						"body":        "Optional JSON object with the destination of EXPORT_DESTINATIONS_FILE to verify instead of the sink",
+					},
+					"acks": gin.H{
+						"path":        "/api/v1/export/acks",
+						"method":      "POST",
+						"description": "Confirm or reject an export once the sink processed it, signed with X-Signature, by destinations with their own secret and X-Export-Destination (EXPORT_ACK_TIMEOUT)",
+						"body":        "JSON object with the export_id, status (confirmed or rejected) and an optional reason",
+					},
+					"deliveries": gin.H{
+						"path":        "/api/v1/export/deliveries",
+						"description": "List exports awaiting or having received their sink's ack, most recent first",
+						"parameters": gin.H{
+							"state":     "Optional: delivered, confirmed or rejected",
+							"stuck":     "Optional: true for deliveries not acknowledged by their deadline",
+							"export_id": "Optional: deliveries of this export",
+							"limit":     "Optional: max deliveries to return (default 100)",
+						},
+					},
+					"delivery_detail": gin.H{
+						"path":        "/api/v1/export/deliveries/:id",
+						"description": "An export delivery and the state of its ack",
+					},
+					"approvals": gin.H{
+						"path":        "/api/v1/export/approvals",
+						"description": "List dates whose exports are held because their spend deviates from the baseline (EXPORT_HOLD_SPEND_FACTOR)",
+						"parameters": gin.H{
+							"status": "Optional: pending or approved",
+							"limit":  "Optional: max holds to return (default 100)",
+						},
+					},
+					"approve": gin.H{
+						"path":        "/api/v1/export/approvals/:id",
+						"method":      "POST",
+						"description": "Approve a held date and export its metrics",
+					},
+				},
+			},
+		},
+		"approvals": gin.H{
+			"description": "Rollbacks and backfills waiting for a second operator (APPROVAL_REQUIRED_ACTIONS)",
+			"methods":     []string{"GET", "POST"},
+			"endpoints": gin.H{
+				"list": gin.H{
+					"path":        "/api/v1/approvals",
+					"description": "List approval requests, most recent first",
+					"parameters": gin.H{
+						"status": "Optional: pending, approved, rejected, executed or failed",
+						"action": "Optional: rollback or backfill",
+						"limit":  "Optional: max requests to return (1-1000, default 100)",
+					},
+				},
+				"get":     gin.H{"path": "/api/v1/approvals/:id", "description": "Get an approval request and the outcome of its operation"},
+				"approve": gin.H{"path": "/api/v1/approvals/:id/approve", "description": "Approve a pending request under another admin API key than its requester's and execute it"},
+				"reject":  gin.H{"path": "/api/v1/approvals/:id/reject", "description": "Reject or withdraw a pending request (optional JSON body with a reason)"},
+			},
+		},
+		"pipelines": gin.H{
+			"description": "Manage named pipeline presets",
+			"methods":     []string{"GET", "POST", "PUT", "DELETE"},
+			"endpoints": gin.H{
+				"list":     gin.H{"path": "/api/v1/pipelines", "description": "List pipeline presets"},
+				"create":   gin.H{"path": "/api/v1/pipelines", "description": "Create a pipeline preset (JSON body)"},
+				"get":      gin.H{"path": "/api/v1/pipelines/:name", "description": "Get a pipeline preset"},
+				"update":   gin.H{"path": "/api/v1/pipelines/:name", "description": "Replace a pipeline preset (JSON body)"},
+				"delete":   gin.H{"path": "/api/v1/pipelines/:name", "description": "Delete a pipeline preset"},
+				"history":  gin.H{"path": "/api/v1/pipelines/:name/history", "description": "Audit trail of a pipeline preset"},
+				"schedule": gin.H{"path": "/api/v1/pipelines/:name/schedule", "description": "Upcoming scheduled runs, including calendar skips (limit: 1-100, default 10)"},
+				"runs":     gin.H{"path": "/api/v1/pipelines/:name/runs", "description": "History of scheduled and catch-up runs, most recent first (limit: 1-1000, default 100)"},
+			},
+		},
+		"usage": gin.H{
+			"path":        "/api/v1/usage",
+			"method":      "GET",
+			"description": "Quota usage of the caller's tenant (X-Tenant-ID) and of the upstream APIs, and the tenant's accounted run costs for a month",
+			"parameters": gin.H{
+				"month": "Optional: month of the cost totals (YYYY-MM, default current UTC month)",
+			},
+		},
+		"events": gin.H{
+			"description": "Append-only log of ingested record versions",
+			"methods":     []string{"GET"},
+			"endpoints": gin.H{
+				"list": gin.H{
+					"path":        "/api/v1/events",
+					"description": "Record versions, most recent first",
+					"parameters": gin.H{
+						"source":        "Optional: ads or crm",
+						"key":           "Optional: record key",
+						"ingest_run_id": "Optional: versions written by one run or push",
+						"tag":           "Optional, repeatable: versions written by runs with the tag, key:value or key",
+						"limit":         "Optional: max versions to return (1-1000, default 100)",
+					},
+				},
+				"snapshot": gin.H{
+					"path":        "/api/v1/events/snapshot",
+					"description": "Latest version of every record at a point in time",
+					"parameters": gin.H{
+						"as_of":         "Optional: RFC 3339 time (default now)",
+						"ingest_run_id": "Optional: read as of the end of a run or push",
+					},
+				},
+			},
+		},
+		"shadows": gin.H{
+			"description": "Candidate attribution models, parse policies and value policies evaluated on every run without affecting the active dataset",
+			"methods":     []string{"GET", "PUT", "POST", "DELETE"},
+			"endpoints": gin.H{
+				"list":    gin.H{"path": "/api/v1/shadows", "description": "Registered shadow configs"},
+				"save":    gin.H{"path": "/api/v1/shadows/:label", "method": "PUT", "description": "Register or replace a shadow config (JSON body: attribution_mode, attribution_half_life, parsing, values)"},
+				"delete":  gin.H{"path": "/api/v1/shadows/:label", "method": "DELETE", "description": "Stop evaluating a shadow config; its results are kept"},
+				"results": gin.H{"path": "/api/v1/shadows/:label/results", "description": "Diff reports against the active config, most recent first (limit, default 100)"},
+				"result":  gin.H{"path": "/api/v1/shadows/:label/results/:id", "description": "A diff report with the shadow's metrics"},
+				"promote": gin.H{"path": "/api/v1/shadows/:label/results/:id/promote", "method": "POST", "description": "Replace the metrics of the result's window with the shadow's, served by all metrics endpoints"},
+			},
+		},
+		"datasets": gin.H{
+			"description": "Promoted metric datasets and their rollback",
+			"methods":     []string{"GET", "POST"},
+			"endpoints": gin.H{
+				"list":   gin.H{"path": "/api/v1/datasets", "description": "Dataset promotions, most recent first (limit, default 100)"},
+				"demote": gin.H{"path": "/api/v1/datasets/demote", "method": "POST", "description": "Roll back the most recent active promotion, restoring the metrics it replaced"},
+			},
+		},
+		"reporting": gin.H{
+			"path":        "/api/v1/reporting/flat",
+			"method":      "GET",
+			"description": "Metrics as flat rows with a fixed column set for BI connectors; requires an API key from REPORTING_API_KEYS or API_KEYS_FILE",
+			"parameters": gin.H{
+				"from":   "Optional: start date, YYYY-MM-DD (default 365 days ago)",
+				"to":     "Optional: end date, YYYY-MM-DD (default today)",
+				"cursor": "Optional: next_cursor of the previous page",
+				"limit":  "Optional: rows per page, 1-1000 (default 1000)",
+			},
+		},
+		"shared": gin.H{
+			"description": "Read-only metrics views of signed, expiring share tokens for embedding, e.g. in a partner portal; the token goes in the token parameter or as a Bearer token",
+			"methods":     []string{"GET", "POST"},
+			"endpoints": gin.H{
+				"issue":      gin.H{"path": "/api/v1/admin/share-tokens", "method": "POST", "description": "Issue a share token with an admin API key (JSON body: name, scope, days or from and to, role, expires_in up to SHARE_TOKEN_MAX_TTL)"},
+				"metrics":    gin.H{"path": "/api/v1/shared/metrics", "description": "Metrics rows of the token's view (from, to narrow its range; limit 1-1000, offset)"},
+				"timeseries": gin.H{"path": "/api/v1/shared/timeseries", "description": "A metric of the token's view over time (metric, group_by, interval, fill, from, to)"},
+			},
+		},
+		"actions": gin.H{
+			"path":        "/api/v1/actions",
+			"method":      "GET",
+			"description": "Campaigns suggested for a pause because their daily CPA or spend stayed above a cap for consecutive days",
+			"parameters": gin.H{
+				"through": "Optional: last day evaluated, YYYY-MM-DD (default yesterday)",
+			},
+		},
+		"quarantine": gin.H{
+			"path":        "/api/v1/quarantine",
+			"method":      "GET",
+			"description": "Rows rejected during parsing, most recent first",
+			"parameters": gin.H{
+				"source": "Optional: ads, crm or ga4",
+				"limit":  "Optional: max rows to return (default 100)",
+			},
+		},
+		"deadletter": gin.H{
+			"description": "The quarantined rows as a dead-letter queue",
+			"methods":     []string{"GET", "POST"},
+			"endpoints": gin.H{
+				"list": gin.H{"path": "/api/v1/deadletter", "description": "Same as /api/v1/quarantine"},
+				"replay": gin.H{
+					"path":        "/api/v1/deadletter/replay",
+					"description": "Reprocess quarantined ads and CRM rows like pushed records and take them out of the quarantine",
+					"body":        "Optional JSON object with ids, or source and limit (default 100), and payloads correcting rows by ID",
+				},
+			},
+		},
+		"fx": gin.H{
+			"description": "Daily FX rates from BASE_CURRENCY used by the currency parameter of metrics queries and exports",
+			"methods":     []string{"GET", "PUT"},
+			"endpoints": gin.H{
+				"list": gin.H{
+					"path":        "/api/v1/fx/rates",
+					"description": "List the stored rates of a currency",
+					"parameters": gin.H{
+						"currency": "Required: three letter currency code",
+						"from":     "Optional: start date, YYYY-MM-DD (default 365 days ago)",
+						"to":       "Optional: end date, YYYY-MM-DD (default today)",
+					},
+				},
+				"put": gin.H{"path": "/api/v1/fx/rates", "description": "Store rates, replacing those of the same currency and date (JSON array of date, currency and rate)"},
+			},
+		},
+		"gaps": gin.H{
+			"path":        "/api/v1/gaps",
+			"method":      "GET",
+			"description": "Runs of days without stored data per source, up to yesterday",
+			"parameters": gin.H{
+				"source": "Optional: ads, crm or ga4",
+				"from":   "Optional: start date, YYYY-MM-DD (default 365 days ago)",
+				"to":     "Optional: end date, YYYY-MM-DD (default yesterday)",
+			},
+		},
+		"jobs": gin.H{
+			"description": "Inspect the ingest/export job queue and the supervised background jobs",
+			"methods":     []string{"GET"},
+			"endpoints": gin.H{
+				"queue": gin.H{
+					"path":        "/api/v1/jobs/queue",
+					"description": "Running and queued jobs per job type",
+					"parameters":  gin.H{},
+					"example":     "/api/v1/jobs/queue",
+				},
+				"background": gin.H{
+					"path":        "/api/v1/jobs/background",
+					"description": "Background jobs with their last heartbeat and restarts",
+					"parameters":  gin.H{},
+					"example":     "/api/v1/jobs/background",
+				},
+				"incidents": gin.H{
+					"path":        "/api/v1/jobs/incidents",
+					"description": "Background jobs restarted by the watchdog, most recent first",
+					"parameters": gin.H{
+						"job":   "Optional: scheduler, event_compaction or flag_reload",
+						"limit": "Optional: max incidents to return (default 100)",
+					},
+					"example": "/api/v1/jobs/incidents?job=scheduler",
 				},
 			},
 		},
+		"admin": gin.H{
+			"description": "Operational controls",
+			"methods":     []string{"GET", "POST"},
+			"endpoints": gin.H{
+				"maintenance": gin.H{"path": "/admin/maintenance", "description": "Get or set maintenance mode (JSON body: enabled, reason, retry_after_seconds)"},
+			},
+		},
+		"flags": gin.H{
+			"description": "Feature flags and their state for the X-Tenant-ID tenant",
+			"methods":     []string{"GET", "POST"},
+			"endpoints": gin.H{
+				"list":   gin.H{"path": "/api/v1/flags", "description": "List feature flags with their active state"},
+				"reload": gin.H{"path": "/api/v1/flags/reload", "description": "Re-read the feature flag file"},
+			},
+		},
 		"business_metrics": gin.H{
-			"cpc":             "Cost Per Click (cost / clicks)",
-			"cpa":             "Cost Per Acquisition (cost / leads)",
-			"cvr_lead_to_opp": "Conversion Rate Lead to Opportunity (opportunities / leads)",
-			"cvr_opp_to_won":  "Conversion Rate Opportunity to Won (closed_won / opportunities)",
-			"roas":            "Return on Ad Spend (revenue / cost)",
+			"cpc":                "Cost Per Click (cost / clicks)",
+			"cpa":                "Cost Per Acquisition (cost / leads)",
+			"cvr_click_to_lead":  "Conversion Rate Click to Lead (leads / clicks)",
+			"cvr_lead_to_opp":    "Conversion Rate Lead to Opportunity (opportunities / leads)",
+			"cvr_opp_to_won":     "Conversion Rate Opportunity to Won (closed_won / opportunities)",
+			"roas":               "Return on Ad Spend (revenue / cost)",
+			"attributed_revenue": "Closed won revenue credited by the attribution model (ATTRIBUTION_MODE)",
+			"pipeline_value":     "Amounts of open leads and opportunities",
+			"expected_revenue":   "Amounts of open leads and opportunities weighted by their win probability",
+			"stage_conversions":  "Conversion rates between adjacent FUNNEL_STAGES (later stage / earlier stage)",
+			"sessions":           "GA4 sessions of the UTM (GA4_PROPERTY_ID)",
+			"conversions":        "GA4 conversions of the UTM (GA4_CONVERSION_METRIC)",
 		},
+		"languages":  i18n.Languages(),
 		"request_id": requestID,
 	}
 
@@ -191,42 +862,33 @@ func (h *HTTPHandlers) GetMetricsByChannel(c *gin.Context) {
 	h.metrics.IncHTTPRequestsInFlight()
 	defer h.metrics.DecHTTPRequestsInFlight()
 
-	requestID := uuid.New().String()
-	ctx := context.WithValue(c.Request.Context(), logger.RequestIDKey, requestID)
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
 
 	// Parse query parameters
 	channel := c.Query("channel")
 	if channel == "" {
 		h.metrics.RecordHTTPRequest("GET", "/metrics/channel", "400", time.Since(start))
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":      "Missing required parameter",
-			"message":    "channel parameter is required",
-			"request_id": requestID,
-		})
+		c.JSON(http.StatusBadRequest, errorBody(c, requestID, "missing_parameter", "channel"))
 		return
 	}
 
 	from, to, limit, offset, err := h.parseMetricsParams(c)
 	if err != nil {
 		h.metrics.RecordHTTPRequest("GET", "/metrics/channel", "400", time.Since(start))
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":      "Invalid parameters",
-			"message":    err.Error(),
-			"request_id": requestID,
-		})
+		c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_parameters", err.Error()))
 		return
 	}
 
 	// Get metrics
-	response, err := h.metricsService.GetMetricsByChannel(ctx, channel, from, to, limit, offset)
+	response, err := h.metricsService.GetMetricsByChannel(ctx, channel, from, to, limit, offset, c.Query("currency"))
 	if err != nil {
-		h.metrics.RecordHTTPRequest("GET", "/metrics/channel", "500", time.Since(start))
-		h.logger.WithContext(ctx).WithError(err).Error("Failed to get metrics by channel")
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":      "Failed to retrieve metrics",
-			"message":    err.Error(),
-			"request_id": requestID,
-		})
+		status, code := errorStatus(err, "metrics_retrieval_failed")
+		h.metrics.RecordHTTPRequest("GET", "/metrics/channel", strconv.Itoa(status), time.Since(start))
+		if status >= http.StatusInternalServerError {
+			h.logger.WithContext(ctx).WithError(err).Error("Failed to get metrics by channel")
+		}
+		c.JSON(status, errorBody(c, requestID, code, err.Error()))
 		return
 	}
 
@@ -240,6 +902,18 @@ func (h *HTTPHandlers) GetMetricsByChannel(c *gin.Context) {
 		"has_more":   response.HasMore,
 		"request_id": requestID,
 	}
+	if response.Staleness != nil {
+		responseData["staleness"] = response.Staleness
+	}
+	if response.Meta != nil {
+		responseData["meta"] = response.Meta
+	}
+	if response.QueryCost != nil {
+		responseData["query_cost"] = response.QueryCost
+	}
+	if response.Currency != nil {
+		responseData["currency"] = response.Currency
+	}
 
 	c.JSON(http.StatusOK, responseData)
 }
@@ -250,42 +924,33 @@ func (h *HTTPHandlers) GetMetricsByFunnel(c *gin.Context) {
 	h.metrics.IncHTTPRequestsInFlight()
 	defer h.metrics.DecHTTPRequestsInFlight()
 
-	requestID := uuid.New().String()
-	ctx := context.WithValue(c.Request.Context(), logger.RequestIDKey, requestID)
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
 
 	// Parse query parameters
 	utmCampaign := c.Query("utm_campaign")
 	if utmCampaign == "" {
 		h.metrics.RecordHTTPRequest("GET", "/metrics/funnel", "400", time.Since(start))
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":      "Missing required parameter",
-			"message":    "utm_campaign parameter is required",
-			"request_id": requestID,
-		})
+		c.JSON(http.StatusBadRequest, errorBody(c, requestID, "missing_parameter", "utm_campaign"))
 		return
 	}
 
 	from, to, limit, offset, err := h.parseMetricsParams(c)
 	if err != nil {
 		h.metrics.RecordHTTPRequest("GET", "/metrics/funnel", "400", time.Since(start))
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":      "Invalid parameters",
-			"message":    err.Error(),
-			"request_id": requestID,
-		})
+		c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_parameters", err.Error()))
 		return
 	}
 
 	// Get metrics
-	response, err := h.metricsService.GetMetricsByFunnel(ctx, utmCampaign, from, to, limit, offset)
+	response, err := h.metricsService.GetMetricsByFunnel(ctx, utmCampaign, from, to, limit, offset, c.Query("currency"))
 	if err != nil {
-		h.metrics.RecordHTTPRequest("GET", "/metrics/funnel", "500", time.Since(start))
-		h.logger.WithContext(ctx).WithError(err).Error("Failed to get metrics by funnel")
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":      "Failed to retrieve metrics",
-			"message":    err.Error(),
-			"request_id": requestID,
-		})
+		status, code := errorStatus(err, "metrics_retrieval_failed")
+		h.metrics.RecordHTTPRequest("GET", "/metrics/funnel", strconv.Itoa(status), time.Since(start))
+		if status >= http.StatusInternalServerError {
+			h.logger.WithContext(ctx).WithError(err).Error("Failed to get metrics by funnel")
+		}
+		c.JSON(status, errorBody(c, requestID, code, err.Error()))
 		return
 	}
 
@@ -299,59 +964,253 @@ func (h *HTTPHandlers) GetMetricsByFunnel(c *gin.Context) {
 		"has_more":   response.HasMore,
 		"request_id": requestID,
 	}
+	if response.Staleness != nil {
+		responseData["staleness"] = response.Staleness
+	}
+	if response.Meta != nil {
+		responseData["meta"] = response.Meta
+	}
+	if response.QueryCost != nil {
+		responseData["query_cost"] = response.QueryCost
+	}
+	if response.Currency != nil {
+		responseData["currency"] = response.Currency
+	}
+	if response.Funnel != nil {
+		responseData["funnel"] = response.Funnel
+	}
 
 	c.JSON(http.StatusOK, responseData)
 }
 
+// GetDimensionValues returns distinct values of a dimension for filter dropdowns
+func (h *HTTPHandlers) GetDimensionValues(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
+
+	dimension := c.Param("name")
+	if !domain.IsValidDimension(dimension) {
+		h.metrics.RecordHTTPRequest("GET", "/metrics/dimensions/values", "400", time.Since(start))
+		c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_dimension", strings.Join(domain.Dimensions, ", ")))
+		return
+	}
+
+	from, to, err := h.parseDateRange(c)
+	if err != nil {
+		h.metrics.RecordHTTPRequest("GET", "/metrics/dimensions/values", "400", time.Since(start))
+		c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_parameters", err.Error()))
+		return
+	}
+
+	values, err := h.metricsService.GetDimensionValues(ctx, dimension, from, to)
+	if err != nil {
+		status, code := errorStatus(err, "dimension_values_failed")
+		h.metrics.RecordHTTPRequest("GET", "/metrics/dimensions/values", strconv.Itoa(status), time.Since(start))
+		if status >= http.StatusInternalServerError {
+			h.logger.WithContext(ctx).WithError(err).Error("Failed to get dimension values")
+		}
+		c.JSON(status, errorBody(c, requestID, code, err.Error()))
+		return
+	}
+
+	h.metrics.RecordHTTPRequest("GET", "/metrics/dimensions/values", "200", time.Since(start))
+
+	c.JSON(http.StatusOK, gin.H{
+		"dimension":  dimension,
+		"values":     values,
+		"total":      len(values),
+		"from":       from.Format("2006-01-02"),
+		"to":         to.Format("2006-01-02"),
+		"request_id": requestID,
+	})
+}
+
 // ExportRun exports metrics for a specific date
 func (h *HTTPHandlers) ExportRun(c *gin.Context) {
 	start := time.Now()
 	h.metrics.IncHTTPRequestsInFlight()
 	defer h.metrics.DecHTTPRequestsInFlight()
 
-	requestID := uuid.New().String()
-	ctx := context.WithValue(c.Request.Context(), logger.RequestIDKey, requestID)
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
 
 	// Parse date parameter
 	dateStr := c.Query("date")
 	if dateStr == "" {
 		h.metrics.RecordHTTPRequest("POST", "/export/run", "400", time.Since(start))
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":      "Missing required parameter",
-			"message":    "date parameter is required",
-			"request_id": requestID,
-		})
+		c.JSON(http.StatusBadRequest, errorBody(c, requestID, "missing_parameter", "date"))
 		return
 	}
 
 	date, err := time.Parse("2006-01-02", dateStr)
 	if err != nil {
 		h.metrics.RecordHTTPRequest("POST", "/export/run", "400", time.Since(start))
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":      "Invalid date format",
-			"message":    "Date must be in YYYY-MM-DD format",
-			"request_id": requestID,
-		})
+		c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_date_format"))
+		return
+	}
+
+	priority, ok := h.parsePriority(c, domain.PriorityHigh)
+	if !ok {
+		h.metrics.RecordHTTPRequest("POST", "/export/run", "400", time.Since(start))
+		c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_priority"))
+		return
+	}
+
+	mode := c.Query("mode")
+	if err := domain.ValidateExportMode(mode); err != nil {
+		h.metrics.RecordHTTPRequest("POST", "/export/run", "400", time.Since(start))
+		c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_export_mode", mode))
 		return
 	}
 
 	// Export metrics
-	if err := h.metricsService.ExportMetrics(ctx, date); err != nil {
-		h.metrics.RecordHTTPRequest("POST", "/export/run", "500", time.Since(start))
-		h.logger.WithContext(ctx).WithError(err).Error("Failed to export metrics")
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":      "Export failed",
-			"message":    err.Error(),
-			"request_id": requestID,
-		})
+	var result *domain.ExportResult
+	err = h.jobQueue.Run(ctx, domain.JobTypeExport, priority, func(ctx context.Context) error {
+		var err error
+		result, err = h.metricsService.ExportMetricsTo(ctx, date, c.Query("currency"), c.Query("destination"), mode)
+		return err
+	})
+	if errors.Is(err, domain.ErrMaintenance) {
+		h.maintenanceError(c, "POST", "/export/run", requestID, start, err)
+		return
+	}
+	if errors.Is(err, domain.ErrJobQueueTimeout) {
+		h.metrics.RecordHTTPRequest("POST", "/export/run", "503", time.Since(start))
+		c.JSON(http.StatusServiceUnavailable, errorBody(c, requestID, "job_queue_busy", err.Error()))
+		return
+	}
+	if err != nil {
+		status, code := errorStatus(err, "export_failed")
+		h.metrics.RecordHTTPRequest("POST", "/export/run", strconv.Itoa(status), time.Since(start))
+		if status >= http.StatusInternalServerError {
+			h.logger.WithContext(ctx).WithError(err).Error("Failed to export metrics")
+		}
+		c.JSON(status, errorBody(c, requestID, code, err.Error()))
 		return
 	}
 
 	h.metrics.RecordHTTPRequest("POST", "/export/run", "200", time.Since(start))
 
-	c.JSON(http.StatusOK, gin.H{
+	response := gin.H{
 		"message":    "Export completed successfully",
 		"date":       date.Format("2006-01-02"),
+		"mode":       result.Mode,
+		"records":    result.Records,
+		"request_id": requestID,
+	}
+	if destination := c.Query("destination"); destination != "" {
+		response["destination"] = destination
+	}
+	if result.Conversion != nil {
+		response["currency"] = result.Conversion
+	}
+	if result.Delivery != nil {
+		response["delivery"] = result.Delivery
+	}
+	if result.Changes != nil {
+		response["changes"] = result.Changes
+		if result.Records == 0 {
+			response["message"] = "No changes to export"
+		}
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// ExportRaw exports processed ads and CRM records for a date range
+func (h *HTTPHandlers) ExportRaw(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
+
+	from, to, err := h.parseDateRange(c)
+	if err != nil {
+		h.metrics.RecordHTTPRequest("POST", "/export/raw", "400", time.Since(start))
+		c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_parameters", err.Error()))
+		return
+	}
+
+	format := domain.RawExportFormat(c.DefaultQuery("format", string(domain.RawExportNDJSON)))
+	if !format.IsValid() {
+		h.metrics.RecordHTTPRequest("POST", "/export/raw", "400", time.Since(start))
+		c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_format", "ndjson, csv, parquet"))
+		return
+	}
+
+	destination := c.DefaultQuery("destination", domain.RawExportDestinationFile)
+	if destination != domain.RawExportDestinationFile && destination != domain.RawExportDestinationSink && destination != domain.RawExportDestinationS3 {
+		h.metrics.RecordHTTPRequest("POST", "/export/raw", "400", time.Since(start))
+		c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_destination", "file, sink, s3"))
+		return
+	}
+
+	datasets := []string{domain.RawDatasetAds, domain.RawDatasetCRM}
+	if dataset := c.Query("dataset"); dataset != "" {
+		if dataset != domain.RawDatasetAds && dataset != domain.RawDatasetCRM {
+			h.metrics.RecordHTTPRequest("POST", "/export/raw", "400", time.Since(start))
+			c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_dataset", "ads, crm"))
+			return
+		}
+		datasets = []string{dataset}
+	}
+
+	priority, ok := h.parsePriority(c, domain.PriorityHigh)
+	if !ok {
+		h.metrics.RecordHTTPRequest("POST", "/export/raw", "400", time.Since(start))
+		c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_priority"))
+		return
+	}
+
+	var results []domain.RawExportResult
+	err = h.jobQueue.Run(ctx, domain.JobTypeExport, priority, func(ctx context.Context) error {
+		var err error
+		results, err = h.rawExportService.ExportRaw(ctx, domain.RawExportRequest{
+			From:        from,
+			To:          to,
+			Format:      format,
+			Destination: destination,
+			Datasets:    datasets,
+		})
+		return err
+	})
+	if errors.Is(err, domain.ErrMaintenance) {
+		h.maintenanceError(c, "POST", "/export/raw", requestID, start, err)
+		return
+	}
+	if errors.Is(err, domain.ErrJobQueueTimeout) {
+		h.metrics.RecordHTTPRequest("POST", "/export/raw", "503", time.Since(start))
+		c.JSON(http.StatusServiceUnavailable, errorBody(c, requestID, "job_queue_busy", err.Error()))
+		return
+	}
+	if errors.Is(err, domain.ErrFeatureDisabled) {
+		h.metrics.RecordHTTPRequest("POST", "/export/raw", "403", time.Since(start))
+		c.JSON(http.StatusForbidden, errorBody(c, requestID, "feature_disabled", err.Error()))
+		return
+	}
+	if err != nil {
+		status, code := errorStatus(err, "raw_export_failed")
+		h.metrics.RecordHTTPRequest("POST", "/export/raw", strconv.Itoa(status), time.Since(start))
+		if status >= http.StatusInternalServerError {
+			h.logger.WithContext(ctx).WithError(err).Error("Failed to export raw data")
+		}
+		c.JSON(status, errorBody(c, requestID, code, err.Error()))
+		return
+	}
+
+	h.metrics.RecordHTTPRequest("POST", "/export/raw", "200", time.Since(start))
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Raw export completed successfully",
+		"from":       from.Format("2006-01-02"),
+		"to":         to.Format("2006-01-02"),
+		"format":     format,
+		"files":      results,
 		"request_id": requestID,
 	})
 }
@@ -362,19 +1221,18 @@ func (h *HTTPHandlers) GetMetricsSummary(c *gin.Context) {
 	h.metrics.IncHTTPRequestsInFlight()
 	defer h.metrics.DecHTTPRequestsInFlight()
 
-	requestID := uuid.New().String()
-	ctx := context.WithValue(c.Request.Context(), logger.RequestIDKey, requestID)
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
 
 	// Get summary
-	summary, err := h.metricsService.GetMetricsSummary(ctx)
+	summary, err := h.metricsService.GetMetricsSummary(ctx, c.Query("currency"))
 	if err != nil {
-		h.metrics.RecordHTTPRequest("GET", "/metrics/summary", "500", time.Since(start))
-		h.logger.WithContext(ctx).WithError(err).Error("Failed to get metrics summary")
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":      "Failed to retrieve summary",
-			"message":    err.Error(),
-			"request_id": requestID,
-		})
+		status, code := errorStatus(err, "summary_retrieval_failed")
+		h.metrics.RecordHTTPRequest("GET", "/metrics/summary", strconv.Itoa(status), time.Since(start))
+		if status >= http.StatusInternalServerError {
+			h.logger.WithContext(ctx).WithError(err).Error("Failed to get metrics summary")
+		}
+		c.JSON(status, errorBody(c, requestID, code, err.Error()))
 		return
 	}
 
@@ -384,48 +1242,70 @@ func (h *HTTPHandlers) GetMetricsSummary(c *gin.Context) {
 	c.JSON(http.StatusOK, summary)
 }
 
+// GetJobQueue returns the current job queue state
+func (h *HTTPHandlers) GetJobQueue(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+
+	h.metrics.RecordHTTPRequest("GET", "/jobs/queue", "200", time.Since(start))
+	c.JSON(http.StatusOK, gin.H{
+		"queues":     h.jobQueue.Stats(),
+		"request_id": requestID,
+	})
+}
+
 // HealthCheck returns the health status of the service
 func (h *HTTPHandlers) HealthCheck(c *gin.Context) {
 	start := time.Now()
 	h.metrics.IncHTTPRequestsInFlight()
 	defer h.metrics.DecHTTPRequestsInFlight()
 
-	requestID := uuid.New().String()
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
 
+	build := buildinfo.Get()
 	health := gin.H{
 		"status":     "healthy",
-		"timestamp":  time.Now().UTC().Format(time.RFC3339),
+		"timestamp":  h.clock.Now().UTC().Format(time.RFC3339),
 		"service":    "etl-go",
-		"version":    "1.0.0",
+		"version":    build.Version,
+		"build":      build,
 		"request_id": requestID,
 	}
 
+	storage, err := h.storageService.Status(ctx)
+	health["storage"] = storage
+	if err != nil {
+		h.logger.WithContext(ctx).WithError(err).Error("Storage health check failed")
+		health["status"] = "unhealthy"
+		health["error"] = err.Error()
+		h.metrics.RecordHTTPRequest("GET", "/health", "503", time.Since(start))
+		c.JSON(http.StatusServiceUnavailable, health)
+		return
+	}
+
 	h.metrics.RecordHTTPRequest("GET", "/health", "200", time.Since(start))
 	c.JSON(http.StatusOK, health)
 }
 
+// Version handles GET /version
+func (h *HTTPHandlers) Version(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	h.metrics.RecordHTTPRequest("GET", "/version", "200", time.Since(start))
+	c.JSON(http.StatusOK, buildinfo.Get())
+}
+
 // parseMetricsParams parses common query parameters for metrics endpoints
 func (h *HTTPHandlers) parseMetricsParams(c *gin.Context) (from, to time.Time, limit, offset int, err error) {
-	// Parse from parameter
-	fromStr := c.Query("from")
-	if fromStr == "" {
-		from = time.Now().AddDate(0, 0, -365) // Default to last 365 days
-	} else {
-		from, err = time.Parse("2006-01-02", fromStr)
-		if err != nil {
-			return time.Time{}, time.Time{}, 0, 0, err
-		}
-	}
-
-	// Parse to parameter
-	toStr := c.Query("to")
-	if toStr == "" {
-		to = time.Now() // Default to now
-	} else {
-		to, err = time.Parse("2006-01-02", toStr)
-		if err != nil {
-			return time.Time{}, time.Time{}, 0, 0, err
-		}
+	from, to, err = h.parseDateRange(c)
+	if err != nil {
+		return time.Time{}, time.Time{}, 0, 0, err
 	}
 
 	// Parse limit parameter
@@ -452,3 +1332,69 @@ func (h *HTTPHandlers) parseMetricsParams(c *gin.Context) (from, to time.Time, l
 
 	return from, to, limit, offset, nil
 }
+
+// parsePriority parses the optional priority query parameter
+func (h *HTTPHandlers) parsePriority(c *gin.Context, defaultPriority domain.JobPriority) (domain.JobPriority, bool) {
+	name := c.Query("priority")
+	if name == "" {
+		return defaultPriority, true
+	}
+	return domain.ParseJobPriority(name)
+}
+
+// parseParsePolicy reads the parse_mode, max_errors and max_error_percent
+// query parameters. It returns nil when no parse_mode was requested.
+func parseParsePolicy(c *gin.Context) (*domain.ParsePolicy, error) {
+	mode := c.Query("parse_mode")
+	if mode == "" {
+		return nil, nil
+	}
+
+	policy := &domain.ParsePolicy{Mode: domain.ParseMode(mode)}
+	if value := c.Query("max_errors"); value != "" {
+		maxErrors, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("max_errors must be an integer")
+		}
+		policy.MaxErrors = maxErrors
+	}
+	if value := c.Query("max_error_percent"); value != "" {
+		percent, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("max_error_percent must be a number")
+		}
+		policy.MaxErrorPercent = percent
+	}
+
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// parseDateRange parses the from/to query parameters
+func (h *HTTPHandlers) parseDateRange(c *gin.Context) (from, to time.Time, err error) {
+	// Parse from parameter
+	fromStr := c.Query("from")
+	if fromStr == "" {
+		from = h.clock.Now().AddDate(0, 0, -365) // Default to last 365 days
+	} else {
+		from, err = time.Parse("2006-01-02", fromStr)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+	}
+
+	// Parse to parameter
+	toStr := c.Query("to")
+	if toStr == "" {
+		to = h.clock.Now() // Default to now
+	} else {
+		to, err = time.Parse("2006-01-02", toStr)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+	}
+
+	return from, to, nil
+}
@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"etlgo/internal/delivery/middleware"
+	"etlgo/internal/domain"
 	"etlgo/pkg/logger"
 	"etlgo/pkg/metrics"
 
@@ -12,40 +13,85 @@ import (
 )
 
 type HTTPRouter struct {
-	handlers *HTTPHandlers
-	logger   *logger.Logger
-	metrics  *metrics.Metrics
+	handlers     *HTTPHandlers
+	apiKeys      domain.APIKeys
+	adminKeys    domain.APIKeys
+	producerKeys domain.APIKeys
+	shedder      *middleware.LoadShedder
+	ids          domain.IDGenerator
+	logger       *logger.Logger
+	metrics      *metrics.Metrics
 }
 
-func NewHTTPRouter(handlers *HTTPHandlers, logger *logger.Logger, metrics *metrics.Metrics) *HTTPRouter {
+// creates the router. apiKeys are the keys BI connectors and partners use
+// for the reporting and metrics endpoints, adminKeys those operators use
+// for the runtime configuration, producerKeys those other pipelines write
+// pre-computed metrics with, each key's name tagging its rows. shedder
+// turns away ingest runs and long unaggregated queries under load. ids
+// generates the IDs of requests that come without one.
+func NewHTTPRouter(handlers *HTTPHandlers, apiKeys, adminKeys, producerKeys domain.APIKeys, shedder *middleware.LoadShedder, ids domain.IDGenerator, logger *logger.Logger, metrics *metrics.Metrics) *HTTPRouter {
 	return &HTTPRouter{
-		handlers: handlers,
-		logger:   logger,
-		metrics:  metrics,
+		handlers:     handlers,
+		apiKeys:      apiKeys,
+		adminKeys:    adminKeys,
+		producerKeys: producerKeys,
+		shedder:      shedder,
+		ids:          ids,
+		logger:       logger,
+		metrics:      metrics,
 	}
 }
 
+// authenticates reads of metrics and records with the reporting keys.
+// Requests without a key are unrestricted until a key is scoped or given a
+// role; from then on they are refused, as they would see what the scopes
+// and roles hide.
+func (r *HTTPRouter) metricsKey() gin.HandlerFunc {
+	if r.apiKeys.Restricted() {
+		return middleware.APIKey(r.apiKeys, r.logger)
+	}
+	return middleware.OptionalAPIKey(r.apiKeys, r.logger)
+}
+
 func (r *HTTPRouter) SetupRoutes() *gin.Engine {
 	gin.SetMode(gin.ReleaseMode)
 
 	router := gin.New()
 
-	router.Use(middleware.RequestID())
+	router.Use(middleware.RequestID(r.ids))
+	router.Use(middleware.Language())
+	router.Use(middleware.Tenant())
 	router.Use(middleware.Logger(r.logger))
 	router.Use(middleware.Recovery(r.logger))
 	router.Use(middleware.Metrics(r.metrics))
+	router.Use(r.shedder.Track())
 	router.Use(middleware.Timeout(30 * time.Second))
 
 	config := cors.DefaultConfig()
 	config.AllowAllOrigins = true
-	config.AllowMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
-	config.AllowHeaders = []string{"Content-Type", "X-Request-ID"}
+	config.AllowMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+	config.AllowHeaders = []string{"Content-Type", "X-Request-ID", "X-User", "X-Tenant-ID", "X-API-Key", "Authorization"}
 	config.ExposeHeaders = []string{"X-Request-ID"}
 
 	router.Use(cors.New(config))
 
-	// Health endpoint
+	// Health and build endpoints
 	router.GET("/health", r.handlers.HealthCheck)
+	router.GET("/version", r.handlers.Version)
+
+	// Admin endpoints; switching maintenance mode takes an admin key
+	admin := router.Group("/admin")
+	{
+		admin.GET("/maintenance", r.handlers.GetMaintenance)
+		admin.POST("/maintenance", middleware.APIKey(r.adminKeys, r.logger), r.handlers.SetMaintenance)
+	}
+
+	// Expensive requests, shed under load
+	shedIngest := r.shedder.Shed("ingest", nil)
+	shedLongRange := r.shedder.Shed("long_range", r.shedder.LongRange(r.handlers.parseDateRange))
+	shedTimeSeries := r.shedder.Shed("long_range", r.shedder.UnaggregatedLongRange(r.handlers.parseDateRange))
+	shedShared := r.shedder.Shed("long_range", r.shedder.LongRange(r.handlers.sharedWindow))
+	shedSharedTimeSeries := r.shedder.Shed("long_range", r.shedder.UnaggregatedLongRange(r.handlers.sharedWindow))
 
 	// API v1 routes
 	v1 := router.Group("/api/v1")
@@ -53,24 +99,180 @@ func (r *HTTPRouter) SetupRoutes() *gin.Engine {
 		v1.GET("/", r.handlers.GetAPIInfo)
 		v1.GET("", r.handlers.GetAPIInfo)
 
+		// Operations that may wait for a second operator take the admin key
+		// they are requested under
+		operatorKey := middleware.OptionalAPIKey(r.adminKeys, r.logger)
+		// Reads of metrics and records take the reporting key they are
+		// scoped to
+		metricsKey := r.metricsKey()
+		// Pushes, uploads and usage take the key whose tenant's quotas
+		// they consume
+		tenantKey := middleware.OptionalAPIKey(r.apiKeys, r.logger)
+
 		// ETL endpoints
 		etl := v1.Group("/ingest")
 		{
-			etl.POST("/run", r.handlers.IngestRun)
+			etl.POST("/run", shedIngest, operatorKey, r.handlers.IngestRun)
+			etl.POST("/push", tenantKey, r.handlers.IngestPush)
+			etl.POST("/webhook/:source", r.handlers.IngestWebhook)
+			etl.POST("/upload", shedIngest, tenantKey, r.handlers.IngestUpload)
+			etl.GET("/jobs", r.handlers.ListIngestJobs)
+			etl.GET("/jobs/:id", r.handlers.GetIngestJob)
+			etl.GET("/runs", r.handlers.ListRuns)
+			etl.GET("/runs/compare", metricsKey, r.handlers.CompareRuns)
+			etl.GET("/runs/:id", r.handlers.GetRun)
+			etl.GET("/runs/:id/certification", r.handlers.GetRunCertification)
+			etl.GET("/runs/:id/join-report", r.handlers.GetRunJoinReport)
+			etl.GET("/runs/:id/notifications/:channel", r.handlers.PreviewNotification)
+			etl.POST("/runs/:id/rollback", operatorKey, r.handlers.RollbackIngest)
+			etl.GET("/restatements", r.handlers.ListRestatements)
+			etl.GET("/checkpoints", r.handlers.ListCheckpoints)
+			etl.GET("/objects", r.handlers.ListSourceObjects)
+			etl.GET("/connectors", r.handlers.ListConnectors)
+			etl.GET("/stream", r.handlers.GetStreamStatus)
+		}
+
+		// Ingest event log
+		events := v1.Group("/events")
+		{
+			events.GET("", metricsKey, r.handlers.ListEvents)
+			events.GET("/snapshot", metricsKey, r.handlers.GetEventSnapshot)
 		}
 
 		// Metrics endpoints
-		metricsGroup := v1.Group("/metrics")
+		metricsGroup := v1.Group("/metrics", metricsKey)
 		{
-			metricsGroup.GET("/channel", r.handlers.GetMetricsByChannel)
-			metricsGroup.GET("/funnel", r.handlers.GetMetricsByFunnel)
+			metricsGroup.GET("/channel", shedLongRange, r.handlers.GetMetricsByChannel)
+			metricsGroup.GET("/funnel", shedLongRange, r.handlers.GetMetricsByFunnel)
 			metricsGroup.GET("/summary", r.handlers.GetMetricsSummary)
+			metricsGroup.GET("/dimensions/:name/values", r.handlers.GetDimensionValues)
+			metricsGroup.GET("/timeseries", shedTimeSeries, r.handlers.GetTimeSeries)
+			metricsGroup.GET("/catalog", r.handlers.GetMetricCatalog)
+			metricsGroup.GET("/revenue", r.handlers.GetRevenue)
+			metricsGroup.GET("/keywords", r.handlers.GetKeywordMetrics)
+			metricsGroup.GET("/models", r.handlers.ListModels)
+			metricsGroup.GET("/models/:name", r.handlers.GetModel)
 		}
 
+		// Pre-computed metrics written by other pipelines, outside the
+		// metrics group as producer keys are not reporting keys
+		v1.POST("/metrics/batch", middleware.APIKey(r.producerKeys, r.logger), r.handlers.WriteMetricsBatch)
+
 		// Export endpoints
-		export := v1.Group("/export")
+		export := v1.Group("/export", metricsKey)
 		{
 			export.POST("/run", r.handlers.ExportRun)
+			export.POST("/raw", r.handlers.ExportRaw)
+			export.POST("/models/:name", r.handlers.ExportModel)
+			export.POST("/verify", r.handlers.VerifyExportDestination)
+			export.GET("/deliveries", r.handlers.ListExportDeliveries)
+			export.GET("/deliveries/:id", r.handlers.GetExportDelivery)
+			export.GET("/approvals", r.handlers.ListExportHolds)
+		}
+		// Releasing a held export is an operator decision
+		v1.POST("/export/approvals/:id", middleware.APIKey(r.adminKeys, r.logger), r.handlers.ApproveExportHold)
+		// Sinks sign their acks instead of carrying an API key
+		v1.POST("/export/acks", r.handlers.AcknowledgeExport)
+
+		// Operations waiting for a second operator, decided under admin keys
+		approvals := v1.Group("/approvals", middleware.APIKey(r.adminKeys, r.logger))
+		{
+			approvals.GET("", r.handlers.ListApprovals)
+			approvals.GET("/:id", r.handlers.GetApproval)
+			approvals.POST("/:id/approve", r.handlers.ApproveRequest)
+			approvals.POST("/:id/reject", r.handlers.RejectRequest)
+		}
+
+		// Pipeline preset endpoints, changed under admin keys
+		pipelines := v1.Group("/pipelines")
+		{
+			pipelines.GET("", r.handlers.ListPipelines)
+			pipelines.POST("", middleware.APIKey(r.adminKeys, r.logger), r.handlers.CreatePipeline)
+			pipelines.GET("/:name", r.handlers.GetPipeline)
+			pipelines.PUT("/:name", middleware.APIKey(r.adminKeys, r.logger), r.handlers.UpdatePipeline)
+			pipelines.DELETE("/:name", middleware.APIKey(r.adminKeys, r.logger), r.handlers.DeletePipeline)
+			pipelines.GET("/:name/history", r.handlers.GetPipelineHistory)
+			pipelines.GET("/:name/schedule", r.handlers.GetPipelineSchedule)
+			pipelines.GET("/:name/runs", r.handlers.GetPipelineRuns)
+		}
+		v1.GET("/schedules", r.handlers.ListSchedules)
+
+		// Job queue endpoints
+		v1.GET("/jobs/queue", r.handlers.GetJobQueue)
+		// Background job liveness and incidents, for operators
+		v1.GET("/jobs/background", middleware.APIKey(r.adminKeys, r.logger), r.handlers.GetBackgroundJobs)
+		v1.GET("/jobs/incidents", middleware.APIKey(r.adminKeys, r.logger), r.handlers.GetJobIncidents)
+
+		// Quarantined rows, the dead letters of the pipeline
+		v1.GET("/quarantine", r.handlers.ListQuarantine)
+		deadletter := v1.Group("/deadletter")
+		{
+			deadletter.GET("", r.handlers.ListQuarantine)
+			deadletter.POST("/replay", middleware.APIKey(r.adminKeys, r.logger), r.handlers.ReplayQuarantine)
+		}
+
+		// Days without upstream data per source
+		v1.GET("/gaps", r.handlers.GetGaps)
+
+		// Daily FX rates for reporting in other currencies
+		fx := v1.Group("/fx")
+		{
+			fx.GET("/rates", r.handlers.ListFXRates)
+			fx.PUT("/rates", middleware.APIKey(r.adminKeys, r.logger), r.handlers.PutFXRates)
+		}
+
+		// Shadow configs and their diff reports
+		shadows := v1.Group("/shadows")
+		{
+			shadows.GET("", r.handlers.ListShadows)
+			shadows.PUT("/:label", middleware.APIKey(r.adminKeys, r.logger), r.handlers.SaveShadow)
+			shadows.DELETE("/:label", middleware.APIKey(r.adminKeys, r.logger), r.handlers.DeleteShadow)
+			shadows.GET("/:label/results", metricsKey, r.handlers.ListShadowResults)
+			shadows.GET("/:label/results/:id", metricsKey, r.handlers.GetShadowResult)
+			shadows.POST("/:label/results/:id/promote", middleware.APIKey(r.adminKeys, r.logger), r.handlers.PromoteShadowResult)
+		}
+
+		// Promoted metric datasets
+		datasets := v1.Group("/datasets")
+		{
+			datasets.GET("", r.handlers.ListDatasetPromotions)
+			datasets.POST("/demote", middleware.APIKey(r.adminKeys, r.logger), r.handlers.DemoteDataset)
+		}
+
+		// Flat reporting for BI connectors, authenticated with API keys
+		reporting := v1.Group("/reporting", middleware.APIKey(r.apiKeys, r.logger))
+		{
+			reporting.GET("/flat", shedLongRange, r.handlers.GetFlatReport)
+		}
+
+		// Read-only metrics views of share tokens, for embedding
+		shared := v1.Group("/shared", middleware.ShareToken(r.handlers.shareTokens.Verify, r.logger))
+		{
+			shared.GET("/metrics", shedShared, r.handlers.GetSharedMetrics)
+			shared.GET("/timeseries", shedSharedTimeSeries, r.handlers.GetSharedTimeSeries)
+		}
+		v1.POST("/admin/share-tokens", middleware.APIKey(r.adminKeys, r.logger), r.handlers.CreateShareToken)
+		v1.DELETE("/admin/share-tokens/:id", middleware.APIKey(r.adminKeys, r.logger), r.handlers.RevokeShareToken)
+
+		// Runtime configuration, authenticated with admin API keys
+		adminConfig := v1.Group("/admin/config", middleware.APIKey(r.adminKeys, r.logger))
+		{
+			adminConfig.GET("", r.handlers.GetAdminConfig)
+			adminConfig.PATCH("", r.handlers.PatchAdminConfig)
+			adminConfig.GET("/changes", r.handlers.ListConfigChanges)
+		}
+
+		// Suggested campaign actions
+		v1.GET("/actions", metricsKey, r.handlers.GetActions)
+
+		// Quota usage
+		v1.GET("/usage", tenantKey, r.handlers.GetUsage)
+
+		// Feature flags
+		flags := v1.Group("/flags")
+		{
+			flags.GET("", r.handlers.ListFeatureFlags)
+			flags.POST("/reload", middleware.APIKey(r.adminKeys, r.logger), r.handlers.ReloadFeatureFlags)
 		}
 	}
 
@@ -0,0 +1,163 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"etlgo/internal/domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+// the longest a request long-polls a job, under the 30s request timeout
+const maxLongPoll = 25 * time.Second
+
+// parses a long-poll wait, a duration or seconds of up to maxLongPoll. An
+// empty wait does not poll.
+func parseLongPoll(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, true
+	}
+	wait, err := time.ParseDuration(value)
+	if seconds, atoiErr := strconv.Atoi(value); atoiErr == nil {
+		wait, err = time.Duration(seconds)*time.Second, nil
+	}
+	if err != nil || wait <= 0 || wait > maxLongPoll {
+		return 0, false
+	}
+	return wait, true
+}
+
+// queues an ingest run, or finds the job of its run key, and answers 202
+// with the job to poll. With a long-poll wait the job is answered with 200
+// if it finishes in time.
+func (h *HTTPHandlers) submitIngestJob(c *gin.Context, ctx context.Context, requestID string, start time.Time, req domain.IngestJobRequest, wait time.Duration) {
+	job, created, err := h.ingestJobs.Submit(ctx, req)
+	if errors.Is(err, domain.ErrMaintenance) {
+		h.maintenanceError(c, "POST", "/ingest/run", requestID, start, err)
+		return
+	}
+	if errors.Is(err, domain.ErrPipelineNotFound) {
+		h.metrics.RecordHTTPRequest("POST", "/ingest/run", "404", time.Since(start))
+		c.JSON(http.StatusNotFound, errorBody(c, requestID, "pipeline_not_found", err.Error()))
+		return
+	}
+	if err != nil {
+		status, code := errorStatus(err, "ingestion_failed")
+		h.metrics.RecordHTTPRequest("POST", "/ingest/run", strconv.Itoa(status), time.Since(start))
+		if status >= http.StatusInternalServerError {
+			h.logger.WithContext(ctx).WithError(err).Error("Failed to queue ETL ingestion")
+		}
+		c.JSON(status, errorBody(c, requestID, code, err.Error()))
+		return
+	}
+
+	if wait > 0 && !job.Terminal {
+		if job, err = h.ingestJobs.Wait(ctx, job.ID, wait); err != nil {
+			h.metrics.RecordHTTPRequest("POST", "/ingest/run", "500", time.Since(start))
+			h.logger.WithContext(ctx).WithError(err).Error("Failed to get ingest job")
+			c.JSON(http.StatusInternalServerError, errorBody(c, requestID, "internal_error"))
+			return
+		}
+	}
+
+	message := "ETL ingestion queued"
+	if !created {
+		message = "ETL ingestion already submitted"
+	}
+	status := http.StatusAccepted
+	if job.Terminal {
+		message = "ETL ingestion " + job.State
+		status = http.StatusOK
+	}
+
+	statusURL := "/api/v1/ingest/jobs/" + job.ID
+	h.metrics.RecordHTTPRequest("POST", "/ingest/run", strconv.Itoa(status), time.Since(start))
+	c.Header("Location", statusURL)
+	c.JSON(status, gin.H{
+		"message":    message,
+		"job_id":     job.ID,
+		"status_url": statusURL,
+		"data":       job,
+		"request_id": requestID,
+	})
+}
+
+// GetIngestJob returns the state of an ingest job, long-polling it until it
+// finishes when the caller waits
+func (h *HTTPHandlers) GetIngestJob(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
+
+	wait, ok := parseLongPoll(c.Query("wait"))
+	if !ok {
+		h.metrics.RecordHTTPRequest("GET", "/ingest/jobs/:id", "400", time.Since(start))
+		c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_wait", maxLongPoll))
+		return
+	}
+
+	job, err := h.ingestJobs.Get(ctx, c.Param("id"))
+	if err == nil && wait > 0 && !job.Terminal {
+		job, err = h.ingestJobs.Wait(ctx, job.ID, wait)
+	}
+	if errors.Is(err, domain.ErrIngestJobNotFound) {
+		h.metrics.RecordHTTPRequest("GET", "/ingest/jobs/:id", "404", time.Since(start))
+		c.JSON(http.StatusNotFound, errorBody(c, requestID, "ingest_job_not_found", c.Param("id")))
+		return
+	}
+	if err != nil {
+		h.metrics.RecordHTTPRequest("GET", "/ingest/jobs/:id", "500", time.Since(start))
+		h.logger.WithContext(ctx).WithError(err).Error("Failed to get ingest job")
+		c.JSON(http.StatusInternalServerError, errorBody(c, requestID, "internal_error"))
+		return
+	}
+
+	h.metrics.RecordHTTPRequest("GET", "/ingest/jobs/:id", "200", time.Since(start))
+	c.JSON(http.StatusOK, gin.H{
+		"data":       job,
+		"request_id": requestID,
+	})
+}
+
+// ListIngestJobs returns the most recent ingest jobs
+func (h *HTTPHandlers) ListIngestJobs(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
+
+	limit := 100
+	if limitStr := c.Query("limit"); limitStr != "" {
+		parsed, err := strconv.Atoi(limitStr)
+		if err != nil || parsed <= 0 || parsed > 1000 {
+			h.metrics.RecordHTTPRequest("GET", "/ingest/jobs", "400", time.Since(start))
+			c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_limit", 1, 1000))
+			return
+		}
+		limit = parsed
+	}
+
+	jobs, err := h.ingestJobs.List(ctx, limit)
+	if err != nil {
+		h.metrics.RecordHTTPRequest("GET", "/ingest/jobs", "500", time.Since(start))
+		h.logger.WithContext(ctx).WithError(err).Error("Failed to list ingest jobs")
+		c.JSON(http.StatusInternalServerError, errorBody(c, requestID, "internal_error"))
+		return
+	}
+
+	h.metrics.RecordHTTPRequest("GET", "/ingest/jobs", "200", time.Since(start))
+	c.JSON(http.StatusOK, gin.H{
+		"data":       jobs,
+		"total":      len(jobs),
+		"request_id": requestID,
+	})
+}
@@ -0,0 +1,67 @@
+package delivery
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"etlgo/internal/domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetKeywordMetrics returns spend, clicks and CPC per keyword and match
+// type from the keyword feed, apart from the campaign metrics
+func (h *HTTPHandlers) GetKeywordMetrics(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
+	const endpoint = "/metrics/keywords"
+
+	from, to, err := h.parseDateRange(c)
+	if err != nil {
+		h.metrics.RecordHTTPRequest("GET", endpoint, "400", time.Since(start))
+		c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_parameters", err.Error()))
+		return
+	}
+
+	filter := domain.KeywordFilter{
+		From:       from,
+		To:         to,
+		Channel:    c.Query("channel"),
+		CampaignID: c.Query("campaign_id"),
+		AdGroupID:  c.Query("ad_group_id"),
+		MatchType:  domain.NormalizeMatchType(c.Query("match_type")),
+		Keyword:    c.Query("keyword"),
+	}
+	// keyword rows are ranked by their spend, so roles hiding cost get none
+	if err := h.metricsService.ColumnMask(ctx).Check("cost"); err != nil {
+		h.metrics.RecordHTTPRequest("GET", endpoint, "403", time.Since(start))
+		c.JSON(http.StatusForbidden, errorBody(c, requestID, "forbidden", err.Error()))
+		return
+	}
+
+	keywords, err := h.etlService.GetKeywordMetrics(ctx, filter)
+	if err != nil {
+		status, code := errorStatus(err, "metrics_retrieval_failed")
+		h.metrics.RecordHTTPRequest("GET", endpoint, strconv.Itoa(status), time.Since(start))
+		if status >= http.StatusInternalServerError {
+			c.JSON(status, errorBody(c, requestID, code))
+			return
+		}
+		c.JSON(status, errorBody(c, requestID, code, err.Error()))
+		return
+	}
+
+	h.metrics.RecordHTTPRequest("GET", endpoint, "200", time.Since(start))
+	c.JSON(http.StatusOK, gin.H{
+		"data":       keywords,
+		"total":      len(keywords),
+		"from":       from.Format("2006-01-02"),
+		"to":         to.Format("2006-01-02"),
+		"request_id": requestID,
+	})
+}
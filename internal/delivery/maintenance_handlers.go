@@ -0,0 +1,74 @@
+package delivery
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"etlgo/internal/domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+// request body for POST /admin/maintenance
+type maintenanceRequest struct {
+	Enabled           *bool  `json:"enabled" binding:"required"`
+	Reason            string `json:"reason"`
+	RetryAfterSeconds int    `json:"retry_after_seconds" binding:"min=0"`
+}
+
+// GetMaintenance returns the maintenance mode state
+func (h *HTTPHandlers) GetMaintenance(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+
+	h.metrics.RecordHTTPRequest("GET", "/admin/maintenance", "200", time.Since(start))
+	c.JSON(http.StatusOK, gin.H{
+		"maintenance": h.maintenanceService.Status(),
+		"request_id":  requestID,
+	})
+}
+
+// SetMaintenance turns maintenance mode on or off. While it is on new
+// ingest and export jobs are rejected with 503 and read queries keep working.
+func (h *HTTPHandlers) SetMaintenance(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
+
+	var req maintenanceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.metrics.RecordHTTPRequest("POST", "/admin/maintenance", "400", time.Since(start))
+		c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_request_body", err.Error()))
+		return
+	}
+
+	actor := operatorOf(c)
+	var status domain.MaintenanceStatus
+	if *req.Enabled {
+		status = h.maintenanceService.Enable(ctx, req.Reason, actor, time.Duration(req.RetryAfterSeconds)*time.Second)
+	} else {
+		status = h.maintenanceService.Disable(ctx, actor)
+	}
+
+	h.metrics.RecordHTTPRequest("POST", "/admin/maintenance", "200", time.Since(start))
+	c.JSON(http.StatusOK, gin.H{
+		"maintenance": status,
+		"request_id":  requestID,
+	})
+}
+
+// maintenanceError rejects a job submitted during maintenance
+func (h *HTTPHandlers) maintenanceError(c *gin.Context, method, endpoint, requestID string, start time.Time, err error) {
+	retryAfter := int(h.maintenanceService.RetryAfter().Seconds())
+
+	h.metrics.RecordHTTPRequest(method, endpoint, "503", time.Since(start))
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	c.JSON(http.StatusServiceUnavailable, errorBody(c, requestID, "maintenance_mode", err.Error()))
+}
@@ -0,0 +1,69 @@
+package delivery
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"etlgo/internal/domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WriteMetricsBatch stores pre-computed metrics written by another pipeline,
+// tagged with the name of the producer key it authenticated with
+func (h *HTTPHandlers) WriteMetricsBatch(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
+	log := h.logger.WithContext(ctx)
+
+	if h.maintenanceService.Status().Enabled {
+		h.maintenanceError(c, "POST", "/metrics/batch", requestID, start, domain.ErrMaintenance)
+		return
+	}
+
+	var batch domain.MetricsBatch
+	if err := c.ShouldBindJSON(&batch); err != nil {
+		h.metrics.RecordHTTPRequest("POST", "/metrics/batch", "400", time.Since(start))
+		c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_request_body", err.Error()))
+		return
+	}
+	if len(batch.Metrics) == 0 {
+		h.metrics.RecordHTTPRequest("POST", "/metrics/batch", "400", time.Since(start))
+		c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_request_body", "metrics must not be empty"))
+		return
+	}
+
+	producer := c.GetString("api_key")
+	result, err := h.metricsService.WriteMetricsBatch(ctx, producer, batch)
+	if errors.Is(err, domain.ErrPushBatchTooLarge) {
+		h.metrics.RecordHTTPRequest("POST", "/metrics/batch", "413", time.Since(start))
+		c.JSON(http.StatusRequestEntityTooLarge, errorBody(c, requestID, "push_batch_too_large", err.Error()))
+		return
+	}
+	if err != nil {
+		status, code := errorStatus(err, "metrics_write_failed")
+		h.metrics.RecordHTTPRequest("POST", "/metrics/batch", strconv.Itoa(status), time.Since(start))
+		if status >= http.StatusInternalServerError {
+			log.WithError(err).Error("Metrics batch write failed")
+		}
+		body := errorBody(c, requestID, code, err.Error())
+		if result != nil {
+			body["errors"] = result.Errors
+		}
+		c.JSON(status, body)
+		return
+	}
+
+	h.metrics.RecordHTTPRequest("POST", "/metrics/batch", "200", time.Since(start))
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Metrics batch written",
+		"data":       result,
+		"request_id": requestID,
+	})
+}
@@ -0,0 +1,220 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"etlgo/internal/domain"
+	"etlgo/pkg/i18n"
+	"etlgo/pkg/logger"
+	"etlgo/pkg/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// reasons a request is shed
+const (
+	shedInFlight = "in_flight"
+	shedLatency  = "latency"
+)
+
+// latency samples kept, and the fewest judged by, so a lone slow request
+// after a quiet spell does not shed load
+const (
+	latencySamples    = 1024
+	minLatencySamples = 20
+)
+
+// thresholds of the load shedder. Expensive requests are rejected while
+// more than MaxInFlight requests are in flight, or while the p99 latency of
+// the requests started within Window exceeds MaxP99. Zero disables a
+// threshold. Rejections tell clients to retry after RetryAfter. Queries
+// over more than LongRange are expensive unless aggregated.
+type LoadShedOptions struct {
+	MaxInFlight int
+	MaxP99      time.Duration
+	Window      time.Duration
+	RetryAfter  time.Duration
+	LongRange   time.Duration
+}
+
+// protects the service under dashboard stampedes: Track counts every
+// request in flight and samples its latency, and Shed turns away the
+// expensive ones with 503 while either is over its threshold. Cheap
+// requests are always admitted, so health checks and dashboards keep
+// working.
+type LoadShedder struct {
+	opts     LoadShedOptions
+	inFlight atomic.Int64
+
+	mu      sync.Mutex
+	samples []latencySample // ring of the latest samples
+	next    int
+	p99     time.Duration
+	p99At   time.Time // when p99 was last computed
+
+	logger  *logger.Logger
+	metrics *metrics.Metrics
+}
+
+type latencySample struct {
+	at      time.Time
+	latency time.Duration
+}
+
+func NewLoadShedder(opts LoadShedOptions, log *logger.Logger, m *metrics.Metrics) *LoadShedder {
+	if opts.Window <= 0 {
+		opts.Window = 30 * time.Second
+	}
+	if opts.RetryAfter <= 0 {
+		opts.RetryAfter = 5 * time.Second
+	}
+	if opts.LongRange <= 0 {
+		opts.LongRange = 90 * 24 * time.Hour
+	}
+	return &LoadShedder{opts: opts, samples: make([]latencySample, 0, latencySamples), logger: log, metrics: m}
+}
+
+// InFlight returns the requests in flight, which drain to zero on shutdown
+func (s *LoadShedder) InFlight() int64 {
+	return s.inFlight.Load()
+}
+
+// Track counts the request in flight and samples its latency once it
+// completes. Long polls (the wait parameter) and shed requests are not
+// sampled, as their latency says nothing of the service's load.
+func (s *LoadShedder) Track() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		s.metrics.SetServerInFlight(s.inFlight.Add(1))
+		defer func() {
+			s.metrics.SetServerInFlight(s.inFlight.Add(-1))
+		}()
+
+		c.Next()
+
+		if c.Query("wait") != "" || c.GetBool("load_shed") {
+			return
+		}
+		s.observe(start, time.Since(start))
+	}
+}
+
+// Shed rejects requests of class that expensive reports as expensive with
+// 503 while the service is overloaded. A nil expensive treats every request
+// of the route as expensive.
+func (s *LoadShedder) Shed(class string, expensive func(*gin.Context) bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if s.opts.MaxInFlight <= 0 && s.opts.MaxP99 <= 0 {
+			c.Next()
+			return
+		}
+		if expensive != nil && !expensive(c) {
+			c.Next()
+			return
+		}
+
+		reason := s.overloaded()
+		if reason == "" {
+			c.Next()
+			return
+		}
+
+		s.metrics.RecordLoadShed(class, reason)
+		s.logger.WithContext(c.Request.Context()).WithFields(map[string]any{
+			"class":  class,
+			"reason": reason,
+			"path":   c.Request.URL.Path,
+		}).Warn("Request shed under load")
+
+		lang := c.GetString("language")
+		c.Set("load_shed", true)
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(s.opts.RetryAfter.Seconds()))))
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{
+			"code":       "service_overloaded",
+			"error":      i18n.Error(lang, "service_overloaded"),
+			"message":    i18n.Message(lang, "service_overloaded", s.opts.RetryAfter),
+			"reason":     reason,
+			"request_id": c.GetString("request_id"),
+		})
+	}
+}
+
+// returns why the service is overloaded, or "" when it is not
+func (s *LoadShedder) overloaded() string {
+	if s.opts.MaxInFlight > 0 && s.inFlight.Load() > int64(s.opts.MaxInFlight) {
+		return shedInFlight
+	}
+	if s.opts.MaxP99 > 0 && s.latencyP99(time.Now()) > s.opts.MaxP99 {
+		return shedLatency
+	}
+	return ""
+}
+
+func (s *LoadShedder) observe(at time.Time, latency time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sample := latencySample{at: at, latency: latency}
+	if len(s.samples) < latencySamples {
+		s.samples = append(s.samples, sample)
+		return
+	}
+	s.samples[s.next] = sample
+	s.next = (s.next + 1) % latencySamples
+}
+
+// returns the p99 latency of the requests started within the window,
+// recomputed at most once a second. Too few samples count as no latency.
+func (s *LoadShedder) latencyP99(now time.Time) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.p99At) < time.Second {
+		return s.p99
+	}
+
+	cutoff := now.Add(-s.opts.Window)
+	latencies := make([]time.Duration, 0, len(s.samples))
+	for _, sample := range s.samples {
+		if sample.at.After(cutoff) {
+			latencies = append(latencies, sample.latency)
+		}
+	}
+
+	s.p99 = 0
+	if len(latencies) >= minLatencySamples {
+		slices.Sort(latencies)
+		s.p99 = latencies[int(math.Ceil(float64(len(latencies))*0.99))-1]
+	}
+	s.p99At = now
+	s.metrics.SetLatencyP99(s.p99)
+	return s.p99
+}
+
+// LongRange reports requests whose date range, as window parses it from
+// the request, spans more than the long range as expensive. Requests window
+// cannot parse are left to the handler to reject.
+func (s *LoadShedder) LongRange(window func(*gin.Context) (from, to time.Time, err error)) func(*gin.Context) bool {
+	return func(c *gin.Context) bool {
+		from, to, err := window(c)
+		return err == nil && to.Sub(from) > s.opts.LongRange
+	}
+}
+
+// UnaggregatedLongRange reports time series over more than the long range
+// as expensive unless they are aggregated to weeks or months
+func (s *LoadShedder) UnaggregatedLongRange(window func(*gin.Context) (from, to time.Time, err error)) func(*gin.Context) bool {
+	longRange := s.LongRange(window)
+	return func(c *gin.Context) bool {
+		if interval := c.DefaultQuery("interval", domain.IntervalDay); interval != domain.IntervalDay {
+			return false
+		}
+		return longRange(c)
+	}
+}
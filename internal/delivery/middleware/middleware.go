@@ -2,22 +2,38 @@ package middleware
 
 import (
 	"context"
+	"errors"
+	"etlgo/internal/domain"
+	"etlgo/pkg/i18n"
 	"etlgo/pkg/logger"
 	"etlgo/pkg/metrics"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
-// RequestID adds a unique request ID to each request
-func RequestID() gin.HandlerFunc {
+// longest X-Request-ID accepted from clients
+const maxRequestIDLength = 128
+
+// RequestID gives each request an ID, echoed in the X-Request-ID header,
+// set on the gin context as request_id and carried by the request context
+// into every log line. Clients and proxies may supply the ID as
+// X-Request-ID, or as the trace ID of a W3C traceparent header, so the
+// request can be followed across services; IDs that are malformed or could
+// forge log lines are replaced with a generated one.
+func RequestID(ids domain.IDGenerator) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		requestID := c.GetHeader("X-Request-ID")
+		if !validRequestID(requestID) {
+			requestID = traceID(c.GetHeader("traceparent"))
+		}
 		if requestID == "" {
-			requestID = uuid.New().String()
+			requestID = ids.NewID()
 		}
 
 		c.Header("X-Request-ID", requestID)
@@ -31,7 +47,201 @@ func RequestID() gin.HandlerFunc {
 	}
 }
 
-// Structured logging middleware
+// reports whether a client-supplied request ID is safe to log and echo:
+// letters, digits and . _ : - only, up to maxRequestIDLength of them
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '.', r == '_', r == ':', r == '-':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// returns the trace ID of a W3C traceparent header, version-traceid-
+// parentid-flags, or "" when the header is missing or malformed
+func traceID(traceparent string) string {
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return ""
+	}
+	if parts[0] == "00" && len(parts) != 4 {
+		return ""
+	}
+	id := strings.ToLower(parts[1])
+	// trace IDs are hex and never all zeros
+	if strings.Trim(id, "0") == "" || strings.Trim(id, "0123456789abcdef") != "" {
+		return ""
+	}
+	return id
+}
+
+// Tenant attaches the tenant named in the X-Tenant-ID header to the request
+// context so feature flags can be evaluated per tenant. An API key bound to
+// a tenant replaces it with its own; quotas only ever follow the key.
+func Tenant() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if tenant := c.GetHeader("X-Tenant-ID"); tenant != "" {
+			c.Request = c.Request.WithContext(domain.WithTenant(c.Request.Context(), tenant))
+		}
+
+		c.Next()
+	}
+}
+
+// Language negotiates the response language from the Accept-Language header
+func Language() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		lang := i18n.Negotiate(c.GetHeader("Accept-Language"))
+
+		c.Set("language", lang)
+		c.Header("Content-Language", lang)
+		c.Writer.Header().Add("Vary", "Accept-Language")
+
+		c.Next()
+	}
+}
+
+// APIKey admits requests carrying one of the keys as a bearer token, in the
+// X-API-Key header or in the api_key query parameter, for connectors that
+// can only set query parameters. Without keys every request is refused.
+// Metrics queries of the request are restricted to the key's scope. Keyed
+// responses are neither cached nor referred to, and the access log redacts
+// the query parameter.
+func APIKey(keys domain.APIKeys, log *logger.Logger) gin.HandlerFunc {
+	return apiKey(keys, true, log)
+}
+
+// OptionalAPIKey admits requests without a key unrestricted, and those with
+// one like APIKey
+func OptionalAPIKey(keys domain.APIKeys, log *logger.Logger) gin.HandlerFunc {
+	return apiKey(keys, false, log)
+}
+
+func apiKey(keys domain.APIKeys, required bool, log *logger.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		secret := c.GetHeader("X-API-Key")
+		if bearer, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); ok {
+			secret = bearer
+		}
+		if secret == "" {
+			secret = c.Query("api_key")
+		}
+		if secret == "" && !required {
+			c.Next()
+			return
+		}
+		private(c)
+
+		key, ok := keys.Match(secret)
+		if secret == "" || !ok {
+			lang := c.GetString("language")
+			c.Header("WWW-Authenticate", "Bearer")
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"code":       "invalid_api_key",
+				"error":      i18n.Error(lang, "invalid_api_key"),
+				"message":    i18n.Message(lang, "invalid_api_key"),
+				"request_id": c.GetString("request_id"),
+			})
+			return
+		}
+
+		c.Set("api_key", key.Name)
+		ctx := domain.WithAPIKey(c.Request.Context(), *key)
+		if key.Tenant != "" {
+			ctx = domain.WithTenant(ctx, key.Tenant)
+		}
+		c.Request = c.Request.WithContext(ctx)
+		log.WithContext(c.Request.Context()).WithField("api_key", key.Name).Debug("API key accepted")
+		c.Next()
+	}
+}
+
+// ShareToken admits requests carrying a share token verify accepts, as a
+// bearer token or in the token query parameter for embedded views. Metrics
+// queries of the request are restricted to the token's view. Responses are
+// private like keyed ones and the access log redacts the query parameter.
+func ShareToken(verify func(ctx context.Context, signed string) (*domain.ShareToken, error), log *logger.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		private(c)
+		signed := c.Query("token")
+		if bearer, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); ok {
+			signed = bearer
+		}
+
+		token, err := verify(c.Request.Context(), signed)
+		if err != nil {
+			code := "invalid_share_token"
+			switch {
+			case errors.Is(err, domain.ErrShareTokensNotConfigured):
+				code = "share_tokens_not_configured"
+			case errors.Is(err, domain.ErrShareTokenExpired):
+				code = "share_token_expired"
+			case errors.Is(err, domain.ErrShareTokenRevoked):
+				code = "share_token_revoked"
+			}
+			lang := c.GetString("language")
+			c.Header("WWW-Authenticate", "Bearer")
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"code":       code,
+				"error":      i18n.Error(lang, code),
+				"message":    i18n.Message(lang, code),
+				"request_id": c.GetString("request_id"),
+			})
+			return
+		}
+
+		c.Set("share_token", token.ID)
+		ctx := domain.WithAPIKey(c.Request.Context(), token.APIKey())
+		c.Request = c.Request.WithContext(domain.WithShareToken(ctx, *token))
+		log.WithContext(ctx).WithField("share_token", token.ID).Debug("Share token accepted")
+		c.Next()
+	}
+}
+
+// marks the response as private to the credentials of the request: never
+// stored by caches, and its URL, which may carry them, never sent as the
+// referrer of links followed from it
+func private(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("Referrer-Policy", "no-referrer")
+}
+
+// query parameters carrying credentials, whose values the access log
+// redacts
+var credentialParams = []string{"api_key", "token"}
+
+// returns the path with the values of credential query parameters
+// redacted. A query that cannot be parsed is left out.
+func redactPath(path string) string {
+	base, rawQuery, ok := strings.Cut(path, "?")
+	if !ok {
+		return path
+	}
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return base
+	}
+	redacted := false
+	for _, param := range credentialParams {
+		if query.Has(param) {
+			query.Set(param, "REDACTED")
+			redacted = true
+		}
+	}
+	if !redacted {
+		return path
+	}
+	return base + "?" + query.Encode()
+}
+
+// Structured logging middleware. Credentials in the query are redacted.
 func Logger(log *logger.Logger) gin.HandlerFunc {
 	return gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
 		log.WithFields(map[string]any{
@@ -40,7 +250,7 @@ func Logger(log *logger.Logger) gin.HandlerFunc {
 			"latency":    param.Latency,
 			"client_ip":  param.ClientIP,
 			"method":     param.Method,
-			"path":       param.Path,
+			"path":       redactPath(param.Path),
 			"user_agent": param.Request.UserAgent(),
 			"error":      param.ErrorMessage,
 		}).Info("HTTP Request")
@@ -61,8 +271,11 @@ func Recovery(log *logger.Logger) gin.HandlerFunc {
 			"method":     c.Request.Method,
 		}).Error("Panic recovered")
 
+		lang := c.GetString("language")
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":      "Internal server error",
+			"code":       "internal_error",
+			"error":      i18n.Error(lang, "internal_error"),
+			"message":    i18n.Message(lang, "internal_error"),
 			"request_id": requestID,
 		})
 	})
@@ -89,8 +302,11 @@ func Timeout(timeout time.Duration) gin.HandlerFunc {
 			// Request completed
 		case <-ctx.Done():
 			// Request timed out
+			lang := c.GetString("language")
 			c.JSON(http.StatusRequestTimeout, gin.H{
-				"error":      "Request timeout",
+				"code":       "request_timeout",
+				"error":      i18n.Error(lang, "request_timeout"),
+				"message":    i18n.Message(lang, "request_timeout", timeout),
 				"request_id": c.GetString("request_id"),
 			})
 			c.Abort()
@@ -106,6 +322,12 @@ func Metrics(m *metrics.Metrics) gin.HandlerFunc {
 
 		status := http.StatusText(c.Writer.Status())
 		m.RecordHTTPRequest(c.Request.Method, c.FullPath(), status, time.Since(start))
+
+		tenant := domain.TenantFromContext(c.Request.Context())
+		if tenant == "" {
+			tenant = domain.DefaultTenant
+		}
+		m.RecordTenantHTTPRequest(tenant, strconv.Itoa(c.Writer.Status()))
 	}
 }
 
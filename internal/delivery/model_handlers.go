@@ -0,0 +1,108 @@
+package delivery
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"etlgo/internal/domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListModels returns the configured derived models with their latest
+// materialization
+func (h *HTTPHandlers) ListModels(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
+
+	models, err := h.modelService.ListModels(ctx)
+	if err != nil {
+		h.metrics.RecordHTTPRequest("GET", "/metrics/models", "500", time.Since(start))
+		h.logger.WithContext(ctx).WithError(err).Error("Failed to list derived models")
+		c.JSON(http.StatusInternalServerError, errorBody(c, requestID, "internal_error"))
+		return
+	}
+
+	h.metrics.RecordHTTPRequest("GET", "/metrics/models", "200", time.Since(start))
+	c.JSON(http.StatusOK, gin.H{
+		"data":       models,
+		"total":      len(models),
+		"request_id": requestID,
+	})
+}
+
+// GetModel returns the latest rows of a derived model in the caller's scope
+func (h *HTTPHandlers) GetModel(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
+
+	result, err := h.modelService.GetModel(ctx, c.Param("name"))
+	if err != nil {
+		status, code := errorStatus(err, "metrics_retrieval_failed")
+		h.metrics.RecordHTTPRequest("GET", "/metrics/models/:name", strconv.Itoa(status), time.Since(start))
+		if status >= http.StatusInternalServerError {
+			h.logger.WithContext(ctx).WithError(err).Error("Failed to get derived model")
+			c.JSON(status, errorBody(c, requestID, code))
+			return
+		}
+		c.JSON(status, errorBody(c, requestID, code, err.Error()))
+		return
+	}
+
+	h.metrics.RecordHTTPRequest("GET", "/metrics/models/:name", "200", time.Since(start))
+	c.JSON(http.StatusOK, gin.H{
+		"data":       result,
+		"total":      len(result.Rows),
+		"request_id": requestID,
+	})
+}
+
+// ExportModel delivers the latest rows of a derived model to the sink or a
+// configured destination
+func (h *HTTPHandlers) ExportModel(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
+
+	result, err := h.modelService.ExportModel(ctx, c.Param("name"), c.Query("destination"))
+	if errors.Is(err, domain.ErrSinkNotConfigured) {
+		h.metrics.RecordHTTPRequest("POST", "/export/models/:name", "400", time.Since(start))
+		c.JSON(http.StatusBadRequest, errorBody(c, requestID, "sink_not_configured"))
+		return
+	}
+	if err != nil {
+		status, code := errorStatus(err, "export_failed")
+		h.metrics.RecordHTTPRequest("POST", "/export/models/:name", strconv.Itoa(status), time.Since(start))
+		if status >= http.StatusInternalServerError {
+			h.logger.WithContext(ctx).WithError(err).Error("Failed to export derived model")
+		}
+		c.JSON(status, errorBody(c, requestID, code, err.Error()))
+		return
+	}
+
+	h.metrics.RecordHTTPRequest("POST", "/export/models/:name", "200", time.Since(start))
+	response := gin.H{
+		"message":         "Export completed successfully",
+		"model":           result.Model,
+		"records":         len(result.Rows),
+		"materialized_at": result.MaterializedAt,
+		"request_id":      requestID,
+	}
+	if destination := c.Query("destination"); destination != "" {
+		response["destination"] = destination
+	}
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,290 @@
+package delivery
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"etlgo/internal/domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListPipelines returns all pipeline presets
+func (h *HTTPHandlers) ListPipelines(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
+
+	pipelines, err := h.pipelineService.ListPipelines(ctx)
+	if err != nil {
+		h.pipelineError(c, "GET", "/pipelines", requestID, start, err)
+		return
+	}
+
+	h.metrics.RecordHTTPRequest("GET", "/pipelines", "200", time.Since(start))
+	c.JSON(http.StatusOK, gin.H{
+		"data":       pipelines,
+		"total":      len(pipelines),
+		"request_id": requestID,
+	})
+}
+
+// GetPipeline returns a single pipeline preset
+func (h *HTTPHandlers) GetPipeline(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
+
+	pipeline, err := h.pipelineService.GetPipeline(ctx, c.Param("name"))
+	if err != nil {
+		h.pipelineError(c, "GET", "/pipelines/:name", requestID, start, err)
+		return
+	}
+
+	h.metrics.RecordHTTPRequest("GET", "/pipelines/:name", "200", time.Since(start))
+	c.JSON(http.StatusOK, gin.H{
+		"data":       pipeline,
+		"request_id": requestID,
+	})
+}
+
+// CreatePipeline stores a new pipeline preset
+func (h *HTTPHandlers) CreatePipeline(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
+
+	var pipeline domain.Pipeline
+	if err := c.ShouldBindJSON(&pipeline); err != nil {
+		h.metrics.RecordHTTPRequest("POST", "/pipelines", "400", time.Since(start))
+		c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_request_body", err.Error()))
+		return
+	}
+
+	created, err := h.pipelineService.CreatePipeline(ctx, pipeline, operatorOf(c))
+	if err != nil {
+		h.pipelineError(c, "POST", "/pipelines", requestID, start, err)
+		return
+	}
+
+	h.metrics.RecordHTTPRequest("POST", "/pipelines", "201", time.Since(start))
+	c.JSON(http.StatusCreated, gin.H{
+		"data":       created,
+		"request_id": requestID,
+	})
+}
+
+// UpdatePipeline replaces an existing pipeline preset
+func (h *HTTPHandlers) UpdatePipeline(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
+
+	var pipeline domain.Pipeline
+	if err := c.ShouldBindJSON(&pipeline); err != nil {
+		h.metrics.RecordHTTPRequest("PUT", "/pipelines/:name", "400", time.Since(start))
+		c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_request_body", err.Error()))
+		return
+	}
+	pipeline.Name = c.Param("name")
+
+	updated, err := h.pipelineService.UpdatePipeline(ctx, pipeline, operatorOf(c))
+	if err != nil {
+		h.pipelineError(c, "PUT", "/pipelines/:name", requestID, start, err)
+		return
+	}
+
+	h.metrics.RecordHTTPRequest("PUT", "/pipelines/:name", "200", time.Since(start))
+	c.JSON(http.StatusOK, gin.H{
+		"data":       updated,
+		"request_id": requestID,
+	})
+}
+
+// DeletePipeline removes a pipeline preset
+func (h *HTTPHandlers) DeletePipeline(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
+
+	if err := h.pipelineService.DeletePipeline(ctx, c.Param("name"), operatorOf(c)); err != nil {
+		h.pipelineError(c, "DELETE", "/pipelines/:name", requestID, start, err)
+		return
+	}
+
+	h.metrics.RecordHTTPRequest("DELETE", "/pipelines/:name", "200", time.Since(start))
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Pipeline deleted",
+		"request_id": requestID,
+	})
+}
+
+// GetPipelineHistory returns the audit trail of a pipeline preset
+func (h *HTTPHandlers) GetPipelineHistory(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
+
+	history, err := h.pipelineService.GetPipelineHistory(ctx, c.Param("name"))
+	if err != nil {
+		h.pipelineError(c, "GET", "/pipelines/:name/history", requestID, start, err)
+		return
+	}
+
+	h.metrics.RecordHTTPRequest("GET", "/pipelines/:name/history", "200", time.Since(start))
+	c.JSON(http.StatusOK, gin.H{
+		"data":       history,
+		"total":      len(history),
+		"request_id": requestID,
+	})
+}
+
+// GetPipelineSchedule previews a pipeline's upcoming scheduled runs,
+// including the ones its calendar skips
+func (h *HTTPHandlers) GetPipelineSchedule(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
+
+	limit := 10
+	if limitStr := c.Query("limit"); limitStr != "" {
+		parsed, err := strconv.Atoi(limitStr)
+		if err != nil || parsed <= 0 || parsed > 100 {
+			h.metrics.RecordHTTPRequest("GET", "/pipelines/:name/schedule", "400", time.Since(start))
+			c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_limit", 1, 100))
+			return
+		}
+		limit = parsed
+	}
+
+	pipeline, runs, err := h.pipelineService.UpcomingRuns(ctx, c.Param("name"), h.clock.Now(), limit)
+	if err != nil {
+		h.pipelineError(c, "GET", "/pipelines/:name/schedule", requestID, start, err)
+		return
+	}
+
+	h.metrics.RecordHTTPRequest("GET", "/pipelines/:name/schedule", "200", time.Since(start))
+	c.JSON(http.StatusOK, gin.H{
+		"pipeline":           pipeline.Name,
+		"schedule":           pipeline.Schedule,
+		"timezone":           h.pipelineService.Location().String(),
+		"business_days_only": pipeline.BusinessDaysOnly,
+		"holiday_calendar":   pipeline.HolidayCalendar,
+		"data":               runs,
+		"request_id":         requestID,
+	})
+}
+
+// GetPipelineRuns returns the history of a pipeline's scheduled runs
+func (h *HTTPHandlers) GetPipelineRuns(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
+
+	limit := 100
+	if limitStr := c.Query("limit"); limitStr != "" {
+		parsed, err := strconv.Atoi(limitStr)
+		if err != nil || parsed <= 0 || parsed > 1000 {
+			h.metrics.RecordHTTPRequest("GET", "/pipelines/:name/runs", "400", time.Since(start))
+			c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_limit", 1, 1000))
+			return
+		}
+		limit = parsed
+	}
+
+	runs, err := h.scheduler.History(ctx, c.Param("name"), limit)
+	if err != nil {
+		h.pipelineError(c, "GET", "/pipelines/:name/runs", requestID, start, err)
+		return
+	}
+
+	h.metrics.RecordHTTPRequest("GET", "/pipelines/:name/runs", "200", time.Since(start))
+	c.JSON(http.StatusOK, gin.H{
+		"data":       runs,
+		"total":      len(runs),
+		"request_id": requestID,
+	})
+}
+
+// ListSchedules returns the scheduled pipelines with their next and last
+// runs
+func (h *HTTPHandlers) ListSchedules(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
+
+	schedules, err := h.scheduler.Schedules(ctx, h.clock.Now())
+	if err != nil {
+		h.pipelineError(c, "GET", "/schedules", requestID, start, err)
+		return
+	}
+
+	h.metrics.RecordHTTPRequest("GET", "/schedules", "200", time.Since(start))
+	c.JSON(http.StatusOK, gin.H{
+		"active":     h.scheduler.Active(),
+		"timezone":   h.pipelineService.Location().String(),
+		"data":       schedules,
+		"total":      len(schedules),
+		"request_id": requestID,
+	})
+}
+
+// pipelineError maps pipeline errors to HTTP responses
+func (h *HTTPHandlers) pipelineError(c *gin.Context, method, endpoint, requestID string, start time.Time, err error) {
+	status := http.StatusInternalServerError
+	code := "pipeline_operation_failed"
+
+	switch {
+	case errors.Is(err, domain.ErrPipelineNotFound):
+		status, code = http.StatusNotFound, "pipeline_not_found"
+	case errors.Is(err, domain.ErrPipelineExists):
+		status, code = http.StatusConflict, "pipeline_exists"
+	case errors.Is(err, domain.ErrInvalidPipeline):
+		status, code = http.StatusBadRequest, "invalid_pipeline"
+	default:
+		status, code = errorStatus(err, code)
+	}
+	if status >= http.StatusInternalServerError {
+		h.logger.WithContext(c.Request.Context()).WithError(err).Error("Pipeline operation failed")
+	}
+
+	h.metrics.RecordHTTPRequest(method, endpoint, strconv.Itoa(status), time.Since(start))
+	c.JSON(status, errorBody(c, requestID, code, err.Error()))
+}
+
+// requestActor identifies the caller for audit records
+func requestActor(c *gin.Context) string {
+	if user := c.GetHeader("X-User"); user != "" {
+		return user
+	}
+	return "anonymous"
+}
@@ -0,0 +1,76 @@
+package delivery
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"etlgo/internal/domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+// IngestPush applies records pushed by a source and updates the affected
+// metrics immediately
+func (h *HTTPHandlers) IngestPush(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
+	log := h.logger.WithContext(ctx)
+
+	if h.maintenanceService.Status().Enabled {
+		h.maintenanceError(c, "POST", "/ingest/push", requestID, start, domain.ErrMaintenance)
+		return
+	}
+
+	var batch domain.PushBatch
+	if err := c.ShouldBindJSON(&batch); err != nil {
+		h.metrics.RecordHTTPRequest("POST", "/ingest/push", "400", time.Since(start))
+		c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_request_body", err.Error()))
+		return
+	}
+	if batch.Len() == 0 {
+		h.metrics.RecordHTTPRequest("POST", "/ingest/push", "400", time.Since(start))
+		c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_request_body", "ads or opportunities must not be empty"))
+		return
+	}
+
+	summary, err := h.etlService.IngestPush(ctx, batch)
+	if errors.Is(err, domain.ErrQuotaExceeded) {
+		h.quotaError(c, "POST", "/ingest/push", requestID, start, err)
+		return
+	}
+	if errors.Is(err, domain.ErrPushBatchTooLarge) {
+		h.metrics.RecordHTTPRequest("POST", "/ingest/push", "413", time.Since(start))
+		c.JSON(http.StatusRequestEntityTooLarge, errorBody(c, requestID, "push_batch_too_large", err.Error()))
+		return
+	}
+	if errors.Is(err, domain.ErrParseThreshold) {
+		h.metrics.RecordHTTPRequest("POST", "/ingest/push", "422", time.Since(start))
+		log.WithError(err).Warn("Pushed records rejected by parse policy")
+		body := errorBody(c, requestID, "parse_policy_violated", err.Error())
+		body["summary"] = summary
+		c.JSON(http.StatusUnprocessableEntity, body)
+		return
+	}
+	if err != nil {
+		status, code := errorStatus(err, "ingestion_failed")
+		h.metrics.RecordHTTPRequest("POST", "/ingest/push", strconv.Itoa(status), time.Since(start))
+		if status >= http.StatusInternalServerError {
+			log.WithError(err).Error("Push ingestion failed")
+		}
+		c.JSON(status, errorBody(c, requestID, code, err.Error()))
+		return
+	}
+
+	h.metrics.RecordHTTPRequest("POST", "/ingest/push", "200", time.Since(start))
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Pushed records applied",
+		"summary":    summary,
+		"request_id": requestID,
+	})
+}
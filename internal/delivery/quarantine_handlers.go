@@ -0,0 +1,125 @@
+package delivery
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"etlgo/internal/domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListQuarantine returns rows rejected during parsing. It serves both
+// /quarantine and /deadletter.
+func (h *HTTPHandlers) ListQuarantine(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
+	path := strings.TrimPrefix(c.FullPath(), "/api/v1")
+
+	source := c.Query("source")
+	if source != "" && !domain.IsSource(source) {
+		h.metrics.RecordHTTPRequest("GET", path, "400", time.Since(start))
+		c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_source", "ads, crm, ga4, keywords"))
+		return
+	}
+
+	limit := 100
+	if limitStr := c.Query("limit"); limitStr != "" {
+		parsed, err := strconv.Atoi(limitStr)
+		if err != nil || parsed <= 0 || parsed > 1000 {
+			h.metrics.RecordHTTPRequest("GET", path, "400", time.Since(start))
+			c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_limit", 1, 1000))
+			return
+		}
+		limit = parsed
+	}
+
+	records, err := h.etlService.ListQuarantined(ctx, source, limit)
+	if err != nil {
+		h.metrics.RecordHTTPRequest("GET", path, "500", time.Since(start))
+		h.logger.WithContext(ctx).WithError(err).Error("Failed to list quarantined rows")
+		c.JSON(http.StatusInternalServerError, errorBody(c, requestID, "quarantine_list_failed"))
+		return
+	}
+
+	h.metrics.RecordHTTPRequest("GET", path, "200", time.Since(start))
+	c.JSON(http.StatusOK, gin.H{
+		"data":       records,
+		"total":      len(records),
+		"request_id": requestID,
+	})
+}
+
+// ReplayQuarantine reprocesses quarantined ads and CRM rows, optionally with
+// corrected payloads
+func (h *HTTPHandlers) ReplayQuarantine(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
+	log := h.logger.WithContext(ctx)
+
+	if h.maintenanceService.Status().Enabled {
+		h.maintenanceError(c, "POST", "/deadletter/replay", requestID, start, domain.ErrMaintenance)
+		return
+	}
+
+	var req domain.ReplayRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			h.metrics.RecordHTTPRequest("POST", "/deadletter/replay", "400", time.Since(start))
+			c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_request_body", err.Error()))
+			return
+		}
+	}
+	if req.Limit < 0 || req.Limit > 1000 {
+		h.metrics.RecordHTTPRequest("POST", "/deadletter/replay", "400", time.Since(start))
+		c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_limit", 1, 1000))
+		return
+	}
+	if req.Limit == 0 {
+		req.Limit = 100
+	}
+
+	summary, err := h.etlService.ReplayQuarantined(ctx, req)
+	if errors.Is(err, domain.ErrQuotaExceeded) {
+		h.quotaError(c, "POST", "/deadletter/replay", requestID, start, err)
+		return
+	}
+	if errors.Is(err, domain.ErrPushBatchTooLarge) {
+		h.metrics.RecordHTTPRequest("POST", "/deadletter/replay", "413", time.Since(start))
+		c.JSON(http.StatusRequestEntityTooLarge, errorBody(c, requestID, "push_batch_too_large", err.Error()))
+		return
+	}
+	if errors.Is(err, domain.ErrParseThreshold) {
+		h.metrics.RecordHTTPRequest("POST", "/deadletter/replay", "422", time.Since(start))
+		log.WithError(err).Warn("Replayed rows rejected by parse policy")
+		c.JSON(http.StatusUnprocessableEntity, errorBody(c, requestID, "parse_policy_violated", err.Error()))
+		return
+	}
+	if err != nil {
+		status, code := errorStatus(err, "ingestion_failed")
+		h.metrics.RecordHTTPRequest("POST", "/deadletter/replay", strconv.Itoa(status), time.Since(start))
+		if status >= http.StatusInternalServerError {
+			log.WithError(err).Error("Failed to replay quarantined rows")
+		}
+		c.JSON(status, errorBody(c, requestID, code, err.Error()))
+		return
+	}
+
+	h.metrics.RecordHTTPRequest("POST", "/deadletter/replay", "200", time.Since(start))
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Quarantined rows replayed",
+		"data":       summary,
+		"request_id": requestID,
+	})
+}
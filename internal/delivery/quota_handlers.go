@@ -0,0 +1,69 @@
+package delivery
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"etlgo/internal/domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetUsage returns the caller's tenant record quota and the upstream call
+// quotas in their current periods, along with the resources the tenant's runs
+// consumed in the current or requested month
+func (h *HTTPHandlers) GetUsage(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
+
+	month := c.DefaultQuery("month", h.clock.Now().UTC().Format("2006-01"))
+	if _, err := time.Parse("2006-01", month); err != nil {
+		h.metrics.RecordHTTPRequest("GET", "/usage", "400", time.Since(start))
+		c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_parameters", "month must be formatted as YYYY-MM"))
+		return
+	}
+
+	usage, err := h.quotaService.Usage(ctx)
+	if err != nil {
+		h.metrics.RecordHTTPRequest("GET", "/usage", "500", time.Since(start))
+		h.logger.WithContext(ctx).WithError(err).Error("Failed to read quota usage")
+		c.JSON(http.StatusInternalServerError, errorBody(c, requestID, "internal_error"))
+		return
+	}
+
+	cost, err := h.quotaService.CostUsage(ctx, month)
+	if err != nil {
+		h.metrics.RecordHTTPRequest("GET", "/usage", "500", time.Since(start))
+		h.logger.WithContext(ctx).WithError(err).Error("Failed to read cost usage")
+		c.JSON(http.StatusInternalServerError, errorBody(c, requestID, "internal_error"))
+		return
+	}
+
+	h.metrics.RecordHTTPRequest("GET", "/usage", "200", time.Since(start))
+	c.JSON(http.StatusOK, gin.H{
+		"data":       usage,
+		"cost":       cost,
+		"request_id": requestID,
+	})
+}
+
+// quotaError rejects work that would exceed a quota until its period resets
+func (h *HTTPHandlers) quotaError(c *gin.Context, method, endpoint, requestID string, start time.Time, err error) {
+	body := errorBody(c, requestID, "quota_exceeded", err.Error())
+
+	var quotaErr *domain.QuotaError
+	if errors.As(err, &quotaErr) {
+		retryAfter := max(int(time.Until(quotaErr.Usage.ResetsAt).Seconds()), 1)
+		c.Header("Retry-After", strconv.Itoa(retryAfter))
+		body["quota"] = quotaErr.Usage
+	}
+
+	h.metrics.RecordHTTPRequest(method, endpoint, "429", time.Since(start))
+	c.JSON(http.StatusTooManyRequests, body)
+}
@@ -0,0 +1,65 @@
+package delivery
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetFlatReport returns a page of the metrics as flat rows with a fixed
+// column set for BI connectors
+func (h *HTTPHandlers) GetFlatReport(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
+	const endpoint = "/reporting/flat"
+
+	from, to, err := h.parseDateRange(c)
+	if err != nil {
+		h.metrics.RecordHTTPRequest("GET", endpoint, "400", time.Since(start))
+		c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_parameters", err.Error()))
+		return
+	}
+
+	// Connectors page through large ranges, so pages default to the maximum
+	limit := 1000
+	if limitStr := c.Query("limit"); limitStr != "" {
+		parsed, err := strconv.Atoi(limitStr)
+		if err != nil || parsed <= 0 || parsed > 1000 {
+			h.metrics.RecordHTTPRequest("GET", endpoint, "400", time.Since(start))
+			c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_limit", 1, 1000))
+			return
+		}
+		limit = parsed
+	}
+
+	report, err := h.metricsService.FlatReport(ctx, from, to, c.Query("cursor"), limit)
+	if err != nil {
+		status, code := errorStatus(err, "metrics_retrieval_failed")
+		h.metrics.RecordHTTPRequest("GET", endpoint, strconv.Itoa(status), time.Since(start))
+		if status >= http.StatusInternalServerError {
+			h.logger.WithContext(ctx).WithError(err).Error("Failed to build flat report")
+		}
+		c.JSON(status, errorBody(c, requestID, code, err.Error()))
+		return
+	}
+
+	h.metrics.RecordHTTPRequest("GET", endpoint, "200", time.Since(start))
+	response := gin.H{
+		"columns":     report.Columns,
+		"rows":        report.Rows,
+		"next_cursor": report.NextCursor,
+		"from":        from.Format("2006-01-02"),
+		"to":          to.Format("2006-01-02"),
+		"request_id":  requestID,
+	}
+	if report.QueryCost != nil {
+		response["query_cost"] = report.QueryCost
+	}
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,46 @@
+package delivery
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListRestatements returns opportunities ingested again with changes and the
+// metrics they restated
+func (h *HTTPHandlers) ListRestatements(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
+
+	limit := 100
+	if limitStr := c.Query("limit"); limitStr != "" {
+		parsed, err := strconv.Atoi(limitStr)
+		if err != nil || parsed <= 0 || parsed > 1000 {
+			h.metrics.RecordHTTPRequest("GET", "/ingest/restatements", "400", time.Since(start))
+			c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_limit", 1, 1000))
+			return
+		}
+		limit = parsed
+	}
+
+	restatements, err := h.etlService.ListRestatements(ctx, c.Query("opportunity_id"), limit)
+	if err != nil {
+		h.metrics.RecordHTTPRequest("GET", "/ingest/restatements", "500", time.Since(start))
+		h.logger.WithContext(ctx).WithError(err).Error("Failed to list restatements")
+		c.JSON(http.StatusInternalServerError, errorBody(c, requestID, "restatement_list_failed"))
+		return
+	}
+
+	h.metrics.RecordHTTPRequest("GET", "/ingest/restatements", "200", time.Since(start))
+	c.JSON(http.StatusOK, gin.H{
+		"data":       restatements,
+		"total":      len(restatements),
+		"request_id": requestID,
+	})
+}
@@ -0,0 +1,67 @@
+package delivery
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"etlgo/internal/domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetRevenue returns closed won revenue bucketed by the date it is
+// recognized on, the opportunities' creation or close
+func (h *HTTPHandlers) GetRevenue(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
+	const endpoint = "/metrics/revenue"
+
+	from, to, err := h.parseDateRange(c)
+	if err != nil {
+		h.metrics.RecordHTTPRequest("GET", endpoint, "400", time.Since(start))
+		c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_parameters", err.Error()))
+		return
+	}
+
+	// the report is all revenue, so roles hiding it get none of it
+	if err := h.metricsService.ColumnMask(ctx).Check("revenue"); err != nil {
+		h.metrics.RecordHTTPRequest("GET", endpoint, "403", time.Since(start))
+		c.JSON(http.StatusForbidden, errorBody(c, requestID, "forbidden", err.Error()))
+		return
+	}
+
+	report, err := h.etlService.GetRevenue(ctx, domain.RevenueQuery{
+		Recognition: domain.RevenueRecognition(c.Query("recognition")),
+		Interval:    c.DefaultQuery("interval", domain.IntervalMonth),
+		From:        from,
+		To:          to,
+	})
+	if err != nil {
+		status, code := errorStatus(err, "metrics_retrieval_failed")
+		h.metrics.RecordHTTPRequest("GET", endpoint, strconv.Itoa(status), time.Since(start))
+		if status >= http.StatusInternalServerError {
+			c.JSON(status, errorBody(c, requestID, code))
+			return
+		}
+		c.JSON(status, errorBody(c, requestID, code, err.Error()))
+		return
+	}
+
+	h.metrics.RecordHTTPRequest("GET", endpoint, "200", time.Since(start))
+	c.JSON(http.StatusOK, gin.H{
+		"recognition":        report.Recognition,
+		"interval":           report.Interval,
+		"buckets":            report.Buckets,
+		"closed_won":         report.ClosedWon,
+		"revenue":            report.Revenue,
+		"missing_close_date": report.MissingCloseDate,
+		"from":               from.Format("2006-01-02"),
+		"to":                 to.Format("2006-01-02"),
+		"request_id":         requestID,
+	})
+}
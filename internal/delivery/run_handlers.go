@@ -0,0 +1,250 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"etlgo/internal/domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListRuns returns the run history, most recently completed first,
+// filtered by status, pipeline and tags
+func (h *HTTPHandlers) ListRuns(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
+	const endpoint = "/ingest/runs"
+
+	filter := domain.RunFilter{
+		Status:   c.Query("status"),
+		Pipeline: c.Query("pipeline"),
+		Limit:    50,
+	}
+	if filter.Status != "" && filter.Status != domain.RunCompleted && filter.Status != domain.RunFailed {
+		h.metrics.RecordHTTPRequest("GET", endpoint, "400", time.Since(start))
+		c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_status", "completed, failed"))
+		return
+	}
+	tags, err := domain.ParseRunTagFilter(c.QueryArray("tag"))
+	if err != nil {
+		h.metrics.RecordHTTPRequest("GET", endpoint, "400", time.Since(start))
+		c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_run_tags", err.Error()))
+		return
+	}
+	filter.Tags = tags
+	if limitStr := c.Query("limit"); limitStr != "" {
+		parsed, err := strconv.Atoi(limitStr)
+		if err != nil || parsed <= 0 || parsed > 1000 {
+			h.metrics.RecordHTTPRequest("GET", endpoint, "400", time.Since(start))
+			c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_limit", 1, 1000))
+			return
+		}
+		filter.Limit = parsed
+	}
+
+	runs, err := h.etlService.ListRuns(ctx, filter)
+	if err != nil {
+		h.metrics.RecordHTTPRequest("GET", endpoint, "500", time.Since(start))
+		h.logger.WithContext(ctx).WithError(err).Error("Failed to list runs")
+		c.JSON(http.StatusInternalServerError, errorBody(c, requestID, "run_list_failed"))
+		return
+	}
+
+	h.metrics.RecordHTTPRequest("GET", endpoint, "200", time.Since(start))
+	c.JSON(http.StatusOK, gin.H{
+		"data":       runs,
+		"total":      len(runs),
+		"request_id": requestID,
+	})
+}
+
+// GetRun returns a finished run with its stats, cost and anomalies
+func (h *HTTPHandlers) GetRun(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
+
+	run, ok := h.findRun(c, ctx, "/ingest/runs/:id", requestID, start)
+	if !ok {
+		return
+	}
+
+	h.metrics.RecordHTTPRequest("GET", "/ingest/runs/:id", "200", time.Since(start))
+	c.JSON(http.StatusOK, gin.H{
+		"data":       run,
+		"request_id": requestID,
+	})
+}
+
+// GetRunCertification downloads the certification of a completed run
+func (h *HTTPHandlers) GetRunCertification(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
+	const endpoint = "/ingest/runs/:id/certification"
+
+	certification, err := h.etlService.GetRunCertification(ctx, c.Param("id"))
+	if errors.Is(err, domain.ErrRunNotFound) {
+		h.metrics.RecordHTTPRequest("GET", endpoint, "404", time.Since(start))
+		c.JSON(http.StatusNotFound, errorBody(c, requestID, "run_not_found", c.Param("id")))
+		return
+	}
+	if err != nil {
+		status, code := errorStatus(err, "internal_error")
+		h.metrics.RecordHTTPRequest("GET", endpoint, strconv.Itoa(status), time.Since(start))
+		if status >= http.StatusInternalServerError {
+			h.logger.WithContext(ctx).WithError(err).Error("Failed to get run certification")
+			c.JSON(status, errorBody(c, requestID, code))
+			return
+		}
+		c.JSON(status, errorBody(c, requestID, code, err.Error()))
+		return
+	}
+
+	h.metrics.RecordHTTPRequest("GET", endpoint, "200", time.Since(start))
+	// The artifact is served as signed, without a request envelope
+	c.Header("Content-Disposition", `attachment; filename="run-`+certification.RunID+`-certification.json"`)
+	c.JSON(http.StatusOK, certification)
+}
+
+// GetRunJoinReport returns how the UTM keys of ads and opportunities joined
+// in a run, with samples of the unmatched keys
+func (h *HTTPHandlers) GetRunJoinReport(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
+	const endpoint = "/ingest/runs/:id/join-report"
+
+	report, err := h.etlService.GetRunJoinReport(ctx, c.Param("id"))
+	if errors.Is(err, domain.ErrRunNotFound) {
+		h.metrics.RecordHTTPRequest("GET", endpoint, "404", time.Since(start))
+		c.JSON(http.StatusNotFound, errorBody(c, requestID, "run_not_found", c.Param("id")))
+		return
+	}
+	if err != nil {
+		status, code := errorStatus(err, "internal_error")
+		h.metrics.RecordHTTPRequest("GET", endpoint, strconv.Itoa(status), time.Since(start))
+		if status >= http.StatusInternalServerError {
+			h.logger.WithContext(ctx).WithError(err).Error("Failed to get run join report")
+			c.JSON(status, errorBody(c, requestID, code))
+			return
+		}
+		c.JSON(status, errorBody(c, requestID, code, err.Error()))
+		return
+	}
+
+	h.metrics.RecordHTTPRequest("GET", endpoint, "200", time.Since(start))
+	c.JSON(http.StatusOK, gin.H{
+		"data":       report,
+		"request_id": requestID,
+	})
+}
+
+// CompareRuns returns the differences between two runs over the same
+// window
+func (h *HTTPHandlers) CompareRuns(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
+	const endpoint = "/ingest/runs/compare"
+
+	for _, param := range []string{"a", "b"} {
+		if c.Query(param) == "" {
+			h.metrics.RecordHTTPRequest("GET", endpoint, "400", time.Since(start))
+			c.JSON(http.StatusBadRequest, errorBody(c, requestID, "missing_parameter", param))
+			return
+		}
+	}
+
+	comparison, err := h.etlService.CompareRuns(ctx, c.Query("a"), c.Query("b"))
+	if err != nil {
+		status, code := errorStatus(err, "internal_error")
+		h.metrics.RecordHTTPRequest("GET", endpoint, strconv.Itoa(status), time.Since(start))
+		if status >= http.StatusInternalServerError {
+			h.logger.WithContext(ctx).WithError(err).Error("Failed to compare runs")
+			c.JSON(status, errorBody(c, requestID, code))
+			return
+		}
+		c.JSON(status, errorBody(c, requestID, code, err.Error()))
+		return
+	}
+
+	h.metrics.RecordHTTPRequest("GET", endpoint, "200", time.Since(start))
+	c.JSON(http.StatusOK, gin.H{
+		"data":       comparison,
+		"request_id": requestID,
+	})
+}
+
+// PreviewNotification renders the notification a channel sends for a run
+// without sending it, for trying out templates
+func (h *HTTPHandlers) PreviewNotification(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
+	const endpoint = "/ingest/runs/:id/notifications/:channel"
+
+	run, ok := h.findRun(c, ctx, endpoint, requestID, start)
+	if !ok {
+		return
+	}
+
+	message, err := h.notifier.Render(c.Param("channel"), *run)
+	if errors.Is(err, domain.ErrNotificationChannelNotFound) {
+		h.metrics.RecordHTTPRequest("GET", endpoint, "404", time.Since(start))
+		c.JSON(http.StatusNotFound, errorBody(c, requestID, "notification_channel_not_found", c.Param("channel")))
+		return
+	}
+	if err != nil {
+		h.metrics.RecordHTTPRequest("GET", endpoint, "422", time.Since(start))
+		c.JSON(http.StatusUnprocessableEntity, errorBody(c, requestID, "notification_template_failed", err.Error()))
+		return
+	}
+
+	h.metrics.RecordHTTPRequest("GET", endpoint, "200", time.Since(start))
+	c.JSON(http.StatusOK, gin.H{
+		"data":       message,
+		"request_id": requestID,
+	})
+}
+
+// looks up the run of the :id parameter, writing the error response when it
+// cannot be returned
+func (h *HTTPHandlers) findRun(c *gin.Context, ctx context.Context, endpoint, requestID string, start time.Time) (*domain.RunRecord, bool) {
+	run, err := h.etlService.GetRun(ctx, c.Param("id"))
+	if errors.Is(err, domain.ErrRunNotFound) {
+		h.metrics.RecordHTTPRequest("GET", endpoint, "404", time.Since(start))
+		c.JSON(http.StatusNotFound, errorBody(c, requestID, "run_not_found", c.Param("id")))
+		return nil, false
+	}
+	if err != nil {
+		h.metrics.RecordHTTPRequest("GET", endpoint, "500", time.Since(start))
+		h.logger.WithContext(ctx).WithError(err).Error("Failed to get run")
+		c.JSON(http.StatusInternalServerError, errorBody(c, requestID, "internal_error"))
+		return nil, false
+	}
+	return run, true
+}
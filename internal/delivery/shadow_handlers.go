@@ -0,0 +1,158 @@
+package delivery
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"etlgo/internal/domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListShadows returns the shadow configs every run evaluates
+func (h *HTTPHandlers) ListShadows(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
+
+	shadows, err := h.etlService.ListShadows(ctx)
+	if err != nil {
+		h.shadowError(c, ctx, "GET", "/shadows", requestID, start, err)
+		return
+	}
+
+	h.metrics.RecordHTTPRequest("GET", "/shadows", "200", time.Since(start))
+	c.JSON(http.StatusOK, gin.H{
+		"data":       shadows,
+		"total":      len(shadows),
+		"request_id": requestID,
+	})
+}
+
+// SaveShadow registers or replaces the shadow config of the :label parameter
+func (h *HTTPHandlers) SaveShadow(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
+	const endpoint = "/shadows/:label"
+
+	var config domain.ShadowConfig
+	if err := c.ShouldBindJSON(&config); err != nil {
+		h.metrics.RecordHTTPRequest("PUT", endpoint, "400", time.Since(start))
+		c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_request_body", err.Error()))
+		return
+	}
+	config.Label = c.Param("label")
+
+	saved, err := h.etlService.SaveShadow(ctx, config, operatorOf(c))
+	if err != nil {
+		h.shadowError(c, ctx, "PUT", endpoint, requestID, start, err)
+		return
+	}
+
+	h.metrics.RecordHTTPRequest("PUT", endpoint, "200", time.Since(start))
+	c.JSON(http.StatusOK, gin.H{
+		"data":       saved,
+		"request_id": requestID,
+	})
+}
+
+// DeleteShadow stops evaluating the shadow config of the :label parameter
+func (h *HTTPHandlers) DeleteShadow(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
+
+	if err := h.etlService.DeleteShadow(ctx, c.Param("label")); err != nil {
+		h.shadowError(c, ctx, "DELETE", "/shadows/:label", requestID, start, err)
+		return
+	}
+
+	h.metrics.RecordHTTPRequest("DELETE", "/shadows/:label", "200", time.Since(start))
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Shadow config deleted",
+		"request_id": requestID,
+	})
+}
+
+// ListShadowResults returns the diff reports of a shadow config, most recent
+// first
+func (h *HTTPHandlers) ListShadowResults(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
+	const endpoint = "/shadows/:label/results"
+
+	limit := 100
+	if limitStr := c.Query("limit"); limitStr != "" {
+		parsed, err := strconv.Atoi(limitStr)
+		if err != nil || parsed <= 0 || parsed > 1000 {
+			h.metrics.RecordHTTPRequest("GET", endpoint, "400", time.Since(start))
+			c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_limit", 1, 1000))
+			return
+		}
+		limit = parsed
+	}
+
+	results, err := h.etlService.ListShadowResults(ctx, c.Param("label"), limit)
+	if err != nil {
+		h.shadowError(c, ctx, "GET", endpoint, requestID, start, err)
+		return
+	}
+
+	h.metrics.RecordHTTPRequest("GET", endpoint, "200", time.Since(start))
+	c.JSON(http.StatusOK, gin.H{
+		"data":       results,
+		"total":      len(results),
+		"request_id": requestID,
+	})
+}
+
+// GetShadowResult returns a diff report with the shadow's metrics
+func (h *HTTPHandlers) GetShadowResult(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
+	const endpoint = "/shadows/:label/results/:id"
+
+	result, err := h.etlService.GetShadowResult(ctx, c.Param("label"), c.Param("id"))
+	if err != nil {
+		h.shadowError(c, ctx, "GET", endpoint, requestID, start, err)
+		return
+	}
+
+	h.metrics.RecordHTTPRequest("GET", endpoint, "200", time.Since(start))
+	c.JSON(http.StatusOK, gin.H{
+		"data":       result,
+		"request_id": requestID,
+	})
+}
+
+// shadowError maps shadow errors to HTTP responses
+func (h *HTTPHandlers) shadowError(c *gin.Context, ctx context.Context, method, endpoint, requestID string, start time.Time, err error) {
+	status, code := errorStatus(err, "internal_error")
+	h.metrics.RecordHTTPRequest(method, endpoint, strconv.Itoa(status), time.Since(start))
+	if status >= http.StatusInternalServerError {
+		h.logger.WithContext(ctx).WithError(err).Error("Shadow operation failed")
+		c.JSON(status, errorBody(c, requestID, code))
+		return
+	}
+	c.JSON(status, errorBody(c, requestID, code, err.Error()))
+}
@@ -0,0 +1,251 @@
+package delivery
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"etlgo/internal/domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+// a request for a share token: the view it grants and how long for, e.g.
+// 720h
+type shareTokenRequest struct {
+	domain.ShareToken
+	ExpiresIn string `json:"expires_in"`
+}
+
+// CreateShareToken issues a share token granting read-only access to one
+// metrics view until it expires
+func (h *HTTPHandlers) CreateShareToken(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
+	const endpoint = "/admin/share-tokens"
+
+	var request shareTokenRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		h.metrics.RecordHTTPRequest("POST", endpoint, "400", time.Since(start))
+		c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_request_body", err.Error()))
+		return
+	}
+	var ttl time.Duration
+	if request.ExpiresIn != "" {
+		var err error
+		if ttl, err = time.ParseDuration(request.ExpiresIn); err != nil {
+			h.metrics.RecordHTTPRequest("POST", endpoint, "400", time.Since(start))
+			c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_request_body", fmt.Sprintf("invalid expires_in %q", request.ExpiresIn)))
+			return
+		}
+	}
+
+	signed, grant, err := h.shareTokens.Issue(ctx, request.ShareToken, ttl)
+	if errors.Is(err, domain.ErrShareTokensNotConfigured) {
+		h.metrics.RecordHTTPRequest("POST", endpoint, "400", time.Since(start))
+		c.JSON(http.StatusBadRequest, errorBody(c, requestID, "share_tokens_not_configured"))
+		return
+	}
+	if err != nil {
+		status, code := errorStatus(err, "share_token_failed")
+		h.metrics.RecordHTTPRequest("POST", endpoint, strconv.Itoa(status), time.Since(start))
+		if status >= http.StatusInternalServerError {
+			h.logger.WithContext(ctx).WithError(err).Error("Failed to issue share token")
+			c.JSON(status, errorBody(c, requestID, code))
+			return
+		}
+		c.JSON(status, errorBody(c, requestID, code, err.Error()))
+		return
+	}
+
+	h.metrics.RecordHTTPRequest("POST", endpoint, "201", time.Since(start))
+	c.JSON(http.StatusCreated, gin.H{
+		"token":      signed,
+		"data":       grant,
+		"links":      gin.H{"metrics": "/api/v1/shared/metrics?token=" + signed, "timeseries": "/api/v1/shared/timeseries?token=" + signed},
+		"request_id": requestID,
+	})
+}
+
+// RevokeShareToken revokes the share token issued with the grant ID in the
+// path, so its links stop working before they expire
+func (h *HTTPHandlers) RevokeShareToken(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
+	const endpoint = "/admin/share-tokens/:id"
+
+	revocation, err := h.shareTokens.Revoke(ctx, c.Param("id"))
+	if errors.Is(err, domain.ErrShareTokensNotConfigured) {
+		h.metrics.RecordHTTPRequest("DELETE", endpoint, "400", time.Since(start))
+		c.JSON(http.StatusBadRequest, errorBody(c, requestID, "share_tokens_not_configured"))
+		return
+	}
+	if err != nil {
+		status, code := errorStatus(err, "share_token_failed")
+		h.metrics.RecordHTTPRequest("DELETE", endpoint, strconv.Itoa(status), time.Since(start))
+		if status >= http.StatusInternalServerError {
+			h.logger.WithContext(ctx).WithError(err).Error("Failed to revoke share token")
+			c.JSON(status, errorBody(c, requestID, code))
+			return
+		}
+		c.JSON(status, errorBody(c, requestID, code, err.Error()))
+		return
+	}
+
+	h.metrics.RecordHTTPRequest("DELETE", endpoint, "200", time.Since(start))
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Share token revoked",
+		"data":       revocation,
+		"request_id": requestID,
+	})
+}
+
+// GetSharedMetrics returns the metrics rows of a share token's view,
+// optionally narrowed to part of its date range
+func (h *HTTPHandlers) GetSharedMetrics(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
+	const endpoint = "/shared/metrics"
+
+	_, _, limit, offset, err := h.parseMetricsParams(c)
+	if err == nil && (limit <= 0 || limit > 1000) {
+		h.metrics.RecordHTTPRequest("GET", endpoint, "400", time.Since(start))
+		c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_limit", 1, 1000))
+		return
+	}
+	var from, to time.Time
+	if err == nil {
+		from, to, err = h.sharedWindow(c)
+	}
+	if err != nil {
+		h.metrics.RecordHTTPRequest("GET", endpoint, "400", time.Since(start))
+		c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_parameters", err.Error()))
+		return
+	}
+
+	response, err := h.metricsService.GetMetricsByFilter(ctx, domain.MetricsFilter{From: &from, To: &to, Limit: limit, Offset: offset})
+	if err != nil {
+		status, code := errorStatus(err, "metrics_retrieval_failed")
+		h.metrics.RecordHTTPRequest("GET", endpoint, strconv.Itoa(status), time.Since(start))
+		if status >= http.StatusInternalServerError {
+			h.logger.WithContext(ctx).WithError(err).Error("Failed to get shared metrics")
+			c.JSON(status, errorBody(c, requestID, code))
+			return
+		}
+		c.JSON(status, errorBody(c, requestID, code, err.Error()))
+		return
+	}
+
+	h.metrics.RecordHTTPRequest("GET", endpoint, "200", time.Since(start))
+	c.JSON(http.StatusOK, gin.H{
+		"data":       response.Data,
+		"total":      response.Total,
+		"limit":      response.Limit,
+		"offset":     response.Offset,
+		"has_more":   response.HasMore,
+		"meta":       response.Meta,
+		"from":       from.Format("2006-01-02"),
+		"to":         to.Format("2006-01-02"),
+		"expires_at": domain.ShareTokenFromContext(ctx).ExpiresAt,
+		"request_id": requestID,
+	})
+}
+
+// GetSharedTimeSeries returns a metric of a share token's view over time,
+// optionally narrowed to part of its date range
+func (h *HTTPHandlers) GetSharedTimeSeries(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
+	const endpoint = "/shared/timeseries"
+
+	metric := c.Query("metric")
+	if metric == "" {
+		h.metrics.RecordHTTPRequest("GET", endpoint, "400", time.Since(start))
+		c.JSON(http.StatusBadRequest, errorBody(c, requestID, "missing_parameter", "metric"))
+		return
+	}
+
+	from, to, err := h.sharedWindow(c)
+	if err != nil {
+		h.metrics.RecordHTTPRequest("GET", endpoint, "400", time.Since(start))
+		c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_parameters", err.Error()))
+		return
+	}
+
+	series, err := h.metricsService.GetTimeSeries(ctx, domain.TimeSeriesQuery{
+		Metric:   metric,
+		GroupBy:  c.Query("group_by"),
+		Interval: c.DefaultQuery("interval", domain.IntervalDay),
+		Fill:     c.DefaultQuery("fill", domain.GapFillZero),
+		From:     from,
+		To:       to,
+	})
+	if err != nil {
+		status, code := errorStatus(err, "metrics_retrieval_failed")
+		h.metrics.RecordHTTPRequest("GET", endpoint, strconv.Itoa(status), time.Since(start))
+		if status >= http.StatusInternalServerError {
+			h.logger.WithContext(ctx).WithError(err).Error("Failed to get shared time series")
+		}
+		c.JSON(status, errorBody(c, requestID, code, err.Error()))
+		return
+	}
+
+	h.metrics.RecordHTTPRequest("GET", endpoint, "200", time.Since(start))
+	c.JSON(http.StatusOK, gin.H{
+		"metric":     series.Metric,
+		"group_by":   series.GroupBy,
+		"interval":   series.Interval,
+		"fill":       series.Fill,
+		"timestamps": series.Timestamps,
+		"series":     series.Series,
+		"from":       from.Format("2006-01-02"),
+		"to":         to.Format("2006-01-02"),
+		"request_id": requestID,
+	})
+}
+
+// returns the date range of the share token's view, narrowed by the from
+// and to parameters. Dates outside the view are clamped to it.
+func (h *HTTPHandlers) sharedWindow(c *gin.Context) (from, to time.Time, err error) {
+	from, to = domain.ShareTokenFromContext(c.Request.Context()).Window(h.clock.Now())
+	if value := c.Query("from"); value != "" {
+		narrowed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		if narrowed.After(from) {
+			from = narrowed
+		}
+	}
+	if value := c.Query("to"); value != "" {
+		narrowed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		if narrowed.Before(to) {
+			to = narrowed
+		}
+	}
+	if from.After(to) {
+		return time.Time{}, time.Time{}, fmt.Errorf("from must not be after to")
+	}
+	return from, to, nil
+}
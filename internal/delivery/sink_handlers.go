@@ -0,0 +1,68 @@
+package delivery
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"etlgo/internal/domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+// body of POST /export/verify. URL is only decoded to reject it: sinks
+// are verified by the name of their destination.
+type verifyRequest struct {
+	Destination string `json:"destination"`
+	URL         string `json:"url"`
+}
+
+// VerifyExportDestination checks a configured export destination with a
+// signed synthetic payload before real data is routed to it. Without a
+// body the sink is verified.
+func (h *HTTPHandlers) VerifyExportDestination(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
+
+	var req verifyRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			h.metrics.RecordHTTPRequest("POST", "/export/verify", "400", time.Since(start))
+			c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_request_body", err.Error()))
+			return
+		}
+	}
+	if req.URL != "" {
+		h.metrics.RecordHTTPRequest("POST", "/export/verify", "400", time.Since(start))
+		c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_sink_target", "only configured destinations are verified; name one in destination"))
+		return
+	}
+
+	verification, err := h.metricsService.VerifyDestination(ctx, req.Destination)
+	if errors.Is(err, domain.ErrSinkNotConfigured) {
+		h.metrics.RecordHTTPRequest("POST", "/export/verify", "400", time.Since(start))
+		c.JSON(http.StatusBadRequest, errorBody(c, requestID, "sink_not_configured"))
+		return
+	}
+	if errors.Is(err, domain.ErrInvalidSinkTarget) {
+		h.metrics.RecordHTTPRequest("POST", "/export/verify", "400", time.Since(start))
+		c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_sink_target", err.Error()))
+		return
+	}
+	if err != nil {
+		h.metrics.RecordHTTPRequest("POST", "/export/verify", "500", time.Since(start))
+		h.logger.WithContext(ctx).WithError(err).Error("Failed to verify sink")
+		c.JSON(http.StatusInternalServerError, errorBody(c, requestID, "internal_error"))
+		return
+	}
+
+	h.metrics.RecordHTTPRequest("POST", "/export/verify", "200", time.Since(start))
+	c.JSON(http.StatusOK, gin.H{
+		"data":       verification,
+		"request_id": requestID,
+	})
+}
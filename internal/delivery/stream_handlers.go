@@ -0,0 +1,24 @@
+package delivery
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetStreamStatus returns the state of streaming ingestion: the batches
+// loaded, the last failure and the offsets committed per partition
+func (h *HTTPHandlers) GetStreamStatus(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+
+	h.metrics.RecordHTTPRequest("GET", "/ingest/stream", "200", time.Since(start))
+	c.JSON(http.StatusOK, gin.H{
+		"data":       h.streamService.Status(),
+		"request_id": requestID,
+	})
+}
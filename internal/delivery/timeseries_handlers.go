@@ -0,0 +1,93 @@
+package delivery
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"etlgo/internal/domain"
+	"etlgo/pkg/buildinfo"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetTimeSeries returns a metric as aligned arrays of bucket timestamps and
+// values per group, ready for charting
+func (h *HTTPHandlers) GetTimeSeries(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
+	const endpoint = "/metrics/timeseries"
+
+	metric := c.Query("metric")
+	if metric == "" {
+		h.metrics.RecordHTTPRequest("GET", endpoint, "400", time.Since(start))
+		c.JSON(http.StatusBadRequest, errorBody(c, requestID, "missing_parameter", "metric"))
+		return
+	}
+
+	from, to, err := h.parseDateRange(c)
+	if err != nil {
+		h.metrics.RecordHTTPRequest("GET", endpoint, "400", time.Since(start))
+		c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_parameters", err.Error()))
+		return
+	}
+
+	series, err := h.metricsService.GetTimeSeries(ctx, domain.TimeSeriesQuery{
+		Metric:   metric,
+		GroupBy:  c.Query("group_by"),
+		Interval: c.DefaultQuery("interval", domain.IntervalDay),
+		Fill:     c.DefaultQuery("fill", domain.GapFillZero),
+		From:     from,
+		To:       to,
+	})
+	if err != nil {
+		status, code := errorStatus(err, "metrics_retrieval_failed")
+		h.metrics.RecordHTTPRequest("GET", endpoint, strconv.Itoa(status), time.Since(start))
+		if status >= http.StatusInternalServerError {
+			h.logger.WithContext(ctx).WithError(err).Error("Failed to get time series")
+		}
+		c.JSON(status, errorBody(c, requestID, code, err.Error()))
+		return
+	}
+
+	h.metrics.RecordHTTPRequest("GET", endpoint, "200", time.Since(start))
+	c.JSON(http.StatusOK, gin.H{
+		"metric":     series.Metric,
+		"group_by":   series.GroupBy,
+		"interval":   series.Interval,
+		"fill":       series.Fill,
+		"timestamps": series.Timestamps,
+		"series":     series.Series,
+		"from":       from.Format("2006-01-02"),
+		"to":         to.Format("2006-01-02"),
+		"request_id": requestID,
+	})
+}
+
+// GetMetricCatalog returns the definitions of the metrics the service
+// computes, for BI tools and docs to discover them. Metrics hidden from the
+// caller's role are left out.
+func (h *HTTPHandlers) GetMetricCatalog(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
+
+	catalog := h.metricsService.MetricCatalog(ctx)
+
+	h.metrics.RecordHTTPRequest("GET", "/metrics/catalog", "200", time.Since(start))
+	c.JSON(http.StatusOK, gin.H{
+		"data":       catalog,
+		"total":      len(catalog),
+		"dimensions": domain.Dimensions,
+		"intervals":  []string{domain.IntervalDay, domain.IntervalWeek, domain.IntervalMonth},
+		"version":    buildinfo.Version,
+		"request_id": requestID,
+	})
+}
@@ -0,0 +1,140 @@
+package delivery
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"etlgo/internal/domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+// IngestUpload runs the pipeline on ads and/or CRM CSV files uploaded as
+// the multipart fields ads and crm, and returns the run's summary. An
+// optional tags field holds a JSON object of run tags.
+func (h *HTTPHandlers) IngestUpload(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
+	log := h.logger.WithContext(ctx)
+
+	if h.maintenanceService.Status().Enabled {
+		h.maintenanceError(c, "POST", "/ingest/upload", requestID, start, domain.ErrMaintenance)
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.uploadMaxBytes)
+	form, err := c.MultipartForm()
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			h.metrics.RecordHTTPRequest("POST", "/ingest/upload", "413", time.Since(start))
+			c.JSON(http.StatusRequestEntityTooLarge, errorBody(c, requestID, "upload_too_large", h.uploadMaxBytes))
+			return
+		}
+		h.metrics.RecordHTTPRequest("POST", "/ingest/upload", "400", time.Since(start))
+		c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_request_body", err.Error()))
+		return
+	}
+	defer form.RemoveAll()
+
+	var tags map[string]string
+	if values := form.Value["tags"]; len(values) > 0 {
+		if err := json.Unmarshal([]byte(values[0]), &tags); err != nil {
+			h.metrics.RecordHTTPRequest("POST", "/ingest/upload", "400", time.Since(start))
+			c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_run_tags", "tags must be a JSON object of strings"))
+			return
+		}
+	}
+	if err := domain.ValidateRunTags(tags); err != nil {
+		h.metrics.RecordHTTPRequest("POST", "/ingest/upload", "400", time.Since(start))
+		c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_run_tags", err.Error()))
+		return
+	}
+
+	files := make(map[string]io.Reader)
+	for _, source := range []string{domain.SourceAds, domain.SourceCRM} {
+		headers := form.File[source]
+		if len(headers) == 0 {
+			continue
+		}
+		if len(headers) > 1 {
+			h.metrics.RecordHTTPRequest("POST", "/ingest/upload", "400", time.Since(start))
+			c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_request_body", "upload one "+source+" file"))
+			return
+		}
+		file, err := headers[0].Open()
+		if err != nil {
+			h.metrics.RecordHTTPRequest("POST", "/ingest/upload", "400", time.Since(start))
+			c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_request_body", err.Error()))
+			return
+		}
+		defer file.Close()
+		files[source] = file
+	}
+	if len(files) == 0 {
+		h.metrics.RecordHTTPRequest("POST", "/ingest/upload", "400", time.Since(start))
+		c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_request_body", "an ads or crm file is required"))
+		return
+	}
+
+	priority, ok := h.parsePriority(c, domain.PriorityNormal)
+	if !ok {
+		h.metrics.RecordHTTPRequest("POST", "/ingest/upload", "400", time.Since(start))
+		c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_priority"))
+		return
+	}
+
+	var summary *domain.RunSummary
+	err = h.jobQueue.Run(ctx, domain.JobTypeIngest, priority, func(ctx context.Context) error {
+		var err error
+		summary, err = h.etlService.IngestFiles(ctx, files, tags)
+		return err
+	})
+	if errors.Is(err, domain.ErrMaintenance) {
+		h.maintenanceError(c, "POST", "/ingest/upload", requestID, start, err)
+		return
+	}
+	if errors.Is(err, domain.ErrQuotaExceeded) {
+		h.quotaError(c, "POST", "/ingest/upload", requestID, start, err)
+		return
+	}
+	if errors.Is(err, domain.ErrJobQueueTimeout) {
+		h.metrics.RecordHTTPRequest("POST", "/ingest/upload", "503", time.Since(start))
+		log.WithError(err).Warn("Upload ingestion not admitted")
+		c.JSON(http.StatusServiceUnavailable, errorBody(c, requestID, "job_queue_busy", err.Error()))
+		return
+	}
+	if errors.Is(err, domain.ErrParseThreshold) {
+		h.metrics.RecordHTTPRequest("POST", "/ingest/upload", "422", time.Since(start))
+		log.WithError(err).Warn("Uploaded files rejected by parse policy")
+		body := errorBody(c, requestID, "parse_policy_violated", err.Error())
+		body["summary"] = summary
+		c.JSON(http.StatusUnprocessableEntity, body)
+		return
+	}
+	if err != nil {
+		status, code := errorStatus(err, "ingestion_failed")
+		h.metrics.RecordHTTPRequest("POST", "/ingest/upload", strconv.Itoa(status), time.Since(start))
+		if status >= http.StatusInternalServerError {
+			log.WithError(err).Error("Upload ingestion failed")
+		}
+		c.JSON(status, errorBody(c, requestID, code, err.Error()))
+		return
+	}
+
+	h.metrics.RecordHTTPRequest("POST", "/ingest/upload", "200", time.Since(start))
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Uploaded files ingested successfully",
+		"summary":    summary,
+		"request_id": requestID,
+	})
+}
@@ -0,0 +1,68 @@
+package delivery
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetBackgroundJobs returns the background jobs supervised by the watchdog
+func (h *HTTPHandlers) GetBackgroundJobs(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+
+	h.metrics.RecordHTTPRequest("GET", "/jobs/background", "200", time.Since(start))
+	c.JSON(http.StatusOK, gin.H{
+		"data":       h.watchdog.Jobs(),
+		"deadline":   h.watchdog.Deadline().String(),
+		"request_id": requestID,
+	})
+}
+
+// GetJobIncidents returns the background jobs the watchdog found wedged and
+// restarted, most recent first
+func (h *HTTPHandlers) GetJobIncidents(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
+	const endpoint = "/jobs/incidents"
+
+	limit := 100
+	if limitStr := c.Query("limit"); limitStr != "" {
+		parsed, err := strconv.Atoi(limitStr)
+		if err != nil || parsed <= 0 || parsed > 1000 {
+			h.metrics.RecordHTTPRequest("GET", endpoint, "400", time.Since(start))
+			c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_limit", 1, 1000))
+			return
+		}
+		limit = parsed
+	}
+
+	incidents, err := h.watchdog.Incidents(ctx, c.Query("job"), limit)
+	if err != nil {
+		status, code := errorStatus(err, "job_incidents_list_failed")
+		h.metrics.RecordHTTPRequest("GET", endpoint, strconv.Itoa(status), time.Since(start))
+		if status >= http.StatusInternalServerError {
+			h.logger.WithContext(ctx).WithError(err).Error("Failed to list job incidents")
+			c.JSON(status, errorBody(c, requestID, code))
+			return
+		}
+		c.JSON(status, errorBody(c, requestID, code, err.Error()))
+		return
+	}
+
+	h.metrics.RecordHTTPRequest("GET", endpoint, "200", time.Since(start))
+	c.JSON(http.StatusOK, gin.H{
+		"data":       incidents,
+		"total":      len(incidents),
+		"request_id": requestID,
+	})
+}
@@ -0,0 +1,103 @@
+package delivery
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"etlgo/internal/domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+// IngestWebhook applies records an upstream system pushed to the webhook of
+// their source, signed with the source's secret together with the time they
+// were sent at, and updates the affected metrics immediately
+func (h *HTTPHandlers) IngestWebhook(c *gin.Context) {
+	start := time.Now()
+	h.metrics.IncHTTPRequestsInFlight()
+	defer h.metrics.DecHTTPRequestsInFlight()
+
+	requestID := requestIDOf(c)
+	ctx := c.Request.Context()
+	log := h.logger.WithContext(ctx)
+	const endpoint = "/ingest/webhook"
+
+	source := c.Param("source")
+	if source != domain.SourceAds && source != domain.SourceCRM {
+		h.metrics.RecordHTTPRequest("POST", endpoint, "400", time.Since(start))
+		c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_source", "ads, crm"))
+		return
+	}
+	if !h.webhooks.Enabled(source) {
+		h.metrics.RecordHTTPRequest("POST", endpoint, "404", time.Since(start))
+		c.JSON(http.StatusNotFound, errorBody(c, requestID, "webhook_not_configured", strings.ToUpper(source)))
+		return
+	}
+	if h.maintenanceService.Status().Enabled {
+		h.maintenanceError(c, "POST", endpoint, requestID, start, domain.ErrMaintenance)
+		return
+	}
+
+	payload, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, h.uploadMaxBytes))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			h.metrics.RecordHTTPRequest("POST", endpoint, "413", time.Since(start))
+			c.JSON(http.StatusRequestEntityTooLarge, errorBody(c, requestID, "upload_too_large", h.uploadMaxBytes))
+			return
+		}
+		h.metrics.RecordHTTPRequest("POST", endpoint, "400", time.Since(start))
+		c.JSON(http.StatusBadRequest, errorBody(c, requestID, "invalid_request_body", err.Error()))
+		return
+	}
+
+	summary, err := h.webhooks.Receive(ctx, source, payload, c.GetHeader("X-Timestamp"), c.GetHeader("X-Signature"))
+	if errors.Is(err, domain.ErrInvalidWebhookSignature) {
+		h.metrics.RecordHTTPRequest("POST", endpoint, "401", time.Since(start))
+		c.JSON(http.StatusUnauthorized, errorBody(c, requestID, "invalid_webhook_signature"))
+		return
+	}
+	if errors.Is(err, domain.ErrStaleWebhook) {
+		h.metrics.RecordHTTPRequest("POST", endpoint, "401", time.Since(start))
+		c.JSON(http.StatusUnauthorized, errorBody(c, requestID, "stale_webhook", h.webhooks.Tolerance().String()))
+		return
+	}
+	if errors.Is(err, domain.ErrQuotaExceeded) {
+		h.quotaError(c, "POST", endpoint, requestID, start, err)
+		return
+	}
+	if errors.Is(err, domain.ErrPushBatchTooLarge) {
+		h.metrics.RecordHTTPRequest("POST", endpoint, "413", time.Since(start))
+		c.JSON(http.StatusRequestEntityTooLarge, errorBody(c, requestID, "push_batch_too_large", err.Error()))
+		return
+	}
+	if errors.Is(err, domain.ErrParseThreshold) {
+		h.metrics.RecordHTTPRequest("POST", endpoint, "422", time.Since(start))
+		log.WithError(err).Warn("Webhook records rejected by parse policy")
+		body := errorBody(c, requestID, "parse_policy_violated", err.Error())
+		body["summary"] = summary
+		c.JSON(http.StatusUnprocessableEntity, body)
+		return
+	}
+	if err != nil {
+		status, code := errorStatus(err, "ingestion_failed")
+		h.metrics.RecordHTTPRequest("POST", endpoint, strconv.Itoa(status), time.Since(start))
+		if status >= http.StatusInternalServerError {
+			log.WithError(err).Error("Webhook ingestion failed")
+		}
+		c.JSON(status, errorBody(c, requestID, code, err.Error()))
+		return
+	}
+
+	h.metrics.RecordHTTPRequest("POST", endpoint, "200", time.Since(start))
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Webhook records applied",
+		"source":     source,
+		"summary":    summary,
+		"request_id": requestID,
+	})
+}
@@ -0,0 +1,119 @@
+package domain
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// types of suggested actions
+const (
+	ActionPause = "pause"
+)
+
+// why an action was suggested
+const (
+	ActionReasonCPACap   = "cpa_cap"
+	ActionReasonSpendCap = "daily_spend_cap"
+)
+
+// when campaigns are suggested for a pause: their daily CPA or spend stayed
+// above a cap for Days consecutive days. Zero caps are not checked.
+type ActionPolicy struct {
+	CPACap        Money `json:"cpa_cap,omitempty"`
+	DailySpendCap Money `json:"daily_spend_cap,omitempty"`
+	Days          int   `json:"days"`
+}
+
+func (p ActionPolicy) Enabled() bool {
+	return p.CPACap > 0 || p.DailySpendCap > 0
+}
+
+func (p ActionPolicy) Validate() error {
+	if p.CPACap < 0 || p.DailySpendCap < 0 {
+		return fmt.Errorf("action caps cannot be negative")
+	}
+	if p.Enabled() && p.Days < 1 {
+		return fmt.Errorf("action caps must be exceeded for at least 1 day, got %d", p.Days)
+	}
+	return nil
+}
+
+// the spend and leads of a campaign on one day. CPA is zero on days without
+// leads.
+type CampaignDay struct {
+	Date  time.Time `json:"date"`
+	Cost  Money     `json:"cost"`
+	Leads int       `json:"leads"`
+	CPA   Money     `json:"cpa"`
+}
+
+// reports whether the day counts against the CPA cap: it had spend and its
+// CPA exceeded the cap, or it had spend without any leads
+func (d CampaignDay) exceedsCPA(cap Money) bool {
+	if d.Cost <= 0 {
+		return false
+	}
+	return d.Leads == 0 || d.CPA > cap
+}
+
+// returns the reasons the policy suggests pausing a campaign whose daily
+// totals, oldest first and one per day, end on the evaluated day
+func (p ActionPolicy) Reasons(days []CampaignDay) []string {
+	if len(days) < p.Days {
+		return nil
+	}
+	recent := days[len(days)-p.Days:]
+
+	var reasons []string
+	if p.CPACap > 0 && !slices.ContainsFunc(recent, func(day CampaignDay) bool { return !day.exceedsCPA(p.CPACap) }) {
+		reasons = append(reasons, ActionReasonCPACap)
+	}
+	if p.DailySpendCap > 0 && !slices.ContainsFunc(recent, func(day CampaignDay) bool { return day.Cost <= p.DailySpendCap }) {
+		reasons = append(reasons, ActionReasonSpendCap)
+	}
+	return reasons
+}
+
+// a machine-readable suggestion for bid management tooling. The ID is
+// stable for the same suggestion about the same days, so consumers can
+// deduplicate.
+type Action struct {
+	ID          string        `json:"id"`
+	Type        string        `json:"type"`
+	Reason      string        `json:"reason"`
+	Channel     string        `json:"channel"`
+	CampaignID  string        `json:"campaign_id"`
+	UTMCampaign string        `json:"utm_campaign"`
+	UTMSource   string        `json:"utm_source"`
+	UTMMedium   string        `json:"utm_medium"`
+	Cap         Money         `json:"cap"`
+	Days        []CampaignDay `json:"days"`
+	Through     time.Time     `json:"through"`
+	SuggestedAt time.Time     `json:"suggested_at"`
+}
+
+// returns the value of the dimension of the campaign the action is about,
+// or "" for ad groups, which actions do not carry
+func (a Action) DimensionValue(dimension string) string {
+	switch dimension {
+	case DimensionChannel:
+		return a.Channel
+	case DimensionCampaignID:
+		return a.CampaignID
+	case DimensionUTMCampaign:
+		return a.UTMCampaign
+	case DimensionUTMSource:
+		return a.UTMSource
+	case DimensionUTMMedium:
+		return a.UTMMedium
+	}
+	return ""
+}
+
+// the actions suggested as of a day under a policy
+type ActionFeed struct {
+	Through time.Time    `json:"through"`
+	Policy  ActionPolicy `json:"policy"`
+	Actions []Action     `json:"actions"`
+}
@@ -3,15 +3,19 @@ package domain
 import "time"
 
 type AdPerformance struct {
-	Date        string  `json:"date"`
-	CampaignID  string  `json:"campaign_id"`
-	Channel     string  `json:"channel"`
-	Clicks      int     `json:"clicks"`
-	Impressions int     `json:"impressions"`
-	Cost        float64 `json:"cost"`
-	UTMCampaign string  `json:"utm_campaign"`
-	UTMSource   string  `json:"utm_source"`
-	UTMMedium   string  `json:"utm_medium"`
+	Date        string `json:"date"`
+	CampaignID  string `json:"campaign_id"`
+	Channel     string `json:"channel"`
+	Clicks      int    `json:"clicks"`
+	Impressions int    `json:"impressions"`
+	Cost        Money  `json:"cost"`
+	UTMCampaign string `json:"utm_campaign"`
+	UTMSource   string `json:"utm_source"`
+	UTMMedium   string `json:"utm_medium"`
+
+	// optional finer grained enrichment of platforms that report it
+	AdGroupID    string   `json:"ad_group_id,omitempty"`
+	QualityScore *float64 `json:"quality_score,omitempty"` // 1 to 10
 }
 
 type AdData struct {
@@ -20,6 +24,11 @@ type AdData struct {
 			Performance []AdPerformance `json:"performance"`
 		} `json:"ads"`
 	} `json:"external"`
+
+	// rows that could not be decoded
+	Rejected []QuarantinedRecord `json:"-"`
+	// the object store files the records were read from
+	Objects []SourceObject `json:"-"`
 }
 
 type ProcessedAdData struct {
@@ -28,13 +37,39 @@ type ProcessedAdData struct {
 	Channel     string    `json:"channel"`
 	Clicks      int       `json:"clicks"`
 	Impressions int       `json:"impressions"`
-	Cost        float64   `json:"cost"`
+	Cost        Money     `json:"cost"`
 	UTMCampaign string    `json:"utm_campaign"`
 	UTMSource   string    `json:"utm_source"`
 	UTMMedium   string    `json:"utm_medium"`
+
+	AdGroupID    string   `json:"ad_group_id,omitempty"`
+	QualityScore *float64 `json:"quality_score,omitempty"`
+
+	Flags       []string  `json:"flags,omitempty"`
+	Fingerprint string    `json:"fingerprint,omitempty"` // of the business fields
 	ProcessedAt time.Time `json:"processed_at"`
 }
 
+// returns the value of the dimension, e.g. channel, or "" for dimensions
+// ads do not carry
+func (a ProcessedAdData) DimensionValue(dimension string) string {
+	switch dimension {
+	case DimensionChannel:
+		return a.Channel
+	case DimensionCampaignID:
+		return a.CampaignID
+	case DimensionUTMCampaign:
+		return a.UTMCampaign
+	case DimensionUTMSource:
+		return a.UTMSource
+	case DimensionUTMMedium:
+		return a.UTMMedium
+	case DimensionAdGroupID:
+		return a.AdGroupID
+	}
+	return ""
+}
+
 // UTM combination for data correlation
 type UTMKey struct {
 	Campaign string
@@ -46,3 +81,29 @@ type UTMKey struct {
 func (u UTMKey) String() string {
 	return u.Campaign + "|" + u.Source + "|" + u.Medium
 }
+
+// reports whether a quality score is on the 1 to 10 scale ad platforms use
+func ValidQualityScore(score float64) bool {
+	return score >= 1 && score <= 10
+}
+
+// averages the quality scores of ads weighted by their impressions. Ads
+// without impressions weigh as one so their scores still count.
+type QualityScoreAverage struct {
+	sum, weight float64
+}
+
+// adds the score of an ad with the impressions
+func (a *QualityScoreAverage) Add(score float64, impressions int) {
+	weight := float64(max(impressions, 1))
+	a.sum += score * weight
+	a.weight += weight
+}
+
+// returns the average, 0 when no ad had a score
+func (a QualityScoreAverage) Value() float64 {
+	if a.weight == 0 {
+		return 0
+	}
+	return a.sum / a.weight
+}
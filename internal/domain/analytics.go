@@ -0,0 +1,31 @@
+package domain
+
+import "time"
+
+// one row of a web analytics report: sessions and conversions of a day for
+// a campaign, source and medium
+type AnalyticsRow struct {
+	Date        string `json:"date"`
+	UTMCampaign string `json:"utm_campaign"`
+	UTMSource   string `json:"utm_source"`
+	UTMMedium   string `json:"utm_medium"`
+	Sessions    int    `json:"sessions"`
+	Conversions int    `json:"conversions"`
+}
+
+type AnalyticsData struct {
+	Rows []AnalyticsRow
+
+	// rows that could not be decoded
+	Rejected []QuarantinedRecord
+}
+
+type ProcessedAnalyticsRow struct {
+	Date        time.Time `json:"date"`
+	UTMCampaign string    `json:"utm_campaign"`
+	UTMSource   string    `json:"utm_source"`
+	UTMMedium   string    `json:"utm_medium"`
+	Sessions    int       `json:"sessions"`
+	Conversions int       `json:"conversions"`
+	ProcessedAt time.Time `json:"processed_at"`
+}
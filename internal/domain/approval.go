@@ -0,0 +1,97 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+// ErrApprovalSelf is returned when operators decide on their own request
+var ErrApprovalSelf = NewError(ErrForbidden, "approval requires a second operator")
+
+// operations that can require a second operator's approval
+const (
+	ActionRollback = "rollback" // rolling back an ingest run or push
+	ActionBackfill = "backfill" // an ingest run reading a source's whole history, or reaching back past JOB_BACKFILL_DAYS
+)
+
+// states of an approval request. Approved requests are executed right away
+// and end up executed or failed.
+const (
+	ApprovalPending  = "pending"
+	ApprovalApproved = "approved" // executing
+	ApprovalRejected = "rejected"
+	ApprovalExecuted = "executed"
+	ApprovalFailed   = "failed"
+)
+
+// a destructive or large operation waiting for, or decided by, a second
+// operator
+type ApprovalRequest struct {
+	ID          string     `json:"id"`
+	Action      string     `json:"action"`
+	Description string     `json:"description"`
+	RequestedBy string     `json:"requested_by"`
+	RequestedAt time.Time  `json:"requested_at"`
+	Status      string     `json:"status"`
+	DecidedBy   string     `json:"decided_by,omitempty"`
+	DecidedAt   *time.Time `json:"decided_at,omitempty"`
+	Reason      string     `json:"reason,omitempty"` // given when rejecting
+
+	// parameters of the operation
+	IngestRunID string            `json:"ingest_run_id,omitempty"` // rollback
+	Pipeline    string            `json:"pipeline,omitempty"`      // backfill
+	Since       *time.Time        `json:"since,omitempty"`         // backfill
+	ParsePolicy *ParsePolicy      `json:"parse_policy,omitempty"`  // backfill
+	Tags        map[string]string `json:"tags,omitempty"`          // backfill
+
+	// outcome of the executed operation
+	Result any    `json:"result,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// returns the approval request of rolling back an ingest run or push
+func NewRollbackApproval(ingestRunID string) ApprovalRequest {
+	return ApprovalRequest{
+		Action:      ActionRollback,
+		Description: fmt.Sprintf("Roll back ingestion %s", ingestRunID),
+		IngestRunID: ingestRunID,
+	}
+}
+
+// returns the approval request of a backfill: a run of the named pipeline,
+// or an ingest run reaching back to since, or over the whole history
+// without it
+func NewBackfillApproval(pipeline string, since *time.Time, parsePolicy *ParsePolicy, tags map[string]string) ApprovalRequest {
+	description := "Backfill ingestion of the sources' whole history"
+	switch {
+	case pipeline != "":
+		description = fmt.Sprintf("Backfill run of pipeline %s", pipeline)
+	case since != nil:
+		description = fmt.Sprintf("Backfill ingestion since %s", since.Format("2006-01-02"))
+	}
+	return ApprovalRequest{
+		Action:      ActionBackfill,
+		Description: description,
+		Pipeline:    pipeline,
+		Since:       since,
+		ParsePolicy: parsePolicy,
+		Tags:        tags,
+	}
+}
+
+// checks the actions that require approval
+func ValidateApprovalActions(actions []string) error {
+	for _, action := range actions {
+		if action != ActionRollback && action != ActionBackfill {
+			return fmt.Errorf("unsupported approval action %q", action)
+		}
+	}
+	return nil
+}
+
+// selects approval requests; empty fields match everything
+type ApprovalFilter struct {
+	Status string
+	Action string
+	Limit  int // 0 returns every match
+}
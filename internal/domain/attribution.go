@@ -0,0 +1,121 @@
+package domain
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// how closed won revenue is attributed to UTM combinations
+const (
+	AttributionSingleKey = "single_key" // all of it to the opportunity's own UTM
+	AttributionEqual     = "equal"      // split equally across the touches
+	AttributionTimeDecay = "time_decay" // touches closer to the opportunity weigh more
+	AttributionUShaped   = "u_shaped"   // 40% first touch, 40% last, 20% across the rest
+)
+
+// share of a U-shaped split given to each of the first and last touches
+const uShapedEndShare = 0.4
+
+// a normalized ad touch of an opportunity
+type Touchpoint struct {
+	UTMCampaign string    `json:"utm_campaign"`
+	UTMSource   string    `json:"utm_source"`
+	UTMMedium   string    `json:"utm_medium"`
+	TouchedAt   time.Time `json:"touched_at"`
+}
+
+// returns the UTM combination of the touch
+func (t Touchpoint) UTM() UTMKey {
+	return UTMKey{Campaign: t.UTMCampaign, Source: t.UTMSource, Medium: t.UTMMedium}
+}
+
+// the attribution model of metric calculation. HalfLife is how much older a
+// touch is when it weighs half as much under time decay.
+type AttributionModel struct {
+	Mode     string        `json:"mode"`
+	HalfLife time.Duration `json:"half_life,omitempty"`
+}
+
+// Validate checks the model and fills in defaults
+func (m *AttributionModel) Validate() error {
+	switch m.Mode {
+	case "":
+		m.Mode = AttributionSingleKey
+	case AttributionSingleKey, AttributionEqual, AttributionUShaped:
+	case AttributionTimeDecay:
+		if m.HalfLife <= 0 {
+			return fmt.Errorf("time_decay attribution needs a positive half life")
+		}
+	default:
+		return fmt.Errorf("unsupported attribution mode %q, expected %s, %s, %s or %s",
+			m.Mode, AttributionSingleKey, AttributionEqual, AttributionTimeDecay, AttributionUShaped)
+	}
+	return nil
+}
+
+// Credits splits an opportunity across the UTM combinations of its touches,
+// returning weights that sum to one. Only touches of combinations accepted
+// by eligible count, typically those with ad data. Opportunities without
+// eligible touches, and every opportunity in single_key mode, credit their
+// own UTM combination in full.
+func (m AttributionModel) Credits(opp ProcessedOpportunity, eligible func(UTMKey) bool) map[UTMKey]float64 {
+	own := UTMKey{Campaign: opp.UTMCampaign, Source: opp.UTMSource, Medium: opp.UTMMedium}
+	if m.Mode == AttributionSingleKey || m.Mode == "" {
+		return map[UTMKey]float64{own: 1}
+	}
+
+	var touches []Touchpoint
+	for _, touch := range opp.Touches {
+		if eligible == nil || eligible(touch.UTM()) {
+			touches = append(touches, touch)
+		}
+	}
+	if len(touches) == 0 {
+		return map[UTMKey]float64{own: 1}
+	}
+	sort.SliceStable(touches, func(i, j int) bool {
+		return touches[i].TouchedAt.Before(touches[j].TouchedAt)
+	})
+
+	weights := make([]float64, len(touches))
+	switch m.Mode {
+	case AttributionTimeDecay:
+		// decay from the opportunity, or the last touch when it is later
+		reference := opp.CreatedAt
+		if last := touches[len(touches)-1].TouchedAt; last.After(reference) {
+			reference = last
+		}
+		for i, touch := range touches {
+			age := reference.Sub(touch.TouchedAt)
+			weights[i] = math.Exp2(-age.Hours() / m.HalfLife.Hours())
+		}
+	case AttributionUShaped:
+		switch n := len(touches); n {
+		case 1:
+			weights[0] = 1
+		case 2:
+			weights[0], weights[1] = 0.5, 0.5
+		default:
+			weights[0], weights[n-1] = uShapedEndShare, uShapedEndShare
+			for i := 1; i < n-1; i++ {
+				weights[i] = (1 - 2*uShapedEndShare) / float64(n-2)
+			}
+		}
+	default:
+		for i := range weights {
+			weights[i] = 1
+		}
+	}
+
+	var total float64
+	for _, weight := range weights {
+		total += weight
+	}
+	credits := make(map[UTMKey]float64, len(touches))
+	for i, touch := range touches {
+		credits[touch.UTM()] += weights[i] / total
+	}
+	return credits
+}
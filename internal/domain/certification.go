@@ -0,0 +1,115 @@
+package domain
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"slices"
+	"time"
+)
+
+// the number and checksum of a dataset's records. The checksum is the
+// SHA-256 of the records' JSON encodings, one per line in sorted order, so
+// it doesn't depend on the order the upstream returned them in.
+type DataChecksum struct {
+	Records int    `json:"records"`
+	SHA256  string `json:"sha256"`
+}
+
+func ChecksumOf[T any](records []T) DataChecksum {
+	lines := make([][]byte, 0, len(records))
+	for _, record := range records {
+		line, err := json.Marshal(record)
+		if err != nil {
+			// the records are plain data, they always encode
+			panic(err)
+		}
+		lines = append(lines, line)
+	}
+	slices.SortFunc(lines, bytes.Compare)
+
+	hash := sha256.New()
+	for _, line := range lines {
+		hash.Write(line)
+		hash.Write([]byte{'\n'})
+	}
+	return DataChecksum{Records: len(records), SHA256: hex.EncodeToString(hash.Sum(nil))}
+}
+
+// the build of the service that certified a run
+type CertifiedBuild struct {
+	Version  string `json:"version"`
+	Commit   string `json:"commit"`
+	Modified bool   `json:"modified,omitempty"`
+}
+
+// the validation results of a certified run
+type CertifiedValidation struct {
+	Parsing   map[string]*ParseReport `json:"parsing"`
+	Values    map[string]*ValueReport `json:"values,omitempty"`
+	Anomalies []RunAnomaly            `json:"anomalies,omitempty"`
+	Gaps      []DataGap               `json:"gaps,omitempty"`
+}
+
+// the signature of a certification over its JSON without the signature
+type CertificationSignature struct {
+	Algorithm string `json:"algorithm"`
+	KeyID     string `json:"key_id"`
+	PublicKey string `json:"public_key"` // base64
+	Value     string `json:"value"`      // base64
+}
+
+// an audit artifact of a completed run: what it extracted and calculated,
+// how the records validated, and with which configuration and build. It is
+// unsigned when no signing key is configured.
+type RunCertification struct {
+	RunID       string                  `json:"run_id"`
+	Tenant      string                  `json:"tenant"`
+	Pipeline    string                  `json:"pipeline,omitempty"`
+	Since       *time.Time              `json:"since,omitempty"`
+	Sources     []string                `json:"sources,omitempty"`
+	StartedAt   time.Time               `json:"started_at"`
+	CompletedAt time.Time               `json:"completed_at"`
+	Data        map[string]DataChecksum `json:"data"`
+	Validation  CertifiedValidation     `json:"validation"`
+	ConfigHash  string                  `json:"config_hash"`
+	Build       CertifiedBuild          `json:"build"`
+	IssuedAt    time.Time               `json:"issued_at"`
+	Signature   *CertificationSignature `json:"signature,omitempty"`
+}
+
+// returns the certification of a completed run, before it is signed
+func NewRunCertification(record RunRecord, configHash string, build CertifiedBuild, issuedAt time.Time) *RunCertification {
+	return &RunCertification{
+		RunID:       record.ID,
+		Tenant:      record.Tenant,
+		Pipeline:    record.Pipeline,
+		Since:       record.Since,
+		Sources:     record.Sources,
+		StartedAt:   record.StartedAt,
+		CompletedAt: record.CompletedAt,
+		Data:        record.Checksums,
+		Validation: CertifiedValidation{
+			Parsing:   record.Parsing,
+			Values:    record.Values,
+			Anomalies: record.Anomalies,
+			Gaps:      record.Gaps,
+		},
+		ConfigHash: configHash,
+		Build:      build,
+		IssuedAt:   issuedAt,
+	}
+}
+
+// returns the bytes the signature covers
+func (c RunCertification) SignedPayload() ([]byte, error) {
+	c.Signature = nil
+	return json.Marshal(c)
+}
+
+// certifies completed runs
+type RunCertifier interface {
+	Certify(ctx context.Context, record RunRecord) (*RunCertification, error)
+}
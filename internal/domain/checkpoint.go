@@ -0,0 +1,23 @@
+package domain
+
+import "time"
+
+// the last successful extraction of a source for a tenant. Runs without a
+// window of their own extract the source again from the checkpoint's
+// watermark.
+type Checkpoint struct {
+	Source      string    `json:"source"`
+	Tenant      string    `json:"tenant,omitempty"`
+	ExtractedAt time.Time `json:"extracted_at"`
+	RunID       string    `json:"run_id"`
+}
+
+// returns the day the next run extracts the source from. The day of the
+// extraction itself is extracted again since its records may have been
+// incomplete.
+func (c Checkpoint) Watermark() time.Time {
+	return c.ExtractedAt.UTC().Truncate(24 * time.Hour)
+}
+
+// sources whose extractions are checkpointed
+var CheckpointedSources = []string{SourceAds, SourceCRM}
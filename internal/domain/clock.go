@@ -0,0 +1,76 @@
+package domain
+
+import (
+	"sync"
+	"time"
+)
+
+// tells the time to services and repositories, so timestamps and default
+// windows can be pinned in tests and shifted for backfills. Elapsed time,
+// such as request latencies and deadlines, and the timestamps of signed
+// requests are measured on the system clock regardless.
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock is the real time
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// a clock standing still at a set time until moved
+type FrozenClock struct {
+	mutex sync.RWMutex
+	at    time.Time
+}
+
+// creates a clock frozen at the given time
+func NewFrozenClock(at time.Time) *FrozenClock {
+	return &FrozenClock{at: at}
+}
+
+func (c *FrozenClock) Now() time.Time {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.at
+}
+
+// moves the clock to the given time
+func (c *FrozenClock) Set(at time.Time) {
+	c.mutex.Lock()
+	c.at = at
+	c.mutex.Unlock()
+}
+
+// moves the clock forward by d
+func (c *FrozenClock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	c.at = c.at.Add(d)
+	c.mutex.Unlock()
+}
+
+// a clock running with the system clock, shifted by a fixed offset, e.g.
+// -30 days to backfill as of a month ago
+type OffsetClock struct {
+	Offset time.Duration
+}
+
+func (c OffsetClock) Now() time.Time {
+	return time.Now().Add(c.Offset)
+}
+
+// returns the clock of the settings: frozen at frozenAt when set, else
+// shifted by offset when non-zero, else the system clock
+func NewClock(frozenAt *time.Time, offset time.Duration) Clock {
+	switch {
+	case frozenAt != nil:
+		return NewFrozenClock(*frozenAt)
+	case offset != 0:
+		return OffsetClock{Offset: offset}
+	}
+	return SystemClock
+}
@@ -0,0 +1,123 @@
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// returned for queries of a metric hidden from the caller's role
+var ErrColumnHidden = NewError(ErrForbidden, "hidden from the API key's role")
+
+// groups of metric columns a role can be denied by name: every amount
+// derived from cost, and every amount derived from revenue
+var ColumnGroups = map[string][]string{
+	"cost":    {"cost", "cpc", "cpa", "roas"},
+	"revenue": {"revenue", "attributed_revenue", "pipeline_value", "expected_revenue", "roas"},
+}
+
+// a role of API keys and the metric columns hidden from it, e.g. a volume
+// role seeing clicks and leads but no cost or revenue. Hidden lists metric
+// columns or the groups of ColumnGroups.
+type Role struct {
+	Name   string   `json:"name"`
+	Hidden []string `json:"hidden"`
+}
+
+func (r Role) Validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("role name is required")
+	}
+	for _, column := range r.Hidden {
+		if _, ok := ColumnGroups[column]; !ok && !slices.Contains(TimeSeriesMetrics, column) {
+			return fmt.Errorf("%s: unsupported hidden column %q, expected a group (%s) or one of: %s", r.Name, column,
+				strings.Join(slices.Sorted(maps.Keys(ColumnGroups)), ", "), strings.Join(TimeSeriesMetrics, ", "))
+		}
+	}
+	return nil
+}
+
+// returns the columns hidden from the role, groups expanded
+func (r Role) Mask() ColumnMask {
+	mask := ColumnMask{}
+	for _, column := range r.Hidden {
+		if group, ok := ColumnGroups[column]; ok {
+			for _, member := range group {
+				mask[member] = true
+			}
+			continue
+		}
+		mask[column] = true
+	}
+	return mask
+}
+
+// roles by name
+type Roles map[string]Role
+
+// validates the roles, refusing duplicate names
+func NewRoles(roles []Role) (Roles, error) {
+	byName := make(Roles, len(roles))
+	for _, role := range roles {
+		if err := role.Validate(); err != nil {
+			return nil, err
+		}
+		if _, exists := byName[role.Name]; exists {
+			return nil, fmt.Errorf("duplicate role name %q", role.Name)
+		}
+		byName[role.Name] = role
+	}
+	return byName, nil
+}
+
+// the metric columns removed from a caller's responses and exports. An
+// empty mask hides nothing.
+type ColumnMask map[string]bool
+
+// returns the hidden columns in order
+func (m ColumnMask) Columns() []string {
+	return slices.Sorted(maps.Keys(m))
+}
+
+// returns ErrColumnHidden when the metric is hidden
+func (m ColumnMask) Check(metric string) error {
+	if m[metric] {
+		return fmt.Errorf("%w: metric %q", ErrColumnHidden, metric)
+	}
+	return nil
+}
+
+// deletes the hidden columns of the record
+func (m ColumnMask) Record(record ExportRecord) {
+	for column := range m {
+		delete(record, column)
+	}
+}
+
+// removes the hidden columns from the JSON object. Objects of masked rows
+// are written with their fields in alphabetical order.
+func (m ColumnMask) strip(raw []byte) ([]byte, error) {
+	if len(m) == 0 {
+		return raw, nil
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+	for column := range m {
+		delete(fields, column)
+	}
+	return json.Marshal(fields)
+}
+
+// returns the report's columns that are not hidden
+func (m ColumnMask) ReportColumns(columns []ReportColumn) []ReportColumn {
+	if len(m) == 0 {
+		return columns
+	}
+	return slices.DeleteFunc(slices.Clone(columns), func(column ReportColumn) bool {
+		return m[column.Name]
+	})
+}
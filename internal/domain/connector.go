@@ -0,0 +1,145 @@
+package domain
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// formats of connector responses
+const (
+	ConnectorFormatJSON   = "json"
+	ConnectorFormatNDJSON = "ndjson"
+	ConnectorFormatCSV    = "csv"
+)
+
+// connector authentication types
+const (
+	ConnectorAuthBearer = "bearer"
+	ConnectorAuthBasic  = "basic"
+	ConnectorAuthHeader = "header"
+)
+
+// a source declared in config rather than in code: an upstream API whose
+// records are extracted with every run alongside the built-in ads and CRM
+// sources. Type is the kind of records it serves, ads or crm, and Format
+// how its responses are encoded, json by default. Responses are decoded
+// with Mapping, or the field mapping of its type when unset.
+type Connector struct {
+	Name    string         `json:"name"`
+	Type    string         `json:"type"`
+	URL     string         `json:"url"`
+	Auth    *ConnectorAuth `json:"auth,omitempty"`
+	Format  string         `json:"format,omitempty"`
+	Mapping *SourceMapping `json:"mapping,omitempty"`
+}
+
+// how a connector authenticates with its upstream: a bearer Token, a basic
+// Username and Password, or Value in the Header of type header
+type ConnectorAuth struct {
+	Type     string `json:"type"`
+	Token    string `json:"token,omitempty"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	Header   string `json:"header,omitempty"`
+	Value    string `json:"value,omitempty"`
+}
+
+// validates the connector. The names of the built-in sources are taken.
+func (c Connector) Validate() error {
+	switch {
+	case c.Name == "":
+		return fmt.Errorf("connector name is required")
+	case strings.ContainsAny(c.Name, " \t\r\n"):
+		return fmt.Errorf("connector name %q must not contain whitespace", c.Name)
+	case c.Name == SourceAds || c.Name == SourceCRM:
+		return fmt.Errorf("connector name %q is the built-in source's", c.Name)
+	case c.Type != SourceAds && c.Type != SourceCRM:
+		return fmt.Errorf("%s: connector type must be %s or %s", c.Name, SourceAds, SourceCRM)
+	case c.URL == "":
+		return fmt.Errorf("%s: connector requires a url", c.Name)
+	}
+	switch c.Format {
+	case "", ConnectorFormatJSON, ConnectorFormatNDJSON, ConnectorFormatCSV:
+	default:
+		return fmt.Errorf("%s: connector format must be %s, %s or %s", c.Name, ConnectorFormatJSON, ConnectorFormatNDJSON, ConnectorFormatCSV)
+	}
+	if c.Mapping != nil && c.Mapping.Pagination != nil && c.Format != "" && c.Format != ConnectorFormatJSON {
+		return fmt.Errorf("%s: only json connectors are paginated", c.Name)
+	}
+	if c.Auth != nil {
+		if err := c.Auth.validate(); err != nil {
+			return fmt.Errorf("%s: auth: %w", c.Name, err)
+		}
+	}
+	return nil
+}
+
+func (a ConnectorAuth) validate() error {
+	switch a.Type {
+	case ConnectorAuthBearer:
+		if a.Token == "" {
+			return fmt.Errorf("bearer requires a token")
+		}
+	case ConnectorAuthBasic:
+		if a.Username == "" {
+			return fmt.Errorf("basic requires a username")
+		}
+	case ConnectorAuthHeader:
+		if a.Header == "" || a.Value == "" {
+			return fmt.Errorf("header requires a header and a value")
+		}
+	default:
+		return fmt.Errorf("type must be %s, %s or %s", ConnectorAuthBearer, ConnectorAuthBasic, ConnectorAuthHeader)
+	}
+	return nil
+}
+
+// returns the request headers authenticating the connector, none without
+// auth
+func (c Connector) Headers() map[string]string {
+	if c.Auth == nil {
+		return nil
+	}
+	switch c.Auth.Type {
+	case ConnectorAuthBearer:
+		return map[string]string{"Authorization": "Bearer " + c.Auth.Token}
+	case ConnectorAuthBasic:
+		credentials := base64.StdEncoding.EncodeToString([]byte(c.Auth.Username + ":" + c.Auth.Password))
+		return map[string]string{"Authorization": "Basic " + credentials}
+	case ConnectorAuthHeader:
+		return map[string]string{c.Auth.Header: c.Auth.Value}
+	}
+	return nil
+}
+
+// returns the connector as listed by the API, without its credentials
+func (c Connector) Info() ConnectorInfo {
+	info := ConnectorInfo{Name: c.Name, Type: c.Type, URL: c.URL, Format: c.Format}
+	if parsed, err := url.Parse(c.URL); err == nil {
+		// query parameters and user info may hold credentials
+		parsed.User, parsed.RawQuery = nil, ""
+		info.URL = parsed.String()
+	}
+	if info.Format == "" {
+		info.Format = ConnectorFormatJSON
+	}
+	if c.Auth != nil {
+		info.Auth = c.Auth.Type
+	}
+	return info
+}
+
+// a registered connector as listed by the API. Built-in sources are
+// extracted with their Extractor, api, csv or object; native connectors
+// name theirs, e.g. meta or salesforce.
+type ConnectorInfo struct {
+	Name      string `json:"name"`
+	Type      string `json:"type"`
+	BuiltIn   bool   `json:"built_in"`
+	Extractor string `json:"extractor,omitempty"`
+	URL       string `json:"url,omitempty"`
+	Format    string `json:"format,omitempty"`
+	Auth      string `json:"auth,omitempty"`
+}
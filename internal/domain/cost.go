@@ -0,0 +1,134 @@
+package domain
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// run stages whose wall time is accounted
+const (
+	StagePrewarm   = "prewarm"
+	StageExtract   = "extract"
+	StageTransform = "transform"
+	StageLoad      = "load"
+	StageMetrics   = "metrics"
+)
+
+// accounted stages in run order
+var CostStages = []string{StagePrewarm, StageExtract, StageTransform, StageLoad, StageMetrics}
+
+// kinds of accounted work
+const (
+	CostKindRun  = "run"
+	CostKindPush = "push"
+)
+
+// resources one run or push consumed, for charging internal teams back
+type RunCost struct {
+	WallTimeSeconds  float64            `json:"wall_time_seconds"`
+	StageSeconds     map[string]float64 `json:"stage_seconds"`
+	BytesDownloaded  int64              `json:"bytes_downloaded"`
+	APICalls         int64              `json:"api_calls"`
+	RecordsProcessed int64              `json:"records_processed"`
+}
+
+// a tenant's accounted resources in one UTC month
+type CostUsage struct {
+	Tenant           string             `json:"tenant"`
+	Period           string             `json:"period"`
+	Runs             int64              `json:"runs"`
+	Pushes           int64              `json:"pushes"`
+	WallTimeSeconds  float64            `json:"wall_time_seconds"`
+	StageSeconds     map[string]float64 `json:"stage_seconds"`
+	BytesDownloaded  int64              `json:"bytes_downloaded"`
+	APICalls         int64              `json:"api_calls"`
+	RecordsProcessed int64              `json:"records_processed"`
+}
+
+// collects the cost of a run while its stages execute, including from
+// concurrent fetches. A nil meter ignores everything so code paths outside
+// a run need no checks.
+type CostMeter struct {
+	start time.Time
+	cost  RunCost
+	mutex sync.Mutex
+}
+
+// starts metering a run
+func NewCostMeter() *CostMeter {
+	return &CostMeter{
+		start: time.Now(),
+		cost:  RunCost{StageSeconds: make(map[string]float64)},
+	}
+}
+
+type costMeterKey struct{}
+
+// returns a context carrying the meter
+func WithCostMeter(ctx context.Context, meter *CostMeter) context.Context {
+	return context.WithValue(ctx, costMeterKey{}, meter)
+}
+
+// returns the meter carried by the context, or nil
+func CostMeterFromContext(ctx context.Context) *CostMeter {
+	meter, _ := ctx.Value(costMeterKey{}).(*CostMeter)
+	return meter
+}
+
+// counts an upstream API call
+func (m *CostMeter) AddAPICall() {
+	if m == nil {
+		return
+	}
+	m.mutex.Lock()
+	m.cost.APICalls++
+	m.mutex.Unlock()
+}
+
+// counts bytes downloaded from an upstream API
+func (m *CostMeter) AddDownload(bytes int64) {
+	if m == nil {
+		return
+	}
+	m.mutex.Lock()
+	m.cost.BytesDownloaded += bytes
+	m.mutex.Unlock()
+}
+
+// adds wall time spent in a stage
+func (m *CostMeter) AddStage(stage string, d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.mutex.Lock()
+	m.cost.StageSeconds[stage] += d.Seconds()
+	m.mutex.Unlock()
+}
+
+// counts processed records
+func (m *CostMeter) AddRecords(n int) {
+	if m == nil {
+		return
+	}
+	m.mutex.Lock()
+	m.cost.RecordsProcessed += int64(n)
+	m.mutex.Unlock()
+}
+
+// returns the cost so far, with the wall time since the meter started
+func (m *CostMeter) Snapshot() RunCost {
+	if m == nil {
+		return RunCost{}
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	cost := m.cost
+	cost.WallTimeSeconds = time.Since(m.start).Seconds()
+	cost.StageSeconds = make(map[string]float64, len(m.cost.StageSeconds))
+	for stage, seconds := range m.cost.StageSeconds {
+		cost.StageSeconds[stage] = seconds
+	}
+	return cost
+}
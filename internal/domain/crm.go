@@ -1,6 +1,9 @@
 package domain
 
 import (
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -17,11 +20,23 @@ type Opportunity struct {
 	OpportunityID string           `json:"opportunity_id"`
 	ContactEmail  string           `json:"contact_email"`
 	Stage         OpportunityStage `json:"stage"`
-	Amount        float64          `json:"amount"`
+	Amount        Money            `json:"amount"`
+	Currency      string           `json:"currency,omitempty"`
+	Probability   *float64         `json:"probability,omitempty"` // percent
 	CreatedAt     string           `json:"created_at"`
+	ClosedAt      string           `json:"closed_at,omitempty"`
 	UTMCampaign   string           `json:"utm_campaign"`
 	UTMSource     string           `json:"utm_source"`
 	UTMMedium     string           `json:"utm_medium"`
+	Touches       []Touch          `json:"touches,omitempty"`
+}
+
+// an ad interaction on the way to an opportunity, as reported by the CRM
+type Touch struct {
+	UTMCampaign string `json:"utm_campaign"`
+	UTMSource   string `json:"utm_source"`
+	UTMMedium   string `json:"utm_medium"`
+	TouchedAt   string `json:"touched_at"`
 }
 
 type CRMData struct {
@@ -30,18 +45,33 @@ type CRMData struct {
 			Opportunities []Opportunity `json:"opportunities"`
 		} `json:"crm"`
 	} `json:"external"`
+
+	// rows that could not be decoded
+	Rejected []QuarantinedRecord `json:"-"`
+	// the object store files the records were read from
+	Objects []SourceObject `json:"-"`
 }
 
 type ProcessedOpportunity struct {
 	OpportunityID string           `json:"opportunity_id"`
 	ContactEmail  string           `json:"contact_email"`
 	Stage         OpportunityStage `json:"stage"`
-	Amount        float64          `json:"amount"`
+	Amount        Money            `json:"amount"` // in the base currency
 	CreatedAt     time.Time        `json:"created_at"`
+	ClosedAt      *time.Time       `json:"closed_at,omitempty"`
 	UTMCampaign   string           `json:"utm_campaign"`
 	UTMSource     string           `json:"utm_source"`
 	UTMMedium     string           `json:"utm_medium"`
-	ProcessedAt   time.Time        `json:"processed_at"`
+	Touches       []Touchpoint     `json:"touches,omitempty"`
+	Probability   *float64         `json:"probability,omitempty"` // percent
+
+	// the amount as reported, when the CRM reported another currency
+	Currency       string `json:"currency,omitempty"`
+	OriginalAmount Money  `json:"original_amount,omitempty"`
+
+	Flags       []string  `json:"flags,omitempty"`
+	Fingerprint string    `json:"fingerprint,omitempty"` // of the business fields
+	ProcessedAt time.Time `json:"processed_at"`
 }
 
 func (o ProcessedOpportunity) IsLead() bool {
@@ -62,3 +92,117 @@ func (o ProcessedOpportunity) IsClosedWon() bool {
 func (o ProcessedOpportunity) IsClosedLost() bool {
 	return o.Stage == StageClosedLost
 }
+
+// reports whether the opportunity is still in the pipeline, in a funnel
+// stage before closed won
+func (o ProcessedOpportunity) IsOpen() bool {
+	return o.Stage != StageClosedWon && o.Stage != StageClosedLost
+}
+
+// returns the amount the open opportunity is expected to bring in: its
+// amount weighted by its win probability, or by the stage's default when
+// the CRM reported none. Closed opportunities and open ones without any
+// probability expect nothing.
+func (o ProcessedOpportunity) ExpectedRevenue(defaults StageProbabilities) Money {
+	if !o.IsOpen() {
+		return 0
+	}
+	probability, ok := defaults[o.Stage]
+	if o.Probability != nil {
+		probability, ok = *o.Probability, true
+	}
+	if !ok {
+		return 0
+	}
+	return o.Amount.Scale(probability / 100)
+}
+
+// default win probabilities in percent of the open stages
+type StageProbabilities map[OpportunityStage]float64
+
+// parses "stage=percent" pairs of the funnel's open stages, e.g.
+// "lead=10,opportunity=40"
+func ParseStageProbabilities(spec string, funnel Funnel) (StageProbabilities, error) {
+	probabilities := StageProbabilities{}
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		stage, value, ok := strings.Cut(pair, "=")
+		percent, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if !ok || err != nil {
+			return nil, fmt.Errorf("invalid stage probability %q: expected stage=percent", pair)
+		}
+		opp := ProcessedOpportunity{Stage: OpportunityStage(strings.TrimSpace(stage))}
+		if !funnel.Allows(opp.Stage) || !opp.IsOpen() {
+			return nil, fmt.Errorf("stage %q has no default probability, only the open funnel stages do", opp.Stage)
+		}
+		if !ValidProbability(percent) {
+			return nil, fmt.Errorf("probability of %s must be between 0 and 100, got %g", opp.Stage, percent)
+		}
+		probabilities[opp.Stage] = percent
+	}
+	return probabilities, nil
+}
+
+// maps the stage names of a CRM's sales process, e.g. Salesforce's
+// StageName, onto funnel stages. Names match regardless of case.
+type StageMapping map[string]OpportunityStage
+
+// parses "name=stage" pairs onto the funnel's stages and closed lost, e.g.
+// "Prospecting=lead,Closed Won=closed_won"
+func ParseStageMapping(spec string, funnel Funnel) (StageMapping, error) {
+	mapping := StageMapping{}
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(pair, "=")
+		name, stage := strings.TrimSpace(name), OpportunityStage(strings.TrimSpace(value))
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid stage mapping %q: expected name=stage", pair)
+		}
+		if !funnel.Allows(stage) {
+			return nil, fmt.Errorf("stage %q of %q is not a funnel stage, expected one of %v or %s", stage, name, funnel, StageClosedLost)
+		}
+		mapping[strings.ToLower(name)] = stage
+	}
+	return mapping, nil
+}
+
+// returns the funnel stage of a CRM stage name, false when it is unmapped
+func (m StageMapping) Stage(name string) (OpportunityStage, bool) {
+	stage, ok := m[strings.ToLower(strings.TrimSpace(name))]
+	return stage, ok
+}
+
+// reports whether a win probability is a percentage
+func ValidProbability(percent float64) bool {
+	return percent >= 0 && percent <= 100
+}
+
+// returns the time the opportunity's revenue is recognized at: when it was
+// closed won under close date recognition, when it was created otherwise.
+// Opportunities the CRM reported no close date for fall back to creation.
+func (o ProcessedOpportunity) RecognizedAt(recognition RevenueRecognition) time.Time {
+	if recognition == RecognizeOnClose && o.IsClosedWon() && o.ClosedAt != nil {
+		return *o.ClosedAt
+	}
+	return o.CreatedAt
+}
+
+// returns the opportunity's value of a UTM dimension, empty for dimensions
+// opportunities don't have
+func (o ProcessedOpportunity) DimensionValue(dimension string) string {
+	switch dimension {
+	case DimensionUTMCampaign:
+		return o.UTMCampaign
+	case DimensionUTMSource:
+		return o.UTMSource
+	case DimensionUTMMedium:
+		return o.UTMMedium
+	}
+	return ""
+}
@@ -0,0 +1,32 @@
+package domain
+
+import "time"
+
+var (
+	ErrNoPromotedDataset    = NewError(ErrConflict, "no promoted dataset to demote")
+	ErrShadowResultRejected = NewError(ErrValidation, "shadow result failed its parse policies")
+)
+
+// a dataset promoted to active: the metrics of a shadow result replacing
+// the stored metrics of its window. Previous holds the replaced metrics,
+// which demoting the promotion restores.
+type DatasetPromotion struct {
+	ID         string            `json:"id"`
+	Label      string            `json:"label"`
+	ResultID   string            `json:"result_id"`
+	RunID      string            `json:"run_id"`
+	From       time.Time         `json:"from"`
+	To         time.Time         `json:"to"`
+	Promoted   int               `json:"promoted"`
+	Replaced   int               `json:"replaced"`
+	PromotedBy string            `json:"promoted_by"`
+	PromotedAt time.Time         `json:"promoted_at"`
+	DemotedBy  string            `json:"demoted_by,omitempty"`
+	DemotedAt  *time.Time        `json:"demoted_at,omitempty"`
+	Previous   []BusinessMetrics `json:"-"`
+}
+
+// reports whether the promotion's dataset is still active
+func (p DatasetPromotion) Active() bool {
+	return p.DemotedAt == nil
+}
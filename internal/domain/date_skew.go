@@ -0,0 +1,81 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+// what to do with a record dated in the future or implausibly long ago
+type DateSkewAction string
+
+const (
+	DateSkewAccept     DateSkewAction = "accept"     // keep the date as is
+	DateSkewClamp      DateSkewAction = "clamp"      // move the date to now, or to the oldest plausible day
+	DateSkewQuarantine DateSkewAction = "quarantine" // drop the row into the quarantine store
+)
+
+// detection of clock-skewed upstream dates, e.g. from timezone bugs. A
+// date is future-dated when it lies more than MaxFuture ahead of the clock
+// and implausibly old when it lies more than MaxAge behind it; zero
+// disables the old check. Skewed records are counted whatever the action.
+type DateSkewPolicy struct {
+	Action    DateSkewAction `json:"action"`
+	MaxFuture time.Duration  `json:"max_future"`
+	MaxAge    time.Duration  `json:"max_age,omitempty"`
+}
+
+func (p DateSkewPolicy) Validate() error {
+	switch p.Action {
+	case DateSkewAccept, DateSkewClamp, DateSkewQuarantine:
+	default:
+		return fmt.Errorf("date skew action must be %s, %s or %s, got %q", DateSkewAccept, DateSkewClamp, DateSkewQuarantine, p.Action)
+	}
+	if p.MaxFuture < 0 || p.MaxAge < 0 {
+		return fmt.Errorf("date skew tolerances must not be negative")
+	}
+	return nil
+}
+
+// Skew reports whether date is future-dated or implausibly old as of now
+func (p DateSkewPolicy) Skew(date, now time.Time) (future, old bool) {
+	future = date.After(now.Add(p.MaxFuture))
+	old = p.MaxAge > 0 && date.Before(now.Add(-p.MaxAge))
+	return future, old
+}
+
+// Oldest returns the oldest plausible date as of now, which old dates are
+// clamped to
+func (p DateSkewPolicy) Oldest(now time.Time) time.Time {
+	return now.Add(-p.MaxAge)
+}
+
+// per-run counts of the records of a source with skewed dates, and the
+// furthest dates seen on either side
+type DateSkewReport struct {
+	Future      int        `json:"future"`
+	Old         int        `json:"old"`
+	Clamped     int        `json:"clamped"`
+	Quarantined int        `json:"quarantined"`
+	Latest      *time.Time `json:"latest,omitempty"`   // furthest future date
+	Earliest    *time.Time `json:"earliest,omitempty"` // oldest implausible date
+}
+
+// Observe counts a future-dated or implausibly old date
+func (r *DateSkewReport) Observe(date time.Time, future bool) {
+	if future {
+		r.Future++
+		if r.Latest == nil || date.After(*r.Latest) {
+			r.Latest = &date
+		}
+		return
+	}
+	r.Old++
+	if r.Earliest == nil || date.Before(*r.Earliest) {
+		r.Earliest = &date
+	}
+}
+
+// Skewed returns the records with skewed dates
+func (r *DateSkewReport) Skewed() int {
+	return r.Future + r.Old
+}
@@ -0,0 +1,236 @@
+package domain
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ErrDerivedModelNotFound is returned for models that are not configured
+var ErrDerivedModelNotFound = NewError(ErrNotFound, "derived model not found")
+
+// the grain of a model without periods: one row per group over the window
+const ModelGrainAll = "all"
+
+// the window of stored metrics a model covers when it sets none
+const DefaultModelWindowDays = 90
+
+// a dataset derived from the stored metrics, e.g. a weekly rollup per
+// channel or an LTV:CAC table, materialized after every successful run.
+// Metrics dated within the window and matching Where are grouped by period
+// and the GroupBy dimensions, and each column is an expression over the
+// group's totals.
+type DerivedModel struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Grain       string          `json:"grain,omitempty"`
+	GroupBy     []string        `json:"group_by,omitempty"`
+	WindowDays  int             `json:"window_days,omitempty"`
+	Where       MetricsScope    `json:"where,omitempty"`
+	Columns     []DerivedColumn `json:"columns"`
+
+	exprs []modelExpr
+}
+
+// a named column of a model, e.g. {"name": "ltv_cac", "expr": "revenue / cost"}
+type DerivedColumn struct {
+	Name string `json:"name"`
+	Expr string `json:"expr"`
+}
+
+// validates the model, defaulting its grain and window, and compiles its
+// column expressions
+func (m *DerivedModel) Validate() error {
+	if m.Name == "" {
+		return fmt.Errorf("derived model name is required")
+	}
+	switch m.Grain {
+	case "":
+		m.Grain = ModelGrainAll
+	case ModelGrainAll, IntervalDay, IntervalWeek, IntervalMonth:
+	default:
+		return fmt.Errorf("%s: unsupported grain %q, use %s, %s, %s or %s", m.Name, m.Grain, IntervalDay, IntervalWeek, IntervalMonth, ModelGrainAll)
+	}
+	if m.WindowDays < 0 {
+		return fmt.Errorf("%s: window_days must not be negative", m.Name)
+	}
+	if m.WindowDays == 0 {
+		m.WindowDays = DefaultModelWindowDays
+	}
+	for _, dimension := range m.GroupBy {
+		if !IsValidDimension(dimension) {
+			return fmt.Errorf("%s: unsupported group_by dimension %q", m.Name, dimension)
+		}
+	}
+	if err := m.Where.Validate(); err != nil {
+		return fmt.Errorf("%s: where: %w", m.Name, err)
+	}
+
+	if len(m.Columns) == 0 {
+		return fmt.Errorf("%s: derived model requires columns", m.Name)
+	}
+	m.exprs = make([]modelExpr, len(m.Columns))
+	names := make(map[string]bool, len(m.Columns))
+	for i, column := range m.Columns {
+		if column.Name == "" || column.Name == "period" || slices.Contains(m.GroupBy, column.Name) {
+			return fmt.Errorf("%s: column %d needs a name other than period and the group_by dimensions", m.Name, i+1)
+		}
+		if names[column.Name] {
+			return fmt.Errorf("%s: duplicate column %q", m.Name, column.Name)
+		}
+		names[column.Name] = true
+
+		expr, err := parseModelExpr(column.Expr)
+		if err != nil {
+			return fmt.Errorf("%s: column %s: %w", m.Name, column.Name, err)
+		}
+		m.exprs[i] = expr
+	}
+	return nil
+}
+
+// returns the days of stored metrics the model covers at the given time
+func (m DerivedModel) Window(at time.Time) (from, to time.Time) {
+	to = time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, time.UTC)
+	return to.AddDate(0, 0, 1-m.WindowDays), to
+}
+
+// a row of a materialized model. Period is the start of the row's period,
+// unset for the all grain; values are null where undefined.
+type DerivedRow struct {
+	Period     *time.Time          `json:"period,omitempty"`
+	Dimensions map[string]string   `json:"dimensions,omitempty"`
+	Values     map[string]*float64 `json:"values"`
+}
+
+// returns the row as a flat export record
+func (r DerivedRow) Record() ExportRecord {
+	record := make(ExportRecord, len(r.Dimensions)+len(r.Values)+1)
+	if r.Period != nil {
+		record["period"] = r.Period.Format("2006-01-02")
+	}
+	for dimension, value := range r.Dimensions {
+		record[dimension] = value
+	}
+	for column, value := range r.Values {
+		record[column] = value
+	}
+	return record
+}
+
+// a model's rows as of a run
+type DerivedModelResult struct {
+	Model          string       `json:"model"`
+	RunID          string       `json:"run_id,omitempty"`
+	From           time.Time    `json:"from"`
+	To             time.Time    `json:"to"`
+	Rows           []DerivedRow `json:"rows"`
+	MaterializedAt time.Time    `json:"materialized_at"`
+}
+
+// a configured model with its latest materialization, if any
+type DerivedModelStatus struct {
+	DerivedModel
+	RunID          string     `json:"run_id,omitempty"`
+	Rows           int        `json:"rows"`
+	MaterializedAt *time.Time `json:"materialized_at,omitempty"`
+}
+
+// calculates the model's rows from the metrics of its window, ordered by
+// period and dimension values. The model must have been validated.
+func (m DerivedModel) Materialize(metrics []BusinessMetrics) []DerivedRow {
+	type group struct {
+		period     time.Time
+		dimensions []string
+		totals     ChannelTotals
+	}
+
+	groups := make(map[string]*group)
+	for _, metric := range metrics {
+		if !m.Where.Allows(metric) {
+			continue
+		}
+
+		var period time.Time
+		if m.Grain != ModelGrainAll {
+			period = BucketStart(m.Grain, metric.Date)
+		}
+		dimensions := make([]string, len(m.GroupBy))
+		for i, dimension := range m.GroupBy {
+			dimensions[i] = metric.DimensionValue(dimension)
+		}
+
+		key := period.Format("2006-01-02") + "\x00" + strings.Join(dimensions, "\x00")
+		g, ok := groups[key]
+		if !ok {
+			g = &group{period: period, dimensions: dimensions}
+			groups[key] = g
+		}
+		g.totals.Add(metric)
+	}
+
+	keys := make([]string, 0, len(groups))
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	rows := make([]DerivedRow, len(keys))
+	for i, key := range keys {
+		g := groups[key]
+		row := DerivedRow{Values: make(map[string]*float64, len(m.Columns))}
+		if m.Grain != ModelGrainAll {
+			row.Period = &g.period
+		}
+		if len(m.GroupBy) > 0 {
+			row.Dimensions = make(map[string]string, len(m.GroupBy))
+			for j, dimension := range m.GroupBy {
+				row.Dimensions[dimension] = g.dimensions[j]
+			}
+		}
+		for j, column := range m.Columns {
+			if value, ok := m.exprs[j](g.totals); ok {
+				row.Values[column.Name] = &value
+			} else {
+				row.Values[column.Name] = nil
+			}
+		}
+		rows[i] = row
+	}
+	return rows
+}
+
+// returns the rows in the scope. Scoped callers can only read models grouped
+// by every scoped dimension, or restricted by Where to values in scope,
+// since the other rows mix values outside it.
+func (s MetricsScope) FilterRows(model DerivedModel, rows []DerivedRow) ([]DerivedRow, error) {
+	for dimension := range s {
+		if slices.Contains(model.GroupBy, dimension) {
+			continue
+		}
+		values, restricted := model.Where[dimension]
+		if !restricted || slices.ContainsFunc(values, func(value string) bool { return !s.AllowsValue(dimension, value) }) {
+			return nil, fmt.Errorf("%w: model %s is not grouped by %s", ErrOutOfScope, model.Name, dimension)
+		}
+	}
+	if len(s) == 0 {
+		return rows, nil
+	}
+
+	allowed := []DerivedRow{}
+	for _, row := range rows {
+		in := true
+		for dimension := range s {
+			value, grouped := row.Dimensions[dimension]
+			if grouped && !s.AllowsValue(dimension, value) {
+				in = false
+				break
+			}
+		}
+		if in {
+			allowed = append(allowed, row)
+		}
+	}
+	return allowed, nil
+}
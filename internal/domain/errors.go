@@ -0,0 +1,49 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+)
+
+// error categories returned across layers. Handlers map them to HTTP
+// statuses, so errors of a category must be checked with errors.Is.
+var (
+	ErrNotFound            = errors.New("not found")
+	ErrValidation          = errors.New("validation failed")
+	ErrConflict            = errors.New("conflict")
+	ErrForbidden           = errors.New("forbidden")
+	ErrUpstreamUnavailable = errors.New("upstream unavailable")
+	ErrOverloaded          = errors.New("overloaded")
+)
+
+// an error with its own message that belongs to a category and optionally
+// wraps a cause
+type categorizedError struct {
+	msg      string
+	category error
+	cause    error
+}
+
+func (e *categorizedError) Error() string {
+	return e.msg
+}
+
+func (e *categorizedError) Unwrap() []error {
+	if e.cause == nil {
+		return []error{e.category}
+	}
+	return []error{e.category, e.cause}
+}
+
+// NewError returns an error of the category with the given message, for
+// declaring sentinel errors that also match their category
+func NewError(category error, msg string) error {
+	return &categorizedError{msg: msg, category: category}
+}
+
+// Errorf formats an error of the category. Errors wrapped with %w still
+// match errors.Is and errors.As.
+func Errorf(category error, format string, args ...any) error {
+	cause := fmt.Errorf(format, args...)
+	return &categorizedError{msg: cause.Error(), category: category, cause: cause}
+}
@@ -0,0 +1,139 @@
+package domain
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+)
+
+// how an event log entry was ingested
+const (
+	EventOriginRun      = "run"
+	EventOriginPush     = "push"
+	EventOriginRollback = "rollback"
+)
+
+// one version of an ingested record. Records are identified by their key
+// within a source and every ingestion that changes a record appends its next
+// version, so the log can be read as of any point in time.
+type IngestEvent struct {
+	Seq         int64                 `json:"seq"`
+	Source      string                `json:"source"`
+	Key         string                `json:"key"`
+	Version     int                   `json:"version"`
+	IngestRunID string                `json:"ingest_run_id"`
+	Origin      string                `json:"origin"`
+	Ad          *ProcessedAdData      `json:"ad,omitempty"`
+	Opportunity *ProcessedOpportunity `json:"opportunity,omitempty"`
+	Deleted     bool                  `json:"deleted,omitempty"` // tombstone of a record removed by a rollback
+	IngestedAt  time.Time             `json:"ingested_at"`
+}
+
+// returns the value of the dimension of the event's record, "" for
+// tombstones
+func (e IngestEvent) DimensionValue(dimension string) string {
+	switch {
+	case e.Ad != nil:
+		return e.Ad.DimensionValue(dimension)
+	case e.Opportunity != nil:
+		return e.Opportunity.DimensionValue(dimension)
+	}
+	return ""
+}
+
+// returns the event of an ingested ad row, keyed by its date, campaign,
+// channel and UTMs
+func NewAdEvent(ad ProcessedAdData) IngestEvent {
+	return IngestEvent{Source: SourceAds, Key: ad.RecordKey(), Ad: &ad}
+}
+
+// returns the event of an ingested opportunity, keyed by its ID
+func NewOpportunityEvent(opp ProcessedOpportunity) IngestEvent {
+	return IngestEvent{Source: SourceCRM, Key: opp.OpportunityID, Opportunity: &opp}
+}
+
+// returns the record key prefixed with its source, unique across sources
+func (e IngestEvent) RecordKey() string {
+	return e.Source + ":" + e.Key
+}
+
+// reports whether two events hold the same record, ignoring when it was
+// processed, so ingesting unchanged records again adds no version. Records
+// with fingerprints are compared by them.
+func (e IngestEvent) SameRecord(other IngestEvent) bool {
+	if e.Deleted || other.Deleted {
+		return e.Deleted == other.Deleted
+	}
+	if a, b := e.fingerprint(), other.fingerprint(); a != "" && b != "" {
+		return a == b
+	}
+	a, b := e.payload(), other.payload()
+	return a != nil && bytes.Equal(a, b)
+}
+
+func (e IngestEvent) fingerprint() string {
+	switch {
+	case e.Ad != nil:
+		return e.Ad.Fingerprint
+	case e.Opportunity != nil:
+		return e.Opportunity.Fingerprint
+	}
+	return ""
+}
+
+func (e IngestEvent) payload() []byte {
+	var record any
+	switch {
+	case e.Ad != nil:
+		ad := *e.Ad
+		ad.ProcessedAt = time.Time{}
+		ad.Fingerprint = ""
+		record = ad
+	case e.Opportunity != nil:
+		opp := *e.Opportunity
+		opp.ProcessedAt = time.Time{}
+		opp.Fingerprint = ""
+		record = opp
+	default:
+		return nil
+	}
+	raw, err := json.Marshal(record)
+	if err != nil {
+		return nil
+	}
+	return raw
+}
+
+// selects event log entries; empty fields match everything
+type EventFilter struct {
+	Source       string
+	Key          string
+	IngestRunID  string
+	IngestRunIDs []string          // any of these ingestions
+	RunTags      map[string]string // ingestions of the recorded runs with these tags, see RunFilter
+	Limit        int               // 0 returns every match
+}
+
+// the latest version of every record as of a point in time
+type EventSnapshot struct {
+	AsOf          time.Time              `json:"as_of"`
+	Ads           []ProcessedAdData      `json:"ads"`
+	Opportunities []ProcessedOpportunity `json:"opportunities"`
+}
+
+// outcome of compacting the event log
+type EventCompaction struct {
+	Horizon   time.Time `json:"horizon"`
+	Removed   int       `json:"removed"`
+	Remaining int       `json:"remaining"`
+}
+
+// outcome of rolling back an ingestion. Records changed again by a later
+// ingestion, or whose previous version was compacted, are skipped.
+type EventRollback struct {
+	IngestRunID string   `json:"ingest_run_id"`
+	RollbackID  string   `json:"rollback_id"`
+	Restored    int      `json:"restored"`
+	Deleted     int      `json:"deleted"`
+	Skipped     []string `json:"skipped"`
+}
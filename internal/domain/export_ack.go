@@ -0,0 +1,103 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// states of an export delivered to a sink that acknowledges it
+// asynchronously
+const (
+	ExportDelivered = "delivered"
+	ExportConfirmed = "confirmed"
+	ExportRejected  = "rejected"
+)
+
+var (
+	// ErrExportDeliveryNotFound is returned for unknown deliveries and acks
+	// of exports that were never delivered
+	ErrExportDeliveryNotFound = NewError(ErrNotFound, "export delivery not found")
+	// ErrExportAcksNotConfigured is returned for acks when export
+	// acknowledgements are disabled
+	ErrExportAcksNotConfigured = errors.New("export acknowledgements not configured")
+	// ErrInvalidAckSignature is returned for acks whose signature doesn't
+	// match their body
+	ErrInvalidAckSignature = errors.New("invalid ack signature")
+)
+
+// an export delivered to a sink, awaiting the sink's acknowledgement once
+// it processed the export. Deliveries not acknowledged by their deadline
+// are flagged stuck until the ack arrives.
+type ExportDelivery struct {
+	ID          string     `json:"id"`
+	ExportID    string     `json:"export_id"`
+	Destination string     `json:"destination,omitempty"`
+	Records     int        `json:"records"`
+	State       string     `json:"state"`
+	Stuck       bool       `json:"stuck"`
+	DeliveredAt time.Time  `json:"delivered_at"`
+	Deadline    time.Time  `json:"deadline"`
+	AckedAt     *time.Time `json:"acked_at,omitempty"`
+	Reason      string     `json:"reason,omitempty"`
+}
+
+// the callback a sink sends once it processed an export, identified by the
+// X-Export-ID it was delivered with
+type ExportAck struct {
+	ExportID string `json:"export_id"`
+	Status   string `json:"status"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+func (a ExportAck) Validate() error {
+	if a.ExportID == "" {
+		return Errorf(ErrValidation, "export_id is required")
+	}
+	if a.Status != ExportConfirmed && a.Status != ExportRejected {
+		return Errorf(ErrValidation, "status must be %s or %s, got %q", ExportConfirmed, ExportRejected, a.Status)
+	}
+	return nil
+}
+
+// returns the delivery with the ack applied. Repeating the ack the
+// delivery already got is a no-op; a different one fails with ErrConflict.
+func (d ExportDelivery) Acknowledge(ack ExportAck, at time.Time) (ExportDelivery, error) {
+	if d.State == ack.Status {
+		return d, nil
+	}
+	if d.State != ExportDelivered {
+		return d, Errorf(ErrConflict, "export %s was already %s", d.ExportID, d.State)
+	}
+	d.State = ack.Status
+	d.Stuck = false
+	d.AckedAt = &at
+	d.Reason = ack.Reason
+	return d, nil
+}
+
+// selects export deliveries; empty fields match everything
+type ExportDeliveryFilter struct {
+	State    string
+	ExportID string
+	Stuck    bool // only deliveries flagged stuck
+	Limit    int  // 0 returns every match
+}
+
+func (f ExportDeliveryFilter) Validate() error {
+	switch f.State {
+	case "", ExportDelivered, ExportConfirmed, ExportRejected:
+		return nil
+	}
+	return fmt.Errorf("state must be %s, %s or %s", ExportDelivered, ExportConfirmed, ExportRejected)
+}
+
+func (f ExportDeliveryFilter) Matches(delivery ExportDelivery) bool {
+	if f.State != "" && delivery.State != f.State {
+		return false
+	}
+	if f.ExportID != "" && delivery.ExportID != f.ExportID {
+		return false
+	}
+	return !f.Stuck || delivery.Stuck
+}
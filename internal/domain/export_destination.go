@@ -0,0 +1,312 @@
+package domain
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+)
+
+// ErrExportDestinationNotFound is returned for exports to a destination
+// that is not configured
+var ErrExportDestinationNotFound = NewError(ErrNotFound, "export destination not found")
+
+// output transform types
+const (
+	ExportTransformFilter    = "filter"
+	ExportTransformAggregate = "aggregate"
+	ExportTransformConvert   = "convert"
+	ExportTransformRename    = "rename"
+	ExportTransformSelect    = "select"
+)
+
+// fields of exported metrics that rows are aggregated by
+var exportDimensionFields = []string{"date", "channel", "campaign_id"}
+
+// an exported metrics row keyed by output field name
+type ExportRecord map[string]any
+
+// a named partner endpoint receiving metric exports in its own shape. The
+// transforms are applied in order to the exported rows before they are
+// serialized. Mode is how its exports are sent, full or diff, the
+// configured export mode when empty.
+type ExportDestination struct {
+	Name        string            `json:"name"`
+	URL         string            `json:"url"`
+	Secret      string            `json:"secret,omitempty"`
+	Compression string            `json:"compression,omitempty"`
+	Mode        string            `json:"mode,omitempty"`
+	Transforms  []ExportTransform `json:"transforms,omitempty"`
+}
+
+// a single output transform:
+//   - filter keeps rows whose field is one of values, or at least min;
+//     exclude drops them instead
+//   - aggregate sums rows sharing the by fields and derives the ratios of
+//     the sums; the other dimensions are dropped
+//   - convert multiplies a numeric field, e.g. by 1000000 for micros,
+//     rounding to decimals when set
+//   - rename renames fields, old to new
+//   - select keeps only fields
+type ExportTransform struct {
+	Type     string            `json:"type"`
+	Field    string            `json:"field,omitempty"`
+	Values   []string          `json:"values,omitempty"`
+	Min      *float64          `json:"min,omitempty"`
+	Exclude  bool              `json:"exclude,omitempty"`
+	By       []string          `json:"by,omitempty"`
+	Multiply float64           `json:"multiply,omitempty"`
+	Decimals *int              `json:"decimals,omitempty"`
+	Rename   map[string]string `json:"rename,omitempty"`
+	Fields   []string          `json:"fields,omitempty"`
+}
+
+// validates the destination. Aggregation needs the exported fields as they
+// are, so it must come before any convert, rename or select.
+func (d *ExportDestination) Validate() error {
+	if d.Name == "" {
+		return fmt.Errorf("export destination name is required")
+	}
+	if d.URL == "" {
+		return fmt.Errorf("%s: export destination requires a url", d.Name)
+	}
+	if err := ValidateExportMode(d.Mode); err != nil {
+		return fmt.Errorf("%s: %w", d.Name, err)
+	}
+
+	reshaped := false
+	for i, transform := range d.Transforms {
+		if err := transform.validate(); err != nil {
+			return fmt.Errorf("%s: transform %d: %w", d.Name, i+1, err)
+		}
+		switch transform.Type {
+		case ExportTransformAggregate:
+			if reshaped {
+				return fmt.Errorf("%s: transform %d: aggregate must come before convert, rename and select", d.Name, i+1)
+			}
+		case ExportTransformConvert, ExportTransformRename, ExportTransformSelect:
+			reshaped = true
+		}
+	}
+	return nil
+}
+
+func (t ExportTransform) validate() error {
+	switch t.Type {
+	case ExportTransformFilter:
+		if t.Field == "" {
+			return fmt.Errorf("filter requires a field")
+		}
+		if len(t.Values) == 0 && t.Min == nil {
+			return fmt.Errorf("filter requires values or min")
+		}
+	case ExportTransformAggregate:
+		for _, field := range t.By {
+			if !slices.Contains(exportDimensionFields, field) {
+				return fmt.Errorf("cannot aggregate by %q, use %s", field, strings.Join(exportDimensionFields, ", "))
+			}
+		}
+	case ExportTransformConvert:
+		if t.Field == "" {
+			return fmt.Errorf("convert requires a field")
+		}
+		if t.Multiply == 0 {
+			return fmt.Errorf("convert requires a non-zero multiply")
+		}
+		if t.Decimals != nil && *t.Decimals < 0 {
+			return fmt.Errorf("decimals must not be negative")
+		}
+	case ExportTransformRename:
+		if len(t.Rename) == 0 {
+			return fmt.Errorf("rename requires fields to rename")
+		}
+	case ExportTransformSelect:
+		if len(t.Fields) == 0 {
+			return fmt.Errorf("select requires fields")
+		}
+	default:
+		return fmt.Errorf("unsupported transform type %q", t.Type)
+	}
+	return nil
+}
+
+// returns the export data shaped for the destination, without the hidden
+// columns. They are removed before the transforms so renames can't bring
+// them back under another name.
+func (d ExportDestination) Apply(data []ExportData, hidden ColumnMask) []ExportRecord {
+	records := make([]ExportRecord, len(data))
+	for i, row := range data {
+		records[i] = row.Record()
+		hidden.Record(records[i])
+	}
+
+	for _, transform := range d.Transforms {
+		switch transform.Type {
+		case ExportTransformFilter:
+			records = slices.DeleteFunc(records, func(record ExportRecord) bool {
+				return transform.matches(record) == transform.Exclude
+			})
+		case ExportTransformAggregate:
+			records = aggregateExportRecords(records, transform.By)
+			for _, record := range records {
+				hidden.Record(record)
+			}
+		case ExportTransformConvert:
+			for _, record := range records {
+				if value, ok := exportNumber(record[transform.Field]); ok {
+					record[transform.Field] = transform.convert(value)
+				}
+			}
+		case ExportTransformRename:
+			for _, record := range records {
+				renamed := make(ExportRecord, len(transform.Rename))
+				for from, to := range transform.Rename {
+					if value, ok := record[from]; ok {
+						renamed[to] = value
+						delete(record, from)
+					}
+				}
+				for field, value := range renamed {
+					record[field] = value
+				}
+			}
+		case ExportTransformSelect:
+			for _, record := range records {
+				for field := range record {
+					if !slices.Contains(transform.Fields, field) {
+						delete(record, field)
+					}
+				}
+			}
+		}
+	}
+	return records
+}
+
+// returns true if the record's field passes the filter's values and min
+func (t ExportTransform) matches(record ExportRecord) bool {
+	value, ok := record[t.Field]
+	if !ok {
+		return false
+	}
+	if len(t.Values) > 0 && !slices.Contains(t.Values, fmt.Sprint(value)) {
+		return false
+	}
+	if t.Min != nil {
+		number, ok := exportNumber(value)
+		if !ok || number < *t.Min {
+			return false
+		}
+	}
+	return true
+}
+
+func (t ExportTransform) convert(value float64) float64 {
+	value *= t.Multiply
+	if t.Decimals != nil {
+		scale := math.Pow(10, float64(*t.Decimals))
+		value = math.Round(value*scale) / scale
+	}
+	return value
+}
+
+// returns the export row keyed by its JSON field names
+func (d ExportData) Record() ExportRecord {
+	record := ExportRecord{
+		"date":            d.Date,
+		"channel":         d.Channel,
+		"campaign_id":     d.CampaignID,
+		"clicks":          d.Clicks,
+		"impressions":     d.Impressions,
+		"cost":            d.Cost,
+		"leads":           d.Leads,
+		"opportunities":   d.Opportunities,
+		"closed_won":      d.ClosedWon,
+		"revenue":         d.Revenue,
+		"cpc":             d.CPC,
+		"cpa":             d.CPA,
+		"cvr_lead_to_opp": d.CVRLeadToOpp,
+		"cvr_opp_to_won":  d.CVROppToWon,
+		"roas":            d.ROAS,
+	}
+	if d.Currency != "" {
+		record["currency"] = d.Currency
+	}
+	return record
+}
+
+// sums the records sharing the by fields, in order of first appearance,
+// and derives the ratios of the sums
+func aggregateExportRecords(records []ExportRecord, by []string) []ExportRecord {
+	var order []string
+	totals := make(map[string]*ExportData)
+	for _, record := range records {
+		values := make([]string, len(by))
+		for i, field := range by {
+			values[i] = fmt.Sprint(record[field])
+		}
+		key := strings.Join(values, "\x00")
+
+		total, ok := totals[key]
+		if !ok {
+			total = &ExportData{}
+			total.Date, _ = record["date"].(string)
+			total.Channel, _ = record["channel"].(string)
+			total.CampaignID, _ = record["campaign_id"].(string)
+			total.Currency, _ = record["currency"].(string)
+			totals[key] = total
+			order = append(order, key)
+		}
+
+		clicks, _ := record["clicks"].(int)
+		impressions, _ := record["impressions"].(int)
+		leads, _ := record["leads"].(int)
+		opportunities, _ := record["opportunities"].(int)
+		closedWon, _ := record["closed_won"].(int)
+		cost, _ := record["cost"].(Money)
+		revenue, _ := record["revenue"].(Money)
+		total.Clicks += clicks
+		total.Impressions += impressions
+		total.Leads += leads
+		total.Opportunities += opportunities
+		total.ClosedWon += closedWon
+		total.Cost += cost
+		total.Revenue += revenue
+	}
+
+	aggregated := make([]ExportRecord, len(order))
+	for i, key := range order {
+		total := totals[key]
+		total.CPC = total.Cost.Div(total.Clicks)
+		total.CPA = total.Cost.Div(total.Leads)
+		if total.Leads > 0 {
+			total.CVRLeadToOpp = float64(total.Opportunities) / float64(total.Leads)
+		}
+		if total.Opportunities > 0 {
+			total.CVROppToWon = float64(total.ClosedWon) / float64(total.Opportunities)
+		}
+		total.ROAS = total.Revenue.Ratio(total.Cost)
+
+		record := total.Record()
+		for _, field := range exportDimensionFields {
+			if !slices.Contains(by, field) {
+				delete(record, field)
+			}
+		}
+		aggregated[i] = record
+	}
+	return aggregated
+}
+
+// returns a numeric record value as a float
+func exportNumber(value any) (float64, bool) {
+	switch value := value.(type) {
+	case int:
+		return float64(value), true
+	case Money:
+		return value.Float64(), true
+	case float64:
+		return value, true
+	}
+	return 0, false
+}
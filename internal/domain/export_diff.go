@@ -0,0 +1,184 @@
+package domain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// export modes. Full exports send every row of the date; diff exports only
+// the rows inserted, updated or deleted since the last export of the date
+// to the same destination.
+const (
+	ExportModeFull = "full"
+	ExportModeDiff = "diff"
+)
+
+// ExportChangeField holds the change marker of the rows of diff exports
+const ExportChangeField = "_change"
+
+// change markers of diff export rows
+const (
+	ExportChangeInsert = "insert"
+	ExportChangeUpdate = "update"
+	ExportChangeDelete = "delete"
+)
+
+// validates an export mode, empty for the configured one
+func ValidateExportMode(mode string) error {
+	switch mode {
+	case "", ExportModeFull, ExportModeDiff:
+		return nil
+	}
+	return fmt.Errorf("unsupported export mode %q, use %s or %s", mode, ExportModeFull, ExportModeDiff)
+}
+
+// the rows last exported for a date to a destination, the sink when
+// Destination is empty. Rows are identified by their text fields, e.g.
+// date, channel and campaign_id, and kept as a hash of the whole row, so
+// a row whose other fields differ was updated.
+type ExportSnapshot struct {
+	Destination string            `json:"destination,omitempty"`
+	Date        string            `json:"date"`
+	ExportID    string            `json:"export_id"`
+	Rows        map[string]string `json:"rows"` // row hashes by key
+	ExportedAt  time.Time         `json:"exported_at"`
+}
+
+// the rows of a diff export by change, and those left out unchanged
+type ExportChanges struct {
+	Inserted  int `json:"inserted"`
+	Updated   int `json:"updated"`
+	Deleted   int `json:"deleted"`
+	Unchanged int `json:"unchanged"`
+}
+
+// the rows a diff export sends
+func (c ExportChanges) Total() int {
+	return c.Inserted + c.Updated + c.Deleted
+}
+
+// the outcome of a metrics export: the currency its amounts were converted
+// to, the delivery awaiting the sink's ack and, for diff exports, the rows
+// sent by change. Diff exports without changes deliver nothing.
+type ExportResult struct {
+	Mode       string              `json:"mode"`
+	Records    int                 `json:"records"`
+	Conversion *CurrencyConversion `json:"currency,omitempty"`
+	Delivery   *ExportDelivery     `json:"delivery,omitempty"`
+	Changes    *ExportChanges      `json:"changes,omitempty"`
+}
+
+// returns the snapshot of the records exported
+func NewExportSnapshot(destination string, date time.Time, exportID string, records []ExportRecord, at time.Time) ExportSnapshot {
+	snapshot := ExportSnapshot{
+		Destination: destination,
+		Date:        date.Format("2006-01-02"),
+		ExportID:    exportID,
+		Rows:        make(map[string]string, len(records)),
+		ExportedAt:  at,
+	}
+	for _, row := range keyExportRecords(records) {
+		snapshot.Rows[row.key] = row.hash
+	}
+	return snapshot
+}
+
+// returns the records inserted or updated since the snapshot, followed by
+// the deleted ones, each marked in its ExportChangeField. Deleted rows
+// only hold the text fields identifying them. Without a snapshot every
+// record is inserted.
+func (s *ExportSnapshot) Diff(records []ExportRecord) ([]ExportRecord, ExportChanges) {
+	var previous map[string]string
+	if s != nil {
+		previous = s.Rows
+	}
+
+	var changes ExportChanges
+	var changed []ExportRecord
+	seen := make(map[string]bool, len(records))
+	for _, row := range keyExportRecords(records) {
+		seen[row.key] = true
+		hash, ok := previous[row.key]
+		switch {
+		case !ok:
+			changes.Inserted++
+			changed = append(changed, row.record.withChange(ExportChangeInsert))
+		case hash != row.hash:
+			changes.Updated++
+			changed = append(changed, row.record.withChange(ExportChangeUpdate))
+		default:
+			changes.Unchanged++
+		}
+	}
+
+	var deleted []string
+	for key := range previous {
+		if !seen[key] {
+			deleted = append(deleted, key)
+		}
+	}
+	sort.Strings(deleted)
+	for _, key := range deleted {
+		changes.Deleted++
+		changed = append(changed, exportRecordFromKey(key).withChange(ExportChangeDelete))
+	}
+	return changed, changes
+}
+
+// a record with its identifying key and hash
+type keyedExportRecord struct {
+	record ExportRecord
+	key    string
+	hash   string
+}
+
+// keys the records by their text fields, numbering repeated keys so rows
+// that only differ in their numbers stay apart
+func keyExportRecords(records []ExportRecord) []keyedExportRecord {
+	keyed := make([]keyedExportRecord, len(records))
+	occurrences := make(map[string]int, len(records))
+	for i, record := range records {
+		identity := make(map[string]string)
+		for field, value := range record {
+			if text, ok := value.(string); ok {
+				identity[field] = text
+			}
+		}
+		raw, _ := json.Marshal(identity)
+		key := string(raw)
+		if n := occurrences[key]; n > 0 {
+			key += "#" + strconv.Itoa(n)
+		}
+		occurrences[string(raw)]++
+
+		raw, _ = json.Marshal(record)
+		sum := sha256.Sum256(raw)
+		keyed[i] = keyedExportRecord{record: record, key: key, hash: hex.EncodeToString(sum[:16])}
+	}
+	return keyed
+}
+
+// returns the text fields of a row key
+func exportRecordFromKey(key string) ExportRecord {
+	if i := strings.LastIndex(key, "#"); i > strings.LastIndex(key, "}") {
+		key = key[:i]
+	}
+	record := make(ExportRecord)
+	_ = json.Unmarshal([]byte(key), &record)
+	return record
+}
+
+// returns a copy of the record with the change marker
+func (r ExportRecord) withChange(change string) ExportRecord {
+	marked := make(ExportRecord, len(r)+1)
+	maps.Copy(marked, r)
+	marked[ExportChangeField] = change
+	return marked
+}
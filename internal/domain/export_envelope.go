@@ -0,0 +1,63 @@
+package domain
+
+import (
+	"errors"
+	"strconv"
+)
+
+// stages of a chunked export delivery. Exports sent in a single request
+// have no stage.
+const (
+	ExportStageChunk    = "chunk"
+	ExportStageComplete = "complete"
+)
+
+// ExportEnvelope identifies a request delivering an export, or one of its
+// chunks, to a destination. JobID names one delivery of the export's
+// content: retried requests and re-run exports of the same content repeat
+// it, so sinks drop requests whose IdempotencyKey they already processed,
+// while changed content gets a new one.
+type ExportEnvelope struct {
+	ExportID   string
+	JobID      string
+	Stage      string
+	ChunkIndex int
+	ChunkCount int
+}
+
+// the job ID and the chunk index, or the stage for the completion call
+func (e ExportEnvelope) IdempotencyKey() string {
+	if e.Stage == ExportStageComplete {
+		return e.JobID + ":" + ExportStageComplete
+	}
+	return e.JobID + ":" + strconv.Itoa(e.ChunkIndex)
+}
+
+func (e ExportEnvelope) Validate() error {
+	if e.ExportID == "" || e.JobID == "" {
+		return errors.New("export envelope needs an export ID and a job ID")
+	}
+	if e.Stage == ExportStageChunk && (e.ChunkIndex < 0 || e.ChunkIndex >= e.ChunkCount) {
+		return errors.New("export envelope chunk index out of range")
+	}
+	return nil
+}
+
+// the headers every export request carries: X-Export-ID, X-Export-Job-ID
+// and Idempotency-Key, plus X-Export-Stage and, for chunks, X-Chunk-Index
+// and X-Chunk-Count
+func (e ExportEnvelope) Headers() map[string]string {
+	headers := map[string]string{
+		"X-Export-ID":     e.ExportID,
+		"X-Export-Job-ID": e.JobID,
+		"Idempotency-Key": e.IdempotencyKey(),
+	}
+	if e.Stage != "" {
+		headers["X-Export-Stage"] = e.Stage
+	}
+	if e.Stage == ExportStageChunk {
+		headers["X-Chunk-Index"] = strconv.Itoa(e.ChunkIndex)
+		headers["X-Chunk-Count"] = strconv.Itoa(e.ChunkCount)
+	}
+	return headers
+}
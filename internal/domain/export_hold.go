@@ -0,0 +1,100 @@
+package domain
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// states of an export hold
+const (
+	ExportHoldPending  = "pending"
+	ExportHoldApproved = "approved"
+)
+
+// returned for exports of a date with a pending hold
+var ErrExportHeld = NewError(ErrConflict, "exports are held")
+
+// exports of a date held because a run loaded a day's spend far from its
+// baseline. Exports of the date are refused until the hold is approved.
+type ExportHold struct {
+	ID         string     `json:"id"`
+	Date       time.Time  `json:"date"`
+	RunID      string     `json:"run_id"`
+	Spend      Money      `json:"spend"`
+	Baseline   Money      `json:"baseline"`
+	Deviation  float64    `json:"deviation"`
+	Status     string     `json:"status"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ApprovedBy string     `json:"approved_by,omitempty"`
+	ApprovedAt *time.Time `json:"approved_at,omitempty"`
+}
+
+// selects export holds; empty fields match everything
+type ExportHoldFilter struct {
+	Status string
+	Date   *time.Time
+	Limit  int // 0 returns every match
+}
+
+// when a day's spend is anomalous: its deviation from the median daily spend
+// of the preceding baseline days exceeds the factor, either way. A zero
+// factor disables the check.
+type SpendAnomalyPolicy struct {
+	Factor       float64
+	BaselineDays int
+}
+
+// days with spend needed before a baseline is trusted
+const minSpendBaselineDays = 3
+
+func (p SpendAnomalyPolicy) Enabled() bool {
+	return p.Factor > 0
+}
+
+func (p SpendAnomalyPolicy) Validate() error {
+	if p.Factor != 0 && p.Factor <= 1 {
+		return fmt.Errorf("spend anomaly factor must be greater than 1, got %g", p.Factor)
+	}
+	if p.Enabled() && p.BaselineDays < minSpendBaselineDays {
+		return fmt.Errorf("spend anomaly baseline must cover at least %d days, got %d", minSpendBaselineDays, p.BaselineDays)
+	}
+	return nil
+}
+
+// returns the median of the daily spend of the baseline, or false when too
+// few days had spend to tell what is normal
+func (p SpendAnomalyPolicy) Baseline(daily []Money) (Money, bool) {
+	var spend []Money
+	for _, day := range daily {
+		if day > 0 {
+			spend = append(spend, day)
+		}
+	}
+	if len(spend) < minSpendBaselineDays {
+		return 0, false
+	}
+
+	slices.Sort(spend)
+	middle := len(spend) / 2
+	if len(spend)%2 == 0 {
+		return (spend[middle-1] + spend[middle]) / 2, true
+	}
+	return spend[middle], true
+}
+
+// returns how many times the spend is above or below the baseline, and
+// whether that exceeds the factor
+func (p SpendAnomalyPolicy) Check(spend, baseline Money) (float64, bool) {
+	if baseline <= 0 {
+		return 0, false
+	}
+	if spend <= 0 {
+		return 0, true
+	}
+	deviation := spend.Ratio(baseline)
+	if deviation < 1 {
+		deviation = baseline.Ratio(spend)
+	}
+	return deviation, deviation > p.Factor
+}
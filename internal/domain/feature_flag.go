@@ -0,0 +1,57 @@
+package domain
+
+import (
+	"context"
+	"errors"
+)
+
+var ErrFeatureDisabled = errors.New("feature disabled")
+
+// feature flags that gate behaviors still being rolled out
+const (
+	FlagStrictValidation = "strict_validation" // default parse mode is strict instead of lenient
+	FlagSinkExport       = "sink_export"       // raw exports may be delivered to the sink
+	FlagS3Export         = "s3_export"         // raw exports may be uploaded to object storage
+)
+
+// default state of the known flags when the configuration does not set them
+var DefaultFeatureFlags = []FeatureFlag{
+	{Name: FlagStrictValidation, Description: "Reject runs on the first unparseable row unless a parse mode is requested", Enabled: false},
+	{Name: FlagSinkExport, Description: "Allow raw exports to the sink destination", Enabled: true},
+	{Name: FlagS3Export, Description: "Allow raw exports to the s3 destination", Enabled: true},
+}
+
+// a named switch with optional per-environment and per-tenant overrides.
+// A tenant override wins over an environment override, which wins over
+// Enabled.
+type FeatureFlag struct {
+	Name         string          `json:"name"`
+	Description  string          `json:"description,omitempty"`
+	Enabled      bool            `json:"enabled"`
+	Environments map[string]bool `json:"environments,omitempty"`
+	Tenants      map[string]bool `json:"tenants,omitempty"`
+}
+
+// returns whether the flag is on for the environment and tenant
+func (f FeatureFlag) EnabledFor(environment, tenant string) bool {
+	if enabled, ok := f.Tenants[tenant]; ok && tenant != "" {
+		return enabled
+	}
+	if enabled, ok := f.Environments[environment]; ok {
+		return enabled
+	}
+	return f.Enabled
+}
+
+type tenantKey struct{}
+
+// returns a context carrying the tenant a request acts for
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// returns the tenant carried by the context, or "" when there is none
+func TenantFromContext(ctx context.Context) string {
+	tenant, _ := ctx.Value(tenantKey{}).(string)
+	return tenant
+}
@@ -0,0 +1,56 @@
+package domain
+
+// describes where a domain field is read from in an upstream record.
+// Path is a JSONPath-like expression relative to the record ("spend",
+// "$.metrics.cost", "tags[0]"). Values are coerced to the domain field's
+// type; Default is used when the path is missing or null.
+type FieldMapping struct {
+	Path    string `json:"path"`
+	Default any    `json:"default,omitempty"`
+	Format  string `json:"format,omitempty"` // date fields only: Go time layout or "unix"
+	Locale  string `json:"locale,omitempty"` // number fields only: overrides the source locale
+}
+
+// maps one upstream payload onto domain records. Records is the path to
+// the array of records in the response body; Locale selects the decimal
+// and grouping separators of string encoded numbers ("en", "de", "auto").
+// Pagination is set for APIs returning their records over several
+// responses, each with its own records array. CSV files of the source are
+// read with CSV, and their fields from the column their path names.
+type SourceMapping struct {
+	Records    string                  `json:"records,omitempty"`
+	Locale     string                  `json:"locale,omitempty"`
+	Fields     map[string]FieldMapping `json:"fields,omitempty"`
+	Pagination *Pagination             `json:"pagination,omitempty"`
+	CSV        *CSVFormat              `json:"csv,omitempty"`
+}
+
+// how the CSV files of a source are laid out. The first row names the
+// columns.
+type CSVFormat struct {
+	Delimiter string `json:"delimiter,omitempty"` // a single character, "," by default
+}
+
+// pagination strategies of upstream APIs
+const (
+	PaginationPage   = "page"   // a page number query parameter
+	PaginationCursor = "cursor" // a cursor from the response body sent back as a query parameter
+	PaginationLink   = "link"   // the rel="next" URL of the Link response header
+)
+
+// how an upstream API splits its records across responses. Pages are
+// requested until one comes back empty, with fewer than PageSize records,
+// without a next cursor or without a next link.
+type Pagination struct {
+	Strategy   string `json:"strategy"`
+	Param      string `json:"param,omitempty"`       // page and cursor: the query parameter, "page" or "cursor" by default
+	FirstPage  *int   `json:"first_page,omitempty"`  // page: the number of the first page, 1 by default
+	SizeParam  string `json:"size_param,omitempty"`  // page: the query parameter asking for PageSize records
+	PageSize   int    `json:"page_size,omitempty"`   // page: records of a full page
+	NextCursor string `json:"next_cursor,omitempty"` // cursor: path of the next cursor in the response body
+	MaxPages   int    `json:"max_pages,omitempty"`   // fetches with more pages fail, 1000 by default
+}
+
+// per-source field mappings keyed by source name (ads, crm, keywords). Fields that
+// are not configured keep their default identity mapping.
+type FieldMappingConfig map[string]SourceMapping
@@ -0,0 +1,82 @@
+package domain
+
+import (
+	"bytes"
+	"encoding/json"
+	"slices"
+	"strings"
+	"time"
+)
+
+// supported fingerprint algorithms
+const (
+	FingerprintSHA256 = "sha256"
+	FingerprintFNV64a = "fnv64a" // faster, 64 bit; fine for change detection
+)
+
+// hashes the business fields of ingested records into a stable fingerprint
+// stored alongside them, so records ingested again can be compared with
+// their stored version without comparing every field
+type Fingerprinter interface {
+	Fingerprint(fields []byte) string
+}
+
+// writes the canonical encoding of the ad's business fields to buf:
+// everything but processing flags and timestamps
+func (a ProcessedAdData) EncodeFingerprintFields(buf *bytes.Buffer) {
+	a.Flags = nil
+	a.ProcessedAt = time.Time{}
+	a.Fingerprint = ""
+	encodeFingerprintFields(buf, &a)
+}
+
+// writes the canonical encoding of the opportunity's business fields to
+// buf: everything but processing flags and timestamps
+func (o ProcessedOpportunity) EncodeFingerprintFields(buf *bytes.Buffer) {
+	o.Flags = nil
+	o.ProcessedAt = time.Time{}
+	o.Fingerprint = ""
+	encodeFingerprintFields(buf, &o)
+}
+
+// JSON encodes the record into buf without the newline the encoder
+// appends, i.e. exactly as json.Marshal does
+func encodeFingerprintFields(buf *bytes.Buffer, record any) {
+	json.NewEncoder(buf).Encode(record) // plain data, can't fail
+	buf.Truncate(buf.Len() - 1)
+}
+
+// returns the natural key of the ad row: its date, campaign, channel and
+// UTMs, and its ad group when it has one
+func (a ProcessedAdData) RecordKey() string {
+	key := strings.Join([]string{
+		a.Date.Format("2006-01-02"), a.CampaignID, a.Channel, a.UTMCampaign, a.UTMSource, a.UTMMedium,
+	}, "|")
+	if a.AdGroupID != "" {
+		key += "|" + a.AdGroupID
+	}
+	return key
+}
+
+// how the records of a source that a run or push ingested compare with
+// their stored versions. Unchanged records are not written again.
+type ChangeCounts struct {
+	New       int `json:"new"`
+	Changed   int `json:"changed"`
+	Unchanged int `json:"unchanged"`
+}
+
+// classifies a record by its stored fingerprints; stored is empty for new
+// records
+func (c *ChangeCounts) Add(fingerprint string, stored []string) (changed bool) {
+	switch {
+	case len(stored) == 0:
+		c.New++
+	case slices.Contains(stored, fingerprint):
+		c.Unchanged++
+		return false
+	default:
+		c.Changed++
+	}
+	return true
+}
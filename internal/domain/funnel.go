@@ -0,0 +1,75 @@
+package domain
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// the ordered stages opportunities move through up to closed won. Lead,
+// opportunity and closed won are built in and keep their order, closed won
+// last; configured stages such as MQL and SQL go around and between them.
+// Closed lost is outside the funnel.
+type Funnel []OpportunityStage
+
+// the funnel without configured stages
+var DefaultFunnel = Funnel{StageLead, StageOpportunity, StageClosedWon}
+
+var stageNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// parses comma separated stages in funnel order, e.g.
+// "lead,mql,sql,opportunity,closed_won". An empty spec is the default
+// funnel.
+func ParseFunnel(spec string) (Funnel, error) {
+	if strings.TrimSpace(spec) == "" {
+		return DefaultFunnel, nil
+	}
+
+	var funnel Funnel
+	for _, name := range strings.Split(spec, ",") {
+		stage := OpportunityStage(strings.TrimSpace(name))
+		if !stageNamePattern.MatchString(string(stage)) {
+			return nil, fmt.Errorf("invalid funnel stage %q, expected lower case letters, digits and underscores", stage)
+		}
+		if stage == StageClosedLost {
+			return nil, fmt.Errorf("%s is outside the funnel", StageClosedLost)
+		}
+		if slices.Contains(funnel, stage) {
+			return nil, fmt.Errorf("funnel stage %s is listed twice", stage)
+		}
+		funnel = append(funnel, stage)
+	}
+
+	lead, opportunity := slices.Index(funnel, StageLead), slices.Index(funnel, StageOpportunity)
+	if lead < 0 || opportunity < 0 || lead > opportunity {
+		return nil, fmt.Errorf("the funnel must list %s before %s", StageLead, StageOpportunity)
+	}
+	if funnel[len(funnel)-1] != StageClosedWon {
+		return nil, fmt.Errorf("the funnel must end with %s", StageClosedWon)
+	}
+	return funnel, nil
+}
+
+// reports whether opportunities may be in the stage: a funnel stage or
+// closed lost
+func (f Funnel) Allows(stage OpportunityStage) bool {
+	return stage == StageClosedLost || slices.Contains(f, stage)
+}
+
+// reports whether the stage is a configured one rather than built in
+func (f Funnel) IsConfigured(stage OpportunityStage) bool {
+	return slices.Contains(f, stage) && !slices.Contains(DefaultFunnel, stage)
+}
+
+// returns the conversion rate between every two adjacent stages, keyed
+// "<from>_to_<to>", from the stages' counts. Rates of stages without
+// opportunities are zero.
+func (f Funnel) Conversions(count func(OpportunityStage) int) map[string]float64 {
+	conversions := make(map[string]float64, len(f)-1)
+	for i := 1; i < len(f); i++ {
+		from, to := f[i-1], f[i]
+		conversions[string(from)+"_to_"+string(to)] = conversionRate(count(to), count(from))
+	}
+	return conversions
+}
@@ -0,0 +1,100 @@
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// returned when no rate of a currency is stored for the date of an amount
+var ErrNoFXRate = NewError(ErrValidation, "no FX rate")
+
+// how many days an FX rate may be older than the amount it converts, so
+// weekends and holidays use the last business day's rate
+const FXRateMaxAgeDays = 7
+
+// returns the ISO 4217 style code in upper case, failing for anything but
+// three letters
+func NormalizeCurrency(code string) (string, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if len(code) != 3 || strings.Trim(code, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") != "" {
+		return "", Errorf(ErrValidation, "invalid currency %q, expected a three letter code like EUR", code)
+	}
+	return code, nil
+}
+
+// the daily exchange rate of a currency: one unit of the base currency is
+// worth Rate units of it
+type FXRate struct {
+	Date     time.Time `json:"date"`
+	Currency string    `json:"currency"`
+	Rate     float64   `json:"rate"`
+}
+
+// FX rates are dated by day, in JSON as YYYY-MM-DD
+type fxRateJSON struct {
+	Date     string  `json:"date"`
+	Currency string  `json:"currency"`
+	Rate     float64 `json:"rate"`
+}
+
+func (r FXRate) MarshalJSON() ([]byte, error) {
+	return json.Marshal(fxRateJSON{Date: r.Date.Format("2006-01-02"), Currency: r.Currency, Rate: r.Rate})
+}
+
+func (r *FXRate) UnmarshalJSON(data []byte) error {
+	var raw fxRateJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	date, err := time.Parse("2006-01-02", raw.Date)
+	if err != nil {
+		return fmt.Errorf("invalid FX rate date %q, expected YYYY-MM-DD", raw.Date)
+	}
+	*r = FXRate{Date: date, Currency: raw.Currency, Rate: raw.Rate}
+	return nil
+}
+
+func (r *FXRate) Validate() error {
+	currency, err := NormalizeCurrency(r.Currency)
+	if err != nil {
+		return err
+	}
+	if r.Date.IsZero() {
+		return Errorf(ErrValidation, "%s rate has no date", currency)
+	}
+	if r.Rate <= 0 {
+		return Errorf(ErrValidation, "%s rate of %s must be positive", currency, r.Date.Format("2006-01-02"))
+	}
+	r.Currency = currency
+	r.Date = r.Date.UTC().Truncate(24 * time.Hour)
+	return nil
+}
+
+// an FX rate applied to the amounts of a response
+type AppliedRate struct {
+	RateDate string  `json:"rate_date"`
+	Rate     float64 `json:"rate"`
+}
+
+// describes the conversion of a response's amounts from the base currency.
+// Every amount is converted at the rate of its own date; Rates lists the
+// rates used, oldest first.
+type CurrencyConversion struct {
+	Currency string        `json:"currency"`
+	Base     string        `json:"base"`
+	Rates    []AppliedRate `json:"rates"`
+}
+
+// converts the metric's amounts at the rate. Ratios of amounts, such as
+// ROAS, don't change.
+func (m *BusinessMetrics) ConvertCurrency(rate float64) {
+	m.Cost = m.Cost.Scale(rate)
+	m.Revenue = m.Revenue.Scale(rate)
+	m.AttributedRevenue = m.AttributedRevenue.Scale(rate)
+	m.PipelineValue = m.PipelineValue.Scale(rate)
+	m.ExpectedRevenue = m.ExpectedRevenue.Scale(rate)
+	m.CPC = m.CPC.Scale(rate)
+	m.CPA = m.CPA.Scale(rate)
+}
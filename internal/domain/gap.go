@@ -0,0 +1,105 @@
+package domain
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// how runs handle days without upstream data
+const (
+	// gaps are not looked for
+	GapIgnore = "ignore"
+	// gaps are reported in the run summary and as run anomalies
+	GapMark = "mark"
+	// gaps are reported, and ad gaps get a zero row per campaign
+	GapZeroFill = "zero_fill"
+)
+
+// flag of the zero ad rows inserted for days without ads data
+const FlagGapFill = "gap_fill"
+
+func ValidateGapPolicy(policy string) error {
+	switch policy {
+	case GapIgnore, GapMark, GapZeroFill:
+		return nil
+	}
+	return fmt.Errorf("unsupported gap policy %q, expected %s, %s or %s", policy, GapIgnore, GapMark, GapZeroFill)
+}
+
+// reports whether the row was inserted for a day without ads data
+func (a ProcessedAdData) GapFilled() bool {
+	return slices.Contains(a.Flags, FlagGapFill)
+}
+
+// consecutive days without data of a source. Filled is set when zero rows
+// were inserted for them.
+type DataGap struct {
+	Source string `json:"source"`
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Days   int    `json:"days"`
+	Filled bool   `json:"filled,omitempty"`
+}
+
+// the gaps of one source over a range
+type SourceGaps struct {
+	Source      string    `json:"source"`
+	MissingDays int       `json:"missing_days"`
+	Gaps        []DataGap `json:"gaps"`
+}
+
+// the gaps of every source over a range of days
+type GapReport struct {
+	From    string       `json:"from"`
+	To      string       `json:"to"`
+	Sources []SourceGaps `json:"sources"`
+}
+
+// returns the UTC day of the time, as keyed in the days given to FindGaps
+func GapDay(t time.Time) time.Time {
+	return t.UTC().Truncate(24 * time.Hour)
+}
+
+// returns the runs of days in the range without data of the source, given
+// the days with data. Days before the source's first day with data are not
+// gaps, as it had not started reporting; without any data the whole range
+// is one gap.
+func FindGaps(source string, from, to time.Time, days map[time.Time]bool) []DataGap {
+	from, to = GapDay(from), GapDay(to)
+	start := from
+	for !start.After(to) && !days[start] {
+		start = start.AddDate(0, 0, 1)
+	}
+	if start.After(to) {
+		start = from
+	}
+
+	var gaps []DataGap
+	var open *DataGap
+	for day := start; !day.After(to); day = day.AddDate(0, 0, 1) {
+		if days[day] {
+			open = nil
+			continue
+		}
+		if open == nil {
+			gaps = append(gaps, DataGap{Source: source, From: day.Format("2006-01-02")})
+			open = &gaps[len(gaps)-1]
+		}
+		open.To = day.Format("2006-01-02")
+		open.Days++
+	}
+	return gaps
+}
+
+// returns the days of the gaps
+func GapDays(gaps []DataGap) []time.Time {
+	var days []time.Time
+	for _, gap := range gaps {
+		from, _ := time.Parse("2006-01-02", gap.From)
+		for i := range gap.Days {
+			days = append(days, from.AddDate(0, 0, i))
+		}
+	}
+	return days
+}
@@ -0,0 +1,110 @@
+package domain
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// formats of generated IDs
+const (
+	IDFormatUUID = "uuid"
+	IDFormatULID = "ulid"
+)
+
+// generates the IDs of runs, ingest jobs and the other records services
+// keep. ULIDs sort by the time they were generated at, so storage keys built
+// from them list in run order.
+type IDGenerator interface {
+	NewID() string
+}
+
+// UUIDGenerator generates random UUIDs
+var UUIDGenerator IDGenerator = uuidGenerator{}
+
+type uuidGenerator struct{}
+
+func (uuidGenerator) NewID() string {
+	return uuid.New().String()
+}
+
+// Crockford's base32 alphabet, which ULIDs are encoded in
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// generates ULIDs: 26 characters encoding a 48-bit millisecond timestamp
+// of the clock followed by 80 random bits. IDs generated within the same
+// millisecond increment the random bits, so they sort in the order they
+// were generated.
+type ULIDGenerator struct {
+	clock Clock
+
+	mutex   sync.Mutex
+	lastMs  uint64
+	lastHi  uint16 // random bits of the last ID, split 16/64
+	lastLow uint64
+}
+
+func NewULIDGenerator(clock Clock) *ULIDGenerator {
+	return &ULIDGenerator{clock: clock}
+}
+
+func (g *ULIDGenerator) NewID() string {
+	ms := uint64(g.clock.Now().UnixMilli())
+
+	g.mutex.Lock()
+	if ms <= g.lastMs {
+		// a clock standing still or stepped back keeps the last timestamp
+		ms = g.lastMs
+		g.lastLow++
+		if g.lastLow == 0 {
+			g.lastHi++
+		}
+	} else {
+		var entropy [10]byte
+		if _, err := rand.Read(entropy[:]); err != nil {
+			panic(fmt.Sprintf("ulid entropy: %v", err))
+		}
+		g.lastMs = ms
+		g.lastHi = binary.BigEndian.Uint16(entropy[:2])
+		g.lastLow = binary.BigEndian.Uint64(entropy[2:])
+	}
+	hi, low := g.lastHi, g.lastLow
+	g.mutex.Unlock()
+
+	var id [16]byte
+	id[0], id[1], id[2] = byte(ms>>40), byte(ms>>32), byte(ms>>24)
+	id[3], id[4], id[5] = byte(ms>>16), byte(ms>>8), byte(ms)
+	binary.BigEndian.PutUint16(id[6:8], hi)
+	binary.BigEndian.PutUint64(id[8:], low)
+	return encodeULID(id)
+}
+
+// encodes the 128 bits of a ULID as 26 base32 characters, the first of
+// which holds only the top 3 bits
+func encodeULID(id [16]byte) string {
+	hi := binary.BigEndian.Uint64(id[:8])
+	low := binary.BigEndian.Uint64(id[8:])
+
+	var out [26]byte
+	for i := 25; i >= 0; i-- {
+		out[i] = crockford[low&0x1f]
+		low = low>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out[:])
+}
+
+// returns the generator of the format, uuid or ulid, with ULIDs stamped by
+// clock
+func NewIDGenerator(format string, clock Clock) (IDGenerator, error) {
+	switch format {
+	case "", IDFormatUUID:
+		return UUIDGenerator, nil
+	case IDFormatULID:
+		return NewULIDGenerator(clock), nil
+	}
+	return nil, fmt.Errorf("id format must be %s or %s, got %q", IDFormatUUID, IDFormatULID, format)
+}
@@ -0,0 +1,197 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrIngestJobNotFound = NewError(ErrNotFound, "ingest job not found")
+
+// states of an ingest job
+const (
+	IngestJobQueued    = "queued"
+	IngestJobRunning   = "running"
+	IngestJobSucceeded = "succeeded"
+	IngestJobFailed    = "failed"
+)
+
+// an ingest run started through the API that runs in the background while
+// the caller polls it. RunID links the run record once the run has started.
+// Terminal is set once the job succeeded or failed; failed jobs carry the
+// ErrorCode of the error and whether retrying the request may succeed.
+type IngestJob struct {
+	ID         string            `json:"id"`
+	RunKey     string            `json:"run_key,omitempty"`
+	State      string            `json:"state"`
+	Terminal   bool              `json:"terminal"`
+	Pipeline   string            `json:"pipeline,omitempty"`
+	Since      *time.Time        `json:"since,omitempty"`
+	ForceFull  bool              `json:"force_full,omitempty"`
+	Tags       map[string]string `json:"tags,omitempty"`
+	Priority   string            `json:"priority"`
+	Tenant     string            `json:"tenant,omitempty"`
+	RunID      string            `json:"run_id,omitempty"`
+	Progress   *RunProgress      `json:"progress,omitempty"`
+	Summary    *RunSummary       `json:"summary,omitempty"`
+	Error      string            `json:"error,omitempty"`
+	ErrorCode  string            `json:"error_code,omitempty"`
+	Retryable  bool              `json:"retryable,omitempty"`
+	RequestID  string            `json:"request_id,omitempty"`
+	CreatedAt  time.Time         `json:"created_at"`
+	StartedAt  *time.Time        `json:"started_at,omitempty"`
+	FinishedAt *time.Time        `json:"finished_at,omitempty"`
+	Links      *IngestJobLinks   `json:"links,omitempty"`
+}
+
+// links of a job to its status, its run's details and the service's logs
+type IngestJobLinks struct {
+	Self          string `json:"self"`
+	Run           string `json:"run,omitempty"`
+	JoinReport    string `json:"join_report,omitempty"` // reconciliation of the ads and CRM keys
+	Certification string `json:"certification,omitempty"`
+	Events        string `json:"events,omitempty"` // record versions the run wrote
+	Logs          string `json:"logs,omitempty"`
+}
+
+// reports whether the job has succeeded or failed
+func (j IngestJob) Finished() bool {
+	return j.State == IngestJobSucceeded || j.State == IngestJobFailed
+}
+
+// what an ingest job runs: a pipeline preset, or the ETL with the options.
+// Requests with a run key submit a job once per tenant; repeating them
+// returns the existing job.
+type IngestJobRequest struct {
+	RunKey   string
+	Pipeline string
+	Options  RunOptions
+	Priority JobPriority
+}
+
+// the longest run key a caller can set
+const MaxRunKeyLen = 200
+
+// checks a run key, e.g. an Airflow dag_run ID
+func ValidateRunKey(key string) error {
+	if len(key) > MaxRunKeyLen || strings.ContainsFunc(key, func(r rune) bool { return r < ' ' || r == 0x7f }) {
+		return Errorf(ErrValidation, "run_key must be at most %d printable characters", MaxRunKeyLen)
+	}
+	return nil
+}
+
+// reports whether the job was submitted for the request, so a repeated
+// run key starts the same run
+func (j IngestJob) SameRequest(req IngestJobRequest) bool {
+	sameSince := j.Since == nil && req.Options.Since == nil ||
+		j.Since != nil && req.Options.Since != nil && j.Since.Equal(*req.Options.Since)
+	return j.Pipeline == req.Pipeline && sameSince && j.ForceFull == req.Options.ForceFull
+}
+
+// returns the machine-readable code of a job's error, matching the codes of
+// the synchronous API, and whether retrying the request may succeed
+func IngestJobErrorCode(err error) (code string, retryable bool) {
+	switch {
+	case errors.Is(err, ErrParseThreshold):
+		return "parse_policy_violated", false
+	case errors.Is(err, ErrQuotaExceeded):
+		return "quota_exceeded", false
+	case errors.Is(err, ErrMaintenance):
+		return "maintenance_mode", true
+	case errors.Is(err, ErrJobQueueTimeout):
+		return "job_queue_busy", true
+	case errors.Is(err, context.Canceled):
+		return "cancelled", true
+	case errors.Is(err, ErrPipelineNotFound):
+		return "pipeline_not_found", false
+	case errors.Is(err, ErrNotFound):
+		return "not_found", false
+	case errors.Is(err, ErrValidation):
+		return "validation_failed", false
+	case errors.Is(err, ErrConflict):
+		return "conflict", false
+	case errors.Is(err, ErrUpstreamUnavailable):
+		return "upstream_unavailable", true
+	case errors.Is(err, ErrOverloaded):
+		return "storage_busy", true
+	}
+	return "ingestion_failed", false
+}
+
+// how far a run has got: the stage it is in out of CostStages and the
+// records it extracted so far
+type RunProgress struct {
+	Stage           string `json:"stage"`
+	CompletedStages int    `json:"completed_stages"`
+	TotalStages     int    `json:"total_stages"`
+	Records         int    `json:"records"`
+}
+
+// follows a run through its stages. A nil tracker ignores everything so
+// runs outside an ingest job need no checks.
+type ProgressTracker struct {
+	runID    string
+	progress RunProgress
+	mutex    sync.Mutex
+}
+
+// starts tracking a run that has not started yet
+func NewProgressTracker() *ProgressTracker {
+	return &ProgressTracker{progress: RunProgress{TotalStages: len(CostStages)}}
+}
+
+type progressTrackerKey struct{}
+
+// returns a context carrying the tracker
+func WithProgressTracker(ctx context.Context, tracker *ProgressTracker) context.Context {
+	return context.WithValue(ctx, progressTrackerKey{}, tracker)
+}
+
+// returns the tracker carried by the context, or nil
+func ProgressTrackerFromContext(ctx context.Context) *ProgressTracker {
+	tracker, _ := ctx.Value(progressTrackerKey{}).(*ProgressTracker)
+	return tracker
+}
+
+// records the ID of the run once it started
+func (t *ProgressTracker) Begin(runID string) {
+	if t == nil {
+		return
+	}
+	t.mutex.Lock()
+	t.runID = runID
+	t.mutex.Unlock()
+}
+
+// records that the run entered the stage, completing the ones before it
+func (t *ProgressTracker) Enter(stage string) {
+	if t == nil {
+		return
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.progress.Stage = stage
+	if i := slices.Index(CostStages, stage); i >= 0 {
+		t.progress.CompletedStages = i
+	}
+}
+
+// counts extracted records
+func (t *ProgressTracker) AddRecords(n int) {
+	if t == nil {
+		return
+	}
+	t.mutex.Lock()
+	t.progress.Records += n
+	t.mutex.Unlock()
+}
+
+// returns the run's ID and a copy of its progress
+func (t *ProgressTracker) Snapshot() (string, RunProgress) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.runID, t.progress
+}
@@ -0,0 +1,55 @@
+package domain
+
+import "errors"
+
+// kinds of work scheduled through the job queue
+type JobType string
+
+const (
+	JobTypeIngest JobType = "ingest"
+	JobTypeExport JobType = "export"
+)
+
+// JobPriority orders queued jobs, higher runs first
+type JobPriority int
+
+const (
+	PriorityLow    JobPriority = 0
+	PriorityNormal JobPriority = 1
+	PriorityHigh   JobPriority = 2
+)
+
+// ErrJobQueueTimeout is returned when a job gave up waiting for a slot
+var ErrJobQueueTimeout = errors.New("timed out waiting in job queue")
+
+// parses a priority name, returning false for unknown values
+func ParseJobPriority(name string) (JobPriority, bool) {
+	switch name {
+	case "low":
+		return PriorityLow, true
+	case "normal":
+		return PriorityNormal, true
+	case "high":
+		return PriorityHigh, true
+	}
+	return PriorityNormal, false
+}
+
+// returns the priority name
+func (p JobPriority) String() string {
+	switch p {
+	case PriorityLow:
+		return "low"
+	case PriorityHigh:
+		return "high"
+	}
+	return "normal"
+}
+
+// represents the current state of the job queue for one job type
+type JobQueueStats struct {
+	Type        JobType `json:"type"`
+	Running     int     `json:"running"`
+	Queued      int     `json:"queued"`
+	Concurrency int     `json:"concurrency"`
+}
@@ -0,0 +1,92 @@
+package domain
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+)
+
+// JoinSampleSize is the number of unmatched UTM keys a join report samples
+// per side
+const JoinSampleSize = 10
+
+// how the UTM keys of the ads and opportunities in a run's metrics window
+// joined. Metrics are calculated per UTM with ads, so opportunities of
+// unmatched keys contribute nothing to the funnel metrics. Keys joined by a
+// fuzzy match count as matched, and the matches are listed for review.
+type JoinReport struct {
+	Ads          JoinSide        `json:"ads"`
+	CRM          JoinSide        `json:"crm"`
+	FuzzyMatches []FuzzyUTMMatch `json:"fuzzy_matches,omitempty"`
+}
+
+// the keys of one side of the join. Samples holds the unmatched keys with
+// the most records.
+type JoinSide struct {
+	Keys             int         `json:"keys"`
+	MatchedKeys      int         `json:"matched_keys"`
+	UnmatchedKeys    int         `json:"unmatched_keys"`
+	Records          int         `json:"records"`
+	UnmatchedRecords int         `json:"unmatched_records"`
+	Samples          []UTMSample `json:"unmatched_samples,omitempty"`
+}
+
+// an unmatched UTM key and its number of records
+type UTMSample struct {
+	UTMValues
+	Records int `json:"records"`
+}
+
+// returns how the UTM keys of the ads and opportunities join, the
+// opportunities as reported before the fuzzy matches were applied
+func NewJoinReport(ads []ProcessedAdData, opportunities []ProcessedOpportunity, fuzzy []FuzzyUTMMatch) *JoinReport {
+	adKeys := make(map[UTMKey]int)
+	for _, ad := range ads {
+		adKeys[UTMKey{Campaign: ad.UTMCampaign, Source: ad.UTMSource, Medium: ad.UTMMedium}]++
+	}
+	crmKeys := make(map[UTMKey]int)
+	for _, opp := range opportunities {
+		crmKeys[UTMKey{Campaign: opp.UTMCampaign, Source: opp.UTMSource, Medium: opp.UTMMedium}]++
+	}
+	// Each side of a fuzzy match counts as joined with the other
+	adJoins, crmJoins := maps.Clone(adKeys), maps.Clone(crmKeys)
+	for _, match := range fuzzy {
+		adJoins[UTMKey{Campaign: match.CRM.UTMCampaign, Source: match.CRM.UTMSource, Medium: match.CRM.UTMMedium}]++
+		crmJoins[UTMKey{Campaign: match.Ads.UTMCampaign, Source: match.Ads.UTMSource, Medium: match.Ads.UTMMedium}]++
+	}
+	return &JoinReport{
+		Ads:          newJoinSide(adKeys, crmJoins),
+		CRM:          newJoinSide(crmKeys, adJoins),
+		FuzzyMatches: fuzzy,
+	}
+}
+
+// returns the side of the keys joined against the other side's
+func newJoinSide(keys, other map[UTMKey]int) JoinSide {
+	side := JoinSide{Keys: len(keys)}
+	var unmatched []UTMSample
+	for utm, records := range keys {
+		side.Records += records
+		if _, ok := other[utm]; ok {
+			side.MatchedKeys++
+			continue
+		}
+		side.UnmatchedRecords += records
+		unmatched = append(unmatched, UTMSample{UTMValues: utmValuesOf(utm), Records: records})
+	}
+	side.UnmatchedKeys = len(unmatched)
+
+	slices.SortFunc(unmatched, func(a, b UTMSample) int {
+		return cmp.Or(
+			cmp.Compare(b.Records, a.Records),
+			cmp.Compare(a.UTMCampaign, b.UTMCampaign),
+			cmp.Compare(a.UTMSource, b.UTMSource),
+			cmp.Compare(a.UTMMedium, b.UTMMedium),
+		)
+	})
+	if len(unmatched) > JoinSampleSize {
+		unmatched = unmatched[:JoinSampleSize]
+	}
+	side.Samples = unmatched
+	return side
+}
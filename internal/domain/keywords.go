@@ -0,0 +1,170 @@
+package domain
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// match types of a keyword: how closely a search term has to match it for
+// the ad to show
+const (
+	MatchExact  = "exact"
+	MatchPhrase = "phrase"
+	MatchBroad  = "broad"
+)
+
+var MatchTypes = []string{MatchExact, MatchPhrase, MatchBroad}
+
+// normalizes a match type as platforms report it, e.g. EXACT or Phrase.
+// Unknown match types are returned as they are.
+func NormalizeMatchType(matchType string) string {
+	return strings.ToLower(strings.TrimSpace(matchType))
+}
+
+// normalizes a keyword: platforms match keywords case insensitively and
+// ignore repeated spaces
+func NormalizeKeyword(keyword string) string {
+	return strings.Join(strings.Fields(strings.ToLower(keyword)), " ")
+}
+
+// one row of the keyword feed: spend and clicks of a keyword and match type
+// in an ad group on a day
+type KeywordPerformance struct {
+	Date       string `json:"date"`
+	CampaignID string `json:"campaign_id"`
+	AdGroupID  string `json:"ad_group_id,omitempty"`
+	Channel    string `json:"channel"`
+	Keyword    string `json:"keyword"`
+	MatchType  string `json:"match_type"`
+	Clicks     int    `json:"clicks"`
+	Cost       Money  `json:"cost"`
+}
+
+type KeywordData struct {
+	Rows []KeywordPerformance
+
+	// rows that could not be decoded
+	Rejected []QuarantinedRecord
+}
+
+type ProcessedKeywordData struct {
+	Date        time.Time `json:"date"`
+	CampaignID  string    `json:"campaign_id"`
+	AdGroupID   string    `json:"ad_group_id,omitempty"`
+	Channel     string    `json:"channel"`
+	Keyword     string    `json:"keyword"`
+	MatchType   string    `json:"match_type"`
+	Clicks      int       `json:"clicks"`
+	Cost        Money     `json:"cost"`
+	ProcessedAt time.Time `json:"processed_at"`
+}
+
+// identifies the row: the keyword and match type of an ad group on a day
+func (k ProcessedKeywordData) Key() string {
+	return k.Date.Format("2006-01-02") + "|" + k.CampaignID + "|" + k.AdGroupID + "|" + k.Keyword + "|" + k.MatchType
+}
+
+// returns the row's value of a metrics dimension. Keyword rows carry no
+// UTMs.
+func (k ProcessedKeywordData) DimensionValue(dimension string) string {
+	switch dimension {
+	case DimensionChannel:
+		return k.Channel
+	case DimensionCampaignID:
+		return k.CampaignID
+	case DimensionAdGroupID:
+		return k.AdGroupID
+	}
+	return ""
+}
+
+// spend and clicks of a keyword and match type in an ad group over the
+// queried days
+type KeywordMetrics struct {
+	Keyword    string `json:"keyword"`
+	MatchType  string `json:"match_type"`
+	Channel    string `json:"channel"`
+	CampaignID string `json:"campaign_id"`
+	AdGroupID  string `json:"ad_group_id,omitempty"`
+	Clicks     int    `json:"clicks"`
+	Cost       Money  `json:"cost"`
+	CPC        Money  `json:"cpc"`
+	Days       int    `json:"days"` // with spend or clicks
+}
+
+// selects keyword rows; empty fields match everything
+type KeywordFilter struct {
+	From       time.Time
+	To         time.Time
+	Channel    string
+	CampaignID string
+	AdGroupID  string
+	MatchType  string
+	Keyword    string
+}
+
+func (f KeywordFilter) Validate() error {
+	if f.To.Before(f.From) {
+		return Errorf(ErrValidation, "to must not be before from")
+	}
+	if f.MatchType != "" && !slices.Contains(MatchTypes, f.MatchType) {
+		return Errorf(ErrValidation, "match_type must be one of %s", strings.Join(MatchTypes, ", "))
+	}
+	return nil
+}
+
+// reports whether the row is selected by the filter's dimensions
+func (f KeywordFilter) Matches(row ProcessedKeywordData) bool {
+	return (f.Channel == "" || row.Channel == f.Channel) &&
+		(f.CampaignID == "" || row.CampaignID == f.CampaignID) &&
+		(f.AdGroupID == "" || row.AdGroupID == f.AdGroupID) &&
+		(f.MatchType == "" || row.MatchType == f.MatchType) &&
+		(f.Keyword == "" || row.Keyword == NormalizeKeyword(f.Keyword))
+}
+
+// totals the rows per keyword, match type and ad group, highest spend first
+func KeywordMetricsOf(rows []ProcessedKeywordData) []KeywordMetrics {
+	type key struct{ channel, campaign, adGroup, keyword, matchType string }
+	totals := make(map[key]*KeywordMetrics)
+	for _, row := range rows {
+		k := key{row.Channel, row.CampaignID, row.AdGroupID, row.Keyword, row.MatchType}
+		total, ok := totals[k]
+		if !ok {
+			total = &KeywordMetrics{
+				Keyword:    row.Keyword,
+				MatchType:  row.MatchType,
+				Channel:    row.Channel,
+				CampaignID: row.CampaignID,
+				AdGroupID:  row.AdGroupID,
+			}
+			totals[k] = total
+		}
+		total.Clicks += row.Clicks
+		total.Cost += row.Cost
+		if row.Clicks > 0 || row.Cost != 0 {
+			total.Days++
+		}
+	}
+
+	result := make([]KeywordMetrics, 0, len(totals))
+	for _, total := range totals {
+		if total.Clicks > 0 {
+			total.CPC = total.Cost.Div(total.Clicks)
+		}
+		result = append(result, *total)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Cost != result[j].Cost {
+			return result[i].Cost > result[j].Cost
+		}
+		return keywordSortKey(result[i]) < keywordSortKey(result[j])
+	})
+	return result
+}
+
+func keywordSortKey(m KeywordMetrics) string {
+	return fmt.Sprintf("%s|%s|%s|%s|%s", m.Keyword, m.MatchType, m.Channel, m.CampaignID, m.AdGroupID)
+}
@@ -0,0 +1,27 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrMaintenance is returned for new jobs while maintenance mode is on
+var ErrMaintenance = errors.New("service is in maintenance mode")
+
+// implemented by components that start work on their own, such as the job
+// queue and schedulers, so maintenance mode can hold them
+type Pausable interface {
+	Pause()
+	Resume()
+}
+
+// represents the current maintenance mode state. RunningJobs reports work
+// admitted before maintenance started; storage is quiescent once it is zero.
+type MaintenanceStatus struct {
+	Enabled     bool       `json:"enabled"`
+	Reason      string     `json:"reason,omitempty"`
+	Actor       string     `json:"actor,omitempty"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	RetryAfter  int        `json:"retry_after_seconds,omitempty"`
+	RunningJobs int        `json:"running_jobs"`
+}
@@ -0,0 +1,41 @@
+package domain
+
+import "slices"
+
+// a metric as the metric catalog describes it to BI tools and docs. Type
+// is the metric's column type in flat reports, integer or number, and
+// Dimensions those its time series can be grouped by.
+type MetricDefinition struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Formula     string   `json:"formula"`
+	Unit        string   `json:"unit"`
+	Type        string   `json:"type"`
+	Dimensions  []string `json:"dimensions"`
+	Since       string   `json:"since"`
+}
+
+// MetricCatalog returns the definitions of the metrics the service
+// computes, in name order. It is generated from the metrics time series,
+// derived models and flat reports are calculated with, so it cannot drift
+// from them.
+func MetricCatalog() []MetricDefinition {
+	catalog := make([]MetricDefinition, 0, len(TimeSeriesMetrics))
+	for _, name := range TimeSeriesMetrics {
+		metric := timeSeriesMetrics[name]
+		definition := MetricDefinition{
+			Name:        name,
+			Description: metric.description,
+			Formula:     metric.formula,
+			Unit:        metric.unit,
+			Type:        "number",
+			Dimensions:  slices.Clone(Dimensions),
+			Since:       metric.since,
+		}
+		if i := slices.IndexFunc(FlatColumns, func(column ReportColumn) bool { return column.Name == name }); i >= 0 {
+			definition.Type = FlatColumns[i].Type
+		}
+		catalog = append(catalog, definition)
+	}
+	return catalog
+}
@@ -1,6 +1,9 @@
 package domain
 
 import (
+	"encoding/json"
+	"slices"
+	"sort"
 	"time"
 )
 
@@ -13,24 +16,106 @@ type BusinessMetrics struct {
 	UTMSource   string    `json:"utm_source"`
 	UTMMedium   string    `json:"utm_medium"`
 
+	// ad group whose ads the row totals. A UTM whose ads span several ad
+	// groups gets a row per ad group, and its opportunities, sessions and
+	// attributed revenue, which can't be told apart by ad group, go on its
+	// row without one.
+	AdGroupID string `json:"ad_group_id,omitempty"`
+
 	// Raw metrics
-	Clicks        int     `json:"clicks"`
-	Impressions   int     `json:"impressions"`
-	Cost          float64 `json:"cost"`
-	Leads         int     `json:"leads"`
-	Opportunities int     `json:"opportunities"`
-	ClosedWon     int     `json:"closed_won"`
-	Revenue       float64 `json:"revenue"`
+	Clicks        int   `json:"clicks"`
+	Impressions   int   `json:"impressions"`
+	Cost          Money `json:"cost"`
+	Leads         int   `json:"leads"`
+	Opportunities int   `json:"opportunities"`
+	ClosedWon     int   `json:"closed_won"`
+	Revenue       Money `json:"revenue"`
+
+	// opportunities in the configured funnel stages
+	Stages map[OpportunityStage]int `json:"stages,omitempty"`
+
+	// closed won revenue credited to the UTM by the attribution model, a
+	// fraction of opportunities that touched several UTMs
+	AttributedRevenue Money `json:"attributed_revenue"`
+
+	// the open leads and opportunities of the UTM at face value, and
+	// weighted by their win probability
+	PipelineValue   Money `json:"pipeline_value"`
+	ExpectedRevenue Money `json:"expected_revenue"`
+
+	// web analytics sessions and conversions of the UTM
+	Sessions    int `json:"sessions"`
+	Conversions int `json:"conversions"`
+
+	// impression weighted average quality score of the UTM's ads that
+	// reported one, see QualityScoreAverage
+	QualityScore float64 `json:"quality_score,omitempty"`
 
 	// Calculated metrics
-	CPC          float64 `json:"cpc"`
-	CPA          float64 `json:"cpa"`
-	CVRLeadToOpp float64 `json:"cvr_lead_to_opp"`
-	CVROppToWon  float64 `json:"cvr_opp_to_won"`
-	ROAS         float64 `json:"roas"`
+	CPC            Money   `json:"cpc"`
+	CPA            Money   `json:"cpa"`
+	CVRClickToLead float64 `json:"cvr_click_to_lead"`
+	CVRLeadToOpp   float64 `json:"cvr_lead_to_opp"`
+	CVROppToWon    float64 `json:"cvr_opp_to_won"`
+	ROAS           float64 `json:"roas"`
+
+	// conversion rates between every two adjacent funnel stages, e.g.
+	// mql_to_sql
+	StageConversions map[string]float64 `json:"stage_conversions,omitempty"`
 
 	// Metadata
 	CalculatedAt time.Time `json:"calculated_at"`
+
+	// version of the transform rules the row was calculated under, see
+	// TransformConfig
+	TransformVersion string `json:"transform_version,omitempty"`
+
+	// the pipeline that contributed the row through the batch API, empty
+	// for rows the ETL calculated. Rows of each producer are kept apart
+	// from the ETL's of the same date, UTM and ad group.
+	Producer string `json:"producer,omitempty"`
+
+	// columns hidden from the caller's role, left out when the row is
+	// serialized
+	Hidden ColumnMask `json:"-"`
+}
+
+func (m BusinessMetrics) MarshalJSON() ([]byte, error) {
+	type row BusinessMetrics
+	raw, err := json.Marshal(row(m))
+	if err != nil {
+		return nil, err
+	}
+	return m.Hidden.strip(raw)
+}
+
+// ChannelCRMOnly is the channel of the metrics of UTMs with opportunities but
+// no ads, calculated when they are included
+const ChannelCRMOnly = "crm_only"
+
+// returns the row's natural key, without its producer
+func (m BusinessMetrics) Key() MetricKey {
+	return MetricKey{
+		Date:        time.Date(m.Date.Year(), m.Date.Month(), m.Date.Day(), 0, 0, 0, 0, time.UTC),
+		UTMCampaign: m.UTMCampaign,
+		UTMSource:   m.UTMSource,
+		UTMMedium:   m.UTMMedium,
+		AdGroupID:   m.AdGroupID,
+	}
+}
+
+// returns the opportunities in the stage: the built-in counts or the
+// configured stage's
+func (m BusinessMetrics) StageCount(stage OpportunityStage) int {
+	switch stage {
+	case StageLead:
+		return m.Leads
+	case StageOpportunity:
+		return m.Opportunities
+	case StageClosedWon:
+		return m.ClosedWon
+	}
+	return m.Stages[stage]
 }
 
 // represents filters for querying metrics
@@ -42,8 +127,36 @@ type MetricsFilter struct {
 	UTMCampaign string     `json:"utm_campaign,omitempty"`
 	UTMSource   string     `json:"utm_source,omitempty"`
 	UTMMedium   string     `json:"utm_medium,omitempty"`
+	AdGroupID   string     `json:"ad_group_id,omitempty"`
 	Limit       int        `json:"limit,omitempty"`
 	Offset      int        `json:"offset,omitempty"`
+
+	// restricts the rows to the caller's scope on top of the filters
+	Scope MetricsScope `json:"-"`
+}
+
+// reports whether the metric matches the filter's dimensions and scope. The
+// date range is not checked.
+func (f MetricsFilter) Matches(metric BusinessMetrics) bool {
+	if f.Channel != "" && metric.Channel != f.Channel {
+		return false
+	}
+	if f.CampaignID != "" && metric.CampaignID != f.CampaignID {
+		return false
+	}
+	if f.UTMCampaign != "" && metric.UTMCampaign != f.UTMCampaign {
+		return false
+	}
+	if f.UTMSource != "" && metric.UTMSource != f.UTMSource {
+		return false
+	}
+	if f.UTMMedium != "" && metric.UTMMedium != f.UTMMedium {
+		return false
+	}
+	if f.AdGroupID != "" && metric.AdGroupID != f.AdGroupID {
+		return false
+	}
+	return f.Scope.Allows(metric)
 }
 
 // represents the API response for metrics queries
@@ -53,6 +166,145 @@ type MetricsResponse struct {
 	Limit   int               `json:"limit"`
 	Offset  int               `json:"offset"`
 	HasMore bool              `json:"has_more"`
+
+	// set when the data was read from a replica that may lag the primary
+	Staleness *Staleness `json:"staleness,omitempty"`
+
+	// how fresh the matching metrics are
+	Meta *MetricsMeta `json:"meta,omitempty"`
+
+	// click to lead totals of a funnel query
+	Funnel *FunnelBreakdown `json:"funnel,omitempty"`
+
+	// the estimated cost of the query when the caller has a row budget
+	QueryCost *QueryCost `json:"query_cost,omitempty"`
+
+	// set when the amounts were converted to a requested currency
+	Currency *CurrencyConversion `json:"currency,omitempty"`
+}
+
+// click to lead totals of every row matching a funnel query, with the leads
+// split by utm_source
+type FunnelBreakdown struct {
+	Clicks         int          `json:"clicks"`
+	Leads          int          `json:"leads"`
+	CVRClickToLead float64      `json:"cvr_click_to_lead"`
+	LeadSources    []LeadSource `json:"lead_sources"`
+}
+
+// the leads of one utm_source within a funnel. LeadShare is its fraction of
+// the funnel's leads.
+type LeadSource struct {
+	UTMSource      string  `json:"utm_source"`
+	Clicks         int     `json:"clicks"`
+	Leads          int     `json:"leads"`
+	CVRClickToLead float64 `json:"cvr_click_to_lead"`
+	LeadShare      float64 `json:"lead_share"`
+}
+
+// builds the funnel breakdown of a set of metrics, sources ordered by leads
+func NewFunnelBreakdown(metrics []BusinessMetrics) *FunnelBreakdown {
+	funnel := &FunnelBreakdown{LeadSources: []LeadSource{}}
+	bySource := make(map[string]*LeadSource)
+	for _, metric := range metrics {
+		funnel.Clicks += metric.Clicks
+		funnel.Leads += metric.Leads
+
+		source, ok := bySource[metric.UTMSource]
+		if !ok {
+			source = &LeadSource{UTMSource: metric.UTMSource}
+			bySource[metric.UTMSource] = source
+		}
+		source.Clicks += metric.Clicks
+		source.Leads += metric.Leads
+	}
+
+	funnel.CVRClickToLead = conversionRate(funnel.Leads, funnel.Clicks)
+	for _, source := range bySource {
+		source.CVRClickToLead = conversionRate(source.Leads, source.Clicks)
+		source.LeadShare = conversionRate(source.Leads, funnel.Leads)
+		funnel.LeadSources = append(funnel.LeadSources, *source)
+	}
+	sort.Slice(funnel.LeadSources, func(i, j int) bool {
+		a, b := funnel.LeadSources[i], funnel.LeadSources[j]
+		if a.Leads != b.Leads {
+			return a.Leads > b.Leads
+		}
+		return a.UTMSource < b.UTMSource
+	})
+	return funnel
+}
+
+// divides with division by zero protection
+func conversionRate(converted, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(converted) / float64(total)
+}
+
+// describes how fresh metrics are so consumers can display it and detect
+// stale data. DataThroughDate and CalculatedAt cover every matching row, not
+// just the returned page; they are empty when nothing matched.
+type MetricsMeta struct {
+	LastETLRunAt      *time.Time        `json:"last_etl_run_at,omitempty"`
+	DataThroughDate   string            `json:"data_through_date,omitempty"`
+	CalculatedAt      *CalculatedAtSpan `json:"calculated_at,omitempty"`
+	TransformVersions []string          `json:"transform_versions,omitempty"` // sorted, more than one when the rules changed
+}
+
+// the oldest and newest calculation time of a set of metrics
+type CalculatedAtSpan struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+// builds the freshness metadata of a set of metrics
+func NewMetricsMeta(metrics []BusinessMetrics) *MetricsMeta {
+	meta := &MetricsMeta{}
+	var through time.Time
+	for _, metric := range metrics {
+		if metric.Date.After(through) {
+			through = metric.Date
+		}
+		if metric.TransformVersion != "" && !slices.Contains(meta.TransformVersions, metric.TransformVersion) {
+			meta.TransformVersions = append(meta.TransformVersions, metric.TransformVersion)
+		}
+		if metric.CalculatedAt.IsZero() {
+			continue
+		}
+		if meta.CalculatedAt == nil {
+			meta.CalculatedAt = &CalculatedAtSpan{From: metric.CalculatedAt, To: metric.CalculatedAt}
+			continue
+		}
+		if metric.CalculatedAt.Before(meta.CalculatedAt.From) {
+			meta.CalculatedAt.From = metric.CalculatedAt
+		}
+		if metric.CalculatedAt.After(meta.CalculatedAt.To) {
+			meta.CalculatedAt.To = metric.CalculatedAt
+		}
+	}
+	if !through.IsZero() {
+		meta.DataThroughDate = through.Format("2006-01-02")
+	}
+	slices.Sort(meta.TransformVersions)
+	return meta
+}
+
+// describes how far behind the primary a replica read may be
+type Staleness struct {
+	Source     string    `json:"source"`
+	LagSeconds float64   `json:"lag_seconds"`
+	MeasuredAt time.Time `json:"measured_at"`
+}
+
+// describes data left out of a partial result because its source could
+// not be read
+type DataWarning struct {
+	Source string `json:"source"`
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Reason string `json:"reason"`
 }
 
 // represents data structure for export functionality
@@ -62,14 +314,85 @@ type ExportData struct {
 	CampaignID    string  `json:"campaign_id"`
 	Clicks        int     `json:"clicks"`
 	Impressions   int     `json:"impressions"`
-	Cost          float64 `json:"cost"`
+	Cost          Money   `json:"cost"`
 	Leads         int     `json:"leads"`
 	Opportunities int     `json:"opportunities"`
 	ClosedWon     int     `json:"closed_won"`
-	Revenue       float64 `json:"revenue"`
-	CPC           float64 `json:"cpc"`
-	CPA           float64 `json:"cpa"`
+	Revenue       Money   `json:"revenue"`
+	CPC           Money   `json:"cpc"`
+	CPA           Money   `json:"cpa"`
 	CVRLeadToOpp  float64 `json:"cvr_lead_to_opp"`
 	CVROppToWon   float64 `json:"cvr_opp_to_won"`
 	ROAS          float64 `json:"roas"`
+	Currency      string  `json:"currency,omitempty"`
+}
+
+// dimensions supported by distinct value lookups
+const (
+	DimensionChannel     = "channel"
+	DimensionCampaignID  = "campaign_id"
+	DimensionUTMCampaign = "utm_campaign"
+	DimensionUTMSource   = "utm_source"
+	DimensionUTMMedium   = "utm_medium"
+	DimensionAdGroupID   = "ad_group_id"
+)
+
+// Dimensions lists every dimension that can be queried for distinct values
+var Dimensions = []string{
+	DimensionChannel,
+	DimensionCampaignID,
+	DimensionUTMCampaign,
+	DimensionUTMSource,
+	DimensionUTMMedium,
+	DimensionAdGroupID,
+}
+
+// IsValidDimension reports whether name is a supported dimension
+func IsValidDimension(name string) bool {
+	for _, d := range Dimensions {
+		if d == name {
+			return true
+		}
+	}
+	return false
+}
+
+// DimensionValue returns the value of the given dimension for the metric
+func (m BusinessMetrics) DimensionValue(name string) string {
+	switch name {
+	case DimensionChannel:
+		return m.Channel
+	case DimensionCampaignID:
+		return m.CampaignID
+	case DimensionUTMCampaign:
+		return m.UTMCampaign
+	case DimensionUTMSource:
+		return m.UTMSource
+	case DimensionUTMMedium:
+		return m.UTMMedium
+	case DimensionAdGroupID:
+		return m.AdGroupID
+	}
+	return ""
+}
+
+// represents a distinct dimension value and how many metric rows carry it
+type DimensionValue struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// returns the counted values most frequent first, alphabetical for ties
+func SortedDimensionValues(counts map[string]int) []DimensionValue {
+	values := make([]DimensionValue, 0, len(counts))
+	for value, count := range counts {
+		values = append(values, DimensionValue{Value: value, Count: count})
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if values[i].Count != values[j].Count {
+			return values[i].Count > values[j].Count
+		}
+		return values[i].Value < values[j].Value
+	})
+	return values
 }
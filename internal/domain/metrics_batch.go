@@ -0,0 +1,114 @@
+package domain
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// pre-computed metrics another pipeline contributes through the batch API.
+// A batch is written whole or not at all.
+type MetricsBatch struct {
+	Metrics []BusinessMetrics `json:"metrics"`
+}
+
+// outcome of a batch write: the rows written for the producer and the
+// dates they span, or the rows that made the batch invalid
+type MetricsBatchResult struct {
+	Producer string        `json:"producer"`
+	Accepted int           `json:"accepted"`
+	From     string        `json:"from,omitempty"`
+	To       string        `json:"to,omitempty"`
+	Errors   []RecordError `json:"errors,omitempty"`
+}
+
+// validates the rows of the batch written by producer on today: each needs
+// a date no later than today, a channel and a full UTM, non-negative counts
+// and amounts, and stages of the funnel, and no two may share a date, UTM
+// and ad group. Derived ratios are recalculated on write, so they are not checked.
+func (b MetricsBatch) Validate(producer string, funnel Funnel, today time.Time) []RecordError {
+	var errs []RecordError
+	seen := make(map[MetricKey]int, len(b.Metrics))
+	for i, metric := range b.Metrics {
+		record := "metrics[" + strconv.Itoa(i) + "]"
+		invalid := func(field, value, reason string) {
+			errs = append(errs, RecordError{Record: record, Field: field, Value: value, Reason: reason})
+		}
+
+		switch {
+		case metric.Date.IsZero():
+			invalid("date", "", "date is required")
+		case metric.Date.UTC().After(today):
+			invalid("date", metric.Date.Format("2006-01-02"), "date is in the future")
+		}
+		for _, field := range []struct{ name, value string }{
+			{"channel", metric.Channel},
+			{"utm_campaign", metric.UTMCampaign},
+			{"utm_source", metric.UTMSource},
+			{"utm_medium", metric.UTMMedium},
+		} {
+			if field.value == "" {
+				invalid(field.name, "", field.name+" is required")
+			}
+		}
+		if metric.Producer != "" && metric.Producer != producer {
+			invalid("producer", metric.Producer, "producer must be the API key's, "+producer)
+		}
+
+		for _, field := range []struct {
+			name  string
+			count int
+		}{
+			{"clicks", metric.Clicks},
+			{"impressions", metric.Impressions},
+			{"leads", metric.Leads},
+			{"opportunities", metric.Opportunities},
+			{"closed_won", metric.ClosedWon},
+			{"sessions", metric.Sessions},
+			{"conversions", metric.Conversions},
+		} {
+			if field.count < 0 {
+				invalid(field.name, strconv.Itoa(field.count), field.name+" must not be negative")
+			}
+		}
+		for _, field := range []struct {
+			name   string
+			amount Money
+		}{
+			{"cost", metric.Cost},
+			{"revenue", metric.Revenue},
+			{"attributed_revenue", metric.AttributedRevenue},
+			{"pipeline_value", metric.PipelineValue},
+			{"expected_revenue", metric.ExpectedRevenue},
+		} {
+			if field.amount < 0 {
+				invalid(field.name, field.amount.String(), field.name+" must not be negative")
+			}
+		}
+		if metric.QualityScore < 0 || metric.QualityScore > 10 {
+			invalid("quality_score", strconv.FormatFloat(metric.QualityScore, 'f', -1, 64), "quality score must be between 0 and 10")
+		}
+		for _, stage := range slices.Sorted(maps.Keys(metric.Stages)) {
+			switch count := metric.Stages[stage]; {
+			case !funnel.IsConfigured(stage):
+				invalid("stages", string(stage), "stage is not a configured funnel stage")
+			case count < 0:
+				invalid("stages", string(stage), "stage count must not be negative")
+			}
+		}
+
+		if metric.Date.IsZero() {
+			continue
+		}
+		metric.Date = metric.Date.UTC()
+		key := metric.Key()
+		if first, ok := seen[key]; ok {
+			invalid("date", metric.Date.Format("2006-01-02"), fmt.Sprintf("duplicates the date, UTM and ad group of metrics[%d]", first))
+			continue
+		}
+		seen[key] = i
+	}
+	return errs
+}
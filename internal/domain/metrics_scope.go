@@ -0,0 +1,152 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"slices"
+)
+
+// returned for metrics queries filtering on values outside the caller's scope
+var ErrOutOfScope = NewError(ErrForbidden, "outside the API key's scope")
+
+// the dimension values a caller may see, e.g. {"channel": ["google_ads"]}.
+// A metric is in scope when its value of every scoped dimension is listed;
+// an empty scope allows everything.
+type MetricsScope map[string][]string
+
+func (s MetricsScope) Validate() error {
+	for dimension, values := range s {
+		if !IsValidDimension(dimension) {
+			return fmt.Errorf("unsupported scope dimension %q", dimension)
+		}
+		if len(values) == 0 {
+			return fmt.Errorf("scope of %s lists no values", dimension)
+		}
+	}
+	return nil
+}
+
+// reports whether the value of the dimension is in scope
+func (s MetricsScope) AllowsValue(dimension, value string) bool {
+	values, scoped := s[dimension]
+	return !scoped || slices.Contains(values, value)
+}
+
+// a record carrying dimension values, e.g. a metric, ad or suggested
+// action
+type Dimensioned interface {
+	DimensionValue(dimension string) string
+}
+
+// reports whether the metric is in scope
+func (s MetricsScope) Allows(metric BusinessMetrics) bool {
+	return s.AllowsRecord(metric)
+}
+
+// reports whether the record's value of every scoped dimension is in
+// scope. Records without a value of a scoped dimension, e.g. opportunities
+// under a channel scope, are not.
+func (s MetricsScope) AllowsRecord(record Dimensioned) bool {
+	for dimension := range s {
+		if !s.AllowsValue(dimension, record.DimensionValue(dimension)) {
+			return false
+		}
+	}
+	return true
+}
+
+// reports whether the scope restricts no dimension but the given one, so
+// totals grouped by that dimension only cover records in scope
+func (s MetricsScope) OnlyRestricts(dimension string) bool {
+	for scoped := range s {
+		if scoped != dimension {
+			return false
+		}
+	}
+	return true
+}
+
+// returns the records in the scope, all of them when it is empty
+func InScope[T Dimensioned](scope MetricsScope, records []T) []T {
+	if len(scope) == 0 {
+		return records
+	}
+	allowed := make([]T, 0, len(records))
+	for _, record := range records {
+		if scope.AllowsRecord(record) {
+			allowed = append(allowed, record)
+		}
+	}
+	return allowed
+}
+
+// returns ErrOutOfScope when the filter asks for a dimension value outside
+// the scope
+func (s MetricsScope) Check(filter MetricsFilter) error {
+	filtered := []string{filter.Channel, filter.CampaignID, filter.UTMCampaign, filter.UTMSource, filter.UTMMedium, filter.AdGroupID}
+	for i, dimension := range []string{DimensionChannel, DimensionCampaignID, DimensionUTMCampaign, DimensionUTMSource, DimensionUTMMedium, DimensionAdGroupID} {
+		if value := filtered[i]; value != "" && !s.AllowsValue(dimension, value) {
+			return fmt.Errorf("%w: %s %q", ErrOutOfScope, dimension, value)
+		}
+	}
+	return nil
+}
+
+// returns the scope of the API key carried by the context, nil when queries
+// are unrestricted
+func MetricsScopeFromContext(ctx context.Context) MetricsScope {
+	if key := APIKeyFromContext(ctx); key != nil {
+		return key.Scope
+	}
+	return nil
+}
+
+// returns the source and channel totals of a comparison the scope allows:
+// source totals span every dimension value and are dropped, and channel
+// totals are kept only for channels in scope, when the scope restricts
+// nothing but the channel
+func (s MetricsScope) comparisons(sources map[string]*SourceComparison, channels map[string]*ChannelComparison) (map[string]*SourceComparison, map[string]*ChannelComparison) {
+	if len(s) == 0 {
+		return sources, channels
+	}
+	allowed := map[string]*ChannelComparison{}
+	if s.OnlyRestricts(DimensionChannel) {
+		for channel, comparison := range channels {
+			if s.AllowsValue(DimensionChannel, channel) {
+				allowed[channel] = comparison
+			}
+		}
+	}
+	return map[string]*SourceComparison{}, allowed
+}
+
+// returns the comparison restricted to the scope
+func (c RunComparison) InScope(scope MetricsScope) RunComparison {
+	c.Sources, c.Channels = scope.comparisons(c.Sources, c.Channels)
+	return c
+}
+
+// returns the result restricted to the scope. Its parse and value reports
+// span every record, so scoped results go without them.
+func (r ShadowResult) InScope(scope MetricsScope) ShadowResult {
+	if len(scope) == 0 {
+		return r
+	}
+	r.Sources, r.Channels = scope.comparisons(r.Sources, r.Channels)
+	r.Metrics = InScope(scope, r.Metrics)
+	r.Parsing, r.Values = map[string]*ParseReport{}, nil
+	return r
+}
+
+// returns the snapshot restricted to the scope
+func (s EventSnapshot) InScope(scope MetricsScope) EventSnapshot {
+	s.Ads = InScope(scope, s.Ads)
+	s.Opportunities = InScope(scope, s.Opportunities)
+	return s
+}
+
+// returns the feed restricted to the scope
+func (f ActionFeed) InScope(scope MetricsScope) ActionFeed {
+	f.Actions = InScope(scope, f.Actions)
+	return f
+}
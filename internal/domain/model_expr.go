@@ -0,0 +1,174 @@
+package domain
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// an expression of a derived model column evaluated over the totals of a
+// row. ok is false when the value is undefined, e.g. a division by zero.
+type modelExpr func(t ChannelTotals) (value float64, ok bool)
+
+// parses an arithmetic expression over the metrics a time series can chart,
+// e.g. "revenue / (cost + 100)". Ratios such as cpc are derived from the
+// row's totals like in time series.
+func parseModelExpr(source string) (modelExpr, error) {
+	p := &exprParser{tokens: tokenizeModelExpr(source)}
+	expr, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+	if token := p.peek(); token != "" {
+		return nil, fmt.Errorf("unexpected %q", token)
+	}
+	return expr, nil
+}
+
+// splits the expression into numbers, identifiers, operators and
+// parentheses
+func tokenizeModelExpr(source string) []string {
+	var tokens []string
+	for i := 0; i < len(source); {
+		r := rune(source[i])
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case unicode.IsDigit(r) || r == '.':
+			j := i
+			for j < len(source) && (unicode.IsDigit(rune(source[j])) || source[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, source[i:j])
+			i = j
+		case unicode.IsLetter(r) || r == '_':
+			j := i
+			for j < len(source) && (unicode.IsLetter(rune(source[j])) || unicode.IsDigit(rune(source[j])) || source[j] == '_') {
+				j++
+			}
+			tokens = append(tokens, source[i:j])
+			i = j
+		default:
+			tokens = append(tokens, source[i:i+1])
+			i++
+		}
+	}
+	return tokens
+}
+
+type exprParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *exprParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *exprParser) next() string {
+	token := p.peek()
+	p.pos++
+	return token
+}
+
+// sum := product (("+" | "-") product)*
+func (p *exprParser) parseSum() (modelExpr, error) {
+	left, err := p.parseProduct()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "+" || p.peek() == "-" {
+		op := p.next()
+		right, err := p.parseProduct()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryModelExpr(op, left, right)
+	}
+	return left, nil
+}
+
+// product := operand (("*" | "/") operand)*
+func (p *exprParser) parseProduct() (modelExpr, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "*" || p.peek() == "/" {
+		op := p.next()
+		right, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryModelExpr(op, left, right)
+	}
+	return left, nil
+}
+
+// operand := number | metric | "(" sum ")" | "-" operand
+func (p *exprParser) parseOperand() (modelExpr, error) {
+	token := p.next()
+	switch {
+	case token == "":
+		return nil, fmt.Errorf("unexpected end of expression")
+	case token == "(":
+		inner, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+		if p.next() != ")" {
+			return nil, fmt.Errorf("missing closing parenthesis")
+		}
+		return inner, nil
+	case token == "-":
+		operand, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		return func(t ChannelTotals) (float64, bool) {
+			v, ok := operand(t)
+			return -v, ok
+		}, nil
+	case unicode.IsDigit(rune(token[0])) || token[0] == '.':
+		number, err := strconv.ParseFloat(token, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", token)
+		}
+		return func(ChannelTotals) (float64, bool) { return number, true }, nil
+	}
+
+	metric, ok := timeSeriesMetrics[token]
+	if !ok {
+		return nil, fmt.Errorf("unknown metric %q, use %s", token, strings.Join(TimeSeriesMetrics, ", "))
+	}
+	return modelExpr(metric.value), nil
+}
+
+func binaryModelExpr(op string, left, right modelExpr) modelExpr {
+	return func(t ChannelTotals) (float64, bool) {
+		l, ok := left(t)
+		if !ok {
+			return 0, false
+		}
+		r, ok := right(t)
+		if !ok {
+			return 0, false
+		}
+		switch op {
+		case "+":
+			return l + r, true
+		case "-":
+			return l - r, true
+		case "*":
+			return l * r, true
+		}
+		if r == 0 {
+			return 0, false
+		}
+		return l / r, true
+	}
+}
@@ -0,0 +1,163 @@
+package domain
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// number of Money units in one currency unit
+const moneyScale = 1_000_000
+
+// a monetary amount stored as an integer number of millionths of the
+// currency unit, so sums over many rows do not accumulate float rounding
+// errors. It serializes to JSON as a plain decimal number.
+type Money int64
+
+// converts a float amount, rounding to the nearest millionth
+func MoneyFromFloat(f float64) Money {
+	return Money(math.Round(f * moneyScale))
+}
+
+// parses a decimal string such as "-1234.56", with at most one leading
+// sign, exactly. Digits beyond the sixth decimal place are rounded half
+// away from zero.
+func ParseMoney(s string) (Money, error) {
+	value := strings.TrimSpace(s)
+	negative := strings.HasPrefix(value, "-")
+	if negative || strings.HasPrefix(value, "+") {
+		value = value[1:]
+	}
+
+	integer, fraction, _ := strings.Cut(value, ".")
+	if integer == "" && fraction == "" || !allDigits(integer) || !allDigits(fraction) {
+		return 0, fmt.Errorf("invalid decimal amount %q", s)
+	}
+
+	units := int64(0)
+	if integer != "" {
+		var err error
+		if units, err = strconv.ParseInt(integer, 10, 64); err != nil || units > math.MaxInt64/moneyScale {
+			return 0, fmt.Errorf("amount %q out of range", s)
+		}
+	}
+
+	roundUp := false
+	if len(fraction) > 6 {
+		roundUp = fraction[6] >= '5'
+		fraction = fraction[:6]
+	}
+	fraction += strings.Repeat("0", 6-len(fraction))
+	micros, _ := strconv.ParseInt(fraction, 10, 64)
+	if roundUp {
+		micros++
+	}
+	// The largest whole units still leave room for only part of a fraction
+	if units > (math.MaxInt64-micros)/moneyScale {
+		return 0, fmt.Errorf("amount %q out of range", s)
+	}
+
+	amount := units*moneyScale + micros
+	if negative {
+		amount = -amount
+	}
+	return Money(amount), nil
+}
+
+// returns the amount as a float for ratios and display
+func (m Money) Float64() float64 {
+	return float64(m) / moneyScale
+}
+
+// returns the amount divided by n, rounded half away from zero. Dividing by
+// zero returns zero.
+func (m Money) Div(n int) Money {
+	if n == 0 {
+		return 0
+	}
+	return Money(math.Round(float64(m) / float64(n)))
+}
+
+// returns the amount multiplied by a weight, rounded half away from zero
+func (m Money) Scale(weight float64) Money {
+	return Money(math.Round(float64(m) * weight))
+}
+
+// returns the ratio of two amounts, or zero when the divisor is zero
+func (m Money) Ratio(divisor Money) float64 {
+	if divisor == 0 {
+		return 0
+	}
+	return float64(m) / float64(divisor)
+}
+
+// formats the amount as a decimal without trailing zeros, e.g. "890.5"
+func (m Money) String() string {
+	var buf [24]byte
+	return string(m.appendDecimal(buf[:0]))
+}
+
+// writes the amount as a JSON number so existing consumers keep working
+func (m Money) MarshalJSON() ([]byte, error) {
+	return m.appendDecimal(make([]byte, 0, 24)), nil
+}
+
+// appends the amount as a decimal without trailing zeros. Amounts are
+// encoded for every fingerprinted and exported record, so this avoids
+// intermediate strings.
+func (m Money) appendDecimal(dst []byte) []byte {
+	amount := int64(m)
+	if amount < 0 {
+		dst = append(dst, '-')
+		amount = -amount
+	}
+
+	dst = strconv.AppendInt(dst, amount/moneyScale, 10)
+	fraction := amount % moneyScale
+	if fraction == 0 {
+		return dst
+	}
+
+	var digits [6]byte
+	for i := len(digits) - 1; i >= 0; i-- {
+		digits[i] = byte('0' + fraction%10)
+		fraction /= 10
+	}
+	n := len(digits)
+	for digits[n-1] == '0' {
+		n--
+	}
+	dst = append(dst, '.')
+	return append(dst, digits[:n]...)
+}
+
+// reads a JSON number or a quoted decimal string without going through float64
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.Trim(data, `"`)
+	if string(data) == "null" {
+		return nil
+	}
+
+	parsed, err := ParseMoney(string(data))
+	if err != nil {
+		// exponent notation is valid JSON but not a plain decimal
+		f, floatErr := strconv.ParseFloat(string(data), 64)
+		if floatErr != nil {
+			return err
+		}
+		parsed = MoneyFromFloat(f)
+	}
+	*m = parsed
+	return nil
+}
+
+func allDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,50 @@
+package domain
+
+import "testing"
+
+// amounts as upstream APIs send them
+var benchmarkAmounts = []string{"0", "12.5", "1234.56", "-99.999999", "1000000", "0.0000015"}
+
+func BenchmarkParseMoney(b *testing.B) {
+	b.ReportAllocs()
+	for b.Loop() {
+		for _, amount := range benchmarkAmounts {
+			if _, err := ParseMoney(amount); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkMoneyMarshalJSON(b *testing.B) {
+	amounts := make([]Money, len(benchmarkAmounts))
+	for i, amount := range benchmarkAmounts {
+		amounts[i], _ = ParseMoney(amount)
+	}
+
+	b.ReportAllocs()
+	for b.Loop() {
+		for _, amount := range amounts {
+			if _, err := amount.MarshalJSON(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkMoneyUnmarshalJSON(b *testing.B) {
+	data := make([][]byte, len(benchmarkAmounts))
+	for i, amount := range benchmarkAmounts {
+		data[i] = []byte(amount)
+	}
+
+	b.ReportAllocs()
+	for b.Loop() {
+		var m Money
+		for _, raw := range data {
+			if err := m.UnmarshalJSON(raw); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
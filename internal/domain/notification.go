@@ -0,0 +1,105 @@
+package domain
+
+import (
+	"fmt"
+	"slices"
+)
+
+// ErrNotificationChannelNotFound is returned for unknown notification channels
+var ErrNotificationChannelNotFound = NewError(ErrNotFound, "notification channel not found")
+
+// notification channel types
+const (
+	ChannelSlack   = "slack"
+	ChannelWebhook = "webhook"
+	ChannelEmail   = "email"
+)
+
+// events channels can subscribe to
+const (
+	EventRunCompleted    = "run_completed"
+	EventRunFailed       = "run_failed"
+	EventApprovalPending = "approval_pending"
+	EventActions         = "actions_suggested"
+	EventExportStuck     = "export_stuck"
+)
+
+// a destination for run notifications. Template and Subject are Go
+// text/templates executed with a Notification; empty ones use the channel
+// type's default.
+type NotificationChannel struct {
+	Name         string   `json:"name"`
+	Type         string   `json:"type"`
+	URL          string   `json:"url,omitempty"` // slack and webhook
+	To           []string `json:"to,omitempty"`  // email
+	Events       []string `json:"events,omitempty"`
+	Pipelines    []string `json:"pipelines,omitempty"` // only runs of these pipelines, all when empty
+	Subject      string   `json:"subject,omitempty"`   // email
+	Template     string   `json:"template,omitempty"`
+	TemplateFile string   `json:"template_file,omitempty"` // read into Template when loaded
+	ContentType  string   `json:"content_type,omitempty"`  // webhook
+}
+
+// Validate checks the channel and fills in defaults
+func (c *NotificationChannel) Validate() error {
+	if c.Name == "" {
+		return fmt.Errorf("notification channel name is required")
+	}
+	switch c.Type {
+	case ChannelSlack, ChannelWebhook:
+		if c.URL == "" {
+			return fmt.Errorf("%s: %s channel requires a url", c.Name, c.Type)
+		}
+	case ChannelEmail:
+		if len(c.To) == 0 {
+			return fmt.Errorf("%s: email channel requires recipients", c.Name)
+		}
+	default:
+		return fmt.Errorf("%s: unsupported channel type %q", c.Name, c.Type)
+	}
+
+	if len(c.Events) == 0 {
+		c.Events = []string{EventRunFailed}
+	}
+	for _, event := range c.Events {
+		if event != EventRunCompleted && event != EventRunFailed && event != EventApprovalPending && event != EventActions && event != EventExportStuck {
+			return fmt.Errorf("%s: unsupported event %q", c.Name, event)
+		}
+	}
+	if c.Type == ChannelWebhook && c.ContentType == "" {
+		c.ContentType = "application/json"
+	}
+	return nil
+}
+
+// returns true if the channel is notified of the event for the run.
+// Events without a run, such as pending approvals, suggested actions and
+// stuck exports, ignore the pipeline filter.
+func (c *NotificationChannel) Wants(event string, run *RunRecord) bool {
+	if !slices.Contains(c.Events, event) {
+		return false
+	}
+	return run == nil || len(c.Pipelines) == 0 || slices.Contains(c.Pipelines, run.Pipeline)
+}
+
+// the data notification templates are executed with: the run of run
+// events, the approval request of approval events, the suggested actions
+// of action events, or the exports of stuck export events
+type Notification struct {
+	Event       string           `json:"event"`
+	Channel     string           `json:"channel"`
+	Run         *RunRecord       `json:"run,omitempty"`
+	RunURL      string           `json:"run_url,omitempty"` // run detail endpoint
+	Approval    *ApprovalRequest `json:"approval,omitempty"`
+	ApprovalURL string           `json:"approval_url,omitempty"` // approval detail endpoint
+	Actions     []Action         `json:"actions,omitempty"`
+	ActionsURL  string           `json:"actions_url,omitempty"` // action feed endpoint
+	Exports     []ExportDelivery `json:"exports,omitempty"`
+	ExportsURL  string           `json:"exports_url,omitempty"` // stuck delivery list endpoint
+}
+
+// a rendered notification
+type NotificationMessage struct {
+	Subject string `json:"subject,omitempty"`
+	Body    string `json:"body"`
+}
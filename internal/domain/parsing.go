@@ -0,0 +1,109 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrParseThreshold = errors.New("parse error threshold exceeded")
+
+// how a run reacts to rows that cannot be decoded or normalized
+type ParseMode string
+
+const (
+	ParseModeLenient   ParseMode = "lenient"   // skip bad rows and report them
+	ParseModeStrict    ParseMode = "strict"    // fail once MaxErrors rows are rejected
+	ParseModeThreshold ParseMode = "threshold" // fail when the rejected share exceeds MaxErrorPercent
+)
+
+// maximum number of rejected rows kept in a parse report
+const maxReportedRecordErrors = 20
+
+// parsing mode with its limits
+type ParsePolicy struct {
+	Mode            ParseMode `json:"mode"`
+	MaxErrors       int       `json:"max_errors,omitempty"`
+	MaxErrorPercent float64   `json:"max_error_percent,omitempty"`
+}
+
+// Validate checks the policy and fills in defaults
+func (p *ParsePolicy) Validate() error {
+	switch p.Mode {
+	case "":
+		p.Mode = ParseModeLenient
+	case ParseModeLenient:
+	case ParseModeStrict:
+		if p.MaxErrors < 0 {
+			return fmt.Errorf("max_errors must not be negative")
+		}
+		if p.MaxErrors == 0 {
+			p.MaxErrors = 1
+		}
+	case ParseModeThreshold:
+		if p.MaxErrorPercent < 0 || p.MaxErrorPercent > 100 {
+			return fmt.Errorf("max_error_percent must be between 0 and 100")
+		}
+	default:
+		return fmt.Errorf("unsupported parse mode %q", p.Mode)
+	}
+	return nil
+}
+
+// describes a row rejected during decoding or normalization
+type RecordError struct {
+	Record string `json:"record"`
+	Field  string `json:"field,omitempty"`
+	Value  string `json:"value,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// per-source parsing outcome of a run
+type ParseReport struct {
+	Mode      ParseMode     `json:"mode"`
+	Total     int           `json:"total"`
+	Accepted  int           `json:"accepted"`
+	Rejected  int           `json:"rejected"`
+	ErrorRate float64       `json:"error_rate"` // percent of rows rejected
+	Errors    []RecordError `json:"errors,omitempty"`
+	Failed    bool          `json:"failed"`
+}
+
+// counts a rejected row with its field errors, keeping the first few for the report
+func (r *ParseReport) Reject(errs ...RecordError) {
+	r.Total++
+	r.Rejected++
+	for _, err := range errs {
+		if len(r.Errors) < maxReportedRecordErrors {
+			r.Errors = append(r.Errors, err)
+		}
+	}
+}
+
+// counts an accepted row
+func (r *ParseReport) Accept() {
+	r.Total++
+	r.Accepted++
+}
+
+// applies the policy to the report, marking it failed and returning
+// ErrParseThreshold when the run must not continue
+func (p ParsePolicy) Evaluate(source string, r *ParseReport) error {
+	r.Mode = p.Mode
+	if r.Total > 0 {
+		r.ErrorRate = float64(r.Rejected) / float64(r.Total) * 100
+	}
+
+	switch p.Mode {
+	case ParseModeStrict:
+		if r.Rejected >= p.MaxErrors {
+			r.Failed = true
+			return fmt.Errorf("%w: %s rejected %d rows (max %d)", ErrParseThreshold, source, r.Rejected, p.MaxErrors)
+		}
+	case ParseModeThreshold:
+		if r.ErrorRate > p.MaxErrorPercent {
+			r.Failed = true
+			return fmt.Errorf("%w: %s rejected %.2f%% of rows (max %.2f%%)", ErrParseThreshold, source, r.ErrorRate, p.MaxErrorPercent)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+var (
+	ErrPipelineNotFound = NewError(ErrNotFound, "pipeline not found")
+	ErrPipelineExists   = NewError(ErrConflict, "pipeline already exists")
+	ErrInvalidPipeline  = NewError(ErrValidation, "invalid pipeline")
+)
+
+// data sources that can be extracted
+const (
+	SourceAds = "ads"
+	SourceCRM = "crm"
+	SourceGA4 = "ga4"
+
+	// keyword level ads, a dataset of its own next to the campaign ads
+	SourceKeywords = "keywords"
+)
+
+// returns true if the source can be extracted
+func IsSource(source string) bool {
+	return source == SourceAds || source == SourceCRM || source == SourceGA4 || source == SourceKeywords
+}
+
+// destinations a pipeline can export to after a run
+const (
+	DestinationSink = "sink"
+)
+
+// represents a named, pre-approved ETL run configuration
+type Pipeline struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Sources     []string `json:"sources"`
+	SinceDays   int      `json:"since_days,omitempty"`
+	// metrics are attributed service-wide by ATTRIBUTION_MODE, since every
+	// run recalculates the stored metrics; the field is only kept to reject
+	// pipelines setting it
+	AttributionModel string                 `json:"attribution_model,omitempty"`
+	Destinations     []string               `json:"destinations,omitempty"`
+	Schedule         string                 `json:"schedule,omitempty"`
+	BusinessDaysOnly bool                   `json:"business_days_only,omitempty"`
+	HolidayCalendar  string                 `json:"holiday_calendar,omitempty"`
+	Parsing          map[string]ParsePolicy `json:"parsing,omitempty"`
+	// clears the replaced late-data window when a run extracts no ads,
+	// instead of failing the run
+	AllowEmptyReplace bool      `json:"allow_empty_replace,omitempty"`
+	Version           int       `json:"version"`
+	CreatedBy         string    `json:"created_by"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedBy         string    `json:"updated_by"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+// records a change to a pipeline for auditing
+type PipelineRevision struct {
+	Version   int       `json:"version"`
+	Action    string    `json:"action"`
+	ChangedBy string    `json:"changed_by"`
+	ChangedAt time.Time `json:"changed_at"`
+	Pipeline  Pipeline  `json:"pipeline"`
+}
+
+// Validate checks the pipeline definition and fills in defaults
+func (p *Pipeline) Validate() error {
+	if p.Name == "" {
+		return invalidPipeline("name is required")
+	}
+	for _, r := range p.Name {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '_' || r == '-') {
+			return invalidPipeline("name may only contain lowercase letters, digits, '_' and '-'")
+		}
+	}
+
+	if len(p.Sources) == 0 {
+		p.Sources = []string{SourceAds, SourceCRM}
+	}
+	for _, source := range p.Sources {
+		if !IsSource(source) {
+			return invalidPipeline("unsupported source %q", source)
+		}
+	}
+
+	if p.SinceDays < 0 {
+		return invalidPipeline("since_days must not be negative")
+	}
+
+	if p.AttributionModel != "" {
+		return invalidPipeline("attribution_model %q is not configurable per pipeline, metrics are attributed by the service's ATTRIBUTION_MODE", p.AttributionModel)
+	}
+
+	for _, destination := range p.Destinations {
+		if destination != DestinationSink {
+			return invalidPipeline("unsupported destination %q", destination)
+		}
+	}
+
+	for source, policy := range p.Parsing {
+		if !IsSource(source) {
+			return invalidPipeline("parsing configured for unsupported source %q", source)
+		}
+		if err := policy.Validate(); err != nil {
+			return invalidPipeline("%s parsing: %v", source, err)
+		}
+		p.Parsing[source] = policy
+	}
+
+	if p.Schedule != "" {
+		if _, err := ParseCronSchedule(p.Schedule); err != nil {
+			return invalidPipeline("schedule: %v", err)
+		}
+	} else if p.BusinessDaysOnly || p.HolidayCalendar != "" {
+		return invalidPipeline("business_days_only and holiday_calendar require a schedule")
+	}
+
+	return nil
+}
+
+func invalidPipeline(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", ErrInvalidPipeline, fmt.Sprintf(format, args...))
+}
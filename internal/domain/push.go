@@ -0,0 +1,58 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrPushBatchTooLarge is returned when a pushed batch exceeds the record limit
+var ErrPushBatchTooLarge = errors.New("push batch exceeds the record limit")
+
+// records pushed by a source between full runs
+type PushBatch struct {
+	Ads           []AdPerformance `json:"ads"`
+	Opportunities []Opportunity   `json:"opportunities"`
+}
+
+// returns the number of records in the batch
+func (b PushBatch) Len() int {
+	return len(b.Ads) + len(b.Opportunities)
+}
+
+// returns the sources that have records in the batch
+func (b PushBatch) Sources() []string {
+	var sources []string
+	if len(b.Ads) > 0 {
+		sources = append(sources, SourceAds)
+	}
+	if len(b.Opportunities) > 0 {
+		sources = append(sources, SourceCRM)
+	}
+	return sources
+}
+
+// identifies one (date, UTM, ad group) metrics row
+type MetricKey struct {
+	Date        time.Time `json:"date"`
+	UTMCampaign string    `json:"utm_campaign"`
+	UTMSource   string    `json:"utm_source"`
+	UTMMedium   string    `json:"utm_medium"`
+	AdGroupID   string    `json:"ad_group_id,omitempty"`
+}
+
+// returns the UTM part of the key
+func (k MetricKey) UTM() UTMKey {
+	return UTMKey{Campaign: k.UTMCampaign, Source: k.UTMSource, Medium: k.UTMMedium}
+}
+
+// describes the outcome of applying a pushed batch
+type PushSummary struct {
+	RunSummary
+
+	// metrics recalculated for the UTMs the batch touched
+	UpdatedMetrics []MetricKey `json:"updated_metrics"`
+
+	// opportunities stored but counted by no metric, as they were
+	// recognized before the window or their UTM has no ads in it
+	Deferred int `json:"deferred"`
+}
@@ -0,0 +1,43 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// a row rejected during decoding or normalization, kept for inspection.
+// Errors holds one entry per field that could not be coerced.
+type QuarantinedRecord struct {
+	ID            string          `json:"id"`
+	Source        string          `json:"source"`
+	Record        string          `json:"record"`
+	Payload       json.RawMessage `json:"payload,omitempty"`
+	Errors        []RecordError   `json:"errors"`
+	QuarantinedAt time.Time       `json:"quarantined_at"`
+}
+
+// selects quarantined rows to reprocess: the rows with IDs when set, else
+// the most recent Limit rows of Source (all sources when empty). Payloads
+// replaces the payload of a row by ID, e.g. to correct a date.
+type ReplayRequest struct {
+	IDs      []string                   `json:"ids,omitempty"`
+	Source   string                     `json:"source,omitempty"`
+	Limit    int                        `json:"limit,omitempty"`
+	Payloads map[string]json.RawMessage `json:"payloads,omitempty"`
+}
+
+// a quarantined row that was not reprocessed and stays quarantined
+type ReplaySkip struct {
+	ID     string `json:"id"`
+	Reason string `json:"reason"`
+}
+
+// the outcome of reprocessing quarantined rows. Replayed rows were pushed
+// again and taken out of the quarantine; Rejected of them failed again and
+// were quarantined anew with their current errors.
+type ReplaySummary struct {
+	Replayed int          `json:"replayed"`
+	Rejected int          `json:"rejected"`
+	Skipped  []ReplaySkip `json:"skipped,omitempty"`
+	Push     *PushSummary `json:"push,omitempty"`
+}
@@ -0,0 +1,46 @@
+package domain
+
+import "fmt"
+
+// what happens to metrics queries over their caller's row budget
+const (
+	// the query is refused with guidance to narrow it
+	QueryBudgetReject = "reject"
+	// the query's range is narrowed to the most recent days within budget
+	QueryBudgetDowngrade = "downgrade"
+)
+
+// returned for metrics queries expected to scan more rows than the caller's
+// budget allows
+var ErrQueryTooExpensive = NewError(ErrValidation, "query too expensive")
+
+func ValidateQueryBudgetMode(mode string) error {
+	switch mode {
+	case QueryBudgetReject, QueryBudgetDowngrade:
+		return nil
+	}
+	return fmt.Errorf("unsupported query budget mode %q, expected %s or %s", mode, QueryBudgetReject, QueryBudgetDowngrade)
+}
+
+// the estimated cost of a metrics query: the rows it scans, the days of its
+// range times the rows stored per day within it.
+// RequestedFrom and From are set when the range was narrowed to fit the
+// budget.
+type QueryCost struct {
+	Days          int     `json:"days"`
+	RowsPerDay    float64 `json:"rows_per_day"`
+	EstimatedRows int64   `json:"estimated_rows"`
+	Budget        int64   `json:"budget"`
+	Downgraded    bool    `json:"downgraded,omitempty"`
+	RequestedFrom string  `json:"requested_from,omitempty"`
+	From          string  `json:"from,omitempty"`
+}
+
+// returns the number of days of the range that fit the budget, 0 when not
+// even one does
+func (c QueryCost) DaysWithinBudget() int {
+	if c.RowsPerDay <= 0 {
+		return c.Days
+	}
+	return min(c.Days, int(float64(c.Budget)/c.RowsPerDay))
+}
@@ -0,0 +1,113 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrQuotaExceeded is returned when work would exceed a usage quota
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// tracked quotas
+const (
+	// calls to an upstream API per source and UTC day
+	QuotaUpstreamCalls = "upstream_calls"
+	// records ingested per tenant and UTC month
+	QuotaRecordsIngested = "records_ingested"
+)
+
+// tenant that usage without an X-Tenant-ID is accounted to, and quota usage
+// of requests without an API key bound to a tenant
+const DefaultTenant = "default"
+
+// key of the limit applying to subjects without their own
+const QuotaWildcard = "*"
+
+// identifies one usage counter
+type QuotaKey struct {
+	Quota   string
+	Subject string
+	Period  string
+}
+
+// limits per subject, 0 or absent meaning unlimited
+type QuotaLimits map[string]int64
+
+// parses a comma separated list of subject=limit pairs, where subject * sets
+// the limit of every subject without its own
+func ParseQuotaLimits(spec string) (QuotaLimits, error) {
+	limits := QuotaLimits{}
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		subject, value, ok := strings.Cut(pair, "=")
+		limit, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if !ok || err != nil || limit < 0 || strings.TrimSpace(subject) == "" {
+			return nil, fmt.Errorf("invalid quota limit %q: expected subject=limit", pair)
+		}
+		limits[strings.TrimSpace(subject)] = limit
+	}
+	return limits, nil
+}
+
+// returns the subject's limit, 0 when unlimited
+func (l QuotaLimits) For(subject string) int64 {
+	if limit, ok := l[subject]; ok {
+		return limit
+	}
+	return l[QuotaWildcard]
+}
+
+// usage of one quota in its current period
+type QuotaUsage struct {
+	Quota     string    `json:"quota"`
+	Subject   string    `json:"subject"`
+	Period    string    `json:"period"`
+	Used      int64     `json:"used"`
+	Limit     int64     `json:"limit,omitempty"`
+	Remaining *int64    `json:"remaining,omitempty"`
+	ResetsAt  time.Time `json:"resets_at"`
+}
+
+// consumes one call of the source's upstream call quota, failing with
+// ErrQuotaExceeded once it is exhausted
+type UpstreamCallFunc func(ctx context.Context, source string) error
+
+type upstreamCallsKey struct{}
+
+// returns a context whose requests to upstream APIs each consume a call of
+// their source's quota, pages, retries and hedges included
+func WithUpstreamCalls(ctx context.Context, consume UpstreamCallFunc) context.Context {
+	return context.WithValue(ctx, upstreamCallsKey{}, consume)
+}
+
+// consumes a call of the source's quota before a request to its API. Outside
+// a context of WithUpstreamCalls requests are not accounted.
+func ConsumeUpstreamCall(ctx context.Context, source string) error {
+	consume, ok := ctx.Value(upstreamCallsKey{}).(UpstreamCallFunc)
+	if !ok {
+		return nil
+	}
+	return consume(ctx, source)
+}
+
+// describes the quota that rejected work and when it resets
+type QuotaError struct {
+	Usage     QuotaUsage
+	Requested int64
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("%s quota for %s exceeded: %d of %d used in %s, %d requested",
+		e.Usage.Quota, e.Usage.Subject, e.Usage.Used, e.Usage.Limit, e.Usage.Period, e.Requested)
+}
+
+func (e *QuotaError) Unwrap() error {
+	return ErrQuotaExceeded
+}
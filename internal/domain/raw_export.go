@@ -0,0 +1,60 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// output formats for raw data exports
+type RawExportFormat string
+
+const (
+	RawExportNDJSON  RawExportFormat = "ndjson"
+	RawExportCSV     RawExportFormat = "csv"
+	RawExportParquet RawExportFormat = "parquet"
+)
+
+// returns true if the format is supported
+func (f RawExportFormat) IsValid() bool {
+	switch f {
+	case RawExportNDJSON, RawExportCSV, RawExportParquet:
+		return true
+	}
+	return false
+}
+
+// destinations raw exports can be delivered to
+const (
+	RawExportDestinationSink = "sink"
+	RawExportDestinationFile = "file"
+	RawExportDestinationS3   = "s3"
+)
+
+// datasets available for raw export
+const (
+	RawDatasetAds = "ads"
+	RawDatasetCRM = "crm"
+)
+
+// represents a raw export job
+type RawExportRequest struct {
+	From        time.Time       `json:"from"`
+	To          time.Time       `json:"to"`
+	Format      RawExportFormat `json:"format"`
+	Destination string          `json:"destination"`
+	Datasets    []string        `json:"datasets"`
+}
+
+// describes a single delivered raw export file
+type RawExportResult struct {
+	Dataset  string `json:"dataset"`
+	Records  int    `json:"records"`
+	Bytes    int    `json:"bytes"`
+	Location string `json:"location"`
+}
+
+// interface for delivering raw processed records
+type RawExportClient interface {
+	ExportAds(ctx context.Context, ads []ProcessedAdData, req RawExportRequest) (*RawExportResult, error)
+	ExportOpportunities(ctx context.Context, opportunities []ProcessedOpportunity, req RawExportRequest) (*RawExportResult, error)
+}
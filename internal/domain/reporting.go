@@ -0,0 +1,296 @@
+package domain
+
+import (
+	"cmp"
+	"context"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// returned for pagination cursors that were not issued by a flat report
+var ErrInvalidCursor = NewError(ErrValidation, "invalid cursor")
+
+// a long-lived API key of a reporting connector or partner. Metrics queries
+// made with it only see the rows in its scope, without the columns its role
+// hides.
+type APIKey struct {
+	Name  string       `json:"name"`
+	Key   string       `json:"key"`
+	Scope MetricsScope `json:"scope,omitempty"`
+	// the role whose hidden columns are removed from the key's responses
+	Role string `json:"role,omitempty"`
+	// the tenant the key's requests act as and whose quotas they consume,
+	// in place of the X-Tenant-ID header
+	Tenant string `json:"tenant,omitempty"`
+}
+
+func (k APIKey) Validate() error {
+	if k.Name == "" {
+		return fmt.Errorf("API key name is required")
+	}
+	if k.Key == "" {
+		return fmt.Errorf("%s: API key is empty", k.Name)
+	}
+	if err := k.Scope.Validate(); err != nil {
+		return fmt.Errorf("%s: %w", k.Name, err)
+	}
+	return nil
+}
+
+// API keys by name
+type APIKeys map[string]APIKey
+
+// parses comma-separated name=key pairs of unscoped keys, e.g.
+// "looker=k1,powerbi=k2"
+func ParseAPIKeys(spec string) (APIKeys, error) {
+	keys := APIKeys{}
+	for i, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, key, ok := strings.Cut(pair, "=")
+		name, key = strings.TrimSpace(name), strings.TrimSpace(key)
+		if !ok || name == "" || key == "" {
+			// The entry is not echoed, it may hold a key
+			return nil, fmt.Errorf("invalid API key entry %d: expected name=key", i+1)
+		}
+		if err := keys.Add(APIKey{Name: name, Key: key}); err != nil {
+			return nil, err
+		}
+	}
+	return keys, nil
+}
+
+// adds a validated key, refusing duplicate names
+func (k APIKeys) Add(key APIKey) error {
+	if err := key.Validate(); err != nil {
+		return err
+	}
+	if _, exists := k[key.Name]; exists {
+		return fmt.Errorf("duplicate API key name %q", key.Name)
+	}
+	k[key.Name] = key
+	return nil
+}
+
+// reports whether any key is restricted, to a scope or by a role hiding
+// columns
+func (k APIKeys) Restricted() bool {
+	for _, key := range k {
+		if len(key.Scope) > 0 || key.Role != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// returns the key matching the secret. Every key is compared in constant
+// time.
+func (k APIKeys) Match(secret string) (*APIKey, bool) {
+	var matched *APIKey
+	for _, key := range k {
+		if subtle.ConstantTimeCompare([]byte(key.Key), []byte(secret)) == 1 {
+			matched = &key
+		}
+	}
+	return matched, matched != nil
+}
+
+type apiKeyContextKey struct{}
+
+// returns a context of a request authenticated with the key
+func WithAPIKey(ctx context.Context, key APIKey) context.Context {
+	return context.WithValue(ctx, apiKeyContextKey{}, key)
+}
+
+// returns the key the request was authenticated with, nil without one
+func APIKeyFromContext(ctx context.Context) *APIKey {
+	key, ok := ctx.Value(apiKeyContextKey{}).(APIKey)
+	if !ok {
+		return nil
+	}
+	return &key
+}
+
+// a column of the flat report with its type: date, string, integer,
+// number or timestamp
+type ReportColumn struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// the columns of FlatRow in order. The set is fixed: columns are only ever
+// added at the end.
+var FlatColumns = []ReportColumn{
+	{"date", "date"},
+	{"channel", "string"},
+	{"campaign_id", "string"},
+	{"utm_campaign", "string"},
+	{"utm_source", "string"},
+	{"utm_medium", "string"},
+	{"clicks", "integer"},
+	{"impressions", "integer"},
+	{"cost", "number"},
+	{"leads", "integer"},
+	{"opportunities", "integer"},
+	{"closed_won", "integer"},
+	{"revenue", "number"},
+	{"attributed_revenue", "number"},
+	{"sessions", "integer"},
+	{"conversions", "integer"},
+	{"cpc", "number"},
+	{"cpa", "number"},
+	{"cvr_click_to_lead", "number"},
+	{"cvr_lead_to_opp", "number"},
+	{"cvr_opp_to_won", "number"},
+	{"roas", "number"},
+	{"calculated_at", "timestamp"},
+	{"pipeline_value", "number"},
+	{"expected_revenue", "number"},
+	{"transform_version", "string"},
+	{"producer", "string"},
+}
+
+// a business metric as one flat row for BI connectors, with ISO 8601 dates
+type FlatRow struct {
+	Date              string  `json:"date"`
+	Channel           string  `json:"channel"`
+	CampaignID        string  `json:"campaign_id"`
+	UTMCampaign       string  `json:"utm_campaign"`
+	UTMSource         string  `json:"utm_source"`
+	UTMMedium         string  `json:"utm_medium"`
+	Clicks            int     `json:"clicks"`
+	Impressions       int     `json:"impressions"`
+	Cost              Money   `json:"cost"`
+	Leads             int     `json:"leads"`
+	Opportunities     int     `json:"opportunities"`
+	ClosedWon         int     `json:"closed_won"`
+	Revenue           Money   `json:"revenue"`
+	AttributedRevenue Money   `json:"attributed_revenue"`
+	Sessions          int     `json:"sessions"`
+	Conversions       int     `json:"conversions"`
+	CPC               Money   `json:"cpc"`
+	CPA               Money   `json:"cpa"`
+	CVRClickToLead    float64 `json:"cvr_click_to_lead"`
+	CVRLeadToOpp      float64 `json:"cvr_lead_to_opp"`
+	CVROppToWon       float64 `json:"cvr_opp_to_won"`
+	ROAS              float64 `json:"roas"`
+	CalculatedAt      string  `json:"calculated_at"`
+	PipelineValue     Money   `json:"pipeline_value"`
+	ExpectedRevenue   Money   `json:"expected_revenue"`
+	TransformVersion  string  `json:"transform_version"`
+	Producer          string  `json:"producer"`
+
+	// columns hidden from the caller's role, left out when the row is
+	// serialized
+	Hidden ColumnMask `json:"-"`
+}
+
+func (r FlatRow) MarshalJSON() ([]byte, error) {
+	type row FlatRow
+	raw, err := json.Marshal(row(r))
+	if err != nil {
+		return nil, err
+	}
+	return r.Hidden.strip(raw)
+}
+
+func FlatRowOf(metric BusinessMetrics) FlatRow {
+	return FlatRow{
+		Date:              metric.Date.Format("2006-01-02"),
+		Channel:           metric.Channel,
+		CampaignID:        metric.CampaignID,
+		UTMCampaign:       metric.UTMCampaign,
+		UTMSource:         metric.UTMSource,
+		UTMMedium:         metric.UTMMedium,
+		Clicks:            metric.Clicks,
+		Impressions:       metric.Impressions,
+		Cost:              metric.Cost,
+		Leads:             metric.Leads,
+		Opportunities:     metric.Opportunities,
+		ClosedWon:         metric.ClosedWon,
+		Revenue:           metric.Revenue,
+		AttributedRevenue: metric.AttributedRevenue,
+		Sessions:          metric.Sessions,
+		Conversions:       metric.Conversions,
+		CPC:               metric.CPC,
+		CPA:               metric.CPA,
+		CVRClickToLead:    metric.CVRClickToLead,
+		CVRLeadToOpp:      metric.CVRLeadToOpp,
+		CVROppToWon:       metric.CVROppToWon,
+		ROAS:              metric.ROAS,
+		CalculatedAt:      metric.CalculatedAt.UTC().Format(time.RFC3339),
+		PipelineValue:     metric.PipelineValue,
+		ExpectedRevenue:   metric.ExpectedRevenue,
+		TransformVersion:  metric.TransformVersion,
+		Producer:          metric.Producer,
+	}
+}
+
+// the position after a row in the flat report's order. Rows are ordered by
+// date, then channel, campaign, UTM and producer, so a page boundary stays
+// put when rows are added or updated.
+type ReportCursor struct {
+	Date        string `json:"d"`
+	Channel     string `json:"c"`
+	CampaignID  string `json:"i"`
+	UTMCampaign string `json:"uc"`
+	UTMSource   string `json:"us"`
+	UTMMedium   string `json:"um"`
+	Producer    string `json:"p,omitempty"`
+}
+
+func (r FlatRow) Cursor() ReportCursor {
+	return ReportCursor{
+		Date:        r.Date,
+		Channel:     r.Channel,
+		CampaignID:  r.CampaignID,
+		UTMCampaign: r.UTMCampaign,
+		UTMSource:   r.UTMSource,
+		UTMMedium:   r.UTMMedium,
+		Producer:    r.Producer,
+	}
+}
+
+func (c ReportCursor) Compare(other ReportCursor) int {
+	return cmp.Or(
+		strings.Compare(c.Date, other.Date),
+		strings.Compare(c.Channel, other.Channel),
+		strings.Compare(c.CampaignID, other.CampaignID),
+		strings.Compare(c.UTMCampaign, other.UTMCampaign),
+		strings.Compare(c.UTMSource, other.UTMSource),
+		strings.Compare(c.UTMMedium, other.UTMMedium),
+		strings.Compare(c.Producer, other.Producer),
+	)
+}
+
+// returns the opaque form of the cursor handed to clients
+func (c ReportCursor) Encode() string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func DecodeReportCursor(encoded string) (ReportCursor, error) {
+	var cursor ReportCursor
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return cursor, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(raw, &cursor); err != nil || cursor.Date == "" {
+		return cursor, ErrInvalidCursor
+	}
+	return cursor, nil
+}
+
+// a page of the flat report. NextCursor is empty on the last page.
+type FlatReport struct {
+	Columns    []ReportColumn `json:"columns"`
+	Rows       []FlatRow      `json:"rows"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+	QueryCost  *QueryCost     `json:"query_cost,omitempty"`
+}
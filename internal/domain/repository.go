@@ -2,40 +2,343 @@ package domain
 
 import (
 	"context"
+	"io"
 	"time"
 )
 
-// interface for ad data operations
+// interface for ad data operations. Store replaces the stored ads with the
+// same natural key, see ProcessedAdData.RecordKey, so repeated loads are
+// idempotent. Replace drops the stored ads dated within the range before
+// storing ads, for re-extracted dates.
 type AdRepository interface {
 	Store(ctx context.Context, ads []ProcessedAdData) error
+	Replace(ctx context.Context, from, to time.Time, ads []ProcessedAdData) error
 	GetByDateRange(ctx context.Context, from, to time.Time) ([]ProcessedAdData, error)
 	GetByUTM(ctx context.Context, utm UTMKey, from, to time.Time) ([]ProcessedAdData, error)
 	GetByCampaign(ctx context.Context, campaignID string, from, to time.Time) ([]ProcessedAdData, error)
 	GetByChannel(ctx context.Context, channel string, from, to time.Time) ([]ProcessedAdData, error)
 }
 
-// the interface for CRM data operations
+// the interface for CRM data operations. Store replaces opportunities with
+// the same ID, so updated deals overwrite their previous version.
 type CRMRepository interface {
 	Store(ctx context.Context, opportunities []ProcessedOpportunity) error
+	Delete(ctx context.Context, ids []string) error
+	GetByIDs(ctx context.Context, ids []string) ([]ProcessedOpportunity, error)
 	GetByDateRange(ctx context.Context, from, to time.Time) ([]ProcessedOpportunity, error)
 	GetByUTM(ctx context.Context, utm UTMKey, from, to time.Time) ([]ProcessedOpportunity, error)
 	GetByStage(ctx context.Context, stage OpportunityStage, from, to time.Time) ([]ProcessedOpportunity, error)
 }
 
-// interface for metrics operations
+// interface for web analytics storage. Replace drops the stored rows of the
+// days from and to before storing rows, since a report covers whole days.
+type AnalyticsRepository interface {
+	Replace(ctx context.Context, from, to time.Time, rows []ProcessedAnalyticsRow) error
+	GetByDateRange(ctx context.Context, from, to time.Time) ([]ProcessedAnalyticsRow, error)
+}
+
+// interface for keyword level ads storage. Store replaces the stored rows
+// with the same key, see ProcessedKeywordData.Key.
+type KeywordRepository interface {
+	Store(ctx context.Context, rows []ProcessedKeywordData) error
+	GetByDateRange(ctx context.Context, from, to time.Time) ([]ProcessedKeywordData, error)
+}
+
+// interface for metrics operations. Store and Upsert replace the stored
+// metrics with the same key, see BusinessMetrics.Key, and producer, so
+// recalculating a day never duplicates its metrics.
 type MetricsRepository interface {
 	Store(ctx context.Context, metrics []BusinessMetrics) error
+	Upsert(ctx context.Context, metrics []BusinessMetrics) error
 	GetByFilter(ctx context.Context, filter MetricsFilter) (*MetricsResponse, error)
 	GetByDate(ctx context.Context, date time.Time) ([]BusinessMetrics, error)
+	GetDistinctValues(ctx context.Context, dimension string, from, to time.Time) ([]DimensionValue, error)
+	// counts the metrics dated within the range without reading them
+	Count(ctx context.Context, from, to time.Time) (int64, error)
+	// atomically replaces the ETL-calculated metrics dated within the
+	// range, returning the replaced ones. Producers' metrics are kept.
+	Replace(ctx context.Context, from, to time.Time, metrics []BusinessMetrics) ([]BusinessMetrics, error)
+}
+
+// interface for daily FX rates. Put replaces the rates of the same currency
+// and date; RateOn returns the latest rate of the currency dated on or
+// before the date, failing with ErrNoFXRate without one.
+type FXRateRepository interface {
+	Put(ctx context.Context, rates []FXRate) error
+	RateOn(ctx context.Context, currency string, date time.Time) (*FXRate, error)
+	List(ctx context.Context, currency string, from, to time.Time) ([]FXRate, error)
+}
+
+// interface for pipeline preset storage
+type PipelineRepository interface {
+	Create(ctx context.Context, pipeline Pipeline) error
+	Update(ctx context.Context, pipeline Pipeline) error
+	Delete(ctx context.Context, name, actor string) error
+	Get(ctx context.Context, name string) (*Pipeline, error)
+	List(ctx context.Context) ([]Pipeline, error)
+	History(ctx context.Context, name string) ([]PipelineRevision, error)
+}
+
+// interface for scheduled run history
+type ScheduleHistoryRepository interface {
+	Record(ctx context.Context, record ScheduleRecord) error
+	LastDue(ctx context.Context, pipeline string) (*time.Time, error)
+	List(ctx context.Context, pipeline string, limit int) ([]ScheduleRecord, error)
+}
+
+// interface for extraction checkpoints. Get returns nil when the tenant has
+// no checkpoint for the source; Save replaces it.
+type CheckpointRepository interface {
+	Save(ctx context.Context, checkpoint Checkpoint) error
+	Get(ctx context.Context, tenant, source string) (*Checkpoint, error)
+	List(ctx context.Context, tenant string) ([]Checkpoint, error)
+}
+
+// interface for the object store files already ingested. Get returns nil
+// when the tenant's object was not ingested; Save replaces the recorded
+// objects with the same keys.
+type SourceObjectRepository interface {
+	Save(ctx context.Context, objects []SourceObject) error
+	Get(ctx context.Context, tenant, source, key string) (*SourceObject, error)
+	List(ctx context.Context, tenant, source string) ([]SourceObject, error)
+}
+
+// interface for materialized derived models. Get returns nil when the model
+// was not materialized yet; Save replaces the model's previous result.
+type DerivedModelRepository interface {
+	Save(ctx context.Context, result DerivedModelResult) error
+	Get(ctx context.Context, model string) (*DerivedModelResult, error)
+}
+
+// interface for finished run records. Latest returns the most recently
+// completed run with the status, or ErrRunNotFound; List returns the most
+// recently completed first.
+type RunRepository interface {
+	Save(ctx context.Context, record RunRecord) error
+	Get(ctx context.Context, id string) (*RunRecord, error)
+	Latest(ctx context.Context, status string) (*RunRecord, error)
+	List(ctx context.Context, filter RunFilter) ([]RunRecord, error)
+}
+
+// interface for asynchronous ingest jobs. Save adds or updates a job by ID;
+// List returns the most recent first. FindByRunKey returns the tenant's job
+// with the run key, or ErrIngestJobNotFound.
+type IngestJobRepository interface {
+	Save(ctx context.Context, job IngestJob) error
+	Get(ctx context.Context, id string) (*IngestJob, error)
+	List(ctx context.Context, limit int) ([]IngestJob, error)
+	FindByRunKey(ctx context.Context, tenant, key string) (*IngestJob, error)
+}
+
+// interface for usage counters. Consume adds n unless that would take the
+// counter above a non-zero limit, in which case it returns ErrQuotaExceeded
+// and leaves the counter unchanged.
+type QuotaRepository interface {
+	Consume(ctx context.Context, key QuotaKey, n, limit int64) (int64, error)
+	Get(ctx context.Context, key QuotaKey) (int64, error)
+}
+
+// interface for the restatement log. List returns the most recent first,
+// optionally for one opportunity.
+type RestatementRepository interface {
+	Store(ctx context.Context, restatements []Restatement) error
+	List(ctx context.Context, opportunityID string, limit int) ([]Restatement, error)
+}
+
+// interface for approval requests. Decide moves a pending request to the
+// given status and fails with ErrConflict when it is no longer pending.
+type ApprovalRepository interface {
+	Create(ctx context.Context, request ApprovalRequest) error
+	Get(ctx context.Context, id string) (*ApprovalRequest, error)
+	List(ctx context.Context, filter ApprovalFilter) ([]ApprovalRequest, error)
+	Decide(ctx context.Context, id, status, actor, reason string, at time.Time) (*ApprovalRequest, error)
+	Update(ctx context.Context, request ApprovalRequest) error
+}
+
+// interface for export holds. Create fails with ErrConflict when the date
+// already has a pending hold, and Approve when the hold is not pending.
+type ExportHoldRepository interface {
+	Create(ctx context.Context, hold ExportHold) error
+	Get(ctx context.Context, id string) (*ExportHold, error)
+	List(ctx context.Context, filter ExportHoldFilter) ([]ExportHold, error)
+	Approve(ctx context.Context, id, actor string, at time.Time) (*ExportHold, error)
+}
+
+// interface for the snapshots diff exports compare against, the last per
+// destination and date. Get returns nil when the date was never exported to
+// the destination.
+type ExportSnapshotRepository interface {
+	Get(ctx context.Context, destination string, date time.Time) (*ExportSnapshot, error)
+	Save(ctx context.Context, snapshot ExportSnapshot) error
+}
+
+// interface for exports awaiting their sink's acknowledgement. Acknowledge
+// applies the ack to the most recent delivery of its export ID and fails
+// with ErrExportDeliveryNotFound when there is none. MarkStuck flags the
+// deliveries still awaiting an ack past their deadline and returns those
+// newly flagged. List returns the most recent first.
+type ExportDeliveryRepository interface {
+	Record(ctx context.Context, delivery ExportDelivery) error
+	Get(ctx context.Context, id string) (*ExportDelivery, error)
+	List(ctx context.Context, filter ExportDeliveryFilter) ([]ExportDelivery, error)
+	Acknowledge(ctx context.Context, ack ExportAck, at time.Time) (*ExportDelivery, error)
+	MarkStuck(ctx context.Context, now time.Time) ([]ExportDelivery, error)
+}
+
+// interface for the append-only log of ingested records. Append assigns
+// sequence numbers and versions and skips records equal to their latest
+// version, returning the appended events. List returns the most recent
+// first. Latest returns the latest version of every record not deleted as
+// of asOf, or an ErrValidation error when asOf is before the compaction
+// horizon. Compact drops versions superseded at or before the horizon.
+type EventLogRepository interface {
+	Append(ctx context.Context, events []IngestEvent) ([]IngestEvent, error)
+	List(ctx context.Context, filter EventFilter) ([]IngestEvent, error)
+	Latest(ctx context.Context, asOf time.Time) ([]IngestEvent, error)
+	Compact(ctx context.Context, horizon time.Time) (EventCompaction, error)
+}
+
+// interface for rejected row storage, the dead letters of the pipeline.
+// Delete skips unknown IDs.
+type QuarantineRepository interface {
+	Store(ctx context.Context, records []QuarantinedRecord) error
+	List(ctx context.Context, source string, limit int) ([]QuarantinedRecord, error)
+	Delete(ctx context.Context, ids []string) error
+}
+
+// interface for feature flag evaluation. Providers resolve the tenant from
+// the context and apply their own environment.
+type FeatureFlagProvider interface {
+	IsEnabled(ctx context.Context, name string) bool
+	Flags(ctx context.Context) []FeatureFlag
+	Reload() error
 }
 
-// interface for external API calls
+// interface for external API calls. A since asks the API for the records
+// from that day on, which APIs without the filter ignore. Prewarm opens connections to the APIs
+// ahead of the fetches, when enabled.
 type ExternalAPIClient interface {
-	FetchAdsData(ctx context.Context) (*AdData, error)
-	FetchCRMData(ctx context.Context) (*CRMData, error)
+	FetchAdsData(ctx context.Context, since *time.Time) (*AdData, error)
+	FetchCRMData(ctx context.Context, since *time.Time) (*CRMData, error)
+	Prewarm(ctx context.Context)
+}
+
+// implemented by extraction clients that track what they extracted
+// themselves, e.g. the files already read. Runs extract such sources in
+// full instead of resuming them from their checkpoints.
+type ExtractionTracker interface {
+	TracksExtraction(source string) bool
+}
+
+// reports whether the client tracks the source's extraction itself
+func TracksExtraction(client ExternalAPIClient, source string) bool {
+	tracker, ok := client.(ExtractionTracker)
+	return ok && tracker.TracksExtraction(source)
+}
+
+// implemented by extraction clients made of registered connectors
+type ConnectorLister interface {
+	Connectors() []ConnectorInfo
+}
+
+// returns the connectors the client extracts, none when it isn't made of
+// connectors
+func ListConnectors(client ExternalAPIClient) []ConnectorInfo {
+	lister, ok := client.(ConnectorLister)
+	if !ok {
+		return nil
+	}
+	return lister.Connectors()
+}
+
+// interface for decoding ads and CRM records from uploaded files
+type FileDecoder interface {
+	DecodeAds(r io.Reader) (*AdData, error)
+	DecodeCRM(r io.Reader) (*CRMData, error)
+}
+
+// interface for web analytics reports of the days from and to. Prewarm
+// opens connections to the API ahead of the fetch, when enabled.
+type AnalyticsClient interface {
+	FetchAnalyticsData(ctx context.Context, from, to time.Time) (*AnalyticsData, error)
+	Prewarm(ctx context.Context)
 }
 
-// interface for data export
+// interface for consuming the ads and CRM topics of a stream. Poll waits up
+// to its timeout for the next records and returns nil when none arrived.
+// Commit marks a batch consumed, so it is not delivered again after a
+// restart, and Rewind has it delivered again. Close leaves the consumer
+// group.
+type StreamConsumer interface {
+	Poll(ctx context.Context) (*StreamBatch, error)
+	Commit(ctx context.Context, batch *StreamBatch) error
+	Rewind(ctx context.Context, batch *StreamBatch) error
+	Topics() []string
+	Close(ctx context.Context) error
+}
+
+// interface for the keyword level ads feed. A since asks the API for the
+// rows from that day on, which APIs without the filter ignore. Prewarm opens
+// connections to the API ahead of the fetch, when enabled.
+type KeywordClient interface {
+	FetchKeywordData(ctx context.Context, since *time.Time) (*KeywordData, error)
+	Prewarm(ctx context.Context)
+}
+
+// interface for data export. Every request delivering an export, to the
+// sink or a destination, carries the export's ExportEnvelope, so sinks can
+// deduplicate retried and re-run deliveries by its idempotency key.
 type ExportClient interface {
 	Export(ctx context.Context, data []ExportData, date time.Time) error
+	// delivers records to the destination, or to the sink when the
+	// destination has no URL
+	ExportTo(ctx context.Context, destination ExportDestination, exportID string, records []ExportRecord) error
+	VerifySink(ctx context.Context, target SinkTarget) (*SinkVerification, error)
+}
+
+// interface for delivering rendered notifications to a channel
+type NotificationSender interface {
+	Send(ctx context.Context, channel NotificationChannel, message NotificationMessage) error
+}
+
+// interface for background job incidents. List returns the most recent
+// first, of every job when job is empty.
+type JobIncidentRepository interface {
+	Record(ctx context.Context, incident JobIncident) error
+	List(ctx context.Context, job string, limit int) ([]JobIncident, error)
+}
+
+// interface for shadow configs and their results. Configs are keyed by
+// label; ListResults returns the most recent first.
+type ShadowRepository interface {
+	SaveConfig(ctx context.Context, config ShadowConfig) error
+	DeleteConfig(ctx context.Context, label string) error
+	Configs(ctx context.Context) ([]ShadowConfig, error)
+	SaveResult(ctx context.Context, result ShadowResult) error
+	GetResult(ctx context.Context, id string) (*ShadowResult, error)
+	ListResults(ctx context.Context, label string, limit int) ([]ShadowResult, error)
+}
+
+// interface for dataset promotions. Save inserts or replaces by ID; List
+// returns the most recent first and Latest the most recent still active.
+type DatasetRepository interface {
+	Save(ctx context.Context, promotion DatasetPromotion) error
+	Latest(ctx context.Context) (*DatasetPromotion, error)
+	List(ctx context.Context, limit int) ([]DatasetPromotion, error)
+}
+
+// interface for the audit log of runtime configuration changes. List
+// returns the most recent first.
+type ConfigChangeRepository interface {
+	Record(ctx context.Context, change ConfigChange) error
+	List(ctx context.Context, limit int) ([]ConfigChange, error)
+}
+
+// interface for the revoked share tokens. Revoke keeps the first revocation
+// of an ID; Prune drops those revoked before the time, once the tokens
+// have expired anyway.
+type ShareTokenRevocationRepository interface {
+	Revoke(ctx context.Context, revocation ShareTokenRevocation) (*ShareTokenRevocation, error)
+	IsRevoked(ctx context.Context, id string) (bool, error)
+	Prune(ctx context.Context, before time.Time) error
 }
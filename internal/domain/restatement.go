@@ -0,0 +1,81 @@
+package domain
+
+import "time"
+
+// how a restated opportunity was ingested
+const (
+	RestatedByRun  = "run"
+	RestatedByPush = "push"
+)
+
+// a change to an already ingested opportunity, such as a deal adjusted
+// after close. The metrics that counted either version are restated.
+type Restatement struct {
+	ID            string             `json:"id"`
+	OpportunityID string             `json:"opportunity_id"`
+	Origin        string             `json:"origin"`
+	RunID         string             `json:"run_id,omitempty"`
+	Previous      OpportunityVersion `json:"previous"`
+	Current       OpportunityVersion `json:"current"`
+	AmountDelta   Money              `json:"amount_delta"`
+	Metrics       []MetricKey        `json:"metrics"`
+	RestatedAt    time.Time          `json:"restated_at"`
+}
+
+// the fields of an opportunity that affect metrics
+type OpportunityVersion struct {
+	Stage       OpportunityStage `json:"stage"`
+	Amount      Money            `json:"amount"`
+	CreatedAt   time.Time        `json:"created_at"`
+	ClosedAt    *time.Time       `json:"closed_at,omitempty"`
+	Probability *float64         `json:"probability,omitempty"`
+	UTMCampaign string           `json:"utm_campaign"`
+	UTMSource   string           `json:"utm_source"`
+	UTMMedium   string           `json:"utm_medium"`
+}
+
+// returns the version of an opportunity
+func VersionOf(opp ProcessedOpportunity) OpportunityVersion {
+	return OpportunityVersion{
+		Stage:       opp.Stage,
+		Amount:      opp.Amount,
+		CreatedAt:   opp.CreatedAt,
+		ClosedAt:    opp.ClosedAt,
+		Probability: opp.Probability,
+		UTMCampaign: opp.UTMCampaign,
+		UTMSource:   opp.UTMSource,
+		UTMMedium:   opp.UTMMedium,
+	}
+}
+
+// returns the restatement of an opportunity ingested again, or false when
+// nothing that affects metrics changed
+func NewRestatement(previous, current ProcessedOpportunity) (Restatement, bool) {
+	before, after := VersionOf(previous), VersionOf(current)
+	if before.Equal(after) {
+		return Restatement{}, false
+	}
+	return Restatement{
+		OpportunityID: current.OpportunityID,
+		Previous:      before,
+		Current:       after,
+		AmountDelta:   after.Amount - before.Amount,
+	}, true
+}
+
+// reports whether two versions are the same, comparing times as instants
+func (v OpportunityVersion) Equal(other OpportunityVersion) bool {
+	if !v.CreatedAt.Equal(other.CreatedAt) {
+		return false
+	}
+	if (v.ClosedAt == nil) != (other.ClosedAt == nil) || v.ClosedAt != nil && !v.ClosedAt.Equal(*other.ClosedAt) {
+		return false
+	}
+	if (v.Probability == nil) != (other.Probability == nil) || v.Probability != nil && *v.Probability != *other.Probability {
+		return false
+	}
+	v.CreatedAt, other.CreatedAt = time.Time{}, time.Time{}
+	v.ClosedAt, other.ClosedAt = nil, nil
+	v.Probability, other.Probability = nil, nil
+	return v == other
+}
@@ -0,0 +1,95 @@
+package domain
+
+import "time"
+
+// the date closed won revenue is recognized on
+type RevenueRecognition string
+
+const (
+	RecognizeOnCreate RevenueRecognition = "created" // the day the opportunity was created
+	RecognizeOnClose  RevenueRecognition = "closed"  // the day it was closed won
+)
+
+// how long before a range opportunities closed won in it may have been
+// created. Under close date recognition, longer sales cycles are not
+// counted.
+const RevenueCloseLookback = 365 * 24 * time.Hour
+
+func ParseRevenueRecognition(value string) (RevenueRecognition, error) {
+	switch recognition := RevenueRecognition(value); recognition {
+	case RecognizeOnCreate, RecognizeOnClose:
+		return recognition, nil
+	}
+	return "", Errorf(ErrValidation, "unsupported revenue recognition %q, expected %s or %s", value, RecognizeOnCreate, RecognizeOnClose)
+}
+
+// a request for closed won revenue over time, bucketed by interval on the
+// date it is recognized on
+type RevenueQuery struct {
+	Recognition RevenueRecognition
+	Interval    string
+	From        time.Time
+	To          time.Time
+}
+
+func (q RevenueQuery) Validate() error {
+	if _, err := ParseRevenueRecognition(string(q.Recognition)); err != nil {
+		return err
+	}
+	return validateBuckets(q.Interval, q.From, q.To)
+}
+
+// the closed won opportunities and their revenue in one bucket
+type RevenueBucket struct {
+	Start     time.Time `json:"start"`
+	ClosedWon int       `json:"closed_won"`
+	Revenue   Money     `json:"revenue"`
+}
+
+// closed won revenue over time. Under close date recognition,
+// MissingCloseDate counts the opportunities without a close date, which
+// are recognized on their creation instead.
+type RevenueReport struct {
+	Recognition      RevenueRecognition `json:"recognition"`
+	Interval         string             `json:"interval"`
+	Buckets          []RevenueBucket    `json:"buckets"`
+	ClosedWon        int                `json:"closed_won"`
+	Revenue          Money              `json:"revenue"`
+	MissingCloseDate int                `json:"missing_close_date,omitempty"`
+}
+
+// buckets the closed won opportunities of a validated query by the date
+// their revenue is recognized on. Every bucket of the range is listed;
+// opportunities recognized outside it are left out.
+func NewRevenueReport(query RevenueQuery, opportunities []ProcessedOpportunity) *RevenueReport {
+	report := &RevenueReport{
+		Recognition: query.Recognition,
+		Interval:    query.Interval,
+		Buckets:     []RevenueBucket{},
+	}
+
+	index := make(map[time.Time]int)
+	last := BucketStart(query.Interval, query.To)
+	for bucket := BucketStart(query.Interval, query.From); !bucket.After(last); bucket = nextBucket(query.Interval, bucket) {
+		index[bucket] = len(report.Buckets)
+		report.Buckets = append(report.Buckets, RevenueBucket{Start: bucket})
+	}
+
+	for _, opp := range opportunities {
+		if !opp.IsClosedWon() {
+			continue
+		}
+		i, ok := index[BucketStart(query.Interval, opp.RecognizedAt(query.Recognition))]
+		if !ok {
+			continue
+		}
+		report.Buckets[i].ClosedWon++
+		report.Buckets[i].Revenue += opp.Amount
+		report.ClosedWon++
+		report.Revenue += opp.Amount
+		if query.Recognition == RecognizeOnClose && opp.ClosedAt == nil {
+			report.MissingCloseDate++
+		}
+	}
+	return report
+}
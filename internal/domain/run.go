@@ -0,0 +1,284 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrRunNotFound is returned when no run with the given ID is recorded
+var ErrRunNotFound = NewError(ErrNotFound, "run not found")
+
+// ErrEmptyReplacement is returned when a run replacing the stored ads of
+// its window extracts none, which more likely means the source failed than
+// that no ad ran. Runs allowing empty replacements clear the window.
+var ErrEmptyReplacement = NewError(ErrUpstreamUnavailable, "no ads extracted to replace the stored ones")
+
+// RunOptions configures a single ETL run
+type RunOptions struct {
+	Since             *time.Time
+	Sources           []string
+	Pipeline          string                 // name of the preset the run was started from
+	Parsing           map[string]ParsePolicy // per-source overrides of the default parse policy
+	ReplaceFrom       *time.Time             // stored ads from this day up to the run are replaced by the extracted ones
+	AllowEmptyReplace bool                   // replaces the stored ads even when none are extracted
+	ForceFull         bool                   // extracts everything instead of resuming from the checkpoints
+	Watermarks        map[string]time.Time   // per source, the day extraction resumes from when Since is not set
+	Tags              map[string]string      // labels of the caller, e.g. trigger=airflow
+	// extracts ads and CRM from this client instead of the configured one,
+	// e.g. uploaded files. Such runs neither resume from nor save checkpoints.
+	Extractor ExternalAPIClient
+}
+
+// the optional body of an ingest run request. RunKey identifies the run
+// for the caller, e.g. an orchestrator's run ID, so retried requests do not
+// start it twice.
+type RunRequest struct {
+	RunKey string            `json:"run_key,omitempty"`
+	Tags   map[string]string `json:"tags,omitempty"`
+}
+
+// returns the day the source is extracted from, or nil to extract all of it
+func (o RunOptions) SinceFor(source string) *time.Time {
+	if o.Since != nil {
+		return o.Since
+	}
+	if watermark, ok := o.Watermarks[source]; ok {
+		return &watermark
+	}
+	return nil
+}
+
+// returns the day metrics are calculated from: the run's window, or the
+// earliest watermark when every checkpointed source it extracts resumes
+// from one
+func (o RunOptions) MetricsSince() *time.Time {
+	if o.Since != nil {
+		return o.Since
+	}
+	var since *time.Time
+	for _, source := range CheckpointedSources {
+		if !o.IncludesSource(source) {
+			continue
+		}
+		watermark := o.SinceFor(source)
+		if watermark == nil {
+			return nil
+		}
+		if since == nil || watermark.Before(*since) {
+			since = watermark
+		}
+	}
+	return since
+}
+
+// returns true if the source should be extracted
+func (o RunOptions) IncludesSource(source string) bool {
+	if len(o.Sources) == 0 {
+		return true
+	}
+	for _, s := range o.Sources {
+		if s == source {
+			return true
+		}
+	}
+	return false
+}
+
+// describes the outcome of an ETL run
+type RunSummary struct {
+	ID             string                     `json:"id"`
+	Pipeline       string                     `json:"pipeline,omitempty"`
+	Tags           map[string]string          `json:"tags,omitempty"`
+	Since          *time.Time                 `json:"since,omitempty"`
+	Watermarks     map[string]time.Time       `json:"watermarks,omitempty"` // per source, the day an incremental run resumed from
+	Sources        []string                   `json:"sources,omitempty"`
+	Objects        int                        `json:"objects,omitempty"` // object store files read
+	AdsRecords     int                        `json:"ads_records"`
+	CRMRecords     int                        `json:"crm_records"`
+	SessionRecords int                        `json:"session_records,omitempty"`
+	KeywordRecords int                        `json:"keyword_records,omitempty"`
+	Parsing        map[string]*ParseReport    `json:"parsing"`
+	Values         map[string]*ValueReport    `json:"values,omitempty"`
+	Dates          map[string]*DateSkewReport `json:"dates,omitempty"` // per source, future-dated and implausibly old dates
+	Cost           *RunCost                   `json:"cost,omitempty"`
+	Changes        map[string]*ChangeCounts   `json:"changes,omitempty"`  // per source, against the stored records
+	Channels       map[string]*ChannelTotals  `json:"channels,omitempty"` // metrics calculated over the run's window
+	Restatements   int                        `json:"restatements,omitempty"`
+	ReplacedFrom   *time.Time                 `json:"replaced_from,omitempty"`
+	ExportHolds    []ExportHold               `json:"export_holds,omitempty"`
+	Gaps           []DataGap                  `json:"gaps,omitempty"`
+	Checksums      map[string]DataChecksum    `json:"checksums,omitempty"` // per source and of the calculated metrics
+	Join           *JoinReport                `json:"join,omitempty"`      // of the ads and opportunities in the metrics window
+	StartedAt      time.Time                  `json:"started_at"`
+	CompletedAt    time.Time                  `json:"completed_at"`
+}
+
+// limits of the tags a run can carry
+const (
+	MaxRunTags        = 20
+	MaxRunTagKeyLen   = 64
+	MaxRunTagValueLen = 256
+)
+
+// the tag scheduled runs are recorded with, trigger=schedule, runs of
+// uploaded files with trigger=upload and runs of stream batches with
+// trigger=stream
+const (
+	RunTagTrigger      = "trigger"
+	RunTriggerSchedule = "schedule"
+	RunTriggerUpload   = "upload"
+	RunTriggerStream   = "stream"
+)
+
+// checks the tags of a run. Keys are lowercase letters, digits, '_', '-'
+// and '.', so they read the same in filters; values are free text.
+func ValidateRunTags(tags map[string]string) error {
+	if len(tags) > MaxRunTags {
+		return Errorf(ErrValidation, "a run takes at most %d tags", MaxRunTags)
+	}
+	for key, value := range tags {
+		if key == "" || len(key) > MaxRunTagKeyLen || strings.IndexFunc(key, invalidTagKeyRune) >= 0 {
+			return Errorf(ErrValidation, "tag key %q must be 1 to %d lowercase letters, digits, '_', '-' or '.'", key, MaxRunTagKeyLen)
+		}
+		if value == "" || len(value) > MaxRunTagValueLen {
+			return Errorf(ErrValidation, "tag %s must have a value of 1 to %d characters", key, MaxRunTagValueLen)
+		}
+	}
+	return nil
+}
+
+func invalidTagKeyRune(r rune) bool {
+	return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '_' || r == '-' || r == '.')
+}
+
+// parses tag filters of the form key:value, matching runs tagged with the
+// value, or key, matching runs tagged with the key at all
+func ParseRunTagFilter(filters []string) (map[string]string, error) {
+	if len(filters) == 0 {
+		return nil, nil
+	}
+	tags := make(map[string]string, len(filters))
+	for _, filter := range filters {
+		key, value, _ := strings.Cut(filter, ":")
+		if key == "" {
+			return nil, Errorf(ErrValidation, "tag filter %q needs a key", filter)
+		}
+		if previous, ok := tags[key]; ok && previous != value {
+			return nil, Errorf(ErrValidation, "tag %s is filtered by more than one value", key)
+		}
+		tags[key] = value
+	}
+	return tags, nil
+}
+
+// outcomes of a recorded run
+const (
+	RunCompleted = "completed"
+	RunFailed    = "failed"
+)
+
+// a finished ETL run as kept for the run detail endpoint and notifications
+type RunRecord struct {
+	RunSummary
+	Tenant    string       `json:"tenant"`
+	Status    string       `json:"status"`
+	Error     string       `json:"error,omitempty"`
+	Anomalies []RunAnomaly `json:"anomalies,omitempty"`
+
+	// the audit certification of a completed run
+	Certification *RunCertification `json:"certification,omitempty"`
+}
+
+// selects recorded runs; empty fields match everything. A tag with an empty
+// value matches runs tagged with the key at all.
+type RunFilter struct {
+	Status   string
+	Pipeline string
+	Tags     map[string]string
+	Limit    int // 0 returns every match
+}
+
+// returns true if the run passes the filter
+func (f RunFilter) Matches(record RunRecord) bool {
+	if f.Status != "" && record.Status != f.Status {
+		return false
+	}
+	if f.Pipeline != "" && record.Pipeline != f.Pipeline {
+		return false
+	}
+	for key, value := range f.Tags {
+		tagged, ok := record.Tags[key]
+		if !ok || value != "" && tagged != value {
+			return false
+		}
+	}
+	return true
+}
+
+// kinds of data anomalies a run reports
+const (
+	AnomalyRejectedRows = "rejected_rows"
+	AnomalyNegative     = "negative_values"
+	AnomalyOutliers     = "outliers"
+	AnomalySpend        = "spend_deviation"
+	AnomalyGaps         = "data_gaps"
+)
+
+// unusual data seen by a run: rows a source rejected, negative and outlier
+// values of a value policy field, days whose spend put their exports on
+// hold, or days without data of a source
+type RunAnomaly struct {
+	Kind   string `json:"kind"`
+	Field  string `json:"field"` // source for rejected rows, "<source>.<field>" otherwise
+	Count  int    `json:"count"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// returns the anomalies of the run's parse and value reports in a stable
+// order
+func (s *RunSummary) Anomalies() []RunAnomaly {
+	var anomalies []RunAnomaly
+	for _, source := range []string{SourceAds, SourceCRM, SourceGA4, SourceKeywords} {
+		if report := s.Parsing[source]; report != nil && report.Rejected > 0 {
+			anomalies = append(anomalies, RunAnomaly{
+				Kind:   AnomalyRejectedRows,
+				Field:  source,
+				Count:  report.Rejected,
+				Detail: fmt.Sprintf("%.1f%% of %d rows", report.ErrorRate, report.Total),
+			})
+		}
+	}
+	for _, field := range ValuePolicyFields {
+		report := s.Values[field]
+		if report == nil {
+			continue
+		}
+		if report.Negative > 0 {
+			anomalies = append(anomalies, RunAnomaly{Kind: AnomalyNegative, Field: field, Count: report.Negative})
+		}
+		if report.Outliers > 0 {
+			anomaly := RunAnomaly{Kind: AnomalyOutliers, Field: field, Count: report.Outliers}
+			if report.Threshold > 0 {
+				anomaly.Detail = fmt.Sprintf("above %g", report.Threshold)
+			}
+			anomalies = append(anomalies, anomaly)
+		}
+	}
+	for _, hold := range s.ExportHolds {
+		anomalies = append(anomalies, RunAnomaly{
+			Kind:   AnomalySpend,
+			Field:  "ads.cost",
+			Count:  1,
+			Detail: fmt.Sprintf("%s spend %s is %.1fx the baseline %s, exports held as %s", hold.Date.Format("2006-01-02"), hold.Spend, hold.Deviation, hold.Baseline, hold.ID),
+		})
+	}
+	for _, gap := range s.Gaps {
+		detail := fmt.Sprintf("no data from %s to %s", gap.From, gap.To)
+		if gap.Filled {
+			detail += ", zero filled"
+		}
+		anomalies = append(anomalies, RunAnomaly{Kind: AnomalyGaps, Field: gap.Source, Count: gap.Days, Detail: detail})
+	}
+	return anomalies
+}
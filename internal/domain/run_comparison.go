@@ -0,0 +1,249 @@
+package domain
+
+import "time"
+
+// totals of the business metrics a run calculated for one channel over its
+// metrics window
+type ChannelTotals struct {
+	Clicks            int   `json:"clicks"`
+	Impressions       int   `json:"impressions"`
+	Cost              Money `json:"cost"`
+	Leads             int   `json:"leads"`
+	Opportunities     int   `json:"opportunities"`
+	ClosedWon         int   `json:"closed_won"`
+	Revenue           Money `json:"revenue"`
+	AttributedRevenue Money `json:"attributed_revenue"`
+	PipelineValue     Money `json:"pipeline_value"`
+	ExpectedRevenue   Money `json:"expected_revenue"`
+	Sessions          int   `json:"sessions"`
+	Conversions       int   `json:"conversions"`
+
+	// opportunities in the configured funnel stages
+	Stages map[OpportunityStage]int `json:"stages,omitempty"`
+}
+
+// sums the metrics per channel
+func ChannelTotalsOf(metrics []BusinessMetrics) map[string]*ChannelTotals {
+	totals := make(map[string]*ChannelTotals)
+	for _, metric := range metrics {
+		channel := totals[metric.Channel]
+		if channel == nil {
+			channel = &ChannelTotals{}
+			totals[metric.Channel] = channel
+		}
+		channel.Add(metric)
+	}
+	return totals
+}
+
+// adds the metric's counts and amounts to the totals
+func (t *ChannelTotals) Add(metric BusinessMetrics) {
+	t.Clicks += metric.Clicks
+	t.Impressions += metric.Impressions
+	t.Cost += metric.Cost
+	t.Leads += metric.Leads
+	t.Opportunities += metric.Opportunities
+	t.ClosedWon += metric.ClosedWon
+	for stage, count := range metric.Stages {
+		if t.Stages == nil {
+			t.Stages = make(map[OpportunityStage]int)
+		}
+		t.Stages[stage] += count
+	}
+	t.Revenue += metric.Revenue
+	t.AttributedRevenue += metric.AttributedRevenue
+	t.PipelineValue += metric.PipelineValue
+	t.ExpectedRevenue += metric.ExpectedRevenue
+	t.Sessions += metric.Sessions
+	t.Conversions += metric.Conversions
+}
+
+// a count of run A and run B with the change from A to B
+type CountDelta struct {
+	A     int `json:"a"`
+	B     int `json:"b"`
+	Delta int `json:"delta"`
+}
+
+func countDelta(a, b int) CountDelta {
+	return CountDelta{A: a, B: b, Delta: b - a}
+}
+
+// an amount of run A and run B with the change from A to B
+type MoneyDelta struct {
+	A     Money `json:"a"`
+	B     Money `json:"b"`
+	Delta Money `json:"delta"`
+}
+
+func moneyDelta(a, b Money) MoneyDelta {
+	return MoneyDelta{A: a, B: b, Delta: b - a}
+}
+
+// how the records of a source compare between two runs. Rejected rows
+// failed parsing; unchanged records matched their stored versions and were
+// not written again, which shadow results don't report.
+type SourceComparison struct {
+	Records   CountDelta  `json:"records"`
+	Rejected  CountDelta  `json:"rejected"`
+	Unchanged *CountDelta `json:"unchanged,omitempty"`
+}
+
+// how the metrics of a channel compare between two runs
+type ChannelComparison struct {
+	Clicks            CountDelta `json:"clicks"`
+	Impressions       CountDelta `json:"impressions"`
+	Cost              MoneyDelta `json:"cost"`
+	Leads             CountDelta `json:"leads"`
+	Opportunities     CountDelta `json:"opportunities"`
+	ClosedWon         CountDelta `json:"closed_won"`
+	Revenue           MoneyDelta `json:"revenue"`
+	AttributedRevenue MoneyDelta `json:"attributed_revenue"`
+	PipelineValue     MoneyDelta `json:"pipeline_value"`
+	ExpectedRevenue   MoneyDelta `json:"expected_revenue"`
+	Sessions          CountDelta `json:"sessions"`
+	Conversions       CountDelta `json:"conversions"`
+
+	Stages map[OpportunityStage]CountDelta `json:"stages,omitempty"`
+}
+
+// identifies a compared run
+type ComparedRun struct {
+	ID          string    `json:"id"`
+	Status      string    `json:"status"`
+	Pipeline    string    `json:"pipeline,omitempty"`
+	StartedAt   time.Time `json:"started_at"`
+	CompletedAt time.Time `json:"completed_at"`
+}
+
+// the differences between two runs over the same window, as B minus A
+type RunComparison struct {
+	A        ComparedRun                   `json:"a"`
+	B        ComparedRun                   `json:"b"`
+	Since    *time.Time                    `json:"since,omitempty"`
+	Sources  map[string]*SourceComparison  `json:"sources"`
+	Channels map[string]*ChannelComparison `json:"channels"`
+}
+
+// reports whether both runs extracted from the same day, or both without a
+// since filter, and resumed every source from the same watermark
+func SameRunWindow(a, b RunSummary) bool {
+	if a.Since == nil || b.Since == nil {
+		if a.Since != nil || b.Since != nil {
+			return false
+		}
+	} else if !a.Since.Equal(*b.Since) {
+		return false
+	}
+	if len(a.Watermarks) != len(b.Watermarks) {
+		return false
+	}
+	for source, watermark := range a.Watermarks {
+		if other, ok := b.Watermarks[source]; !ok || !other.Equal(watermark) {
+			return false
+		}
+	}
+	return true
+}
+
+// compares run B against run A. Sources missing from a run count as zero
+// there.
+func CompareRuns(a, b RunRecord) *RunComparison {
+	comparison := &RunComparison{
+		A:        comparedRun(a),
+		B:        comparedRun(b),
+		Since:    a.Since,
+		Sources:  make(map[string]*SourceComparison),
+		Channels: CompareChannels(a.Channels, b.Channels),
+	}
+
+	for _, source := range []string{SourceAds, SourceCRM, SourceGA4, SourceKeywords} {
+		unchanged := countDelta(unchangedRecords(a, source), unchangedRecords(b, source))
+		comparison.Sources[source] = &SourceComparison{
+			Records:   countDelta(loadedRecords(a, source), loadedRecords(b, source)),
+			Rejected:  countDelta(rejectedRows(a, source), rejectedRows(b, source)),
+			Unchanged: &unchanged,
+		}
+	}
+	return comparison
+}
+
+func comparedRun(run RunRecord) ComparedRun {
+	return ComparedRun{
+		ID:          run.ID,
+		Status:      run.Status,
+		Pipeline:    run.Pipeline,
+		StartedAt:   run.StartedAt,
+		CompletedAt: run.CompletedAt,
+	}
+}
+
+func loadedRecords(run RunRecord, source string) int {
+	switch source {
+	case SourceAds:
+		return run.AdsRecords
+	case SourceCRM:
+		return run.CRMRecords
+	case SourceKeywords:
+		return run.KeywordRecords
+	}
+	return run.SessionRecords
+}
+
+func rejectedRows(run RunRecord, source string) int {
+	if report := run.Parsing[source]; report != nil {
+		return report.Rejected
+	}
+	return 0
+}
+
+func unchangedRecords(run RunRecord, source string) int {
+	if changes := run.Changes[source]; changes != nil {
+		return changes.Unchanged
+	}
+	return 0
+}
+
+// compares the channel totals of B against A. Channels missing from one
+// side count as zero there.
+func CompareChannels(a, b map[string]*ChannelTotals) map[string]*ChannelComparison {
+	comparison := make(map[string]*ChannelComparison)
+	for _, totals := range []map[string]*ChannelTotals{a, b} {
+		for channel := range totals {
+			if comparison[channel] != nil {
+				continue
+			}
+			ta, tb := channelTotals(a, channel), channelTotals(b, channel)
+			comparison[channel] = &ChannelComparison{
+				Clicks:            countDelta(ta.Clicks, tb.Clicks),
+				Impressions:       countDelta(ta.Impressions, tb.Impressions),
+				Cost:              moneyDelta(ta.Cost, tb.Cost),
+				Leads:             countDelta(ta.Leads, tb.Leads),
+				Opportunities:     countDelta(ta.Opportunities, tb.Opportunities),
+				ClosedWon:         countDelta(ta.ClosedWon, tb.ClosedWon),
+				Revenue:           moneyDelta(ta.Revenue, tb.Revenue),
+				AttributedRevenue: moneyDelta(ta.AttributedRevenue, tb.AttributedRevenue),
+				PipelineValue:     moneyDelta(ta.PipelineValue, tb.PipelineValue),
+				ExpectedRevenue:   moneyDelta(ta.ExpectedRevenue, tb.ExpectedRevenue),
+				Sessions:          countDelta(ta.Sessions, tb.Sessions),
+				Conversions:       countDelta(ta.Conversions, tb.Conversions),
+			}
+			for _, stages := range []map[OpportunityStage]int{ta.Stages, tb.Stages} {
+				for stage := range stages {
+					if comparison[channel].Stages == nil {
+						comparison[channel].Stages = make(map[OpportunityStage]CountDelta)
+					}
+					comparison[channel].Stages[stage] = countDelta(ta.Stages[stage], tb.Stages[stage])
+				}
+			}
+		}
+	}
+	return comparison
+}
+
+func channelTotals(totals map[string]*ChannelTotals, channel string) ChannelTotals {
+	if t := totals[channel]; t != nil {
+		return *t
+	}
+	return ChannelTotals{}
+}
@@ -0,0 +1,91 @@
+package domain
+
+import (
+	"slices"
+	"time"
+)
+
+// the settings operators may change while the service runs. Changes last
+// until the next restart, which goes back to the configured values.
+type RuntimeConfig struct {
+	RateLimits  map[string]RateLimit `json:"rate_limits"` // by upstream API: ads, crm or sink
+	ParsePolicy ParsePolicy          `json:"parse_policy"`
+	// holds scheduled pipeline runs, independently of maintenance mode
+	SchedulesPaused bool `json:"schedules_paused"`
+}
+
+// the request rate of an upstream API. A PerSecond of 0 disables limiting.
+type RateLimit struct {
+	PerSecond int `json:"per_second"`
+	Burst     int `json:"burst"`
+}
+
+func (l RateLimit) Validate() error {
+	if l.PerSecond < 0 {
+		return Errorf(ErrValidation, "per_second cannot be negative")
+	}
+	if l.PerSecond > 0 && l.Burst < 1 {
+		return Errorf(ErrValidation, "burst must be at least 1, got %d", l.Burst)
+	}
+	return nil
+}
+
+// a partial change of the runtime settings. Settings left out keep their
+// value; rate limits are replaced per API.
+type RuntimeConfigPatch struct {
+	RateLimits      map[string]RateLimit `json:"rate_limits,omitempty"`
+	ParsePolicy     *ParsePolicy         `json:"parse_policy,omitempty"`
+	SchedulesPaused *bool                `json:"schedules_paused,omitempty"`
+	Reason          string               `json:"reason,omitempty"`
+}
+
+// Validate checks the patch against the current settings and fills in
+// defaults
+func (p *RuntimeConfigPatch) Validate(current RuntimeConfig) error {
+	if len(p.RateLimits) == 0 && p.ParsePolicy == nil && p.SchedulesPaused == nil {
+		return Errorf(ErrValidation, "no settings to change")
+	}
+	for api, limit := range p.RateLimits {
+		if _, ok := current.RateLimits[api]; !ok {
+			apis := make([]string, 0, len(current.RateLimits))
+			for known := range current.RateLimits {
+				apis = append(apis, known)
+			}
+			slices.Sort(apis)
+			return Errorf(ErrValidation, "unknown rate limited API %q, expected one of %v", api, apis)
+		}
+		if err := limit.Validate(); err != nil {
+			return Errorf(ErrValidation, "rate_limits.%s: %w", api, err)
+		}
+	}
+	if p.ParsePolicy != nil {
+		if err := p.ParsePolicy.Validate(); err != nil {
+			return Errorf(ErrValidation, "parse_policy: %w", err)
+		}
+	}
+	return nil
+}
+
+// an applied change of the runtime settings, kept for auditing
+type ConfigChange struct {
+	ID        string          `json:"id"`
+	Actor     string          `json:"actor"`
+	APIKey    string          `json:"api_key,omitempty"` // name of the admin key the change was made with
+	Reason    string          `json:"reason,omitempty"`
+	Changes   []SettingChange `json:"changes"`
+	ChangedAt time.Time       `json:"changed_at"`
+}
+
+// the old and new value of one changed setting, e.g. rate_limits.ads
+type SettingChange struct {
+	Setting string `json:"setting"`
+	From    any    `json:"from"`
+	To      any    `json:"to"`
+}
+
+// implemented by clients whose upstream rate limits can change while they
+// run. SetRateLimit applies to requests that wait for the limiter afterwards.
+type RateLimitController interface {
+	RateLimits() map[string]RateLimit
+	SetRateLimit(api string, limit RateLimit) error
+}
@@ -0,0 +1,267 @@
+package domain
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// reasons a scheduled pipeline run is skipped
+const (
+	SkipWeekend = "weekend"
+	SkipHoliday = "holiday"
+)
+
+// outcomes of scheduled pipeline runs
+const (
+	ScheduleCompleted      = "completed"
+	ScheduleFailed         = "failed"
+	ScheduleSkippedWeekend = "skipped_" + SkipWeekend
+	ScheduleSkippedHoliday = "skipped_" + SkipHoliday
+	ScheduleSkippedOverlap = "skipped_overlap"
+	ScheduleSkippedPaused  = "skipped_paused"
+	ScheduleSkippedQuota   = "skipped_quota"
+)
+
+// name of the pipeline running the full ETL on the ETL_SCHEDULE cron
+const ScheduledETLPipeline = "etl"
+
+// weekend used by calendars that do not configure one
+var DefaultWeekend = []string{"saturday", "sunday"}
+
+// a pipeline run due at a scheduled time and, when it is skipped, why
+type ScheduledRun struct {
+	At         time.Time `json:"at"`
+	Skip       string    `json:"skip,omitempty"`
+	SkipDetail string    `json:"skip_detail,omitempty"`
+}
+
+// records what happened to a scheduled pipeline run. A catch-up run covers
+// Missed runs the scheduler could not fire while the service was down and is
+// recorded at the last of them.
+type ScheduleRecord struct {
+	Pipeline    string     `json:"pipeline"`
+	DueAt       time.Time  `json:"due_at"`
+	Outcome     string     `json:"outcome"`
+	Detail      string     `json:"detail,omitempty"`
+	CatchUp     bool       `json:"catch_up,omitempty"`
+	Missed      int        `json:"missed,omitempty"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+// a scheduled pipeline with when it runs next, leaving out runs its
+// calendar skips, and its last recorded run
+type ScheduleStatus struct {
+	Pipeline  string          `json:"pipeline"`
+	Schedule  string          `json:"schedule"`
+	Running   bool            `json:"running"`
+	NextRunAt *time.Time      `json:"next_run_at,omitempty"`
+	LastRun   *ScheduleRecord `json:"last_run,omitempty"`
+}
+
+// a parsed 5 field cron expression: minute hour day-of-month month
+// day-of-week. Fields accept *, lists, ranges and steps; day-of-week 0 and 7
+// are Sunday. As in cron, when both day fields are restricted a time matches
+// either of them.
+type CronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	domAny, dowAny                bool
+}
+
+// cron field bounds in expression order
+var cronFields = []struct {
+	name     string
+	min, max int
+}{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+// parses a 5 field cron expression
+func ParseCronSchedule(expr string) (*CronSchedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("cron expression must have 5 fields, got %d", len(fields))
+	}
+
+	bits := make([]uint64, len(fields))
+	for i, field := range fields {
+		set, err := parseCronField(field, cronFields[i].min, cronFields[i].max)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s field %q: %w", cronFields[i].name, field, err)
+		}
+		bits[i] = set
+	}
+
+	// 7 is an alias for Sunday
+	if bits[4]&(1<<7) != 0 {
+		bits[4] = bits[4]&^(1<<7) | 1
+	}
+
+	return &CronSchedule{
+		minute: bits[0],
+		hour:   bits[1],
+		dom:    bits[2],
+		month:  bits[3],
+		dow:    bits[4],
+		domAny: fields[2] == "*",
+		dowAny: fields[4] == "*",
+	}, nil
+}
+
+func parseCronField(field string, min, max int) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepPart)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step %q", stepPart)
+			}
+			step = n
+		}
+
+		lo, hi := min, max
+		if rangePart != "*" {
+			from, to, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if lo, err = strconv.Atoi(from); err != nil {
+				return 0, fmt.Errorf("invalid value %q", from)
+			}
+			hi = lo
+			if isRange {
+				if hi, err = strconv.Atoi(to); err != nil {
+					return 0, fmt.Errorf("invalid value %q", to)
+				}
+			} else if hasStep {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("values must be between %d and %d", min, max)
+		}
+
+		for v := lo; v <= hi; v += step {
+			set |= 1 << v
+		}
+	}
+	return set, nil
+}
+
+// reports whether the schedule fires in t's minute
+func (s *CronSchedule) Matches(t time.Time) bool {
+	return s.minute&(1<<t.Minute()) != 0 &&
+		s.hour&(1<<t.Hour()) != 0 &&
+		s.month&(1<<int(t.Month())) != 0 &&
+		s.matchesDay(t)
+}
+
+func (s *CronSchedule) matchesDay(t time.Time) bool {
+	domMatch := s.dom&(1<<t.Day()) != 0
+	dowMatch := s.dow&(1<<int(t.Weekday())) != 0
+	switch {
+	case s.domAny || s.dowAny:
+		return domMatch && dowMatch
+	default:
+		return domMatch || dowMatch
+	}
+}
+
+// returns the first time after the given one the schedule fires, in its
+// location. The zero time is returned when it never fires within five years,
+// e.g. for February 30th.
+func (s *CronSchedule) Next(after time.Time) time.Time {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if s.month&(1<<int(t.Month())) == 0 || !s.matchesDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.hour&(1<<t.Hour()) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.minute&(1<<t.Minute()) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// a locale's non-working days. Holidays map YYYY-MM-DD dates to their names;
+// Weekend lists weekday names and defaults to Saturday and Sunday.
+type HolidayCalendar struct {
+	Weekend  []string          `json:"weekend,omitempty"`
+	Holidays map[string]string `json:"holidays"`
+}
+
+// checks weekday names and holiday dates
+func (c HolidayCalendar) Validate() error {
+	for _, day := range c.Weekend {
+		if _, ok := parseWeekday(day); !ok {
+			return fmt.Errorf("unknown weekday %q", day)
+		}
+	}
+	for date := range c.Holidays {
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			return fmt.Errorf("invalid holiday date %q, expected YYYY-MM-DD", date)
+		}
+	}
+	return nil
+}
+
+// reports whether t falls on the calendar's weekend
+func (c HolidayCalendar) IsWeekend(t time.Time) bool {
+	weekend := c.Weekend
+	if len(weekend) == 0 {
+		weekend = DefaultWeekend
+	}
+	for _, day := range weekend {
+		if weekday, ok := parseWeekday(day); ok && weekday == t.Weekday() {
+			return true
+		}
+	}
+	return false
+}
+
+// returns the name of the holiday t falls on
+func (c HolidayCalendar) Holiday(t time.Time) (string, bool) {
+	name, ok := c.Holidays[t.Format("2006-01-02")]
+	return name, ok
+}
+
+func parseWeekday(name string) (time.Weekday, bool) {
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if strings.EqualFold(day.String(), name) {
+			return day, true
+		}
+	}
+	return 0, false
+}
+
+// reports why the pipeline's scheduled run at t is skipped, with the
+// holiday name as detail, or an empty reason when it runs. Pipelines with a
+// holiday calendar skip its holidays; business-days-only pipelines also skip
+// the weekend, using the calendar's weekend when one is set.
+func (p Pipeline) ScheduleSkip(t time.Time, calendar *HolidayCalendar) (reason, detail string) {
+	if calendar == nil {
+		calendar = &HolidayCalendar{}
+	}
+	if name, ok := calendar.Holiday(t); ok {
+		return SkipHoliday, name
+	}
+	if p.BusinessDaysOnly && calendar.IsWeekend(t) {
+		return SkipWeekend, strings.ToLower(t.Weekday().String())
+	}
+	return "", ""
+}
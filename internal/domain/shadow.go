@@ -0,0 +1,113 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+var (
+	ErrShadowNotFound       = NewError(ErrNotFound, "shadow config not found")
+	ErrShadowResultNotFound = NewError(ErrNotFound, "shadow result not found")
+	ErrInvalidShadow        = NewError(ErrValidation, "invalid shadow config")
+)
+
+// a candidate configuration evaluated in shadow mode. Every run also
+// applies it to the same extracted rows without storing anything in the
+// active dataset; unset fields keep the active configuration.
+type ShadowConfig struct {
+	Label               string                 `json:"label"`
+	Description         string                 `json:"description,omitempty"`
+	AttributionMode     string                 `json:"attribution_mode,omitempty"`
+	AttributionHalfLife string                 `json:"attribution_half_life,omitempty"` // e.g. 168h, for time_decay
+	Parsing             map[string]ParsePolicy `json:"parsing,omitempty"`
+	Values              ValuePolicies          `json:"values,omitempty"`
+	CreatedBy           string                 `json:"created_by"`
+	CreatedAt           time.Time              `json:"created_at"`
+}
+
+// Validate checks the config and fills in defaults
+func (c *ShadowConfig) Validate() error {
+	if c.Label == "" {
+		return invalidShadow("label is required")
+	}
+	for _, r := range c.Label {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '_' || r == '-') {
+			return invalidShadow("label may only contain lowercase letters, digits, '_' and '-'")
+		}
+	}
+
+	if c.AttributionMode != "" {
+		if _, err := c.Attribution(AttributionModel{}); err != nil {
+			return invalidShadow("%v", err)
+		}
+	} else if c.AttributionHalfLife != "" {
+		return invalidShadow("attribution_half_life requires attribution_mode")
+	}
+
+	for source, policy := range c.Parsing {
+		if source != SourceAds && source != SourceCRM {
+			return invalidShadow("parsing configured for unsupported source %q", source)
+		}
+		if err := policy.Validate(); err != nil {
+			return invalidShadow("%s parsing: %v", source, err)
+		}
+		c.Parsing[source] = policy
+	}
+
+	if err := c.Values.Validate(); err != nil {
+		return invalidShadow("%v", err)
+	}
+
+	if c.AttributionMode == "" && len(c.Parsing) == 0 && c.Values == nil {
+		return invalidShadow("at least one of attribution_mode, parsing or values is required")
+	}
+	return nil
+}
+
+// returns the attribution model of the config, or active when it doesn't
+// set one
+func (c ShadowConfig) Attribution(active AttributionModel) (AttributionModel, error) {
+	if c.AttributionMode == "" {
+		return active, nil
+	}
+	model := AttributionModel{Mode: c.AttributionMode}
+	if c.AttributionHalfLife != "" {
+		halfLife, err := time.ParseDuration(c.AttributionHalfLife)
+		if err != nil {
+			return model, fmt.Errorf("invalid attribution_half_life %q", c.AttributionHalfLife)
+		}
+		model.HalfLife = halfLife
+	}
+	return model, model.Validate()
+}
+
+func invalidShadow(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", ErrInvalidShadow, fmt.Sprintf(format, args...))
+}
+
+// the outcome of a shadow config for one run, compared against the active
+// configuration on the same rows as A against B. Error is set when the
+// shadow's parse policies would have failed the run.
+type ShadowResult struct {
+	ID        string                        `json:"id"`
+	Label     string                        `json:"label"`
+	RunID     string                        `json:"run_id"`
+	Since     *time.Time                    `json:"since,omitempty"`
+	Config    ShadowConfig                  `json:"config"`
+	Error     string                        `json:"error,omitempty"`
+	Parsing   map[string]*ParseReport       `json:"parsing"`
+	Values    map[string]*ValueReport       `json:"values,omitempty"`
+	Sources   map[string]*SourceComparison  `json:"sources"`
+	Channels  map[string]*ChannelComparison `json:"channels"`
+	Metrics   []BusinessMetrics             `json:"metrics,omitempty"`
+	CreatedAt time.Time                     `json:"created_at"`
+}
+
+// compares the records and rejected rows of a source under a shadow config
+// (B) with the active configuration (A)
+func CompareShadowSource(activeRecords, activeRejected, records, rejected int) *SourceComparison {
+	return &SourceComparison{
+		Records:  countDelta(activeRecords, records),
+		Rejected: countDelta(activeRejected, rejected),
+	}
+}
@@ -0,0 +1,155 @@
+package domain
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalidShareToken is returned for share tokens that are malformed
+	// or weren't signed with the configured secret
+	ErrInvalidShareToken = errors.New("invalid share token")
+	// ErrShareTokenExpired is returned for share tokens past their expiry
+	ErrShareTokenExpired = errors.New("share token expired")
+	// ErrShareTokenRevoked is returned for share tokens revoked by ID
+	ErrShareTokenRevoked = errors.New("share token revoked")
+	// ErrShareTokensNotConfigured is returned for share tokens issued or
+	// presented without a signing secret
+	ErrShareTokensNotConfigured = errors.New("share tokens not configured")
+)
+
+// a signed, expiring grant of read-only access to one metrics view, e.g.
+// one campaign over the last 30 days, for embedding in a partner portal.
+// The view is the rows in Scope over the last Days days, or from From to
+// To, without the columns Role hides. A grant always has a scope, so a
+// token never grants every row. Tokens hold the grant itself; only the IDs
+// of revoked ones are stored, and rotating the secret revokes them all.
+type ShareToken struct {
+	ID        string       `json:"id"`
+	Name      string       `json:"name,omitempty"`
+	Scope     MetricsScope `json:"scope,omitempty"`
+	Days      int          `json:"days,omitempty"`
+	From      string       `json:"from,omitempty"`
+	To        string       `json:"to,omitempty"`
+	Role      string       `json:"role,omitempty"`
+	ExpiresAt time.Time    `json:"expires_at"`
+}
+
+func (t ShareToken) Validate() error {
+	if len(t.Scope) == 0 {
+		return Errorf(ErrValidation, "scope is required, a share token grants one view")
+	}
+	if err := t.Scope.Validate(); err != nil {
+		return Errorf(ErrValidation, "%w", err)
+	}
+	fixed := t.From != "" || t.To != ""
+	switch {
+	case t.Days < 0:
+		return Errorf(ErrValidation, "days must not be negative")
+	case t.Days > 0 && fixed:
+		return Errorf(ErrValidation, "set days or from and to, not both")
+	case t.Days == 0 && !fixed:
+		return Errorf(ErrValidation, "days or from and to are required")
+	case fixed:
+		from, err := time.Parse("2006-01-02", t.From)
+		if err != nil {
+			return Errorf(ErrValidation, "invalid from date %q", t.From)
+		}
+		to, err := time.Parse("2006-01-02", t.To)
+		if err != nil {
+			return Errorf(ErrValidation, "invalid to date %q", t.To)
+		}
+		if from.After(to) {
+			return Errorf(ErrValidation, "from must not be after to")
+		}
+	}
+	return nil
+}
+
+// returns the first and last day of the view as of now
+func (t ShareToken) Window(now time.Time) (from, to time.Time) {
+	if t.Days > 0 {
+		to = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+		return to.AddDate(0, 0, 1-t.Days), to
+	}
+	from, _ = time.Parse("2006-01-02", t.From)
+	to, _ = time.Parse("2006-01-02", t.To)
+	return from, to
+}
+
+// returns the API key queries of the view are made with, so they are held
+// to its scope and role like a partner's key
+func (t ShareToken) APIKey() APIKey {
+	return APIKey{Name: "share:" + t.ID, Scope: t.Scope, Role: t.Role}
+}
+
+// returns the token handed out: its grant as base64url JSON, a dot and
+// the base64url HMAC-SHA256 of the grant with the secret
+func (t ShareToken) Sign(secret string) (string, error) {
+	raw, err := json.Marshal(t)
+	if err != nil {
+		return "", err
+	}
+	grant := base64.RawURLEncoding.EncodeToString(raw)
+	return grant + "." + shareTokenSignature(secret, grant), nil
+}
+
+// verifies a signed token and returns its grant
+func ParseShareToken(signed, secret string, now time.Time) (*ShareToken, error) {
+	if secret == "" {
+		return nil, ErrShareTokensNotConfigured
+	}
+	grant, signature, ok := strings.Cut(signed, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(shareTokenSignature(secret, grant))) {
+		return nil, ErrInvalidShareToken
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(grant)
+	if err != nil {
+		return nil, ErrInvalidShareToken
+	}
+	var token ShareToken
+	if err := json.Unmarshal(raw, &token); err != nil || len(token.Scope) == 0 {
+		return nil, ErrInvalidShareToken
+	}
+	if !now.Before(token.ExpiresAt) {
+		return nil, fmt.Errorf("%w at %s", ErrShareTokenExpired, token.ExpiresAt.Format(time.RFC3339))
+	}
+	return &token, nil
+}
+
+func shareTokenSignature(secret, grant string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(grant))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// records that the share token with the ID was revoked, by the admin key
+// named RevokedBy
+type ShareTokenRevocation struct {
+	ID        string    `json:"id"`
+	RevokedAt time.Time `json:"revoked_at"`
+	RevokedBy string    `json:"revoked_by,omitempty"`
+}
+
+type shareTokenContextKey struct{}
+
+// returns a context of a request made with the share token
+func WithShareToken(ctx context.Context, token ShareToken) context.Context {
+	return context.WithValue(ctx, shareTokenContextKey{}, token)
+}
+
+// returns the share token the request was made with, nil without one
+func ShareTokenFromContext(ctx context.Context) *ShareToken {
+	token, ok := ctx.Value(shareTokenContextKey{}).(ShareToken)
+	if !ok {
+		return nil
+	}
+	return &token
+}
@@ -0,0 +1,58 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	// ErrSinkNotConfigured is returned when there is no sink to deliver to
+	ErrSinkNotConfigured = errors.New("sink URL not configured")
+	// ErrInvalidSinkTarget is returned for sink configurations that cannot be verified
+	ErrInvalidSinkTarget = NewError(ErrValidation, "invalid sink target")
+)
+
+// a configured sink to verify before routing real data to it: an export
+// destination, or the sink of SINK_URL when URL is empty. Targets never
+// come from requests, so the service only posts to URLs operators set up.
+type SinkTarget struct {
+	URL         string
+	Secret      string
+	Compression string
+}
+
+// checks run against a sink
+const (
+	SinkCheckSignedAccepted           = "signed_payload_accepted"
+	SinkCheckResponseBody             = "response_body"
+	SinkCheckSigningConfigured        = "signing_configured"
+	SinkCheckBadSignatureRejected     = "bad_signature_rejected"
+	SinkCheckMissingSignatureRejected = "missing_signature_rejected"
+)
+
+// the outcome of one check
+type SinkCheck struct {
+	Name   string `json:"name"`
+	Passed bool   `json:"passed"`
+	Detail string `json:"detail"`
+}
+
+// the result of sending a synthetic payload to a sink. Passed is true when
+// every check passed.
+type SinkVerification struct {
+	URL             string            `json:"url"`
+	Compression     string            `json:"compression"`
+	Passed          bool              `json:"passed"`
+	Checks          []SinkCheck       `json:"checks"`
+	Status          int               `json:"status,omitempty"`
+	LatencySeconds  float64           `json:"latency_seconds"`
+	ResponseHeaders map[string]string `json:"response_headers,omitempty"`
+	ResponseBody    string            `json:"response_body,omitempty"` // truncated
+	VerifiedAt      time.Time         `json:"verified_at"`
+}
+
+// records a check, failing the verification when it did not pass
+func (v *SinkVerification) Check(name string, passed bool, detail string) {
+	v.Checks = append(v.Checks, SinkCheck{Name: name, Passed: passed, Detail: detail})
+	v.Passed = v.Passed && passed
+}
@@ -0,0 +1,17 @@
+package domain
+
+import "time"
+
+// a file of a source read from an object store bucket. Objects are recorded
+// once the run that read them succeeds, and later runs skip the recorded
+// ones unless their ETag changed.
+type SourceObject struct {
+	Source     string    `json:"source"`
+	Tenant     string    `json:"tenant,omitempty"`
+	Key        string    `json:"key"`
+	ETag       string    `json:"etag"`
+	Size       int64     `json:"size"`
+	Records    int       `json:"records"`
+	RunID      string    `json:"run_id,omitempty"`
+	IngestedAt time.Time `json:"ingested_at"`
+}
@@ -0,0 +1,119 @@
+package domain
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// datasets copied between storage backends
+const (
+	DatasetAds     = "ads"
+	DatasetCRM     = "crm"
+	DatasetMetrics = "metrics"
+)
+
+// StorageDatasets lists every dataset a storage migration can copy
+var StorageDatasets = []string{DatasetAds, DatasetCRM, DatasetMetrics}
+
+// the repositories backed by one storage backend. Schema is nil for
+// backends without a schema; Closer releases the backend's connections and
+// may be nil.
+type Storage struct {
+	Backend string
+	Ads     AdRepository
+	CRM     CRMRepository
+	Metrics MetricsRepository
+	Schema  SchemaMigrator
+	Closer  io.Closer
+}
+
+type storageLoadKey struct{}
+
+// returns a context whose repository reads belong to a load, such as an ETL
+// run, which waits for storage instead of being turned away when it is busy
+func WithStorageLoad(ctx context.Context) context.Context {
+	return context.WithValue(ctx, storageLoadKey{}, true)
+}
+
+// reports whether the context's repository reads belong to a load
+func IsStorageLoad(ctx context.Context) bool {
+	load, _ := ctx.Value(storageLoadKey{}).(bool)
+	return load
+}
+
+// interface for versioned schema migrations of SQL backends
+type SchemaMigrator interface {
+	Status(ctx context.Context) (SchemaStatus, error)
+	Migrate(ctx context.Context) (SchemaStatus, error)
+}
+
+// reports the schema version of a SQL backend against the migrations
+// built into this binary
+type SchemaStatus struct {
+	Dialect   string     `json:"dialect"`
+	Version   int        `json:"version"`
+	Latest    int        `json:"latest"`
+	Pending   int        `json:"pending"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+// releases the backend's resources
+func (s *Storage) Close() error {
+	if s.Closer == nil {
+		return nil
+	}
+	return s.Closer.Close()
+}
+
+// selects what a storage migration copies. Data is streamed one day at a
+// time from Start to End inclusive.
+type MigrationOptions struct {
+	Start    time.Time
+	End      time.Time
+	Datasets []string
+	DryRun   bool
+}
+
+// reports progress after each copied day
+type MigrationProgress struct {
+	Dataset string    `json:"dataset"`
+	Date    time.Time `json:"date"`
+	Day     int       `json:"day"`
+	Days    int       `json:"days"`
+	Records int       `json:"records"`
+}
+
+// verification counts of one migrated dataset. Destination counts the
+// source records found in the destination by natural key, and a day is a
+// mismatch when any is missing.
+type MigrationReport struct {
+	Dataset     string   `json:"dataset"`
+	Days        int      `json:"days"`
+	Source      int      `json:"source_records"`
+	Copied      int      `json:"copied_records"`
+	Destination int      `json:"destination_records"`
+	Mismatches  []string `json:"mismatched_days,omitempty"`
+}
+
+// returns true if every day verified
+func (r MigrationReport) Verified() bool {
+	return len(r.Mismatches) == 0
+}
+
+// describes the storage backend in health reports. Schema is nil for
+// backends without a schema.
+type StorageStatus struct {
+	Backend string        `json:"backend"`
+	Schema  *SchemaStatus `json:"schema,omitempty"`
+}
+
+// the outcome of one storage conformance check, see
+// storagetest.Check
+type ConformanceResult struct {
+	Check       string `json:"check"`
+	Dataset     string `json:"dataset"`
+	Description string `json:"description"`
+	Passed      bool   `json:"passed"`
+	Error       string `json:"error,omitempty"`
+}
@@ -0,0 +1,83 @@
+package domain
+
+import "time"
+
+// the offsets of a topic partition a stream batch was read from
+type StreamPartition struct {
+	Topic     string `json:"topic"`
+	Partition int    `json:"partition"`
+	First     int64  `json:"first_offset"`
+	Last      int64  `json:"last_offset"`
+}
+
+// records consumed from the ads and CRM topics of a stream, with the
+// offsets they were read from per partition. Messages counts the records
+// read, rejected ones included.
+type StreamBatch struct {
+	Ads        *AdData
+	CRM        *CRMData
+	Messages   int
+	Partitions []StreamPartition
+}
+
+// returns the sources the batch holds records of
+func (b *StreamBatch) Sources() []string {
+	var sources []string
+	if b.Ads != nil {
+		sources = append(sources, SourceAds)
+	}
+	if b.CRM != nil {
+		sources = append(sources, SourceCRM)
+	}
+	return sources
+}
+
+// adds the records and offsets of the next batch
+func (b *StreamBatch) Append(next *StreamBatch) {
+	if next.Ads != nil {
+		if b.Ads == nil {
+			b.Ads = &AdData{}
+		}
+		b.Ads.External.Ads.Performance = append(b.Ads.External.Ads.Performance, next.Ads.External.Ads.Performance...)
+		b.Ads.Rejected = append(b.Ads.Rejected, next.Ads.Rejected...)
+	}
+	if next.CRM != nil {
+		if b.CRM == nil {
+			b.CRM = &CRMData{}
+		}
+		b.CRM.External.CRM.Opportunities = append(b.CRM.External.CRM.Opportunities, next.CRM.External.CRM.Opportunities...)
+		b.CRM.Rejected = append(b.CRM.Rejected, next.CRM.Rejected...)
+	}
+	b.Messages += next.Messages
+
+	for _, partition := range next.Partitions {
+		merged := false
+		for i := range b.Partitions {
+			existing := &b.Partitions[i]
+			if existing.Topic == partition.Topic && existing.Partition == partition.Partition {
+				existing.First = min(existing.First, partition.First)
+				existing.Last = max(existing.Last, partition.Last)
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			b.Partitions = append(b.Partitions, partition)
+		}
+	}
+}
+
+// the state of streaming ingestion. Committed holds the last offset loaded
+// per partition.
+type StreamStatus struct {
+	Enabled    bool              `json:"enabled"`
+	Group      string            `json:"group,omitempty"`
+	Topics     []string          `json:"topics,omitempty"`
+	Batches    int               `json:"batches"`
+	Messages   int               `json:"messages"`
+	Failures   int               `json:"failures"`
+	LastRunID  string            `json:"last_run_id,omitempty"`
+	LastLoadAt *time.Time        `json:"last_load_at,omitempty"`
+	LastError  string            `json:"last_error,omitempty"`
+	Committed  []StreamPartition `json:"committed,omitempty"`
+}
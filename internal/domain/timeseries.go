@@ -0,0 +1,317 @@
+package domain
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"time"
+)
+
+// bucket sizes of time series
+const (
+	IntervalDay   = "day"
+	IntervalWeek  = "week"
+	IntervalMonth = "month"
+)
+
+// how buckets without data, or whose ratio is undefined, are filled
+const (
+	GapFillZero = "zero"
+	GapFillNull = "null"
+)
+
+// the series key of an ungrouped time series
+const TimeSeriesTotal = "total"
+
+// the most buckets a time series may have
+const MaxTimeSeriesBuckets = 1000
+
+// the value of a metric in a bucket from its totals. ok is false for ratios
+// with a zero denominator.
+type seriesMetric func(t ChannelTotals) (value float64, ok bool)
+
+func countMetric(count func(ChannelTotals) int) seriesMetric {
+	return func(t ChannelTotals) (float64, bool) { return float64(count(t)), true }
+}
+
+func moneyMetric(amount func(ChannelTotals) Money) seriesMetric {
+	return func(t ChannelTotals) (float64, bool) { return amount(t).Float64(), true }
+}
+
+// units of metrics: counts of events, amounts in the reporting currency and
+// unitless ratios
+const (
+	MetricUnitCount    = "count"
+	MetricUnitCurrency = "currency"
+	MetricUnitRatio    = "ratio"
+)
+
+// the version every metric of the first release is available since
+const firstMetricsVersion = "1.0.0"
+
+// a metric the service computes: how its value is calculated from a
+// bucket's totals, and how the metric catalog describes it
+type metricDefinition struct {
+	value       seriesMetric
+	unit        string
+	formula     string
+	description string
+	since       string // version of the service that introduced the metric
+}
+
+// metrics a time series can chart, and derived models and the metric
+// catalog build on. Ratios are calculated from the bucket's totals rather
+// than averaged over its rows.
+var timeSeriesMetrics = map[string]metricDefinition{
+	"clicks": {
+		value: countMetric(func(t ChannelTotals) int { return t.Clicks }),
+		unit:  MetricUnitCount, formula: "sum(clicks)", since: firstMetricsVersion,
+		description: "Ad clicks",
+	},
+	"impressions": {
+		value: countMetric(func(t ChannelTotals) int { return t.Impressions }),
+		unit:  MetricUnitCount, formula: "sum(impressions)", since: firstMetricsVersion,
+		description: "Ad impressions",
+	},
+	"leads": {
+		value: countMetric(func(t ChannelTotals) int { return t.Leads }),
+		unit:  MetricUnitCount, formula: "sum(leads)", since: firstMetricsVersion,
+		description: "CRM opportunities in the lead stage",
+	},
+	"opportunities": {
+		value: countMetric(func(t ChannelTotals) int { return t.Opportunities }),
+		unit:  MetricUnitCount, formula: "sum(opportunities)", since: firstMetricsVersion,
+		description: "CRM opportunities in the opportunity stage",
+	},
+	"closed_won": {
+		value: countMetric(func(t ChannelTotals) int { return t.ClosedWon }),
+		unit:  MetricUnitCount, formula: "sum(closed_won)", since: firstMetricsVersion,
+		description: "CRM opportunities closed won",
+	},
+	"sessions": {
+		value: countMetric(func(t ChannelTotals) int { return t.Sessions }),
+		unit:  MetricUnitCount, formula: "sum(sessions)", since: firstMetricsVersion,
+		description: "Web analytics sessions",
+	},
+	"conversions": {
+		value: countMetric(func(t ChannelTotals) int { return t.Conversions }),
+		unit:  MetricUnitCount, formula: "sum(conversions)", since: firstMetricsVersion,
+		description: "Web analytics conversions",
+	},
+	"cost": {
+		value: moneyMetric(func(t ChannelTotals) Money { return t.Cost }),
+		unit:  MetricUnitCurrency, formula: "sum(cost)", since: firstMetricsVersion,
+		description: "Ad spend",
+	},
+	"revenue": {
+		value: moneyMetric(func(t ChannelTotals) Money { return t.Revenue }),
+		unit:  MetricUnitCurrency, formula: "sum(revenue)", since: firstMetricsVersion,
+		description: "Revenue of closed won opportunities",
+	},
+	"attributed_revenue": {
+		value: moneyMetric(func(t ChannelTotals) Money { return t.AttributedRevenue }),
+		unit:  MetricUnitCurrency, formula: "sum(attributed_revenue)", since: firstMetricsVersion,
+		description: "Closed won revenue credited by the attribution model",
+	},
+	"pipeline_value": {
+		value: moneyMetric(func(t ChannelTotals) Money { return t.PipelineValue }),
+		unit:  MetricUnitCurrency, formula: "sum(pipeline_value)", since: firstMetricsVersion,
+		description: "Amounts of open leads and opportunities at face value",
+	},
+	"expected_revenue": {
+		value: moneyMetric(func(t ChannelTotals) Money { return t.ExpectedRevenue }),
+		unit:  MetricUnitCurrency, formula: "sum(expected_revenue)", since: firstMetricsVersion,
+		description: "Amounts of open leads and opportunities weighted by their win probability",
+	},
+	"cpc": {
+		value: func(t ChannelTotals) (float64, bool) {
+			return t.Cost.Div(t.Clicks).Float64(), t.Clicks > 0
+		},
+		unit: MetricUnitCurrency, formula: "sum(cost) / sum(clicks)", since: firstMetricsVersion,
+		description: "Cost per click",
+	},
+	"cpa": {
+		value: func(t ChannelTotals) (float64, bool) {
+			return t.Cost.Div(t.Leads).Float64(), t.Leads > 0
+		},
+		unit: MetricUnitCurrency, formula: "sum(cost) / sum(leads)", since: firstMetricsVersion,
+		description: "Cost per lead",
+	},
+	"roas": {
+		value: func(t ChannelTotals) (float64, bool) {
+			return t.Revenue.Ratio(t.Cost), t.Cost > 0
+		},
+		unit: MetricUnitRatio, formula: "sum(revenue) / sum(cost)", since: firstMetricsVersion,
+		description: "Return on ad spend",
+	},
+	"cvr_click_to_lead": {
+		value: func(t ChannelTotals) (float64, bool) {
+			return conversionRate(t.Leads, t.Clicks), t.Clicks > 0
+		},
+		unit: MetricUnitRatio, formula: "sum(leads) / sum(clicks)", since: firstMetricsVersion,
+		description: "Leads per click",
+	},
+	"cvr_lead_to_opp": {
+		value: func(t ChannelTotals) (float64, bool) {
+			return conversionRate(t.Opportunities, t.Leads), t.Leads > 0
+		},
+		unit: MetricUnitRatio, formula: "sum(opportunities) / sum(leads)", since: firstMetricsVersion,
+		description: "Opportunities per lead",
+	},
+	"cvr_opp_to_won": {
+		value: func(t ChannelTotals) (float64, bool) {
+			return conversionRate(t.ClosedWon, t.Opportunities), t.Opportunities > 0
+		},
+		unit: MetricUnitRatio, formula: "sum(closed_won) / sum(opportunities)", since: firstMetricsVersion,
+		description: "Closed won opportunities per opportunity",
+	},
+}
+
+// TimeSeriesMetrics lists the metrics a time series can chart
+var TimeSeriesMetrics = slices.Sorted(maps.Keys(timeSeriesMetrics))
+
+// a request for a metric over time, bucketed by interval and optionally
+// split into one series per value of a dimension
+type TimeSeriesQuery struct {
+	Metric   string
+	GroupBy  string
+	Interval string
+	Fill     string
+	From     time.Time
+	To       time.Time
+}
+
+func (q TimeSeriesQuery) Validate() error {
+	if _, ok := timeSeriesMetrics[q.Metric]; !ok {
+		return Errorf(ErrValidation, "unsupported metric %q, expected one of: %s", q.Metric, strings.Join(TimeSeriesMetrics, ", "))
+	}
+	if q.GroupBy != "" && !IsValidDimension(q.GroupBy) {
+		return Errorf(ErrValidation, "unsupported group_by %q, expected one of: %s", q.GroupBy, strings.Join(Dimensions, ", "))
+	}
+	if q.Fill != GapFillZero && q.Fill != GapFillNull {
+		return Errorf(ErrValidation, "unsupported fill %q, expected %s or %s", q.Fill, GapFillZero, GapFillNull)
+	}
+	return validateBuckets(q.Interval, q.From, q.To)
+}
+
+// checks the interval and that the range has at most MaxTimeSeriesBuckets
+// buckets of it
+func validateBuckets(interval string, from, to time.Time) error {
+	switch interval {
+	case IntervalDay, IntervalWeek, IntervalMonth:
+	default:
+		return Errorf(ErrValidation, "unsupported interval %q, expected %s, %s or %s", interval, IntervalDay, IntervalWeek, IntervalMonth)
+	}
+	if from.After(to) {
+		return Errorf(ErrValidation, "from must not be after to")
+	}
+	buckets, last := 0, BucketStart(interval, to)
+	for bucket := BucketStart(interval, from); !bucket.After(last); bucket = nextBucket(interval, bucket) {
+		if buckets++; buckets > MaxTimeSeriesBuckets {
+			return Errorf(ErrValidation, "the range has more than %d %s buckets, narrow it or use a longer interval", MaxTimeSeriesBuckets, interval)
+		}
+	}
+	return nil
+}
+
+// returns the start of the interval's bucket holding the time, in UTC.
+// Weeks start on Monday.
+func BucketStart(interval string, t time.Time) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch interval {
+	case IntervalWeek:
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	case IntervalMonth:
+		return day.AddDate(0, 0, 1-day.Day())
+	}
+	return day
+}
+
+func nextBucket(interval string, start time.Time) time.Time {
+	switch interval {
+	case IntervalWeek:
+		return start.AddDate(0, 0, 7)
+	case IntervalMonth:
+		return start.AddDate(0, 1, 0)
+	}
+	return start.AddDate(0, 0, 1)
+}
+
+// one line of a time series, with a value per timestamp of the series. Null
+// values are gaps under the null fill policy.
+type TimeSeriesLine struct {
+	Key    string     `json:"key"`
+	Values []*float64 `json:"values"`
+}
+
+// a metric over time as aligned arrays ready for charting: every line has a
+// value for every timestamp, the start of each bucket
+type TimeSeries struct {
+	Metric     string           `json:"metric"`
+	GroupBy    string           `json:"group_by,omitempty"`
+	Interval   string           `json:"interval"`
+	Fill       string           `json:"fill"`
+	Timestamps []time.Time      `json:"timestamps"`
+	Series     []TimeSeriesLine `json:"series"`
+}
+
+// buckets the metrics of a validated query into its time series. Lines are
+// ordered by key; an ungrouped series always has one line, keyed total.
+func NewTimeSeries(query TimeSeriesQuery, metrics []BusinessMetrics) *TimeSeries {
+	series := &TimeSeries{
+		Metric:     query.Metric,
+		GroupBy:    query.GroupBy,
+		Interval:   query.Interval,
+		Fill:       query.Fill,
+		Timestamps: []time.Time{},
+		Series:     []TimeSeriesLine{},
+	}
+
+	index := make(map[time.Time]int)
+	last := BucketStart(query.Interval, query.To)
+	for bucket := BucketStart(query.Interval, query.From); !bucket.After(last); bucket = nextBucket(query.Interval, bucket) {
+		index[bucket] = len(series.Timestamps)
+		series.Timestamps = append(series.Timestamps, bucket)
+	}
+
+	totals := make(map[string][]*ChannelTotals)
+	if query.GroupBy == "" {
+		totals[TimeSeriesTotal] = make([]*ChannelTotals, len(series.Timestamps))
+	}
+	for _, metric := range metrics {
+		i, ok := index[BucketStart(query.Interval, metric.Date)]
+		if !ok {
+			continue
+		}
+		key := TimeSeriesTotal
+		if query.GroupBy != "" {
+			key = metric.DimensionValue(query.GroupBy)
+		}
+		buckets := totals[key]
+		if buckets == nil {
+			buckets = make([]*ChannelTotals, len(series.Timestamps))
+			totals[key] = buckets
+		}
+		if buckets[i] == nil {
+			buckets[i] = &ChannelTotals{}
+		}
+		buckets[i].Add(metric)
+	}
+
+	value := timeSeriesMetrics[query.Metric].value
+	for _, key := range slices.Sorted(maps.Keys(totals)) {
+		line := TimeSeriesLine{Key: key, Values: make([]*float64, len(series.Timestamps))}
+		for i, bucket := range totals[key] {
+			var v float64
+			ok := false
+			if bucket != nil {
+				v, ok = value(*bucket)
+			}
+			if ok || query.Fill == GapFillZero {
+				line.Values[i] = &v
+			}
+		}
+		series.Series = append(series.Series, line)
+	}
+	return series
+}
@@ -0,0 +1,33 @@
+package domain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+)
+
+// the rules business metrics are calculated with. Metrics record the
+// version of the rules they were calculated under, so periods calculated
+// under different rules can be told apart.
+type TransformConfig struct {
+	Attribution       AttributionModel   `json:"attribution"`
+	Recognition       RevenueRecognition `json:"recognition"`
+	FuzzyUTMThreshold float64            `json:"fuzzy_utm_threshold"`
+	CRMOnlyKeys       bool               `json:"crm_only_keys"`
+	Funnel            Funnel             `json:"funnel"`
+	Probabilities     StageProbabilities `json:"stage_probabilities,omitempty"`
+	BaseCurrency      string             `json:"base_currency"`
+	ValuePolicies     ValuePolicies      `json:"value_policies,omitempty"`
+	DateSkew          *DateSkewPolicy    `json:"date_skew,omitempty"`
+}
+
+// returns the first 12 hex digits of the SHA-256 of the rules
+func (c TransformConfig) Version() string {
+	encoded, err := json.Marshal(c)
+	if err != nil {
+		// the rules are plain data, they always encode
+		panic(err)
+	}
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:6])
+}
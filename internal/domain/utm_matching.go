@@ -0,0 +1,189 @@
+package domain
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// the UTM values of a key as reported
+type UTMValues struct {
+	UTMCampaign string `json:"utm_campaign"`
+	UTMSource   string `json:"utm_source"`
+	UTMMedium   string `json:"utm_medium"`
+}
+
+func utmValuesOf(utm UTMKey) UTMValues {
+	return UTMValues{UTMCampaign: utm.Campaign, UTMSource: utm.Source, UTMMedium: utm.Medium}
+}
+
+// an opportunity UTM key counted under a near identical ad key
+type FuzzyUTMMatch struct {
+	CRM        UTMValues `json:"crm"`
+	Ads        UTMValues `json:"ads"`
+	Similarity float64   `json:"similarity"`
+	Records    int       `json:"records"` // opportunities of the CRM key
+}
+
+// matches opportunities whose UTM key no ad has to the most similar ad key
+// with a similarity of at least Threshold. A zero threshold only matches
+// exactly.
+type UTMMatcher struct {
+	Threshold float64
+}
+
+func (m UTMMatcher) Validate() error {
+	if m.Threshold < 0 || m.Threshold > 1 {
+		return fmt.Errorf("fuzzy UTM match threshold must be between 0 and 1, got %g", m.Threshold)
+	}
+	return nil
+}
+
+// returns the opportunities with the UTM keys of fuzzy matched ones
+// replaced by their ad key, and the matches applied
+func (m UTMMatcher) MatchOpportunities(ads []ProcessedAdData, opportunities []ProcessedOpportunity) ([]ProcessedOpportunity, []FuzzyUTMMatch) {
+	if m.Threshold == 0 || len(ads) == 0 {
+		return opportunities, nil
+	}
+
+	adKeys := make(map[UTMKey]bool)
+	for _, ad := range ads {
+		adKeys[UTMKey{Campaign: ad.UTMCampaign, Source: ad.UTMSource, Medium: ad.UTMMedium}] = true
+	}
+	candidates := make([]UTMKey, 0, len(adKeys))
+	for utm := range adKeys {
+		candidates = append(candidates, utm)
+	}
+	// A stable order picks the same key on equal similarities
+	slices.SortFunc(candidates, compareUTMKeys)
+
+	matches := make(map[UTMKey]*FuzzyUTMMatch)
+	unmatched := make(map[UTMKey]bool)
+	matched := slices.Clone(opportunities)
+	for i, opp := range matched {
+		utm := UTMKey{Campaign: opp.UTMCampaign, Source: opp.UTMSource, Medium: opp.UTMMedium}
+		if adKeys[utm] || unmatched[utm] {
+			continue
+		}
+		match, ok := matches[utm]
+		if !ok {
+			best, similarity := bestUTMMatch(utm, candidates)
+			if similarity < m.Threshold {
+				unmatched[utm] = true
+				continue
+			}
+			match = &FuzzyUTMMatch{CRM: utmValuesOf(utm), Ads: utmValuesOf(best), Similarity: similarity}
+			matches[utm] = match
+		}
+		match.Records++
+		matched[i].UTMCampaign = match.Ads.UTMCampaign
+		matched[i].UTMSource = match.Ads.UTMSource
+		matched[i].UTMMedium = match.Ads.UTMMedium
+	}
+
+	applied := make([]FuzzyUTMMatch, 0, len(matches))
+	for _, match := range matches {
+		applied = append(applied, *match)
+	}
+	slices.SortFunc(applied, func(a, b FuzzyUTMMatch) int {
+		return cmp.Or(cmp.Compare(b.Records, a.Records), cmp.Compare(a.CRM.UTMCampaign, b.CRM.UTMCampaign),
+			cmp.Compare(a.CRM.UTMSource, b.CRM.UTMSource), cmp.Compare(a.CRM.UTMMedium, b.CRM.UTMMedium))
+	})
+	return matched, applied
+}
+
+// returns the most similar of the candidates and its similarity
+func bestUTMMatch(utm UTMKey, candidates []UTMKey) (UTMKey, float64) {
+	var best UTMKey
+	bestSimilarity := -1.0
+	for _, candidate := range candidates {
+		if similarity := UTMSimilarity(utm, candidate); similarity > bestSimilarity {
+			best, bestSimilarity = candidate, similarity
+		}
+	}
+	return best, bestSimilarity
+}
+
+func compareUTMKeys(a, b UTMKey) int {
+	return cmp.Or(cmp.Compare(a.Campaign, b.Campaign), cmp.Compare(a.Source, b.Source), cmp.Compare(a.Medium, b.Medium))
+}
+
+// returns how alike two UTM keys are from 0 to 1: the similarity of their
+// least alike value
+func UTMSimilarity(a, b UTMKey) float64 {
+	return min(
+		utmValueSimilarity(a.Campaign, b.Campaign),
+		utmValueSimilarity(a.Source, b.Source),
+		utmValueSimilarity(a.Medium, b.Medium),
+	)
+}
+
+// returns how alike two UTM values are after normalizing their case and
+// separators: the better of their normalized edit distance and their token
+// overlap
+func utmValueSimilarity(a, b string) float64 {
+	a, b = normalizeUTMValue(a), normalizeUTMValue(b)
+	if a == b {
+		return 1
+	}
+	if a == "" || b == "" {
+		return 0
+	}
+
+	aRunes, bRunes := []rune(a), []rune(b)
+	similarity := 1 - float64(editDistance(aRunes, bRunes))/float64(max(len(aRunes), len(bRunes)))
+	return max(similarity, tokenOverlap(strings.Split(a, "_"), strings.Split(b, "_")))
+}
+
+// lowercases the value and joins its words with single underscores
+func normalizeUTMValue(value string) string {
+	words := strings.FieldsFunc(strings.ToLower(value), func(r rune) bool {
+		return strings.ContainsRune(" _-.+/|", r)
+	})
+	return strings.Join(words, "_")
+}
+
+// returns the Levenshtein distance of a and b
+func editDistance(a, b []rune) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			substitution := previous[j-1]
+			if a[i-1] != b[j-1] {
+				substitution++
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, substitution)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+// returns the Jaccard index of the token sets
+func tokenOverlap(a, b []string) float64 {
+	set := make(map[string]bool, len(a))
+	for _, token := range a {
+		set[token] = true
+	}
+	union := len(set)
+	shared := 0
+	seen := make(map[string]bool, len(b))
+	for _, token := range b {
+		if seen[token] {
+			continue
+		}
+		seen[token] = true
+		if set[token] {
+			shared++
+		} else {
+			union++
+		}
+	}
+	return float64(shared) / float64(union)
+}
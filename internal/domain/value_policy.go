@@ -0,0 +1,82 @@
+package domain
+
+import "fmt"
+
+// what to do with a negative or outlier value
+type ValueAction string
+
+const (
+	ValueAllow      ValueAction = "allow"      // keep the value as is
+	ValueClamp      ValueAction = "clamp"      // negatives become zero, outliers the threshold
+	ValueQuarantine ValueAction = "quarantine" // drop the row into the quarantine store
+	ValueFlag       ValueAction = "flag"       // keep the value and tag the row
+)
+
+// numeric fields value policies can be configured for, as "<source>.<field>"
+var ValuePolicyFields = []string{"ads.cost", "ads.clicks", "ads.impressions", "crm.amount"}
+
+// handling of negative and outlier values for one field. A value is an
+// outlier when it exceeds Max, or when it lies more than MaxDeviation
+// robust standard deviations (scaled median absolute deviation) above the
+// run's median. Zero disables either check.
+type ValuePolicy struct {
+	Negative     ValueAction `json:"negative,omitempty"`
+	Outlier      ValueAction `json:"outlier,omitempty"`
+	Max          float64     `json:"max,omitempty"`
+	MaxDeviation float64     `json:"max_deviation,omitempty"`
+}
+
+// value policies keyed by "<source>.<field>"
+type ValuePolicies map[string]ValuePolicy
+
+// Validate checks the policies and fills in defaults
+func (p ValuePolicies) Validate() error {
+	for field, policy := range p {
+		known := false
+		for _, name := range ValuePolicyFields {
+			known = known || name == field
+		}
+		if !known {
+			return fmt.Errorf("unsupported value policy field %q", field)
+		}
+
+		if policy.Negative == "" {
+			policy.Negative = ValueAllow
+		}
+		if policy.Outlier == "" {
+			policy.Outlier = ValueAllow
+		}
+		if !policy.Negative.isValid() {
+			return fmt.Errorf("%s: unsupported negative action %q", field, policy.Negative)
+		}
+		if !policy.Outlier.isValid() {
+			return fmt.Errorf("%s: unsupported outlier action %q", field, policy.Outlier)
+		}
+		if policy.Max < 0 || policy.MaxDeviation < 0 {
+			return fmt.Errorf("%s: outlier thresholds must not be negative", field)
+		}
+		if policy.Outlier != ValueAllow && policy.Max == 0 && policy.MaxDeviation == 0 {
+			return fmt.Errorf("%s: outlier action requires max or max_deviation", field)
+		}
+		p[field] = policy
+	}
+	return nil
+}
+
+func (a ValueAction) isValid() bool {
+	switch a {
+	case ValueAllow, ValueClamp, ValueQuarantine, ValueFlag:
+		return true
+	}
+	return false
+}
+
+// per-run counts of records affected by a field's value policy
+type ValueReport struct {
+	Negative    int     `json:"negative"`
+	Outliers    int     `json:"outliers"`
+	Threshold   float64 `json:"threshold,omitempty"` // effective outlier threshold of the run
+	Clamped     int     `json:"clamped"`
+	Quarantined int     `json:"quarantined"`
+	Flagged     int     `json:"flagged"`
+}
@@ -0,0 +1,70 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// ErrBackgroundJobNotFound is returned for jobs the watchdog doesn't supervise
+var ErrBackgroundJobNotFound = NewError(ErrNotFound, "background job not found")
+
+// background jobs supervised by the watchdog
+const (
+	BackgroundJobScheduler       = "scheduler"
+	BackgroundJobEventCompaction = "event_compaction"
+	BackgroundJobFlagReload      = "flag_reload"
+	BackgroundJobExportAcks      = "export_acks"
+	BackgroundJobStreamConsumer  = "stream_consumer"
+	// prefix of the watched scheduled runs, followed by the pipeline
+	BackgroundJobScheduledRun = "scheduled_run:"
+)
+
+// Heartbeat is how a long-running job tells the watchdog it is alive. Jobs
+// beat from their main loop and wait between iterations with Sleep, which
+// keeps beating, so only a job stuck inside an iteration stops beating.
+type Heartbeat interface {
+	Beat()
+	// waits for d, beating meanwhile, and reports false if ctx was
+	// cancelled first
+	Sleep(ctx context.Context, d time.Duration) bool
+}
+
+type heartbeatKey struct{}
+
+// returns a context carrying the heartbeat of the job it runs
+func WithHeartbeat(ctx context.Context, heartbeat Heartbeat) context.Context {
+	return context.WithValue(ctx, heartbeatKey{}, heartbeat)
+}
+
+// beats the heartbeat carried by the context, if any, so the stages of a
+// watched run can show it progressing
+func Beat(ctx context.Context) {
+	if heartbeat, ok := ctx.Value(heartbeatKey{}).(Heartbeat); ok {
+		heartbeat.Beat()
+	}
+}
+
+// represents the liveness of a supervised background job
+type BackgroundJobStatus struct {
+	Name          string    `json:"name"`
+	Running       bool      `json:"running"`
+	StartedAt     time.Time `json:"started_at"`
+	LastHeartbeat time.Time `json:"last_heartbeat"`
+	Restarts      int       `json:"restarts"`
+}
+
+// represents a background job that stopped beating and was restarted.
+// Abandoned is set when the wedged instance didn't return after being
+// cancelled; it is left running and a new instance is started regardless.
+// Stale is set for watched jobs, such as scheduled runs, which are only
+// alerted on and neither cancelled nor restarted.
+type JobIncident struct {
+	ID            string    `json:"id"`
+	Job           string    `json:"job"`
+	StartedAt     time.Time `json:"started_at"`
+	LastHeartbeat time.Time `json:"last_heartbeat"`
+	DetectedAt    time.Time `json:"detected_at"`
+	Deadline      string    `json:"deadline"`
+	Abandoned     bool      `json:"abandoned"`
+	Stale         bool      `json:"stale,omitempty"`
+}
@@ -0,0 +1,55 @@
+package domain
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+)
+
+var (
+	// ErrWebhookNotConfigured is returned for webhooks of a source without
+	// a signing secret
+	ErrWebhookNotConfigured = errors.New("webhook not configured")
+	// ErrInvalidWebhookSignature is returned for webhooks whose signature
+	// doesn't match their body
+	ErrInvalidWebhookSignature = errors.New("invalid webhook signature")
+	// ErrStaleWebhook is returned for webhooks whose signed timestamp is too
+	// far from now, e.g. replays of captured deliveries
+	ErrStaleWebhook = errors.New("stale webhook")
+)
+
+// decodes the body of a source's webhook, one record or an array of them
+// in the shape its upstream API returns, into a push batch
+func DecodeWebhookBatch(source string, payload []byte) (PushBatch, error) {
+	var batch PushBatch
+	var err error
+	switch source {
+	case SourceAds:
+		batch.Ads, err = decodeWebhookRecords[AdPerformance](payload)
+	case SourceCRM:
+		batch.Opportunities, err = decodeWebhookRecords[Opportunity](payload)
+	default:
+		return batch, Errorf(ErrValidation, "unsupported webhook source %q", source)
+	}
+	if err != nil {
+		return batch, Errorf(ErrValidation, "invalid %s webhook body: %w", source, err)
+	}
+	if batch.Len() == 0 {
+		return batch, Errorf(ErrValidation, "the %s webhook body holds no records", source)
+	}
+	return batch, nil
+}
+
+func decodeWebhookRecords[T any](payload []byte) ([]T, error) {
+	payload = bytes.TrimSpace(payload)
+	if len(payload) > 0 && payload[0] == '[' {
+		var records []T
+		err := json.Unmarshal(payload, &records)
+		return records, err
+	}
+	var record T
+	if err := json.Unmarshal(payload, &record); err != nil {
+		return nil, err
+	}
+	return []T{record}, nil
+}
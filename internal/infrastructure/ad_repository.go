@@ -9,7 +9,9 @@ import (
 )
 
 type AdRepository struct {
-	data   map[string][]domain.ProcessedAdData
+	data map[string][]domain.ProcessedAdData
+	// position of each stored ad in its date partition by natural key
+	index  map[string]map[string]int
 	mutex  sync.RWMutex
 	logger *logger.Logger
 }
@@ -17,23 +19,71 @@ type AdRepository struct {
 func NewAdRepository(logger *logger.Logger) *AdRepository {
 	return &AdRepository{
 		data:   make(map[string][]domain.ProcessedAdData),
+		index:  make(map[string]map[string]int),
 		logger: logger,
 	}
 }
 
+// replaces the stored ads with the same natural key, see
+// domain.ProcessedAdData.RecordKey, so storing the same rows again is a
+// no-op
 func (r *AdRepository) Store(ctx context.Context, ads []domain.ProcessedAdData) error {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
 	for _, ad := range ads {
-		dateKey := ad.Date.Format("2006-01-02")
-		r.data[dateKey] = append(r.data[dateKey], ad)
+		r.upsert(ad)
 	}
 
 	r.logger.WithContext(ctx).WithField("count", len(ads)).Info("Stored ads data in memory")
 	return nil
 }
 
+func (r *AdRepository) Replace(ctx context.Context, from, to time.Time, ads []domain.ProcessedAdData) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	fromKey, toKey := from.Format("2006-01-02"), to.Format("2006-01-02")
+	replaced := 0
+	for dateKey, stored := range r.data {
+		if dateKey >= fromKey && dateKey <= toKey {
+			replaced += len(stored)
+			delete(r.data, dateKey)
+			delete(r.index, dateKey)
+		}
+	}
+
+	for _, ad := range ads {
+		r.upsert(ad)
+	}
+
+	r.logger.WithContext(ctx).WithFields(map[string]any{
+		"from":     fromKey,
+		"to":       toKey,
+		"replaced": replaced,
+		"count":    len(ads),
+	}).Info("Replaced ads data in memory")
+	return nil
+}
+
+// replaces the stored ad with the same natural key, adding it when there
+// is none
+func (r *AdRepository) upsert(ad domain.ProcessedAdData) {
+	dateKey := ad.Date.Format("2006-01-02")
+	key := ad.RecordKey()
+	positions, ok := r.index[dateKey]
+	if !ok {
+		positions = make(map[string]int)
+		r.index[dateKey] = positions
+	}
+	if i, ok := positions[key]; ok {
+		r.data[dateKey][i] = ad
+		return
+	}
+	positions[key] = len(r.data[dateKey])
+	r.data[dateKey] = append(r.data[dateKey], ad)
+}
+
 func (r *AdRepository) GetByDateRange(ctx context.Context, from, to time.Time) ([]domain.ProcessedAdData, error) {
 	r.mutex.RLock()
 	defer r.mutex.RUnlock()
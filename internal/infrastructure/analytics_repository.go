@@ -0,0 +1,54 @@
+package infrastructure
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"etlgo/internal/domain"
+	"etlgo/pkg/logger"
+)
+
+// implements domain.AnalyticsRepository interface
+type AnalyticsRepository struct {
+	data   map[string][]domain.ProcessedAnalyticsRow
+	mutex  sync.RWMutex
+	logger *logger.Logger
+}
+
+// creates a new web analytics repository
+func NewAnalyticsRepository(logger *logger.Logger) *AnalyticsRepository {
+	return &AnalyticsRepository{
+		data:   make(map[string][]domain.ProcessedAnalyticsRow),
+		logger: logger,
+	}
+}
+
+func (r *AnalyticsRepository) Replace(ctx context.Context, from, to time.Time, rows []domain.ProcessedAnalyticsRow) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for date := from; !date.After(to); date = date.AddDate(0, 0, 1) {
+		delete(r.data, date.Format("2006-01-02"))
+	}
+	for _, row := range rows {
+		dateKey := row.Date.Format("2006-01-02")
+		r.data[dateKey] = append(r.data[dateKey], row)
+	}
+
+	r.logger.WithContext(ctx).WithField("count", len(rows)).Info("Stored analytics data in memory")
+	return nil
+}
+
+func (r *AnalyticsRepository) GetByDateRange(ctx context.Context, from, to time.Time) ([]domain.ProcessedAnalyticsRow, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	var result []domain.ProcessedAnalyticsRow
+	for date := from; !date.After(to); date = date.AddDate(0, 0, 1) {
+		if rows, exists := r.data[date.Format("2006-01-02")]; exists {
+			result = append(result, rows...)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,45 @@
+package infrastructure
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"etlgo/internal/domain"
+)
+
+// loads API keys with their metrics scopes from a JSON array. An empty path
+// configures no keys.
+func LoadAPIKeys(path string) ([]domain.APIKey, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read API key file: %w", err)
+	}
+	var keys []domain.APIKey
+	if err := json.Unmarshal(raw, &keys); err != nil {
+		return nil, fmt.Errorf("failed to parse API key file: %w", err)
+	}
+	return keys, nil
+}
+
+// loads the roles of API keys with the columns they hide from a JSON array.
+// An empty path configures no roles.
+func LoadRoles(path string) ([]domain.Role, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read roles file: %w", err)
+	}
+	var roles []domain.Role
+	if err := json.Unmarshal(raw, &roles); err != nil {
+		return nil, fmt.Errorf("failed to parse roles file: %w", err)
+	}
+	return roles, nil
+}
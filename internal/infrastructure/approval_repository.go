@@ -0,0 +1,110 @@
+package infrastructure
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"etlgo/internal/domain"
+	"etlgo/pkg/logger"
+)
+
+// implements domain.ApprovalRepository interface in memory
+type ApprovalRepository struct {
+	requests []domain.ApprovalRequest
+	mutex    sync.RWMutex
+	logger   *logger.Logger
+}
+
+// creates a new approval repository
+func NewApprovalRepository(logger *logger.Logger) *ApprovalRepository {
+	return &ApprovalRepository{logger: logger}
+}
+
+func (r *ApprovalRepository) Create(ctx context.Context, request domain.ApprovalRequest) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.requests = append(r.requests, request)
+
+	r.logger.WithContext(ctx).WithFields(map[string]any{
+		"approval_id": request.ID,
+		"action":      request.Action,
+	}).Info("Recorded approval request")
+	return nil
+}
+
+func (r *ApprovalRepository) Get(ctx context.Context, id string) (*domain.ApprovalRequest, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	if i := r.index(id); i >= 0 {
+		request := r.requests[i]
+		return &request, nil
+	}
+	return nil, domain.Errorf(domain.ErrNotFound, "approval request %s not found", id)
+}
+
+func (r *ApprovalRepository) List(ctx context.Context, filter domain.ApprovalFilter) ([]domain.ApprovalRequest, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	result := make([]domain.ApprovalRequest, 0)
+	for i := len(r.requests) - 1; i >= 0; i-- {
+		if filter.Limit > 0 && len(result) >= filter.Limit {
+			break
+		}
+		request := r.requests[i]
+		if filter.Status != "" && request.Status != filter.Status {
+			continue
+		}
+		if filter.Action != "" && request.Action != filter.Action {
+			continue
+		}
+		result = append(result, request)
+	}
+	return result, nil
+}
+
+func (r *ApprovalRepository) Decide(ctx context.Context, id, status, actor, reason string, at time.Time) (*domain.ApprovalRequest, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	i := r.index(id)
+	if i < 0 {
+		return nil, domain.Errorf(domain.ErrNotFound, "approval request %s not found", id)
+	}
+	request := &r.requests[i]
+	if request.Status != domain.ApprovalPending {
+		return nil, domain.Errorf(domain.ErrConflict, "approval request %s is already %s", id, request.Status)
+	}
+	request.Status = status
+	request.DecidedBy = actor
+	request.DecidedAt = &at
+	request.Reason = reason
+
+	decided := *request
+	return &decided, nil
+}
+
+func (r *ApprovalRepository) Update(ctx context.Context, request domain.ApprovalRequest) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	i := r.index(request.ID)
+	if i < 0 {
+		return domain.Errorf(domain.ErrNotFound, "approval request %s not found", request.ID)
+	}
+	r.requests[i] = request
+	return nil
+}
+
+// returns the position of the request, or -1
+func (r *ApprovalRepository) index(id string) int {
+	for i := range r.requests {
+		if r.requests[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
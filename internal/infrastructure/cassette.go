@@ -0,0 +1,222 @@
+package infrastructure
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"etlgo/pkg/logger"
+)
+
+// cassette modes
+const (
+	CassetteOff    = "off"
+	CassetteRecord = "record"
+	CassetteReplay = "replay"
+)
+
+// response headers that are not worth keeping in a cassette
+var skippedCassetteHeaders = []string{"Date", "Set-Cookie", "Content-Length", "Connection"}
+
+var cassetteNameUnsafe = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// returns true if the cassette replays responses instead of sending
+// requests; nil cassettes send them
+func (c *Cassette) Replaying() bool {
+	return c != nil && c.mode == CassetteReplay
+}
+
+// records upstream responses to cassette files and replays them, so the
+// full pipeline can run reproducibly without the live APIs. Each request is
+// kept in its own file keyed by method and URL; recording it again replaces
+// the file.
+type Cassette struct {
+	mode   string
+	dir    string
+	logger *logger.Logger
+}
+
+// one recorded request and its response
+type cassetteInteraction struct {
+	Request struct {
+		Method string `json:"method"`
+		URL    string `json:"url"`
+	} `json:"request"`
+	Response struct {
+		Status       int               `json:"status"`
+		Headers      map[string]string `json:"headers,omitempty"`
+		Body         string            `json:"body"`
+		BodyEncoding string            `json:"body_encoding,omitempty"` // base64 for non UTF-8 bodies
+	} `json:"response"`
+	RecordedAt time.Time `json:"recorded_at"`
+}
+
+// creates a cassette in dir for the mode, or returns nil when the mode is
+// off so upstream calls go to the network as usual
+func NewCassette(mode, dir string, logger *logger.Logger) (*Cassette, error) {
+	switch mode {
+	case "", CassetteOff:
+		return nil, nil
+	case CassetteRecord:
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create cassette directory: %w", err)
+		}
+	case CassetteReplay:
+		if _, err := os.Stat(dir); err != nil {
+			return nil, fmt.Errorf("cassette directory not readable: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported cassette mode %q: expected %s, %s or %s", mode, CassetteOff, CassetteRecord, CassetteReplay)
+	}
+
+	logger.WithFields(map[string]any{"mode": mode, "dir": dir}).Warn("Upstream cassette enabled")
+	return &Cassette{mode: mode, dir: dir, logger: logger}, nil
+}
+
+// returns a transport recording the responses of next, or replaying them
+// without calling next at all
+func (c *Cassette) Wrap(next http.RoundTripper) http.RoundTripper {
+	return &cassetteTransport{cassette: c, next: next}
+}
+
+type cassetteTransport struct {
+	cassette *Cassette
+	next     http.RoundTripper
+}
+
+func (t *cassetteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.cassette.mode == CassetteReplay {
+		return t.cassette.replay(req)
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	return t.cassette.record(req, resp)
+}
+
+// saves the response and returns a copy of it with the body still readable
+func (c *Cassette) record(req *http.Request, resp *http.Response) (*http.Response, error) {
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response for cassette: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	var interaction cassetteInteraction
+	interaction.Request.Method = req.Method
+	interaction.Request.URL = req.URL.String()
+	interaction.Response.Status = resp.StatusCode
+	interaction.Response.Headers = make(map[string]string)
+	for name := range resp.Header {
+		if !containsFold(skippedCassetteHeaders, name) {
+			interaction.Response.Headers[name] = resp.Header.Get(name)
+		}
+	}
+	if utf8.Valid(body) {
+		interaction.Response.Body = string(body)
+	} else {
+		interaction.Response.Body = base64.StdEncoding.EncodeToString(body)
+		interaction.Response.BodyEncoding = "base64"
+	}
+	interaction.RecordedAt = time.Now().UTC()
+
+	raw, err := json.MarshalIndent(interaction, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode cassette: %w", err)
+	}
+
+	// Write then rename so a replay never reads a partial cassette
+	path := c.path(req)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0o644); err != nil {
+		return nil, fmt.Errorf("failed to write cassette: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return nil, fmt.Errorf("failed to write cassette: %w", err)
+	}
+
+	c.logger.WithFields(map[string]any{
+		"url":      interaction.Request.URL,
+		"status":   resp.StatusCode,
+		"cassette": filepath.Base(path),
+	}).Info("Recorded upstream response")
+	return resp, nil
+}
+
+// serves the recorded response for the request
+func (c *Cassette) replay(req *http.Request) (*http.Response, error) {
+	path := c.path(req)
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("no cassette recorded for %s %s: %w", req.Method, req.URL, err)
+	}
+
+	var interaction cassetteInteraction
+	if err := json.Unmarshal(raw, &interaction); err != nil {
+		return nil, fmt.Errorf("invalid cassette %s: %w", filepath.Base(path), err)
+	}
+
+	body := []byte(interaction.Response.Body)
+	if interaction.Response.BodyEncoding == "base64" {
+		if body, err = base64.StdEncoding.DecodeString(interaction.Response.Body); err != nil {
+			return nil, fmt.Errorf("invalid cassette %s: %w", filepath.Base(path), err)
+		}
+	}
+
+	header := make(http.Header, len(interaction.Response.Headers))
+	for name, value := range interaction.Response.Headers {
+		header.Set(name, value)
+	}
+
+	c.logger.WithFields(map[string]any{
+		"url":      req.URL.String(),
+		"cassette": filepath.Base(path),
+	}).Debug("Replayed upstream response")
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", interaction.Response.Status, http.StatusText(interaction.Response.Status)),
+		StatusCode:    interaction.Response.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// returns the cassette file of the request, named after its host and path
+// with a hash of the method and full URL to keep names unique
+func (c *Cassette) path(req *http.Request) string {
+	sum := sha256.Sum256([]byte(req.Method + " " + req.URL.String()))
+	name := cassetteNameUnsafe.ReplaceAllString(req.URL.Host+req.URL.Path, "_")
+	name = strings.Trim(name, "_")
+	if len(name) > 80 {
+		name = name[:80]
+	}
+	return filepath.Join(c.dir, fmt.Sprintf("%s_%s_%s.json", strings.ToLower(req.Method), name, hex.EncodeToString(sum[:6])))
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
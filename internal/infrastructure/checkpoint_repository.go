@@ -0,0 +1,130 @@
+package infrastructure
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"etlgo/internal/domain"
+	"etlgo/pkg/logger"
+)
+
+// implements domain.CheckpointRepository. Checkpoints are kept in memory
+// and, when a path is set, written to a JSON file after every save so runs
+// stay incremental across restarts.
+type CheckpointRepository struct {
+	path        string
+	checkpoints map[string]domain.Checkpoint // by tenant and source
+	mutex       sync.RWMutex
+	logger      *logger.Logger
+}
+
+// creates a checkpoint store, loading the file at path when it exists. An
+// empty path keeps the checkpoints in memory only.
+func NewCheckpointRepository(path string, logger *logger.Logger) (*CheckpointRepository, error) {
+	r := &CheckpointRepository{
+		path:        path,
+		checkpoints: make(map[string]domain.Checkpoint),
+		logger:      logger,
+	}
+	if path == "" {
+		return r, nil
+	}
+
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoints: %w", err)
+	}
+	var checkpoints []domain.Checkpoint
+	if err := json.Unmarshal(raw, &checkpoints); err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoints: %w", err)
+	}
+	for _, checkpoint := range checkpoints {
+		r.checkpoints[checkpointKey(checkpoint.Tenant, checkpoint.Source)] = checkpoint
+	}
+	return r, nil
+}
+
+func checkpointKey(tenant, source string) string {
+	return tenant + "/" + source
+}
+
+func (r *CheckpointRepository) Save(ctx context.Context, checkpoint domain.Checkpoint) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.checkpoints[checkpointKey(checkpoint.Tenant, checkpoint.Source)] = checkpoint
+	return r.save()
+}
+
+func (r *CheckpointRepository) Get(ctx context.Context, tenant, source string) (*domain.Checkpoint, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	checkpoint, ok := r.checkpoints[checkpointKey(tenant, source)]
+	if !ok {
+		return nil, nil
+	}
+	return &checkpoint, nil
+}
+
+// returns the tenant's checkpoints by source
+func (r *CheckpointRepository) List(ctx context.Context, tenant string) ([]domain.Checkpoint, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	result := make([]domain.Checkpoint, 0)
+	for _, checkpoint := range r.checkpoints {
+		if checkpoint.Tenant == tenant {
+			result = append(result, checkpoint)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Source < result[j].Source
+	})
+	return result, nil
+}
+
+// writes the checkpoints to a temporary file and renames it over the old one
+func (r *CheckpointRepository) save() error {
+	if r.path == "" {
+		return nil
+	}
+
+	checkpoints := make([]domain.Checkpoint, 0, len(r.checkpoints))
+	for _, checkpoint := range r.checkpoints {
+		checkpoints = append(checkpoints, checkpoint)
+	}
+	sort.Slice(checkpoints, func(i, j int) bool {
+		return checkpointKey(checkpoints[i].Tenant, checkpoints[i].Source) < checkpointKey(checkpoints[j].Tenant, checkpoints[j].Source)
+	})
+	raw, err := json.Marshal(checkpoints)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(r.path), filepath.Base(r.path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write checkpoints: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(raw); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write checkpoints: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write checkpoints: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), r.path); err != nil {
+		return fmt.Errorf("failed to write checkpoints: %w", err)
+	}
+	return nil
+}
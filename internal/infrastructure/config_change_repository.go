@@ -0,0 +1,50 @@
+package infrastructure
+
+import (
+	"context"
+	"sync"
+
+	"etlgo/internal/domain"
+	"etlgo/pkg/logger"
+)
+
+// configuration changes kept before the oldest are dropped
+const maxConfigChanges = 1000
+
+// implements domain.ConfigChangeRepository interface in memory, keeping the
+// most recent changes
+type ConfigChangeRepository struct {
+	changes []domain.ConfigChange
+	mutex   sync.RWMutex
+	logger  *logger.Logger
+}
+
+// creates a new configuration change repository
+func NewConfigChangeRepository(logger *logger.Logger) *ConfigChangeRepository {
+	return &ConfigChangeRepository{logger: logger}
+}
+
+func (r *ConfigChangeRepository) Record(ctx context.Context, change domain.ConfigChange) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.changes = append(r.changes, change)
+	if overflow := len(r.changes) - maxConfigChanges; overflow > 0 {
+		r.changes = append([]domain.ConfigChange(nil), r.changes[overflow:]...)
+	}
+	return nil
+}
+
+func (r *ConfigChangeRepository) List(ctx context.Context, limit int) ([]domain.ConfigChange, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	result := make([]domain.ConfigChange, 0)
+	for i := len(r.changes) - 1; i >= 0; i-- {
+		if limit > 0 && len(result) >= limit {
+			break
+		}
+		result = append(result, r.changes[i])
+	}
+	return result, nil
+}
@@ -0,0 +1,229 @@
+package infrastructure
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"etlgo/internal/domain"
+)
+
+// loads the connectors declared in a JSON array. Auth values may name
+// environment variables as $NAME or ${NAME}, so credentials stay out of the
+// file. An empty path declares no connectors.
+func LoadConnectors(path string) ([]domain.Connector, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read connectors file: %w", err)
+	}
+	var connectors []domain.Connector
+	if err := json.Unmarshal(raw, &connectors); err != nil {
+		return nil, fmt.Errorf("failed to parse connectors file: %w", err)
+	}
+
+	names := make(map[string]bool, len(connectors))
+	for i := range connectors {
+		connector := &connectors[i]
+		if err := connector.Validate(); err != nil {
+			return nil, err
+		}
+		if names[connector.Name] {
+			return nil, fmt.Errorf("duplicate connector %q", connector.Name)
+		}
+		names[connector.Name] = true
+
+		if auth := connector.Auth; auth != nil {
+			auth.Token = os.ExpandEnv(auth.Token)
+			auth.Username = os.ExpandEnv(auth.Username)
+			auth.Password = os.ExpandEnv(auth.Password)
+			auth.Value = os.ExpandEnv(auth.Value)
+		}
+	}
+	return connectors, nil
+}
+
+// Connector returns a client fetching the connector's records from its URL
+// with the HTTP client's transport, rate limits and pagination. Fetches are
+// authenticated with the connector's auth and decoded in its format with
+// its mapping, or the field mapping of its type.
+func (c *HTTPClient) Connector(connector domain.Connector) (domain.ExternalAPIClient, error) {
+	mapper := c.mapper
+	if connector.Mapping != nil {
+		var err error
+		if mapper, err = c.mapper.withSource(connector.Type, *connector.Mapping); err != nil {
+			return nil, fmt.Errorf("%s: %w", connector.Name, err)
+		}
+	}
+
+	client := *c
+	client.mapper = mapper
+	client.headers = connector.Headers()
+	client.format = connector.Format
+	return &connectorClient{c: &client, connector: connector}, nil
+}
+
+// implements domain.ExternalAPIClient for a declared connector, serving
+// only the records of its type
+type connectorClient struct {
+	c         *HTTPClient
+	connector domain.Connector
+}
+
+func (k *connectorClient) FetchAdsData(ctx context.Context, since *time.Time) (*domain.AdData, error) {
+	page, err := k.c.fetchPages(ctx, domain.SourceAds, k.connector.Name, k.connector.URL, since, k.c.adsLimiter)
+	if err != nil {
+		return nil, err
+	}
+	return page.adData(), nil
+}
+
+func (k *connectorClient) FetchCRMData(ctx context.Context, since *time.Time) (*domain.CRMData, error) {
+	page, err := k.c.fetchPages(ctx, domain.SourceCRM, k.connector.Name, k.connector.URL, since, k.c.crmLimiter)
+	if err != nil {
+		return nil, err
+	}
+	return page.crmData(), nil
+}
+
+// opens connections to the connector's API
+func (k *connectorClient) Prewarm(ctx context.Context) {
+	if !k.c.prewarm {
+		return
+	}
+	prewarmUpstreams(ctx, k.c.client, map[string]string{k.connector.Name: k.connector.URL}, k.c.logger, k.c.metrics)
+}
+
+// implements domain.ExternalAPIClient over the registered connectors: the
+// built-in ads and CRM sources and the connectors declared in config. A
+// fetch of a source extracts every connector of its type in registration
+// order and returns their records together, so adding a source is a matter
+// of declaring it.
+type ConnectorRegistry struct {
+	connectors []registeredConnector
+}
+
+type registeredConnector struct {
+	info   domain.ConnectorInfo
+	client domain.ExternalAPIClient
+}
+
+// creates an empty registry
+func NewConnectorRegistry() *ConnectorRegistry {
+	return &ConnectorRegistry{}
+}
+
+// Register adds a connector extracting records of its type with client.
+// Names must be unique.
+func (r *ConnectorRegistry) Register(info domain.ConnectorInfo, client domain.ExternalAPIClient) error {
+	for _, registered := range r.connectors {
+		if registered.info.Name == info.Name {
+			return fmt.Errorf("duplicate connector %q", info.Name)
+		}
+	}
+	r.connectors = append(r.connectors, registeredConnector{info: info, client: client})
+	return nil
+}
+
+// Connectors returns the registered connectors in registration order
+func (r *ConnectorRegistry) Connectors() []domain.ConnectorInfo {
+	infos := make([]domain.ConnectorInfo, 0, len(r.connectors))
+	for _, registered := range r.connectors {
+		infos = append(infos, registered.info)
+	}
+	return infos
+}
+
+// fetches the ads of every ads connector. Errors of declared connectors
+// name them.
+func (r *ConnectorRegistry) FetchAdsData(ctx context.Context, since *time.Time) (*domain.AdData, error) {
+	var merged *domain.AdData
+	for _, registered := range r.ofType(domain.SourceAds) {
+		data, err := registered.client.FetchAdsData(ctx, since)
+		if err != nil {
+			return nil, registered.wrap(err)
+		}
+		if merged == nil {
+			merged = data
+			continue
+		}
+		merged.External.Ads.Performance = append(merged.External.Ads.Performance, data.External.Ads.Performance...)
+		merged.Rejected = append(merged.Rejected, data.Rejected...)
+		merged.Objects = append(merged.Objects, data.Objects...)
+	}
+	if merged == nil {
+		merged = &domain.AdData{}
+	}
+	return merged, nil
+}
+
+// fetches the opportunities of every CRM connector. Errors of declared
+// connectors name them.
+func (r *ConnectorRegistry) FetchCRMData(ctx context.Context, since *time.Time) (*domain.CRMData, error) {
+	var merged *domain.CRMData
+	for _, registered := range r.ofType(domain.SourceCRM) {
+		data, err := registered.client.FetchCRMData(ctx, since)
+		if err != nil {
+			return nil, registered.wrap(err)
+		}
+		if merged == nil {
+			merged = data
+			continue
+		}
+		merged.External.CRM.Opportunities = append(merged.External.CRM.Opportunities, data.External.CRM.Opportunities...)
+		merged.Rejected = append(merged.Rejected, data.Rejected...)
+		merged.Objects = append(merged.Objects, data.Objects...)
+	}
+	if merged == nil {
+		merged = &domain.CRMData{}
+	}
+	return merged, nil
+}
+
+// prewarms every connector concurrently, once per client
+func (r *ConnectorRegistry) Prewarm(ctx context.Context) {
+	var wg sync.WaitGroup
+	seen := make(map[domain.ExternalAPIClient]bool, len(r.connectors))
+	for _, registered := range r.connectors {
+		if seen[registered.client] {
+			continue
+		}
+		seen[registered.client] = true
+		wg.Go(func() { registered.client.Prewarm(ctx) })
+	}
+	wg.Wait()
+}
+
+// reports whether a connector of the source tracks its extraction itself,
+// in which case the source is extracted in full
+func (r *ConnectorRegistry) TracksExtraction(source string) bool {
+	for _, registered := range r.ofType(source) {
+		if domain.TracksExtraction(registered.client, source) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *ConnectorRegistry) ofType(source string) []registeredConnector {
+	var connectors []registeredConnector
+	for _, registered := range r.connectors {
+		if registered.info.Type == source {
+			connectors = append(connectors, registered)
+		}
+	}
+	return connectors
+}
+
+func (c registeredConnector) wrap(err error) error {
+	if c.info.BuiltIn {
+		return err
+	}
+	return fmt.Errorf("connector %s: %w", c.info.Name, err)
+}
@@ -12,6 +12,7 @@ import (
 // implements domain.CRMRepository interface
 type CRMRepository struct {
 	data   map[string][]domain.ProcessedOpportunity
+	dates  map[string]string // date key of each opportunity ID
 	mutex  sync.RWMutex
 	logger *logger.Logger
 }
@@ -20,6 +21,7 @@ type CRMRepository struct {
 func NewCRMRepository(logger *logger.Logger) *CRMRepository {
 	return &CRMRepository{
 		data:   make(map[string][]domain.ProcessedOpportunity),
+		dates:  make(map[string]string),
 		logger: logger,
 	}
 }
@@ -30,6 +32,12 @@ func (r *CRMRepository) Store(ctx context.Context, opportunities []domain.Proces
 
 	for _, opp := range opportunities {
 		dateKey := opp.CreatedAt.Format("2006-01-02")
+		if opp.OpportunityID != "" {
+			if previous, ok := r.dates[opp.OpportunityID]; ok {
+				r.remove(previous, opp.OpportunityID)
+			}
+			r.dates[opp.OpportunityID] = dateKey
+		}
 		r.data[dateKey] = append(r.data[dateKey], opp)
 	}
 
@@ -37,6 +45,54 @@ func (r *CRMRepository) Store(ctx context.Context, opportunities []domain.Proces
 	return nil
 }
 
+func (r *CRMRepository) Delete(ctx context.Context, ids []string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, id := range ids {
+		if dateKey, ok := r.dates[id]; ok {
+			r.remove(dateKey, id)
+			delete(r.dates, id)
+		}
+	}
+
+	r.logger.WithContext(ctx).WithField("count", len(ids)).Info("Deleted CRM data from memory")
+	return nil
+}
+
+// drops the opportunity with the ID from a date partition
+func (r *CRMRepository) remove(dateKey, id string) {
+	stored := r.data[dateKey]
+	for i := range stored {
+		if stored[i].OpportunityID == id {
+			r.data[dateKey] = append(stored[:i:i], stored[i+1:]...)
+			return
+		}
+	}
+}
+
+// returns the stored opportunities with the given IDs; unknown IDs are
+// skipped
+func (r *CRMRepository) GetByIDs(ctx context.Context, ids []string) ([]domain.ProcessedOpportunity, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	var result []domain.ProcessedOpportunity
+	for _, id := range ids {
+		dateKey, ok := r.dates[id]
+		if !ok {
+			continue
+		}
+		for _, opp := range r.data[dateKey] {
+			if opp.OpportunityID == id {
+				result = append(result, opp)
+				break
+			}
+		}
+	}
+	return result, nil
+}
+
 func (r *CRMRepository) GetByDateRange(ctx context.Context, from, to time.Time) ([]domain.ProcessedOpportunity, error) {
 	r.mutex.RLock()
 	defer r.mutex.RUnlock()
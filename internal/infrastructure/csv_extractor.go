@@ -0,0 +1,92 @@
+package infrastructure
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"etlgo/internal/domain"
+	"etlgo/pkg/metrics"
+)
+
+// implements domain.ExternalAPIClient over the CSV files the sources
+// export, and domain.FileDecoder for uploaded ones. Rows are decoded with
+// the field mappings, each field read from the column its path names.
+// Files hold every record, so a since is ignored.
+type CSVExtractor struct {
+	adsPath string
+	crmPath string
+	mapper  *FieldMapper
+	metrics *metrics.Metrics
+}
+
+// creates an extractor reading the ads and CRM files at the paths
+func NewCSVExtractor(adsPath, crmPath string, mapper *FieldMapper, metrics *metrics.Metrics) *CSVExtractor {
+	return &CSVExtractor{
+		adsPath: adsPath,
+		crmPath: crmPath,
+		mapper:  mapper,
+		metrics: metrics,
+	}
+}
+
+// reads ads from the ads file
+func (e *CSVExtractor) FetchAdsData(ctx context.Context, since *time.Time) (*domain.AdData, error) {
+	page, err := e.readFile(domain.SourceAds, e.adsPath)
+	if err != nil {
+		return nil, err
+	}
+	return page.adData(), nil
+}
+
+// reads opportunities from the CRM file
+func (e *CSVExtractor) FetchCRMData(ctx context.Context, since *time.Time) (*domain.CRMData, error) {
+	page, err := e.readFile(domain.SourceCRM, e.crmPath)
+	if err != nil {
+		return nil, err
+	}
+	return page.crmData(), nil
+}
+
+// there are no connections to open
+func (e *CSVExtractor) Prewarm(ctx context.Context) {}
+
+func (e *CSVExtractor) DecodeAds(r io.Reader) (*domain.AdData, error) {
+	page, err := e.mapper.decodeCSV(domain.SourceAds, r, 0)
+	if err != nil {
+		return nil, domain.Errorf(domain.ErrValidation, "ads file: %w", err)
+	}
+	return page.adData(), nil
+}
+
+func (e *CSVExtractor) DecodeCRM(r io.Reader) (*domain.CRMData, error) {
+	page, err := e.mapper.decodeCSV(domain.SourceCRM, r, 0)
+	if err != nil {
+		return nil, domain.Errorf(domain.ErrValidation, "CRM file: %w", err)
+	}
+	return page.crmData(), nil
+}
+
+// decodes the source's file, counting the read like an API call
+func (e *CSVExtractor) readFile(source, path string) (mappedPage, error) {
+	start := time.Now()
+	api := source + "_csv"
+
+	file, err := os.Open(path)
+	if err != nil {
+		e.metrics.RecordExternalAPIFailure(api, "read_error")
+		return mappedPage{}, domain.Errorf(domain.ErrUpstreamUnavailable, "failed to open %s file: %w", source, err)
+	}
+	defer file.Close()
+
+	page, err := e.mapper.decodeCSV(source, file, 0)
+	if err != nil {
+		e.metrics.RecordExternalAPIFailure(api, "decode_error")
+		return mappedPage{}, fmt.Errorf("failed to decode %s file %s: %w", source, path, err)
+	}
+
+	e.metrics.RecordExternalAPICall(api, "success", time.Since(start))
+	return page, nil
+}
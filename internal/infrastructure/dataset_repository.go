@@ -0,0 +1,64 @@
+package infrastructure
+
+import (
+	"context"
+	"sync"
+
+	"etlgo/internal/domain"
+	"etlgo/pkg/logger"
+)
+
+// implements domain.DatasetRepository interface in memory. Promotions keep
+// the metrics they replaced, so none are dropped: demoting walks back
+// through all of them.
+type DatasetRepository struct {
+	promotions []domain.DatasetPromotion
+	mutex      sync.RWMutex
+	logger     *logger.Logger
+}
+
+// creates a new dataset repository
+func NewDatasetRepository(logger *logger.Logger) *DatasetRepository {
+	return &DatasetRepository{logger: logger}
+}
+
+func (r *DatasetRepository) Save(ctx context.Context, promotion domain.DatasetPromotion) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for i := range r.promotions {
+		if r.promotions[i].ID == promotion.ID {
+			r.promotions[i] = promotion
+			return nil
+		}
+	}
+	r.promotions = append(r.promotions, promotion)
+	return nil
+}
+
+func (r *DatasetRepository) Latest(ctx context.Context) (*domain.DatasetPromotion, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for i := len(r.promotions) - 1; i >= 0; i-- {
+		if r.promotions[i].Active() {
+			promotion := r.promotions[i]
+			return &promotion, nil
+		}
+	}
+	return nil, domain.ErrNoPromotedDataset
+}
+
+func (r *DatasetRepository) List(ctx context.Context, limit int) ([]domain.DatasetPromotion, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	result := make([]domain.DatasetPromotion, 0)
+	for i := len(r.promotions) - 1; i >= 0; i-- {
+		if limit > 0 && len(result) >= limit {
+			break
+		}
+		result = append(result, r.promotions[i])
+	}
+	return result, nil
+}
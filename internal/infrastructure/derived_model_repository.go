@@ -0,0 +1,44 @@
+package infrastructure
+
+import (
+	"context"
+	"sync"
+
+	"etlgo/internal/domain"
+	"etlgo/pkg/logger"
+)
+
+// implements domain.DerivedModelRepository interface, keeping the latest
+// result of every model in memory
+type DerivedModelRepository struct {
+	results map[string]domain.DerivedModelResult
+	mutex   sync.RWMutex
+	logger  *logger.Logger
+}
+
+// creates a new derived model repository
+func NewDerivedModelRepository(logger *logger.Logger) *DerivedModelRepository {
+	return &DerivedModelRepository{results: make(map[string]domain.DerivedModelResult), logger: logger}
+}
+
+func (r *DerivedModelRepository) Save(ctx context.Context, result domain.DerivedModelResult) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.results[result.Model] = result
+
+	r.logger.WithContext(ctx).WithFields(map[string]any{
+		"model": result.Model,
+		"rows":  len(result.Rows),
+	}).Debug("Stored derived model in memory")
+	return nil
+}
+
+func (r *DerivedModelRepository) Get(ctx context.Context, model string) (*domain.DerivedModelResult, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	result, ok := r.results[model]
+	if !ok {
+		return nil, nil
+	}
+	return &result, nil
+}
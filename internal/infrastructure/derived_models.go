@@ -0,0 +1,40 @@
+package infrastructure
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"etlgo/internal/domain"
+)
+
+// loads the derived models from a JSON array file. An empty path configures
+// none.
+func LoadDerivedModels(path string) ([]domain.DerivedModel, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read derived model file: %w", err)
+	}
+	var models []domain.DerivedModel
+	if err := json.Unmarshal(raw, &models); err != nil {
+		return nil, fmt.Errorf("failed to parse derived model file: %w", err)
+	}
+
+	names := make(map[string]bool, len(models))
+	for i := range models {
+		model := &models[i]
+		if err := model.Validate(); err != nil {
+			return nil, err
+		}
+		if names[model.Name] {
+			return nil, fmt.Errorf("duplicate derived model %q", model.Name)
+		}
+		names[model.Name] = true
+	}
+
+	return models, nil
+}
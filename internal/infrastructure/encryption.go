@@ -0,0 +1,67 @@
+package infrastructure
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+)
+
+// EncryptionAlgorithm identifies the client-side encryption scheme in object tags
+const EncryptionAlgorithm = "AES-256-GCM"
+
+// encrypts export payloads before they leave the service. The output is
+// the random 12 byte nonce followed by the GCM sealed ciphertext.
+type PayloadEncryptor struct {
+	aead  cipher.AEAD
+	keyID string
+}
+
+// creates an encryptor from a base64 encoded 32 byte key
+func NewPayloadEncryptor(encodedKey, keyID string) (*PayloadEncryptor, error) {
+	key, err := base64.StdEncoding.DecodeString(encodedKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encryption key encoding: %w", err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("encryption key must be 32 bytes, got %d", len(key))
+	}
+	if keyID == "" {
+		return nil, fmt.Errorf("encryption key ID is required")
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PayloadEncryptor{aead: aead, keyID: keyID}, nil
+}
+
+// returns the configured key identifier (e.g. a KMS key ARN or local key name)
+func (e *PayloadEncryptor) KeyID() string {
+	return e.keyID
+}
+
+// seals the payload, binding the key ID as additional authenticated data
+func (e *PayloadEncryptor) Encrypt(payload []byte) ([]byte, error) {
+	nonce := make([]byte, e.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return e.aead.Seal(nonce, nonce, payload, []byte(e.keyID)), nil
+}
+
+// opens a payload produced by Encrypt
+func (e *PayloadEncryptor) Decrypt(sealed []byte) ([]byte, error) {
+	size := e.aead.NonceSize()
+	if len(sealed) < size {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	return e.aead.Open(nil, sealed[:size], sealed[size:], []byte(e.keyID))
+}
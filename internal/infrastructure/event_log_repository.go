@@ -0,0 +1,166 @@
+package infrastructure
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"etlgo/internal/domain"
+	"etlgo/pkg/logger"
+)
+
+// implements domain.EventLogRepository interface in memory. Events are kept
+// in sequence order and only removed by compaction.
+type EventLogRepository struct {
+	events  []domain.IngestEvent
+	latest  map[string]domain.IngestEvent // latest event of each record key
+	seq     int64
+	horizon time.Time
+	mutex   sync.RWMutex
+	clock   domain.Clock
+	logger  *logger.Logger
+}
+
+// creates a new event log repository
+func NewEventLogRepository(clock domain.Clock, logger *logger.Logger) *EventLogRepository {
+	return &EventLogRepository{
+		latest: make(map[string]domain.IngestEvent),
+		clock:  clock,
+		logger: logger,
+	}
+}
+
+func (r *EventLogRepository) Append(ctx context.Context, events []domain.IngestEvent) ([]domain.IngestEvent, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	now := r.clock.Now().UTC()
+	appended := make([]domain.IngestEvent, 0, len(events))
+	for _, event := range events {
+		key := event.RecordKey()
+		previous, exists := r.latest[key]
+		if exists && previous.SameRecord(event) {
+			continue
+		}
+		if !exists && event.Deleted {
+			continue
+		}
+
+		r.seq++
+		event.Seq = r.seq
+		event.Version = previous.Version + 1
+		event.IngestedAt = now
+		r.events = append(r.events, event)
+		r.latest[key] = event
+		appended = append(appended, event)
+	}
+
+	r.logger.WithContext(ctx).WithFields(map[string]any{
+		"received": len(events),
+		"appended": len(appended),
+	}).Info("Appended ingest events")
+	return appended, nil
+}
+
+func (r *EventLogRepository) List(ctx context.Context, filter domain.EventFilter) ([]domain.IngestEvent, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	result := make([]domain.IngestEvent, 0)
+	for i := len(r.events) - 1; i >= 0; i-- {
+		if filter.Limit > 0 && len(result) >= filter.Limit {
+			break
+		}
+		event := r.events[i]
+		if filter.Source != "" && event.Source != filter.Source {
+			continue
+		}
+		if filter.Key != "" && event.Key != filter.Key {
+			continue
+		}
+		if filter.IngestRunID != "" && event.IngestRunID != filter.IngestRunID {
+			continue
+		}
+		if filter.IngestRunIDs != nil && !slices.Contains(filter.IngestRunIDs, event.IngestRunID) {
+			continue
+		}
+		result = append(result, event)
+	}
+	return result, nil
+}
+
+func (r *EventLogRepository) Latest(ctx context.Context, asOf time.Time) ([]domain.IngestEvent, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	if asOf.Before(r.horizon) {
+		return nil, domain.Errorf(domain.ErrValidation, "as of %s is before the compaction horizon %s",
+			asOf.Format(time.RFC3339), r.horizon.Format(time.RFC3339))
+	}
+
+	position := make(map[string]int)
+	var result []domain.IngestEvent
+	for _, event := range r.events {
+		if event.IngestedAt.After(asOf) {
+			break
+		}
+		key := event.RecordKey()
+		if i, ok := position[key]; ok {
+			result[i] = event
+			continue
+		}
+		position[key] = len(result)
+		result = append(result, event)
+	}
+
+	live := result[:0]
+	for _, event := range result {
+		if !event.Deleted {
+			live = append(live, event)
+		}
+	}
+	return live, nil
+}
+
+func (r *EventLogRepository) Compact(ctx context.Context, horizon time.Time) (domain.EventCompaction, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if horizon.Before(r.horizon) {
+		horizon = r.horizon
+	}
+
+	// The last version of each record at the horizon is kept unless it is
+	// a tombstone; everything after the horizon is kept
+	last := make(map[string]int64)
+	for _, event := range r.events {
+		if event.IngestedAt.After(horizon) {
+			break
+		}
+		last[event.RecordKey()] = event.Seq
+	}
+
+	kept := make([]domain.IngestEvent, 0, len(r.events))
+	for _, event := range r.events {
+		if !event.IngestedAt.After(horizon) && (last[event.RecordKey()] != event.Seq || event.Deleted) {
+			continue
+		}
+		kept = append(kept, event)
+	}
+
+	compaction := domain.EventCompaction{
+		Horizon:   horizon,
+		Removed:   len(r.events) - len(kept),
+		Remaining: len(kept),
+	}
+	r.events = kept
+	r.horizon = horizon
+
+	r.logger.WithContext(ctx).WithFields(map[string]any{
+		"horizon":   horizon,
+		"removed":   compaction.Removed,
+		"remaining": compaction.Remaining,
+	}).Info("Compacted ingest events")
+	return compaction, nil
+}
@@ -0,0 +1,112 @@
+package infrastructure
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"etlgo/internal/domain"
+	"etlgo/pkg/logger"
+)
+
+// export deliveries kept before the oldest acknowledged ones are dropped
+const maxExportDeliveries = 1000
+
+// implements domain.ExportDeliveryRepository interface in memory, keeping
+// the most recent deliveries and every one still awaiting its ack
+type ExportDeliveryRepository struct {
+	deliveries []domain.ExportDelivery // in delivery order
+	mutex      sync.RWMutex
+	logger     *logger.Logger
+}
+
+// creates a new export delivery repository
+func NewExportDeliveryRepository(logger *logger.Logger) *ExportDeliveryRepository {
+	return &ExportDeliveryRepository{logger: logger}
+}
+
+func (r *ExportDeliveryRepository) Record(ctx context.Context, delivery domain.ExportDelivery) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.deliveries = append(r.deliveries, delivery)
+	if overflow := len(r.deliveries) - maxExportDeliveries; overflow > 0 {
+		kept := make([]domain.ExportDelivery, 0, maxExportDeliveries)
+		for _, stored := range r.deliveries {
+			if overflow > 0 && stored.State != domain.ExportDelivered {
+				overflow--
+				continue
+			}
+			kept = append(kept, stored)
+		}
+		r.deliveries = kept
+	}
+
+	r.logger.WithContext(ctx).WithFields(map[string]any{
+		"delivery_id": delivery.ID,
+		"export_id":   delivery.ExportID,
+	}).Debug("Recorded export delivery")
+	return nil
+}
+
+func (r *ExportDeliveryRepository) Get(ctx context.Context, id string) (*domain.ExportDelivery, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for _, delivery := range r.deliveries {
+		if delivery.ID == id {
+			return &delivery, nil
+		}
+	}
+	return nil, domain.ErrExportDeliveryNotFound
+}
+
+func (r *ExportDeliveryRepository) List(ctx context.Context, filter domain.ExportDeliveryFilter) ([]domain.ExportDelivery, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	result := make([]domain.ExportDelivery, 0)
+	for i := len(r.deliveries) - 1; i >= 0; i-- {
+		if filter.Limit > 0 && len(result) >= filter.Limit {
+			break
+		}
+		if filter.Matches(r.deliveries[i]) {
+			result = append(result, r.deliveries[i])
+		}
+	}
+	return result, nil
+}
+
+func (r *ExportDeliveryRepository) Acknowledge(ctx context.Context, ack domain.ExportAck, at time.Time) (*domain.ExportDelivery, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for i := len(r.deliveries) - 1; i >= 0; i-- {
+		if r.deliveries[i].ExportID != ack.ExportID {
+			continue
+		}
+		acknowledged, err := r.deliveries[i].Acknowledge(ack, at)
+		if err != nil {
+			return nil, err
+		}
+		r.deliveries[i] = acknowledged
+		return &acknowledged, nil
+	}
+	return nil, domain.ErrExportDeliveryNotFound
+}
+
+func (r *ExportDeliveryRepository) MarkStuck(ctx context.Context, now time.Time) ([]domain.ExportDelivery, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var stuck []domain.ExportDelivery
+	for i := range r.deliveries {
+		delivery := &r.deliveries[i]
+		if delivery.State != domain.ExportDelivered || delivery.Stuck || now.Before(delivery.Deadline) {
+			continue
+		}
+		delivery.Stuck = true
+		stuck = append(stuck, *delivery)
+	}
+	return stuck, nil
+}
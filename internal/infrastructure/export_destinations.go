@@ -0,0 +1,48 @@
+package infrastructure
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"etlgo/internal/domain"
+)
+
+// loads the export destinations from a JSON array file. An empty path
+// configures none.
+func LoadExportDestinations(path string) ([]domain.ExportDestination, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read export destination file: %w", err)
+	}
+	var destinations []domain.ExportDestination
+	if err := json.Unmarshal(raw, &destinations); err != nil {
+		return nil, fmt.Errorf("failed to parse export destination file: %w", err)
+	}
+
+	names := make(map[string]bool, len(destinations))
+	for i := range destinations {
+		destination := &destinations[i]
+		if err := destination.Validate(); err != nil {
+			return nil, err
+		}
+		if names[destination.Name] {
+			return nil, fmt.Errorf("duplicate export destination %q", destination.Name)
+		}
+		names[destination.Name] = true
+
+		switch destination.Compression {
+		case "":
+			destination.Compression = SinkCompressionNone
+		case SinkCompressionNone, SinkCompressionGzip:
+		default:
+			return nil, fmt.Errorf("%s: compression must be %s or %s", destination.Name, SinkCompressionNone, SinkCompressionGzip)
+		}
+	}
+
+	return destinations, nil
+}
@@ -0,0 +1,94 @@
+package infrastructure
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"etlgo/internal/domain"
+	"etlgo/pkg/logger"
+)
+
+// implements domain.ExportHoldRepository interface in memory
+type ExportHoldRepository struct {
+	holds  []domain.ExportHold
+	mutex  sync.RWMutex
+	logger *logger.Logger
+}
+
+// creates a new export hold repository
+func NewExportHoldRepository(logger *logger.Logger) *ExportHoldRepository {
+	return &ExportHoldRepository{logger: logger}
+}
+
+func (r *ExportHoldRepository) Create(ctx context.Context, hold domain.ExportHold) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, existing := range r.holds {
+		if existing.Status == domain.ExportHoldPending && existing.Date.Equal(hold.Date) {
+			return domain.Errorf(domain.ErrConflict, "exports for %s are already held as %s", hold.Date.Format("2006-01-02"), existing.ID)
+		}
+	}
+	r.holds = append(r.holds, hold)
+
+	r.logger.WithContext(ctx).WithFields(map[string]any{
+		"hold_id": hold.ID,
+		"date":    hold.Date.Format("2006-01-02"),
+	}).Info("Recorded export hold")
+	return nil
+}
+
+func (r *ExportHoldRepository) Get(ctx context.Context, id string) (*domain.ExportHold, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for _, hold := range r.holds {
+		if hold.ID == id {
+			return &hold, nil
+		}
+	}
+	return nil, domain.Errorf(domain.ErrNotFound, "export hold %s not found", id)
+}
+
+func (r *ExportHoldRepository) List(ctx context.Context, filter domain.ExportHoldFilter) ([]domain.ExportHold, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	result := make([]domain.ExportHold, 0)
+	for i := len(r.holds) - 1; i >= 0; i-- {
+		if filter.Limit > 0 && len(result) >= filter.Limit {
+			break
+		}
+		hold := r.holds[i]
+		if filter.Status != "" && hold.Status != filter.Status {
+			continue
+		}
+		if filter.Date != nil && !hold.Date.Equal(*filter.Date) {
+			continue
+		}
+		result = append(result, hold)
+	}
+	return result, nil
+}
+
+func (r *ExportHoldRepository) Approve(ctx context.Context, id, actor string, at time.Time) (*domain.ExportHold, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for i := range r.holds {
+		hold := &r.holds[i]
+		if hold.ID != id {
+			continue
+		}
+		if hold.Status != domain.ExportHoldPending {
+			return nil, domain.Errorf(domain.ErrConflict, "export hold %s is already %s", id, hold.Status)
+		}
+		hold.Status = domain.ExportHoldApproved
+		hold.ApprovedBy = actor
+		hold.ApprovedAt = &at
+		approved := *hold
+		return &approved, nil
+	}
+	return nil, domain.Errorf(domain.ErrNotFound, "export hold %s not found", id)
+}
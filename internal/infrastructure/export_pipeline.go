@@ -0,0 +1,124 @@
+package infrastructure
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+)
+
+// records serialized per pipeline job
+const exportBlockRecords = 500
+
+// runs encode for the indexes 0..n-1 on up to workers goroutines and hands
+// each result to emit. Ordered pipelines emit in index order, a result as
+// soon as it and all before it are ready, so emitting overlaps with
+// encoding the rest; unordered pipelines emit from the workers as results
+// complete. At most twice as many results as workers are held at a time.
+// The first error stops the pipeline and is returned.
+func runPipeline[T any](ctx context.Context, n, workers int, ordered bool, encode func(i int) (T, error), emit func(ctx context.Context, i int, result T) error) error {
+	if n == 0 {
+		return nil
+	}
+	workers = max(1, min(workers, n))
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	// A slot is taken per index fed and released once it is emitted
+	window := make(chan struct{}, 2*workers)
+	jobs := make(chan int)
+	go func() {
+		defer close(jobs)
+		for i := range n {
+			select {
+			case window <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case jobs <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	// Buffered so workers never wait on the emitter
+	var results []chan T
+	if ordered {
+		results = make([]chan T, n)
+		for i := range results {
+			results[i] = make(chan T, 1)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Go(func() {
+			for i := range jobs {
+				if ctx.Err() != nil {
+					continue
+				}
+				result, err := encode(i)
+				if err != nil {
+					cancel(err)
+					continue
+				}
+				if ordered {
+					results[i] <- result
+					continue
+				}
+				if err := emit(ctx, i, result); err != nil {
+					cancel(err)
+				}
+				<-window
+			}
+		})
+	}
+
+	if ordered {
+	emitLoop:
+		for i := range n {
+			select {
+			case result := <-results[i]:
+				if err := emit(ctx, i, result); err != nil {
+					cancel(err)
+					break emitLoop
+				}
+				<-window
+			case <-ctx.Done():
+				break emitLoop
+			}
+		}
+	}
+
+	wg.Wait()
+	return context.Cause(ctx)
+}
+
+// JSON encodes the records concurrently and emits them in order
+func encodeJSONRecords[T any](ctx context.Context, records []T, workers int, emit func(encoded []byte) error) error {
+	blocks := (len(records) + exportBlockRecords - 1) / exportBlockRecords
+	return runPipeline(ctx, blocks, workers, true,
+		func(block int) ([][]byte, error) {
+			start := block * exportBlockRecords
+			end := min(start+exportBlockRecords, len(records))
+			encoded := make([][]byte, 0, end-start)
+			for _, record := range records[start:end] {
+				raw, err := json.Marshal(record)
+				if err != nil {
+					return nil, err
+				}
+				encoded = append(encoded, raw)
+			}
+			return encoded, nil
+		},
+		func(_ context.Context, _ int, encoded [][]byte) error {
+			for _, raw := range encoded {
+				if err := emit(raw); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+}
@@ -0,0 +1,115 @@
+package infrastructure
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"etlgo/internal/domain"
+	"etlgo/pkg/logger"
+)
+
+// implements domain.ExportSnapshotRepository. Snapshots are kept in memory
+// and, when a path is set, written to a JSON file after every save so diff
+// exports after a restart compare against what was last sent.
+type ExportSnapshotRepository struct {
+	path      string
+	snapshots map[string]domain.ExportSnapshot // by destination and date
+	mutex     sync.RWMutex
+	logger    *logger.Logger
+}
+
+// creates a snapshot store, loading the file at path when it exists. An
+// empty path keeps the snapshots in memory only.
+func NewExportSnapshotRepository(path string, logger *logger.Logger) (*ExportSnapshotRepository, error) {
+	r := &ExportSnapshotRepository{
+		path:      path,
+		snapshots: make(map[string]domain.ExportSnapshot),
+		logger:    logger,
+	}
+	if path == "" {
+		return r, nil
+	}
+
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read export snapshots: %w", err)
+	}
+	var snapshots []domain.ExportSnapshot
+	if err := json.Unmarshal(raw, &snapshots); err != nil {
+		return nil, fmt.Errorf("failed to parse export snapshots: %w", err)
+	}
+	for _, snapshot := range snapshots {
+		r.snapshots[exportSnapshotKey(snapshot.Destination, snapshot.Date)] = snapshot
+	}
+	return r, nil
+}
+
+func exportSnapshotKey(destination, date string) string {
+	return destination + "/" + date
+}
+
+func (r *ExportSnapshotRepository) Get(ctx context.Context, destination string, date time.Time) (*domain.ExportSnapshot, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	snapshot, ok := r.snapshots[exportSnapshotKey(destination, date.Format("2006-01-02"))]
+	if !ok {
+		return nil, nil
+	}
+	return &snapshot, nil
+}
+
+// replaces the destination's snapshot of the date
+func (r *ExportSnapshotRepository) Save(ctx context.Context, snapshot domain.ExportSnapshot) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.snapshots[exportSnapshotKey(snapshot.Destination, snapshot.Date)] = snapshot
+	return r.save()
+}
+
+// writes the snapshots to a temporary file and renames it over the old one
+func (r *ExportSnapshotRepository) save() error {
+	if r.path == "" {
+		return nil
+	}
+
+	snapshots := make([]domain.ExportSnapshot, 0, len(r.snapshots))
+	for _, snapshot := range r.snapshots {
+		snapshots = append(snapshots, snapshot)
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return exportSnapshotKey(snapshots[i].Destination, snapshots[i].Date) < exportSnapshotKey(snapshots[j].Destination, snapshots[j].Date)
+	})
+	raw, err := json.Marshal(snapshots)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(r.path), filepath.Base(r.path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write export snapshots: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(raw); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write export snapshots: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write export snapshots: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), r.path); err != nil {
+		return fmt.Errorf("failed to write export snapshots: %w", err)
+	}
+	return nil
+}
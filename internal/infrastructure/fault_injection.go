@@ -0,0 +1,283 @@
+package infrastructure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"time"
+
+	"etlgo/internal/domain"
+	"etlgo/pkg/logger"
+	"etlgo/pkg/metrics"
+)
+
+// returned by operations the fault injector failed on purpose
+var ErrInjectedFault = errors.New("injected fault")
+
+// what faults are injected into
+const (
+	FaultTargetUpstream = "upstream" // outbound HTTP calls: ads, CRM, GA4 and the sink
+	FaultTargetStorage  = "storage"  // ads, CRM and metrics repository operations
+)
+
+var faultTargets = []string{FaultTargetUpstream, FaultTargetStorage}
+
+// how often faults are injected. FailPercent of the operations fail and
+// DelayPercent are delayed by up to MaxDelay first. Targets defaults to
+// every target.
+type FaultOptions struct {
+	FailPercent  float64
+	DelayPercent float64
+	MaxDelay     time.Duration
+	Targets      []string
+}
+
+func (o FaultOptions) Validate() error {
+	for _, percent := range []float64{o.FailPercent, o.DelayPercent} {
+		if percent < 0 || percent > 100 {
+			return fmt.Errorf("fault percentages must be between 0 and 100, got %g", percent)
+		}
+	}
+	if o.DelayPercent > 0 && o.MaxDelay <= 0 {
+		return fmt.Errorf("injected delays need a positive max delay")
+	}
+	for _, target := range o.Targets {
+		if !slices.Contains(faultTargets, target) {
+			return fmt.Errorf("unknown fault target %q, expected one of %v", target, faultTargets)
+		}
+	}
+	return nil
+}
+
+// randomly delays and fails operations for resilience testing. A nil
+// injector injects nothing.
+type FaultInjector struct {
+	opts    FaultOptions
+	logger  *logger.Logger
+	metrics *metrics.Metrics
+}
+
+func NewFaultInjector(opts FaultOptions, logger *logger.Logger, metrics *metrics.Metrics) *FaultInjector {
+	if len(opts.Targets) == 0 {
+		opts.Targets = faultTargets
+	}
+	return &FaultInjector{opts: opts, logger: logger, metrics: metrics}
+}
+
+func (f *FaultInjector) targets(target string) bool {
+	return f != nil && slices.Contains(f.opts.Targets, target)
+}
+
+// delays and fails the operation as configured. Delays end early when the
+// context is done.
+func (f *FaultInjector) inject(ctx context.Context, target, operation string) error {
+	if f.opts.DelayPercent > 0 && rand.Float64()*100 < f.opts.DelayPercent {
+		delay := time.Duration(rand.Int64N(int64(f.opts.MaxDelay))) + 1
+		f.metrics.RecordInjectedFault(target, "delay")
+		f.logger.WithContext(ctx).WithFields(map[string]any{
+			"target":    target,
+			"operation": operation,
+			"delay":     delay,
+		}).Debug("Injecting delay")
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+
+	if f.opts.FailPercent > 0 && rand.Float64()*100 < f.opts.FailPercent {
+		f.metrics.RecordInjectedFault(target, "failure")
+		f.logger.WithContext(ctx).WithFields(map[string]any{
+			"target":    target,
+			"operation": operation,
+		}).Warn("Injecting failure")
+		return fmt.Errorf("%w: %s %s", ErrInjectedFault, target, operation)
+	}
+	return nil
+}
+
+// returns the transport injecting faults into its requests
+func (f *FaultInjector) WrapTransport(next http.RoundTripper) http.RoundTripper {
+	if !f.targets(FaultTargetUpstream) {
+		return next
+	}
+	return &faultTransport{next: next, faults: f}
+}
+
+type faultTransport struct {
+	next   http.RoundTripper
+	faults *FaultInjector
+}
+
+func (t *faultTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.faults.inject(req.Context(), FaultTargetUpstream, req.Method+" "+req.URL.Host); err != nil {
+		return nil, err
+	}
+	return t.next.RoundTrip(req)
+}
+
+// replaces the ads, CRM and metrics repositories of the storage with ones
+// injecting faults into their operations
+func (f *FaultInjector) WrapStorage(storage *domain.Storage) {
+	if !f.targets(FaultTargetStorage) {
+		return
+	}
+	storage.Ads = &faultAdRepository{next: storage.Ads, faults: f}
+	storage.CRM = &faultCRMRepository{next: storage.CRM, faults: f}
+	storage.Metrics = &faultMetricsRepository{next: storage.Metrics, faults: f}
+}
+
+type faultAdRepository struct {
+	next   domain.AdRepository
+	faults *FaultInjector
+}
+
+func (r *faultAdRepository) Store(ctx context.Context, ads []domain.ProcessedAdData) error {
+	if err := r.faults.inject(ctx, FaultTargetStorage, "ads.Store"); err != nil {
+		return err
+	}
+	return r.next.Store(ctx, ads)
+}
+
+func (r *faultAdRepository) Replace(ctx context.Context, from, to time.Time, ads []domain.ProcessedAdData) error {
+	if err := r.faults.inject(ctx, FaultTargetStorage, "ads.Replace"); err != nil {
+		return err
+	}
+	return r.next.Replace(ctx, from, to, ads)
+}
+
+func (r *faultAdRepository) GetByDateRange(ctx context.Context, from, to time.Time) ([]domain.ProcessedAdData, error) {
+	if err := r.faults.inject(ctx, FaultTargetStorage, "ads.GetByDateRange"); err != nil {
+		return nil, err
+	}
+	return r.next.GetByDateRange(ctx, from, to)
+}
+
+func (r *faultAdRepository) GetByUTM(ctx context.Context, utm domain.UTMKey, from, to time.Time) ([]domain.ProcessedAdData, error) {
+	if err := r.faults.inject(ctx, FaultTargetStorage, "ads.GetByUTM"); err != nil {
+		return nil, err
+	}
+	return r.next.GetByUTM(ctx, utm, from, to)
+}
+
+func (r *faultAdRepository) GetByCampaign(ctx context.Context, campaignID string, from, to time.Time) ([]domain.ProcessedAdData, error) {
+	if err := r.faults.inject(ctx, FaultTargetStorage, "ads.GetByCampaign"); err != nil {
+		return nil, err
+	}
+	return r.next.GetByCampaign(ctx, campaignID, from, to)
+}
+
+func (r *faultAdRepository) GetByChannel(ctx context.Context, channel string, from, to time.Time) ([]domain.ProcessedAdData, error) {
+	if err := r.faults.inject(ctx, FaultTargetStorage, "ads.GetByChannel"); err != nil {
+		return nil, err
+	}
+	return r.next.GetByChannel(ctx, channel, from, to)
+}
+
+type faultCRMRepository struct {
+	next   domain.CRMRepository
+	faults *FaultInjector
+}
+
+func (r *faultCRMRepository) Store(ctx context.Context, opportunities []domain.ProcessedOpportunity) error {
+	if err := r.faults.inject(ctx, FaultTargetStorage, "crm.Store"); err != nil {
+		return err
+	}
+	return r.next.Store(ctx, opportunities)
+}
+
+func (r *faultCRMRepository) Delete(ctx context.Context, ids []string) error {
+	if err := r.faults.inject(ctx, FaultTargetStorage, "crm.Delete"); err != nil {
+		return err
+	}
+	return r.next.Delete(ctx, ids)
+}
+
+func (r *faultCRMRepository) GetByIDs(ctx context.Context, ids []string) ([]domain.ProcessedOpportunity, error) {
+	if err := r.faults.inject(ctx, FaultTargetStorage, "crm.GetByIDs"); err != nil {
+		return nil, err
+	}
+	return r.next.GetByIDs(ctx, ids)
+}
+
+func (r *faultCRMRepository) GetByDateRange(ctx context.Context, from, to time.Time) ([]domain.ProcessedOpportunity, error) {
+	if err := r.faults.inject(ctx, FaultTargetStorage, "crm.GetByDateRange"); err != nil {
+		return nil, err
+	}
+	return r.next.GetByDateRange(ctx, from, to)
+}
+
+func (r *faultCRMRepository) GetByUTM(ctx context.Context, utm domain.UTMKey, from, to time.Time) ([]domain.ProcessedOpportunity, error) {
+	if err := r.faults.inject(ctx, FaultTargetStorage, "crm.GetByUTM"); err != nil {
+		return nil, err
+	}
+	return r.next.GetByUTM(ctx, utm, from, to)
+}
+
+func (r *faultCRMRepository) GetByStage(ctx context.Context, stage domain.OpportunityStage, from, to time.Time) ([]domain.ProcessedOpportunity, error) {
+	if err := r.faults.inject(ctx, FaultTargetStorage, "crm.GetByStage"); err != nil {
+		return nil, err
+	}
+	return r.next.GetByStage(ctx, stage, from, to)
+}
+
+type faultMetricsRepository struct {
+	next   domain.MetricsRepository
+	faults *FaultInjector
+}
+
+func (r *faultMetricsRepository) Store(ctx context.Context, metrics []domain.BusinessMetrics) error {
+	if err := r.faults.inject(ctx, FaultTargetStorage, "metrics.Store"); err != nil {
+		return err
+	}
+	return r.next.Store(ctx, metrics)
+}
+
+func (r *faultMetricsRepository) Upsert(ctx context.Context, metrics []domain.BusinessMetrics) error {
+	if err := r.faults.inject(ctx, FaultTargetStorage, "metrics.Upsert"); err != nil {
+		return err
+	}
+	return r.next.Upsert(ctx, metrics)
+}
+
+func (r *faultMetricsRepository) GetByFilter(ctx context.Context, filter domain.MetricsFilter) (*domain.MetricsResponse, error) {
+	if err := r.faults.inject(ctx, FaultTargetStorage, "metrics.GetByFilter"); err != nil {
+		return nil, err
+	}
+	return r.next.GetByFilter(ctx, filter)
+}
+
+func (r *faultMetricsRepository) GetByDate(ctx context.Context, date time.Time) ([]domain.BusinessMetrics, error) {
+	if err := r.faults.inject(ctx, FaultTargetStorage, "metrics.GetByDate"); err != nil {
+		return nil, err
+	}
+	return r.next.GetByDate(ctx, date)
+}
+
+func (r *faultMetricsRepository) GetDistinctValues(ctx context.Context, dimension string, from, to time.Time) ([]domain.DimensionValue, error) {
+	if err := r.faults.inject(ctx, FaultTargetStorage, "metrics.GetDistinctValues"); err != nil {
+		return nil, err
+	}
+	return r.next.GetDistinctValues(ctx, dimension, from, to)
+}
+
+func (r *faultMetricsRepository) Count(ctx context.Context, from, to time.Time) (int64, error) {
+	if err := r.faults.inject(ctx, FaultTargetStorage, "metrics.Count"); err != nil {
+		return 0, err
+	}
+	return r.next.Count(ctx, from, to)
+}
+
+func (r *faultMetricsRepository) Replace(ctx context.Context, from, to time.Time, metrics []domain.BusinessMetrics) ([]domain.BusinessMetrics, error) {
+	if err := r.faults.inject(ctx, FaultTargetStorage, "metrics.Replace"); err != nil {
+		return nil, err
+	}
+	return r.next.Replace(ctx, from, to, metrics)
+}
@@ -0,0 +1,166 @@
+package infrastructure
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"etlgo/internal/domain"
+	"etlgo/pkg/logger"
+)
+
+// implements domain.FeatureFlagProvider from a JSON file keyed by flag
+// name, layered over the built-in defaults and the FEATURE_FLAGS overrides.
+// The file can be reloaded at runtime so flags change without a redeploy.
+type ConfigFlagProvider struct {
+	path        string
+	environment string
+	overrides   map[string]bool
+	flags       map[string]domain.FeatureFlag
+	modTime     time.Time
+	mutex       sync.RWMutex
+	logger      *logger.Logger
+}
+
+// creates a provider for the environment and loads the flag file. An empty
+// path uses the defaults. overrides is a comma separated list of
+// name=true|false pairs that set a flag's default state.
+func NewConfigFlagProvider(path, environment, overrides string, logger *logger.Logger) (*ConfigFlagProvider, error) {
+	parsed, err := parseFlagOverrides(overrides)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &ConfigFlagProvider{
+		path:        path,
+		environment: environment,
+		overrides:   parsed,
+		logger:      logger,
+	}
+	if err := p.Reload(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// re-reads the flag file. On error the current flags are kept.
+func (p *ConfigFlagProvider) Reload() error {
+	flags := make(map[string]domain.FeatureFlag, len(domain.DefaultFeatureFlags))
+	for _, flag := range domain.DefaultFeatureFlags {
+		flags[flag.Name] = flag
+	}
+
+	var modTime time.Time
+	if p.path != "" {
+		info, err := os.Stat(p.path)
+		if err != nil {
+			return fmt.Errorf("failed to read feature flag file: %w", err)
+		}
+		modTime = info.ModTime()
+
+		raw, err := os.ReadFile(p.path)
+		if err != nil {
+			return fmt.Errorf("failed to read feature flag file: %w", err)
+		}
+		var configured map[string]domain.FeatureFlag
+		if err := json.Unmarshal(raw, &configured); err != nil {
+			return fmt.Errorf("failed to parse feature flag file: %w", err)
+		}
+		for name, flag := range configured {
+			flag.Name = name
+			if flag.Description == "" {
+				flag.Description = flags[name].Description
+			}
+			flags[name] = flag
+		}
+	}
+
+	for name, enabled := range p.overrides {
+		flag := flags[name]
+		flag.Name = name
+		flag.Enabled = enabled
+		flags[name] = flag
+	}
+
+	p.mutex.Lock()
+	p.flags = flags
+	p.modTime = modTime
+	p.mutex.Unlock()
+
+	p.logger.WithFields(map[string]any{
+		"environment": p.environment,
+		"flags":       len(flags),
+	}).Info("Feature flags loaded")
+	return nil
+}
+
+// checks the flag file every interval and reloads it when it changed, until
+// ctx is cancelled
+func (p *ConfigFlagProvider) Watch(ctx context.Context, interval time.Duration, heartbeat domain.Heartbeat) {
+	if p.path == "" {
+		return
+	}
+
+	for heartbeat.Sleep(ctx, interval) {
+		info, err := os.Stat(p.path)
+		if err == nil {
+			p.mutex.RLock()
+			unchanged := info.ModTime().Equal(p.modTime)
+			p.mutex.RUnlock()
+			if unchanged {
+				continue
+			}
+			err = p.Reload()
+		}
+		if err != nil {
+			p.logger.WithError(err).Warn("Failed to reload feature flags")
+		}
+	}
+}
+
+// reports whether the flag is on for this environment and the tenant in
+// ctx. Unknown flags are off.
+func (p *ConfigFlagProvider) IsEnabled(ctx context.Context, name string) bool {
+	p.mutex.RLock()
+	flag, ok := p.flags[name]
+	p.mutex.RUnlock()
+
+	return ok && flag.EnabledFor(p.environment, domain.TenantFromContext(ctx))
+}
+
+// returns every flag sorted by name
+func (p *ConfigFlagProvider) Flags(ctx context.Context) []domain.FeatureFlag {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	names := slices.Sorted(maps.Keys(p.flags))
+	flags := make([]domain.FeatureFlag, 0, len(names))
+	for _, name := range names {
+		flags = append(flags, p.flags[name])
+	}
+	return flags
+}
+
+func parseFlagOverrides(overrides string) (map[string]bool, error) {
+	parsed := map[string]bool{}
+	for _, pair := range strings.Split(overrides, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(pair, "=")
+		enabled, err := strconv.ParseBool(strings.TrimSpace(value))
+		if !ok || err != nil || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("invalid feature flag override %q: expected name=true|false", pair)
+		}
+		parsed[strings.TrimSpace(name)] = enabled
+	}
+	return parsed, nil
+}
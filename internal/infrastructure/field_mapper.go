@@ -0,0 +1,764 @@
+package infrastructure
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"etlgo/internal/domain"
+)
+
+type fieldKind int
+
+const (
+	fieldString fieldKind = iota
+	fieldInt
+	fieldMoney
+	fieldDate
+	fieldTouches
+	fieldFloat
+)
+
+// target field type and, for dates, the canonical layout the domain expects
+type fieldSpec struct {
+	kind   fieldKind
+	layout string
+}
+
+var adFieldSpecs = map[string]fieldSpec{
+	"date":          {kind: fieldDate, layout: "2006-01-02"},
+	"campaign_id":   {kind: fieldString},
+	"channel":       {kind: fieldString},
+	"clicks":        {kind: fieldInt},
+	"impressions":   {kind: fieldInt},
+	"cost":          {kind: fieldMoney},
+	"utm_campaign":  {kind: fieldString},
+	"utm_source":    {kind: fieldString},
+	"utm_medium":    {kind: fieldString},
+	"ad_group_id":   {kind: fieldString},
+	"quality_score": {kind: fieldFloat},
+}
+
+var crmFieldSpecs = map[string]fieldSpec{
+	"opportunity_id": {kind: fieldString},
+	"contact_email":  {kind: fieldString},
+	"stage":          {kind: fieldString},
+	"amount":         {kind: fieldMoney},
+	"currency":       {kind: fieldString},
+	"probability":    {kind: fieldFloat},
+	"created_at":     {kind: fieldDate, layout: time.RFC3339},
+	"closed_at":      {kind: fieldDate, layout: time.RFC3339},
+	"utm_campaign":   {kind: fieldString},
+	"utm_source":     {kind: fieldString},
+	"utm_medium":     {kind: fieldString},
+	"touches":        {kind: fieldTouches},
+}
+
+var keywordFieldSpecs = map[string]fieldSpec{
+	"date":        {kind: fieldDate, layout: "2006-01-02"},
+	"campaign_id": {kind: fieldString},
+	"ad_group_id": {kind: fieldString},
+	"channel":     {kind: fieldString},
+	"keyword":     {kind: fieldString},
+	"match_type":  {kind: fieldString},
+	"clicks":      {kind: fieldInt},
+	"cost":        {kind: fieldMoney},
+}
+
+var sourceFieldSpecs = map[string]map[string]fieldSpec{
+	domain.SourceAds:      adFieldSpecs,
+	domain.SourceCRM:      crmFieldSpecs,
+	domain.SourceKeywords: keywordFieldSpecs,
+}
+
+var defaultRecordPaths = map[string]string{
+	domain.SourceAds:      "$.external.ads.performance",
+	domain.SourceCRM:      "$.external.crm.opportunities",
+	domain.SourceKeywords: "$.external.ads.keywords",
+}
+
+type pathSegment struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+type compiledField struct {
+	target string
+	spec   fieldSpec
+	path   []pathSegment
+	column string // the path as a CSV column name
+	mapped bool   // configured rather than the identity mapping
+	def    any
+	format string
+	locale string
+}
+
+type compiledSource struct {
+	records    []pathSegment
+	fields     []compiledField
+	pagination *compiledPagination
+	delimiter  rune // of CSV files
+}
+
+// decodes upstream payloads into domain records using per-source field mappings
+type FieldMapper struct {
+	sources map[string]compiledSource
+}
+
+// loads field mappings from a JSON file. An empty path yields the default
+// mapping, which matches the upstream field names one to one.
+func LoadFieldMapper(path string) (*FieldMapper, error) {
+	config := domain.FieldMappingConfig{}
+	if path != "" {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read field mapping file: %w", err)
+		}
+		if err := json.Unmarshal(raw, &config); err != nil {
+			return nil, fmt.Errorf("failed to parse field mapping file: %w", err)
+		}
+	}
+	return NewFieldMapper(config)
+}
+
+// compiles the configured mappings on top of the defaults
+func NewFieldMapper(config domain.FieldMappingConfig) (*FieldMapper, error) {
+	for source := range config {
+		if _, ok := defaultRecordPaths[source]; !ok {
+			return nil, fmt.Errorf("unknown mapping source %q", source)
+		}
+	}
+
+	mapper := &FieldMapper{sources: make(map[string]compiledSource)}
+	for source, specs := range sourceFieldSpecs {
+		compiled, err := compileSource(source, specs, config[source])
+		if err != nil {
+			return nil, err
+		}
+		mapper.sources[source] = compiled
+	}
+
+	return mapper, nil
+}
+
+// returns a mapper decoding the source with mapping instead of its
+// configured one, for connectors with their own
+func (m *FieldMapper) withSource(source string, mapping domain.SourceMapping) (*FieldMapper, error) {
+	compiled, err := compileSource(source, sourceFieldSpecs[source], mapping)
+	if err != nil {
+		return nil, err
+	}
+	sources := maps.Clone(m.sources)
+	sources[source] = compiled
+	return &FieldMapper{sources: sources}, nil
+}
+
+func compileSource(source string, specs map[string]fieldSpec, mapping domain.SourceMapping) (compiledSource, error) {
+	recordsPath := mapping.Records
+	if recordsPath == "" {
+		recordsPath = defaultRecordPaths[source]
+	}
+	records, err := parsePath(recordsPath)
+	if err != nil {
+		return compiledSource{}, fmt.Errorf("%s records path: %w", source, err)
+	}
+	if !isValidNumberLocale(mapping.Locale) {
+		return compiledSource{}, fmt.Errorf("%s mapping: unsupported locale %q", source, mapping.Locale)
+	}
+
+	for target := range mapping.Fields {
+		if _, ok := specs[target]; !ok {
+			return compiledSource{}, fmt.Errorf("%s mapping: unknown field %q", source, target)
+		}
+	}
+
+	targets := make([]string, 0, len(specs))
+	for target := range specs {
+		targets = append(targets, target)
+	}
+	sort.Strings(targets)
+
+	pagination, err := compilePagination(source, mapping.Pagination)
+	if err != nil {
+		return compiledSource{}, err
+	}
+
+	delimiter, err := compileDelimiter(source, mapping.CSV)
+	if err != nil {
+		return compiledSource{}, err
+	}
+
+	compiled := compiledSource{records: records, pagination: pagination, delimiter: delimiter}
+	for _, target := range targets {
+		spec := specs[target]
+		field, ok := mapping.Fields[target]
+		mapped := ok && field.Path != ""
+		if !mapped {
+			field.Path = target
+		}
+		if field.Format != "" && spec.kind != fieldDate {
+			return compiledSource{}, fmt.Errorf("%s mapping: format is only supported on date fields, not %q", source, target)
+		}
+		if field.Locale != "" && spec.kind != fieldInt && spec.kind != fieldMoney && spec.kind != fieldFloat {
+			return compiledSource{}, fmt.Errorf("%s mapping: locale is only supported on number fields, not %q", source, target)
+		}
+		if !isValidNumberLocale(field.Locale) {
+			return compiledSource{}, fmt.Errorf("%s mapping for %q: unsupported locale %q", source, target, field.Locale)
+		}
+		if field.Locale == "" {
+			field.Locale = mapping.Locale
+		}
+
+		path, err := parsePath(field.Path)
+		if err != nil {
+			return compiledSource{}, fmt.Errorf("%s mapping for %q: %w", source, target, err)
+		}
+
+		compiledField := compiledField{
+			target: target,
+			spec:   spec,
+			path:   path,
+			column: strings.TrimSpace(field.Path),
+			mapped: mapped,
+			format: field.Format,
+			locale: field.Locale,
+		}
+		if field.Default != nil {
+			if compiledField.def, err = compiledField.coerce(field.Default); err != nil {
+				return compiledSource{}, fmt.Errorf("%s mapping for %q: invalid default: %w", source, target, err)
+			}
+		}
+
+		compiled.fields = append(compiled.fields, compiledField)
+	}
+
+	return compiled, nil
+}
+
+// returns the field delimiter of the source's CSV files
+func compileDelimiter(source string, format *domain.CSVFormat) (rune, error) {
+	if format == nil || format.Delimiter == "" {
+		return ',', nil
+	}
+	delimiter := []rune(format.Delimiter)
+	if len(delimiter) != 1 || strings.ContainsRune("\"\r\n", delimiter[0]) || delimiter[0] == utf8.RuneError {
+		return 0, fmt.Errorf("%s csv: delimiter must be a single character other than a quote or line break, got %q", source, format.Delimiter)
+	}
+	return delimiter[0], nil
+}
+
+// returns the pagination of the source's API, or nil when it returns
+// everything at once
+func (m *FieldMapper) pagination(source string) *compiledPagination {
+	return m.sources[source].pagination
+}
+
+// the decoded records of one or more responses of a source, and the next
+// cursor of the last one
+type mappedPage struct {
+	records  []mappedRecord
+	rejected []domain.QuarantinedRecord
+	cursor   string
+}
+
+// returns the records of the page, rejected ones included
+func (p mappedPage) len() int {
+	return len(p.records) + len(p.rejected)
+}
+
+// appends the records of the next page
+func (p *mappedPage) add(next mappedPage) {
+	p.records = append(p.records, next.records...)
+	p.rejected = append(p.rejected, next.rejected...)
+	p.cursor = next.cursor
+}
+
+// returns the records as an ads API response
+func (p mappedPage) adData() *domain.AdData {
+	adData := domain.AdData{Rejected: p.rejected}
+	performance := make([]domain.AdPerformance, 0, len(p.records))
+	for _, r := range p.records {
+		performance = append(performance, domain.AdPerformance{
+			Date:         r.str("date"),
+			CampaignID:   r.str("campaign_id"),
+			Channel:      r.str("channel"),
+			Clicks:       r.int("clicks"),
+			Impressions:  r.int("impressions"),
+			Cost:         r.money("cost"),
+			UTMCampaign:  r.str("utm_campaign"),
+			UTMSource:    r.str("utm_source"),
+			UTMMedium:    r.str("utm_medium"),
+			AdGroupID:    r.str("ad_group_id"),
+			QualityScore: r.optionalFloat("quality_score"),
+		})
+	}
+	adData.External.Ads.Performance = performance
+	return &adData
+}
+
+// returns the records as a CRM API response
+func (p mappedPage) crmData() *domain.CRMData {
+	crmData := domain.CRMData{Rejected: p.rejected}
+	opportunities := make([]domain.Opportunity, 0, len(p.records))
+	for _, r := range p.records {
+		opportunities = append(opportunities, domain.Opportunity{
+			OpportunityID: r.str("opportunity_id"),
+			ContactEmail:  r.str("contact_email"),
+			Stage:         domain.OpportunityStage(r.str("stage")),
+			Amount:        r.money("amount"),
+			Currency:      r.str("currency"),
+			Probability:   r.optionalFloat("probability"),
+			CreatedAt:     r.str("created_at"),
+			ClosedAt:      r.str("closed_at"),
+			UTMCampaign:   r.str("utm_campaign"),
+			UTMSource:     r.str("utm_source"),
+			UTMMedium:     r.str("utm_medium"),
+			Touches:       r.touches("touches"),
+		})
+	}
+	crmData.External.CRM.Opportunities = opportunities
+	return &crmData
+}
+
+// returns the records as a keyword feed response
+func (p mappedPage) keywordData() *domain.KeywordData {
+	keywordData := domain.KeywordData{Rejected: p.rejected}
+	keywordData.Rows = make([]domain.KeywordPerformance, 0, len(p.records))
+	for _, r := range p.records {
+		keywordData.Rows = append(keywordData.Rows, domain.KeywordPerformance{
+			Date:       r.str("date"),
+			CampaignID: r.str("campaign_id"),
+			AdGroupID:  r.str("ad_group_id"),
+			Channel:    r.str("channel"),
+			Keyword:    r.str("keyword"),
+			MatchType:  r.str("match_type"),
+			Clicks:     r.int("clicks"),
+			Cost:       r.money("cost"),
+		})
+	}
+	return &keywordData
+}
+
+// coerced field values of one record keyed by domain field
+type mappedRecord map[string]any
+
+func (r mappedRecord) str(field string) string {
+	s, _ := r[field].(string)
+	return s
+}
+
+func (r mappedRecord) int(field string) int {
+	i, _ := r[field].(int)
+	return i
+}
+
+func (r mappedRecord) money(field string) domain.Money {
+	m, _ := r[field].(domain.Money)
+	return m
+}
+
+// returns nil when the record has no value for the field
+func (r mappedRecord) optionalFloat(field string) *float64 {
+	f, ok := r[field].(float64)
+	if !ok {
+		return nil
+	}
+	return &f
+}
+
+func (r mappedRecord) touches(field string) []domain.Touch {
+	t, _ := r[field].([]domain.Touch)
+	return t
+}
+
+// decodes the records of a payload, numbering its rows from offset, and
+// its next cursor. Rows with values that cannot be coerced are returned as
+// rejected instead of failing the whole payload.
+func (m *FieldMapper) decode(source string, body []byte, offset int) (mappedPage, error) {
+	compiled := m.sources[source]
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var root any
+	if err := decoder.Decode(&root); err != nil {
+		return mappedPage{}, err
+	}
+
+	var page mappedPage
+	if compiled.pagination != nil && compiled.pagination.nextCursor != nil {
+		if cursor, found := lookupPath(root, compiled.pagination.nextCursor); found && cursor != nil {
+			page.cursor = fmt.Sprint(cursor)
+		}
+	}
+
+	value, found := lookupPath(root, compiled.records)
+	if !found || value == nil {
+		return page, nil
+	}
+	items, ok := value.([]any)
+	if !ok {
+		return mappedPage{}, fmt.Errorf("records path does not point to an array")
+	}
+
+	page.records, page.rejected = compiled.mapRecords(source, items, offset, func(item any, field compiledField) (any, bool) {
+		return lookupPath(item, field.path)
+	})
+	return page, nil
+}
+
+// decodes a newline delimited JSON file of the source, one record per
+// line, numbering its rows from offset. Blank lines are skipped.
+func (m *FieldMapper) decodeNDJSON(source string, r io.Reader, offset int) (mappedPage, error) {
+	compiled := m.sources[source]
+
+	var items []any
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+	for {
+		var item any
+		err := decoder.Decode(&item)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return mappedPage{}, fmt.Errorf("invalid NDJSON record %d: %w", len(items), err)
+		}
+		items = append(items, item)
+	}
+
+	var page mappedPage
+	page.records, page.rejected = compiled.mapRecords(source, items, offset, func(item any, field compiledField) (any, bool) {
+		return lookupPath(item, field.path)
+	})
+	return page, nil
+}
+
+// reads the rows of a CSV file of the source into records keyed by the
+// column names of its header row, numbering them from offset. Fields are
+// read from the column named by their mapping path, and empty cells count
+// as missing.
+func (m *FieldMapper) decodeCSV(source string, r io.Reader, offset int) (mappedPage, error) {
+	compiled := m.sources[source]
+
+	reader := csv.NewReader(r)
+	reader.Comma = compiled.delimiter
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return mappedPage{}, nil
+	}
+	if err != nil {
+		return mappedPage{}, fmt.Errorf("invalid CSV header: %w", err)
+	}
+	header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	columns := make(map[string]bool, len(header))
+	for _, name := range header {
+		columns[name] = true
+	}
+	for _, field := range compiled.fields {
+		if field.mapped && !columns[field.column] {
+			return mappedPage{}, fmt.Errorf("CSV has no column %q for field %q", field.column, field.target)
+		}
+	}
+
+	var items []any
+	for {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return mappedPage{}, fmt.Errorf("invalid CSV: %w", err)
+		}
+		item := make(map[string]any, len(header))
+		for i, name := range header {
+			if row[i] != "" {
+				item[name] = row[i]
+			}
+		}
+		items = append(items, item)
+	}
+
+	var page mappedPage
+	page.records, page.rejected = compiled.mapRecords(source, items, offset, func(item any, field compiledField) (any, bool) {
+		value, found := item.(map[string]any)[field.column]
+		return value, found
+	})
+	return page, nil
+}
+
+// coerces the fields of the items, numbering them from offset. Items with
+// values that cannot be coerced are returned as rejected.
+func (c compiledSource) mapRecords(source string, items []any, offset int, lookup func(item any, field compiledField) (any, bool)) ([]mappedRecord, []domain.QuarantinedRecord) {
+	records := make([]mappedRecord, 0, len(items))
+	var rejected []domain.QuarantinedRecord
+	for i, item := range items {
+		row := fmt.Sprintf("row %d", offset+i)
+		record := make(mappedRecord, len(c.fields))
+		var fieldErrs []domain.RecordError
+		for _, field := range c.fields {
+			raw, found := lookup(item, field)
+			if !found || raw == nil {
+				if field.def != nil {
+					record[field.target] = field.def
+				}
+				continue
+			}
+
+			coerced, err := field.coerce(raw)
+			if err != nil {
+				fieldErrs = append(fieldErrs, domain.RecordError{
+					Record: row,
+					Field:  field.target,
+					Value:  fmt.Sprint(raw),
+					Reason: err.Error(),
+				})
+				continue
+			}
+			record[field.target] = coerced
+		}
+		if len(fieldErrs) > 0 {
+			payload, _ := json.Marshal(item)
+			rejected = append(rejected, domain.QuarantinedRecord{
+				Source:  source,
+				Record:  row,
+				Payload: payload,
+				Errors:  fieldErrs,
+			})
+			continue
+		}
+		records = append(records, record)
+	}
+	return records, rejected
+}
+
+// parses a JSONPath-like expression: an optional "$" root followed by
+// dot separated keys and [n] array indexes, e.g. "$.data[0].metrics.spend"
+func parsePath(expr string) ([]pathSegment, error) {
+	expr = strings.TrimPrefix(strings.TrimSpace(expr), "$")
+	expr = strings.TrimPrefix(expr, ".")
+
+	var segments []pathSegment
+	if expr == "" {
+		return segments, nil
+	}
+
+	for _, part := range strings.Split(expr, ".") {
+		key := part
+		var indexes []int
+		if open := strings.IndexByte(part, '['); open >= 0 {
+			key = part[:open]
+			rest := part[open:]
+			for rest != "" {
+				end := strings.IndexByte(rest, ']')
+				if rest[0] != '[' || end < 0 {
+					return nil, fmt.Errorf("invalid path %q", expr)
+				}
+				n, err := strconv.Atoi(rest[1:end])
+				if err != nil || n < 0 {
+					return nil, fmt.Errorf("invalid index in path %q", expr)
+				}
+				indexes = append(indexes, n)
+				rest = rest[end+1:]
+			}
+		}
+
+		if key == "" && len(indexes) == 0 {
+			return nil, fmt.Errorf("invalid path %q", expr)
+		}
+		if key != "" {
+			segments = append(segments, pathSegment{key: key})
+		}
+		for _, n := range indexes {
+			segments = append(segments, pathSegment{index: n, isIndex: true})
+		}
+	}
+
+	return segments, nil
+}
+
+func lookupPath(value any, path []pathSegment) (any, bool) {
+	for _, segment := range path {
+		if segment.isIndex {
+			items, ok := value.([]any)
+			if !ok || segment.index >= len(items) {
+				return nil, false
+			}
+			value = items[segment.index]
+			continue
+		}
+
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if value, ok = object[segment.key]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// converts a decoded JSON value to the field's target type
+func (f compiledField) coerce(value any) (any, error) {
+	switch f.spec.kind {
+	case fieldString:
+		return coerceString(value)
+
+	case fieldInt:
+		n, err := coerceFloat(value, f.locale)
+		if err != nil {
+			return nil, err
+		}
+		if n != math.Trunc(n) {
+			return nil, fmt.Errorf("expected an integer, got %v", value)
+		}
+		return int(n), nil
+
+	case fieldMoney:
+		return coerceMoney(value, f.locale)
+
+	case fieldFloat:
+		return coerceFloat(value, f.locale)
+
+	case fieldDate:
+		return coerceDate(value, f.spec.layout, f.format)
+
+	case fieldTouches:
+		return coerceTouches(value)
+	}
+
+	return nil, fmt.Errorf("unsupported field type")
+}
+
+func coerceString(value any) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	}
+	return "", fmt.Errorf("expected a string, got %T", value)
+}
+
+// reads an array of touch objects with the upstream touch field names, or
+// such an array encoded as JSON text, as CSV cells carry it
+func coerceTouches(value any) ([]domain.Touch, error) {
+	if text, ok := value.(string); ok {
+		if err := json.Unmarshal([]byte(text), &value); err != nil {
+			return nil, fmt.Errorf("expected a JSON array of touches, got %q", text)
+		}
+	}
+	items, ok := value.([]any)
+	if !ok {
+		return nil, fmt.Errorf("expected an array of touches, got %T", value)
+	}
+	touches := make([]domain.Touch, 0, len(items))
+	for i, item := range items {
+		object, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("touch %d: expected an object, got %T", i, item)
+		}
+		var touch domain.Touch
+		for name, target := range map[string]*string{
+			"utm_campaign": &touch.UTMCampaign,
+			"utm_source":   &touch.UTMSource,
+			"utm_medium":   &touch.UTMMedium,
+			"touched_at":   &touch.TouchedAt,
+		} {
+			if raw, found := object[name]; found && raw != nil {
+				s, err := coerceString(raw)
+				if err != nil {
+					return nil, fmt.Errorf("touch %d: %s: %w", i, name, err)
+				}
+				*target = s
+			}
+		}
+		touches = append(touches, touch)
+	}
+	return touches, nil
+}
+
+// accepts JSON numbers and string encoded numbers in the given locale
+func coerceFloat(value any, locale string) (float64, error) {
+	switch v := value.(type) {
+	case json.Number:
+		return v.Float64()
+	case float64:
+		return v, nil
+	case string:
+		return parseLocaleNumber(v, locale)
+	}
+	return 0, fmt.Errorf("expected a number, got %T", value)
+}
+
+// reads an amount exactly from its decimal text, so "0.1" stays 0.1
+func coerceMoney(value any, locale string) (domain.Money, error) {
+	var text string
+	switch v := value.(type) {
+	case json.Number:
+		text = v.String()
+	case float64:
+		return domain.MoneyFromFloat(v), nil
+	case string:
+		normalized, err := normalizeLocaleNumber(v, locale)
+		if err != nil {
+			return 0, err
+		}
+		text = normalized
+	default:
+		return 0, fmt.Errorf("expected a number, got %T", value)
+	}
+
+	if m, err := domain.ParseMoney(text); err == nil {
+		return m, nil
+	}
+	// exponent notation is not a plain decimal
+	f, err := strconv.ParseFloat(text, 64)
+	if err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+		return 0, fmt.Errorf("expected a number, got %q", text)
+	}
+	return domain.MoneyFromFloat(f), nil
+}
+
+// normalizes a date to the canonical layout. Without a format the value is
+// passed through as is; "unix" reads epoch seconds.
+func coerceDate(value any, layout, format string) (string, error) {
+	switch format {
+	case "":
+		return coerceString(value)
+
+	case "unix":
+		seconds, err := coerceFloat(value, "")
+		if err != nil {
+			return "", err
+		}
+		return time.Unix(int64(seconds), 0).UTC().Format(layout), nil
+	}
+
+	s, err := coerceString(value)
+	if err != nil {
+		return "", err
+	}
+	t, err := time.Parse(format, s)
+	if err != nil {
+		return "", fmt.Errorf("expected a date in layout %q, got %q", format, s)
+	}
+	return t.Format(layout), nil
+}
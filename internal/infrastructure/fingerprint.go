@@ -0,0 +1,63 @@
+package infrastructure
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/fnv"
+	"slices"
+	"strings"
+	"sync"
+
+	"etlgo/internal/domain"
+)
+
+// hash functions of the supported fingerprint algorithms
+var fingerprintHashes = map[string]func() hash.Hash{
+	domain.FingerprintSHA256: sha256.New,
+	domain.FingerprintFNV64a: func() hash.Hash { return fnv.New64a() },
+}
+
+// implements domain.Fingerprinter with a hash function. Fingerprints are
+// prefixed with the algorithm so ones computed with a previous algorithm
+// never match. Hash states are pooled since every ingested record is
+// fingerprinted.
+type HashFingerprinter struct {
+	algorithm string
+	states    sync.Pool
+}
+
+// a hash and the buffers its digest is encoded with
+type fingerprintState struct {
+	hash    hash.Hash
+	sum     []byte
+	encoded []byte
+}
+
+// creates a fingerprinter for one of the supported algorithms
+func NewHashFingerprinter(algorithm string) (*HashFingerprinter, error) {
+	newHash, ok := fingerprintHashes[algorithm]
+	if !ok {
+		algorithms := make([]string, 0, len(fingerprintHashes))
+		for name := range fingerprintHashes {
+			algorithms = append(algorithms, name)
+		}
+		slices.Sort(algorithms)
+		return nil, fmt.Errorf("unsupported fingerprint algorithm %q, must be one of: %s", algorithm, strings.Join(algorithms, ", "))
+	}
+	f := &HashFingerprinter{algorithm: algorithm}
+	f.states.New = func() any { return &fingerprintState{hash: newHash()} }
+	return f, nil
+}
+
+func (f *HashFingerprinter) Fingerprint(fields []byte) string {
+	state := f.states.Get().(*fingerprintState)
+	defer f.states.Put(state)
+
+	state.hash.Reset()
+	state.hash.Write(fields)
+	state.sum = state.hash.Sum(state.sum[:0])
+	state.encoded = hex.AppendEncode(state.encoded[:0], state.sum)
+	return f.algorithm + ":" + string(state.encoded)
+}
@@ -0,0 +1,57 @@
+package infrastructure
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"etlgo/internal/domain"
+)
+
+// BenchmarkFingerprint encodes and fingerprints n ads rows per operation,
+// as a run does for every row it transforms
+func BenchmarkFingerprint(b *testing.B) {
+	for _, algorithm := range []string{domain.FingerprintSHA256, domain.FingerprintFNV64a} {
+		for _, n := range []int{1_000, 100_000} {
+			b.Run(fmt.Sprintf("%s/%d", algorithm, n), func(b *testing.B) {
+				fingerprinter, err := NewHashFingerprinter(algorithm)
+				if err != nil {
+					b.Fatal(err)
+				}
+				ads := benchmarkAds(n)
+				var buf bytes.Buffer
+
+				b.ReportAllocs()
+				for b.Loop() {
+					for i := range ads {
+						buf.Reset()
+						ads[i].EncodeFingerprintFields(&buf)
+						ads[i].Fingerprint = fingerprinter.Fingerprint(buf.Bytes())
+					}
+				}
+			})
+		}
+	}
+}
+
+// returns n distinct ads rows
+func benchmarkAds(n int) []domain.ProcessedAdData {
+	date := time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC)
+	ads := make([]domain.ProcessedAdData, n)
+	for i := range ads {
+		ads[i] = domain.ProcessedAdData{
+			Date:        date.AddDate(0, 0, i%90),
+			CampaignID:  fmt.Sprintf("C-%d", i%500),
+			Channel:     "google_ads",
+			Clicks:      i % 1000,
+			Impressions: i % 50000,
+			Cost:        domain.Money(i) * 12_345,
+			UTMCampaign: fmt.Sprintf("campaign_%d", i%500),
+			UTMSource:   "google",
+			UTMMedium:   "cpc",
+			AdGroupID:   fmt.Sprintf("G-%d", i%7),
+		}
+	}
+	return ads
+}
@@ -0,0 +1,104 @@
+package infrastructure
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"etlgo/internal/domain"
+	"etlgo/pkg/logger"
+)
+
+// implements domain.FXRateRepository interface in memory
+type FXRateRepository struct {
+	// rates per currency, ordered by date
+	rates  map[string][]domain.FXRate
+	mutex  sync.RWMutex
+	logger *logger.Logger
+}
+
+// creates a new FX rate repository
+func NewFXRateRepository(logger *logger.Logger) *FXRateRepository {
+	return &FXRateRepository{
+		rates:  make(map[string][]domain.FXRate),
+		logger: logger,
+	}
+}
+
+func (r *FXRateRepository) Put(ctx context.Context, rates []domain.FXRate) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, rate := range rates {
+		stored := r.rates[rate.Currency]
+		i, found := slices.BinarySearchFunc(stored, rate.Date, compareRateDate)
+		if found {
+			stored[i] = rate
+			continue
+		}
+		r.rates[rate.Currency] = slices.Insert(stored, i, rate)
+	}
+
+	r.logger.WithContext(ctx).WithField("count", len(rates)).Info("Stored FX rates")
+	return nil
+}
+
+func (r *FXRateRepository) RateOn(ctx context.Context, currency string, date time.Time) (*domain.FXRate, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	stored := r.rates[currency]
+	i, found := slices.BinarySearchFunc(stored, date.UTC().Truncate(24*time.Hour), compareRateDate)
+	if found {
+		rate := stored[i]
+		return &rate, nil
+	}
+	if i == 0 {
+		return nil, fmt.Errorf("%w of %s on or before %s", domain.ErrNoFXRate, currency, date.Format("2006-01-02"))
+	}
+	rate := stored[i-1]
+	return &rate, nil
+}
+
+func (r *FXRateRepository) List(ctx context.Context, currency string, from, to time.Time) ([]domain.FXRate, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	result := make([]domain.FXRate, 0)
+	for _, rate := range r.rates[currency] {
+		if !rate.Date.Before(from.Truncate(24*time.Hour)) && !rate.Date.After(to) {
+			result = append(result, rate)
+		}
+	}
+	return result, nil
+}
+
+func compareRateDate(rate domain.FXRate, date time.Time) int {
+	return rate.Date.Compare(date)
+}
+
+// loads FX rates from a JSON array. An empty path loads none.
+func LoadFXRates(path string) ([]domain.FXRate, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read FX rate file: %w", err)
+	}
+	var rates []domain.FXRate
+	if err := json.Unmarshal(raw, &rates); err != nil {
+		return nil, fmt.Errorf("failed to parse FX rate file: %w", err)
+	}
+	for i := range rates {
+		if err := rates[i].Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return rates, nil
+}
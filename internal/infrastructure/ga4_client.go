@@ -0,0 +1,404 @@
+package infrastructure
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"etlgo/internal/domain"
+	"etlgo/pkg/logger"
+	"etlgo/pkg/metrics"
+)
+
+const (
+	// read-only scope requested for the service account
+	ga4Scope = "https://www.googleapis.com/auth/analytics.readonly"
+
+	// rows requested per runReport call
+	ga4PageSize = 10000
+
+	// lifetime requested for access tokens; they are refreshed a minute
+	// before they expire
+	ga4TokenLifetime = time.Hour
+)
+
+// report dimensions, in the order of a row's dimension values
+var ga4Dimensions = []string{"date", "sessionCampaignName", "sessionSource", "sessionMedium"}
+
+// the fields of a service account key file used to authenticate
+type ga4Credentials struct {
+	ClientEmail  string `json:"client_email"`
+	PrivateKeyID string `json:"private_key_id"`
+	PrivateKey   string `json:"private_key"`
+	TokenURI     string `json:"token_uri"`
+}
+
+// fetches sessions and conversions by day, campaign, source and medium from
+// the Google Analytics 4 Data API, authenticating as a service account.
+// Implements domain.AnalyticsClient.
+type GA4Client struct {
+	client           *http.Client
+	apiURL           string
+	propertyID       string
+	conversionMetric string
+	credentials      ga4Credentials
+	key              *rsa.PrivateKey
+	token            string
+	tokenExpiry      time.Time
+	mutex            sync.Mutex
+	prewarm          bool
+	logger           *logger.Logger
+	metrics          *metrics.Metrics
+}
+
+// creates a GA4 client for the property with the service account key file,
+// or returns nil when no property is configured. Requests go through the
+// cassette when one is given.
+func NewGA4Client(propertyID, credentialsFile, apiURL, conversionMetric string, transport TransportOptions, cassette *Cassette, timeout time.Duration, logger *logger.Logger, metrics *metrics.Metrics) (*GA4Client, error) {
+	if propertyID == "" {
+		return nil, nil
+	}
+	if credentialsFile == "" {
+		return nil, fmt.Errorf("GA4 property %s configured without a service account credentials file", propertyID)
+	}
+
+	raw, err := os.ReadFile(credentialsFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read GA4 credentials: %w", err)
+	}
+	var credentials ga4Credentials
+	if err := json.Unmarshal(raw, &credentials); err != nil {
+		return nil, fmt.Errorf("invalid GA4 credentials file: %w", err)
+	}
+	if credentials.ClientEmail == "" || credentials.PrivateKey == "" || credentials.TokenURI == "" {
+		return nil, fmt.Errorf("invalid GA4 credentials file: client_email, private_key and token_uri are required")
+	}
+	key, err := parseRSAPrivateKey(credentials.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid GA4 credentials file: %w", err)
+	}
+
+	client := &http.Client{Timeout: timeout, Transport: NewUpstreamTransport(transport)}
+	if cassette != nil {
+		client.Transport = cassette.Wrap(client.Transport)
+	}
+
+	return &GA4Client{
+		client:           client,
+		apiURL:           strings.TrimSuffix(apiURL, "/"),
+		propertyID:       propertyID,
+		conversionMetric: conversionMetric,
+		credentials:      credentials,
+		key:              key,
+		prewarm:          transport.Prewarm && !cassette.Replaying(),
+		logger:           logger,
+		metrics:          metrics,
+	}, nil
+}
+
+// opens connections to the token endpoint and the Data API
+func (c *GA4Client) Prewarm(ctx context.Context) {
+	if !c.prewarm {
+		return
+	}
+	prewarmUpstreams(ctx, c.client, map[string]string{"ga4_token": c.credentials.TokenURI, "ga4": c.apiURL}, c.logger, c.metrics)
+}
+
+// parses a PEM encoded PKCS #8 or PKCS #1 RSA key
+func parseRSAPrivateKey(value string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(value))
+	if block == nil {
+		return nil, fmt.Errorf("private_key is not PEM encoded")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private_key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private_key is not an RSA key")
+	}
+	return key, nil
+}
+
+// GA4 runReport request and response bodies
+type ga4ReportRequest struct {
+	DateRanges []ga4DateRange `json:"dateRanges"`
+	Dimensions []ga4Named     `json:"dimensions"`
+	Metrics    []ga4Named     `json:"metrics"`
+	Limit      string         `json:"limit"`
+	Offset     string         `json:"offset"`
+}
+
+type ga4DateRange struct {
+	StartDate string `json:"startDate"`
+	EndDate   string `json:"endDate"`
+}
+
+type ga4Named struct {
+	Name string `json:"name"`
+}
+
+type ga4ReportResponse struct {
+	Rows []struct {
+		DimensionValues []ga4Value `json:"dimensionValues"`
+		MetricValues    []ga4Value `json:"metricValues"`
+	} `json:"rows"`
+	RowCount int `json:"rowCount"`
+}
+
+type ga4Value struct {
+	Value string `json:"value"`
+}
+
+// fetches the report of the days from and to, page by page
+func (c *GA4Client) FetchAnalyticsData(ctx context.Context, from, to time.Time) (*domain.AnalyticsData, error) {
+	start := time.Now()
+	data := &domain.AnalyticsData{}
+
+	for offset := 0; ; offset += ga4PageSize {
+		page, err := c.runReport(ctx, from, to, offset)
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range page.Rows {
+			dimensions := make([]string, len(ga4Dimensions))
+			for i := range dimensions {
+				if i < len(row.DimensionValues) {
+					dimensions[i] = row.DimensionValues[i].Value
+				}
+			}
+			record := strings.Join(dimensions, "|")
+
+			var metricValues [2]int
+			var errs []domain.RecordError
+			for i, name := range []string{"sessions", c.conversionMetric} {
+				value := ""
+				if i < len(row.MetricValues) {
+					value = row.MetricValues[i].Value
+				}
+				parsed, err := strconv.ParseFloat(value, 64)
+				if err != nil {
+					errs = append(errs, domain.RecordError{Record: record, Field: name, Value: value, Reason: "not a number"})
+					continue
+				}
+				metricValues[i] = int(parsed)
+			}
+			if len(errs) > 0 {
+				payload, _ := json.Marshal(row)
+				data.Rejected = append(data.Rejected, domain.QuarantinedRecord{
+					Source:  domain.SourceGA4,
+					Record:  record,
+					Payload: payload,
+					Errors:  errs,
+				})
+				continue
+			}
+
+			data.Rows = append(data.Rows, domain.AnalyticsRow{
+				Date:        dimensions[0],
+				UTMCampaign: dimensions[1],
+				UTMSource:   dimensions[2],
+				UTMMedium:   dimensions[3],
+				Sessions:    metricValues[0],
+				Conversions: metricValues[1],
+			})
+		}
+		if offset+ga4PageSize >= page.RowCount {
+			break
+		}
+	}
+
+	duration := time.Since(start)
+	c.metrics.RecordExternalAPICall("ga4", "success", duration)
+	c.logger.WithContext(ctx).WithFields(map[string]any{
+		"property": c.propertyID,
+		"duration": duration,
+		"records":  len(data.Rows),
+	}).Info("Successfully fetched GA4 data")
+
+	return data, nil
+}
+
+// requests one page of the report
+func (c *GA4Client) runReport(ctx context.Context, from, to time.Time, offset int) (*ga4ReportResponse, error) {
+	token, err := c.accessToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	request := ga4ReportRequest{
+		DateRanges: []ga4DateRange{{StartDate: from.Format("2006-01-02"), EndDate: to.Format("2006-01-02")}},
+		Metrics:    []ga4Named{{Name: "sessions"}, {Name: c.conversionMetric}},
+		Limit:      strconv.Itoa(ga4PageSize),
+		Offset:     strconv.Itoa(offset),
+	}
+	for _, name := range ga4Dimensions {
+		request.Dimensions = append(request.Dimensions, ga4Named{Name: name})
+	}
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal GA4 report request: %w", err)
+	}
+
+	endpoint := fmt.Sprintf("%s/properties/%s:runReport", c.apiURL, url.PathEscape(c.propertyID))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		c.metrics.RecordExternalAPIFailure("ga4", "request_creation")
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	// Token exchanges are not GA4 API calls, only reports count
+	if err := domain.ConsumeUpstreamCall(ctx, domain.SourceGA4); err != nil {
+		return nil, err
+	}
+	body, err := c.do(ctx, req, "report")
+	if err != nil {
+		return nil, err
+	}
+
+	var page ga4ReportResponse
+	if err := json.Unmarshal(body, &page); err != nil {
+		c.metrics.RecordExternalAPIFailure("ga4", "json_parse")
+		return nil, domain.Errorf(domain.ErrUpstreamUnavailable, "failed to parse GA4 report: %w", err)
+	}
+	return &page, nil
+}
+
+// returns a cached access token, exchanging a signed JWT assertion for a new
+// one when it is about to expire
+func (c *GA4Client) accessToken(ctx context.Context) (string, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.token != "" && time.Until(c.tokenExpiry) > time.Minute {
+		return c.token, nil
+	}
+
+	now := time.Now()
+	assertion, err := c.signAssertion(now)
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.credentials.TokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		c.metrics.RecordExternalAPIFailure("ga4", "request_creation")
+		return "", fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	body, err := c.do(ctx, req, "token")
+	if err != nil {
+		return "", err
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &token); err != nil || token.AccessToken == "" {
+		c.metrics.RecordExternalAPIFailure("ga4", "token")
+		return "", domain.Errorf(domain.ErrUpstreamUnavailable, "GA4 token endpoint returned no access token")
+	}
+
+	c.token = token.AccessToken
+	c.tokenExpiry = now.Add(time.Duration(token.ExpiresIn) * time.Second)
+	return c.token, nil
+}
+
+// returns an RS256 signed JWT asserting the service account's identity
+func (c *GA4Client) signAssertion(now time.Time) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": c.credentials.PrivateKeyID})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]any{
+		"iss":   c.credentials.ClientEmail,
+		"scope": ga4Scope,
+		"aud":   c.credentials.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(ga4TokenLifetime).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	sum := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, c.key, crypto.SHA256, sum[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign GA4 token assertion: %w", err)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// sends a request and returns its body, failing on non 200 responses
+func (c *GA4Client) do(ctx context.Context, req *http.Request, call string) ([]byte, error) {
+	start := time.Now()
+	meter := domain.CostMeterFromContext(ctx)
+	meter.AddAPICall()
+
+	resp, err := c.client.Do(traceUpstream(req, "ga4", c.metrics))
+	if err != nil {
+		c.metrics.RecordExternalAPIFailure("ga4", "network_error")
+		return nil, domain.Errorf(domain.ErrUpstreamUnavailable, "failed to reach GA4 %s endpoint: %w", call, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	meter.AddDownload(int64(len(body)))
+	if err != nil {
+		c.metrics.RecordExternalAPIFailure("ga4", "read_body")
+		return nil, domain.Errorf(domain.ErrUpstreamUnavailable, "failed to read GA4 %s response: %w", call, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		c.metrics.RecordExternalAPICall("ga4", fmt.Sprintf("error_%d", resp.StatusCode), time.Since(start))
+		if resp.StatusCode == http.StatusUnauthorized && call == "report" {
+			// the token was revoked; the next request signs in again
+			c.mutex.Lock()
+			c.token = ""
+			c.mutex.Unlock()
+		}
+		var apiErr struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+			Description string `json:"error_description"`
+		}
+		_ = json.Unmarshal(body, &apiErr)
+		detail := cmp.Or(apiErr.Error.Message, apiErr.Description)
+		if detail != "" {
+			detail = ": " + detail
+		}
+		return nil, domain.Errorf(domain.ErrUpstreamUnavailable, "GA4 %s endpoint returned status %d%s", call, resp.StatusCode, detail)
+	}
+	return body, nil
+}
@@ -0,0 +1,127 @@
+package infrastructure
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+
+	"etlgo/internal/domain"
+)
+
+// outcomes of hedged requests
+const (
+	hedgeWon       = "won"       // the hedge answered first
+	hedgeLost      = "lost"      // the first request answered first
+	hedgeFailed    = "failed"    // both requests failed
+	hedgeThrottled = "throttled" // the rate limiter had no room for a hedge
+)
+
+// the response or error of one attempt of a hedged request
+type hedgeAttempt struct {
+	resp  *http.Response
+	err   error
+	hedge bool
+}
+
+// reports whether the attempt is worth waiting for the other one: it
+// failed to connect or the upstream failed
+func (a hedgeAttempt) failed() bool {
+	return a.err != nil || a.resp.StatusCode >= http.StatusInternalServerError
+}
+
+// sends an idempotent GET to the upstream API. When no response arrived
+// after the hedge delay a second request is sent, if the API's rate limiter
+// has room; the first success is returned and the other request cancelled.
+func (c *HTTPClient) doHedged(req *http.Request, api string, limiter *upstreamLimiter) (*http.Response, error) {
+	if c.hedgeDelay <= 0 || req.Method != http.MethodGet {
+		return c.upstream.Do(traceUpstream(req, api, c.metrics))
+	}
+
+	ctx := req.Context()
+	results := make(chan hedgeAttempt, 2)
+	cancels := make(map[bool]context.CancelFunc, 2)
+	send := func(hedge bool) {
+		attemptCtx, cancel := context.WithCancel(ctx)
+		cancels[hedge] = cancel
+		go func() {
+			resp, err := c.upstream.Do(traceUpstream(req.Clone(attemptCtx), api, c.metrics))
+			results <- hedgeAttempt{resp: resp, err: err, hedge: hedge}
+		}()
+	}
+
+	send(false)
+	timer := time.NewTimer(c.hedgeDelay)
+	defer timer.Stop()
+	pending, hedged := 1, false
+	for {
+		select {
+		case <-timer.C:
+			// A hedge is a request of its own, sent only within the rate
+			// limit and the call quota
+			if !limiter.limiter.Allow() || domain.ConsumeUpstreamCall(ctx, api) != nil {
+				c.metrics.RecordUpstreamHedge(api, hedgeThrottled)
+				continue
+			}
+			domain.CostMeterFromContext(ctx).AddAPICall()
+			send(true)
+			pending, hedged = pending+1, true
+		case attempt := <-results:
+			pending--
+			if attempt.failed() && pending > 0 {
+				if attempt.resp != nil {
+					attempt.resp.Body.Close()
+				}
+				cancels[attempt.hedge]()
+				continue
+			}
+			// The first request may fail before the hedge is due
+			timer.Stop()
+
+			if hedged {
+				outcome := hedgeLost
+				switch {
+				case attempt.failed():
+					outcome = hedgeFailed
+				case attempt.hedge:
+					outcome = hedgeWon
+				}
+				c.metrics.RecordUpstreamHedge(api, outcome)
+			}
+			for hedge, cancel := range cancels {
+				if hedge != attempt.hedge {
+					cancel()
+				}
+			}
+			go drainHedges(results, pending)
+
+			if attempt.err != nil {
+				cancels[attempt.hedge]()
+				return nil, attempt.err
+			}
+			attempt.resp.Body = &cancelOnClose{ReadCloser: attempt.resp.Body, cancel: cancels[attempt.hedge]}
+			return attempt.resp, nil
+		}
+	}
+}
+
+// closes the responses of cancelled attempts that still answered
+func drainHedges(results <-chan hedgeAttempt, pending int) {
+	for range pending {
+		if attempt := <-results; attempt.resp != nil {
+			attempt.resp.Body.Close()
+		}
+	}
+}
+
+// a response body releasing the context of its request when closed
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
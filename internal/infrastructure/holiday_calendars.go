@@ -0,0 +1,33 @@
+package infrastructure
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"etlgo/internal/domain"
+)
+
+// loads holiday calendars from a JSON file keyed by calendar name, usually a
+// locale such as "us" or "de". An empty path configures no calendars.
+func LoadHolidayCalendars(path string) (map[string]domain.HolidayCalendar, error) {
+	calendars := map[string]domain.HolidayCalendar{}
+	if path == "" {
+		return calendars, nil
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read holiday calendar file: %w", err)
+	}
+	if err := json.Unmarshal(raw, &calendars); err != nil {
+		return nil, fmt.Errorf("failed to parse holiday calendar file: %w", err)
+	}
+	for name, calendar := range calendars {
+		if err := calendar.Validate(); err != nil {
+			return nil, fmt.Errorf("holiday calendar %q: %w", name, err)
+		}
+	}
+
+	return calendars, nil
+}
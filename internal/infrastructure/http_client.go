@@ -6,225 +6,364 @@ import (
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
-	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"time"
 
 	"etlgo/internal/domain"
 	"etlgo/pkg/logger"
 	"etlgo/pkg/metrics"
-
-	"golang.org/x/time/rate"
 )
 
 // implements ExternalAPIClient interface
 type HTTPClient struct {
 	client      *http.Client
+	upstream    *http.Client // fetches from the ads and CRM APIs
 	adsURL      string
 	crmURL      string
+	keywordsURL string // optional keyword level ads feed
+	sinceParam  string // query parameter asking the upstream APIs for newer records
 	sinkURL     string
 	sinkSecret  string
 	logger      *logger.Logger
 	metrics     *metrics.Metrics
-	rateLimiter rate.Limiter
+	adsLimiter  *upstreamLimiter
+	crmLimiter  *upstreamLimiter
+	kwLimiter   *upstreamLimiter
+	sinkLimiter *upstreamLimiter
+	sinkOptions SinkOptions
+	progress    *chunkProgress
+	mapper      *FieldMapper
+	prewarm     bool
+	hedgeDelay  time.Duration
+	headers     map[string]string // sent with every upstream fetch
+	format      string            // of upstream responses, json when empty
 }
 
-// creates a new HTTP client
-func NewHTTPClient(adsURL, crmURL, sinkURL, sinkSecret string, timeout time.Duration, logger *logger.Logger, metrics *metrics.Metrics) *HTTPClient {
+// creates a new HTTP client. Upstream fetches go through the cassette when
+// one is given; replaying cassettes opens no connections to prewarm, and
+// fetches through a cassette are never hedged. An empty sinceParam fetches
+// everything and leaves filtering to the run.
+func NewHTTPClient(adsURL, crmURL, keywordsURL, sinceParam, sinkURL, sinkSecret string, sinkOptions SinkOptions, transport TransportOptions, rateLimits RateLimitOptions, cassette *Cassette, mapper *FieldMapper, timeout time.Duration, logger *logger.Logger, metrics *metrics.Metrics) *HTTPClient {
+	client := &http.Client{
+		Timeout:   timeout,
+		Transport: NewUpstreamTransport(transport),
+	}
+	upstream := client
+	hedgeDelay := transport.HedgeDelay
+	if cassette != nil {
+		upstream = &http.Client{Timeout: timeout, Transport: cassette.Wrap(client.Transport)}
+		hedgeDelay = 0
+	}
+
 	return &HTTPClient{
-		client: &http.Client{
-			Timeout: timeout,
-			Transport: &http.Transport{
-				MaxIdleConns:        100,
-				MaxIdleConnsPerHost: 10,
-				IdleConnTimeout:     90 * time.Second,
-			},
-		},
+		client:      client,
+		upstream:    upstream,
 		adsURL:      adsURL,
 		crmURL:      crmURL,
+		keywordsURL: keywordsURL,
+		sinceParam:  sinceParam,
 		sinkURL:     sinkURL,
 		sinkSecret:  sinkSecret,
 		logger:      logger,
 		metrics:     metrics,
-		rateLimiter: *rate.NewLimiter(rate.Limit(100), 10),
+		adsLimiter:  newUpstreamLimiter("ads", rateLimits, metrics),
+		crmLimiter:  newUpstreamLimiter("crm", rateLimits, metrics),
+		kwLimiter:   newUpstreamLimiter("keywords", rateLimits, metrics),
+		sinkLimiter: newUpstreamLimiter("sink", rateLimits, metrics),
+		sinkOptions: sinkOptions,
+		progress:    newChunkProgress(),
+		mapper:      mapper,
+		prewarm:     transport.Prewarm && !cassette.Replaying(),
+		hedgeDelay:  hedgeDelay,
 	}
 }
 
-// fetches ads data from external API
-func (c *HTTPClient) FetchAdsData(ctx context.Context) (*domain.AdData, error) {
-	start := time.Now()
+// opens connections to the ads and CRM APIs
+func (c *HTTPClient) Prewarm(ctx context.Context) {
+	if !c.prewarm {
+		return
+	}
+	prewarmUpstreams(ctx, c.client, map[string]string{"ads": c.adsURL, "crm": c.crmURL}, c.logger, c.metrics)
+}
 
-	// Apply rate limiting
-	if err := c.rateLimiter.Wait(ctx); err != nil {
-		c.metrics.RecordExternalAPIFailure("ads", "rate_limit")
-		return nil, fmt.Errorf("rate limit exceeded: %w", err)
+// Keywords returns the client of the keyword feed, or nil when no feed is
+// configured
+func (c *HTTPClient) Keywords() domain.KeywordClient {
+	if c.keywordsURL == "" {
+		return nil
 	}
+	return keywordFeed{c}
+}
 
-	req, err := http.NewRequestWithContext(ctx, "GET", c.adsURL, nil)
+// adds the since query parameter to an upstream URL when the client sends
+// one
+func (c *HTTPClient) sinceURL(rawURL string, since *time.Time) string {
+	if c.sinceParam == "" || since == nil {
+		return rawURL
+	}
+	parsed, err := url.Parse(rawURL)
 	if err != nil {
-		c.metrics.RecordExternalAPIFailure("ads", "request_creation")
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return rawURL
 	}
+	query := parsed.Query()
+	query.Set(c.sinceParam, since.Format("2006-01-02"))
+	parsed.RawQuery = query.Encode()
+	return parsed.String()
+}
 
-	req.Header.Set("Accept", "application/json")
+// fetches ads data from external API
+func (c *HTTPClient) FetchAdsData(ctx context.Context, since *time.Time) (*domain.AdData, error) {
+	page, err := c.fetchPages(ctx, domain.SourceAds, "ads", c.adsURL, since, c.adsLimiter)
+	if err != nil {
+		return nil, err
+	}
+	return page.adData(), nil
+}
 
-	resp, err := c.client.Do(req)
+// fetches CRM data from external API
+func (c *HTTPClient) FetchCRMData(ctx context.Context, since *time.Time) (*domain.CRMData, error) {
+	page, err := c.fetchPages(ctx, domain.SourceCRM, "CRM", c.crmURL, since, c.crmLimiter)
 	if err != nil {
-		c.metrics.RecordExternalAPIFailure("ads", "network_error")
-		return nil, fmt.Errorf("failed to fetch ads data: %w", err)
+		return nil, err
 	}
-	defer resp.Body.Close()
+	return page.crmData(), nil
+}
 
-	duration := time.Since(start)
+// fetches every page of the source from its API. Each page is decoded as
+// soon as it arrives, so only its records are kept, not its body.
+func (c *HTTPClient) fetchPages(ctx context.Context, source, name, rawURL string, since *time.Time, limiter *upstreamLimiter) (mappedPage, error) {
+	start := time.Now()
 
-	if resp.StatusCode != http.StatusOK {
-		c.metrics.RecordExternalAPICall("ads", fmt.Sprintf("error_%d", resp.StatusCode), duration)
-		return nil, fmt.Errorf("ads API returned status %d", resp.StatusCode)
+	var result mappedPage
+	pagination := c.mapper.pagination(source)
+	if c.format != "" && c.format != domain.ConnectorFormatJSON {
+		pagination = nil
 	}
-
-	body, err := io.ReadAll(resp.Body)
+	pager, err := newPager(pagination, c.sinceURL(rawURL, since))
 	if err != nil {
-		c.metrics.RecordExternalAPIFailure("ads", "read_body")
-		return nil, fmt.Errorf("failed to read response body: %w", err)
+		c.metrics.RecordExternalAPIFailure(source, "request_creation")
+		return result, fmt.Errorf("failed to create request: %w", err)
 	}
-
-	var adData domain.AdData
-	if err := json.Unmarshal(body, &adData); err != nil {
-		c.metrics.RecordExternalAPIFailure("ads", "json_parse")
-		return nil, fmt.Errorf("failed to parse ads data: %w", err)
+	for {
+		pageURL, ok, err := pager.nextURL()
+		if err != nil {
+			c.metrics.RecordExternalAPIFailure(source, "pagination")
+			return result, domain.Errorf(domain.ErrUpstreamUnavailable, "%s API returned %w", name, err)
+		}
+		if !ok {
+			break
+		}
+
+		page, header, err := c.fetchPage(ctx, source, name, pageURL, result.len(), limiter)
+		if err != nil {
+			return result, err
+		}
+		result.add(page)
+		domain.Beat(ctx)
+
+		if err := pager.advance(pageURL, header, page); err != nil {
+			c.metrics.RecordExternalAPIFailure(source, "pagination")
+			return result, domain.Errorf(domain.ErrUpstreamUnavailable, "failed to paginate %s data: %w", name, err)
+		}
 	}
 
-	c.metrics.RecordExternalAPICall("ads", "success", duration)
-
-	c.logger.WithContext(ctx).WithFields(map[string]interface{}{
-		"url":      c.adsURL,
-		"duration": duration,
-		"records":  len(adData.External.Ads.Performance),
-	}).Info("Successfully fetched ads data")
+	c.logger.WithContext(ctx).WithFields(map[string]any{
+		"url":      rawURL,
+		"duration": time.Since(start),
+		"pages":    pager.pages,
+		"records":  len(result.records),
+	}).Info(fmt.Sprintf("Successfully fetched %s data", name))
 
-	return &adData, nil
+	return result, nil
 }
 
-// fetches CRM data from external API
-func (c *HTTPClient) FetchCRMData(ctx context.Context) (*domain.CRMData, error) {
+// fetches and decodes one page of the source, numbering its rows from
+// offset
+func (c *HTTPClient) fetchPage(ctx context.Context, source, name, pageURL string, offset int, limiter *upstreamLimiter) (mappedPage, http.Header, error) {
 	start := time.Now()
 
 	// Apply rate limiting
-	if err := c.rateLimiter.Wait(ctx); err != nil {
-		c.metrics.RecordExternalAPIFailure("crm", "rate_limit")
-		return nil, fmt.Errorf("rate limit exceeded: %w", err)
+	if err := limiter.Wait(ctx); err != nil {
+		c.metrics.RecordExternalAPIFailure(source, "rate_limit")
+		return mappedPage{}, nil, fmt.Errorf("rate limit exceeded: %w", err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "GET", c.crmURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", pageURL, nil)
 	if err != nil {
-		c.metrics.RecordExternalAPIFailure("crm", "request_creation")
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		c.metrics.RecordExternalAPIFailure(source, "request_creation")
+		return mappedPage{}, nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Set("Accept", "application/json")
+	switch c.format {
+	case domain.ConnectorFormatNDJSON:
+		req.Header.Set("Accept", "application/x-ndjson")
+	case domain.ConnectorFormatCSV:
+		req.Header.Set("Accept", "text/csv")
+	default:
+		req.Header.Set("Accept", "application/json")
+	}
+	for key, value := range c.headers {
+		req.Header.Set(key, value)
+	}
 
-	resp, err := c.client.Do(req)
+	if err := domain.ConsumeUpstreamCall(ctx, source); err != nil {
+		return mappedPage{}, nil, err
+	}
+	meter := domain.CostMeterFromContext(ctx)
+	meter.AddAPICall()
+	resp, err := c.doHedged(req, source, limiter)
 	if err != nil {
-		c.metrics.RecordExternalAPIFailure("crm", "network_error")
-		return nil, fmt.Errorf("failed to fetch CRM data: %w", err)
+		c.metrics.RecordExternalAPIFailure(source, "network_error")
+		return mappedPage{}, nil, domain.Errorf(domain.ErrUpstreamUnavailable, "failed to fetch %s data: %w", name, err)
 	}
 	defer resp.Body.Close()
 
 	duration := time.Since(start)
 
 	if resp.StatusCode != http.StatusOK {
-		c.metrics.RecordExternalAPICall("crm", fmt.Sprintf("error_%d", resp.StatusCode), duration)
-		return nil, fmt.Errorf("CRM API returned status %d", resp.StatusCode)
+		c.metrics.RecordExternalAPICall(source, fmt.Sprintf("error_%d", resp.StatusCode), duration)
+		return mappedPage{}, nil, domain.Errorf(domain.ErrUpstreamUnavailable, "%s API returned status %d", name, resp.StatusCode)
 	}
 
 	body, err := io.ReadAll(resp.Body)
+	meter.AddDownload(int64(len(body)))
 	if err != nil {
-		c.metrics.RecordExternalAPIFailure("crm", "read_body")
-		return nil, fmt.Errorf("failed to read response body: %w", err)
+		c.metrics.RecordExternalAPIFailure(source, "read_body")
+		return mappedPage{}, nil, domain.Errorf(domain.ErrUpstreamUnavailable, "failed to read response body: %w", err)
 	}
 
-	var crmData domain.CRMData
-	if err := json.Unmarshal(body, &crmData); err != nil {
-		c.metrics.RecordExternalAPIFailure("crm", "json_parse")
-		return nil, fmt.Errorf("failed to parse CRM data: %w", err)
+	page, err := c.decode(source, body, offset)
+	if err != nil {
+		c.metrics.RecordExternalAPIFailure(source, "json_parse")
+		return mappedPage{}, nil, domain.Errorf(domain.ErrUpstreamUnavailable, "failed to parse %s data: %w", name, err)
 	}
 
-	c.metrics.RecordExternalAPICall("crm", "success", duration)
+	c.metrics.RecordExternalAPICall(source, "success", duration)
+	return page, resp.Header, nil
+}
 
-	c.logger.WithContext(ctx).WithFields(map[string]any{
-		"url":      c.crmURL,
-		"duration": duration,
-		"records":  len(crmData.External.CRM.Opportunities),
-	}).Info("Successfully fetched CRM data")
+// decodes a response of the source in the client's format
+func (c *HTTPClient) decode(source string, body []byte, offset int) (mappedPage, error) {
+	switch c.format {
+	case domain.ConnectorFormatNDJSON:
+		return c.mapper.decodeNDJSON(source, bytes.NewReader(body), offset)
+	case domain.ConnectorFormatCSV:
+		return c.mapper.decodeCSV(source, bytes.NewReader(body), offset)
+	}
+	return c.mapper.decode(source, body, offset)
+}
+
+// implements domain.KeywordClient with the HTTP client's keyword feed
+type keywordFeed struct {
+	c *HTTPClient
+}
 
-	return &crmData, nil
+// opens connections to the keyword feed
+func (f keywordFeed) Prewarm(ctx context.Context) {
+	if !f.c.prewarm {
+		return
+	}
+	prewarmUpstreams(ctx, f.c.client, map[string]string{"keywords": f.c.keywordsURL}, f.c.logger, f.c.metrics)
+}
+
+// fetches keyword level ads data from the keyword feed
+func (f keywordFeed) FetchKeywordData(ctx context.Context, since *time.Time) (*domain.KeywordData, error) {
+	page, err := f.c.fetchPages(ctx, domain.SourceKeywords, "keywords", f.c.keywordsURL, since, f.c.kwLimiter)
+	if err != nil {
+		return nil, err
+	}
+	return page.keywordData(), nil
 }
 
 // implements ExportClient interface
 func (c *HTTPClient) Export(ctx context.Context, data []domain.ExportData, date time.Time) error {
 	if c.sinkURL == "" {
-		return fmt.Errorf("sink URL not configured")
+		return domain.ErrSinkNotConfigured
 	}
 
 	start := time.Now()
 
-	// Apply rate limiting
-	if err := c.rateLimiter.Wait(ctx); err != nil {
-		c.metrics.RecordExternalAPIFailure("sink", "rate_limit")
-		return fmt.Errorf("rate limit exceeded: %w", err)
-	}
-
-	payload, err := json.Marshal(data)
+	chunks, err := chunkExportData(ctx, data, c.sinkOptions.ChunkSize, c.sinkOptions.Workers)
 	if err != nil {
 		c.metrics.RecordExternalAPIFailure("sink", "json_marshal")
 		return fmt.Errorf("failed to marshal export data: %w", err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", c.sinkURL, bytes.NewReader(payload))
-	if err != nil {
-		c.metrics.RecordExternalAPIFailure("sink", "request_creation")
-		return fmt.Errorf("failed to create request: %w", err)
+	exportID := "metrics_" + date.Format("2006-01-02")
+	if err := c.deliver(ctx, c.sink(), exportID, "application/json", nil, chunks); err != nil {
+		return fmt.Errorf("failed to export data: %w", err)
 	}
 
-	req.Header.Set("Content-Type", "application/json")
+	c.logger.WithContext(ctx).WithFields(map[string]any{
+		"url":      c.sinkURL,
+		"duration": time.Since(start),
+		"records":  len(data),
+		"chunks":   len(chunks),
+		"date":     date.Format("2006-01-02"),
+	}).Info("Successfully exported data")
 
-	// Add HMAC signature if secret is provided
-	if c.sinkSecret != "" {
-		signature := c.generateHMACSignature(payload)
-		req.Header.Set("X-Signature", signature)
-	}
+	return nil
+}
 
-	resp, err := c.client.Do(req)
-	if err != nil {
-		c.metrics.RecordExternalAPIFailure("sink", "network_error")
-		return fmt.Errorf("failed to export data: %w", err)
+// delivers export records shaped for a destination to its URL, or to the
+// sink when the destination has none
+func (c *HTTPClient) ExportTo(ctx context.Context, destination domain.ExportDestination, exportID string, records []domain.ExportRecord) error {
+	target := domain.SinkTarget{URL: destination.URL, Secret: destination.Secret, Compression: destination.Compression}
+	var headers map[string]string
+	if destination.URL == "" {
+		if c.sinkURL == "" {
+			return domain.ErrSinkNotConfigured
+		}
+		target = c.sink()
+	} else {
+		headers = map[string]string{"X-Export-Destination": destination.Name}
 	}
-	defer resp.Body.Close()
 
-	duration := time.Since(start)
+	start := time.Now()
 
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		c.metrics.RecordExternalAPICall("sink", fmt.Sprintf("error_%d", resp.StatusCode), duration)
-		return fmt.Errorf("sink API returned status %d", resp.StatusCode)
+	chunks, err := chunkExportData(ctx, records, c.sinkOptions.ChunkSize, c.sinkOptions.Workers)
+	if err != nil {
+		c.metrics.RecordExternalAPIFailure("sink", "json_marshal")
+		return fmt.Errorf("failed to marshal export data: %w", err)
 	}
 
-	c.metrics.RecordExternalAPICall("sink", "success", duration)
+	if err := c.deliver(ctx, target, exportID, "application/json", headers, chunks); err != nil {
+		return fmt.Errorf("failed to export %s: %w", exportID, err)
+	}
 
 	c.logger.WithContext(ctx).WithFields(map[string]any{
-		"url":      c.sinkURL,
-		"duration": duration,
-		"records":  len(data),
-		"date":     date.Format("2006-01-02"),
+		"destination": destination.Name,
+		"url":         target.URL,
+		"export_id":   exportID,
+		"duration":    time.Since(start),
+		"records":     len(records),
+		"chunks":      len(chunks),
 	}).Info("Successfully exported data")
 
 	return nil
 }
 
-// generates HMAC-SHA256 signature for the payload
-func (c *HTTPClient) generateHMACSignature(payload []byte) string {
-	h := hmac.New(sha256.New, []byte(c.sinkSecret))
+// delivers an encoded export file to the sink and returns its location
+func (c *HTTPClient) ExportFile(ctx context.Context, filename, contentType string, payload []byte) (string, error) {
+	if c.sinkURL == "" {
+		return "", domain.ErrSinkNotConfigured
+	}
+
+	headers := map[string]string{"X-Export-Filename": filename}
+	chunks := splitPayload(payload, c.sinkOptions.ChunkSize)
+
+	if err := c.deliver(ctx, c.sink(), filename, contentType, headers, chunks); err != nil {
+		return "", fmt.Errorf("failed to export file: %w", err)
+	}
+
+	return c.sinkURL, nil
+}
+
+func hmacSignature(secret string, payload []byte) string {
+	h := hmac.New(sha256.New, []byte(secret))
 	h.Write(payload)
 	return hex.EncodeToString(h.Sum(nil))
 }
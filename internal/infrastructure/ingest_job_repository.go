@@ -0,0 +1,93 @@
+package infrastructure
+
+import (
+	"context"
+	"sync"
+
+	"etlgo/internal/domain"
+	"etlgo/pkg/logger"
+)
+
+// ingest jobs kept before the oldest finished ones are dropped
+const maxIngestJobs = 1000
+
+// implements domain.IngestJobRepository interface in memory, keeping the
+// most recent jobs and every unfinished one
+type IngestJobRepository struct {
+	jobs   []domain.IngestJob // in creation order
+	index  map[string]int
+	mutex  sync.RWMutex
+	logger *logger.Logger
+}
+
+// creates a new ingest job repository
+func NewIngestJobRepository(logger *logger.Logger) *IngestJobRepository {
+	return &IngestJobRepository{index: make(map[string]int), logger: logger}
+}
+
+func (r *IngestJobRepository) Save(ctx context.Context, job domain.IngestJob) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if i, ok := r.index[job.ID]; ok {
+		r.jobs[i] = job
+		return nil
+	}
+	r.jobs = append(r.jobs, job)
+	r.index[job.ID] = len(r.jobs) - 1
+
+	if overflow := len(r.jobs) - maxIngestJobs; overflow > 0 {
+		kept := make([]domain.IngestJob, 0, maxIngestJobs)
+		for _, stored := range r.jobs {
+			if overflow > 0 && stored.Finished() {
+				overflow--
+				continue
+			}
+			kept = append(kept, stored)
+		}
+		r.jobs = kept
+		clear(r.index)
+		for i, stored := range r.jobs {
+			r.index[stored.ID] = i
+		}
+	}
+	return nil
+}
+
+func (r *IngestJobRepository) Get(ctx context.Context, id string) (*domain.IngestJob, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	i, ok := r.index[id]
+	if !ok {
+		return nil, domain.ErrIngestJobNotFound
+	}
+	job := r.jobs[i]
+	return &job, nil
+}
+
+func (r *IngestJobRepository) List(ctx context.Context, limit int) ([]domain.IngestJob, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	result := make([]domain.IngestJob, 0)
+	for i := len(r.jobs) - 1; i >= 0; i-- {
+		if limit > 0 && len(result) >= limit {
+			break
+		}
+		result = append(result, r.jobs[i])
+	}
+	return result, nil
+}
+
+func (r *IngestJobRepository) FindByRunKey(ctx context.Context, tenant, key string) (*domain.IngestJob, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for i := len(r.jobs) - 1; i >= 0; i-- {
+		if job := r.jobs[i]; job.RunKey == key && job.Tenant == tenant {
+			return &job, nil
+		}
+	}
+	return nil, domain.ErrIngestJobNotFound
+}
@@ -0,0 +1,52 @@
+package infrastructure
+
+import (
+	"context"
+	"sync"
+
+	"etlgo/internal/domain"
+	"etlgo/pkg/logger"
+)
+
+// incidents kept before the oldest are dropped
+const maxJobIncidents = 1000
+
+// implements domain.JobIncidentRepository interface in memory, keeping the
+// most recent incidents
+type JobIncidentRepository struct {
+	incidents []domain.JobIncident
+	mutex     sync.RWMutex
+	logger    *logger.Logger
+}
+
+// creates a new job incident repository
+func NewJobIncidentRepository(logger *logger.Logger) *JobIncidentRepository {
+	return &JobIncidentRepository{logger: logger}
+}
+
+func (r *JobIncidentRepository) Record(ctx context.Context, incident domain.JobIncident) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.incidents = append(r.incidents, incident)
+	if overflow := len(r.incidents) - maxJobIncidents; overflow > 0 {
+		r.incidents = append([]domain.JobIncident(nil), r.incidents[overflow:]...)
+	}
+	return nil
+}
+
+func (r *JobIncidentRepository) List(ctx context.Context, job string, limit int) ([]domain.JobIncident, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	result := make([]domain.JobIncident, 0)
+	for i := len(r.incidents) - 1; i >= 0; i-- {
+		if limit > 0 && len(result) >= limit {
+			break
+		}
+		if job == "" || r.incidents[i].Job == job {
+			result = append(result, r.incidents[i])
+		}
+	}
+	return result, nil
+}
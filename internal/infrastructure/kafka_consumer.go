@@ -0,0 +1,337 @@
+package infrastructure
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"etlgo/internal/domain"
+	"etlgo/pkg/logger"
+	"etlgo/pkg/metrics"
+
+	"github.com/google/uuid"
+)
+
+// content types of the Kafka REST proxy v2 API
+const (
+	kafkaContentType = "application/vnd.kafka.v2+json"
+	kafkaJSONRecords = "application/vnd.kafka.json.v2+json"
+)
+
+// configures the Kafka consumer
+type KafkaOptions struct {
+	// base URL of the REST proxy, e.g. http://kafka-rest:8082
+	URL      string
+	Group    string
+	AdsTopic string
+	CRMTopic string
+	// basic auth credentials of the proxy, when it requires them
+	Username string
+	Password string
+	// how long a poll waits for records, and the most bytes it returns
+	PollTimeout time.Duration
+	MaxBytes    int
+}
+
+// a message of a records response
+type kafkaMessage struct {
+	Topic     string          `json:"topic"`
+	Value     json.RawMessage `json:"value"`
+	Partition int             `json:"partition"`
+	Offset    int64           `json:"offset"`
+}
+
+// a partition offset of commit and seek requests
+type kafkaOffset struct {
+	Topic     string `json:"topic"`
+	Partition int    `json:"partition"`
+	Offset    int64  `json:"offset"`
+}
+
+// implements domain.StreamConsumer over the Kafka REST proxy v2 API, as
+// served by the Confluent REST proxy and Redpanda. Messages hold one JSON
+// record each, in the shape the source's field mapping reads, and are
+// decoded like NDJSON files. The consumer joins its group on the first
+// poll with auto commit disabled, so offsets only move on Commit, and
+// joins again when the proxy dropped the instance.
+type KafkaConsumer struct {
+	client  *http.Client
+	options KafkaOptions
+	sources map[string]string // by topic
+	mapper  *FieldMapper
+	baseURI string
+	mutex   sync.Mutex
+	logger  *logger.Logger
+	metrics *metrics.Metrics
+}
+
+// creates a consumer of the ads and CRM topics; a topic left empty isn't
+// consumed
+func NewKafkaConsumer(options KafkaOptions, mapper *FieldMapper, timeout time.Duration, logger *logger.Logger, metrics *metrics.Metrics) (*KafkaConsumer, error) {
+	if options.AdsTopic == "" && options.CRMTopic == "" {
+		return nil, fmt.Errorf("Kafka consumer needs an ads or CRM topic")
+	}
+	if options.AdsTopic == options.CRMTopic {
+		return nil, fmt.Errorf("ads and CRM records need topics of their own")
+	}
+	sources := make(map[string]string, 2)
+	if options.AdsTopic != "" {
+		sources[options.AdsTopic] = domain.SourceAds
+	}
+	if options.CRMTopic != "" {
+		sources[options.CRMTopic] = domain.SourceCRM
+	}
+
+	return &KafkaConsumer{
+		// polls are held open for up to the poll timeout
+		client:  &http.Client{Timeout: timeout + options.PollTimeout},
+		options: options,
+		sources: sources,
+		mapper:  mapper,
+		logger:  logger,
+		metrics: metrics,
+	}, nil
+}
+
+// returns the topics consumed
+func (c *KafkaConsumer) Topics() []string {
+	var topics []string
+	for _, topic := range []string{c.options.AdsTopic, c.options.CRMTopic} {
+		if topic != "" {
+			topics = append(topics, topic)
+		}
+	}
+	return topics
+}
+
+// fetches the next records, decoding them with the source's field mapping.
+// Records that cannot be mapped are rejected under their topic, partition
+// and offset, e.g. "ads/3@1042".
+func (c *KafkaConsumer) Poll(ctx context.Context) (*domain.StreamBatch, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if err := c.join(ctx); err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	query := url.Values{}
+	query.Set("timeout", strconv.FormatInt(c.options.PollTimeout.Milliseconds(), 10))
+	if c.options.MaxBytes > 0 {
+		query.Set("max_bytes", strconv.Itoa(c.options.MaxBytes))
+	}
+	body, err := c.do(ctx, "GET", c.baseURI+"/records?"+query.Encode(), nil, kafkaJSONRecords)
+	if err != nil {
+		return nil, err
+	}
+	var messages []kafkaMessage
+	if err := json.Unmarshal(body, &messages); err != nil {
+		c.metrics.RecordExternalAPIFailure("kafka", "json_decode")
+		return nil, fmt.Errorf("failed to decode Kafka records: %w", err)
+	}
+	if len(messages) == 0 {
+		// proxies answering before the timeout are not polled in a loop
+		select {
+		case <-ctx.Done():
+		case <-time.After(c.options.PollTimeout - time.Since(start)):
+		}
+		return nil, nil
+	}
+
+	batch := &domain.StreamBatch{}
+	pages := make(map[string]*mappedPage, 2)
+	for _, message := range messages {
+		batch.Append(&domain.StreamBatch{Partitions: []domain.StreamPartition{{
+			Topic:     message.Topic,
+			Partition: message.Partition,
+			First:     message.Offset,
+			Last:      message.Offset,
+		}}})
+
+		source, ok := c.sources[message.Topic]
+		if !ok || len(message.Value) == 0 || string(message.Value) == "null" {
+			// tombstones carry no record
+			continue
+		}
+		record, err := c.mapper.decodeNDJSON(source, bytes.NewReader(message.Value), 0)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode %s message %d of partition %d: %w", message.Topic, message.Offset, message.Partition, err)
+		}
+		name := fmt.Sprintf("%s/%d@%d", message.Topic, message.Partition, message.Offset)
+		for i := range record.rejected {
+			rejected := &record.rejected[i]
+			rejected.Record = name
+			for j := range rejected.Errors {
+				rejected.Errors[j].Record = name
+			}
+		}
+
+		if pages[source] == nil {
+			pages[source] = &mappedPage{}
+		}
+		pages[source].add(record)
+		batch.Messages++
+	}
+	if page := pages[domain.SourceAds]; page != nil {
+		batch.Ads = page.adData()
+	}
+	if page := pages[domain.SourceCRM]; page != nil {
+		batch.CRM = page.crmData()
+	}
+	return batch, nil
+}
+
+// commits the last offset of every partition of the batch
+func (c *KafkaConsumer) Commit(ctx context.Context, batch *domain.StreamBatch) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	offsets := make([]kafkaOffset, len(batch.Partitions))
+	for i, partition := range batch.Partitions {
+		offsets[i] = kafkaOffset{Topic: partition.Topic, Partition: partition.Partition, Offset: partition.Last}
+	}
+	if err := c.send(ctx, "/offsets", map[string]any{"offsets": offsets}); err != nil {
+		return fmt.Errorf("failed to commit Kafka offsets: %w", err)
+	}
+	return nil
+}
+
+// seeks every partition of the batch back to its first offset
+func (c *KafkaConsumer) Rewind(ctx context.Context, batch *domain.StreamBatch) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	offsets := make([]kafkaOffset, len(batch.Partitions))
+	for i, partition := range batch.Partitions {
+		offsets[i] = kafkaOffset{Topic: partition.Topic, Partition: partition.Partition, Offset: partition.First}
+	}
+	if err := c.send(ctx, "/positions", map[string]any{"offsets": offsets}); err != nil {
+		return fmt.Errorf("failed to rewind Kafka offsets: %w", err)
+	}
+	return nil
+}
+
+// deletes the consumer instance, leaving the group so its partitions are
+// reassigned right away
+func (c *KafkaConsumer) Close(ctx context.Context) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.baseURI == "" {
+		return nil
+	}
+	_, err := c.do(ctx, "DELETE", c.baseURI, nil, kafkaContentType)
+	c.baseURI = ""
+	return err
+}
+
+// creates a consumer instance in the group and subscribes it to the
+// topics, unless it already exists
+func (c *KafkaConsumer) join(ctx context.Context) error {
+	if c.baseURI != "" {
+		return nil
+	}
+
+	instance := map[string]string{
+		"name":               "etlgo-" + uuid.New().String(),
+		"format":             "json",
+		"auto.offset.reset":  "earliest",
+		"auto.commit.enable": "false",
+	}
+	groupURL := strings.TrimSuffix(c.options.URL, "/") + "/consumers/" + url.PathEscape(c.options.Group)
+	body, err := c.do(ctx, "POST", groupURL, instance, kafkaContentType)
+	if err != nil {
+		return fmt.Errorf("failed to join Kafka consumer group %s: %w", c.options.Group, err)
+	}
+	var created struct {
+		InstanceID string `json:"instance_id"`
+		BaseURI    string `json:"base_uri"`
+	}
+	if err := json.Unmarshal(body, &created); err != nil || created.BaseURI == "" {
+		c.metrics.RecordExternalAPIFailure("kafka", "json_decode")
+		return fmt.Errorf("invalid Kafka consumer instance response")
+	}
+	c.baseURI = strings.TrimSuffix(created.BaseURI, "/")
+
+	if err := c.send(ctx, "/subscription", map[string]any{"topics": c.Topics()}); err != nil {
+		return fmt.Errorf("failed to subscribe to Kafka topics: %w", err)
+	}
+
+	c.logger.WithContext(ctx).WithFields(map[string]any{
+		"group":    c.options.Group,
+		"instance": created.InstanceID,
+		"topics":   c.Topics(),
+	}).Info("Joined Kafka consumer group")
+	return nil
+}
+
+// posts a JSON body to a path of the consumer instance
+func (c *KafkaConsumer) send(ctx context.Context, path string, payload any) error {
+	if c.baseURI == "" {
+		return domain.Errorf(domain.ErrUpstreamUnavailable, "Kafka consumer instance is gone")
+	}
+	_, err := c.do(ctx, "POST", c.baseURI+path, payload, kafkaContentType)
+	return err
+}
+
+// sends a request to the proxy. A 404 means the proxy dropped the consumer
+// instance, e.g. after it idled or the proxy restarted, so the next poll
+// joins the group again.
+func (c *KafkaConsumer) do(ctx context.Context, method, target string, payload any, accept string) ([]byte, error) {
+	start := time.Now()
+
+	var body io.Reader
+	if payload != nil {
+		raw, err := json.Marshal(payload)
+		if err != nil {
+			c.metrics.RecordExternalAPIFailure("kafka", "json_marshal")
+			return nil, fmt.Errorf("failed to marshal Kafka request: %w", err)
+		}
+		body = bytes.NewReader(raw)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		c.metrics.RecordExternalAPIFailure("kafka", "request_creation")
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", accept)
+	if payload != nil || method == "DELETE" {
+		req.Header.Set("Content-Type", kafkaContentType)
+	}
+	if c.options.Username != "" {
+		req.SetBasicAuth(c.options.Username, c.options.Password)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		c.metrics.RecordExternalAPIFailure("kafka", "network_error")
+		return nil, domain.Errorf(domain.ErrUpstreamUnavailable, "failed to reach Kafka REST proxy: %w", err)
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	duration := time.Since(start)
+	if err != nil {
+		c.metrics.RecordExternalAPIFailure("kafka", "network_error")
+		return nil, domain.Errorf(domain.ErrUpstreamUnavailable, "failed to read Kafka REST proxy response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		c.metrics.RecordExternalAPICall("kafka", fmt.Sprintf("error_%d", resp.StatusCode), duration)
+		if resp.StatusCode == http.StatusNotFound && c.baseURI != "" && strings.HasPrefix(target, c.baseURI) {
+			c.baseURI = ""
+		}
+		return nil, domain.Errorf(domain.ErrUpstreamUnavailable, "Kafka REST proxy returned status %d", resp.StatusCode)
+	}
+
+	c.metrics.RecordExternalAPICall("kafka", "success", duration)
+	return raw, nil
+}
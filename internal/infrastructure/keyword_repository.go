@@ -0,0 +1,54 @@
+package infrastructure
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"etlgo/internal/domain"
+	"etlgo/pkg/logger"
+)
+
+// implements domain.KeywordRepository interface
+type KeywordRepository struct {
+	data   map[string]map[string]domain.ProcessedKeywordData // by date and key
+	mutex  sync.RWMutex
+	logger *logger.Logger
+}
+
+// creates a new keyword level ads repository
+func NewKeywordRepository(logger *logger.Logger) *KeywordRepository {
+	return &KeywordRepository{
+		data:   make(map[string]map[string]domain.ProcessedKeywordData),
+		logger: logger,
+	}
+}
+
+func (r *KeywordRepository) Store(ctx context.Context, rows []domain.ProcessedKeywordData) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, row := range rows {
+		dateKey := row.Date.Format("2006-01-02")
+		if r.data[dateKey] == nil {
+			r.data[dateKey] = make(map[string]domain.ProcessedKeywordData)
+		}
+		r.data[dateKey][row.Key()] = row
+	}
+
+	r.logger.WithContext(ctx).WithField("count", len(rows)).Info("Stored keyword data in memory")
+	return nil
+}
+
+func (r *KeywordRepository) GetByDateRange(ctx context.Context, from, to time.Time) ([]domain.ProcessedKeywordData, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	var result []domain.ProcessedKeywordData
+	for date := from.Truncate(24 * time.Hour); !date.After(to); date = date.AddDate(0, 0, 1) {
+		for _, row := range r.data[date.Format("2006-01-02")] {
+			result = append(result, row)
+		}
+	}
+	return result, nil
+}
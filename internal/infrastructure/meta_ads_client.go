@@ -0,0 +1,640 @@
+package infrastructure
+
+import (
+	"cmp"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"etlgo/internal/domain"
+	"etlgo/pkg/logger"
+	"etlgo/pkg/metrics"
+)
+
+const (
+	// rows requested per Graph API call
+	metaPageSize = 500
+
+	// long-lived tokens are exchanged for new ones this long before they
+	// expire
+	metaTokenRefreshBefore = 7 * 24 * time.Hour
+
+	// channel and UTM values of campaigns whose ads carry no URL tags
+	metaChannel   = "facebook_ads"
+	metaUTMSource = "facebook"
+	metaUTMMedium = "paid_social"
+)
+
+// insights fields requested per campaign and day
+var metaInsightFields = []string{"campaign_id", "campaign_name", "spend", "clicks", "impressions"}
+
+// settings of the Meta Marketing API connector. AccessToken is a long-lived
+// user or system user token; with AppID and AppSecret it is exchanged for a
+// new one before it expires and when the API reports it expired, and calls
+// carry an appsecret_proof. Refreshed tokens are kept in TokenFile across
+// restarts. Calls pause while the usage the Graph API reports in its
+// headers is at ThrottlePercent or more, and rate limited calls are retried
+// up to MaxRetries times unless the API asks to wait longer than
+// MaxBackoff.
+type MetaOptions struct {
+	AdAccountID     string
+	AccessToken     string
+	AppID           string
+	AppSecret       string
+	APIURL          string
+	TokenFile       string
+	ThrottlePercent float64
+	MaxBackoff      time.Duration
+	MaxRetries      int
+	RetryBackoff    time.Duration
+}
+
+// fetches campaign insights by day from the Meta (Facebook) Marketing API
+// and serves them as ads records. UTM parameters come from the URL tags of
+// the campaign's ads, with campaign macros resolved. Implements
+// domain.ExternalAPIClient; it serves no CRM records.
+type MetaAdsClient struct {
+	client    *http.Client
+	opts      MetaOptions
+	accountID string // with its act_ prefix
+
+	mutex       sync.Mutex
+	token       string
+	tokenExpiry time.Time // zero when unknown or never
+	exchanged   bool      // the token was exchanged since startup
+
+	throttleMutex sync.Mutex
+	pausedUntil   time.Time
+
+	prewarm bool
+	logger  *logger.Logger
+	metrics *metrics.Metrics
+}
+
+// a token kept in the token file
+type metaStoredToken struct {
+	AccessToken string    `json:"access_token"`
+	ExpiresAt   time.Time `json:"expires_at,omitzero"`
+}
+
+// creates a Meta client for the ad account, or returns nil when no ad
+// account is configured. Requests go through the cassette when one is
+// given.
+func NewMetaAdsClient(opts MetaOptions, transport TransportOptions, cassette *Cassette, timeout time.Duration, logger *logger.Logger, metrics *metrics.Metrics) (*MetaAdsClient, error) {
+	if opts.AdAccountID == "" {
+		return nil, nil
+	}
+	if (opts.AppID == "") != (opts.AppSecret == "") {
+		return nil, fmt.Errorf("Meta app id and app secret must be set together")
+	}
+	if opts.ThrottlePercent <= 0 || opts.ThrottlePercent > 100 {
+		return nil, fmt.Errorf("Meta throttle percent must be within (0, 100], got %g", opts.ThrottlePercent)
+	}
+
+	c := &MetaAdsClient{
+		client:    &http.Client{Timeout: timeout, Transport: NewUpstreamTransport(transport)},
+		opts:      opts,
+		accountID: "act_" + strings.TrimPrefix(opts.AdAccountID, "act_"),
+		token:     opts.AccessToken,
+		prewarm:   transport.Prewarm && !cassette.Replaying(),
+		logger:    logger,
+		metrics:   metrics,
+	}
+	c.opts.APIURL = strings.TrimSuffix(opts.APIURL, "/")
+	if cassette != nil {
+		c.client.Transport = cassette.Wrap(c.client.Transport)
+	}
+
+	// A refreshed token outlives the configured one
+	if opts.TokenFile != "" {
+		raw, err := os.ReadFile(opts.TokenFile)
+		switch {
+		case err == nil:
+			var stored metaStoredToken
+			if err := json.Unmarshal(raw, &stored); err != nil {
+				return nil, fmt.Errorf("invalid Meta token file: %w", err)
+			}
+			if stored.AccessToken != "" {
+				c.token, c.tokenExpiry = stored.AccessToken, stored.ExpiresAt
+			}
+		case !os.IsNotExist(err):
+			return nil, fmt.Errorf("failed to read Meta token file: %w", err)
+		}
+	}
+	if c.token == "" {
+		return nil, fmt.Errorf("Meta ad account %s configured without an access token", opts.AdAccountID)
+	}
+	return c, nil
+}
+
+// URL returns the insights endpoint of the ad account
+func (c *MetaAdsClient) URL() string {
+	return c.opts.APIURL + "/" + c.accountID + "/insights"
+}
+
+// opens connections to the Graph API
+func (c *MetaAdsClient) Prewarm(ctx context.Context) {
+	if !c.prewarm {
+		return
+	}
+	prewarmUpstreams(ctx, c.client, map[string]string{"meta": c.opts.APIURL}, c.logger, c.metrics)
+}
+
+// Graph API response bodies
+type metaInsightsResponse struct {
+	Data []struct {
+		DateStart    string `json:"date_start"`
+		CampaignID   string `json:"campaign_id"`
+		CampaignName string `json:"campaign_name"`
+		Spend        string `json:"spend"`
+		Clicks       string `json:"clicks"`
+		Impressions  string `json:"impressions"`
+	} `json:"data"`
+	Paging metaPaging `json:"paging"`
+}
+
+type metaAdsResponse struct {
+	Data []struct {
+		CampaignID string `json:"campaign_id"`
+		URLTags    string `json:"url_tags"`
+		Creative   struct {
+			URLTags string `json:"url_tags"`
+		} `json:"creative"`
+	} `json:"data"`
+	Paging metaPaging `json:"paging"`
+}
+
+type metaPaging struct {
+	Next string `json:"next"`
+}
+
+type metaErrorResponse struct {
+	Error struct {
+		Message      string `json:"message"`
+		Type         string `json:"type"`
+		Code         int    `json:"code"`
+		ErrorSubcode int    `json:"error_subcode"`
+		IsTransient  bool   `json:"is_transient"`
+	} `json:"error"`
+}
+
+// fetches the insights of the account's campaigns by day since the given
+// day, or as far back as the API keeps them
+func (c *MetaAdsClient) FetchAdsData(ctx context.Context, since *time.Time) (*domain.AdData, error) {
+	start := time.Now()
+
+	tags, err := c.campaignURLTags(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := url.Values{
+		"level":          {"campaign"},
+		"fields":         {strings.Join(metaInsightFields, ",")},
+		"time_increment": {"1"},
+		"limit":          {strconv.Itoa(metaPageSize)},
+	}
+	if since != nil {
+		timeRange, _ := json.Marshal(map[string]string{
+			"since": since.Format("2006-01-02"),
+			"until": time.Now().UTC().Format("2006-01-02"),
+		})
+		query.Set("time_range", string(timeRange))
+	} else {
+		query.Set("date_preset", "maximum")
+	}
+
+	data := &domain.AdData{}
+	pages := 0
+	for pageURL := c.URL() + "?" + query.Encode(); pageURL != ""; pages++ {
+		var page metaInsightsResponse
+		if err := c.get(ctx, pageURL, "insights", &page); err != nil {
+			return nil, err
+		}
+		for _, row := range page.Data {
+			record := row.CampaignID + "|" + row.DateStart
+			var errs []domain.RecordError
+			cost, err := domain.ParseMoney(cmp.Or(row.Spend, "0"))
+			if err != nil {
+				errs = append(errs, domain.RecordError{Record: record, Field: "spend", Value: row.Spend, Reason: "not a number"})
+			}
+			clicks, err := strconv.Atoi(cmp.Or(row.Clicks, "0"))
+			if err != nil {
+				errs = append(errs, domain.RecordError{Record: record, Field: "clicks", Value: row.Clicks, Reason: "not an integer"})
+			}
+			impressions, err := strconv.Atoi(cmp.Or(row.Impressions, "0"))
+			if err != nil {
+				errs = append(errs, domain.RecordError{Record: record, Field: "impressions", Value: row.Impressions, Reason: "not an integer"})
+			}
+			if len(errs) > 0 {
+				payload, _ := json.Marshal(row)
+				data.Rejected = append(data.Rejected, domain.QuarantinedRecord{
+					Source:  domain.SourceAds,
+					Record:  record,
+					Payload: payload,
+					Errors:  errs,
+				})
+				continue
+			}
+
+			utm := metaUTM(tags[row.CampaignID], row.CampaignID, row.CampaignName)
+			data.External.Ads.Performance = append(data.External.Ads.Performance, domain.AdPerformance{
+				Date:        row.DateStart,
+				CampaignID:  row.CampaignID,
+				Channel:     metaChannel,
+				Clicks:      clicks,
+				Impressions: impressions,
+				Cost:        cost,
+				UTMCampaign: utm.Get("utm_campaign"),
+				UTMSource:   utm.Get("utm_source"),
+				UTMMedium:   utm.Get("utm_medium"),
+			})
+		}
+		pageURL = page.Paging.Next
+	}
+
+	duration := time.Since(start)
+	c.metrics.RecordExternalAPICall("meta", "success", duration)
+	c.logger.WithContext(ctx).WithFields(map[string]any{
+		"account":  c.accountID,
+		"duration": duration,
+		"pages":    pages,
+		"records":  len(data.External.Ads.Performance),
+	}).Info("Successfully fetched Meta ads data")
+
+	return data, nil
+}
+
+// serves no CRM records
+func (c *MetaAdsClient) FetchCRMData(ctx context.Context, since *time.Time) (*domain.CRMData, error) {
+	return &domain.CRMData{}, nil
+}
+
+// returns the URL tags of each campaign, those of its first ad carrying
+// any. Tags set on the ad take precedence over those of its creative.
+func (c *MetaAdsClient) campaignURLTags(ctx context.Context) (map[string]string, error) {
+	query := url.Values{
+		"fields": {"campaign_id,url_tags,creative{url_tags}"},
+		"limit":  {strconv.Itoa(metaPageSize)},
+	}
+	tags := make(map[string]string)
+	for pageURL := c.opts.APIURL + "/" + c.accountID + "/ads?" + query.Encode(); pageURL != ""; {
+		var page metaAdsResponse
+		if err := c.get(ctx, pageURL, "ads", &page); err != nil {
+			return nil, err
+		}
+		for _, ad := range page.Data {
+			if value := cmp.Or(ad.URLTags, ad.Creative.URLTags); value != "" && tags[ad.CampaignID] == "" {
+				tags[ad.CampaignID] = value
+			}
+		}
+		pageURL = page.Paging.Next
+	}
+	return tags, nil
+}
+
+// returns the UTM parameters of a campaign from its URL tags. The campaign
+// macros are resolved; parameters holding other macros, which differ per ad
+// set or ad, and missing ones fall back to the campaign name and the
+// facebook source and paid_social medium.
+func metaUTM(tags, campaignID, campaignName string) url.Values {
+	utm, err := url.ParseQuery(tags)
+	if err != nil {
+		utm = url.Values{}
+	}
+	macros := strings.NewReplacer("{{campaign.id}}", campaignID, "{{campaign.name}}", campaignName)
+	for key, values := range utm {
+		value := macros.Replace(values[0])
+		if strings.Contains(value, "{{") {
+			utm.Del(key)
+			continue
+		}
+		utm.Set(key, value)
+	}
+
+	fallbacks := map[string]string{"utm_campaign": campaignName, "utm_source": metaUTMSource, "utm_medium": metaUTMMedium}
+	for key, fallback := range fallbacks {
+		if utm.Get(key) == "" {
+			utm.Set(key, fallback)
+		}
+	}
+	return utm
+}
+
+// whether a Graph API error code means a rate limit was hit: the app,
+// user, page and ad account limits and the business use case ones
+func metaRateLimited(code int) bool {
+	switch code {
+	case 4, 17, 32, 613:
+		return true
+	}
+	return code >= 80000 && code <= 80014
+}
+
+// sends a Graph API GET request and decodes its body into out. Calls pause
+// while the reported usage is high; rate limited and transient failures
+// are retried, and an expired token is exchanged once.
+func (c *MetaAdsClient) get(ctx context.Context, rawURL, call string, out any) error {
+	refreshed := false
+	var err error
+	for attempt := 0; attempt <= c.opts.MaxRetries; attempt++ {
+		if err := c.waitThrottle(ctx); err != nil {
+			return err
+		}
+
+		var body []byte
+		var apiErr *metaErrorResponse
+		var status int
+		body, status, apiErr, err = c.do(ctx, rawURL, call)
+		if err != nil {
+			return err
+		}
+		if apiErr == nil {
+			if err := json.Unmarshal(body, out); err != nil {
+				c.metrics.RecordExternalAPIFailure("meta", "json_parse")
+				return domain.Errorf(domain.ErrUpstreamUnavailable, "failed to parse Meta %s response: %w", call, err)
+			}
+			return nil
+		}
+
+		graph := apiErr.Error
+		err = domain.Errorf(domain.ErrUpstreamUnavailable, "Meta %s endpoint returned status %d: %s (code %d)", call, status, cmp.Or(graph.Message, http.StatusText(status)), graph.Code)
+		switch {
+		case graph.Code == 190:
+			// the token expired or was revoked
+			if refreshed || c.opts.AppID == "" {
+				return domain.Errorf(domain.ErrUpstreamUnavailable, "Meta access token is expired or invalid: %s", graph.Message)
+			}
+			if err := c.refreshToken(ctx); err != nil {
+				return err
+			}
+			refreshed = true
+			attempt--
+			continue
+		case metaRateLimited(graph.Code) || status == http.StatusTooManyRequests:
+			// waitThrottle honours the wait the usage headers asked for;
+			// without one the call backs off like any other failure
+			wait := c.throttleWait()
+			if wait > c.opts.MaxBackoff {
+				return domain.Errorf(domain.ErrUpstreamUnavailable, "Meta rate limit reached, access is regained in %s", wait.Round(time.Second))
+			}
+			if wait > 0 {
+				continue
+			}
+		case graph.IsTransient || status >= http.StatusInternalServerError:
+		default:
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(c.opts.RetryBackoff * time.Duration(attempt+1)):
+		}
+	}
+	return err
+}
+
+// sends one request, returning the body of successful responses or the
+// Graph API error of failed ones
+func (c *MetaAdsClient) do(ctx context.Context, rawURL, call string) ([]byte, int, *metaErrorResponse, error) {
+	start := time.Now()
+	token := c.accessToken(ctx)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		c.metrics.RecordExternalAPIFailure("meta", "request_creation")
+		return nil, 0, nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	if c.opts.AppSecret != "" {
+		query := req.URL.Query()
+		query.Set("appsecret_proof", c.appSecretProof(token))
+		req.URL.RawQuery = query.Encode()
+	}
+
+	if err := domain.ConsumeUpstreamCall(ctx, domain.SourceAds); err != nil {
+		return nil, 0, nil, err
+	}
+	meter := domain.CostMeterFromContext(ctx)
+	meter.AddAPICall()
+	resp, err := c.client.Do(traceUpstream(req, "meta", c.metrics))
+	if err != nil {
+		c.metrics.RecordExternalAPIFailure("meta", "network_error")
+		return nil, 0, nil, domain.Errorf(domain.ErrUpstreamUnavailable, "failed to reach Meta %s endpoint: %w", call, err)
+	}
+	defer resp.Body.Close()
+	c.observeUsage(ctx, resp.Header)
+
+	body, err := io.ReadAll(resp.Body)
+	meter.AddDownload(int64(len(body)))
+	if err != nil {
+		c.metrics.RecordExternalAPIFailure("meta", "read_body")
+		return nil, 0, nil, domain.Errorf(domain.ErrUpstreamUnavailable, "failed to read Meta %s response: %w", call, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		c.metrics.RecordExternalAPICall("meta", fmt.Sprintf("error_%d", resp.StatusCode), time.Since(start))
+		var apiErr metaErrorResponse
+		_ = json.Unmarshal(body, &apiErr)
+		return nil, resp.StatusCode, &apiErr, nil
+	}
+	return body, resp.StatusCode, nil, nil
+}
+
+// proves the token was issued to the app, as apps requiring it demand
+func (c *MetaAdsClient) appSecretProof(token string) string {
+	mac := hmac.New(sha256.New, []byte(c.opts.AppSecret))
+	mac.Write([]byte(token))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// returns the access token, exchanging it first when it is about to expire
+// or its expiry is unknown and it was not exchanged yet. A failed exchange
+// keeps the current token; the call reports it should it have expired.
+func (c *MetaAdsClient) accessToken(ctx context.Context) string {
+	c.mutex.Lock()
+	due := c.opts.AppID != "" && ((c.tokenExpiry.IsZero() && !c.exchanged) ||
+		(!c.tokenExpiry.IsZero() && time.Until(c.tokenExpiry) < metaTokenRefreshBefore))
+	c.mutex.Unlock()
+
+	if due {
+		if err := c.refreshToken(ctx); err != nil {
+			c.logger.WithContext(ctx).WithError(err).Warn("Failed to refresh Meta access token")
+		}
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.token
+}
+
+// exchanges the access token for a new long-lived one and keeps it in the
+// token file
+func (c *MetaAdsClient) refreshToken(ctx context.Context) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.exchanged = true
+
+	query := url.Values{
+		"grant_type":        {"fb_exchange_token"},
+		"client_id":         {c.opts.AppID},
+		"client_secret":     {c.opts.AppSecret},
+		"fb_exchange_token": {c.token},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.opts.APIURL+"/oauth/access_token?"+query.Encode(), nil)
+	if err != nil {
+		c.metrics.RecordExternalAPIFailure("meta", "request_creation")
+		return fmt.Errorf("failed to create token request: %w", err)
+	}
+	resp, err := c.client.Do(traceUpstream(req, "meta", c.metrics))
+	if err != nil {
+		c.metrics.RecordExternalAPIFailure("meta", "network_error")
+		return domain.Errorf(domain.ErrUpstreamUnavailable, "failed to reach Meta token endpoint: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+		metaErrorResponse
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil || token.AccessToken == "" {
+		c.metrics.RecordExternalAPIFailure("meta", "token")
+		return domain.Errorf(domain.ErrUpstreamUnavailable, "Meta token endpoint returned no access token (status %d): %s", resp.StatusCode, token.Error.Message)
+	}
+
+	c.token = token.AccessToken
+	c.tokenExpiry = time.Time{}
+	if token.ExpiresIn > 0 {
+		c.tokenExpiry = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	}
+	c.logger.WithContext(ctx).WithField("expires_at", c.tokenExpiry).Info("Refreshed Meta access token")
+
+	if c.opts.TokenFile != "" {
+		stored, _ := json.Marshal(metaStoredToken{AccessToken: c.token, ExpiresAt: c.tokenExpiry})
+		if err := os.WriteFile(c.opts.TokenFile, stored, 0o600); err != nil {
+			c.logger.WithContext(ctx).WithError(err).Warn("Failed to keep the refreshed Meta access token")
+		}
+	}
+	return nil
+}
+
+// usage headers of the Graph API, in percent of the limits
+type metaUsage struct {
+	CallCount    float64 `json:"call_count"`
+	TotalCPUTime float64 `json:"total_cputime"`
+	TotalTime    float64 `json:"total_time"`
+	// minutes until a throttled business use case regains access
+	EstimatedTimeToRegainAccess float64 `json:"estimated_time_to_regain_access"`
+}
+
+type metaAccountUsage struct {
+	AccountUtilPercent float64 `json:"acc_id_util_pct"`
+	AppUtilPercent     float64 `json:"app_id_util_pct"`
+	// seconds until the ad account's usage resets
+	ResetTimeDuration float64 `json:"reset_time_duration"`
+}
+
+// reads the usage the Graph API reports in the X-App-Usage,
+// X-Business-Use-Case-Usage, X-Ad-Account-Usage and
+// X-FB-Ads-Insights-Throttle headers and pauses further calls while it is
+// at the throttle percent or more. The pause is the wait the API asks for
+// or, without one, grows with the usage up to the maximum backoff.
+func (c *MetaAdsClient) observeUsage(ctx context.Context, header http.Header) {
+	var highest float64
+	var regain time.Duration
+
+	var usages []metaUsage
+	if value := header.Get("X-App-Usage"); value != "" {
+		var usage metaUsage
+		if json.Unmarshal([]byte(value), &usage) == nil {
+			usages = append(usages, usage)
+		}
+	}
+	if value := header.Get("X-Business-Use-Case-Usage"); value != "" {
+		var byBusiness map[string][]metaUsage
+		if json.Unmarshal([]byte(value), &byBusiness) == nil {
+			for _, business := range byBusiness {
+				usages = append(usages, business...)
+			}
+		}
+	}
+	for _, usage := range usages {
+		highest = max(highest, usage.CallCount, usage.TotalCPUTime, usage.TotalTime)
+		regain = max(regain, time.Duration(usage.EstimatedTimeToRegainAccess*float64(time.Minute)))
+	}
+	for _, name := range []string{"X-Ad-Account-Usage", "X-FB-Ads-Insights-Throttle"} {
+		var usage metaAccountUsage
+		if value := header.Get(name); value != "" && json.Unmarshal([]byte(value), &usage) == nil {
+			highest = max(highest, usage.AccountUtilPercent, usage.AppUtilPercent)
+			if usage.AccountUtilPercent >= 100 {
+				regain = max(regain, time.Duration(usage.ResetTimeDuration*float64(time.Second)))
+			}
+		}
+	}
+
+	if highest < c.opts.ThrottlePercent && regain == 0 {
+		return
+	}
+	pause := regain
+	if pause == 0 {
+		share := 1.0
+		if c.opts.ThrottlePercent < 100 {
+			share = min(1, (highest-c.opts.ThrottlePercent)/(100-c.opts.ThrottlePercent))
+		}
+		pause = max(time.Second, time.Duration(share*float64(c.opts.MaxBackoff)))
+	}
+
+	c.throttleMutex.Lock()
+	if until := time.Now().Add(pause); until.After(c.pausedUntil) {
+		c.pausedUntil = until
+	}
+	c.throttleMutex.Unlock()
+
+	c.logger.WithContext(ctx).WithFields(map[string]any{
+		"usage_percent": highest,
+		"pause":         pause,
+	}).Warn("Meta API usage is high, pausing calls")
+}
+
+// returns how long calls are still paused for
+func (c *MetaAdsClient) throttleWait() time.Duration {
+	c.throttleMutex.Lock()
+	defer c.throttleMutex.Unlock()
+	return max(0, time.Until(c.pausedUntil))
+}
+
+// blocks while calls are paused, up to the maximum backoff, or until the
+// context is done
+func (c *MetaAdsClient) waitThrottle(ctx context.Context) error {
+	wait := c.throttleWait()
+	if wait <= 0 {
+		return nil
+	}
+	if wait > c.opts.MaxBackoff {
+		return domain.Errorf(domain.ErrUpstreamUnavailable, "Meta rate limit reached, access is regained in %s", wait.Round(time.Second))
+	}
+
+	start := time.Now()
+	select {
+	case <-ctx.Done():
+		c.metrics.RecordRateLimitWait("meta", "cancelled", time.Since(start))
+		return ctx.Err()
+	case <-time.After(wait):
+	}
+	c.metrics.RecordRateLimitWait("meta", "throttled", time.Since(start))
+	return nil
+}
@@ -12,19 +12,26 @@ import (
 
 // implements domain.MetricsRepository interface
 type MetricsRepository struct {
-	data   map[string][]domain.BusinessMetrics
+	data map[string][]domain.BusinessMetrics
+	// position of each stored metric in its date partition by key and producer
+	index  map[string]map[producedMetricKey]int
 	mutex  sync.RWMutex
+	clock  domain.Clock
 	logger *logger.Logger
 }
 
 // creates a new metrics repository
-func NewMetricsRepository(logger *logger.Logger) *MetricsRepository {
+func NewMetricsRepository(clock domain.Clock, logger *logger.Logger) *MetricsRepository {
 	return &MetricsRepository{
 		data:   make(map[string][]domain.BusinessMetrics),
+		index:  make(map[string]map[producedMetricKey]int),
+		clock:  clock,
 		logger: logger,
 	}
 }
 
+// replaces the stored metrics with the same date, UTM and ad group like
+// Upsert, so storing the same metrics again is a no-op
 func (r *MetricsRepository) Store(ctx context.Context, metrics []domain.BusinessMetrics) error {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
@@ -32,11 +39,10 @@ func (r *MetricsRepository) Store(ctx context.Context, metrics []domain.Business
 	log := r.logger.WithContext(ctx)
 
 	for _, metric := range metrics {
-		dateKey := metric.Date.Format("2006-01-02")
-		r.data[dateKey] = append(r.data[dateKey], metric)
+		r.upsert(metric)
 
 		log.WithFields(map[string]any{
-			"date":         dateKey,
+			"date":         metric.Date.Format("2006-01-02"),
 			"utm_campaign": metric.UTMCampaign,
 			"utm_source":   metric.UTMSource,
 			"utm_medium":   metric.UTMMedium,
@@ -48,6 +54,57 @@ func (r *MetricsRepository) Store(ctx context.Context, metrics []domain.Business
 	return nil
 }
 
+// replaces the stored metric with the same date, UTM, ad group and
+// producer, adding it when there is none
+func (r *MetricsRepository) Upsert(ctx context.Context, metrics []domain.BusinessMetrics) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, metric := range metrics {
+		r.upsert(metric)
+	}
+
+	r.logger.WithContext(ctx).WithField("count", len(metrics)).Info("Upserted business metrics in memory")
+	return nil
+}
+
+// identifies a stored metric within its date partition: the ETL's rows and
+// each producer's are kept apart
+type producedMetricKey struct {
+	domain.MetricKey
+	producer string
+}
+
+func (r *MetricsRepository) upsert(metric domain.BusinessMetrics) {
+	dateKey := metric.Date.Format("2006-01-02")
+	key := producedMetricKey{metric.Key(), metric.Producer}
+	positions, ok := r.index[dateKey]
+	if !ok {
+		positions = make(map[producedMetricKey]int)
+		r.index[dateKey] = positions
+	}
+	if i, ok := positions[key]; ok {
+		r.data[dateKey][i] = metric
+		return
+	}
+	positions[key] = len(r.data[dateKey])
+	r.data[dateKey] = append(r.data[dateKey], metric)
+}
+
+// rebuilds the positions of a date partition after its metrics were rewritten
+func (r *MetricsRepository) reindex(dateKey string) {
+	metrics, ok := r.data[dateKey]
+	if !ok {
+		delete(r.index, dateKey)
+		return
+	}
+	positions := make(map[producedMetricKey]int, len(metrics))
+	for i, metric := range metrics {
+		positions[producedMetricKey{metric.Key(), metric.Producer}] = i
+	}
+	r.index[dateKey] = positions
+}
+
 func (r *MetricsRepository) GetByFilter(ctx context.Context, filter domain.MetricsFilter) (*domain.MetricsResponse, error) {
 	r.mutex.RLock()
 	defer r.mutex.RUnlock()
@@ -61,8 +118,9 @@ func (r *MetricsRepository) GetByFilter(ctx context.Context, filter domain.Metri
 	var allMetrics []domain.BusinessMetrics
 
 	// Get date range
-	from := time.Now().AddDate(0, 0, -365)
-	to := time.Now()
+	now := r.clock.Now()
+	from := now.AddDate(0, 0, -365)
+	to := now
 
 	if filter.From != nil {
 		from = *filter.From
@@ -93,7 +151,7 @@ func (r *MetricsRepository) GetByFilter(ctx context.Context, filter domain.Metri
 	// Apply filters
 	var filteredMetrics []domain.BusinessMetrics
 	for _, metric := range allMetrics {
-		if r.matchesFilter(metric, filter) {
+		if filter.Matches(metric) {
 			filteredMetrics = append(filteredMetrics, metric)
 		}
 	}
@@ -104,8 +162,9 @@ func (r *MetricsRepository) GetByFilter(ctx context.Context, filter domain.Metri
 		"utm_campaign_filter": filter.UTMCampaign,
 	}).Info("Applied filters to metrics")
 
-	// Sort by date
-	sort.Slice(filteredMetrics, func(i, j int) bool {
+	// Sort by date, keeping the stored order within a day so pages are
+	// stable across requests
+	sort.SliceStable(filteredMetrics, func(i, j int) bool {
 		return filteredMetrics[i].Date.Before(filteredMetrics[j].Date)
 	})
 
@@ -152,6 +211,7 @@ func (r *MetricsRepository) GetByFilter(ctx context.Context, filter domain.Metri
 		Limit:   limit,
 		Offset:  offset,
 		HasMore: hasMore,
+		Meta:    domain.NewMetricsMeta(filteredMetrics),
 	}, nil
 }
 
@@ -167,23 +227,67 @@ func (r *MetricsRepository) GetByDate(ctx context.Context, date time.Time) ([]do
 	return []domain.BusinessMetrics{}, nil
 }
 
-// matchesFilter checks if a metric matches the given filter
-func (r *MetricsRepository) matchesFilter(metric domain.BusinessMetrics, filter domain.MetricsFilter) bool {
-	if filter.Channel != "" && metric.Channel != filter.Channel {
-		return false
-	}
-	if filter.CampaignID != "" && metric.CampaignID != filter.CampaignID {
-		return false
+// counts distinct values of a dimension directly from the date partitions
+func (r *MetricsRepository) GetDistinctValues(ctx context.Context, dimension string, from, to time.Time) ([]domain.DimensionValue, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	counts := make(map[string]int)
+	for date := from; !date.After(to); date = date.AddDate(0, 0, 1) {
+		for _, metric := range r.data[date.Format("2006-01-02")] {
+			counts[metric.DimensionValue(dimension)]++
+		}
 	}
-	if filter.UTMCampaign != "" && metric.UTMCampaign != filter.UTMCampaign {
-		return false
+
+	return domain.SortedDimensionValues(counts), nil
+}
+
+// counts the metrics of the date partitions within the range
+func (r *MetricsRepository) Count(ctx context.Context, from, to time.Time) (int64, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	var count int64
+	for date := from; !date.After(to); date = date.AddDate(0, 0, 1) {
+		count += int64(len(r.data[date.Format("2006-01-02")]))
 	}
-	if filter.UTMSource != "" && metric.UTMSource != filter.UTMSource {
-		return false
+	return count, nil
+}
+
+// replaces the ETL-calculated metrics of the date partitions within the
+// range under one lock, so readers see either the old or the new metrics.
+// Producers' metrics stay in their partitions.
+func (r *MetricsRepository) Replace(ctx context.Context, from, to time.Time, metrics []domain.BusinessMetrics) ([]domain.BusinessMetrics, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var replaced []domain.BusinessMetrics
+	for date := from; !date.After(to); date = date.AddDate(0, 0, 1) {
+		dateKey := date.Format("2006-01-02")
+		var kept []domain.BusinessMetrics
+		for _, metric := range r.data[dateKey] {
+			if metric.Producer == "" {
+				replaced = append(replaced, metric)
+			} else {
+				kept = append(kept, metric)
+			}
+		}
+		if len(kept) > 0 {
+			r.data[dateKey] = kept
+		} else {
+			delete(r.data, dateKey)
+		}
+		r.reindex(dateKey)
 	}
-	if filter.UTMMedium != "" && metric.UTMMedium != filter.UTMMedium {
-		return false
+	for _, metric := range metrics {
+		r.upsert(metric)
 	}
 
-	return true
+	r.logger.WithContext(ctx).WithFields(map[string]any{
+		"from":     from.Format("2006-01-02"),
+		"to":       to.Format("2006-01-02"),
+		"count":    len(metrics),
+		"replaced": len(replaced),
+	}).Info("Replaced business metrics in memory")
+	return replaced, nil
 }
@@ -0,0 +1,3 @@
+-- Closed won revenue credited to each UTM combination by the attribution model.
+
+ALTER TABLE business_metrics ADD COLUMN attributed_revenue_micros BIGINT NOT NULL DEFAULT 0;
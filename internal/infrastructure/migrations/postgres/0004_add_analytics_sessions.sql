@@ -0,0 +1,19 @@
+-- Web analytics sessions and conversions by day and UTM combination, and
+-- their totals joined into the business metrics.
+
+CREATE TABLE analytics_sessions (
+    id           BIGSERIAL PRIMARY KEY,
+    date         DATE        NOT NULL,
+    utm_campaign TEXT        NOT NULL,
+    utm_source   TEXT        NOT NULL,
+    utm_medium   TEXT        NOT NULL,
+    sessions     INTEGER     NOT NULL,
+    conversions  INTEGER     NOT NULL,
+    processed_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX analytics_sessions_date_idx ON analytics_sessions (date);
+CREATE INDEX analytics_sessions_utm_idx ON analytics_sessions (utm_campaign, utm_source, utm_medium);
+
+ALTER TABLE business_metrics ADD COLUMN sessions INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE business_metrics ADD COLUMN conversions INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,22 @@
+-- Columns the SQL repositories need to round-trip every stored field:
+-- fingerprints, the opportunity close date, touches, probability and
+-- reported currency, and the funnel and pipeline metrics. Maps and lists
+-- are stored as JSON text.
+
+ALTER TABLE ad_performance ADD COLUMN fingerprint TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE opportunities ADD COLUMN closed_at TIMESTAMPTZ;
+ALTER TABLE opportunities ADD COLUMN touches TEXT NOT NULL DEFAULT '';
+ALTER TABLE opportunities ADD COLUMN probability DOUBLE PRECISION;
+ALTER TABLE opportunities ADD COLUMN currency TEXT NOT NULL DEFAULT '';
+ALTER TABLE opportunities ADD COLUMN original_amount_micros BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE opportunities ADD COLUMN fingerprint TEXT NOT NULL DEFAULT '';
+
+CREATE UNIQUE INDEX opportunities_opportunity_id_idx ON opportunities (opportunity_id) WHERE opportunity_id <> '';
+
+ALTER TABLE business_metrics ADD COLUMN stages TEXT NOT NULL DEFAULT '';
+ALTER TABLE business_metrics ADD COLUMN pipeline_value_micros BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE business_metrics ADD COLUMN expected_revenue_micros BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE business_metrics ADD COLUMN stage_conversions TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX business_metrics_utm_idx ON business_metrics (utm_campaign, utm_source, utm_medium, date);
@@ -0,0 +1,8 @@
+-- Optional ad group and quality score of ads, and of the metrics
+-- calculated from them. Ads without a quality score store NULL.
+
+ALTER TABLE ad_performance ADD COLUMN ad_group_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE ad_performance ADD COLUMN quality_score DOUBLE PRECISION;
+
+ALTER TABLE business_metrics ADD COLUMN ad_group_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE business_metrics ADD COLUMN quality_score DOUBLE PRECISION NOT NULL DEFAULT 0;
@@ -0,0 +1,8 @@
+-- The producer of metrics contributed through the batch API, empty for the
+-- metrics the ETL calculates. A metric is kept per date, UTM and producer.
+
+ALTER TABLE business_metrics ADD COLUMN producer TEXT NOT NULL DEFAULT '';
+
+DROP INDEX business_metrics_natural_key_idx;
+CREATE UNIQUE INDEX business_metrics_natural_key_idx
+    ON business_metrics (date, utm_campaign, utm_source, utm_medium, producer);
@@ -0,0 +1,6 @@
+-- Metrics are kept per ad group: a UTM whose ads span several ad groups
+-- gets a row per ad group, so the ad group joins the natural key.
+
+DROP INDEX business_metrics_natural_key_idx;
+CREATE UNIQUE INDEX business_metrics_natural_key_idx
+    ON business_metrics (date, utm_campaign, utm_source, utm_medium, ad_group_id, producer);
@@ -0,0 +1,64 @@
+-- Processed ads, CRM opportunities and calculated business metrics.
+-- Money columns hold integer micro-units (domain.Money); dates are
+-- YYYY-MM-DD and timestamps RFC 3339 text so they sort chronologically.
+
+CREATE TABLE ad_performance (
+    id           INTEGER PRIMARY KEY AUTOINCREMENT,
+    date         TEXT    NOT NULL,
+    campaign_id  TEXT    NOT NULL,
+    channel      TEXT    NOT NULL,
+    clicks       INTEGER NOT NULL,
+    impressions  INTEGER NOT NULL,
+    cost_micros  INTEGER NOT NULL,
+    utm_campaign TEXT    NOT NULL,
+    utm_source   TEXT    NOT NULL,
+    utm_medium   TEXT    NOT NULL,
+    flags        TEXT    NOT NULL DEFAULT '',
+    processed_at TEXT    NOT NULL
+);
+
+CREATE INDEX ad_performance_date_idx ON ad_performance (date);
+CREATE INDEX ad_performance_utm_idx ON ad_performance (utm_campaign, utm_source, utm_medium);
+
+CREATE TABLE opportunities (
+    id             INTEGER PRIMARY KEY AUTOINCREMENT,
+    opportunity_id TEXT    NOT NULL,
+    contact_email  TEXT    NOT NULL,
+    stage          TEXT    NOT NULL,
+    amount_micros  INTEGER NOT NULL,
+    created_at     TEXT    NOT NULL,
+    utm_campaign   TEXT    NOT NULL,
+    utm_source     TEXT    NOT NULL,
+    utm_medium     TEXT    NOT NULL,
+    flags          TEXT    NOT NULL DEFAULT '',
+    processed_at   TEXT    NOT NULL
+);
+
+CREATE INDEX opportunities_created_at_idx ON opportunities (created_at);
+CREATE INDEX opportunities_utm_idx ON opportunities (utm_campaign, utm_source, utm_medium);
+
+CREATE TABLE business_metrics (
+    id              INTEGER PRIMARY KEY AUTOINCREMENT,
+    date            TEXT    NOT NULL,
+    channel         TEXT    NOT NULL,
+    campaign_id     TEXT    NOT NULL,
+    utm_campaign    TEXT    NOT NULL,
+    utm_source      TEXT    NOT NULL,
+    utm_medium      TEXT    NOT NULL,
+    clicks          INTEGER NOT NULL,
+    impressions     INTEGER NOT NULL,
+    cost_micros     INTEGER NOT NULL,
+    leads           INTEGER NOT NULL,
+    opportunities   INTEGER NOT NULL,
+    closed_won      INTEGER NOT NULL,
+    revenue_micros  INTEGER NOT NULL,
+    cpc_micros      INTEGER NOT NULL,
+    cpa_micros      INTEGER NOT NULL,
+    cvr_lead_to_opp REAL    NOT NULL,
+    cvr_opp_to_won  REAL    NOT NULL,
+    roas            REAL    NOT NULL,
+    calculated_at   TEXT    NOT NULL
+);
+
+CREATE INDEX business_metrics_date_idx ON business_metrics (date);
+CREATE INDEX business_metrics_channel_idx ON business_metrics (channel, date);
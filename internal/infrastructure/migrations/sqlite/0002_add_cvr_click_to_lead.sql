@@ -0,0 +1,3 @@
+-- Click to lead conversion rate of calculated business metrics.
+
+ALTER TABLE business_metrics ADD COLUMN cvr_click_to_lead REAL NOT NULL DEFAULT 0;
@@ -0,0 +1,3 @@
+-- Version of the transform rules each business metric was calculated under.
+
+ALTER TABLE business_metrics ADD COLUMN transform_version TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,17 @@
+-- Natural keys making repeated loads idempotent: an ad row per date,
+-- campaign, channel, UTM and ad group, and a metric per date and UTM.
+-- Duplicates stored by earlier appending loads keep their latest row.
+
+DELETE FROM ad_performance WHERE id NOT IN (
+    SELECT MAX(id) FROM ad_performance
+    GROUP BY date, campaign_id, channel, utm_campaign, utm_source, utm_medium, ad_group_id
+);
+CREATE UNIQUE INDEX ad_performance_natural_key_idx
+    ON ad_performance (date, campaign_id, channel, utm_campaign, utm_source, utm_medium, ad_group_id);
+
+DELETE FROM business_metrics WHERE id NOT IN (
+    SELECT MAX(id) FROM business_metrics
+    GROUP BY date, utm_campaign, utm_source, utm_medium
+);
+CREATE UNIQUE INDEX business_metrics_natural_key_idx
+    ON business_metrics (date, utm_campaign, utm_source, utm_medium);
@@ -0,0 +1,147 @@
+package infrastructure
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"etlgo/internal/domain"
+)
+
+// loads notification channels from a JSON array. A channel's template_file
+// is read into its template, relative to the file's directory. An empty
+// path configures no channels.
+func LoadNotificationChannels(path string) ([]domain.NotificationChannel, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read notification channel file: %w", err)
+	}
+	var channels []domain.NotificationChannel
+	if err := json.Unmarshal(raw, &channels); err != nil {
+		return nil, fmt.Errorf("failed to parse notification channel file: %w", err)
+	}
+
+	names := make(map[string]bool, len(channels))
+	for i := range channels {
+		channel := &channels[i]
+		if err := channel.Validate(); err != nil {
+			return nil, err
+		}
+		if names[channel.Name] {
+			return nil, fmt.Errorf("duplicate notification channel %q", channel.Name)
+		}
+		names[channel.Name] = true
+
+		if channel.TemplateFile != "" {
+			templatePath := channel.TemplateFile
+			if !filepath.IsAbs(templatePath) {
+				templatePath = filepath.Join(filepath.Dir(path), templatePath)
+			}
+			template, err := os.ReadFile(templatePath)
+			if err != nil {
+				return nil, fmt.Errorf("%s: failed to read template file: %w", channel.Name, err)
+			}
+			channel.Template = string(template)
+		}
+	}
+
+	return channels, nil
+}
+
+// SMTP relay used by email channels
+type SMTPOptions struct {
+	Addr     string // host:port
+	From     string
+	Username string
+	Password string
+}
+
+// implements domain.NotificationSender interface, posting to Slack incoming
+// webhooks and generic webhooks and sending mail through an SMTP relay
+type NotificationSender struct {
+	client *http.Client
+	smtp   SMTPOptions
+}
+
+// creates a notification sender
+func NewNotificationSender(smtpOptions SMTPOptions, timeout time.Duration) *NotificationSender {
+	return &NotificationSender{
+		client: &http.Client{Timeout: timeout},
+		smtp:   smtpOptions,
+	}
+}
+
+func (s *NotificationSender) Send(ctx context.Context, channel domain.NotificationChannel, message domain.NotificationMessage) error {
+	switch channel.Type {
+	case domain.ChannelSlack:
+		payload, err := json.Marshal(map[string]string{"text": message.Body})
+		if err != nil {
+			return fmt.Errorf("failed to encode slack message: %w", err)
+		}
+		return s.post(ctx, channel.URL, "application/json", payload)
+	case domain.ChannelWebhook:
+		return s.post(ctx, channel.URL, channel.ContentType, []byte(message.Body))
+	case domain.ChannelEmail:
+		return s.mail(channel.To, message)
+	}
+	return fmt.Errorf("unsupported channel type %q", channel.Type)
+}
+
+func (s *NotificationSender) post(ctx context.Context, url, contentType string, payload []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to deliver notification: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("notification endpoint returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (s *NotificationSender) mail(to []string, message domain.NotificationMessage) error {
+	if s.smtp.Addr == "" || s.smtp.From == "" {
+		return fmt.Errorf("email channels require SMTP_ADDR and SMTP_FROM")
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", s.smtp.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", strings.NewReplacer("\r", " ", "\n", " ").Replace(message.Subject))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(message.Body, "\n", "\r\n"))
+
+	var auth smtp.Auth
+	if s.smtp.Username != "" {
+		host, _, err := net.SplitHostPort(s.smtp.Addr)
+		if err != nil {
+			return fmt.Errorf("invalid SMTP address: %w", err)
+		}
+		auth = smtp.PlainAuth("", s.smtp.Username, s.smtp.Password, host)
+	}
+
+	if err := smtp.SendMail(s.smtp.Addr, auth, s.smtp.From, to, msg.Bytes()); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	return nil
+}
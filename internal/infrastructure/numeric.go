@@ -0,0 +1,202 @@
+package infrastructure
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// LocaleAuto guesses separators from the value itself
+const LocaleAuto = "auto"
+
+// decimal and digit grouping separators of a number locale
+type numberFormat struct {
+	decimal rune
+	groups  []rune
+}
+
+var (
+	pointDecimal = numberFormat{decimal: '.', groups: []rune{','}}
+	commaDecimal = numberFormat{decimal: ',', groups: []rune{'.'}}
+	spaceGrouped = numberFormat{decimal: ',', groups: []rune{' ', ' ', ' '}}
+	swissGrouped = numberFormat{decimal: '.', groups: []rune{'\'', '’'}}
+)
+
+var numberLocales = map[string]numberFormat{
+	"en":    pointDecimal,
+	"en-us": pointDecimal,
+	"en-gb": pointDecimal,
+	"ja":    pointDecimal,
+	"zh":    pointDecimal,
+	"es-mx": pointDecimal,
+	"de":    commaDecimal,
+	"es":    commaDecimal,
+	"it":    commaDecimal,
+	"nl":    commaDecimal,
+	"pt":    commaDecimal,
+	"pt-br": commaDecimal,
+	"da":    commaDecimal,
+	"id":    commaDecimal,
+	"tr":    commaDecimal,
+	"fr":    spaceGrouped,
+	"ru":    spaceGrouped,
+	"sv":    spaceGrouped,
+	"pl":    spaceGrouped,
+	"cs":    spaceGrouped,
+	"fi":    spaceGrouped,
+	"nb":    spaceGrouped,
+	"de-ch": swissGrouped,
+}
+
+// returns true if the locale is known or "auto"
+func isValidNumberLocale(locale string) bool {
+	if locale == "" || strings.EqualFold(locale, LocaleAuto) {
+		return true
+	}
+	_, ok := numberLocales[strings.ToLower(locale)]
+	return ok
+}
+
+// parses a string encoded number using the locale's separators, e.g.
+// "1,024" (en) or "1.234,56" (de). Grouping must be in blocks of three
+// digits so values in the wrong locale are rejected instead of misread.
+func parseLocaleNumber(s, locale string) (float64, error) {
+	normalized, err := normalizeLocaleNumber(s, locale)
+	if err != nil {
+		return 0, err
+	}
+	f, err := strconv.ParseFloat(normalized, 64)
+	if err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+		return 0, fmt.Errorf("expected a number, got %q", s)
+	}
+	return f, nil
+}
+
+// rewrites a string encoded number in the locale into Go number syntax
+func normalizeLocaleNumber(s, locale string) (string, error) {
+	value := strings.TrimSpace(s)
+	if value == "" {
+		return "", fmt.Errorf("expected a number, got an empty string")
+	}
+
+	var format numberFormat
+	switch {
+	case locale == "":
+		format = pointDecimal
+	case strings.EqualFold(locale, LocaleAuto):
+		format = guessNumberFormat(value)
+	default:
+		var ok bool
+		if format, ok = numberLocales[strings.ToLower(locale)]; !ok {
+			return "", fmt.Errorf("unsupported number locale %q", locale)
+		}
+	}
+
+	// plain Go syntax, including exponents, needs no rewriting
+	if format.decimal == '.' {
+		if _, err := strconv.ParseFloat(value, 64); err == nil {
+			return value, nil
+		}
+	}
+
+	normalized, ok := normalizeNumber(value, format)
+	if !ok {
+		return "", fmt.Errorf("expected a number, got %q", s)
+	}
+	return normalized, nil
+}
+
+// rewrites a localized number into Go syntax, validating digit grouping
+func normalizeNumber(value string, format numberFormat) (string, bool) {
+	sign := ""
+	if value[0] == '-' || value[0] == '+' {
+		sign, value = value[:1], value[1:]
+	}
+
+	integer, fraction := value, ""
+	if i := strings.LastIndex(value, string(format.decimal)); i >= 0 {
+		integer, fraction = value[:i], value[i+len(string(format.decimal)):]
+		if fraction == "" || !isDigits(fraction) {
+			return "", false
+		}
+	}
+
+	if integer == "" {
+		integer = "0"
+	}
+	if !isDigits(integer) {
+		blocks := splitGroups(integer, format.groups)
+		if len(blocks) < 2 || len(blocks[0]) == 0 || len(blocks[0]) > 3 || !isDigits(blocks[0]) {
+			return "", false
+		}
+		for _, block := range blocks[1:] {
+			if len(block) != 3 || !isDigits(block) {
+				return "", false
+			}
+		}
+		integer = strings.Join(blocks, "")
+	}
+
+	if fraction == "" {
+		return sign + integer, true
+	}
+	return sign + integer + "." + fraction, true
+}
+
+// picks separators from the value: when both "." and "," appear the last
+// one is the decimal separator; a single separator followed by exactly
+// three digits is read as grouping ("1,024"), anything else as decimal
+func guessNumberFormat(value string) numberFormat {
+	lastPoint := strings.LastIndexByte(value, '.')
+	lastComma := strings.LastIndexByte(value, ',')
+
+	switch {
+	case lastPoint >= 0 && lastComma >= 0:
+		if lastComma > lastPoint {
+			return commaDecimal
+		}
+		return pointDecimal
+	case lastComma >= 0:
+		if strings.Count(value, ",") > 1 || len(value)-lastComma-1 == 3 {
+			return pointDecimal
+		}
+		return commaDecimal
+	case lastPoint >= 0:
+		if strings.Count(value, ".") > 1 {
+			return commaDecimal
+		}
+		return pointDecimal
+	case strings.ContainsAny(value, "   "):
+		return spaceGrouped
+	}
+	return pointDecimal
+}
+
+// splits on any of the separators, keeping empty blocks so "1,,024" is rejected
+func splitGroups(value string, separators []rune) []string {
+	var blocks []string
+	var current strings.Builder
+	for _, r := range value {
+		if slices.Contains(separators, r) {
+			blocks = append(blocks, current.String())
+			current.Reset()
+			continue
+		}
+		current.WriteRune(r)
+	}
+	return append(blocks, current.String())
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
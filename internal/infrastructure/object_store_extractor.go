@@ -0,0 +1,176 @@
+package infrastructure
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"etlgo/internal/domain"
+	"etlgo/pkg/logger"
+	"etlgo/pkg/metrics"
+)
+
+// formats of source files, by key extension
+const (
+	objectFormatJSON   = "json"
+	objectFormatNDJSON = "ndjson"
+	objectFormatCSV    = "csv"
+)
+
+// implements domain.ExternalAPIClient over the files sources drop in an
+// object store bucket, under a prefix per source. Every run reads the
+// files not ingested yet, or changed since, decoding them with the field
+// mappings by their extension: .json like an API response, .ndjson or
+// .jsonl one record per line, and .csv like CSV files. The files read are
+// returned with the data, to be recorded once the run succeeds. Files hold
+// their records in full, so a since is ignored.
+type ObjectStoreExtractor struct {
+	store    *S3Client
+	prefixes map[string]string // by source
+	objects  domain.SourceObjectRepository
+	mapper   *FieldMapper
+	logger   *logger.Logger
+	metrics  *metrics.Metrics
+}
+
+// creates an extractor reading ads and CRM files under the prefixes
+func NewObjectStoreExtractor(store *S3Client, adsPrefix, crmPrefix string, objects domain.SourceObjectRepository, mapper *FieldMapper, logger *logger.Logger, metrics *metrics.Metrics) *ObjectStoreExtractor {
+	return &ObjectStoreExtractor{
+		store: store,
+		prefixes: map[string]string{
+			domain.SourceAds: adsPrefix,
+			domain.SourceCRM: crmPrefix,
+		},
+		objects: objects,
+		mapper:  mapper,
+		logger:  logger,
+		metrics: metrics,
+	}
+}
+
+// reads ads from the new files under the ads prefix
+func (e *ObjectStoreExtractor) FetchAdsData(ctx context.Context, since *time.Time) (*domain.AdData, error) {
+	page, objects, err := e.fetch(ctx, domain.SourceAds)
+	if err != nil {
+		return nil, err
+	}
+	data := page.adData()
+	data.Objects = objects
+	return data, nil
+}
+
+// reads opportunities from the new files under the CRM prefix
+func (e *ObjectStoreExtractor) FetchCRMData(ctx context.Context, since *time.Time) (*domain.CRMData, error) {
+	page, objects, err := e.fetch(ctx, domain.SourceCRM)
+	if err != nil {
+		return nil, err
+	}
+	data := page.crmData()
+	data.Objects = objects
+	return data, nil
+}
+
+// the listing opens the connection
+func (e *ObjectStoreExtractor) Prewarm(ctx context.Context) {}
+
+// the ingested objects record what was extracted, so the files of any date
+// are read
+func (e *ObjectStoreExtractor) TracksExtraction(source string) bool {
+	return true
+}
+
+// reads and decodes the source's files not ingested yet, in key order.
+// Rejected rows are named after their file.
+func (e *ObjectStoreExtractor) fetch(ctx context.Context, source string) (mappedPage, []domain.SourceObject, error) {
+	tenant := domain.TenantFromContext(ctx)
+	if tenant == "" {
+		tenant = domain.DefaultTenant
+	}
+
+	listed, err := e.store.ListObjects(ctx, e.prefixes[source])
+	if err != nil {
+		return mappedPage{}, nil, err
+	}
+
+	var page mappedPage
+	var objects []domain.SourceObject
+	skipped := 0
+	for _, object := range listed {
+		format := objectFormat(object.Key)
+		if format == "" {
+			continue
+		}
+		ingested, err := e.objects.Get(ctx, tenant, source, object.Key)
+		if err != nil {
+			return mappedPage{}, nil, fmt.Errorf("failed to read ingested %s objects: %w", source, err)
+		}
+		if ingested != nil && ingested.ETag == object.ETag {
+			skipped++
+			continue
+		}
+
+		body, err := e.store.GetObject(ctx, object.Key)
+		if err != nil {
+			return mappedPage{}, nil, err
+		}
+		file, err := e.decode(source, format, body)
+		if err != nil {
+			e.metrics.RecordExternalAPIFailure("s3", "decode_error")
+			return mappedPage{}, nil, fmt.Errorf("failed to decode %s object %s: %w", source, object.Key, err)
+		}
+		for i := range file.rejected {
+			rejected := &file.rejected[i]
+			rejected.Record = object.Key + " " + rejected.Record
+			for j := range rejected.Errors {
+				rejected.Errors[j].Record = rejected.Record
+			}
+		}
+
+		page.records = append(page.records, file.records...)
+		page.rejected = append(page.rejected, file.rejected...)
+		objects = append(objects, domain.SourceObject{
+			Source:  source,
+			Tenant:  tenant,
+			Key:     object.Key,
+			ETag:    object.ETag,
+			Size:    object.Size,
+			Records: file.len(),
+		})
+	}
+
+	e.logger.WithContext(ctx).WithFields(map[string]any{
+		"source":  source,
+		"prefix":  e.prefixes[source],
+		"objects": len(objects),
+		"skipped": skipped,
+		"records": page.len(),
+	}).Info("Read source objects")
+	return page, objects, nil
+}
+
+func (e *ObjectStoreExtractor) decode(source, format string, body []byte) (mappedPage, error) {
+	switch format {
+	case objectFormatNDJSON:
+		return e.mapper.decodeNDJSON(source, bytes.NewReader(body), 0)
+	case objectFormatCSV:
+		return e.mapper.decodeCSV(source, bytes.NewReader(body), 0)
+	}
+	return e.mapper.decode(source, body, 0)
+}
+
+// returns the format of a source file by its extension, or "" for keys
+// that are not source files
+func objectFormat(key string) string {
+	switch strings.ToLower(path.Ext(key)) {
+	case ".json":
+		return objectFormatJSON
+	case ".ndjson", ".jsonl":
+		return objectFormatNDJSON
+	case ".csv":
+		return objectFormatCSV
+	}
+	return ""
+}
@@ -0,0 +1,219 @@
+package infrastructure
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"etlgo/internal/domain"
+)
+
+// pages fetched from one upstream API at most unless configured otherwise
+const defaultMaxPages = 1000
+
+// the pagination of a source with its defaults applied
+type compiledPagination struct {
+	strategy   string
+	param      string
+	firstPage  int
+	sizeParam  string
+	pageSize   int
+	nextCursor []pathSegment
+	maxPages   int
+}
+
+func compilePagination(source string, pagination *domain.Pagination) (*compiledPagination, error) {
+	if pagination == nil {
+		return nil, nil
+	}
+
+	compiled := &compiledPagination{
+		strategy:  pagination.Strategy,
+		param:     pagination.Param,
+		firstPage: 1,
+		sizeParam: pagination.SizeParam,
+		pageSize:  pagination.PageSize,
+		maxPages:  pagination.MaxPages,
+	}
+	if pagination.PageSize < 0 || pagination.MaxPages < 0 {
+		return nil, fmt.Errorf("%s pagination: page_size and max_pages must not be negative", source)
+	}
+	if compiled.maxPages == 0 {
+		compiled.maxPages = defaultMaxPages
+	}
+
+	switch pagination.Strategy {
+	case domain.PaginationPage:
+		if compiled.param == "" {
+			compiled.param = "page"
+		}
+		if pagination.FirstPage != nil {
+			compiled.firstPage = *pagination.FirstPage
+		}
+		if compiled.sizeParam != "" && compiled.pageSize == 0 {
+			return nil, fmt.Errorf("%s pagination: size_param requires page_size", source)
+		}
+	case domain.PaginationCursor:
+		if compiled.param == "" {
+			compiled.param = "cursor"
+		}
+		if pagination.NextCursor == "" {
+			return nil, fmt.Errorf("%s pagination: cursor pagination requires next_cursor", source)
+		}
+		path, err := parsePath(pagination.NextCursor)
+		if err != nil {
+			return nil, fmt.Errorf("%s pagination next_cursor: %w", source, err)
+		}
+		compiled.nextCursor = path
+	case domain.PaginationLink:
+	default:
+		return nil, fmt.Errorf("%s pagination: unsupported strategy %q, use %s, %s or %s", source, pagination.Strategy,
+			domain.PaginationPage, domain.PaginationCursor, domain.PaginationLink)
+	}
+
+	if pagination.Strategy != domain.PaginationPage && (pagination.FirstPage != nil || compiled.sizeParam != "" || compiled.pageSize != 0) {
+		return nil, fmt.Errorf("%s pagination: first_page, size_param and page_size only apply to page pagination", source)
+	}
+	if pagination.Strategy != domain.PaginationCursor && pagination.NextCursor != "" {
+		return nil, fmt.Errorf("%s pagination: next_cursor only applies to cursor pagination", source)
+	}
+	if pagination.Strategy == domain.PaginationLink && compiled.param != "" {
+		return nil, fmt.Errorf("%s pagination: param does not apply to link pagination", source)
+	}
+	return compiled, nil
+}
+
+// walks the pages of one fetch. A nil pagination fetches a single page.
+type pager struct {
+	pagination *compiledPagination
+	next       string // URL of the next page, empty once the last was fetched
+	pages      int
+	cursor     string
+}
+
+// starts a fetch at the API's URL
+func newPager(pagination *compiledPagination, rawURL string) (*pager, error) {
+	p := &pager{pagination: pagination, next: rawURL}
+	if pagination != nil && pagination.strategy == domain.PaginationPage {
+		pageURL, err := p.withQuery(rawURL, pagination.firstPage)
+		if err != nil {
+			return nil, err
+		}
+		p.next = pageURL
+	}
+	return p, nil
+}
+
+// returns the URL of the next page, or false after the last page
+func (p *pager) nextURL() (string, bool, error) {
+	if p.next == "" {
+		return "", false, nil
+	}
+	limit := 1
+	if p.pagination != nil {
+		limit = p.pagination.maxPages
+	}
+	if p.pages >= limit {
+		return "", false, fmt.Errorf("more than %d pages", limit)
+	}
+	return p.next, true, nil
+}
+
+// moves past the fetched page, given its URL, response header and decoded
+// records
+func (p *pager) advance(pageURL string, header http.Header, page mappedPage) error {
+	p.pages++
+	current := p.next
+	p.next = ""
+	if p.pagination == nil {
+		return nil
+	}
+
+	switch p.pagination.strategy {
+	case domain.PaginationPage:
+		records := page.len()
+		if records == 0 || p.pagination.pageSize > 0 && records < p.pagination.pageSize {
+			return nil
+		}
+		next, err := p.withQuery(current, p.pagination.firstPage+p.pages)
+		if err != nil {
+			return err
+		}
+		p.next = next
+	case domain.PaginationCursor:
+		if page.cursor == "" {
+			return nil
+		}
+		if page.cursor == p.cursor {
+			return fmt.Errorf("next cursor %q repeats the previous one", page.cursor)
+		}
+		p.cursor = page.cursor
+		next, err := setQuery(current, p.pagination.param, page.cursor)
+		if err != nil {
+			return err
+		}
+		p.next = next
+	case domain.PaginationLink:
+		link := nextLink(header.Values("Link"))
+		if link == "" {
+			return nil
+		}
+		base, err := url.Parse(pageURL)
+		if err != nil {
+			return err
+		}
+		next, err := base.Parse(link)
+		if err != nil {
+			return fmt.Errorf("invalid next link %q: %w", link, err)
+		}
+		p.next = next.String()
+	}
+	return nil
+}
+
+// sets the page number, and the page size when configured
+func (p *pager) withQuery(rawURL string, page int) (string, error) {
+	pageURL, err := setQuery(rawURL, p.pagination.param, strconv.Itoa(page))
+	if err != nil || p.pagination.sizeParam == "" {
+		return pageURL, err
+	}
+	return setQuery(pageURL, p.pagination.sizeParam, strconv.Itoa(p.pagination.pageSize))
+}
+
+func setQuery(rawURL, param, value string) (string, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	query := parsed.Query()
+	query.Set(param, value)
+	parsed.RawQuery = query.Encode()
+	return parsed.String(), nil
+}
+
+// returns the target of the rel="next" link of Link headers as in RFC 8288,
+// e.g. `<https://api.example.com/ads?page=2>; rel="next"`
+func nextLink(headers []string) string {
+	for _, header := range headers {
+		for _, link := range strings.Split(header, ",") {
+			target, params, ok := strings.Cut(strings.TrimSpace(link), ";")
+			if !ok || !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+			for _, param := range strings.Split(params, ";") {
+				name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+				if !strings.EqualFold(name, "rel") {
+					continue
+				}
+				for _, rel := range strings.Fields(strings.Trim(value, `"`)) {
+					if strings.EqualFold(rel, "next") {
+						return strings.TrimSpace(target[1 : len(target)-1])
+					}
+				}
+			}
+		}
+	}
+	return ""
+}
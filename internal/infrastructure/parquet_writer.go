@@ -0,0 +1,320 @@
+package infrastructure
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+
+	"etlgo/internal/domain"
+)
+
+// Minimal Parquet writer used for raw exports. It writes a single row group
+// with one uncompressed, PLAIN encoded data page per column. All columns are
+// REQUIRED, which keeps the pages free of definition/repetition levels.
+
+type columnKind int
+
+const (
+	kindString columnKind = iota
+	kindInt64
+	kindDouble
+	kindDecimal // domain.Money as DECIMAL(18,6) backed by INT64
+	kindTimestamp
+)
+
+// a single column of tabular export data
+type tableColumn struct {
+	name   string
+	kind   columnKind
+	values []any
+}
+
+// Parquet physical types
+const (
+	parquetTypeInt64     = 2
+	parquetTypeDouble    = 5
+	parquetTypeByteArray = 6
+)
+
+// Parquet converted types
+const (
+	parquetConvertedUTF8            = 0
+	parquetConvertedDecimal         = 5
+	parquetConvertedTimestampMillis = 9
+)
+
+// scale and precision of money columns
+const (
+	parquetMoneyScale     = 6
+	parquetMoneyPrecision = 18
+)
+
+const parquetMagic = "PAR1"
+
+// thrift compact protocol type ids
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// encodes the columns as a Parquet file. Column pages are encoded
+// concurrently by up to workers goroutines.
+func encodeParquet(ctx context.Context, columns []tableColumn, numRows, workers int) ([]byte, error) {
+	var file bytes.Buffer
+	file.WriteString(parquetMagic)
+
+	var chunks []*thriftWriter
+	var totalSize int64
+
+	err := runPipeline(ctx, len(columns), workers, true,
+		func(i int) ([]byte, error) {
+			col := columns[i]
+			if len(col.values) != numRows {
+				return nil, fmt.Errorf("column %s has %d values, expected %d", col.name, len(col.values), numRows)
+			}
+			return plainEncode(col)
+		},
+		func(_ context.Context, i int, data []byte) error {
+			col := columns[i]
+
+			header := newThriftWriter()
+			header.i32Field(1, 0) // DATA_PAGE
+			header.i32Field(2, int32(len(data)))
+			header.i32Field(3, int32(len(data)))
+			header.structField(5)
+			header.i32Field(1, int32(numRows))
+			header.i32Field(2, 0) // PLAIN
+			header.i32Field(3, 3) // RLE
+			header.i32Field(4, 3) // RLE
+			header.endStruct()
+			header.stop()
+
+			offset := int64(file.Len())
+			file.Write(header.bytes())
+			file.Write(data)
+			size := int64(file.Len()) - offset
+			totalSize += size
+
+			chunk := newThriftWriter()
+			chunk.i64Field(2, offset)
+			chunk.structField(3)
+			chunk.i32Field(1, physicalType(col.kind))
+			chunk.listField(2, thriftI32, 1)
+			chunk.varint(uint64(zigzag32(0))) // PLAIN
+			chunk.listField(3, thriftBinary, 1)
+			chunk.binary([]byte(col.name))
+			chunk.i32Field(4, 0) // UNCOMPRESSED
+			chunk.i64Field(5, int64(numRows))
+			chunk.i64Field(6, size)
+			chunk.i64Field(7, size)
+			chunk.i64Field(9, offset)
+			chunk.endStruct()
+			chunk.stop()
+			chunks = append(chunks, chunk)
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	meta := newThriftWriter()
+	meta.i32Field(1, 1)
+	meta.listField(2, thriftStruct, len(columns)+1)
+
+	// Root schema element
+	meta.beginStruct()
+	meta.binaryField(4, []byte("schema"))
+	meta.i32Field(5, int32(len(columns)))
+	meta.endStruct()
+
+	for _, col := range columns {
+		meta.beginStruct()
+		meta.i32Field(1, physicalType(col.kind))
+		meta.i32Field(3, 0) // REQUIRED
+		meta.binaryField(4, []byte(col.name))
+		switch col.kind {
+		case kindString:
+			meta.i32Field(6, parquetConvertedUTF8)
+		case kindDecimal:
+			meta.i32Field(6, parquetConvertedDecimal)
+			meta.i32Field(7, parquetMoneyScale)
+			meta.i32Field(8, parquetMoneyPrecision)
+		case kindTimestamp:
+			meta.i32Field(6, parquetConvertedTimestampMillis)
+		}
+		meta.endStruct()
+	}
+
+	meta.i64Field(3, int64(numRows))
+	meta.listField(4, thriftStruct, 1)
+	meta.beginStruct()
+	meta.listField(1, thriftStruct, len(chunks))
+	for _, chunk := range chunks {
+		meta.raw(chunk.bytes())
+	}
+	meta.i64Field(2, totalSize)
+	meta.i64Field(3, int64(numRows))
+	meta.endStruct()
+	meta.binaryField(6, []byte("etlgo"))
+	meta.stop()
+
+	footer := meta.bytes()
+	file.Write(footer)
+	binary.Write(&file, binary.LittleEndian, uint32(len(footer)))
+	file.WriteString(parquetMagic)
+
+	return file.Bytes(), nil
+}
+
+// PLAIN encodes the values of a column
+func plainEncode(col tableColumn) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, v := range col.values {
+		switch col.kind {
+		case kindString:
+			s, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("column %s: expected string, got %T", col.name, v)
+			}
+			binary.Write(&buf, binary.LittleEndian, uint32(len(s)))
+			buf.WriteString(s)
+		case kindInt64:
+			n, ok := v.(int)
+			if !ok {
+				return nil, fmt.Errorf("column %s: expected int, got %T", col.name, v)
+			}
+			binary.Write(&buf, binary.LittleEndian, int64(n))
+		case kindDouble:
+			f, ok := v.(float64)
+			if !ok {
+				return nil, fmt.Errorf("column %s: expected float64, got %T", col.name, v)
+			}
+			binary.Write(&buf, binary.LittleEndian, math.Float64bits(f))
+		case kindDecimal:
+			m, ok := v.(domain.Money)
+			if !ok {
+				return nil, fmt.Errorf("column %s: expected domain.Money, got %T", col.name, v)
+			}
+			binary.Write(&buf, binary.LittleEndian, int64(m))
+		case kindTimestamp:
+			t, ok := v.(time.Time)
+			if !ok {
+				return nil, fmt.Errorf("column %s: expected time.Time, got %T", col.name, v)
+			}
+			binary.Write(&buf, binary.LittleEndian, t.UnixMilli())
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func physicalType(kind columnKind) int32 {
+	switch kind {
+	case kindInt64, kindDecimal, kindTimestamp:
+		return parquetTypeInt64
+	case kindDouble:
+		return parquetTypeDouble
+	}
+	return parquetTypeByteArray
+}
+
+// thriftWriter writes structs using the thrift compact protocol
+type thriftWriter struct {
+	buf     bytes.Buffer
+	lastIDs []int16
+}
+
+func newThriftWriter() *thriftWriter {
+	return &thriftWriter{lastIDs: []int16{0}}
+}
+
+func (w *thriftWriter) bytes() []byte {
+	return w.buf.Bytes()
+}
+
+func (w *thriftWriter) fieldHeader(id int16, typ byte) {
+	last := w.lastIDs[len(w.lastIDs)-1]
+	if delta := id - last; delta > 0 && delta <= 15 {
+		w.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		w.buf.WriteByte(typ)
+		w.varint(uint64(zigzag32(int32(id))))
+	}
+	w.lastIDs[len(w.lastIDs)-1] = id
+}
+
+func (w *thriftWriter) i32Field(id int16, v int32) {
+	w.fieldHeader(id, thriftI32)
+	w.varint(uint64(zigzag32(v)))
+}
+
+func (w *thriftWriter) i64Field(id int16, v int64) {
+	w.fieldHeader(id, thriftI64)
+	w.varint(zigzag64(v))
+}
+
+func (w *thriftWriter) binaryField(id int16, b []byte) {
+	w.fieldHeader(id, thriftBinary)
+	w.binary(b)
+}
+
+// starts a nested struct field, closed by endStruct
+func (w *thriftWriter) structField(id int16) {
+	w.fieldHeader(id, thriftStruct)
+	w.beginStruct()
+}
+
+// starts a struct without a field header, used for list elements
+func (w *thriftWriter) beginStruct() {
+	w.lastIDs = append(w.lastIDs, 0)
+}
+
+// writes the stop byte and leaves the current struct
+func (w *thriftWriter) endStruct() {
+	w.buf.WriteByte(0)
+	w.lastIDs = w.lastIDs[:len(w.lastIDs)-1]
+}
+
+// writes the list header; elements are written by the caller
+func (w *thriftWriter) listField(id int16, elemType byte, size int) {
+	w.fieldHeader(id, thriftList)
+	if size < 15 {
+		w.buf.WriteByte(byte(size)<<4 | elemType)
+	} else {
+		w.buf.WriteByte(0xF0 | elemType)
+		w.varint(uint64(size))
+	}
+}
+
+// writes the stop byte of the top level struct
+func (w *thriftWriter) stop() {
+	w.buf.WriteByte(0)
+}
+
+func (w *thriftWriter) binary(b []byte) {
+	w.varint(uint64(len(b)))
+	w.buf.Write(b)
+}
+
+func (w *thriftWriter) raw(b []byte) {
+	w.buf.Write(b)
+}
+
+func (w *thriftWriter) varint(v uint64) {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], v)
+	w.buf.Write(tmp[:n])
+}
+
+func zigzag32(v int32) uint32 {
+	return uint32((v << 1) ^ (v >> 31))
+}
+
+func zigzag64(v int64) uint64 {
+	return uint64((v << 1) ^ (v >> 63))
+}
@@ -0,0 +1,155 @@
+package infrastructure
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"etlgo/internal/domain"
+	"etlgo/pkg/logger"
+)
+
+// implements domain.PipelineRepository interface
+type PipelineRepository struct {
+	pipelines map[string]domain.Pipeline
+	history   map[string][]domain.PipelineRevision
+	mutex     sync.RWMutex
+	clock     domain.Clock
+	logger    *logger.Logger
+}
+
+// creates a new pipeline repository
+func NewPipelineRepository(clock domain.Clock, logger *logger.Logger) *PipelineRepository {
+	return &PipelineRepository{
+		pipelines: make(map[string]domain.Pipeline),
+		history:   make(map[string][]domain.PipelineRevision),
+		clock:     clock,
+		logger:    logger,
+	}
+}
+
+func (r *PipelineRepository) Create(ctx context.Context, pipeline domain.Pipeline) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, exists := r.pipelines[pipeline.Name]; exists {
+		return domain.ErrPipelineExists
+	}
+
+	pipeline.Version = len(r.history[pipeline.Name]) + 1
+	r.pipelines[pipeline.Name] = pipeline
+	r.record(pipeline, "create", pipeline.CreatedBy, pipeline.CreatedAt)
+
+	r.logger.WithContext(ctx).WithField("pipeline", pipeline.Name).Info("Created pipeline")
+	return nil
+}
+
+func (r *PipelineRepository) Update(ctx context.Context, pipeline domain.Pipeline) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	existing, exists := r.pipelines[pipeline.Name]
+	if !exists {
+		return domain.ErrPipelineNotFound
+	}
+
+	pipeline.CreatedBy = existing.CreatedBy
+	pipeline.CreatedAt = existing.CreatedAt
+	pipeline.Version = existing.Version + 1
+	r.pipelines[pipeline.Name] = pipeline
+	r.record(pipeline, "update", pipeline.UpdatedBy, pipeline.UpdatedAt)
+
+	r.logger.WithContext(ctx).WithFields(map[string]any{
+		"pipeline": pipeline.Name,
+		"version":  pipeline.Version,
+	}).Info("Updated pipeline")
+	return nil
+}
+
+func (r *PipelineRepository) Delete(ctx context.Context, name, actor string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	existing, exists := r.pipelines[name]
+	if !exists {
+		return domain.ErrPipelineNotFound
+	}
+
+	delete(r.pipelines, name)
+	existing.Version++
+	r.record(existing, "delete", actor, r.clock.Now())
+
+	r.logger.WithContext(ctx).WithField("pipeline", name).Info("Deleted pipeline")
+	return nil
+}
+
+func (r *PipelineRepository) Get(ctx context.Context, name string) (*domain.Pipeline, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	pipeline, exists := r.pipelines[name]
+	if !exists {
+		return nil, domain.ErrPipelineNotFound
+	}
+	return &pipeline, nil
+}
+
+func (r *PipelineRepository) List(ctx context.Context) ([]domain.Pipeline, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	pipelines := make([]domain.Pipeline, 0, len(r.pipelines))
+	for _, pipeline := range r.pipelines {
+		pipelines = append(pipelines, pipeline)
+	}
+
+	sort.Slice(pipelines, func(i, j int) bool {
+		return pipelines[i].Name < pipelines[j].Name
+	})
+	return pipelines, nil
+}
+
+// returns every revision of a pipeline, including deleted ones
+func (r *PipelineRepository) History(ctx context.Context, name string) ([]domain.PipelineRevision, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	history, exists := r.history[name]
+	if !exists {
+		return nil, domain.ErrPipelineNotFound
+	}
+	return append([]domain.PipelineRevision(nil), history...), nil
+}
+
+// appends a revision; must be called with the lock held
+func (r *PipelineRepository) record(pipeline domain.Pipeline, action, actor string, at time.Time) {
+	r.history[pipeline.Name] = append(r.history[pipeline.Name], domain.PipelineRevision{
+		Version:   pipeline.Version,
+		Action:    action,
+		ChangedBy: actor,
+		ChangedAt: at,
+		Pipeline:  pipeline,
+	})
+}
+
+// loads pipeline definitions from a JSON array so scheduled pipelines
+// survive restarts. An empty path loads none.
+func LoadPipelines(path string) ([]domain.Pipeline, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pipeline file: %w", err)
+	}
+	var pipelines []domain.Pipeline
+	if err := json.Unmarshal(raw, &pipelines); err != nil {
+		return nil, fmt.Errorf("failed to parse pipeline file: %w", err)
+	}
+	return pipelines, nil
+}
@@ -0,0 +1,83 @@
+package infrastructure
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"etlgo/internal/domain"
+	"etlgo/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+// implements domain.QuarantineRepository interface. Only the most recent
+// maxRecords rows are kept.
+type QuarantineRepository struct {
+	records    []domain.QuarantinedRecord
+	maxRecords int
+	mutex      sync.RWMutex
+	clock      domain.Clock
+	logger     *logger.Logger
+}
+
+// creates a new quarantine repository
+func NewQuarantineRepository(maxRecords int, clock domain.Clock, logger *logger.Logger) *QuarantineRepository {
+	return &QuarantineRepository{
+		maxRecords: maxRecords,
+		clock:      clock,
+		logger:     logger,
+	}
+}
+
+func (r *QuarantineRepository) Store(ctx context.Context, records []domain.QuarantinedRecord) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	now := r.clock.Now()
+	for _, record := range records {
+		record.ID = uuid.New().String()
+		record.QuarantinedAt = now
+		r.records = append(r.records, record)
+	}
+
+	if overflow := len(r.records) - r.maxRecords; r.maxRecords > 0 && overflow > 0 {
+		r.records = append([]domain.QuarantinedRecord(nil), r.records[overflow:]...)
+	}
+
+	r.logger.WithContext(ctx).WithField("count", len(records)).Info("Quarantined rejected rows")
+	return nil
+}
+
+// returns the most recent records first, optionally filtered by source
+func (r *QuarantineRepository) List(ctx context.Context, source string, limit int) ([]domain.QuarantinedRecord, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	result := make([]domain.QuarantinedRecord, 0)
+	for i := len(r.records) - 1; i >= 0; i-- {
+		if limit > 0 && len(result) >= limit {
+			break
+		}
+		if source != "" && r.records[i].Source != source {
+			continue
+		}
+		result = append(result, r.records[i])
+	}
+
+	return result, nil
+}
+
+// drops the records with the IDs
+func (r *QuarantineRepository) Delete(ctx context.Context, ids []string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	before := len(r.records)
+	r.records = slices.DeleteFunc(r.records, func(record domain.QuarantinedRecord) bool {
+		return slices.Contains(ids, record.ID)
+	})
+
+	r.logger.WithContext(ctx).WithField("count", before-len(r.records)).Info("Deleted quarantined rows")
+	return nil
+}
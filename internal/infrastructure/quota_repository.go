@@ -0,0 +1,124 @@
+package infrastructure
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"etlgo/internal/domain"
+	"etlgo/pkg/logger"
+)
+
+// implements domain.QuotaRepository interface. Counters are kept in memory
+// and, when a path is set, written to a JSON file after every change so
+// usage survives restarts instead of granting every quota afresh.
+type QuotaRepository struct {
+	path   string
+	usage  map[domain.QuotaKey]int64
+	mutex  sync.Mutex
+	logger *logger.Logger
+}
+
+// one counter of the usage file
+type quotaCounter struct {
+	Quota   string `json:"quota"`
+	Subject string `json:"subject"`
+	Period  string `json:"period"`
+	Used    int64  `json:"used"`
+}
+
+// creates a quota repository, loading the file at path when it exists. An
+// empty path keeps the counters in memory only.
+func NewQuotaRepository(path string, logger *logger.Logger) (*QuotaRepository, error) {
+	r := &QuotaRepository{
+		path:   path,
+		usage:  make(map[domain.QuotaKey]int64),
+		logger: logger,
+	}
+	if path == "" {
+		return r, nil
+	}
+
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read quota usage: %w", err)
+	}
+	var counters []quotaCounter
+	if err := json.Unmarshal(raw, &counters); err != nil {
+		return nil, fmt.Errorf("failed to parse quota usage: %w", err)
+	}
+	for _, counter := range counters {
+		r.usage[domain.QuotaKey{Quota: counter.Quota, Subject: counter.Subject, Period: counter.Period}] = counter.Used
+	}
+	return r, nil
+}
+
+func (r *QuotaRepository) Consume(ctx context.Context, key domain.QuotaKey, n, limit int64) (int64, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	used := r.usage[key]
+	if limit > 0 && used+n > limit {
+		return used, domain.ErrQuotaExceeded
+	}
+	r.usage[key] = used + n
+	return used + n, r.save()
+}
+
+func (r *QuotaRepository) Get(ctx context.Context, key domain.QuotaKey) (int64, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.usage[key], nil
+}
+
+// writes the counters to a temporary file and renames it over the old one
+func (r *QuotaRepository) save() error {
+	if r.path == "" {
+		return nil
+	}
+
+	counters := make([]quotaCounter, 0, len(r.usage))
+	for key, used := range r.usage {
+		counters = append(counters, quotaCounter{Quota: key.Quota, Subject: key.Subject, Period: key.Period, Used: used})
+	}
+	sort.Slice(counters, func(i, j int) bool {
+		a, b := counters[i], counters[j]
+		if a.Quota != b.Quota {
+			return a.Quota < b.Quota
+		}
+		if a.Subject != b.Subject {
+			return a.Subject < b.Subject
+		}
+		return a.Period < b.Period
+	})
+	raw, err := json.Marshal(counters)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(r.path), filepath.Base(r.path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write quota usage: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(raw); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write quota usage: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write quota usage: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), r.path); err != nil {
+		return fmt.Errorf("failed to write quota usage: %w", err)
+	}
+	return nil
+}
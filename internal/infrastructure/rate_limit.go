@@ -0,0 +1,131 @@
+package infrastructure
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"etlgo/internal/domain"
+	"etlgo/pkg/metrics"
+
+	"golang.org/x/time/rate"
+)
+
+// the upstream APIs with their own rate limiter
+var rateLimitedAPIs = []string{"ads", "crm", "keywords", "sink"}
+
+// request rates of the upstream APIs. Each API has its own limiter allowing
+// PerSecond requests with bursts of Burst; Limits and Bursts override them
+// per API (ads, crm or sink). A rate of 0 disables limiting.
+type RateLimitOptions struct {
+	PerSecond int
+	Burst     int
+	Limits    domain.QuotaLimits
+	Bursts    domain.QuotaLimits
+}
+
+func (o RateLimitOptions) Validate() error {
+	for _, overrides := range []domain.QuotaLimits{o.Limits, o.Bursts} {
+		for api := range overrides {
+			if !slices.Contains(rateLimitedAPIs, api) {
+				return fmt.Errorf("unknown rate limited API %q, expected one of %v", api, rateLimitedAPIs)
+			}
+		}
+	}
+	for _, api := range rateLimitedAPIs {
+		perSecond, burst := o.limitOf(api)
+		if perSecond < 0 {
+			return fmt.Errorf("rate limit of %s cannot be negative", api)
+		}
+		if perSecond > 0 && burst < 1 {
+			return fmt.Errorf("rate limit burst of %s must be at least 1, got %d", api, burst)
+		}
+	}
+	return nil
+}
+
+func (o RateLimitOptions) limitOf(api string) (perSecond, burst int) {
+	perSecond, burst = o.PerSecond, o.Burst
+	if limit, ok := o.Limits[api]; ok {
+		perSecond = int(limit)
+	}
+	if limit, ok := o.Bursts[api]; ok {
+		burst = int(limit)
+	}
+	return perSecond, burst
+}
+
+// the rate limiter of one upstream API, recording how long calls wait for it
+type upstreamLimiter struct {
+	api     string
+	limiter *rate.Limiter
+	metrics *metrics.Metrics
+}
+
+func newUpstreamLimiter(api string, opts RateLimitOptions, metrics *metrics.Metrics) *upstreamLimiter {
+	perSecond, burst := opts.limitOf(api)
+	limit := rate.Limit(perSecond)
+	if perSecond == 0 {
+		limit = rate.Inf
+	}
+	return &upstreamLimiter{api: api, limiter: rate.NewLimiter(limit, burst), metrics: metrics}
+}
+
+// blocks until a request may be sent or the context is done
+func (l *upstreamLimiter) Wait(ctx context.Context) error {
+	start := time.Now()
+	err := l.limiter.Wait(ctx)
+	outcome := "admitted"
+	if err != nil {
+		outcome = "cancelled"
+	}
+	l.metrics.RecordRateLimitWait(l.api, outcome, time.Since(start))
+	return err
+}
+
+// returns the limiter's current rate, with a PerSecond of 0 when unlimited
+func (l *upstreamLimiter) rateLimit() domain.RateLimit {
+	limit := domain.RateLimit{Burst: l.limiter.Burst()}
+	if l.limiter.Limit() != rate.Inf {
+		limit.PerSecond = int(l.limiter.Limit())
+	}
+	return limit
+}
+
+// changes the rate for requests that wait afterwards
+func (l *upstreamLimiter) set(limit domain.RateLimit) {
+	l.limiter.SetBurst(limit.Burst)
+	if limit.PerSecond == 0 {
+		l.limiter.SetLimit(rate.Inf)
+		return
+	}
+	l.limiter.SetLimit(rate.Limit(limit.PerSecond))
+}
+
+func (c *HTTPClient) limiters() map[string]*upstreamLimiter {
+	return map[string]*upstreamLimiter{"ads": c.adsLimiter, "crm": c.crmLimiter, "keywords": c.kwLimiter, "sink": c.sinkLimiter}
+}
+
+// RateLimits returns the current rate limit of every upstream API
+func (c *HTTPClient) RateLimits() map[string]domain.RateLimit {
+	limits := make(map[string]domain.RateLimit, len(rateLimitedAPIs))
+	for api, limiter := range c.limiters() {
+		limits[api] = limiter.rateLimit()
+	}
+	return limits
+}
+
+// SetRateLimit changes the rate limit of an upstream API while the client
+// runs
+func (c *HTTPClient) SetRateLimit(api string, limit domain.RateLimit) error {
+	limiter, ok := c.limiters()[api]
+	if !ok {
+		return domain.Errorf(domain.ErrValidation, "unknown rate limited API %q, expected one of %v", api, rateLimitedAPIs)
+	}
+	if err := limit.Validate(); err != nil {
+		return err
+	}
+	limiter.set(limit)
+	return nil
+}
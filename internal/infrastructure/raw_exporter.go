@@ -0,0 +1,306 @@
+package infrastructure
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"etlgo/internal/domain"
+	"etlgo/pkg/logger"
+)
+
+// implements domain.RawExportClient interface
+type RawExporter struct {
+	httpClient *HTTPClient
+	s3Client   *S3Client
+	encryptor  *PayloadEncryptor
+	outputDir  string
+	workers    int
+	logger     *logger.Logger
+}
+
+// creates a new raw exporter writing files to outputDir, the HTTP sink or S3.
+// s3Client and encryptor are optional; when an encryptor is set, objects
+// written to S3 are encrypted client-side. Records are encoded by up to
+// workers goroutines.
+func NewRawExporter(httpClient *HTTPClient, s3Client *S3Client, encryptor *PayloadEncryptor, outputDir string, workers int, logger *logger.Logger) *RawExporter {
+	return &RawExporter{
+		httpClient: httpClient,
+		s3Client:   s3Client,
+		encryptor:  encryptor,
+		outputDir:  outputDir,
+		workers:    workers,
+		logger:     logger,
+	}
+}
+
+// encodes and delivers processed ads records
+func (e *RawExporter) ExportAds(ctx context.Context, ads []domain.ProcessedAdData, req domain.RawExportRequest) (*domain.RawExportResult, error) {
+	table := []tableColumn{
+		{name: "date", kind: kindTimestamp},
+		{name: "campaign_id", kind: kindString},
+		{name: "channel", kind: kindString},
+		{name: "clicks", kind: kindInt64},
+		{name: "impressions", kind: kindInt64},
+		{name: "cost", kind: kindDecimal},
+		{name: "utm_campaign", kind: kindString},
+		{name: "utm_source", kind: kindString},
+		{name: "utm_medium", kind: kindString},
+		{name: "ad_group_id", kind: kindString},
+		{name: "quality_score", kind: kindDouble}, // 0 when not reported
+		{name: "processed_at", kind: kindTimestamp},
+	}
+
+	records := make([]any, len(ads))
+	for i, ad := range ads {
+		records[i] = ad
+		var qualityScore float64
+		if ad.QualityScore != nil {
+			qualityScore = *ad.QualityScore
+		}
+		appendRow(table, ad.Date, ad.CampaignID, ad.Channel, ad.Clicks, ad.Impressions, ad.Cost,
+			ad.UTMCampaign, ad.UTMSource, ad.UTMMedium, ad.AdGroupID, qualityScore, ad.ProcessedAt)
+	}
+
+	return e.export(ctx, domain.RawDatasetAds, records, table, req)
+}
+
+// encodes and delivers processed CRM opportunities
+func (e *RawExporter) ExportOpportunities(ctx context.Context, opportunities []domain.ProcessedOpportunity, req domain.RawExportRequest) (*domain.RawExportResult, error) {
+	table := []tableColumn{
+		{name: "opportunity_id", kind: kindString},
+		{name: "contact_email", kind: kindString},
+		{name: "stage", kind: kindString},
+		{name: "amount", kind: kindDecimal},
+		{name: "created_at", kind: kindTimestamp},
+		{name: "utm_campaign", kind: kindString},
+		{name: "utm_source", kind: kindString},
+		{name: "utm_medium", kind: kindString},
+		{name: "processed_at", kind: kindTimestamp},
+	}
+
+	records := make([]any, len(opportunities))
+	for i, opp := range opportunities {
+		records[i] = opp
+		appendRow(table, opp.OpportunityID, opp.ContactEmail, string(opp.Stage), opp.Amount, opp.CreatedAt,
+			opp.UTMCampaign, opp.UTMSource, opp.UTMMedium, opp.ProcessedAt)
+	}
+
+	return e.export(ctx, domain.RawDatasetCRM, records, table, req)
+}
+
+func (e *RawExporter) export(ctx context.Context, dataset string, records []any, table []tableColumn, req domain.RawExportRequest) (*domain.RawExportResult, error) {
+	filename := fmt.Sprintf("%s_%s_%s.%s", dataset, req.From.Format("20060102"), req.To.Format("20060102"), req.Format)
+
+	var location string
+	var size int
+	var err error
+	if req.Destination == domain.RawExportDestinationFile {
+		location, size, err = e.writeFile(ctx, filename, records, table, req.Format)
+	} else {
+		var payload bytes.Buffer
+		contentType, encodeErr := encodeRaw(ctx, &payload, req.Format, records, table, e.workers)
+		if encodeErr != nil {
+			return nil, fmt.Errorf("failed to encode %s export: %w", dataset, encodeErr)
+		}
+		size = payload.Len()
+
+		switch req.Destination {
+		case domain.RawExportDestinationSink:
+			location, err = e.httpClient.ExportFile(ctx, filename, contentType, payload.Bytes())
+		case domain.RawExportDestinationS3:
+			location, err = e.putObject(ctx, filename, contentType, payload.Bytes())
+		default:
+			err = domain.Errorf(domain.ErrValidation, "unsupported destination %q", req.Destination)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	e.logger.WithContext(ctx).WithFields(map[string]any{
+		"dataset":     dataset,
+		"format":      req.Format,
+		"destination": req.Destination,
+		"records":     len(records),
+		"bytes":       size,
+		"location":    location,
+	}).Info("Raw export delivered")
+
+	return &domain.RawExportResult{
+		Dataset:  dataset,
+		Records:  len(records),
+		Bytes:    size,
+		Location: location,
+	}, nil
+}
+
+// encodes the export straight into a file in the output directory and
+// returns its path and size. A partially written file is removed.
+func (e *RawExporter) writeFile(ctx context.Context, filename string, records []any, table []tableColumn, format domain.RawExportFormat) (string, int, error) {
+	if err := os.MkdirAll(e.outputDir, 0o755); err != nil {
+		return "", 0, fmt.Errorf("failed to create export directory: %w", err)
+	}
+
+	path := filepath.Join(e.outputDir, filename)
+	file, err := os.Create(path)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to write export file: %w", err)
+	}
+
+	buffered := bufio.NewWriter(file)
+	out := &countingWriter{w: buffered}
+	_, err = encodeRaw(ctx, out, format, records, table, e.workers)
+	if err == nil {
+		err = buffered.Flush()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return "", 0, fmt.Errorf("failed to write export file: %w", err)
+	}
+
+	return path, out.n, nil
+}
+
+func (e *RawExporter) putObject(ctx context.Context, filename, contentType string, payload []byte) (string, error) {
+	if e.s3Client == nil {
+		return "", fmt.Errorf("S3 destination not configured")
+	}
+
+	// The object carries the export's envelope as metadata
+	sum := sha256.Sum256(payload)
+	envelope := domain.ExportEnvelope{ExportID: filename, JobID: filename + "-" + hex.EncodeToString(sum[:8]), ChunkCount: 1}
+	metadata := map[string]string{
+		"export-id":       envelope.ExportID,
+		"export-job-id":   envelope.JobID,
+		"idempotency-key": envelope.IdempotencyKey(),
+	}
+
+	if e.encryptor == nil {
+		return e.s3Client.PutObject(ctx, filename, contentType, payload, metadata, nil)
+	}
+
+	sealed, err := e.encryptor.Encrypt(payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt export: %w", err)
+	}
+
+	metadata["encryption"] = EncryptionAlgorithm
+	metadata["encryption-key-id"] = e.encryptor.KeyID()
+	metadata["original-content-type"] = contentType
+	tags := map[string]string{
+		"encryption":        EncryptionAlgorithm,
+		"encryption-key-id": e.encryptor.KeyID(),
+	}
+
+	return e.s3Client.PutObject(ctx, filename+".enc", "application/octet-stream", sealed, metadata, tags)
+}
+
+func appendRow(table []tableColumn, values ...any) {
+	for i := range table {
+		table[i].values = append(table[i].values, values[i])
+	}
+}
+
+// encodes records in the requested format into w and returns the payload
+// content type. Records are encoded concurrently by up to workers
+// goroutines and written in order.
+func encodeRaw(ctx context.Context, w io.Writer, format domain.RawExportFormat, records []any, table []tableColumn, workers int) (string, error) {
+	switch format {
+	case domain.RawExportNDJSON:
+		err := encodeJSONRecords(ctx, records, workers, func(encoded []byte) error {
+			if _, err := w.Write(encoded); err != nil {
+				return err
+			}
+			_, err := w.Write([]byte{'\n'})
+			return err
+		})
+		return "application/x-ndjson", err
+
+	case domain.RawExportCSV:
+		header := make([]string, len(table))
+		for i, col := range table {
+			header[i] = col.name
+		}
+		writer := csv.NewWriter(w)
+		writer.Write(header)
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			return "", err
+		}
+
+		blocks := (len(records) + exportBlockRecords - 1) / exportBlockRecords
+		err := runPipeline(ctx, blocks, workers, true,
+			func(block int) ([]byte, error) {
+				start := block * exportBlockRecords
+				end := min(start+exportBlockRecords, len(records))
+
+				var buf bytes.Buffer
+				writer := csv.NewWriter(&buf)
+				line := make([]string, len(table))
+				for row := start; row < end; row++ {
+					for i, col := range table {
+						line[i] = formatCSVValue(col.values[row])
+					}
+					writer.Write(line)
+				}
+				writer.Flush()
+				return buf.Bytes(), writer.Error()
+			},
+			func(_ context.Context, _ int, encoded []byte) error {
+				_, err := w.Write(encoded)
+				return err
+			})
+		return "text/csv", err
+
+	case domain.RawExportParquet:
+		payload, err := encodeParquet(ctx, table, len(records), workers)
+		if err != nil {
+			return "", err
+		}
+		_, err = w.Write(payload)
+		return "application/vnd.apache.parquet", err
+	}
+
+	return "", domain.Errorf(domain.ErrValidation, "unsupported format %q", format)
+}
+
+// counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += n
+	return n, err
+}
+
+func formatCSVValue(v any) string {
+	switch value := v.(type) {
+	case string:
+		return value
+	case int:
+		return strconv.Itoa(value)
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case domain.Money:
+		return value.String()
+	case time.Time:
+		return value.Format(time.RFC3339)
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,55 @@
+package infrastructure
+
+import (
+	"context"
+	"sync"
+
+	"etlgo/internal/domain"
+	"etlgo/pkg/logger"
+)
+
+// restatements kept before the oldest are dropped
+const maxRestatements = 10000
+
+// implements domain.RestatementRepository interface in memory, keeping the
+// most recent restatements
+type RestatementRepository struct {
+	restatements []domain.Restatement
+	mutex        sync.RWMutex
+	logger       *logger.Logger
+}
+
+// creates a new restatement repository
+func NewRestatementRepository(logger *logger.Logger) *RestatementRepository {
+	return &RestatementRepository{logger: logger}
+}
+
+func (r *RestatementRepository) Store(ctx context.Context, restatements []domain.Restatement) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.restatements = append(r.restatements, restatements...)
+	if overflow := len(r.restatements) - maxRestatements; overflow > 0 {
+		r.restatements = append([]domain.Restatement(nil), r.restatements[overflow:]...)
+	}
+
+	r.logger.WithContext(ctx).WithField("count", len(restatements)).Info("Recorded restatements")
+	return nil
+}
+
+func (r *RestatementRepository) List(ctx context.Context, opportunityID string, limit int) ([]domain.Restatement, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	result := make([]domain.Restatement, 0)
+	for i := len(r.restatements) - 1; i >= 0; i-- {
+		if limit > 0 && len(result) >= limit {
+			break
+		}
+		if opportunityID != "" && r.restatements[i].OpportunityID != opportunityID {
+			continue
+		}
+		result = append(result, r.restatements[i])
+	}
+	return result, nil
+}
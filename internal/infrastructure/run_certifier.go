@@ -0,0 +1,70 @@
+package infrastructure
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"etlgo/internal/domain"
+	"etlgo/pkg/buildinfo"
+)
+
+// CertificationAlgorithm identifies the signature scheme of run certifications
+const CertificationAlgorithm = "Ed25519"
+
+// implements domain.RunCertifier, signing certifications with an Ed25519
+// key so auditors can verify them with the public key alone
+type RunCertifier struct {
+	key        ed25519.PrivateKey
+	keyID      string
+	configHash string
+	build      domain.CertifiedBuild
+}
+
+// creates a certifier from a base64 encoded 32 byte Ed25519 seed. Without a
+// key certifications are issued unsigned.
+func NewRunCertifier(encodedKey, keyID, configHash string) (*RunCertifier, error) {
+	build := buildinfo.Get()
+	certifier := &RunCertifier{
+		keyID:      keyID,
+		configHash: configHash,
+		build:      domain.CertifiedBuild{Version: build.Version, Commit: build.Commit, Modified: build.Modified},
+	}
+	if encodedKey == "" {
+		return certifier, nil
+	}
+
+	seed, err := base64.StdEncoding.DecodeString(encodedKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid certification signing key encoding: %w", err)
+	}
+	if len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("certification signing key must be %d bytes, got %d", ed25519.SeedSize, len(seed))
+	}
+	if keyID == "" {
+		return nil, fmt.Errorf("certification key ID is required")
+	}
+	certifier.key = ed25519.NewKeyFromSeed(seed)
+	return certifier, nil
+}
+
+func (c *RunCertifier) Certify(ctx context.Context, record domain.RunRecord) (*domain.RunCertification, error) {
+	certification := domain.NewRunCertification(record, c.configHash, c.build, time.Now().UTC())
+	if c.key == nil {
+		return certification, nil
+	}
+
+	payload, err := certification.SignedPayload()
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode certification: %w", err)
+	}
+	certification.Signature = &domain.CertificationSignature{
+		Algorithm: CertificationAlgorithm,
+		KeyID:     c.keyID,
+		PublicKey: base64.StdEncoding.EncodeToString(c.key.Public().(ed25519.PublicKey)),
+		Value:     base64.StdEncoding.EncodeToString(ed25519.Sign(c.key, payload)),
+	}
+	return certification, nil
+}
@@ -0,0 +1,92 @@
+package infrastructure
+
+import (
+	"context"
+	"sync"
+
+	"etlgo/internal/domain"
+	"etlgo/pkg/logger"
+)
+
+// runs kept before the oldest are dropped
+const maxRunRecords = 1000
+
+// implements domain.RunRepository interface in memory, keeping the most
+// recent runs
+type RunRepository struct {
+	runs   map[string]domain.RunRecord
+	order  []string
+	mutex  sync.RWMutex
+	logger *logger.Logger
+}
+
+// creates a new run repository
+func NewRunRepository(logger *logger.Logger) *RunRepository {
+	return &RunRepository{
+		runs:   make(map[string]domain.RunRecord),
+		logger: logger,
+	}
+}
+
+func (r *RunRepository) Save(ctx context.Context, record domain.RunRecord) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, exists := r.runs[record.ID]; !exists {
+		r.order = append(r.order, record.ID)
+	}
+	r.runs[record.ID] = record
+
+	if overflow := len(r.order) - maxRunRecords; overflow > 0 {
+		for _, id := range r.order[:overflow] {
+			delete(r.runs, id)
+		}
+		r.order = append([]string(nil), r.order[overflow:]...)
+	}
+
+	r.logger.WithContext(ctx).WithFields(map[string]any{
+		"run_id": record.ID,
+		"status": record.Status,
+	}).Debug("Recorded run")
+	return nil
+}
+
+func (r *RunRepository) Get(ctx context.Context, id string) (*domain.RunRecord, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	record, ok := r.runs[id]
+	if !ok {
+		return nil, domain.ErrRunNotFound
+	}
+	return &record, nil
+}
+
+func (r *RunRepository) Latest(ctx context.Context, status string) (*domain.RunRecord, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	// Runs are saved when they finish, so the order is completion order
+	for i := len(r.order) - 1; i >= 0; i-- {
+		if record := r.runs[r.order[i]]; record.Status == status {
+			return &record, nil
+		}
+	}
+	return nil, domain.ErrRunNotFound
+}
+
+func (r *RunRepository) List(ctx context.Context, filter domain.RunFilter) ([]domain.RunRecord, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	result := make([]domain.RunRecord, 0)
+	for i := len(r.order) - 1; i >= 0; i-- {
+		if filter.Limit > 0 && len(result) >= filter.Limit {
+			break
+		}
+		if record := r.runs[r.order[i]]; filter.Matches(record) {
+			result = append(result, record)
+		}
+	}
+	return result, nil
+}
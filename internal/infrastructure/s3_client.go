@@ -0,0 +1,272 @@
+package infrastructure
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"etlgo/internal/domain"
+	"etlgo/pkg/metrics"
+)
+
+// S3 connection settings
+type S3Options struct {
+	Bucket    string
+	Region    string
+	Endpoint  string // optional, for S3 compatible stores (uses path-style URLs)
+	Prefix    string
+	AccessKey string
+	SecretKey string
+}
+
+// reads and writes objects of S3, or a store with an S3 compatible API
+// such as GCS, using SigV4 signed requests
+type S3Client struct {
+	client  *http.Client
+	options S3Options
+	metrics *metrics.Metrics
+}
+
+// creates a new S3 client
+func NewS3Client(options S3Options, timeout time.Duration, metrics *metrics.Metrics) *S3Client {
+	return &S3Client{
+		client:  &http.Client{Timeout: timeout},
+		options: options,
+		metrics: metrics,
+	}
+}
+
+// uploads an object under the configured prefix and returns its s3:// location
+func (c *S3Client) PutObject(ctx context.Context, key, contentType string, body []byte, metadata, tags map[string]string) (string, error) {
+	start := time.Now()
+	key = strings.TrimPrefix(c.options.Prefix+"/"+key, "/")
+
+	objectURL := c.objectURL(key)
+	req, err := http.NewRequestWithContext(ctx, "PUT", objectURL.String(), bytes.NewReader(body))
+	if err != nil {
+		c.metrics.RecordExternalAPIFailure("s3", "request_creation")
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range metadata {
+		req.Header.Set("X-Amz-Meta-"+k, v)
+	}
+	if len(tags) > 0 {
+		values := url.Values{}
+		for k, v := range tags {
+			values.Set(k, v)
+		}
+		req.Header.Set("X-Amz-Tagging", values.Encode())
+	}
+
+	c.sign(req, body, time.Now().UTC())
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		c.metrics.RecordExternalAPIFailure("s3", "network_error")
+		return "", fmt.Errorf("failed to upload object: %w", err)
+	}
+	defer resp.Body.Close()
+
+	duration := time.Since(start)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		c.metrics.RecordExternalAPICall("s3", fmt.Sprintf("error_%d", resp.StatusCode), duration)
+		return "", fmt.Errorf("S3 returned status %d", resp.StatusCode)
+	}
+
+	c.metrics.RecordExternalAPICall("s3", "success", duration)
+	return fmt.Sprintf("s3://%s/%s", c.options.Bucket, key), nil
+}
+
+// an object listed in the bucket
+type S3Object struct {
+	Key          string
+	ETag         string
+	Size         int64
+	LastModified time.Time
+}
+
+// a page of ListObjectsV2 results
+type s3ListResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		ETag         string    `xml:"ETag"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// lists the objects whose key starts with prefix, in key order, following
+// continuation tokens across pages. The configured prefix is not applied.
+func (c *S3Client) ListObjects(ctx context.Context, prefix string) ([]S3Object, error) {
+	var objects []S3Object
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		bucketURL := c.objectURL("")
+		// SigV4 wants spaces encoded as %20
+		bucketURL.RawQuery = strings.ReplaceAll(query.Encode(), "+", "%20")
+
+		body, err := c.get(ctx, bucketURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list objects: %w", err)
+		}
+		var page s3ListResult
+		if err := xml.Unmarshal(body, &page); err != nil {
+			c.metrics.RecordExternalAPIFailure("s3", "decode_error")
+			return nil, fmt.Errorf("failed to decode object listing: %w", err)
+		}
+		for _, object := range page.Contents {
+			objects = append(objects, S3Object{
+				Key:          object.Key,
+				ETag:         strings.Trim(object.ETag, `"`),
+				Size:         object.Size,
+				LastModified: object.LastModified,
+			})
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return objects, nil
+		}
+		token = page.NextContinuationToken
+	}
+}
+
+// reads an object by its full key. The configured prefix is not applied.
+func (c *S3Client) GetObject(ctx context.Context, key string) ([]byte, error) {
+	body, err := c.get(ctx, c.objectURL(key))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read object %s: %w", key, err)
+	}
+	return body, nil
+}
+
+// sends a signed GET and returns the response body
+func (c *S3Client) get(ctx context.Context, target *url.URL) ([]byte, error) {
+	start := time.Now()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", target.String(), nil)
+	if err != nil {
+		c.metrics.RecordExternalAPIFailure("s3", "request_creation")
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	c.sign(req, nil, time.Now().UTC())
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		c.metrics.RecordExternalAPIFailure("s3", "network_error")
+		return nil, domain.Errorf(domain.ErrUpstreamUnavailable, "failed to reach object store: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	duration := time.Since(start)
+	if err != nil {
+		c.metrics.RecordExternalAPIFailure("s3", "network_error")
+		return nil, domain.Errorf(domain.ErrUpstreamUnavailable, "failed to read object store response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		c.metrics.RecordExternalAPICall("s3", fmt.Sprintf("error_%d", resp.StatusCode), duration)
+		return nil, domain.Errorf(domain.ErrUpstreamUnavailable, "object store returned status %d", resp.StatusCode)
+	}
+
+	c.metrics.RecordExternalAPICall("s3", "success", duration)
+	return body, nil
+}
+
+func (c *S3Client) objectURL(key string) *url.URL {
+	if c.options.Endpoint != "" {
+		u, err := url.Parse(strings.TrimSuffix(c.options.Endpoint, "/"))
+		if err == nil {
+			u.Path = "/" + c.options.Bucket + "/" + key
+			return u
+		}
+	}
+	return &url.URL{
+		Scheme: "https",
+		Host:   fmt.Sprintf("%s.s3.%s.amazonaws.com", c.options.Bucket, c.options.Region),
+		Path:   "/" + key,
+	}
+}
+
+// signs the request with AWS Signature Version 4
+func (c *S3Client) sign(req *http.Request, body []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := day + "/" + c.options.Region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+c.options.SecretKey), day)
+	key = hmacSHA256(key, c.options.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		c.options.AccessKey, scope, signedHeaders, signature,
+	))
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,574 @@
+package infrastructure
+
+import (
+	"cmp"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"etlgo/internal/domain"
+	"etlgo/pkg/logger"
+	"etlgo/pkg/metrics"
+)
+
+const (
+	// assertions of the JWT bearer flow are valid this long; Salesforce
+	// accepts up to 3 minutes
+	salesforceAssertionTTL = 3 * time.Minute
+
+	salesforceJWTGrant = "urn:ietf:params:oauth:grant-type:jwt-bearer"
+
+	// the field incremental pulls filter and order opportunities by
+	salesforceWatermarkField = "SystemModstamp"
+)
+
+// layout of Salesforce datetimes, e.g. 2024-01-15T10:30:00.000+0000
+const salesforceDateTime = "2006-01-02T15:04:05.000-0700"
+
+// where each opportunity field is read from in the records the query
+// returns, by default. Paths follow relationships with dots, e.g.
+// Account.PersonEmail; fields left empty are not read.
+var salesforceDefaultFields = map[string]string{
+	"opportunity_id": "Id",
+	"stage":          "StageName",
+	"amount":         "Amount",
+	"probability":    "Probability",
+	"created_at":     "CreatedDate",
+	"closed_at":      "CloseDate",
+	"is_closed":      "IsClosed",
+	"is_won":         "IsWon",
+	"currency":       "CurrencyIsoCode",
+	"contact_email":  "",
+	"utm_campaign":   "",
+	"utm_source":     "",
+	"utm_medium":     "",
+}
+
+// a SOQL query on opportunities, split at its WHERE clause
+var salesforceQueryPattern = regexp.MustCompile(`(?is)^\s*(select\s.+?\sfrom\s+opportunity\b)(?:\s+where\s+(.+?))?\s*$`)
+
+// clauses the watermark takes over
+var salesforceOrderPattern = regexp.MustCompile(`(?i)\b(order\s+by|limit|offset)\b`)
+
+// settings of the Salesforce connector. It authenticates with the OAuth
+// JWT bearer flow: assertions for Username are issued to the connected app
+// of ClientID, signed with the private key in PrivateKeyFile whose
+// certificate the app holds, and sent to LoginURL. Opportunities are read
+// with Query, a SOQL query on Opportunity without ORDER BY, LIMIT or
+// OFFSET; Fields overrides where their fields are read from, as
+// "field=path" pairs, and Stages maps their StageName onto funnel stages.
+// Failed calls are retried up to MaxRetries times.
+type SalesforceOptions struct {
+	ClientID       string
+	Username       string
+	PrivateKeyFile string
+	LoginURL       string
+	APIVersion     string
+	Query          string
+	Fields         string
+	Stages         domain.StageMapping
+	MaxRetries     int
+	RetryBackoff   time.Duration
+}
+
+// pulls opportunities from Salesforce with a SOQL query and serves them as
+// CRM records. Runs with a since day pull only the opportunities modified
+// since, by their SystemModstamp. Implements domain.ExternalAPIClient; it
+// serves no ads records.
+type SalesforceClient struct {
+	client *http.Client
+	opts   SalesforceOptions
+	key    *rsa.PrivateKey
+	fields map[string][]string // paths of the opportunity fields
+
+	selectFrom string // the query up to its WHERE clause
+	where      string
+
+	mutex       sync.Mutex
+	token       string
+	instanceURL string
+
+	prewarm bool
+	logger  *logger.Logger
+	metrics *metrics.Metrics
+}
+
+// creates a Salesforce client for the connected app, or returns nil when
+// none is configured. Requests go through the cassette when one is given.
+func NewSalesforceClient(opts SalesforceOptions, transport TransportOptions, cassette *Cassette, timeout time.Duration, logger *logger.Logger, metrics *metrics.Metrics) (*SalesforceClient, error) {
+	if opts.ClientID == "" {
+		return nil, nil
+	}
+	if opts.Username == "" || opts.PrivateKeyFile == "" {
+		return nil, fmt.Errorf("Salesforce client id requires a username and a private key file")
+	}
+
+	raw, err := os.ReadFile(opts.PrivateKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Salesforce private key: %w", err)
+	}
+	key, err := parseRSAPrivateKey(string(raw))
+	if err != nil {
+		return nil, fmt.Errorf("invalid Salesforce private key: %w", err)
+	}
+
+	query := salesforceQueryPattern.FindStringSubmatch(opts.Query)
+	if query == nil {
+		return nil, fmt.Errorf("Salesforce query must select from Opportunity, got %q", opts.Query)
+	}
+	if salesforceOrderPattern.MatchString(query[2]) {
+		return nil, fmt.Errorf("Salesforce query must not have ORDER BY, LIMIT or OFFSET, the watermark orders it")
+	}
+
+	fields, err := parseSalesforceFields(opts.Fields)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &SalesforceClient{
+		client:     &http.Client{Timeout: timeout, Transport: NewUpstreamTransport(transport)},
+		opts:       opts,
+		key:        key,
+		fields:     fields,
+		selectFrom: query[1],
+		where:      query[2],
+		prewarm:    transport.Prewarm && !cassette.Replaying(),
+		logger:     logger,
+		metrics:    metrics,
+	}
+	c.opts.LoginURL = strings.TrimSuffix(opts.LoginURL, "/")
+	if cassette != nil {
+		c.client.Transport = cassette.Wrap(c.client.Transport)
+	}
+	return c, nil
+}
+
+// parses "field=path" overrides of the default field paths
+func parseSalesforceFields(spec string) (map[string][]string, error) {
+	paths := make(map[string]string, len(salesforceDefaultFields))
+	for field, path := range salesforceDefaultFields {
+		paths[field] = path
+	}
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		field, path, ok := strings.Cut(pair, "=")
+		field = strings.TrimSpace(field)
+		if _, known := salesforceDefaultFields[field]; !ok || !known {
+			return nil, fmt.Errorf("invalid Salesforce field %q: expected field=path of a known field", pair)
+		}
+		paths[field] = strings.TrimSpace(path)
+	}
+
+	fields := make(map[string][]string, len(paths))
+	for field, path := range paths {
+		if path != "" {
+			fields[field] = strings.Split(path, ".")
+		}
+	}
+	for _, required := range []string{"opportunity_id", "stage", "created_at"} {
+		if fields[required] == nil {
+			return nil, fmt.Errorf("Salesforce field %s needs a path", required)
+		}
+	}
+	return fields, nil
+}
+
+// URL returns the login URL the connector authenticates with
+func (c *SalesforceClient) URL() string {
+	return c.opts.LoginURL
+}
+
+// opens connections to the login server
+func (c *SalesforceClient) Prewarm(ctx context.Context) {
+	if !c.prewarm {
+		return
+	}
+	prewarmUpstreams(ctx, c.client, map[string]string{"salesforce": c.opts.LoginURL}, c.logger, c.metrics)
+}
+
+// REST API response bodies
+type salesforceQueryResponse struct {
+	TotalSize      int              `json:"totalSize"`
+	Done           bool             `json:"done"`
+	NextRecordsURL string           `json:"nextRecordsUrl"`
+	Records        []map[string]any `json:"records"`
+}
+
+type salesforceError struct {
+	Message   string `json:"message"`
+	ErrorCode string `json:"errorCode"`
+}
+
+type salesforceTokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	InstanceURL      string `json:"instance_url"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// serves no ads records
+func (c *SalesforceClient) FetchAdsData(ctx context.Context, since *time.Time) (*domain.AdData, error) {
+	return &domain.AdData{}, nil
+}
+
+// pulls the opportunities the query selects, only those modified since the
+// given day when one is given
+func (c *SalesforceClient) FetchCRMData(ctx context.Context, since *time.Time) (*domain.CRMData, error) {
+	start := time.Now()
+	query := url.Values{"q": {c.query(since)}}
+
+	data := &domain.CRMData{}
+	pages := 0
+	for path := "/services/data/" + c.opts.APIVersion + "/query?" + query.Encode(); path != ""; pages++ {
+		var page salesforceQueryResponse
+		if err := c.get(ctx, path, &page); err != nil {
+			return nil, err
+		}
+		for _, record := range page.Records {
+			opp, errs := c.opportunity(record)
+			if len(errs) > 0 {
+				payload, _ := json.Marshal(record)
+				data.Rejected = append(data.Rejected, domain.QuarantinedRecord{
+					Source:  domain.SourceCRM,
+					Record:  opp.OpportunityID,
+					Payload: payload,
+					Errors:  errs,
+				})
+				continue
+			}
+			data.External.CRM.Opportunities = append(data.External.CRM.Opportunities, opp)
+		}
+		path = ""
+		if !page.Done {
+			path = page.NextRecordsURL
+		}
+	}
+
+	duration := time.Since(start)
+	c.metrics.RecordExternalAPICall("salesforce", "success", duration)
+	fields := map[string]any{
+		"duration": duration,
+		"pages":    pages,
+		"records":  len(data.External.CRM.Opportunities),
+		"rejected": len(data.Rejected),
+	}
+	if since != nil {
+		fields["watermark"] = since.UTC().Format(time.RFC3339)
+	}
+	c.logger.WithContext(ctx).WithFields(fields).Info("Successfully fetched Salesforce opportunities")
+
+	return data, nil
+}
+
+// returns the configured query, narrowed to the opportunities modified
+// since the given day and ordered by modification
+func (c *SalesforceClient) query(since *time.Time) string {
+	var conditions []string
+	if c.where != "" {
+		conditions = append(conditions, "("+c.where+")")
+	}
+	if since != nil {
+		conditions = append(conditions, salesforceWatermarkField+" >= "+since.UTC().Format("2006-01-02T15:04:05Z"))
+	}
+
+	query := c.selectFrom
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	return query + " ORDER BY " + salesforceWatermarkField
+}
+
+// maps a query record onto an opportunity. StageName is mapped onto a
+// funnel stage; unmapped stages of closed opportunities fall back to
+// closed won or lost, those of open ones reject the record.
+func (c *SalesforceClient) opportunity(record map[string]any) (domain.Opportunity, []domain.RecordError) {
+	opp := domain.Opportunity{
+		OpportunityID: c.str(record, "opportunity_id"),
+		ContactEmail:  c.str(record, "contact_email"),
+		Currency:      c.str(record, "currency"),
+		UTMCampaign:   c.str(record, "utm_campaign"),
+		UTMSource:     c.str(record, "utm_source"),
+		UTMMedium:     c.str(record, "utm_medium"),
+	}
+	var errs []domain.RecordError
+	reject := func(field, value, reason string) {
+		errs = append(errs, domain.RecordError{Record: opp.OpportunityID, Field: field, Value: value, Reason: reason})
+	}
+
+	closed, won := c.boolean(record, "is_closed"), c.boolean(record, "is_won")
+	stageName := c.str(record, "stage")
+	stage, ok := c.opts.Stages.Stage(stageName)
+	switch {
+	case ok:
+		closed = closed || stage == domain.StageClosedWon || stage == domain.StageClosedLost
+	case closed && won:
+		stage = domain.StageClosedWon
+	case closed:
+		stage = domain.StageClosedLost
+	default:
+		reject("stage", stageName, "not in the Salesforce stage map")
+	}
+	opp.Stage = stage
+
+	switch amount := c.value(record, "amount").(type) {
+	case nil:
+	case float64:
+		opp.Amount = domain.MoneyFromFloat(amount)
+	default:
+		reject("amount", fmt.Sprint(amount), "not a number")
+	}
+	switch probability := c.value(record, "probability").(type) {
+	case nil:
+	case float64:
+		opp.Probability = &probability
+	default:
+		reject("probability", fmt.Sprint(probability), "not a number")
+	}
+
+	createdAt := c.str(record, "created_at")
+	if created, err := parseSalesforceTime(createdAt); err == nil {
+		opp.CreatedAt = created.Format(time.RFC3339)
+	} else {
+		reject("created_at", createdAt, "not a Salesforce datetime")
+	}
+	// CloseDate is the expected close date of open opportunities
+	if closedAt := c.str(record, "closed_at"); closed && closedAt != "" {
+		if closedOn, err := parseSalesforceTime(closedAt); err == nil {
+			opp.ClosedAt = closedOn.Format(time.RFC3339)
+		} else {
+			reject("closed_at", closedAt, "not a Salesforce date")
+		}
+	}
+	return opp, errs
+}
+
+// parses a Salesforce datetime or date
+func parseSalesforceTime(value string) (time.Time, error) {
+	for _, layout := range []string{salesforceDateTime, time.RFC3339, "2006-01-02"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized date %q", value)
+}
+
+// returns the value of an opportunity field in the record, following its
+// path through related records; nil when it has no path or is null
+func (c *SalesforceClient) value(record map[string]any, field string) any {
+	path := c.fields[field]
+	if path == nil {
+		return nil
+	}
+	var value any = record
+	for _, name := range path {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+		value = object[name]
+	}
+	return value
+}
+
+func (c *SalesforceClient) str(record map[string]any, field string) string {
+	switch value := c.value(record, field).(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+func (c *SalesforceClient) boolean(record map[string]any, field string) bool {
+	value, _ := c.value(record, field).(bool)
+	return value
+}
+
+// sends a REST API GET request to the instance and decodes its body into
+// out. The session is opened on the first call and again once when it
+// expires; server errors and network failures are retried.
+func (c *SalesforceClient) get(ctx context.Context, path string, out any) error {
+	reauthenticated := false
+	var err error
+	for attempt := 0; attempt <= c.opts.MaxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(c.opts.RetryBackoff * time.Duration(attempt)):
+			}
+		}
+
+		token, instanceURL, authErr := c.session(ctx)
+		if authErr != nil {
+			return authErr
+		}
+
+		var body []byte
+		var status int
+		var apiErr *salesforceError
+		body, status, apiErr, err = c.do(ctx, token, instanceURL+path)
+		switch {
+		case err != nil:
+			if !errors.Is(err, domain.ErrUpstreamUnavailable) {
+				return err
+			}
+			continue
+		case apiErr == nil:
+			if err := json.Unmarshal(body, out); err != nil {
+				c.metrics.RecordExternalAPIFailure("salesforce", "json_parse")
+				return domain.Errorf(domain.ErrUpstreamUnavailable, "failed to parse Salesforce query response: %w", err)
+			}
+			return nil
+		}
+
+		err = domain.Errorf(domain.ErrUpstreamUnavailable, "Salesforce query returned status %d: %s (%s)", status, cmp.Or(apiErr.Message, http.StatusText(status)), apiErr.ErrorCode)
+		switch {
+		case status == http.StatusUnauthorized && !reauthenticated:
+			// the session expired or was revoked
+			c.mutex.Lock()
+			if c.token == token {
+				c.token = ""
+			}
+			c.mutex.Unlock()
+			reauthenticated = true
+			attempt--
+		case status >= http.StatusInternalServerError:
+		default:
+			// REQUEST_LIMIT_EXCEEDED and malformed queries fail the run
+			return err
+		}
+	}
+	return err
+}
+
+// sends one request, returning the body of successful responses or the
+// first error of failed ones
+func (c *SalesforceClient) do(ctx context.Context, token, rawURL string) ([]byte, int, *salesforceError, error) {
+	start := time.Now()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		c.metrics.RecordExternalAPIFailure("salesforce", "request_creation")
+		return nil, 0, nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	if err := domain.ConsumeUpstreamCall(ctx, domain.SourceCRM); err != nil {
+		return nil, 0, nil, err
+	}
+	meter := domain.CostMeterFromContext(ctx)
+	meter.AddAPICall()
+	resp, err := c.client.Do(traceUpstream(req, "salesforce", c.metrics))
+	if err != nil {
+		c.metrics.RecordExternalAPIFailure("salesforce", "network_error")
+		return nil, 0, nil, domain.Errorf(domain.ErrUpstreamUnavailable, "failed to reach Salesforce: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	meter.AddDownload(int64(len(body)))
+	if err != nil {
+		c.metrics.RecordExternalAPIFailure("salesforce", "read_body")
+		return nil, 0, nil, domain.Errorf(domain.ErrUpstreamUnavailable, "failed to read Salesforce response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		c.metrics.RecordExternalAPICall("salesforce", fmt.Sprintf("error_%d", resp.StatusCode), time.Since(start))
+		var apiErrs []salesforceError
+		_ = json.Unmarshal(body, &apiErrs)
+		apiErr := salesforceError{}
+		if len(apiErrs) > 0 {
+			apiErr = apiErrs[0]
+		}
+		return nil, resp.StatusCode, &apiErr, nil
+	}
+	return body, resp.StatusCode, nil, nil
+}
+
+// returns the access token and instance URL of the session, opening one
+// with the JWT bearer flow when there is none
+func (c *SalesforceClient) session(ctx context.Context) (string, string, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.token != "" {
+		return c.token, c.instanceURL, nil
+	}
+
+	assertion, err := c.assertion()
+	if err != nil {
+		return "", "", err
+	}
+	form := url.Values{"grant_type": {salesforceJWTGrant}, "assertion": {assertion}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.opts.LoginURL+"/services/oauth2/token", strings.NewReader(form.Encode()))
+	if err != nil {
+		c.metrics.RecordExternalAPIFailure("salesforce", "request_creation")
+		return "", "", fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.client.Do(traceUpstream(req, "salesforce", c.metrics))
+	if err != nil {
+		c.metrics.RecordExternalAPIFailure("salesforce", "network_error")
+		return "", "", domain.Errorf(domain.ErrUpstreamUnavailable, "failed to reach Salesforce token endpoint: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var token salesforceTokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil || token.AccessToken == "" || token.InstanceURL == "" {
+		c.metrics.RecordExternalAPIFailure("salesforce", "token")
+		return "", "", domain.Errorf(domain.ErrUpstreamUnavailable, "Salesforce token endpoint returned no session (status %d): %s %s", resp.StatusCode, token.Error, token.ErrorDescription)
+	}
+
+	c.token, c.instanceURL = token.AccessToken, strings.TrimSuffix(token.InstanceURL, "/")
+	c.logger.WithContext(ctx).WithField("instance_url", c.instanceURL).Info("Opened Salesforce session")
+	return c.token, c.instanceURL, nil
+}
+
+// returns an RS256 signed JWT asserting the username to the login server
+func (c *SalesforceClient) assertion() (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]any{
+		"iss": c.opts.ClientID,
+		"sub": c.opts.Username,
+		"aud": c.opts.LoginURL,
+		"exp": time.Now().Add(salesforceAssertionTTL).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	sum := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, c.key, crypto.SHA256, sum[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign Salesforce assertion: %w", err)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
@@ -0,0 +1,127 @@
+package infrastructure
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"etlgo/internal/domain"
+	"etlgo/pkg/logger"
+)
+
+// scheduled runs kept per pipeline
+const scheduleHistoryPerPipeline = 1000
+
+// implements domain.ScheduleHistoryRepository. The history is kept in
+// memory and, when a path is set, written to a JSON file after every record
+// so missed runs can still be detected after a restart.
+type ScheduleHistoryRepository struct {
+	path    string
+	records map[string][]domain.ScheduleRecord
+	mutex   sync.RWMutex
+	logger  *logger.Logger
+}
+
+// creates a schedule history, loading the file at path when it exists. An
+// empty path keeps the history in memory only.
+func NewScheduleHistoryRepository(path string, logger *logger.Logger) (*ScheduleHistoryRepository, error) {
+	r := &ScheduleHistoryRepository{
+		path:    path,
+		records: make(map[string][]domain.ScheduleRecord),
+		logger:  logger,
+	}
+	if path == "" {
+		return r, nil
+	}
+
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schedule history: %w", err)
+	}
+	if err := json.Unmarshal(raw, &r.records); err != nil {
+		return nil, fmt.Errorf("failed to parse schedule history: %w", err)
+	}
+	return r, nil
+}
+
+func (r *ScheduleHistoryRepository) Record(ctx context.Context, record domain.ScheduleRecord) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	records := append(r.records[record.Pipeline], record)
+	if overflow := len(records) - scheduleHistoryPerPipeline; overflow > 0 {
+		records = append([]domain.ScheduleRecord(nil), records[overflow:]...)
+	}
+	r.records[record.Pipeline] = records
+
+	return r.save()
+}
+
+// returns the latest due time recorded for the pipeline, or nil when it has
+// no history
+func (r *ScheduleHistoryRepository) LastDue(ctx context.Context, pipeline string) (*time.Time, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	var last *time.Time
+	for _, record := range r.records[pipeline] {
+		if last == nil || record.DueAt.After(*last) {
+			due := record.DueAt
+			last = &due
+		}
+	}
+	return last, nil
+}
+
+// returns the pipeline's most recent records first
+func (r *ScheduleHistoryRepository) List(ctx context.Context, pipeline string, limit int) ([]domain.ScheduleRecord, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	records := r.records[pipeline]
+	result := make([]domain.ScheduleRecord, 0)
+	for i := len(records) - 1; i >= 0; i-- {
+		if limit > 0 && len(result) >= limit {
+			break
+		}
+		result = append(result, records[i])
+	}
+	return result, nil
+}
+
+// writes the history to a temporary file and renames it over the old one
+func (r *ScheduleHistoryRepository) save() error {
+	if r.path == "" {
+		return nil
+	}
+
+	raw, err := json.Marshal(r.records)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(r.path), filepath.Base(r.path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write schedule history: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(raw); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write schedule history: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write schedule history: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), r.path); err != nil {
+		return fmt.Errorf("failed to write schedule history: %w", err)
+	}
+	return nil
+}
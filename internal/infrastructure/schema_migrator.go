@@ -0,0 +1,222 @@
+package infrastructure
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"etlgo/internal/domain"
+	"etlgo/pkg/logger"
+)
+
+// SQL dialects with embedded migrations
+const (
+	DialectPostgres = "postgres"
+	DialectSQLite   = "sqlite"
+)
+
+// arbitrary key of the Postgres advisory lock held while migrating, so
+// replicas starting together do not apply the same migration twice
+const migrationLockKey = 7_346_201
+
+//go:embed migrations
+var migrationFiles embed.FS
+
+// one versioned migration file, named NNNN_description.sql
+type migration struct {
+	version int
+	name    string
+	sql     string
+}
+
+// implements domain.SchemaMigrator by applying the migrations embedded for
+// a SQL dialect. Applied versions are recorded in schema_migrations; each
+// migration runs in its own transaction.
+type SQLSchemaMigrator struct {
+	db         *sql.DB
+	dialect    string
+	migrations []migration
+	logger     *logger.Logger
+}
+
+// creates a migrator for the dialect's embedded migrations
+func NewSQLSchemaMigrator(db *sql.DB, dialect string, logger *logger.Logger) (*SQLSchemaMigrator, error) {
+	migrations, err := loadMigrations(dialect)
+	if err != nil {
+		return nil, err
+	}
+	return &SQLSchemaMigrator{
+		db:         db,
+		dialect:    dialect,
+		migrations: migrations,
+		logger:     logger,
+	}, nil
+}
+
+// returns the applied and latest available schema versions
+func (m *SQLSchemaMigrator) Status(ctx context.Context) (domain.SchemaStatus, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return domain.SchemaStatus{}, fmt.Errorf("failed to connect: %w", err)
+	}
+	defer conn.Close()
+
+	if err := m.ensureVersionTable(ctx, conn); err != nil {
+		return domain.SchemaStatus{}, err
+	}
+	return m.status(ctx, conn)
+}
+
+// applies every pending migration in version order
+func (m *SQLSchemaMigrator) Migrate(ctx context.Context) (domain.SchemaStatus, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return domain.SchemaStatus{}, fmt.Errorf("failed to connect: %w", err)
+	}
+	defer conn.Close()
+
+	if m.dialect == DialectPostgres {
+		if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLockKey); err != nil {
+			return domain.SchemaStatus{}, fmt.Errorf("failed to acquire migration lock: %w", err)
+		}
+		defer conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLockKey)
+	}
+
+	if err := m.ensureVersionTable(ctx, conn); err != nil {
+		return domain.SchemaStatus{}, err
+	}
+	status, err := m.status(ctx, conn)
+	if err != nil {
+		return status, err
+	}
+
+	for _, mig := range m.migrations {
+		if mig.version <= status.Version {
+			continue
+		}
+
+		start := time.Now()
+		if err := m.apply(ctx, conn, mig); err != nil {
+			return status, fmt.Errorf("migration %04d_%s failed: %w", mig.version, mig.name, err)
+		}
+		m.logger.WithContext(ctx).WithFields(map[string]any{
+			"dialect":  m.dialect,
+			"version":  mig.version,
+			"name":     mig.name,
+			"duration": time.Since(start),
+		}).Info("Applied schema migration")
+	}
+
+	return m.status(ctx, conn)
+}
+
+func (m *SQLSchemaMigrator) apply(ctx context.Context, conn *sql.Conn, mig migration) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, mig.sql); err != nil {
+		return err
+	}
+	insert := "INSERT INTO schema_migrations (version, name, applied_at) VALUES (" + m.placeholders(3) + ")"
+	if _, err := tx.ExecContext(ctx, insert, mig.version, mig.name, time.Now().UTC().Format(time.RFC3339)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (m *SQLSchemaMigrator) ensureVersionTable(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		name       TEXT NOT NULL,
+		applied_at TEXT NOT NULL
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+	return nil
+}
+
+func (m *SQLSchemaMigrator) status(ctx context.Context, conn *sql.Conn) (domain.SchemaStatus, error) {
+	status := domain.SchemaStatus{Dialect: m.dialect}
+	if len(m.migrations) > 0 {
+		status.Latest = m.migrations[len(m.migrations)-1].version
+	}
+
+	var appliedAt string
+	err := conn.QueryRowContext(ctx, "SELECT version, applied_at FROM schema_migrations ORDER BY version DESC LIMIT 1").Scan(&status.Version, &appliedAt)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+	case err != nil:
+		return status, fmt.Errorf("failed to read schema version: %w", err)
+	default:
+		if t, err := time.Parse(time.RFC3339, appliedAt); err == nil {
+			status.AppliedAt = &t
+		}
+	}
+
+	for _, mig := range m.migrations {
+		if mig.version > status.Version {
+			status.Pending++
+		}
+	}
+	return status, nil
+}
+
+// returns n bind parameters in the dialect's syntax
+func (m *SQLSchemaMigrator) placeholders(n int) string {
+	params := make([]string, n)
+	for i := range params {
+		if m.dialect == DialectPostgres {
+			params[i] = "$" + strconv.Itoa(i+1)
+		} else {
+			params[i] = "?"
+		}
+	}
+	return strings.Join(params, ", ")
+}
+
+// reads migrations/<dialect>/NNNN_name.sql sorted by version
+func loadMigrations(dialect string) ([]migration, error) {
+	dir := path.Join("migrations", dialect)
+	entries, err := migrationFiles.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("no migrations for dialect %q", dialect)
+	}
+
+	var migrations []migration
+	for _, entry := range entries {
+		if entry.IsDir() || path.Ext(entry.Name()) != ".sql" {
+			continue
+		}
+		base := strings.TrimSuffix(entry.Name(), ".sql")
+		prefix, name, ok := strings.Cut(base, "_")
+		version, err := strconv.Atoi(prefix)
+		if !ok || err != nil || version <= 0 {
+			return nil, fmt.Errorf("invalid migration file name %q, expected NNNN_description.sql", entry.Name())
+		}
+
+		content, err := migrationFiles.ReadFile(path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, migration{version: version, name: name, sql: string(content)})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].version < migrations[j].version })
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].version == migrations[i-1].version {
+			return nil, fmt.Errorf("duplicate migration version %d", migrations[i].version)
+		}
+	}
+	return migrations, nil
+}
@@ -0,0 +1,101 @@
+package infrastructure
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"etlgo/internal/domain"
+	"etlgo/pkg/logger"
+)
+
+// shadow results kept before the oldest are dropped. Results carry the
+// shadow's metrics, so fewer are kept than runs.
+const maxShadowResults = 200
+
+// implements domain.ShadowRepository interface in memory
+type ShadowRepository struct {
+	configs map[string]domain.ShadowConfig
+	results []domain.ShadowResult
+	mutex   sync.RWMutex
+	logger  *logger.Logger
+}
+
+// creates a new shadow repository
+func NewShadowRepository(logger *logger.Logger) *ShadowRepository {
+	return &ShadowRepository{
+		configs: make(map[string]domain.ShadowConfig),
+		logger:  logger,
+	}
+}
+
+func (r *ShadowRepository) SaveConfig(ctx context.Context, config domain.ShadowConfig) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.configs[config.Label] = config
+	return nil
+}
+
+func (r *ShadowRepository) DeleteConfig(ctx context.Context, label string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.configs[label]; !ok {
+		return domain.ErrShadowNotFound
+	}
+	delete(r.configs, label)
+	return nil
+}
+
+func (r *ShadowRepository) Configs(ctx context.Context) ([]domain.ShadowConfig, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	configs := make([]domain.ShadowConfig, 0, len(r.configs))
+	for _, config := range r.configs {
+		configs = append(configs, config)
+	}
+	sort.Slice(configs, func(i, j int) bool { return configs[i].Label < configs[j].Label })
+	return configs, nil
+}
+
+func (r *ShadowRepository) SaveResult(ctx context.Context, result domain.ShadowResult) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.results = append(r.results, result)
+	if overflow := len(r.results) - maxShadowResults; overflow > 0 {
+		r.results = append([]domain.ShadowResult(nil), r.results[overflow:]...)
+	}
+	return nil
+}
+
+func (r *ShadowRepository) GetResult(ctx context.Context, id string) (*domain.ShadowResult, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for i := range r.results {
+		if r.results[i].ID == id {
+			result := r.results[i]
+			return &result, nil
+		}
+	}
+	return nil, domain.ErrShadowResultNotFound
+}
+
+func (r *ShadowRepository) ListResults(ctx context.Context, label string, limit int) ([]domain.ShadowResult, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	result := make([]domain.ShadowResult, 0)
+	for i := len(r.results) - 1; i >= 0; i-- {
+		if limit > 0 && len(result) >= limit {
+			break
+		}
+		if r.results[i].Label == label {
+			result = append(result, r.results[i])
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,132 @@
+package infrastructure
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"etlgo/internal/domain"
+	"etlgo/pkg/logger"
+)
+
+// implements domain.ShareTokenRevocationRepository. Revocations are kept in
+// memory and, when a path is set, written to a JSON file after every change
+// so revoked tokens stay revoked across restarts.
+type ShareTokenRevocationRepository struct {
+	path        string
+	revocations map[string]domain.ShareTokenRevocation
+	mutex       sync.RWMutex
+	logger      *logger.Logger
+}
+
+// creates a revocation store, loading the file at path when it exists. An
+// empty path keeps the revocations in memory only.
+func NewShareTokenRevocationRepository(path string, logger *logger.Logger) (*ShareTokenRevocationRepository, error) {
+	r := &ShareTokenRevocationRepository{
+		path:        path,
+		revocations: make(map[string]domain.ShareTokenRevocation),
+		logger:      logger,
+	}
+	if path == "" {
+		return r, nil
+	}
+
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read share token revocations: %w", err)
+	}
+	var revocations []domain.ShareTokenRevocation
+	if err := json.Unmarshal(raw, &revocations); err != nil {
+		return nil, fmt.Errorf("failed to parse share token revocations: %w", err)
+	}
+	for _, revocation := range revocations {
+		r.revocations[revocation.ID] = revocation
+	}
+	return r, nil
+}
+
+func (r *ShareTokenRevocationRepository) Revoke(ctx context.Context, revocation domain.ShareTokenRevocation) (*domain.ShareTokenRevocation, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if existing, ok := r.revocations[revocation.ID]; ok {
+		return &existing, nil
+	}
+	r.revocations[revocation.ID] = revocation
+	if err := r.save(); err != nil {
+		delete(r.revocations, revocation.ID)
+		return nil, err
+	}
+	return &revocation, nil
+}
+
+func (r *ShareTokenRevocationRepository) IsRevoked(ctx context.Context, id string) (bool, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	_, ok := r.revocations[id]
+	return ok, nil
+}
+
+func (r *ShareTokenRevocationRepository) Prune(ctx context.Context, before time.Time) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	pruned := false
+	for id, revocation := range r.revocations {
+		if revocation.RevokedAt.Before(before) {
+			delete(r.revocations, id)
+			pruned = true
+		}
+	}
+	if !pruned {
+		return nil
+	}
+	return r.save()
+}
+
+// writes the revocations to a temporary file and renames it over the old
+// one
+func (r *ShareTokenRevocationRepository) save() error {
+	if r.path == "" {
+		return nil
+	}
+
+	revocations := make([]domain.ShareTokenRevocation, 0, len(r.revocations))
+	for _, revocation := range r.revocations {
+		revocations = append(revocations, revocation)
+	}
+	sort.Slice(revocations, func(i, j int) bool {
+		return revocations[i].ID < revocations[j].ID
+	})
+	raw, err := json.Marshal(revocations)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(r.path), filepath.Base(r.path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write share token revocations: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(raw); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write share token revocations: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write share token revocations: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), r.path); err != nil {
+		return fmt.Errorf("failed to write share token revocations: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,379 @@
+package infrastructure
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"sync"
+	"time"
+
+	"etlgo/internal/domain"
+)
+
+// sink compression modes
+const (
+	SinkCompressionNone = "none"
+	SinkCompressionGzip = "gzip"
+)
+
+// negotiates how payloads are delivered to the HTTP sink
+type SinkOptions struct {
+	Compression  string
+	ChunkSize    int // max uncompressed bytes per request, 0 disables chunking
+	MaxRetries   int
+	RetryBackoff time.Duration
+	Workers      int // chunks compressed and, when unordered, posted at a time
+	// post chunks as they are ready instead of in index order, for sinks
+	// that reassemble them by X-Chunk-Index
+	UnorderedChunks bool
+}
+
+// describes a chunked delivery, sent to the sink after all chunks
+type chunkManifest struct {
+	ExportID    string          `json:"export_id"`
+	JobID       string          `json:"job_id"`
+	ContentType string          `json:"content_type"`
+	Compression string          `json:"compression"`
+	TotalBytes  int             `json:"total_bytes"`
+	Chunks      []manifestChunk `json:"chunks"`
+}
+
+// describes a single chunk within the manifest
+type manifestChunk struct {
+	Index          int    `json:"index"`
+	Bytes          int    `json:"bytes"`
+	SHA256         string `json:"sha256"`
+	IdempotencyKey string `json:"idempotency_key"`
+}
+
+// remembers which chunks of an export were acknowledged by the sink so
+// a retried export only resends the chunks that failed
+type chunkProgress struct {
+	delivered map[string]map[int]bool
+	mutex     sync.Mutex
+}
+
+func newChunkProgress() *chunkProgress {
+	return &chunkProgress{delivered: make(map[string]map[int]bool)}
+}
+
+func (p *chunkProgress) isDelivered(key string, index int) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.delivered[key][index]
+}
+
+func (p *chunkProgress) markDelivered(key string, index int) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.delivered[key] == nil {
+		p.delivered[key] = make(map[int]bool)
+	}
+	p.delivered[key][index] = true
+}
+
+func (p *chunkProgress) clear(key string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	delete(p.delivered, key)
+}
+
+// a payload and the body sent for it, compressed as configured. The
+// signature and manifest describe the payload.
+type sinkPayload struct {
+	payload []byte
+	body    []byte
+}
+
+// sends the chunks to the target sink, each request in the export's
+// envelope. A single chunk is posted as is; multiple chunks are compressed
+// concurrently and posted as they are ready, followed by a manifest
+// completion call. Chunks are posted in index order unless the sink
+// accepts them out of order.
+func (c *HTTPClient) deliver(ctx context.Context, target domain.SinkTarget, exportID, contentType string, headers map[string]string, chunks [][]byte) error {
+	// Progress and the job ID are keyed by target and content so a changed
+	// payload never resumes stale chunks nor reuses idempotency keys
+	digest := sha256.New()
+	digest.Write([]byte(target.URL))
+	for _, chunk := range chunks {
+		digest.Write(chunk)
+	}
+	sum := hex.EncodeToString(digest.Sum(nil))
+	progressKey := exportID + ":" + sum
+	jobID := exportID + "-" + sum[:16]
+
+	if len(chunks) == 1 {
+		encoded, err := c.encodeSinkPayload(target, chunks[0])
+		if err != nil {
+			return err
+		}
+		envelope := domain.ExportEnvelope{ExportID: exportID, JobID: jobID, ChunkCount: 1}
+		return c.postWithRetry(ctx, target, encoded, contentType, envelope, headers)
+	}
+
+	manifest := chunkManifest{
+		ExportID:    exportID,
+		JobID:       jobID,
+		ContentType: contentType,
+		Compression: target.Compression,
+		Chunks:      make([]manifestChunk, len(chunks)),
+	}
+	for i, chunk := range chunks {
+		manifest.TotalBytes += len(chunk)
+		manifest.Chunks[i] = manifestChunk{
+			Index:          i,
+			Bytes:          len(chunk),
+			IdempotencyKey: domain.ExportEnvelope{JobID: jobID, ChunkIndex: i}.IdempotencyKey(),
+		}
+	}
+
+	log := c.logger.WithContext(ctx)
+
+	err := runPipeline(ctx, len(chunks), c.sinkOptions.Workers, !c.sinkOptions.UnorderedChunks,
+		func(i int) (sinkPayload, error) {
+			sum := sha256.Sum256(chunks[i])
+			manifest.Chunks[i].SHA256 = hex.EncodeToString(sum[:])
+			if c.progress.isDelivered(progressKey, i) {
+				return sinkPayload{}, nil
+			}
+			return c.encodeSinkPayload(target, chunks[i])
+		},
+		func(ctx context.Context, i int, encoded sinkPayload) error {
+			if encoded.payload == nil {
+				log.WithFields(map[string]any{"export_id": exportID, "chunk": i}).Debug("Skipping already delivered chunk")
+				return nil
+			}
+
+			envelope := domain.ExportEnvelope{
+				ExportID:   exportID,
+				JobID:      jobID,
+				Stage:      domain.ExportStageChunk,
+				ChunkIndex: i,
+				ChunkCount: len(chunks),
+			}
+			if err := c.postWithRetry(ctx, target, encoded, contentType, envelope, headers); err != nil {
+				return fmt.Errorf("chunk %d/%d failed: %w", i+1, len(chunks), err)
+			}
+			c.progress.markDelivered(progressKey, i)
+			return nil
+		})
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(manifest)
+	if err != nil {
+		return fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+	encoded, err := c.encodeSinkPayload(target, payload)
+	if err != nil {
+		return err
+	}
+
+	envelope := domain.ExportEnvelope{
+		ExportID:   exportID,
+		JobID:      jobID,
+		Stage:      domain.ExportStageComplete,
+		ChunkCount: len(chunks),
+	}
+	if err := c.postWithRetry(ctx, target, encoded, "application/json", envelope, nil); err != nil {
+		return fmt.Errorf("completion call failed: %w", err)
+	}
+
+	c.progress.clear(progressKey)
+	return nil
+}
+
+// posts a payload to the target sink in its envelope, retrying failures
+// with linear backoff. Retries repeat the envelope's idempotency key.
+func (c *HTTPClient) postWithRetry(ctx context.Context, target domain.SinkTarget, encoded sinkPayload, contentType string, envelope domain.ExportEnvelope, extra map[string]string) error {
+	if err := envelope.Validate(); err != nil {
+		return err
+	}
+	// The envelope's headers cannot be overridden
+	headers := make(map[string]string, len(extra))
+	maps.Copy(headers, extra)
+	maps.Copy(headers, envelope.Headers())
+
+	var err error
+	for attempt := 0; attempt <= c.sinkOptions.MaxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(c.sinkOptions.RetryBackoff * time.Duration(attempt)):
+			}
+		}
+
+		if err = c.postToSink(ctx, target, encoded, contentType, headers); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// posts a single request to the target sink
+func (c *HTTPClient) postToSink(ctx context.Context, target domain.SinkTarget, encoded sinkPayload, contentType string, headers map[string]string) error {
+	start := time.Now()
+
+	// Apply rate limiting
+	if err := c.sinkLimiter.Wait(ctx); err != nil {
+		c.metrics.RecordExternalAPIFailure("sink", "rate_limit")
+		return fmt.Errorf("rate limit exceeded: %w", err)
+	}
+
+	req, err := c.newEncodedSinkRequest(ctx, "sink", target.URL, target.Compression, encoded.body, contentType, headers)
+	if err != nil {
+		return err
+	}
+
+	// Signature covers the uncompressed payload
+	if target.Secret != "" {
+		req.Header.Set("X-Signature", hmacSignature(target.Secret, encoded.payload))
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		c.metrics.RecordExternalAPIFailure("sink", "network_error")
+		return domain.Errorf(domain.ErrUpstreamUnavailable, "failed to reach sink: %w", err)
+	}
+	defer resp.Body.Close()
+
+	duration := time.Since(start)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		c.metrics.RecordExternalAPICall("sink", fmt.Sprintf("error_%d", resp.StatusCode), duration)
+		return domain.Errorf(domain.ErrUpstreamUnavailable, "sink API returned status %d", resp.StatusCode)
+	}
+
+	c.metrics.RecordExternalAPICall("sink", "success", duration)
+	return nil
+}
+
+// compresses a payload for the target sink
+func (c *HTTPClient) encodeSinkPayload(target domain.SinkTarget, payload []byte) (sinkPayload, error) {
+	body, err := c.compress("sink", target.Compression, payload)
+	if err != nil {
+		return sinkPayload{}, err
+	}
+	return sinkPayload{payload: payload, body: body}, nil
+}
+
+// compresses the payload with the given compression. Failures are counted
+// under api.
+func (c *HTTPClient) compress(api, compression string, payload []byte) ([]byte, error) {
+	if compression != SinkCompressionGzip {
+		return payload, nil
+	}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(payload); err != nil {
+		c.metrics.RecordExternalAPIFailure(api, "compression")
+		return nil, fmt.Errorf("failed to compress payload: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		c.metrics.RecordExternalAPIFailure(api, "compression")
+		return nil, fmt.Errorf("failed to compress payload: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// builds a sink request, compressing the payload as configured. Failures
+// are counted under api.
+func (c *HTTPClient) newSinkRequest(ctx context.Context, api, url, compression string, payload []byte, contentType string, headers map[string]string) (*http.Request, error) {
+	body, err := c.compress(api, compression, payload)
+	if err != nil {
+		return nil, err
+	}
+	return c.newEncodedSinkRequest(ctx, api, url, compression, body, contentType, headers)
+}
+
+// builds a sink request for a body already compressed with compression
+func (c *HTTPClient) newEncodedSinkRequest(ctx context.Context, api, url, compression string, body []byte, contentType string, headers map[string]string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		c.metrics.RecordExternalAPIFailure(api, "request_creation")
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", contentType)
+	if compression == SinkCompressionGzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	return req, nil
+}
+
+func (c *HTTPClient) compression() string {
+	if c.sinkOptions.Compression == SinkCompressionGzip {
+		return SinkCompressionGzip
+	}
+	return SinkCompressionNone
+}
+
+// the configured sink
+func (c *HTTPClient) sink() domain.SinkTarget {
+	return domain.SinkTarget{URL: c.sinkURL, Secret: c.sinkSecret, Compression: c.compression()}
+}
+
+// splits export records into JSON arrays of at most maxBytes each. Records
+// are marshaled concurrently by up to workers goroutines.
+func chunkExportData[T any](ctx context.Context, data []T, maxBytes, workers int) ([][]byte, error) {
+	var chunks [][]byte
+	var current bytes.Buffer
+
+	flush := func() {
+		current.WriteByte(']')
+		chunks = append(chunks, bytes.Clone(current.Bytes()))
+		current.Reset()
+	}
+
+	err := encodeJSONRecords(ctx, data, workers, func(encoded []byte) error {
+		// A record, its comma and the closing bracket must fit
+		if maxBytes > 0 && current.Len() > 0 && current.Len()+len(encoded)+2 > maxBytes {
+			flush()
+		}
+		if current.Len() == 0 {
+			current.WriteByte('[')
+		} else {
+			current.WriteByte(',')
+		}
+		current.Write(encoded)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if current.Len() > 0 || len(chunks) == 0 {
+		if current.Len() == 0 {
+			current.WriteByte('[')
+		}
+		flush()
+	}
+
+	return chunks, nil
+}
+
+// splits a file payload into byte ranges of at most size bytes
+func splitPayload(payload []byte, size int) [][]byte {
+	if size <= 0 || len(payload) <= size {
+		return [][]byte{payload}
+	}
+
+	var chunks [][]byte
+	for start := 0; start < len(payload); start += size {
+		end := min(start+size, len(payload))
+		chunks = append(chunks, payload[start:end])
+	}
+	return chunks
+}
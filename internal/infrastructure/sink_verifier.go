@@ -0,0 +1,194 @@
+package infrastructure
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"etlgo/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+// response bytes kept in a verification report
+const maxVerifyResponseBody = 1024
+
+// sink responses reported by a verification
+type sinkResponse struct {
+	status  int
+	headers http.Header
+	body    []byte
+	latency time.Duration
+}
+
+// VerifySink sends a signed synthetic export to the target sink, or the
+// configured one, and checks that it is accepted while payloads with a bad
+// or missing signature are rejected. Requests carry X-Export-Verify: true so
+// sinks can discard them. Redirects are not followed, so a sink cannot send
+// the payload on to another host. Unreachable sinks fail the verification
+// rather than returning an error.
+func (c *HTTPClient) VerifySink(ctx context.Context, target domain.SinkTarget) (*domain.SinkVerification, error) {
+	if target.URL == "" {
+		target.URL = c.sinkURL
+		if target.Secret == "" {
+			target.Secret = c.sinkSecret
+		}
+	}
+	if target.Compression == "" {
+		target.Compression = c.compression()
+	}
+	if target.URL == "" {
+		return nil, domain.ErrSinkNotConfigured
+	}
+	if parsed, err := url.Parse(target.URL); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("%w: url must be an absolute http(s) URL", domain.ErrInvalidSinkTarget)
+	}
+	if target.Compression != SinkCompressionNone && target.Compression != SinkCompressionGzip {
+		return nil, fmt.Errorf("%w: compression must be %s or %s", domain.ErrInvalidSinkTarget, SinkCompressionNone, SinkCompressionGzip)
+	}
+
+	now := time.Now().UTC()
+	payload, err := json.Marshal([]domain.ExportData{{
+		Date:        now.Format("2006-01-02"),
+		Channel:     "etlgo_verify",
+		CampaignID:  "verify",
+		Clicks:      1,
+		Impressions: 10,
+		Cost:        domain.MoneyFromFloat(1),
+		CPC:         domain.MoneyFromFloat(1),
+	}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal verification payload: %w", err)
+	}
+	// Each request gets its own job so sinks deduplicating by idempotency
+	// key still authenticate the unsigned ones
+	exportID := "verify_" + uuid.New().String()
+	headersFor := func(job string) map[string]string {
+		headers := domain.ExportEnvelope{ExportID: exportID, JobID: exportID + "-" + job, ChunkCount: 1}.Headers()
+		headers["X-Export-Verify"] = "true"
+		return headers
+	}
+
+	verification := &domain.SinkVerification{
+		URL:         target.URL,
+		Compression: target.Compression,
+		Passed:      true,
+		VerifiedAt:  now,
+	}
+
+	// A correctly signed payload must be accepted
+	signature := ""
+	if target.Secret != "" {
+		signature = hmacSignature(target.Secret, payload)
+	}
+	resp, err := c.sendVerification(ctx, target, payload, headersFor("signed"), signature)
+	if err != nil {
+		verification.Check(domain.SinkCheckSignedAccepted, false, err.Error())
+		return verification, nil
+	}
+	verification.Status = resp.status
+	verification.LatencySeconds = resp.latency.Seconds()
+	verification.ResponseHeaders = make(map[string]string, len(resp.headers))
+	for name := range resp.headers {
+		verification.ResponseHeaders[name] = resp.headers.Get(name)
+	}
+	verification.ResponseBody = string(resp.body[:min(len(resp.body), maxVerifyResponseBody)])
+
+	accepted := resp.status >= 200 && resp.status < 300
+	verification.Check(domain.SinkCheckSignedAccepted, accepted,
+		fmt.Sprintf("status %d in %s", resp.status, resp.latency.Round(time.Millisecond)))
+	if !accepted {
+		return verification, nil
+	}
+	valid, detail := checkSinkResponseBody(resp)
+	verification.Check(domain.SinkCheckResponseBody, valid, detail)
+
+	// Payloads the sink cannot authenticate must be rejected
+	if target.Secret == "" {
+		verification.Check(domain.SinkCheckSigningConfigured, false, "no secret configured, payloads are sent unsigned")
+		return verification, nil
+	}
+	verification.Check(domain.SinkCheckSigningConfigured, true, "payloads are signed with HMAC-SHA256 in X-Signature")
+
+	forged := hmacSignature(target.Secret+"-invalid", payload)
+	rejected, detail := c.verifyRejected(ctx, target, payload, headersFor("forged"), forged)
+	verification.Check(domain.SinkCheckBadSignatureRejected, rejected, detail)
+
+	rejected, detail = c.verifyRejected(ctx, target, payload, headersFor("unsigned"), "")
+	verification.Check(domain.SinkCheckMissingSignatureRejected, rejected, detail)
+
+	return verification, nil
+}
+
+// sends the payload expecting the sink to refuse it with a 4xx status
+func (c *HTTPClient) verifyRejected(ctx context.Context, target domain.SinkTarget, payload []byte, headers map[string]string, signature string) (bool, string) {
+	resp, err := c.sendVerification(ctx, target, payload, headers, signature)
+	switch {
+	case err != nil:
+		return false, err.Error()
+	case resp.status >= 400 && resp.status < 500:
+		return true, fmt.Sprintf("rejected with status %d", resp.status)
+	case resp.status >= 200 && resp.status < 300:
+		return false, fmt.Sprintf("accepted with status %d, the sink does not verify X-Signature", resp.status)
+	}
+	return false, fmt.Sprintf("unexpected status %d", resp.status)
+}
+
+// posts one verification request without retries
+func (c *HTTPClient) sendVerification(ctx context.Context, target domain.SinkTarget, payload []byte, headers map[string]string, signature string) (*sinkResponse, error) {
+	req, err := c.newSinkRequest(ctx, "sink_verify", target.URL, target.Compression, payload, "application/json", headers)
+	if err != nil {
+		return nil, err
+	}
+	if signature != "" {
+		req.Header.Set("X-Signature", signature)
+	}
+
+	// A redirect is reported with its own status instead of followed
+	client := *c.client
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		c.metrics.RecordExternalAPIFailure("sink_verify", "network_error")
+		return nil, fmt.Errorf("failed to reach sink: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxVerifyResponseBody+1))
+	if err != nil {
+		c.metrics.RecordExternalAPIFailure("sink_verify", "read_body")
+		return nil, fmt.Errorf("failed to read sink response: %w", err)
+	}
+	latency := time.Since(start)
+	c.metrics.RecordExternalAPICall("sink_verify", fmt.Sprintf("status_%d", resp.StatusCode), latency)
+
+	return &sinkResponse{status: resp.StatusCode, headers: resp.Header, body: body, latency: latency}, nil
+}
+
+// a sink declaring a JSON response must return valid JSON
+func checkSinkResponseBody(resp *sinkResponse) (bool, string) {
+	mediaType, _, _ := mime.ParseMediaType(resp.headers.Get("Content-Type"))
+	if len(resp.body) == 0 {
+		return true, "empty response body"
+	}
+	if mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json") {
+		return true, strings.TrimSpace(fmt.Sprintf("%d byte response body %s", len(resp.body), mediaType))
+	}
+	if len(resp.body) > maxVerifyResponseBody {
+		return true, "JSON response body too large to validate"
+	}
+	if !json.Valid(resp.body) {
+		return false, "response declares application/json but the body is not valid JSON"
+	}
+	return true, "valid JSON response body"
+}
@@ -0,0 +1,133 @@
+package infrastructure
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"etlgo/internal/domain"
+	"etlgo/pkg/logger"
+)
+
+// implements domain.SourceObjectRepository. Ingested objects are kept in
+// memory and, when a path is set, written to a JSON file after every save
+// so restarts do not ingest them again.
+type SourceObjectRepository struct {
+	path    string
+	objects map[string]domain.SourceObject // by tenant, source and key
+	mutex   sync.RWMutex
+	logger  *logger.Logger
+}
+
+// creates an ingested object store, loading the file at path when it
+// exists. An empty path keeps the objects in memory only.
+func NewSourceObjectRepository(path string, logger *logger.Logger) (*SourceObjectRepository, error) {
+	r := &SourceObjectRepository{
+		path:    path,
+		objects: make(map[string]domain.SourceObject),
+		logger:  logger,
+	}
+	if path == "" {
+		return r, nil
+	}
+
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ingested objects: %w", err)
+	}
+	var objects []domain.SourceObject
+	if err := json.Unmarshal(raw, &objects); err != nil {
+		return nil, fmt.Errorf("failed to parse ingested objects: %w", err)
+	}
+	for _, object := range objects {
+		r.objects[sourceObjectKey(object.Tenant, object.Source, object.Key)] = object
+	}
+	return r, nil
+}
+
+func sourceObjectKey(tenant, source, key string) string {
+	return tenant + "/" + source + "/" + key
+}
+
+func (r *SourceObjectRepository) Save(ctx context.Context, objects []domain.SourceObject) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, object := range objects {
+		r.objects[sourceObjectKey(object.Tenant, object.Source, object.Key)] = object
+	}
+	return r.save()
+}
+
+func (r *SourceObjectRepository) Get(ctx context.Context, tenant, source, key string) (*domain.SourceObject, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	object, ok := r.objects[sourceObjectKey(tenant, source, key)]
+	if !ok {
+		return nil, nil
+	}
+	return &object, nil
+}
+
+// returns the tenant's ingested objects of the source, or of every source
+// when source is empty, by source and key
+func (r *SourceObjectRepository) List(ctx context.Context, tenant, source string) ([]domain.SourceObject, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	result := make([]domain.SourceObject, 0)
+	for _, object := range r.objects {
+		if object.Tenant == tenant && (source == "" || object.Source == source) {
+			result = append(result, object)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return sourceObjectKey("", result[i].Source, result[i].Key) < sourceObjectKey("", result[j].Source, result[j].Key)
+	})
+	return result, nil
+}
+
+// writes the objects to a temporary file and renames it over the old one
+func (r *SourceObjectRepository) save() error {
+	if r.path == "" {
+		return nil
+	}
+
+	objects := make([]domain.SourceObject, 0, len(r.objects))
+	for _, object := range r.objects {
+		objects = append(objects, object)
+	}
+	sort.Slice(objects, func(i, j int) bool {
+		return sourceObjectKey(objects[i].Tenant, objects[i].Source, objects[i].Key) < sourceObjectKey(objects[j].Tenant, objects[j].Source, objects[j].Key)
+	})
+	raw, err := json.Marshal(objects)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(r.path), filepath.Base(r.path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write ingested objects: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(raw); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write ingested objects: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write ingested objects: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), r.path); err != nil {
+		return fmt.Errorf("failed to write ingested objects: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package infrastructure
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"etlgo/internal/domain"
+	"etlgo/pkg/logger"
+)
+
+const sqlAdColumns = "date, campaign_id, channel, clicks, impressions, cost_micros, utm_campaign, utm_source, utm_medium, ad_group_id, quality_score, flags, fingerprint, processed_at"
+
+// implements domain.AdRepository interface on a SQL database. Ads are read
+// from the primary, since runs read back the rows they just stored.
+type SQLAdRepository struct {
+	db     *sqlRouter
+	logger *logger.Logger
+}
+
+// creates an ad repository on the router's databases
+func NewSQLAdRepository(db *sqlRouter, logger *logger.Logger) *SQLAdRepository {
+	return &SQLAdRepository{db: db, logger: logger}
+}
+
+// replaces stored ads with the same natural key in the same transaction.
+// When the batch repeats a key, its last ad is kept.
+func (r *SQLAdRepository) Store(ctx context.Context, ads []domain.ProcessedAdData) error {
+	ads = lastByKey(ads, domain.ProcessedAdData.RecordKey)
+	err := r.db.inTx(ctx, func(tx *sql.Tx) error {
+		query := r.db.rebind("DELETE FROM ad_performance WHERE date = ? AND campaign_id = ? AND channel = ? AND utm_campaign = ? AND utm_source = ? AND utm_medium = ? AND ad_group_id = ?")
+		err := execEach(ctx, tx, query, ads, func(ad domain.ProcessedAdData) ([]any, error) {
+			return []any{sqlDate(ad.Date), ad.CampaignID, ad.Channel, ad.UTMCampaign, ad.UTMSource, ad.UTMMedium, ad.AdGroupID}, nil
+		})
+		if err != nil {
+			return err
+		}
+		return r.insert(ctx, tx, ads)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store ads: %w", err)
+	}
+
+	r.logger.WithContext(ctx).WithField("count", len(ads)).Info("Stored ads data")
+	return nil
+}
+
+func (r *SQLAdRepository) Replace(ctx context.Context, from, to time.Time, ads []domain.ProcessedAdData) error {
+	ads = lastByKey(ads, domain.ProcessedAdData.RecordKey)
+	var replaced int64
+	err := r.db.inTx(ctx, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, r.db.rebind("DELETE FROM ad_performance WHERE date >= ? AND date <= ?"), sqlDate(from), sqlDate(to))
+		if err != nil {
+			return err
+		}
+		replaced, _ = result.RowsAffected()
+		return r.insert(ctx, tx, ads)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to replace ads: %w", err)
+	}
+
+	r.logger.WithContext(ctx).WithFields(map[string]any{
+		"from":     sqlDate(from),
+		"to":       sqlDate(to),
+		"replaced": replaced,
+		"count":    len(ads),
+	}).Info("Replaced ads data")
+	return nil
+}
+
+func (r *SQLAdRepository) insert(ctx context.Context, tx *sql.Tx, ads []domain.ProcessedAdData) error {
+	query := r.db.rebind("INSERT INTO ad_performance (" + sqlAdColumns + ") VALUES (" + sqlPlaceholders(14) + ")")
+	return execEach(ctx, tx, query, ads, func(ad domain.ProcessedAdData) ([]any, error) {
+		var qualityScore any
+		if ad.QualityScore != nil {
+			qualityScore = *ad.QualityScore
+		}
+		return []any{
+			sqlDate(ad.Date), ad.CampaignID, ad.Channel, ad.Clicks, ad.Impressions, int64(ad.Cost),
+			ad.UTMCampaign, ad.UTMSource, ad.UTMMedium, ad.AdGroupID, qualityScore,
+			sqlFlags(ad.Flags), ad.Fingerprint, sqlTimestamp(ad.ProcessedAt),
+		}, nil
+	})
+}
+
+func (r *SQLAdRepository) GetByDateRange(ctx context.Context, from, to time.Time) ([]domain.ProcessedAdData, error) {
+	return r.query(ctx, from, to, sqlWhere{})
+}
+
+func (r *SQLAdRepository) GetByUTM(ctx context.Context, utm domain.UTMKey, from, to time.Time) ([]domain.ProcessedAdData, error) {
+	var where sqlWhere
+	where.add("utm_campaign = ? AND utm_source = ? AND utm_medium = ?", utm.Campaign, utm.Source, utm.Medium)
+	return r.query(ctx, from, to, where)
+}
+
+func (r *SQLAdRepository) GetByCampaign(ctx context.Context, campaignID string, from, to time.Time) ([]domain.ProcessedAdData, error) {
+	var where sqlWhere
+	where.add("campaign_id = ?", campaignID)
+	return r.query(ctx, from, to, where)
+}
+
+func (r *SQLAdRepository) GetByChannel(ctx context.Context, channel string, from, to time.Time) ([]domain.ProcessedAdData, error) {
+	var where sqlWhere
+	where.add("channel = ?", channel)
+	return r.query(ctx, from, to, where)
+}
+
+// returns the ads dated within the range that match the conditions, in the
+// order they were stored
+func (r *SQLAdRepository) query(ctx context.Context, from, to time.Time, where sqlWhere) ([]domain.ProcessedAdData, error) {
+	where.add("date >= ? AND date <= ?", sqlDate(from), sqlDate(to))
+	query := "SELECT " + sqlAdColumns + " FROM ad_performance" + where.String() + " ORDER BY date, id"
+
+	rows, err := r.db.writer().QueryContext(ctx, r.db.rebind(query), where.args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query ads: %w", err)
+	}
+	defer rows.Close()
+
+	var result []domain.ProcessedAdData
+	for rows.Next() {
+		var ad domain.ProcessedAdData
+		var date, processedAt sqlTime
+		var cost int64
+		var flags string
+		var qualityScore sql.NullFloat64
+		if err := rows.Scan(
+			&date, &ad.CampaignID, &ad.Channel, &ad.Clicks, &ad.Impressions, &cost,
+			&ad.UTMCampaign, &ad.UTMSource, &ad.UTMMedium, &ad.AdGroupID, &qualityScore,
+			&flags, &ad.Fingerprint, &processedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to read ad row: %w", err)
+		}
+		if qualityScore.Valid {
+			ad.QualityScore = &qualityScore.Float64
+		}
+		ad.Date = date.Time
+		ad.Cost = domain.Money(cost)
+		ad.Flags = scanSQLFlags(flags)
+		ad.ProcessedAt = processedAt.Time
+		result = append(result, ad)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query ads: %w", err)
+	}
+	return result, nil
+}
@@ -0,0 +1,190 @@
+package infrastructure
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"etlgo/internal/domain"
+	"etlgo/pkg/logger"
+)
+
+const sqlOpportunityColumns = "opportunity_id, contact_email, stage, amount_micros, created_at, closed_at, utm_campaign, utm_source, utm_medium, touches, probability, currency, original_amount_micros, flags, fingerprint, processed_at"
+
+// IDs bound per IN list, well below the bind parameter limits of every
+// dialect
+const sqlIDBatch = 500
+
+// implements domain.CRMRepository interface on a SQL database. Opportunities
+// are read from the primary, since runs read back the rows they just stored.
+type SQLCRMRepository struct {
+	db     *sqlRouter
+	logger *logger.Logger
+}
+
+// creates a CRM repository on the router's databases
+func NewSQLCRMRepository(db *sqlRouter, logger *logger.Logger) *SQLCRMRepository {
+	return &SQLCRMRepository{db: db, logger: logger}
+}
+
+// replaces stored opportunities with the same ID in the same transaction.
+// When the batch repeats an ID, its last opportunity is kept.
+func (r *SQLCRMRepository) Store(ctx context.Context, opportunities []domain.ProcessedOpportunity) error {
+	last := make(map[string]int, len(opportunities))
+	for i, opp := range opportunities {
+		if opp.OpportunityID != "" {
+			last[opp.OpportunityID] = i
+		}
+	}
+	ids := make([]string, 0, len(last))
+	stored := make([]domain.ProcessedOpportunity, 0, len(opportunities))
+	for i, opp := range opportunities {
+		if opp.OpportunityID != "" {
+			if last[opp.OpportunityID] != i {
+				continue
+			}
+			ids = append(ids, opp.OpportunityID)
+		}
+		stored = append(stored, opp)
+	}
+
+	err := r.db.inTx(ctx, func(tx *sql.Tx) error {
+		if err := r.delete(ctx, tx, ids); err != nil {
+			return err
+		}
+		query := r.db.rebind("INSERT INTO opportunities (" + sqlOpportunityColumns + ") VALUES (" + sqlPlaceholders(16) + ")")
+		return execEach(ctx, tx, query, stored, opportunityArgs)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store opportunities: %w", err)
+	}
+
+	r.logger.WithContext(ctx).WithField("count", len(opportunities)).Info("Stored CRM data")
+	return nil
+}
+
+func opportunityArgs(opp domain.ProcessedOpportunity) ([]any, error) {
+	touches, err := sqlJSON(opp.Touches)
+	if err != nil {
+		return nil, err
+	}
+	var closedAt, probability any
+	if opp.ClosedAt != nil {
+		closedAt = sqlTimestamp(*opp.ClosedAt)
+	}
+	if opp.Probability != nil {
+		probability = *opp.Probability
+	}
+	return []any{
+		opp.OpportunityID, opp.ContactEmail, string(opp.Stage), int64(opp.Amount), sqlTimestamp(opp.CreatedAt), closedAt,
+		opp.UTMCampaign, opp.UTMSource, opp.UTMMedium, touches, probability, opp.Currency, int64(opp.OriginalAmount),
+		sqlFlags(opp.Flags), opp.Fingerprint, sqlTimestamp(opp.ProcessedAt),
+	}, nil
+}
+
+func (r *SQLCRMRepository) Delete(ctx context.Context, ids []string) error {
+	err := r.db.inTx(ctx, func(tx *sql.Tx) error {
+		return r.delete(ctx, tx, ids)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete opportunities: %w", err)
+	}
+
+	r.logger.WithContext(ctx).WithField("count", len(ids)).Info("Deleted CRM data")
+	return nil
+}
+
+func (r *SQLCRMRepository) delete(ctx context.Context, tx *sql.Tx, ids []string) error {
+	for start := 0; start < len(ids); start += sqlIDBatch {
+		var where sqlWhere
+		where.in("opportunity_id", ids[start:min(start+sqlIDBatch, len(ids))])
+		if _, err := tx.ExecContext(ctx, r.db.rebind("DELETE FROM opportunities"+where.String()), where.args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// returns the stored opportunities with the given IDs; unknown IDs are
+// skipped
+func (r *SQLCRMRepository) GetByIDs(ctx context.Context, ids []string) ([]domain.ProcessedOpportunity, error) {
+	var result []domain.ProcessedOpportunity
+	for start := 0; start < len(ids); start += sqlIDBatch {
+		var where sqlWhere
+		where.in("opportunity_id", ids[start:min(start+sqlIDBatch, len(ids))])
+		opportunities, err := r.query(ctx, where)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, opportunities...)
+	}
+	return result, nil
+}
+
+func (r *SQLCRMRepository) GetByDateRange(ctx context.Context, from, to time.Time) ([]domain.ProcessedOpportunity, error) {
+	return r.query(ctx, createdWithin(from, to))
+}
+
+func (r *SQLCRMRepository) GetByUTM(ctx context.Context, utm domain.UTMKey, from, to time.Time) ([]domain.ProcessedOpportunity, error) {
+	where := createdWithin(from, to)
+	where.add("utm_campaign = ? AND utm_source = ? AND utm_medium = ?", utm.Campaign, utm.Source, utm.Medium)
+	return r.query(ctx, where)
+}
+
+func (r *SQLCRMRepository) GetByStage(ctx context.Context, stage domain.OpportunityStage, from, to time.Time) ([]domain.ProcessedOpportunity, error) {
+	where := createdWithin(from, to)
+	where.add("stage = ?", string(stage))
+	return r.query(ctx, where)
+}
+
+// matches opportunities created on the days from and to or in between
+func createdWithin(from, to time.Time) sqlWhere {
+	var where sqlWhere
+	where.add("created_at >= ? AND created_at < ?", sqlDayStart(from), sqlDayAfter(to))
+	return where
+}
+
+func (r *SQLCRMRepository) query(ctx context.Context, where sqlWhere) ([]domain.ProcessedOpportunity, error) {
+	query := "SELECT " + sqlOpportunityColumns + " FROM opportunities" + where.String() + " ORDER BY created_at, id"
+
+	rows, err := r.db.writer().QueryContext(ctx, r.db.rebind(query), where.args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query opportunities: %w", err)
+	}
+	defer rows.Close()
+
+	var result []domain.ProcessedOpportunity
+	for rows.Next() {
+		var opp domain.ProcessedOpportunity
+		var createdAt, closedAt, processedAt sqlTime
+		var stage, touches, flags string
+		var amount, originalAmount int64
+		var probability sql.NullFloat64
+		if err := rows.Scan(
+			&opp.OpportunityID, &opp.ContactEmail, &stage, &amount, &createdAt, &closedAt,
+			&opp.UTMCampaign, &opp.UTMSource, &opp.UTMMedium, &touches, &probability, &opp.Currency, &originalAmount,
+			&flags, &opp.Fingerprint, &processedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to read opportunity row: %w", err)
+		}
+		if err := scanSQLJSON(touches, &opp.Touches); err != nil {
+			return nil, fmt.Errorf("failed to read touches of opportunity %s: %w", opp.OpportunityID, err)
+		}
+		opp.Stage = domain.OpportunityStage(stage)
+		opp.Amount = domain.Money(amount)
+		opp.CreatedAt = createdAt.Time
+		opp.ClosedAt = closedAt.Ptr()
+		if probability.Valid {
+			opp.Probability = &probability.Float64
+		}
+		opp.OriginalAmount = domain.Money(originalAmount)
+		opp.Flags = scanSQLFlags(flags)
+		opp.ProcessedAt = processedAt.Time
+		result = append(result, opp)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query opportunities: %w", err)
+	}
+	return result, nil
+}
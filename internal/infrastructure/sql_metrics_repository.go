@@ -0,0 +1,342 @@
+package infrastructure
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"time"
+
+	"etlgo/internal/domain"
+	"etlgo/pkg/logger"
+)
+
+const sqlMetricsColumns = "date, channel, campaign_id, utm_campaign, utm_source, utm_medium, ad_group_id, clicks, impressions, cost_micros, leads, opportunities, closed_won, revenue_micros, stages, attributed_revenue_micros, pipeline_value_micros, expected_revenue_micros, sessions, conversions, quality_score, cpc_micros, cpa_micros, cvr_click_to_lead, cvr_lead_to_opp, cvr_opp_to_won, roas, stage_conversions, calculated_at, transform_version, producer"
+
+// implements domain.MetricsRepository interface on a SQL database. Filters,
+// pagination and the freshness metadata are computed by the database;
+// dashboard queries are routed to read replicas when there are any.
+type SQLMetricsRepository struct {
+	db     *sqlRouter
+	clock  domain.Clock
+	logger *logger.Logger
+}
+
+// creates a metrics repository on the router's databases
+func NewSQLMetricsRepository(db *sqlRouter, clock domain.Clock, logger *logger.Logger) *SQLMetricsRepository {
+	return &SQLMetricsRepository{db: db, clock: clock, logger: logger}
+}
+
+// replaces the stored metrics with the same date, UTM, ad group and
+// producer like Upsert
+func (r *SQLMetricsRepository) Store(ctx context.Context, metrics []domain.BusinessMetrics) error {
+	if err := r.upsert(ctx, metrics); err != nil {
+		return fmt.Errorf("failed to store metrics: %w", err)
+	}
+
+	r.logger.WithContext(ctx).WithField("count", len(metrics)).Info("Stored business metrics")
+	return nil
+}
+
+// replaces the stored metrics with the same date, UTM, ad group and
+// producer, adding them when there are none
+func (r *SQLMetricsRepository) Upsert(ctx context.Context, metrics []domain.BusinessMetrics) error {
+	if err := r.upsert(ctx, metrics); err != nil {
+		return fmt.Errorf("failed to upsert metrics: %w", err)
+	}
+
+	r.logger.WithContext(ctx).WithField("count", len(metrics)).Info("Upserted business metrics")
+	return nil
+}
+
+// deletes the stored metrics with the same date, UTM, ad group and producer
+// and inserts the metrics in one transaction. When the batch repeats a
+// date, UTM, ad group and producer, its last metric is kept.
+func (r *SQLMetricsRepository) upsert(ctx context.Context, metrics []domain.BusinessMetrics) error {
+	metrics = lastByKey(metrics, func(m domain.BusinessMetrics) string {
+		return sqlDate(m.Date) + "|" + domain.UTMKey{Campaign: m.UTMCampaign, Source: m.UTMSource, Medium: m.UTMMedium}.String() + "|" + m.AdGroupID + "|" + m.Producer
+	})
+	return r.db.inTx(ctx, func(tx *sql.Tx) error {
+		query := r.db.rebind("DELETE FROM business_metrics WHERE date = ? AND utm_campaign = ? AND utm_source = ? AND utm_medium = ? AND ad_group_id = ? AND producer = ?")
+		err := execEach(ctx, tx, query, metrics, func(metric domain.BusinessMetrics) ([]any, error) {
+			return []any{sqlDate(metric.Date), metric.UTMCampaign, metric.UTMSource, metric.UTMMedium, metric.AdGroupID, metric.Producer}, nil
+		})
+		if err != nil {
+			return err
+		}
+		return r.insert(ctx, tx, metrics)
+	})
+}
+
+// replaces the ETL-calculated metrics dated within the range in one
+// transaction, so readers see either the old or the new metrics. The
+// transaction starts with the delete, so on SQLite it takes the write lock
+// up front instead of upgrading a read lock that a concurrent writer may
+// have invalidated.
+func (r *SQLMetricsRepository) Replace(ctx context.Context, from, to time.Time, metrics []domain.BusinessMetrics) ([]domain.BusinessMetrics, error) {
+	var replaced []domain.BusinessMetrics
+	err := r.db.inTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		replaced, err = r.query(ctx, tx,
+			"DELETE FROM business_metrics WHERE date >= ? AND date <= ? AND producer = '' RETURNING "+sqlMetricsColumns,
+			sqlDate(from), sqlDate(to),
+		)
+		if err != nil {
+			return err
+		}
+		return r.insert(ctx, tx, metrics)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to replace metrics: %w", err)
+	}
+	sort.SliceStable(replaced, func(i, j int) bool {
+		return replaced[i].Date.Before(replaced[j].Date)
+	})
+
+	r.logger.WithContext(ctx).WithFields(map[string]any{
+		"from":     sqlDate(from),
+		"to":       sqlDate(to),
+		"count":    len(metrics),
+		"replaced": len(replaced),
+	}).Info("Replaced business metrics")
+	return replaced, nil
+}
+
+func (r *SQLMetricsRepository) insert(ctx context.Context, tx *sql.Tx, metrics []domain.BusinessMetrics) error {
+	query := r.db.rebind("INSERT INTO business_metrics (" + sqlMetricsColumns + ") VALUES (" + sqlPlaceholders(31) + ")")
+	return execEach(ctx, tx, query, metrics, func(m domain.BusinessMetrics) ([]any, error) {
+		stages, err := sqlJSON(m.Stages)
+		if err != nil {
+			return nil, err
+		}
+		stageConversions, err := sqlJSON(m.StageConversions)
+		if err != nil {
+			return nil, err
+		}
+		return []any{
+			sqlDate(m.Date), m.Channel, m.CampaignID, m.UTMCampaign, m.UTMSource, m.UTMMedium, m.AdGroupID,
+			m.Clicks, m.Impressions, int64(m.Cost), m.Leads, m.Opportunities, m.ClosedWon, int64(m.Revenue), stages,
+			int64(m.AttributedRevenue), int64(m.PipelineValue), int64(m.ExpectedRevenue), m.Sessions, m.Conversions, m.QualityScore,
+			int64(m.CPC), int64(m.CPA), m.CVRClickToLead, m.CVRLeadToOpp, m.CVROppToWon, m.ROAS, stageConversions,
+			sqlTimestamp(m.CalculatedAt), m.TransformVersion, m.Producer,
+		}, nil
+	})
+}
+
+func (r *SQLMetricsRepository) GetByFilter(ctx context.Context, filter domain.MetricsFilter) (*domain.MetricsResponse, error) {
+	now := r.clock.Now()
+	from := now.AddDate(0, 0, -365)
+	to := now
+	if filter.From != nil {
+		from = *filter.From
+	}
+	if filter.To != nil {
+		to = *filter.To
+	}
+
+	limit := 100
+	if filter.Limit > 0 {
+		limit = filter.Limit
+	}
+	offset := max(filter.Offset, 0)
+
+	var where sqlWhere
+	where.add("date >= ? AND date <= ?", sqlDate(from), sqlDate(to))
+	filtered := map[string]string{
+		domain.DimensionChannel:     filter.Channel,
+		domain.DimensionCampaignID:  filter.CampaignID,
+		domain.DimensionUTMCampaign: filter.UTMCampaign,
+		domain.DimensionUTMSource:   filter.UTMSource,
+		domain.DimensionUTMMedium:   filter.UTMMedium,
+		domain.DimensionAdGroupID:   filter.AdGroupID,
+	}
+	for _, dimension := range slices.Sorted(maps.Keys(filtered)) {
+		if value := filtered[dimension]; value != "" {
+			where.add(dimension+" = ?", value)
+		}
+	}
+	for _, dimension := range slices.Sorted(maps.Keys(filter.Scope)) {
+		if !domain.IsValidDimension(dimension) {
+			return nil, fmt.Errorf("unsupported scope dimension %q", dimension)
+		}
+		where.in(dimension, filter.Scope[dimension])
+	}
+
+	db, staleness := r.db.reader(ctx)
+	tx, err := db.BeginTx(ctx, r.db.snapshotTx())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query metrics: %w", err)
+	}
+	defer tx.Rollback()
+
+	var total int
+	var through, calculatedFrom, calculatedTo sqlTime
+	zero := sqlTimestamp(time.Time{})
+	err = tx.QueryRowContext(ctx, r.db.rebind(
+		"SELECT COUNT(*), MAX(date), MIN(CASE WHEN calculated_at > ? THEN calculated_at END), MAX(CASE WHEN calculated_at > ? THEN calculated_at END) FROM business_metrics"+where.String(),
+	), append([]any{zero, zero}, where.args...)...).Scan(&total, &through, &calculatedFrom, &calculatedTo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count metrics: %w", err)
+	}
+
+	meta := &domain.MetricsMeta{}
+	if through.Valid {
+		meta.DataThroughDate = sqlDate(through.Time)
+	}
+	if calculatedFrom.Valid && calculatedTo.Valid {
+		meta.CalculatedAt = &domain.CalculatedAtSpan{From: calculatedFrom.Time, To: calculatedTo.Time}
+	}
+	meta.TransformVersions, err = r.transformVersions(ctx, tx, where)
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := r.query(ctx, tx,
+		"SELECT "+sqlMetricsColumns+" FROM business_metrics"+where.String()+" ORDER BY date, id LIMIT ? OFFSET ?",
+		append(slices.Clone(where.args), limit, offset)...,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	r.logger.WithContext(ctx).WithFields(map[string]any{
+		"final_count": len(page),
+		"total":       total,
+		"limit":       limit,
+		"offset":      offset,
+	}).Info("Returning metrics response")
+
+	return &domain.MetricsResponse{
+		Data:      page,
+		Total:     total,
+		Limit:     limit,
+		Offset:    offset,
+		HasMore:   offset+len(page) < total,
+		Staleness: staleness,
+		Meta:      meta,
+	}, nil
+}
+
+// returns the sorted transform versions of the matching metrics
+func (r *SQLMetricsRepository) transformVersions(ctx context.Context, tx *sql.Tx, where sqlWhere) ([]string, error) {
+	where.add("transform_version <> ''")
+	rows, err := tx.QueryContext(ctx, r.db.rebind("SELECT DISTINCT transform_version FROM business_metrics"+where.String()+" ORDER BY transform_version"), where.args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query transform versions: %w", err)
+	}
+	defer rows.Close()
+
+	var versions []string
+	for rows.Next() {
+		var version string
+		if err := rows.Scan(&version); err != nil {
+			return nil, fmt.Errorf("failed to read transform version: %w", err)
+		}
+		versions = append(versions, version)
+	}
+	return versions, rows.Err()
+}
+
+func (r *SQLMetricsRepository) GetByDate(ctx context.Context, date time.Time) ([]domain.BusinessMetrics, error) {
+	metrics, err := r.query(ctx, r.db.writer(), "SELECT "+sqlMetricsColumns+" FROM business_metrics WHERE date = ? ORDER BY id", sqlDate(date))
+	if err != nil {
+		return nil, err
+	}
+	if metrics == nil {
+		return []domain.BusinessMetrics{}, nil
+	}
+	return metrics, nil
+}
+
+// counts distinct values of a dimension with a GROUP BY on the reader
+func (r *SQLMetricsRepository) GetDistinctValues(ctx context.Context, dimension string, from, to time.Time) ([]domain.DimensionValue, error) {
+	if !domain.IsValidDimension(dimension) {
+		return nil, domain.Errorf(domain.ErrValidation, "unsupported dimension %q", dimension)
+	}
+
+	db, _ := r.db.reader(ctx)
+	rows, err := db.QueryContext(ctx, r.db.rebind(
+		"SELECT "+dimension+", COUNT(*) FROM business_metrics WHERE date >= ? AND date <= ? GROUP BY "+dimension,
+	), sqlDate(from), sqlDate(to))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query %s values: %w", dimension, err)
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var value string
+		var count int
+		if err := rows.Scan(&value, &count); err != nil {
+			return nil, fmt.Errorf("failed to read %s value: %w", dimension, err)
+		}
+		counts[value] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query %s values: %w", dimension, err)
+	}
+	return domain.SortedDimensionValues(counts), nil
+}
+
+// counts the metrics dated within the range on the reader
+func (r *SQLMetricsRepository) Count(ctx context.Context, from, to time.Time) (int64, error) {
+	db, _ := r.db.reader(ctx)
+	var count int64
+	err := db.QueryRowContext(ctx, r.db.rebind("SELECT COUNT(*) FROM business_metrics WHERE date >= ? AND date <= ?"), sqlDate(from), sqlDate(to)).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count metrics: %w", err)
+	}
+	return count, nil
+}
+
+// the query methods shared by *sql.DB and *sql.Tx
+type sqlQuerier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+func (r *SQLMetricsRepository) query(ctx context.Context, db sqlQuerier, query string, args ...any) ([]domain.BusinessMetrics, error) {
+	rows, err := db.QueryContext(ctx, r.db.rebind(query), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query metrics: %w", err)
+	}
+	defer rows.Close()
+
+	var result []domain.BusinessMetrics
+	for rows.Next() {
+		var m domain.BusinessMetrics
+		var date, calculatedAt sqlTime
+		var cost, revenue, attributed, pipeline, expected, cpc, cpa int64
+		var stages, stageConversions string
+		if err := rows.Scan(
+			&date, &m.Channel, &m.CampaignID, &m.UTMCampaign, &m.UTMSource, &m.UTMMedium, &m.AdGroupID,
+			&m.Clicks, &m.Impressions, &cost, &m.Leads, &m.Opportunities, &m.ClosedWon, &revenue, &stages,
+			&attributed, &pipeline, &expected, &m.Sessions, &m.Conversions, &m.QualityScore,
+			&cpc, &cpa, &m.CVRClickToLead, &m.CVRLeadToOpp, &m.CVROppToWon, &m.ROAS, &stageConversions,
+			&calculatedAt, &m.TransformVersion, &m.Producer,
+		); err != nil {
+			return nil, fmt.Errorf("failed to read metrics row: %w", err)
+		}
+		if err := scanSQLJSON(stages, &m.Stages); err != nil {
+			return nil, fmt.Errorf("failed to read stages: %w", err)
+		}
+		if err := scanSQLJSON(stageConversions, &m.StageConversions); err != nil {
+			return nil, fmt.Errorf("failed to read stage conversions: %w", err)
+		}
+		m.Date = date.Time
+		m.Cost = domain.Money(cost)
+		m.Revenue = domain.Money(revenue)
+		m.AttributedRevenue = domain.Money(attributed)
+		m.PipelineValue = domain.Money(pipeline)
+		m.ExpectedRevenue = domain.Money(expected)
+		m.CPC = domain.Money(cpc)
+		m.CPA = domain.Money(cpa)
+		m.CalculatedAt = calculatedAt.Time
+		result = append(result, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query metrics: %w", err)
+	}
+	return result, nil
+}
@@ -0,0 +1,146 @@
+package infrastructure
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"etlgo/internal/domain"
+	"etlgo/pkg/logger"
+)
+
+// how long a measured replica lag is trusted before it is measured again
+const replicaLagTTL = 5 * time.Second
+
+// a read replica and its last measured replication lag
+type sqlReplica struct {
+	name       string
+	db         *sql.DB
+	lag        time.Duration
+	measuredAt time.Time
+	err        error
+	// set while one caller measures the lag, so others keep the cached one
+	measuring bool
+}
+
+// reported for a replica whose first lag measurement is still running
+var errReplicaLagUnmeasured = errors.New("replica lag not measured yet")
+
+// routes SQL repository queries between the primary and read replicas.
+// Writes always go to the primary. Reads go round-robin to replicas whose
+// lag is within maxLag and fall back to the primary when none is, so
+// dashboard queries stay off the primary without serving data that is too
+// old.
+type sqlRouter struct {
+	dialect  string
+	primary  *sql.DB
+	replicas []*sqlReplica
+	maxLag   time.Duration
+	next     atomic.Uint64
+	mutex    sync.Mutex
+	logger   *logger.Logger
+}
+
+func newSQLRouter(dialect string, primary *sql.DB, replicas []*sql.DB, maxLag time.Duration, logger *logger.Logger) *sqlRouter {
+	r := &sqlRouter{
+		dialect: dialect,
+		primary: primary,
+		maxLag:  maxLag,
+		logger:  logger,
+	}
+	for i, db := range replicas {
+		r.replicas = append(r.replicas, &sqlReplica{name: fmt.Sprintf("replica-%d", i+1), db: db})
+	}
+	return r
+}
+
+// returns the primary for Store and other writes
+func (r *sqlRouter) writer() *sql.DB {
+	return r.primary
+}
+
+// returns the database for a read query and, when it is a replica, how
+// stale its data may be
+func (r *sqlRouter) reader(ctx context.Context) (*sql.DB, *domain.Staleness) {
+	if len(r.replicas) == 0 {
+		return r.primary, nil
+	}
+
+	start := int(r.next.Add(1))
+	for i := range r.replicas {
+		replica := r.replicas[(start+i)%len(r.replicas)]
+		lag, measuredAt, err := r.replicaLag(ctx, replica)
+		if err != nil || lag > r.maxLag {
+			continue
+		}
+		return replica.db, &domain.Staleness{
+			Source:     replica.name,
+			LagSeconds: lag.Seconds(),
+			MeasuredAt: measuredAt,
+		}
+	}
+
+	r.logger.WithContext(ctx).Warn("No read replica within the lag limit, reading from primary")
+	return r.primary, nil
+}
+
+// returns the replica's cached lag, measuring it again once it expired.
+// The measurement runs outside the lock, so a slow replica does not hold up
+// reads routed to the others; callers arriving meanwhile get the cached lag.
+func (r *sqlRouter) replicaLag(ctx context.Context, replica *sqlReplica) (time.Duration, time.Time, error) {
+	r.mutex.Lock()
+	if replica.measuring || time.Since(replica.measuredAt) < replicaLagTTL {
+		lag, measuredAt, err := replica.lag, replica.measuredAt, replica.err
+		r.mutex.Unlock()
+		if measuredAt.IsZero() {
+			err = errReplicaLagUnmeasured
+		}
+		return lag, measuredAt, err
+	}
+	replica.measuring = true
+	r.mutex.Unlock()
+
+	lag, err := r.measureLag(ctx, replica.db)
+	measuredAt := time.Now().UTC()
+	if err != nil {
+		r.logger.WithContext(ctx).WithError(err).WithField("replica", replica.name).Warn("Failed to measure replica lag")
+	}
+
+	r.mutex.Lock()
+	replica.lag, replica.measuredAt, replica.err = lag, measuredAt, err
+	replica.measuring = false
+	r.mutex.Unlock()
+	return lag, measuredAt, err
+}
+
+func (r *sqlRouter) measureLag(ctx context.Context, db *sql.DB) (time.Duration, error) {
+	if r.dialect != DialectPostgres {
+		// no portable lag query; replicas are assumed current once reachable
+		return 0, db.PingContext(ctx)
+	}
+
+	// an idle primary makes replay timestamps look old, so a replica that
+	// has replayed everything it received reports no lag
+	var seconds float64
+	err := db.QueryRowContext(ctx, `SELECT CASE
+		WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+		ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+	END`).Scan(&seconds)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// closes the primary and every replica
+func (r *sqlRouter) Close() error {
+	errs := []error{r.primary.Close()}
+	for _, replica := range r.replicas {
+		errs = append(errs, replica.db.Close())
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,321 @@
+package infrastructure
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"etlgo/internal/domain"
+	"etlgo/pkg/logger"
+)
+
+// layout of timestamps bound to SQL queries. Times are written in UTC with
+// a fixed number of fraction digits, so text columns sort chronologically.
+const sqlTimestampLayout = "2006-01-02T15:04:05.000000000Z"
+
+// layout of dates bound to SQL queries
+const sqlDateLayout = "2006-01-02"
+
+// opens the primary and replicas of a SQL backend with the driver, sized by
+// the pool options, and returns the repositories reading and writing
+// through them
+func openSQLStorage(backend, dialect, driver string, opts StorageOptions, logger *logger.Logger) (*domain.Storage, error) {
+	if opts.DSN == "" {
+		return nil, fmt.Errorf("STORAGE_DSN is required")
+	}
+
+	var opened []*sql.DB
+	open := func(dsn string) (*sql.DB, error) {
+		db, err := sql.Open(driver, dsn)
+		if err != nil {
+			return nil, err
+		}
+		opened = append(opened, db)
+		opts.configurePool(db)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := db.PingContext(ctx); err != nil {
+			return nil, err
+		}
+		return db, nil
+	}
+	closeOpened := func() {
+		for _, db := range opened {
+			db.Close()
+		}
+	}
+
+	primary, err := open(opts.DSN)
+	if err != nil {
+		closeOpened()
+		return nil, fmt.Errorf("failed to connect: %w", err)
+	}
+	var replicas []*sql.DB
+	for i, dsn := range opts.ReadDSNs {
+		replica, err := open(dsn)
+		if err != nil {
+			closeOpened()
+			// The DSN is not echoed, it may hold a password
+			return nil, fmt.Errorf("failed to connect to read replica %d: %w", i+1, err)
+		}
+		replicas = append(replicas, replica)
+	}
+
+	migrator, err := NewSQLSchemaMigrator(primary, dialect, logger)
+	if err != nil {
+		closeOpened()
+		return nil, err
+	}
+	router := newSQLRouter(dialect, primary, replicas, opts.MaxReplicaLag, logger)
+	return &domain.Storage{
+		Backend: backend,
+		Ads:     NewSQLAdRepository(router, logger),
+		CRM:     NewSQLCRMRepository(router, logger),
+		Metrics: NewSQLMetricsRepository(router, opts.Clock, logger),
+		Schema:  migrator,
+		Closer:  router,
+	}, nil
+}
+
+// applies the connection pool limits
+func (o StorageOptions) configurePool(db *sql.DB) {
+	if o.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(o.MaxOpenConns)
+	}
+	if o.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(o.MaxIdleConns)
+	}
+	if o.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(o.ConnMaxLifetime)
+	}
+}
+
+// sets the SQLite options a server needs unless the DSN sets them: WAL
+// journaling so metrics queries do not block loads, and a busy timeout so
+// concurrent writers wait for the database lock instead of failing
+func sqliteDSN(dsn string) string {
+	if dsn == "" {
+		return ""
+	}
+	path, query, _ := strings.Cut(dsn, "?")
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		// left for the driver to report
+		return dsn
+	}
+	for _, option := range [][2]string{{"_journal_mode", "WAL"}, {"_busy_timeout", "5000"}} {
+		if !values.Has(option[0]) {
+			values.Set(option[0], option[1])
+		}
+	}
+	return path + "?" + values.Encode()
+}
+
+// rewrites the ? placeholders of a query into the dialect's syntax
+func (r *sqlRouter) rebind(query string) string {
+	if r.dialect != DialectPostgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, c := range query {
+		if c == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// options of transactions whose queries must see one snapshot. SQLite
+// transactions are always serializable.
+func (r *sqlRouter) snapshotTx() *sql.TxOptions {
+	if r.dialect == DialectPostgres {
+		return &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}
+	}
+	return nil
+}
+
+// runs fn in a transaction on the primary, committing when it succeeds
+func (r *sqlRouter) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := r.writer().BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// the conditions of a query's WHERE clause with their arguments
+type sqlWhere struct {
+	clauses []string
+	args    []any
+}
+
+func (w *sqlWhere) add(clause string, args ...any) {
+	w.clauses = append(w.clauses, clause)
+	w.args = append(w.args, args...)
+}
+
+// restricts the column to the values
+func (w *sqlWhere) in(column string, values []string) {
+	args := make([]any, len(values))
+	for i, value := range values {
+		args[i] = value
+	}
+	w.add(column+" IN ("+sqlPlaceholders(len(values))+")", args...)
+}
+
+func (w *sqlWhere) String() string {
+	if len(w.clauses) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(w.clauses, " AND ")
+}
+
+// returns n comma separated ? placeholders
+func sqlPlaceholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// executes the statement for every row in one prepared statement of the
+// transaction
+func execEach[T any](ctx context.Context, tx *sql.Tx, query string, rows []T, args func(T) ([]any, error)) error {
+	stmt, err := tx.PrepareContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, row := range rows {
+		values, err := args(row)
+		if err != nil {
+			return err
+		}
+		if _, err := stmt.ExecContext(ctx, values...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// returns the rows with the last of the rows sharing a key, in the order
+// the kept rows appear
+func lastByKey[T any](rows []T, key func(T) string) []T {
+	last := make(map[string]int, len(rows))
+	for i, row := range rows {
+		last[key(row)] = i
+	}
+	kept := make([]T, 0, len(last))
+	for i, row := range rows {
+		if last[key(row)] == i {
+			kept = append(kept, row)
+		}
+	}
+	return kept
+}
+
+func sqlDate(t time.Time) string {
+	return t.Format(sqlDateLayout)
+}
+
+func sqlTimestamp(t time.Time) string {
+	return t.UTC().Format(sqlTimestampLayout)
+}
+
+// returns the day after t, the exclusive end of a range of whole days
+func sqlDayAfter(t time.Time) string {
+	return sqlTimestamp(time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC))
+}
+
+// returns the start of t's day, the inclusive start of a range of whole
+// days
+func sqlDayStart(t time.Time) string {
+	return sqlTimestamp(time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC))
+}
+
+// scans date and timestamp columns, which drivers return as time.Time or
+// as text depending on the dialect. Valid is false for NULL.
+type sqlTime struct {
+	Time  time.Time
+	Valid bool
+}
+
+func (t *sqlTime) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		t.Time, t.Valid = time.Time{}, false
+		return nil
+	case time.Time:
+		t.Time, t.Valid = v.UTC(), true
+		return nil
+	case []byte:
+		return t.parse(string(v))
+	case string:
+		return t.parse(v)
+	}
+	return fmt.Errorf("cannot scan %T into a time", src)
+}
+
+func (t *sqlTime) parse(value string) error {
+	for _, layout := range []string{time.RFC3339Nano, sqlDateLayout, "2006-01-02 15:04:05.999999999-07:00"} {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			t.Time, t.Valid = parsed.UTC(), true
+			return nil
+		}
+	}
+	return fmt.Errorf("cannot parse %q as a time", value)
+}
+
+// returns the time or nil
+func (t sqlTime) Ptr() *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+// encodes a list or map column as JSON text, empty for empty values
+func sqlJSON(value any) (string, error) {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	switch text := string(encoded); text {
+	case "null", "[]", "{}":
+		return "", nil
+	default:
+		return text, nil
+	}
+}
+
+// decodes JSON text written by sqlJSON, leaving target untouched when empty
+func scanSQLJSON(text string, target any) error {
+	if text == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(text), target)
+}
+
+func sqlFlags(flags []string) string {
+	return strings.Join(flags, ",")
+}
+
+func scanSQLFlags(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(text, ",")
+}
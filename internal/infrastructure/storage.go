@@ -0,0 +1,90 @@
+package infrastructure
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"etlgo/internal/domain"
+	"etlgo/pkg/logger"
+
+	_ "github.com/lib/pq"           // registers the postgres driver
+	_ "github.com/mattn/go-sqlite3" // registers the sqlite3 driver
+)
+
+// StorageBackendMemory keeps data in process memory; it is lost on restart
+const StorageBackendMemory = "memory"
+
+// StorageBackendPostgres keeps data in a PostgreSQL database
+const StorageBackendPostgres = "postgres"
+
+// StorageBackendSQLite keeps data in a SQLite database file, for single
+// node deployments
+const StorageBackendSQLite = "sqlite"
+
+// connection settings of a storage backend. ReadDSNs are read replicas of
+// DSN; SQL backends route queries to them while their replication lag is
+// within MaxReplicaLag. The pool limits apply to each SQL database; zero
+// keeps the driver defaults. Clock defaults to the system clock.
+type StorageOptions struct {
+	Clock domain.Clock
+
+	DSN           string
+	ReadDSNs      []string
+	MaxReplicaLag time.Duration
+
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+}
+
+// opens the repositories of a backend. Backends must pass the checks of
+// storagetest, see etlctl check-storage and the backend tests.
+type storageOpener func(opts StorageOptions, logger *logger.Logger) (*domain.Storage, error)
+
+var storageBackends = map[string]storageOpener{
+	StorageBackendMemory: func(opts StorageOptions, logger *logger.Logger) (*domain.Storage, error) {
+		if len(opts.ReadDSNs) > 0 {
+			return nil, fmt.Errorf("read replicas are only supported by SQL backends")
+		}
+		return &domain.Storage{
+			Backend: StorageBackendMemory,
+			Ads:     NewAdRepository(logger),
+			CRM:     NewCRMRepository(logger),
+			Metrics: NewMetricsRepository(opts.Clock, logger),
+		}, nil
+	},
+	StorageBackendPostgres: func(opts StorageOptions, logger *logger.Logger) (*domain.Storage, error) {
+		return openSQLStorage(StorageBackendPostgres, DialectPostgres, "postgres", opts, logger)
+	},
+	StorageBackendSQLite: func(opts StorageOptions, logger *logger.Logger) (*domain.Storage, error) {
+		if len(opts.ReadDSNs) > 0 {
+			return nil, fmt.Errorf("read replicas are not supported by SQLite")
+		}
+		opts.DSN = sqliteDSN(opts.DSN)
+		return openSQLStorage(StorageBackendSQLite, DialectSQLite, "sqlite3", opts, logger)
+	},
+}
+
+// returns the names of the available storage backends, sorted
+func StorageBackends() []string {
+	return slices.Sorted(maps.Keys(storageBackends))
+}
+
+// opens the ads, CRM and metrics repositories of the named backend
+func OpenStorage(backend string, opts StorageOptions, logger *logger.Logger) (*domain.Storage, error) {
+	open, ok := storageBackends[strings.ToLower(backend)]
+	if !ok {
+		return nil, fmt.Errorf("unknown storage backend %q, expected one of: %s", backend, strings.Join(StorageBackends(), ", "))
+	}
+	if opts.Clock == nil {
+		opts.Clock = domain.SystemClock
+	}
+	storage, err := open(opts, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s storage: %w", backend, err)
+	}
+	return storage, nil
+}
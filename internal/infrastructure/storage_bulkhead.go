@@ -0,0 +1,341 @@
+package infrastructure
+
+import (
+	"context"
+	"time"
+
+	"etlgo/internal/domain"
+	"etlgo/pkg/logger"
+	"etlgo/pkg/metrics"
+)
+
+// returned when a read query is turned away because the storage is busy
+var ErrStorageBusy = domain.NewError(domain.ErrOverloaded, "storage is busy")
+
+// bulkhead lanes
+const (
+	bulkheadRead  = "read"
+	bulkheadWrite = "write"
+)
+
+// limits of the storage bulkhead. At most MaxReads read queries run at
+// once; up to ReadQueue more wait at most ReadQueueTimeout for a slot and
+// further ones are rejected with ErrStorageBusy. Reads of loads
+// (domain.WithStorageLoad) wait for a slot without being rejected. At most
+// MaxWrites writes run at once and the rest wait. Zero limits are
+// unlimited.
+type BulkheadOptions struct {
+	MaxReads         int
+	ReadQueue        int
+	ReadQueueTimeout time.Duration
+	MaxWrites        int
+}
+
+// limits concurrent repository operations, with separate lanes for reads
+// and writes so dashboard queries and ETL loads cannot starve each other
+type StorageBulkhead struct {
+	opts    BulkheadOptions
+	reads   *bulkheadLane
+	writes  *bulkheadLane
+	logger  *logger.Logger
+	metrics *metrics.Metrics
+}
+
+func NewStorageBulkhead(opts BulkheadOptions, logger *logger.Logger, metrics *metrics.Metrics) *StorageBulkhead {
+	b := &StorageBulkhead{opts: opts, logger: logger, metrics: metrics}
+	b.reads = newBulkheadLane(bulkheadRead, opts.MaxReads, opts.ReadQueue)
+	b.writes = newBulkheadLane(bulkheadWrite, opts.MaxWrites, 0)
+	return b
+}
+
+// replaces the ads, CRM and metrics repositories of the storage with ones
+// passing through the bulkhead
+func (b *StorageBulkhead) WrapStorage(storage *domain.Storage) {
+	if b.reads == nil && b.writes == nil {
+		return
+	}
+	storage.Ads = &bulkheadAdRepository{next: storage.Ads, bulkhead: b}
+	storage.CRM = &bulkheadCRMRepository{next: storage.CRM, bulkhead: b}
+	storage.Metrics = &bulkheadMetricsRepository{next: storage.Metrics, bulkhead: b}
+}
+
+// the slots of one lane. queue holds a token per waiting rejectable
+// operation; without a queue they are rejected as soon as every slot is
+// taken.
+type bulkheadLane struct {
+	name  string
+	slots chan struct{}
+	queue chan struct{}
+}
+
+// returns nil for an unlimited lane
+func newBulkheadLane(name string, limit, queue int) *bulkheadLane {
+	if limit <= 0 {
+		return nil
+	}
+	lane := &bulkheadLane{name: name, slots: make(chan struct{}, limit)}
+	if queue > 0 {
+		lane.queue = make(chan struct{}, queue)
+	}
+	return lane
+}
+
+func (b *StorageBulkhead) read(ctx context.Context, operation string) (func(), error) {
+	return b.acquire(ctx, b.reads, operation, !domain.IsStorageLoad(ctx))
+}
+
+func (b *StorageBulkhead) write(ctx context.Context, operation string) (func(), error) {
+	return b.acquire(ctx, b.writes, operation, false)
+}
+
+// takes a slot of the lane and returns its release. Bounded waits give up
+// when the queue is full or the slot did not free up in time.
+func (b *StorageBulkhead) acquire(ctx context.Context, lane *bulkheadLane, operation string, bounded bool) (func(), error) {
+	if lane == nil {
+		return func() {}, nil
+	}
+
+	select {
+	case lane.slots <- struct{}{}:
+		return b.admitted(lane), nil
+	default:
+	}
+
+	if bounded {
+		if lane.queue == nil {
+			return nil, b.reject(ctx, lane, operation, "queue_full")
+		}
+		select {
+		case lane.queue <- struct{}{}:
+			defer func() { <-lane.queue }()
+		default:
+			return nil, b.reject(ctx, lane, operation, "queue_full")
+		}
+	}
+
+	var timeout <-chan time.Time
+	if bounded && b.opts.ReadQueueTimeout > 0 {
+		timer := time.NewTimer(b.opts.ReadQueueTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	start := time.Now()
+	b.metrics.AddStorageQueued(lane.name, 1)
+	defer b.metrics.AddStorageQueued(lane.name, -1)
+	select {
+	case lane.slots <- struct{}{}:
+		b.metrics.RecordStorageQueueWait(lane.name, time.Since(start))
+		return b.admitted(lane), nil
+	case <-timeout:
+		b.metrics.RecordStorageQueueWait(lane.name, time.Since(start))
+		return nil, b.reject(ctx, lane, operation, "timeout")
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (b *StorageBulkhead) admitted(lane *bulkheadLane) func() {
+	b.metrics.AddStorageInUse(lane.name, 1)
+	return func() {
+		<-lane.slots
+		b.metrics.AddStorageInUse(lane.name, -1)
+	}
+}
+
+func (b *StorageBulkhead) reject(ctx context.Context, lane *bulkheadLane, operation, reason string) error {
+	b.metrics.RecordStorageRejection(lane.name, reason)
+	b.logger.WithContext(ctx).WithFields(map[string]any{
+		"lane":      lane.name,
+		"operation": operation,
+		"reason":    reason,
+	}).Warn("Storage busy, rejecting query")
+	return ErrStorageBusy
+}
+
+type bulkheadAdRepository struct {
+	next     domain.AdRepository
+	bulkhead *StorageBulkhead
+}
+
+func (r *bulkheadAdRepository) Store(ctx context.Context, ads []domain.ProcessedAdData) error {
+	release, err := r.bulkhead.write(ctx, "ads.Store")
+	if err != nil {
+		return err
+	}
+	defer release()
+	return r.next.Store(ctx, ads)
+}
+
+func (r *bulkheadAdRepository) Replace(ctx context.Context, from, to time.Time, ads []domain.ProcessedAdData) error {
+	release, err := r.bulkhead.write(ctx, "ads.Replace")
+	if err != nil {
+		return err
+	}
+	defer release()
+	return r.next.Replace(ctx, from, to, ads)
+}
+
+func (r *bulkheadAdRepository) GetByDateRange(ctx context.Context, from, to time.Time) ([]domain.ProcessedAdData, error) {
+	release, err := r.bulkhead.read(ctx, "ads.GetByDateRange")
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return r.next.GetByDateRange(ctx, from, to)
+}
+
+func (r *bulkheadAdRepository) GetByUTM(ctx context.Context, utm domain.UTMKey, from, to time.Time) ([]domain.ProcessedAdData, error) {
+	release, err := r.bulkhead.read(ctx, "ads.GetByUTM")
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return r.next.GetByUTM(ctx, utm, from, to)
+}
+
+func (r *bulkheadAdRepository) GetByCampaign(ctx context.Context, campaignID string, from, to time.Time) ([]domain.ProcessedAdData, error) {
+	release, err := r.bulkhead.read(ctx, "ads.GetByCampaign")
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return r.next.GetByCampaign(ctx, campaignID, from, to)
+}
+
+func (r *bulkheadAdRepository) GetByChannel(ctx context.Context, channel string, from, to time.Time) ([]domain.ProcessedAdData, error) {
+	release, err := r.bulkhead.read(ctx, "ads.GetByChannel")
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return r.next.GetByChannel(ctx, channel, from, to)
+}
+
+type bulkheadCRMRepository struct {
+	next     domain.CRMRepository
+	bulkhead *StorageBulkhead
+}
+
+func (r *bulkheadCRMRepository) Store(ctx context.Context, opportunities []domain.ProcessedOpportunity) error {
+	release, err := r.bulkhead.write(ctx, "crm.Store")
+	if err != nil {
+		return err
+	}
+	defer release()
+	return r.next.Store(ctx, opportunities)
+}
+
+func (r *bulkheadCRMRepository) Delete(ctx context.Context, ids []string) error {
+	release, err := r.bulkhead.write(ctx, "crm.Delete")
+	if err != nil {
+		return err
+	}
+	defer release()
+	return r.next.Delete(ctx, ids)
+}
+
+func (r *bulkheadCRMRepository) GetByIDs(ctx context.Context, ids []string) ([]domain.ProcessedOpportunity, error) {
+	release, err := r.bulkhead.read(ctx, "crm.GetByIDs")
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return r.next.GetByIDs(ctx, ids)
+}
+
+func (r *bulkheadCRMRepository) GetByDateRange(ctx context.Context, from, to time.Time) ([]domain.ProcessedOpportunity, error) {
+	release, err := r.bulkhead.read(ctx, "crm.GetByDateRange")
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return r.next.GetByDateRange(ctx, from, to)
+}
+
+func (r *bulkheadCRMRepository) GetByUTM(ctx context.Context, utm domain.UTMKey, from, to time.Time) ([]domain.ProcessedOpportunity, error) {
+	release, err := r.bulkhead.read(ctx, "crm.GetByUTM")
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return r.next.GetByUTM(ctx, utm, from, to)
+}
+
+func (r *bulkheadCRMRepository) GetByStage(ctx context.Context, stage domain.OpportunityStage, from, to time.Time) ([]domain.ProcessedOpportunity, error) {
+	release, err := r.bulkhead.read(ctx, "crm.GetByStage")
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return r.next.GetByStage(ctx, stage, from, to)
+}
+
+type bulkheadMetricsRepository struct {
+	next     domain.MetricsRepository
+	bulkhead *StorageBulkhead
+}
+
+func (r *bulkheadMetricsRepository) Store(ctx context.Context, metrics []domain.BusinessMetrics) error {
+	release, err := r.bulkhead.write(ctx, "metrics.Store")
+	if err != nil {
+		return err
+	}
+	defer release()
+	return r.next.Store(ctx, metrics)
+}
+
+func (r *bulkheadMetricsRepository) Upsert(ctx context.Context, metrics []domain.BusinessMetrics) error {
+	release, err := r.bulkhead.write(ctx, "metrics.Upsert")
+	if err != nil {
+		return err
+	}
+	defer release()
+	return r.next.Upsert(ctx, metrics)
+}
+
+func (r *bulkheadMetricsRepository) GetByFilter(ctx context.Context, filter domain.MetricsFilter) (*domain.MetricsResponse, error) {
+	release, err := r.bulkhead.read(ctx, "metrics.GetByFilter")
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return r.next.GetByFilter(ctx, filter)
+}
+
+func (r *bulkheadMetricsRepository) GetByDate(ctx context.Context, date time.Time) ([]domain.BusinessMetrics, error) {
+	release, err := r.bulkhead.read(ctx, "metrics.GetByDate")
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return r.next.GetByDate(ctx, date)
+}
+
+func (r *bulkheadMetricsRepository) GetDistinctValues(ctx context.Context, dimension string, from, to time.Time) ([]domain.DimensionValue, error) {
+	release, err := r.bulkhead.read(ctx, "metrics.GetDistinctValues")
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return r.next.GetDistinctValues(ctx, dimension, from, to)
+}
+
+func (r *bulkheadMetricsRepository) Count(ctx context.Context, from, to time.Time) (int64, error) {
+	release, err := r.bulkhead.read(ctx, "metrics.Count")
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+	return r.next.Count(ctx, from, to)
+}
+
+// Replace reads back the replaced metrics but is a write
+func (r *bulkheadMetricsRepository) Replace(ctx context.Context, from, to time.Time, metrics []domain.BusinessMetrics) ([]domain.BusinessMetrics, error) {
+	release, err := r.bulkhead.write(ctx, "metrics.Replace")
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return r.next.Replace(ctx, from, to, metrics)
+}
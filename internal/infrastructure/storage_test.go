@@ -0,0 +1,39 @@
+package infrastructure
+
+import (
+	"path/filepath"
+	"testing"
+
+	"etlgo/internal/domain"
+	"etlgo/internal/storagetest"
+	"etlgo/pkg/logger"
+)
+
+func TestMemoryStorageConformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) *domain.Storage {
+		return openTestStorage(t, StorageBackendMemory, StorageOptions{})
+	})
+}
+
+func TestSQLiteStorageConformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) *domain.Storage {
+		return openTestStorage(t, StorageBackendSQLite, StorageOptions{DSN: filepath.Join(t.TempDir(), "etl.db")})
+	})
+}
+
+// opens an empty, migrated storage closed when the test ends
+func openTestStorage(t *testing.T, backend string, opts StorageOptions) *domain.Storage {
+	t.Helper()
+	storage, err := OpenStorage(backend, opts, logger.New("error"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { storage.Close() })
+
+	if storage.Schema != nil {
+		if _, err := storage.Schema.Migrate(t.Context()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return storage
+}
@@ -0,0 +1,113 @@
+package infrastructure
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"sync"
+	"time"
+
+	"etlgo/pkg/logger"
+	"etlgo/pkg/metrics"
+)
+
+// connection settings of the clients talking to upstream APIs
+type TransportOptions struct {
+	HTTP2               bool          // negotiate HTTP/2 over TLS
+	KeepAlive           time.Duration // TCP keep-alive probe interval, negative disables probes
+	IdleConnTimeout     time.Duration // how long idle connections are kept for reuse, 0 disables reuse
+	MaxIdleConnsPerHost int
+	Prewarm             bool           // open connections before extraction
+	HedgeDelay          time.Duration  // send a second ads or CRM GET when the first hasn't answered after it, 0 disables hedging
+	Faults              *FaultInjector // injects faults into requests when set
+}
+
+// returns a transport with the options
+func NewUpstreamTransport(opts TransportOptions) http.RoundTripper {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: opts.KeepAlive}
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     opts.HTTP2,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   opts.MaxIdleConnsPerHost,
+		IdleConnTimeout:       opts.IdleConnTimeout,
+		DisableKeepAlives:     opts.IdleConnTimeout == 0,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+	if !opts.HTTP2 {
+		// A non-nil empty map turns HTTP/2 off
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+	return opts.Faults.WrapTransport(transport)
+}
+
+// returns the request with a trace recording whether it reused a pooled
+// connection and how long setting up a new one took, by phase
+func traceUpstream(req *http.Request, api string, metrics *metrics.Metrics) *http.Request {
+	var dnsStart, connectStart, tlsStart time.Time
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { dnsStart = time.Now() },
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			metrics.RecordUpstreamConnectionSetup(api, "dns", time.Since(dnsStart))
+		},
+		ConnectStart: func(string, string) { connectStart = time.Now() },
+		ConnectDone: func(_, _ string, err error) {
+			if err == nil {
+				metrics.RecordUpstreamConnectionSetup(api, "connect", time.Since(connectStart))
+			}
+		},
+		TLSHandshakeStart: func() { tlsStart = time.Now() },
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			if err == nil {
+				metrics.RecordUpstreamConnectionSetup(api, "tls", time.Since(tlsStart))
+			}
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			metrics.RecordUpstreamConnection(api, info.Reused)
+		},
+	}
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+}
+
+// opens a connection to the origin of every URL, concurrently, so the
+// requests that follow reuse them instead of each paying the DNS, TCP and
+// TLS setup. The responses don't matter; failures are only logged since
+// the real requests report them.
+func prewarmUpstreams(ctx context.Context, client *http.Client, urls map[string]string, logger *logger.Logger, metrics *metrics.Metrics) {
+	origins := make(map[string]string) // origin -> api
+	for api, rawURL := range urls {
+		parsed, err := url.Parse(rawURL)
+		if err != nil || parsed.Host == "" {
+			continue
+		}
+		origins[parsed.Scheme+"://"+parsed.Host+"/"] = api
+	}
+
+	var wg sync.WaitGroup
+	for origin, api := range origins {
+		wg.Go(func() {
+			start := time.Now()
+			req, err := http.NewRequestWithContext(ctx, http.MethodHead, origin, nil)
+			if err != nil {
+				return
+			}
+			resp, err := client.Do(traceUpstream(req, api, metrics))
+			if err != nil {
+				metrics.RecordUpstreamPrewarm(api, "failed", time.Since(start))
+				logger.WithContext(ctx).WithError(err).WithField("origin", origin).Warn("Failed to prewarm upstream connection")
+				return
+			}
+			// Drain the body so the connection returns to the pool
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			metrics.RecordUpstreamPrewarm(api, "success", time.Since(start))
+		})
+	}
+	wg.Wait()
+}
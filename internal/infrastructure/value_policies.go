@@ -0,0 +1,31 @@
+package infrastructure
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"etlgo/internal/domain"
+)
+
+// loads value policies from a JSON file keyed by "<source>.<field>". An
+// empty path disables value policies.
+func LoadValuePolicies(path string) (domain.ValuePolicies, error) {
+	policies := domain.ValuePolicies{}
+	if path == "" {
+		return policies, nil
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read value policy file: %w", err)
+	}
+	if err := json.Unmarshal(raw, &policies); err != nil {
+		return nil, fmt.Errorf("failed to parse value policy file: %w", err)
+	}
+	if err := policies.Validate(); err != nil {
+		return nil, err
+	}
+
+	return policies, nil
+}
@@ -0,0 +1,582 @@
+// Package storagetest holds the conformance suite every storage backend
+// must pass: ordering, filtering, pagination and idempotent writes of the
+// domain repositories. Backend tests call Run with a factory of empty
+// storages; etlctl check-storage calls Check against a scratch database.
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"etlgo/internal/domain"
+	"etlgo/pkg/logger"
+)
+
+// the days the conformance checks write to, far enough in the past not to
+// meet real data. Check i owns the conformanceDays days from
+// conformanceEpoch + i*conformanceDays.
+var conformanceEpoch = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+const conformanceDays = 10
+
+// a single behavior every backend shares. day is the first of the days the
+// check owns.
+type conformanceCheck struct {
+	name        string
+	dataset     string
+	description string
+	run         func(ctx context.Context, s *domain.Storage, day time.Time) error
+}
+
+var conformanceChecks = []conformanceCheck{
+	{
+		name:        "ads_store_idempotent",
+		dataset:     domain.DatasetAds,
+		description: "storing ads with the same natural key again replaces them",
+		run: func(ctx context.Context, s *domain.Storage, day time.Time) error {
+			ad := conformanceAd(day, "google_ads", "C-1", "summer")
+			if err := s.Ads.Store(ctx, []domain.ProcessedAdData{ad, ad}); err != nil {
+				return err
+			}
+			ad.Clicks = 99
+			if err := s.Ads.Store(ctx, []domain.ProcessedAdData{ad}); err != nil {
+				return err
+			}
+
+			ads, err := s.Ads.GetByDateRange(ctx, day, day)
+			if err != nil {
+				return err
+			}
+			if len(ads) != 1 {
+				return fmt.Errorf("expected 1 ad, got %d", len(ads))
+			}
+			if ads[0].Clicks != 99 {
+				return fmt.Errorf("expected the last stored version with 99 clicks, got %d", ads[0].Clicks)
+			}
+			return nil
+		},
+	},
+	{
+		name:        "ads_date_range",
+		dataset:     domain.DatasetAds,
+		description: "date ranges include both ends and come back ordered by date",
+		run: func(ctx context.Context, s *domain.Storage, day time.Time) error {
+			ads := []domain.ProcessedAdData{
+				conformanceAd(day.AddDate(0, 0, 3), "google_ads", "C-1", "summer"),
+				conformanceAd(day.AddDate(0, 0, 1), "google_ads", "C-1", "summer"),
+				conformanceAd(day, "google_ads", "C-1", "summer"),
+				conformanceAd(day.AddDate(0, 0, 2), "google_ads", "C-1", "summer"),
+			}
+			if err := s.Ads.Store(ctx, ads); err != nil {
+				return err
+			}
+
+			got, err := s.Ads.GetByDateRange(ctx, day.AddDate(0, 0, 1), day.AddDate(0, 0, 2))
+			if err != nil {
+				return err
+			}
+			return checkDates(got, func(ad domain.ProcessedAdData) time.Time { return ad.Date },
+				day.AddDate(0, 0, 1), day.AddDate(0, 0, 2))
+		},
+	},
+	{
+		name:        "ads_filters",
+		dataset:     domain.DatasetAds,
+		description: "channel, campaign and UTM reads only return matching ads",
+		run: func(ctx context.Context, s *domain.Storage, day time.Time) error {
+			ads := []domain.ProcessedAdData{
+				conformanceAd(day, "google_ads", "C-1", "summer"),
+				conformanceAd(day, "google_ads", "C-2", "winter"),
+				conformanceAd(day, "facebook_ads", "C-3", "summer"),
+			}
+			if err := s.Ads.Store(ctx, ads); err != nil {
+				return err
+			}
+
+			byChannel, err := s.Ads.GetByChannel(ctx, "google_ads", day, day)
+			if err != nil {
+				return err
+			}
+			if err := checkCount("channel google_ads", len(byChannel), 2); err != nil {
+				return err
+			}
+			byCampaign, err := s.Ads.GetByCampaign(ctx, "C-3", day, day)
+			if err != nil {
+				return err
+			}
+			if err := checkCount("campaign C-3", len(byCampaign), 1); err != nil {
+				return err
+			}
+			byUTM, err := s.Ads.GetByUTM(ctx, domain.UTMKey{Campaign: "summer", Source: "conformance", Medium: "cpc"}, day, day)
+			if err != nil {
+				return err
+			}
+			return checkCount("utm summer", len(byUTM), 2)
+		},
+	},
+	{
+		name:        "ads_replace",
+		dataset:     domain.DatasetAds,
+		description: "replacing the ads of a range drops every ad stored within it and keeps the others",
+		run: func(ctx context.Context, s *domain.Storage, day time.Time) error {
+			ads := []domain.ProcessedAdData{
+				conformanceAd(day, "google_ads", "C-1", "summer"),
+				conformanceAd(day.AddDate(0, 0, 1), "google_ads", "C-1", "summer"),
+				conformanceAd(day.AddDate(0, 0, 2), "google_ads", "C-1", "summer"),
+			}
+			if err := s.Ads.Store(ctx, ads); err != nil {
+				return err
+			}
+			replacement := conformanceAd(day.AddDate(0, 0, 1), "facebook_ads", "C-9", "winter")
+			if err := s.Ads.Replace(ctx, day.AddDate(0, 0, 1), day.AddDate(0, 0, 1), []domain.ProcessedAdData{replacement}); err != nil {
+				return err
+			}
+
+			got, err := s.Ads.GetByDateRange(ctx, day, day.AddDate(0, 0, conformanceDays-1))
+			if err != nil {
+				return err
+			}
+			if err := checkCount("ads", len(got), 3); err != nil {
+				return err
+			}
+			if got[1].Channel != "facebook_ads" {
+				return fmt.Errorf("expected the replacement ad, got %s", got[1].Channel)
+			}
+			if got[2].Channel != "google_ads" {
+				return fmt.Errorf("expected the ad after the range to be kept, got %s", got[2].Channel)
+			}
+			return nil
+		},
+	},
+	{
+		name:        "crm_store_by_id",
+		dataset:     domain.DatasetCRM,
+		description: "storing an opportunity replaces the one with its ID, even when it moved to another day",
+		run: func(ctx context.Context, s *domain.Storage, day time.Time) error {
+			opp := conformanceOpportunity("conformance-1", day, domain.StageLead)
+			if err := s.CRM.Store(ctx, []domain.ProcessedOpportunity{opp}); err != nil {
+				return err
+			}
+			moved := conformanceOpportunity("conformance-1", day.AddDate(0, 0, 1), domain.StageClosedWon)
+			if err := s.CRM.Store(ctx, []domain.ProcessedOpportunity{moved}); err != nil {
+				return err
+			}
+
+			got, err := s.CRM.GetByDateRange(ctx, day, day.AddDate(0, 0, 1))
+			if err != nil {
+				return err
+			}
+			if err := checkCount("opportunities", len(got), 1); err != nil {
+				return err
+			}
+			if got[0].Stage != domain.StageClosedWon || !got[0].CreatedAt.Equal(moved.CreatedAt) {
+				return fmt.Errorf("expected the last stored version, got stage %s created %s", got[0].Stage, got[0].CreatedAt.Format(time.RFC3339))
+			}
+			return nil
+		},
+	},
+	{
+		name:        "crm_ids_and_delete",
+		dataset:     domain.DatasetCRM,
+		description: "reads by ID skip unknown IDs and deleted opportunities",
+		run: func(ctx context.Context, s *domain.Storage, day time.Time) error {
+			opportunities := []domain.ProcessedOpportunity{
+				conformanceOpportunity("conformance-2", day, domain.StageLead),
+				conformanceOpportunity("conformance-3", day, domain.StageOpportunity),
+			}
+			if err := s.CRM.Store(ctx, opportunities); err != nil {
+				return err
+			}
+			if err := s.CRM.Delete(ctx, []string{"conformance-2"}); err != nil {
+				return err
+			}
+
+			got, err := s.CRM.GetByIDs(ctx, []string{"conformance-2", "conformance-3", "conformance-unknown"})
+			if err != nil {
+				return err
+			}
+			if err := checkCount("opportunities", len(got), 1); err != nil {
+				return err
+			}
+			if got[0].OpportunityID != "conformance-3" {
+				return fmt.Errorf("expected conformance-3, got %s", got[0].OpportunityID)
+			}
+			return nil
+		},
+	},
+	{
+		name:        "crm_filters",
+		dataset:     domain.DatasetCRM,
+		description: "date range, stage and UTM reads only return matching opportunities, ordered by creation",
+		run: func(ctx context.Context, s *domain.Storage, day time.Time) error {
+			opportunities := []domain.ProcessedOpportunity{
+				conformanceOpportunity("conformance-4", day.AddDate(0, 0, 2), domain.StageLead),
+				conformanceOpportunity("conformance-5", day, domain.StageClosedWon),
+				conformanceOpportunity("conformance-6", day.AddDate(0, 0, 1), domain.StageLead),
+			}
+			opportunities[2].UTMCampaign = "winter"
+			if err := s.CRM.Store(ctx, opportunities); err != nil {
+				return err
+			}
+
+			got, err := s.CRM.GetByDateRange(ctx, day, day.AddDate(0, 0, 2))
+			if err != nil {
+				return err
+			}
+			if err := checkDates(got, func(opp domain.ProcessedOpportunity) time.Time { return opp.CreatedAt },
+				day, day.AddDate(0, 0, 2)); err != nil {
+				return err
+			}
+			byStage, err := s.CRM.GetByStage(ctx, domain.StageLead, day, day.AddDate(0, 0, 2))
+			if err != nil {
+				return err
+			}
+			if err := checkCount("stage lead", len(byStage), 2); err != nil {
+				return err
+			}
+			byUTM, err := s.CRM.GetByUTM(ctx, domain.UTMKey{Campaign: "winter", Source: "conformance", Medium: "cpc"}, day, day.AddDate(0, 0, 2))
+			if err != nil {
+				return err
+			}
+			return checkCount("utm winter", len(byUTM), 1)
+		},
+	},
+	{
+		name:        "metrics_upsert_idempotent",
+		dataset:     domain.DatasetMetrics,
+		description: "storing or upserting metrics with the same date and UTM replaces them",
+		run: func(ctx context.Context, s *domain.Storage, day time.Time) error {
+			metric := conformanceMetric(day, "google_ads", "summer")
+			if err := s.Metrics.Store(ctx, []domain.BusinessMetrics{metric, metric}); err != nil {
+				return err
+			}
+			metric.Clicks = 99
+			if err := s.Metrics.Upsert(ctx, []domain.BusinessMetrics{metric}); err != nil {
+				return err
+			}
+
+			got, err := s.Metrics.GetByDate(ctx, day)
+			if err != nil {
+				return err
+			}
+			if err := checkCount("metrics", len(got), 1); err != nil {
+				return err
+			}
+			if got[0].Clicks != 99 {
+				return fmt.Errorf("expected the last stored version with 99 clicks, got %d", got[0].Clicks)
+			}
+			return nil
+		},
+	},
+	{
+		name:        "metrics_filter",
+		dataset:     domain.DatasetMetrics,
+		description: "filters match dimensions exactly and the total counts every matching row",
+		run: func(ctx context.Context, s *domain.Storage, day time.Time) error {
+			metrics := []domain.BusinessMetrics{
+				conformanceMetric(day, "google_ads", "summer"),
+				conformanceMetric(day, "facebook_ads", "winter"),
+				conformanceMetric(day.AddDate(0, 0, 1), "google_ads", "summer"),
+				conformanceMetric(day.AddDate(0, 0, 2), "google_ads", "autumn"),
+			}
+			if err := s.Metrics.Store(ctx, metrics); err != nil {
+				return err
+			}
+
+			to := day.AddDate(0, 0, 1)
+			response, err := s.Metrics.GetByFilter(ctx, domain.MetricsFilter{From: &day, To: &to, Channel: "google_ads"})
+			if err != nil {
+				return err
+			}
+			if err := checkCount("total", response.Total, 2); err != nil {
+				return err
+			}
+			if err := checkCount("rows", len(response.Data), 2); err != nil {
+				return err
+			}
+			for _, metric := range response.Data {
+				if metric.Channel != "google_ads" {
+					return fmt.Errorf("unexpected channel %s", metric.Channel)
+				}
+			}
+
+			response, err = s.Metrics.GetByFilter(ctx, domain.MetricsFilter{From: &day, To: &to, UTMCampaign: "summe"})
+			if err != nil {
+				return err
+			}
+			return checkCount("prefix utm_campaign", response.Total, 0)
+		},
+	},
+	{
+		name:        "metrics_pagination",
+		dataset:     domain.DatasetMetrics,
+		description: "limit and offset pages return every row exactly once, ordered by date",
+		run: func(ctx context.Context, s *domain.Storage, day time.Time) error {
+			var metrics []domain.BusinessMetrics
+			for i := 4; i >= 0; i-- {
+				for _, campaign := range []string{"summer", "winter"} {
+					metrics = append(metrics, conformanceMetric(day.AddDate(0, 0, i), "google_ads", campaign))
+				}
+			}
+			if err := s.Metrics.Store(ctx, metrics); err != nil {
+				return err
+			}
+
+			to := day.AddDate(0, 0, 4)
+			var pages []domain.BusinessMetrics
+			for offset := 0; ; offset += 3 {
+				response, err := s.Metrics.GetByFilter(ctx, domain.MetricsFilter{From: &day, To: &to, Limit: 3, Offset: offset})
+				if err != nil {
+					return err
+				}
+				if response.Total != len(metrics) {
+					return fmt.Errorf("expected total %d at offset %d, got %d", len(metrics), offset, response.Total)
+				}
+				if response.HasMore != (offset+3 < len(metrics)) {
+					return fmt.Errorf("unexpected has_more %t at offset %d", response.HasMore, offset)
+				}
+				pages = append(pages, response.Data...)
+				if !response.HasMore {
+					break
+				}
+			}
+
+			seen := make(map[string]bool, len(pages))
+			for _, metric := range pages {
+				key := metric.Date.Format("2006-01-02") + "|" + metric.UTMCampaign
+				if seen[key] {
+					return fmt.Errorf("row %s returned on two pages", key)
+				}
+				seen[key] = true
+			}
+			return checkDates(pages, func(metric domain.BusinessMetrics) time.Time { return metric.Date }, day, to)
+		},
+	},
+	{
+		name:        "metrics_count_and_distinct",
+		dataset:     domain.DatasetMetrics,
+		description: "counts and distinct dimension values cover the range, most frequent value first",
+		run: func(ctx context.Context, s *domain.Storage, day time.Time) error {
+			metrics := []domain.BusinessMetrics{
+				conformanceMetric(day, "google_ads", "summer"),
+				conformanceMetric(day, "facebook_ads", "winter"),
+				conformanceMetric(day.AddDate(0, 0, 1), "facebook_ads", "summer"),
+				conformanceMetric(day.AddDate(0, 0, 2), "google_ads", "summer"),
+			}
+			if err := s.Metrics.Store(ctx, metrics); err != nil {
+				return err
+			}
+
+			to := day.AddDate(0, 0, 1)
+			count, err := s.Metrics.Count(ctx, day, to)
+			if err != nil {
+				return err
+			}
+			if err := checkCount("count", int(count), 3); err != nil {
+				return err
+			}
+			values, err := s.Metrics.GetDistinctValues(ctx, domain.DimensionChannel, day, to)
+			if err != nil {
+				return err
+			}
+			expected := []domain.DimensionValue{{Value: "facebook_ads", Count: 2}, {Value: "google_ads", Count: 1}}
+			if !slices.Equal(values, expected) {
+				return fmt.Errorf("expected %v, got %v", expected, values)
+			}
+			return nil
+		},
+	},
+	{
+		name:        "metrics_replace",
+		dataset:     domain.DatasetMetrics,
+		description: "replacing a range returns the replaced metrics and keeps the days outside it",
+		run: func(ctx context.Context, s *domain.Storage, day time.Time) error {
+			metrics := []domain.BusinessMetrics{
+				conformanceMetric(day, "google_ads", "summer"),
+				conformanceMetric(day.AddDate(0, 0, 1), "google_ads", "summer"),
+				conformanceMetric(day.AddDate(0, 0, 1), "google_ads", "winter"),
+				conformanceMetric(day.AddDate(0, 0, 2), "google_ads", "summer"),
+			}
+			if err := s.Metrics.Store(ctx, metrics); err != nil {
+				return err
+			}
+			replacement := conformanceMetric(day.AddDate(0, 0, 1), "facebook_ads", "autumn")
+			replaced, err := s.Metrics.Replace(ctx, day.AddDate(0, 0, 1), day.AddDate(0, 0, 1), []domain.BusinessMetrics{replacement})
+			if err != nil {
+				return err
+			}
+			if err := checkCount("replaced", len(replaced), 2); err != nil {
+				return err
+			}
+
+			count, err := s.Metrics.Count(ctx, day, day.AddDate(0, 0, 2))
+			if err != nil {
+				return err
+			}
+			if err := checkCount("count", int(count), 3); err != nil {
+				return err
+			}
+			got, err := s.Metrics.GetByDate(ctx, day.AddDate(0, 0, 1))
+			if err != nil {
+				return err
+			}
+			if len(got) != 1 || got[0].UTMCampaign != "autumn" {
+				return fmt.Errorf("expected only the replacement on the replaced day, got %d metrics", len(got))
+			}
+			return nil
+		},
+	},
+}
+
+// Run runs every check as a subtest of t on a storage of its own, created
+// by open. The storages must be empty and migrated.
+func Run(t *testing.T, open func(t *testing.T) *domain.Storage) {
+	t.Helper()
+	for i, check := range conformanceChecks {
+		t.Run(check.name, func(t *testing.T) {
+			storage := open(t)
+			if err := check.run(t.Context(), storage, checkDay(i)); err != nil {
+				t.Fatalf("%s: %v", check.description, err)
+			}
+		})
+	}
+}
+
+// Check runs every check on the days it owns in one storage and returns
+// their results in order. The checks write records, so run it against a
+// scratch database; it fails without running any when those days already
+// hold data, since the checks expect to find only what they stored.
+func Check(ctx context.Context, storage *domain.Storage, logger *logger.Logger) ([]domain.ConformanceResult, error) {
+	if err := checkEmpty(ctx, storage); err != nil {
+		return nil, err
+	}
+
+	results := make([]domain.ConformanceResult, 0, len(conformanceChecks))
+	for i, check := range conformanceChecks {
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+
+		result := domain.ConformanceResult{Check: check.name, Dataset: check.dataset, Description: check.description, Passed: true}
+		if err := check.run(ctx, storage, checkDay(i)); err != nil {
+			result.Passed = false
+			result.Error = err.Error()
+		}
+
+		logger.WithContext(ctx).WithFields(map[string]any{
+			"backend": storage.Backend,
+			"check":   check.name,
+			"passed":  result.Passed,
+		}).Info("Storage conformance check completed")
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// returns the first of the days check i owns
+func checkDay(i int) time.Time {
+	return conformanceEpoch.AddDate(0, 0, i*conformanceDays)
+}
+
+// returns an error if any dataset holds records on the days of the checks
+func checkEmpty(ctx context.Context, storage *domain.Storage) error {
+	from := conformanceEpoch
+	to := conformanceEpoch.AddDate(0, 0, len(conformanceChecks)*conformanceDays-1)
+
+	ads, err := storage.Ads.GetByDateRange(ctx, from, to)
+	if err != nil {
+		return fmt.Errorf("failed to read ads: %w", err)
+	}
+	opportunities, err := storage.CRM.GetByDateRange(ctx, from, to)
+	if err != nil {
+		return fmt.Errorf("failed to read CRM data: %w", err)
+	}
+	metrics, err := storage.Metrics.Count(ctx, from, to)
+	if err != nil {
+		return fmt.Errorf("failed to count metrics: %w", err)
+	}
+	if len(ads) > 0 || len(opportunities) > 0 || metrics > 0 {
+		return domain.Errorf(domain.ErrConflict, "storage already holds data between %s and %s, run the conformance checks against an empty database",
+			from.Format("2006-01-02"), to.Format("2006-01-02"))
+	}
+	return nil
+}
+
+func conformanceAd(day time.Time, channel, campaignID, utmCampaign string) domain.ProcessedAdData {
+	return domain.ProcessedAdData{
+		Date:        day,
+		CampaignID:  campaignID,
+		Channel:     channel,
+		Clicks:      10,
+		Impressions: 100,
+		Cost:        domain.MoneyFromFloat(12.5),
+		UTMCampaign: utmCampaign,
+		UTMSource:   "conformance",
+		UTMMedium:   "cpc",
+		ProcessedAt: day,
+	}
+}
+
+func conformanceOpportunity(id string, day time.Time, stage domain.OpportunityStage) domain.ProcessedOpportunity {
+	return domain.ProcessedOpportunity{
+		OpportunityID: id,
+		ContactEmail:  id + "@example.com",
+		Stage:         stage,
+		Amount:        domain.MoneyFromFloat(1000),
+		CreatedAt:     day.Add(9 * time.Hour),
+		UTMCampaign:   "summer",
+		UTMSource:     "conformance",
+		UTMMedium:     "cpc",
+		ProcessedAt:   day,
+	}
+}
+
+func conformanceMetric(day time.Time, channel, utmCampaign string) domain.BusinessMetrics {
+	return domain.BusinessMetrics{
+		Date:        day,
+		Channel:     channel,
+		CampaignID:  "C-1",
+		UTMCampaign: utmCampaign,
+		UTMSource:   "conformance",
+		UTMMedium:   "cpc",
+		Clicks:      10,
+		Impressions: 100,
+		Cost:        domain.MoneyFromFloat(12.5),
+	}
+}
+
+// returns an error if the count is not the expected one
+func checkCount(what string, got, expected int) error {
+	if got != expected {
+		return fmt.Errorf("expected %d %s, got %d", expected, what, got)
+	}
+	return nil
+}
+
+// returns an error if the records are not ordered by date, or are dated
+// outside from and to, or do not cover both ends
+func checkDates[T any](records []T, date func(T) time.Time, from, to time.Time) error {
+	if len(records) == 0 {
+		return errors.New("expected records, got none")
+	}
+	for i, record := range records {
+		day := date(record).UTC().Truncate(24 * time.Hour)
+		if day.Before(from) || day.After(to) {
+			return fmt.Errorf("record dated %s is outside %s to %s", day.Format("2006-01-02"), from.Format("2006-01-02"), to.Format("2006-01-02"))
+		}
+		if i > 0 && date(record).Before(date(records[i-1])) {
+			return fmt.Errorf("record %d is dated before record %d", i+1, i)
+		}
+	}
+	if first := date(records[0]).UTC().Truncate(24 * time.Hour); !first.Equal(from) {
+		return fmt.Errorf("expected the first record on %s, got %s", from.Format("2006-01-02"), first.Format("2006-01-02"))
+	}
+	if last := date(records[len(records)-1]).UTC().Truncate(24 * time.Hour); !last.Equal(to) {
+		return fmt.Errorf("expected the last record on %s, got %s", to.Format("2006-01-02"), last.Format("2006-01-02"))
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"etlgo/internal/domain"
+	"etlgo/pkg/logger"
+	"etlgo/pkg/metrics"
+)
+
+// ApprovalService holds destructive and large operations until a second
+// operator approves them, then executes them on the job queue
+type ApprovalService struct {
+	repo            domain.ApprovalRepository
+	etlService      *ETLService
+	pipelineService *PipelineService
+	jobQueue        *JobQueue
+	notifier        *NotificationService
+	required        []string
+	clock           domain.Clock
+	ids             domain.IDGenerator
+	logger          *logger.Logger
+	metrics         *metrics.Metrics
+}
+
+// NewApprovalService creates a new approval service. Only the actions in
+// required need approval; the others run right away.
+func NewApprovalService(
+	repo domain.ApprovalRepository,
+	etlService *ETLService,
+	pipelineService *PipelineService,
+	jobQueue *JobQueue,
+	notifier *NotificationService,
+	required []string,
+	clock domain.Clock,
+	ids domain.IDGenerator,
+	logger *logger.Logger,
+	metrics *metrics.Metrics,
+) *ApprovalService {
+	return &ApprovalService{
+		repo:            repo,
+		etlService:      etlService,
+		pipelineService: pipelineService,
+		jobQueue:        jobQueue,
+		notifier:        notifier,
+		required:        required,
+		clock:           clock,
+		ids:             ids,
+		logger:          logger,
+		metrics:         metrics,
+	}
+}
+
+// Required returns true if the action needs a second operator's approval
+func (s *ApprovalService) Required(action string) bool {
+	return slices.Contains(s.required, action)
+}
+
+// Submit queues the request for approval and notifies the channels
+// subscribed to pending approvals
+func (s *ApprovalService) Submit(ctx context.Context, request domain.ApprovalRequest, actor string) (*domain.ApprovalRequest, error) {
+	// Don't ask for approval of rollbacks that have nothing to roll back
+	if request.Action == domain.ActionRollback {
+		events, err := s.etlService.ListEvents(ctx, domain.EventFilter{IngestRunID: request.IngestRunID, Limit: 1})
+		if err != nil {
+			return nil, err
+		}
+		if request.IngestRunID == "" || len(events) == 0 {
+			return nil, domain.Errorf(domain.ErrNotFound, "no events recorded for ingest run %s", request.IngestRunID)
+		}
+	}
+
+	request.ID = s.ids.NewID()
+	request.RequestedBy = actor
+	request.RequestedAt = s.clock.Now().UTC()
+	request.Status = domain.ApprovalPending
+
+	if err := s.repo.Create(ctx, request); err != nil {
+		return nil, err
+	}
+	s.notifier.NotifyApproval(ctx, request)
+	return &request, nil
+}
+
+// List returns approval requests, most recent first
+func (s *ApprovalService) List(ctx context.Context, filter domain.ApprovalFilter) ([]domain.ApprovalRequest, error) {
+	return s.repo.List(ctx, filter)
+}
+
+// Get returns an approval request
+func (s *ApprovalService) Get(ctx context.Context, id string) (*domain.ApprovalRequest, error) {
+	return s.repo.Get(ctx, id)
+}
+
+// Approve approves a pending request and executes it. The requester can't
+// approve their own request. If the job queue doesn't admit the operation
+// the request stays pending so it can be approved again; otherwise it ends
+// up executed or failed and the operation's error is returned.
+func (s *ApprovalService) Approve(ctx context.Context, id, actor string) (*domain.ApprovalRequest, error) {
+	request, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if request.RequestedBy == actor {
+		return nil, domain.ErrApprovalSelf
+	}
+
+	request, err = s.repo.Decide(ctx, id, domain.ApprovalApproved, actor, "", s.clock.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+
+	log := s.logger.WithContext(ctx).WithFields(map[string]any{
+		"approval_id": request.ID,
+		"action":      request.Action,
+		"approved_by": actor,
+	})
+	log.Info("Approval request approved")
+
+	result, err := s.execute(ctx, *request)
+	if errors.Is(err, domain.ErrMaintenance) || errors.Is(err, domain.ErrJobQueueTimeout) || errors.Is(err, domain.ErrQuotaExceeded) {
+		request.Status = domain.ApprovalPending
+		request.DecidedBy = ""
+		request.DecidedAt = nil
+		if updateErr := s.repo.Update(ctx, *request); updateErr != nil {
+			log.WithError(updateErr).Error("Failed to reopen approval request")
+		}
+		return nil, err
+	}
+
+	request.Result = result
+	request.Status = domain.ApprovalExecuted
+	if err != nil {
+		request.Status = domain.ApprovalFailed
+		request.Error = err.Error()
+		log.WithError(err).Warn("Approved operation failed")
+	}
+	if updateErr := s.repo.Update(ctx, *request); updateErr != nil {
+		log.WithError(updateErr).Error("Failed to record approved operation outcome")
+	}
+	return request, err
+}
+
+// Reject rejects a pending request. Requesters may reject, i.e. withdraw,
+// their own requests.
+func (s *ApprovalService) Reject(ctx context.Context, id, actor, reason string) (*domain.ApprovalRequest, error) {
+	request, err := s.repo.Decide(ctx, id, domain.ApprovalRejected, actor, reason, s.clock.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.WithContext(ctx).WithFields(map[string]any{
+		"approval_id": request.ID,
+		"action":      request.Action,
+		"rejected_by": actor,
+	}).Info("Approval request rejected")
+	return request, nil
+}
+
+// runs the approved operation on the job queue and returns its result
+func (s *ApprovalService) execute(ctx context.Context, request domain.ApprovalRequest) (any, error) {
+	var result any
+	var err error
+	switch request.Action {
+	case domain.ActionRollback:
+		err = s.jobQueue.Run(ctx, domain.JobTypeIngest, domain.PriorityNormal, func(ctx context.Context) error {
+			var err error
+			result, err = s.etlService.RollbackIngest(ctx, request.IngestRunID)
+			return err
+		})
+	case domain.ActionBackfill:
+		opts := domain.RunOptions{Since: request.Since, Tags: request.Tags}
+		if request.ParsePolicy != nil {
+			opts.Parsing = map[string]domain.ParsePolicy{
+				domain.SourceAds: *request.ParsePolicy,
+				domain.SourceCRM: *request.ParsePolicy,
+			}
+		}
+		err = s.jobQueue.Run(ctx, domain.JobTypeIngest, domain.PriorityLow, func(ctx context.Context) error {
+			var err error
+			if request.Pipeline != "" {
+				_, result, err = s.pipelineService.RunPipeline(ctx, request.Pipeline, request.Tags)
+				return err
+			}
+			result, err = s.etlService.RunETLWithOptions(ctx, opts)
+			return err
+		})
+	default:
+		err = fmt.Errorf("unsupported approval action %q", request.Action)
+	}
+	return result, err
+}
@@ -0,0 +1,157 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+
+	"etlgo/internal/domain"
+	"etlgo/pkg/logger"
+	"etlgo/pkg/metrics"
+)
+
+// ConfigService exposes the effective configuration and applies runtime
+// changes of the settings that are safe to change without a restart: the
+// upstream rate limits, the default parse policy and holding scheduled runs.
+// Every change is validated as a whole before any setting is applied, and
+// recorded in the audit log.
+type ConfigService struct {
+	settings   any
+	rateLimits domain.RateLimitController
+	etlService *ETLService
+	scheduler  *PipelineScheduler
+	changes    domain.ConfigChangeRepository
+	// serializes changes so each one is diffed against the settings it replaces
+	mutex   sync.Mutex
+	clock   domain.Clock
+	ids     domain.IDGenerator
+	logger  *logger.Logger
+	metrics *metrics.Metrics
+}
+
+// NewConfigService creates a config service. settings are the effective
+// startup settings with their secrets redacted.
+func NewConfigService(
+	settings any,
+	rateLimits domain.RateLimitController,
+	etlService *ETLService,
+	scheduler *PipelineScheduler,
+	changes domain.ConfigChangeRepository,
+	clock domain.Clock,
+	ids domain.IDGenerator,
+	logger *logger.Logger,
+	metrics *metrics.Metrics,
+) *ConfigService {
+	return &ConfigService{
+		settings:   settings,
+		rateLimits: rateLimits,
+		etlService: etlService,
+		scheduler:  scheduler,
+		changes:    changes,
+		clock:      clock,
+		ids:        ids,
+		logger:     logger,
+		metrics:    metrics,
+	}
+}
+
+// Settings returns the startup settings without secrets
+func (s *ConfigService) Settings() any {
+	return s.settings
+}
+
+// Runtime returns the current values of the runtime settings
+func (s *ConfigService) Runtime() domain.RuntimeConfig {
+	return domain.RuntimeConfig{
+		RateLimits:      s.rateLimits.RateLimits(),
+		ParsePolicy:     s.etlService.ParsePolicy(),
+		SchedulesPaused: s.scheduler.Held(),
+	}
+}
+
+// Update applies the patch and records the settings it changed. A patch
+// that changes nothing returns a change without settings and is not
+// recorded.
+func (s *ConfigService) Update(ctx context.Context, patch domain.RuntimeConfigPatch, actor, apiKey string) (*domain.ConfigChange, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	current := s.Runtime()
+	if err := patch.Validate(current); err != nil {
+		return nil, err
+	}
+
+	change := &domain.ConfigChange{
+		ID:        s.ids.NewID(),
+		Actor:     actor,
+		APIKey:    apiKey,
+		Reason:    patch.Reason,
+		Changes:   []domain.SettingChange{},
+		ChangedAt: s.clock.Now().UTC(),
+	}
+	for _, api := range slices.Sorted(maps.Keys(patch.RateLimits)) {
+		limit := patch.RateLimits[api]
+		if limit == current.RateLimits[api] {
+			continue
+		}
+		// Validated above, only unknown APIs fail
+		if err := s.rateLimits.SetRateLimit(api, limit); err != nil {
+			return nil, err
+		}
+		change.Changes = append(change.Changes, domain.SettingChange{
+			Setting: "rate_limits." + api,
+			From:    current.RateLimits[api],
+			To:      limit,
+		})
+	}
+	if patch.ParsePolicy != nil && *patch.ParsePolicy != current.ParsePolicy {
+		s.etlService.SetParsePolicy(*patch.ParsePolicy)
+		change.Changes = append(change.Changes, domain.SettingChange{
+			Setting: "parse_policy",
+			From:    current.ParsePolicy,
+			To:      *patch.ParsePolicy,
+		})
+	}
+	if patch.SchedulesPaused != nil && *patch.SchedulesPaused != current.SchedulesPaused {
+		s.scheduler.SetHeld(*patch.SchedulesPaused)
+		change.Changes = append(change.Changes, domain.SettingChange{
+			Setting: "schedules_paused",
+			From:    current.SchedulesPaused,
+			To:      *patch.SchedulesPaused,
+		})
+	}
+
+	if len(change.Changes) == 0 {
+		return change, nil
+	}
+
+	log := s.logger.WithContext(ctx)
+	for _, setting := range change.Changes {
+		s.metrics.RecordConfigChange(setting.Setting)
+		log.WithFields(map[string]any{
+			"change_id": change.ID,
+			"setting":   setting.Setting,
+			"from":      fmt.Sprintf("%+v", setting.From),
+			"to":        fmt.Sprintf("%+v", setting.To),
+			"actor":     actor,
+			"api_key":   apiKey,
+			"reason":    patch.Reason,
+		}).Warn("Runtime configuration changed")
+	}
+	if err := s.changes.Record(ctx, *change); err != nil {
+		// The settings are already applied, the change is still logged above
+		log.WithError(err).WithField("change_id", change.ID).Error("Failed to record configuration change")
+	}
+	return change, nil
+}
+
+// Changes returns the recorded configuration changes, most recent first
+func (s *ConfigService) Changes(ctx context.Context, limit int) ([]domain.ConfigChange, error) {
+	changes, err := s.changes.List(ctx, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list configuration changes: %w", err)
+	}
+	return changes, nil
+}
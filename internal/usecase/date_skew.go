@@ -0,0 +1,104 @@
+package usecase
+
+import (
+	"time"
+
+	"etlgo/internal/domain"
+)
+
+// a date field clock skew is detected on. Day fields hold a calendar day,
+// and are clamped to whole days.
+type dateField[T any] struct {
+	name string
+	get  func(*T) *time.Time // nil when the row has no such date
+	day  bool
+}
+
+// describes how date skew detection reaches into one processed record type
+type dateTarget[T any] struct {
+	source string
+	id     func(*T) string
+	fields []dateField[T]
+}
+
+var adDateTarget = dateTarget[domain.ProcessedAdData]{
+	source: domain.SourceAds,
+	id:     func(ad *domain.ProcessedAdData) string { return ad.CampaignID },
+	fields: []dateField[domain.ProcessedAdData]{
+		{name: "date", get: func(ad *domain.ProcessedAdData) *time.Time { return &ad.Date }, day: true},
+	},
+}
+
+var crmDateTarget = dateTarget[domain.ProcessedOpportunity]{
+	source: domain.SourceCRM,
+	id:     func(opp *domain.ProcessedOpportunity) string { return opp.OpportunityID },
+	fields: []dateField[domain.ProcessedOpportunity]{
+		{name: "created_at", get: func(opp *domain.ProcessedOpportunity) *time.Time { return &opp.CreatedAt }},
+		{name: "closed_at", get: func(opp *domain.ProcessedOpportunity) *time.Time { return opp.ClosedAt }},
+	},
+}
+
+// detects future-dated and implausibly old dates in the rows of one source
+// and applies the policy's action to them. Rows quarantined are handed to
+// rejects and left out of the result; skewed dates are counted in report.
+func applyDateSkewPolicy[T any](rows []T, target dateTarget[T], policy domain.DateSkewPolicy, now time.Time, rejects *rowRejects, report *domain.DateSkewReport) []T {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	oldest := policy.Oldest(now)
+	oldestDay := time.Date(oldest.Year(), oldest.Month(), oldest.Day(), 0, 0, 0, 0, time.UTC)
+	if oldestDay.Before(oldest) {
+		oldestDay = oldestDay.AddDate(0, 0, 1)
+	}
+
+	kept := rows[:0]
+	for i := range rows {
+		row := &rows[i]
+		var errs []domain.RecordError
+
+		for _, field := range target.fields {
+			date := field.get(row)
+			if date == nil || date.IsZero() {
+				continue
+			}
+			future, old := policy.Skew(*date, now)
+			if !future && !old {
+				continue
+			}
+			report.Observe(*date, future)
+
+			switch policy.Action {
+			case domain.DateSkewClamp:
+				switch {
+				case future && field.day:
+					*date = today
+				case future:
+					*date = now
+				case field.day:
+					*date = oldestDay
+				default:
+					*date = oldest
+				}
+				report.Clamped++
+			case domain.DateSkewQuarantine:
+				reason := "dated in the future"
+				if old {
+					reason = "implausibly old date"
+				}
+				errs = append(errs, domain.RecordError{
+					Record: target.id(row),
+					Field:  field.name,
+					Value:  date.Format(time.RFC3339),
+					Reason: reason,
+				})
+			}
+		}
+
+		if len(errs) > 0 {
+			report.Quarantined++
+			rejects.quarantine(target.id(row), *row, errs...)
+			continue
+		}
+		kept = append(kept, *row)
+	}
+
+	return kept
+}
@@ -0,0 +1,171 @@
+package usecase
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"etlgo/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+// how long notified actions are remembered so later runs don't notify them
+// again
+const notifiedActionsRetention = 30 * 24 * time.Hour
+
+// SuggestActions returns the campaigns in the caller's scope the action
+// policy suggests pausing as of the day: those whose stored daily spend and
+// leads stayed above a cap for the policy's consecutive days up to it
+func (s *ETLService) SuggestActions(ctx context.Context, through time.Time) (*domain.ActionFeed, error) {
+	through = through.Truncate(24 * time.Hour)
+	feed := &domain.ActionFeed{
+		Through: through,
+		Policy:  s.actionPolicy,
+		Actions: []domain.Action{},
+	}
+	if !s.actionPolicy.Enabled() {
+		return feed, nil
+	}
+
+	from := through.AddDate(0, 0, 1-s.actionPolicy.Days)
+	ads, err := s.adRepo.GetByDateRange(ctx, from, through)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ads data for actions: %w", err)
+	}
+	opportunities, err := s.crmRepo.GetByDateRange(ctx, from, through)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get CRM data for actions: %w", err)
+	}
+
+	campaigns := campaignDays(ads, opportunities, from, through)
+	now := s.clock.Now()
+	for _, utm := range slices.SortedFunc(maps.Keys(campaigns), compareUTM) {
+		campaign := campaigns[utm]
+		for _, reason := range s.actionPolicy.Reasons(campaign.days) {
+			feed.Actions = append(feed.Actions, newPauseAction(utm, campaign, reason, s.actionPolicy, through, now))
+		}
+	}
+	*feed = feed.InScope(domain.MetricsScopeFromContext(ctx))
+	return feed, nil
+}
+
+// the daily totals of a campaign's UTM with the channel and campaign ID of
+// its ads
+type campaignSeries struct {
+	channel    string
+	campaignID string
+	days       []domain.CampaignDay
+}
+
+// totals the spend and leads of every UTM with ads per day of the range,
+// oldest first
+func campaignDays(ads []domain.ProcessedAdData, opportunities []domain.ProcessedOpportunity, from, to time.Time) map[domain.UTMKey]*campaignSeries {
+	dayIndex := func(t time.Time) int {
+		return int(t.Truncate(24*time.Hour).Sub(from) / (24 * time.Hour))
+	}
+	days := dayIndex(to) + 1
+
+	campaigns := make(map[domain.UTMKey]*campaignSeries)
+	for _, ad := range ads {
+		utm := domain.UTMKey{Campaign: ad.UTMCampaign, Source: ad.UTMSource, Medium: ad.UTMMedium}
+		campaign := campaigns[utm]
+		if campaign == nil {
+			campaign = &campaignSeries{days: make([]domain.CampaignDay, days)}
+			for i := range campaign.days {
+				campaign.days[i].Date = from.AddDate(0, 0, i)
+			}
+			campaigns[utm] = campaign
+		}
+		campaign.channel, campaign.campaignID = ad.Channel, ad.CampaignID
+		if i := dayIndex(ad.Date); i >= 0 && i < days {
+			campaign.days[i].Cost += ad.Cost
+		}
+	}
+
+	for _, opp := range opportunities {
+		if !opp.IsLead() {
+			continue
+		}
+		campaign := campaigns[domain.UTMKey{Campaign: opp.UTMCampaign, Source: opp.UTMSource, Medium: opp.UTMMedium}]
+		if campaign == nil {
+			continue
+		}
+		if i := dayIndex(opp.CreatedAt); i >= 0 && i < days {
+			campaign.days[i].Leads++
+		}
+	}
+
+	for _, campaign := range campaigns {
+		for i := range campaign.days {
+			if day := &campaign.days[i]; day.Leads > 0 {
+				day.CPA = day.Cost.Div(day.Leads)
+			}
+		}
+	}
+	return campaigns
+}
+
+func compareUTM(a, b domain.UTMKey) int {
+	return cmp.Or(
+		strings.Compare(a.Campaign, b.Campaign),
+		strings.Compare(a.Source, b.Source),
+		strings.Compare(a.Medium, b.Medium),
+	)
+}
+
+func newPauseAction(utm domain.UTMKey, campaign *campaignSeries, reason string, policy domain.ActionPolicy, through, now time.Time) domain.Action {
+	limit := policy.CPACap
+	if reason == domain.ActionReasonSpendCap {
+		limit = policy.DailySpendCap
+	}
+	key := fmt.Sprintf("%s|%s|%s|%s|%s|%s", domain.ActionPause, reason, utm.Campaign, utm.Source, utm.Medium, through.Format("2006-01-02"))
+	return domain.Action{
+		ID:          uuid.NewSHA1(uuid.NameSpaceOID, []byte(key)).String(),
+		Type:        domain.ActionPause,
+		Reason:      reason,
+		Channel:     campaign.channel,
+		CampaignID:  campaign.campaignID,
+		UTMCampaign: utm.Campaign,
+		UTMSource:   utm.Source,
+		UTMMedium:   utm.Medium,
+		Cap:         limit,
+		Days:        campaign.days[len(campaign.days)-policy.Days:],
+		Through:     through,
+		SuggestedAt: now,
+	}
+}
+
+// pushes the actions suggested as of yesterday, the last complete day, to
+// the channels subscribed to them. Actions already notified are skipped.
+func (s *ETLService) notifyActions(ctx context.Context) {
+	if !s.actionPolicy.Enabled() {
+		return
+	}
+	feed, err := s.SuggestActions(ctx, s.clock.Now().UTC().AddDate(0, 0, -1))
+	if err != nil {
+		s.logger.WithContext(ctx).WithError(err).Warn("Failed to suggest actions")
+		return
+	}
+
+	s.actionsMutex.Lock()
+	var fresh []domain.Action
+	for _, action := range feed.Actions {
+		if _, ok := s.notifiedActions[action.ID]; !ok {
+			s.notifiedActions[action.ID] = action.Through
+			fresh = append(fresh, action)
+		}
+	}
+	maps.DeleteFunc(s.notifiedActions, func(_ string, through time.Time) bool {
+		return s.clock.Now().Sub(through) > notifiedActionsRetention
+	})
+	s.actionsMutex.Unlock()
+
+	if len(fresh) > 0 {
+		s.notifier.NotifyActions(ctx, fresh)
+	}
+}
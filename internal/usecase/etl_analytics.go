@@ -0,0 +1,113 @@
+package usecase
+
+import (
+	"context"
+	"time"
+
+	"etlgo/internal/domain"
+)
+
+// date formats accepted for analytics report days
+var analyticsDateFormats = []string{
+	"20060102",   // YYYYMMDD, as reported by GA4
+	"2006-01-02", // YYYY-MM-DD
+}
+
+// processes and normalizes the analytics rows, applying the ga4 parse
+// policy. Runs that do not extract analytics process nothing.
+func (s *ETLService) transformAnalyticsData(ctx context.Context, data *domain.AnalyticsData, opts domain.RunOptions, summary *domain.RunSummary) ([]domain.ProcessedAnalyticsRow, error) {
+	if !s.extractsAnalytics(opts) {
+		return nil, nil
+	}
+	log := s.logger.WithContext(ctx)
+
+	rejects := newRowRejects(domain.SourceGA4)
+	for _, rejected := range data.Rejected {
+		rejects.add(rejected)
+		s.metrics.RecordETLRecordFailure("ga4", "decode")
+	}
+	processed := s.processAnalyticsData(data.Rows, rejects)
+	summary.Parsing[rejects.source] = rejects.report
+
+	// Quarantine rejected rows; a storage failure does not fail the run
+	if len(rejects.records) > 0 {
+		if err := s.quarantine.Store(ctx, rejects.records); err != nil {
+			log.WithError(err).Warn("Failed to quarantine rejected rows")
+		}
+	}
+
+	if err := s.parsePolicyFor(ctx, opts, rejects.source).Evaluate(rejects.source, rejects.report); err != nil {
+		log.WithError(err).WithField("source", rejects.source).Error("Parse policy violated")
+		return nil, err
+	}
+
+	s.metrics.RecordETLRecords("ga4", "success", tenantOf(ctx), len(processed))
+	return processed, nil
+}
+
+// processes and normalizes analytics rows. GA4 reports sessions without a
+// campaign, source or medium as "(not set)", which is stored as unknown like
+// the empty UTMs of the other sources.
+func (s *ETLService) processAnalyticsData(rows []domain.AnalyticsRow, rejects *rowRejects) []domain.ProcessedAnalyticsRow {
+	var processed []domain.ProcessedAnalyticsRow
+
+	for _, row := range rows {
+		var date time.Time
+		var err error
+		for _, format := range analyticsDateFormats {
+			date, err = time.Parse(format, row.Date)
+			if err == nil {
+				break
+			}
+		}
+
+		record := row.Date + "|" + row.UTMCampaign + "|" + row.UTMSource + "|" + row.UTMMedium
+		if err != nil {
+			s.metrics.RecordETLRecordFailure("ga4", "date_parse")
+			rejects.reject(record, row, domain.RecordError{
+				Record: record,
+				Field:  "date",
+				Value:  row.Date,
+				Reason: "unrecognized date format",
+			})
+			continue
+		}
+		rejects.report.Accept()
+
+		processed = append(processed, domain.ProcessedAnalyticsRow{
+			Date:        date,
+			UTMCampaign: analyticsUTM(row.UTMCampaign),
+			UTMSource:   analyticsUTM(row.UTMSource),
+			UTMMedium:   analyticsUTM(row.UTMMedium),
+			Sessions:    row.Sessions,
+			Conversions: row.Conversions,
+			ProcessedAt: s.clock.Now(),
+		})
+	}
+
+	return processed
+}
+
+// normalizes an analytics UTM value
+func analyticsUTM(value string) string {
+	if value == "" || value == "(not set)" {
+		return "unknown"
+	}
+	return value
+}
+
+// reports whether the run extracts analytics sessions: the source is
+// configured and included in the run
+func (s *ETLService) extractsAnalytics(opts domain.RunOptions) bool {
+	return s.analytics != nil && opts.IncludesSource(domain.SourceGA4)
+}
+
+// returns the days analytics sessions are extracted for: the metrics window
+// up to today, as the API reports no future days
+func (s *ETLService) analyticsWindow(since *time.Time) (from, to time.Time) {
+	from, to = s.metricsWindow(since)
+	if now := s.clock.Now(); to.After(now) {
+		to = now
+	}
+	return from.Truncate(24 * time.Hour), to.Truncate(24 * time.Hour)
+}
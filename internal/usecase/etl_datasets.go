@@ -0,0 +1,104 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+
+	"etlgo/internal/domain"
+)
+
+// PromoteShadowResult makes the metrics of a shadow result the active
+// dataset, replacing the stored metrics of the run's window in one step.
+// The replaced metrics are kept so DemoteDataset can restore them.
+func (s *ETLService) PromoteShadowResult(ctx context.Context, label, resultID, actor string) (*domain.DatasetPromotion, error) {
+	result, err := s.GetShadowResult(ctx, label, resultID)
+	if err != nil {
+		return nil, err
+	}
+	if result.Error != "" {
+		return nil, fmt.Errorf("%w: %s", domain.ErrShadowResultRejected, result.Error)
+	}
+
+	// Runs and pushes recalculating the window wait for the replacement;
+	// they take the dataset lock after the load lock too
+	s.loadMutex.Lock()
+	defer s.loadMutex.Unlock()
+	s.datasetMutex.Lock()
+	defer s.datasetMutex.Unlock()
+
+	from, to := metricsWindowAt(result.Since, result.CreatedAt)
+	previous, err := s.metricsRepo.Replace(ctx, from, to, result.Metrics)
+	if err != nil {
+		return nil, fmt.Errorf("failed to replace metrics: %w", err)
+	}
+
+	promotion := domain.DatasetPromotion{
+		ID:         s.ids.NewID(),
+		Label:      result.Label,
+		ResultID:   result.ID,
+		RunID:      result.RunID,
+		From:       from,
+		To:         to,
+		Promoted:   len(result.Metrics),
+		Replaced:   len(previous),
+		PromotedBy: actor,
+		PromotedAt: s.clock.Now(),
+		Previous:   previous,
+	}
+	if err := s.datasets.Save(ctx, promotion); err != nil {
+		// Put the replaced metrics back, there would be nothing to demote
+		if _, restoreErr := s.metricsRepo.Replace(ctx, from, to, previous); restoreErr != nil {
+			s.logger.WithContext(ctx).WithError(restoreErr).Error("Failed to restore metrics after a failed promotion")
+		}
+		return nil, fmt.Errorf("failed to save dataset promotion: %w", err)
+	}
+
+	s.logger.WithContext(ctx).WithFields(map[string]any{
+		"promotion_id": promotion.ID,
+		"label":        promotion.Label,
+		"result_id":    promotion.ResultID,
+		"promoted":     promotion.Promoted,
+		"replaced":     promotion.Replaced,
+		"actor":        actor,
+	}).Info("Shadow dataset promoted")
+	return &promotion, nil
+}
+
+// DemoteDataset rolls back the most recent active promotion, restoring the
+// metrics it replaced. Metrics stored in its window since the promotion
+// are replaced too.
+func (s *ETLService) DemoteDataset(ctx context.Context, actor string) (*domain.DatasetPromotion, error) {
+	s.loadMutex.Lock()
+	defer s.loadMutex.Unlock()
+	s.datasetMutex.Lock()
+	defer s.datasetMutex.Unlock()
+
+	promotion, err := s.datasets.Latest(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.metricsRepo.Replace(ctx, promotion.From, promotion.To, promotion.Previous); err != nil {
+		return nil, fmt.Errorf("failed to restore metrics: %w", err)
+	}
+
+	now := s.clock.Now()
+	promotion.DemotedBy = actor
+	promotion.DemotedAt = &now
+	if err := s.datasets.Save(ctx, *promotion); err != nil {
+		return nil, fmt.Errorf("failed to save dataset demotion: %w", err)
+	}
+
+	s.logger.WithContext(ctx).WithFields(map[string]any{
+		"promotion_id": promotion.ID,
+		"label":        promotion.Label,
+		"restored":     len(promotion.Previous),
+		"actor":        actor,
+	}).Info("Dataset demoted")
+	return promotion, nil
+}
+
+// ListDatasetPromotions returns the dataset promotions, most recent first
+func (s *ETLService) ListDatasetPromotions(ctx context.Context, limit int) ([]domain.DatasetPromotion, error) {
+	return s.datasets.List(ctx, limit)
+}
@@ -0,0 +1,108 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"etlgo/internal/domain"
+)
+
+// ReplayQuarantined reprocesses quarantined ads and CRM rows through the push
+// path, so rows rejected for a since fixed cause, such as a date format the
+// transform did not know, are stored and counted in the metrics. The
+// replayed rows leave the quarantine; those rejected again are quarantined
+// anew. Rows of other sources, and payloads that no longer decode, are
+// skipped and stay quarantined.
+func (s *ETLService) ReplayQuarantined(ctx context.Context, req domain.ReplayRequest) (*domain.ReplaySummary, error) {
+	if req.Source != "" && !domain.IsSource(req.Source) {
+		return nil, domain.Errorf(domain.ErrValidation, "unknown source %q", req.Source)
+	}
+
+	var records []domain.QuarantinedRecord
+	var err error
+	if len(req.IDs) > 0 {
+		records, err = s.quarantine.List(ctx, "", 0)
+		records = slices.DeleteFunc(records, func(record domain.QuarantinedRecord) bool {
+			return !slices.Contains(req.IDs, record.ID)
+		})
+	} else {
+		records, err = s.quarantine.List(ctx, req.Source, req.Limit)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list quarantined rows: %w", err)
+	}
+
+	// IDs and corrected payloads of rows that are not selected are reported
+	summary := &domain.ReplaySummary{}
+	unknown := slices.Clone(req.IDs)
+	for id := range req.Payloads {
+		if !slices.Contains(unknown, id) {
+			unknown = append(unknown, id)
+		}
+	}
+	for _, id := range unknown {
+		if !slices.ContainsFunc(records, func(record domain.QuarantinedRecord) bool { return record.ID == id }) {
+			summary.Skipped = append(summary.Skipped, domain.ReplaySkip{ID: id, Reason: "not quarantined"})
+		}
+	}
+
+	var batch domain.PushBatch
+	var replayed []string
+	for _, record := range records {
+		payload := record.Payload
+		if corrected, ok := req.Payloads[record.ID]; ok {
+			payload = corrected
+		}
+
+		switch record.Source {
+		case domain.SourceAds:
+			var ad domain.AdPerformance
+			err = json.Unmarshal(payload, &ad)
+			if err == nil {
+				batch.Ads = append(batch.Ads, ad)
+			}
+		case domain.SourceCRM:
+			var opp domain.Opportunity
+			err = json.Unmarshal(payload, &opp)
+			if err == nil {
+				batch.Opportunities = append(batch.Opportunities, opp)
+			}
+		default:
+			summary.Skipped = append(summary.Skipped, domain.ReplaySkip{ID: record.ID, Reason: "only ads and crm rows can be replayed"})
+			continue
+		}
+		if err != nil {
+			summary.Skipped = append(summary.Skipped, domain.ReplaySkip{ID: record.ID, Reason: "payload does not decode, pass a corrected payload: " + err.Error()})
+			continue
+		}
+		replayed = append(replayed, record.ID)
+	}
+
+	if batch.Len() == 0 {
+		return summary, nil
+	}
+
+	push, err := s.IngestPush(ctx, batch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to replay quarantined rows: %w", err)
+	}
+	if err := s.quarantine.Delete(ctx, replayed); err != nil {
+		return nil, fmt.Errorf("failed to delete replayed rows: %w", err)
+	}
+
+	summary.Replayed = len(replayed)
+	for _, report := range push.Parsing {
+		summary.Rejected += report.Rejected
+	}
+	summary.Push = push
+
+	s.metrics.RecordBusinessMetric("quarantine_replayed")
+	s.logger.WithContext(ctx).WithFields(map[string]any{
+		"replayed": summary.Replayed,
+		"rejected": summary.Rejected,
+		"skipped":  len(summary.Skipped),
+	}).Info("Replayed quarantined rows")
+	return summary, nil
+}
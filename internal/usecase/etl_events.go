@@ -0,0 +1,292 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"etlgo/internal/domain"
+)
+
+// appends the versions of the ingested records to the event log; records
+// unchanged since their latest version are not appended again
+func (s *ETLService) appendEvents(ctx context.Context, ingestRunID, origin string, ads []domain.ProcessedAdData, opportunities []domain.ProcessedOpportunity) error {
+	events := make([]domain.IngestEvent, 0, len(ads)+len(opportunities))
+	for _, ad := range ads {
+		events = append(events, domain.NewAdEvent(ad))
+	}
+	for _, opp := range opportunities {
+		events = append(events, domain.NewOpportunityEvent(opp))
+	}
+	for i := range events {
+		events[i].IngestRunID = ingestRunID
+		events[i].Origin = origin
+	}
+
+	if _, err := s.events.Append(ctx, events); err != nil {
+		return fmt.Errorf("failed to append ingest events: %w", err)
+	}
+	return nil
+}
+
+// returns event log entries in the caller's scope, most recent first. Run
+// tags select the ingestions of the recorded runs carrying them.
+func (s *ETLService) ListEvents(ctx context.Context, filter domain.EventFilter) ([]domain.IngestEvent, error) {
+	if len(filter.RunTags) > 0 {
+		runs, err := s.runs.List(ctx, domain.RunFilter{Tags: filter.RunTags})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list tagged runs: %w", err)
+		}
+		if len(runs) == 0 {
+			return []domain.IngestEvent{}, nil
+		}
+		filter.IngestRunIDs = make([]string, len(runs))
+		for i, run := range runs {
+			filter.IngestRunIDs[i] = run.ID
+		}
+	}
+
+	events, err := s.events.List(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ingest events: %w", err)
+	}
+	return domain.InScope(domain.MetricsScopeFromContext(ctx), events), nil
+}
+
+// returns the latest version of every record in the caller's scope as of
+// asOf, or as of the end of an ingest run when ingestRunID is set. A zero
+// asOf reads the current state.
+func (s *ETLService) EventSnapshot(ctx context.Context, asOf time.Time, ingestRunID string) (*domain.EventSnapshot, error) {
+	if ingestRunID != "" {
+		events, err := s.events.List(ctx, domain.EventFilter{IngestRunID: ingestRunID, Limit: 1})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list ingest events: %w", err)
+		}
+		if len(events) == 0 {
+			return nil, domain.Errorf(domain.ErrNotFound, "no events recorded for ingest run %s", ingestRunID)
+		}
+		asOf = events[0].IngestedAt
+	}
+	if asOf.IsZero() {
+		asOf = s.clock.Now().UTC()
+	}
+
+	latest, err := s.events.Latest(ctx, asOf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ingest events: %w", err)
+	}
+
+	snapshot := &domain.EventSnapshot{
+		AsOf:          asOf,
+		Ads:           []domain.ProcessedAdData{},
+		Opportunities: []domain.ProcessedOpportunity{},
+	}
+	for _, event := range latest {
+		switch {
+		case event.Ad != nil:
+			snapshot.Ads = append(snapshot.Ads, *event.Ad)
+		case event.Opportunity != nil:
+			snapshot.Opportunities = append(snapshot.Opportunities, *event.Opportunity)
+		}
+	}
+	*snapshot = snapshot.InScope(domain.MetricsScopeFromContext(ctx))
+	return snapshot, nil
+}
+
+// rolls back the records an ingest run or push changed to their previous
+// version, appending the restored versions and tombstones for records the
+// ingestion added, then reloads the stored data and recalculates metrics
+// over the ingestion's window, from earlier when an affected day predates it
+func (s *ETLService) RollbackIngest(ctx context.Context, ingestRunID string) (*domain.EventRollback, error) {
+	s.loadMutex.Lock()
+	defer s.loadMutex.Unlock()
+
+	events, err := s.events.List(ctx, domain.EventFilter{IngestRunID: ingestRunID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ingest events: %w", err)
+	}
+	if len(events) == 0 {
+		return nil, domain.Errorf(domain.ErrNotFound, "no events recorded for ingest run %s", ingestRunID)
+	}
+
+	rollback := &domain.EventRollback{
+		IngestRunID: ingestRunID,
+		RollbackID:  s.ids.NewID(),
+		Skipped:     []string{},
+	}
+
+	var reverts []domain.IngestEvent
+	var earliest time.Time
+	for _, event := range events {
+		history, err := s.events.List(ctx, domain.EventFilter{Source: event.Source, Key: event.Key, Limit: 2})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list ingest events: %w", err)
+		}
+
+		var revert domain.IngestEvent
+		switch {
+		case history[0].Seq != event.Seq:
+			// changed again by a later ingestion
+			rollback.Skipped = append(rollback.Skipped, event.RecordKey())
+			continue
+		case len(history) > 1:
+			revert = history[1]
+		case event.Version == 1:
+			revert = domain.IngestEvent{Source: event.Source, Key: event.Key, Deleted: true}
+		default:
+			// the previous version was compacted
+			rollback.Skipped = append(rollback.Skipped, event.RecordKey())
+			continue
+		}
+		revert.IngestRunID = rollback.RollbackID
+		revert.Origin = domain.EventOriginRollback
+		reverts = append(reverts, revert)
+
+		if revert.Deleted {
+			rollback.Deleted++
+		} else {
+			rollback.Restored++
+		}
+		for _, day := range []time.Time{eventDay(event), eventDay(revert)} {
+			if !day.IsZero() && (earliest.IsZero() || day.Before(earliest)) {
+				earliest = day
+			}
+		}
+	}
+	if len(reverts) == 0 {
+		return rollback, nil
+	}
+
+	if _, err := s.events.Append(ctx, reverts); err != nil {
+		return nil, fmt.Errorf("failed to append ingest events: %w", err)
+	}
+	if err := s.reloadFromEvents(ctx, reverts); err != nil {
+		return nil, err
+	}
+	since, err := s.ingestMetricsSince(ctx, ingestRunID)
+	if err != nil {
+		return nil, err
+	}
+	if since != nil && earliest.Before(*since) {
+		since = &earliest
+	}
+	if _, _, err := s.calculateMetrics(ctx, since); err != nil {
+		return nil, fmt.Errorf("failed to recalculate metrics: %w", err)
+	}
+
+	s.metrics.RecordBusinessMetric("rollback")
+	s.logger.WithContext(ctx).WithFields(map[string]any{
+		"ingest_run_id": ingestRunID,
+		"rollback_id":   rollback.RollbackID,
+		"restored":      rollback.Restored,
+		"deleted":       rollback.Deleted,
+		"skipped":       len(rollback.Skipped),
+	}).Info("Rolled back ingestion")
+	return rollback, nil
+}
+
+// returns the day the metrics of an ingestion were calculated from: the
+// run's window, or for pushes the latest completed run's, whose metrics
+// they add to. Nil is the whole default window.
+func (s *ETLService) ingestMetricsSince(ctx context.Context, ingestRunID string) (*time.Time, error) {
+	run, err := s.runs.Get(ctx, ingestRunID)
+	if errors.Is(err, domain.ErrRunNotFound) {
+		return s.latestMetricsSince(ctx)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get run: %w", err)
+	}
+	return runMetricsSince(*run), nil
+}
+
+// returns the day the latest completed run calculated metrics from, nil
+// without one
+func (s *ETLService) latestMetricsSince(ctx context.Context) (*time.Time, error) {
+	run, err := s.runs.Latest(ctx, domain.RunCompleted)
+	if errors.Is(err, domain.ErrRunNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest run: %w", err)
+	}
+	return runMetricsSince(*run), nil
+}
+
+// returns the day the run calculated metrics from
+func runMetricsSince(run domain.RunRecord) *time.Time {
+	opts := domain.RunOptions{Since: run.Since, Sources: run.Sources, Watermarks: run.Watermarks}
+	return opts.MetricsSince()
+}
+
+// the last day ads can be stored on, so a range up to it has no end
+var endOfTime = time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+
+// applies reverted events to the stored data. Ads have no identity in their
+// store, so they are reloaded from the latest versions in the log.
+func (s *ETLService) reloadFromEvents(ctx context.Context, reverts []domain.IngestEvent) error {
+	var restored []domain.ProcessedOpportunity
+	var deleted []string
+	adsChanged := false
+	for _, revert := range reverts {
+		switch {
+		case revert.Source == domain.SourceAds:
+			adsChanged = true
+		case revert.Deleted:
+			deleted = append(deleted, revert.Key)
+		case revert.Opportunity != nil:
+			restored = append(restored, *revert.Opportunity)
+		}
+	}
+
+	if adsChanged {
+		snapshot, err := s.EventSnapshot(ctx, time.Time{}, "")
+		if err != nil {
+			return err
+		}
+		if err := s.adRepo.Replace(ctx, time.Time{}, endOfTime, snapshot.Ads); err != nil {
+			return fmt.Errorf("failed to reload ads data: %w", err)
+		}
+	}
+	if len(restored) > 0 {
+		if err := s.crmRepo.Store(ctx, restored); err != nil {
+			return fmt.Errorf("failed to restore CRM data: %w", err)
+		}
+	}
+	if len(deleted) > 0 {
+		if err := s.crmRepo.Delete(ctx, deleted); err != nil {
+			return fmt.Errorf("failed to delete CRM data: %w", err)
+		}
+	}
+	return nil
+}
+
+// returns the day of the event's record, or zero for tombstones
+func eventDay(event domain.IngestEvent) time.Time {
+	switch {
+	case event.Ad != nil:
+		return event.Ad.Date.Truncate(24 * time.Hour)
+	case event.Opportunity != nil:
+		return event.Opportunity.CreatedAt.Truncate(24 * time.Hour)
+	}
+	return time.Time{}
+}
+
+// drops the versions superseded more than retention ago. Reading the log
+// as of an earlier time is no longer possible afterwards.
+func (s *ETLService) CompactEvents(ctx context.Context, retention time.Duration) (domain.EventCompaction, error) {
+	compaction, err := s.events.Compact(ctx, s.clock.Now().UTC().Add(-retention))
+	if err != nil {
+		return compaction, fmt.Errorf("failed to compact ingest events: %w", err)
+	}
+	return compaction, nil
+}
+
+// compacts the event log every interval until ctx is cancelled
+func (s *ETLService) WatchEventCompaction(ctx context.Context, interval, retention time.Duration, heartbeat domain.Heartbeat) {
+	for heartbeat.Sleep(ctx, interval) {
+		if _, err := s.CompactEvents(ctx, retention); err != nil {
+			s.logger.WithError(err).Warn("Failed to compact ingest events")
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"etlgo/internal/domain"
+)
+
+// compares the stored spend of every day the run loaded ads for with the
+// median daily spend of the baseline days before it, and holds the exports of
+// the days that deviate by more than the policy's factor. Days whose exports
+// are already held keep their pending hold.
+func (s *ETLService) holdAnomalousSpend(ctx context.Context, ads []domain.ProcessedAdData, runID string) ([]domain.ExportHold, error) {
+	if !s.spendPolicy.Enabled() || len(ads) == 0 {
+		return nil, nil
+	}
+
+	var days []time.Time
+	for _, ad := range ads {
+		day := ad.Date.Truncate(24 * time.Hour)
+		if !slices.ContainsFunc(days, day.Equal) {
+			days = append(days, day)
+		}
+	}
+	slices.SortFunc(days, time.Time.Compare)
+
+	from := days[0].AddDate(0, 0, -s.spendPolicy.BaselineDays)
+	stored, err := s.adRepo.GetByDateRange(ctx, from, days[len(days)-1])
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ads data for spend baseline: %w", err)
+	}
+	spend := make(map[time.Time]domain.Money)
+	for _, ad := range stored {
+		spend[ad.Date.Truncate(24*time.Hour)] += ad.Cost
+	}
+
+	log := s.logger.WithContext(ctx)
+	var holds []domain.ExportHold
+	for _, day := range days {
+		daily := make([]domain.Money, 0, s.spendPolicy.BaselineDays)
+		for i := 1; i <= s.spendPolicy.BaselineDays; i++ {
+			daily = append(daily, spend[day.AddDate(0, 0, -i)])
+		}
+		baseline, ok := s.spendPolicy.Baseline(daily)
+		if !ok {
+			continue
+		}
+		deviation, anomalous := s.spendPolicy.Check(spend[day], baseline)
+		if !anomalous {
+			continue
+		}
+
+		hold := domain.ExportHold{
+			ID:        s.ids.NewID(),
+			Date:      day,
+			RunID:     runID,
+			Spend:     spend[day],
+			Baseline:  baseline,
+			Deviation: deviation,
+			Status:    domain.ExportHoldPending,
+			CreatedAt: s.clock.Now().UTC(),
+		}
+		err := s.holds.Create(ctx, hold)
+		if errors.Is(err, domain.ErrConflict) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to hold exports: %w", err)
+		}
+		holds = append(holds, hold)
+
+		s.metrics.RecordBusinessMetric("export_hold")
+		log.WithFields(map[string]any{
+			"hold_id":   hold.ID,
+			"date":      day.Format("2006-01-02"),
+			"spend":     hold.Spend,
+			"baseline":  baseline,
+			"deviation": deviation,
+		}).Warn("Spend deviates from baseline, holding exports")
+	}
+	return holds, nil
+}
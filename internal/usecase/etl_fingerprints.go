@@ -0,0 +1,141 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+
+	"etlgo/internal/domain"
+)
+
+// buffers records are encoded into for fingerprinting, reused across runs
+// and pushes
+var fingerprintBuffers = sync.Pool{
+	New: func() any { return new(bytes.Buffer) },
+}
+
+// stamps the fingerprint of their business fields on the processed records
+func (s *ETLService) fingerprintRecords(ads []domain.ProcessedAdData, opportunities []domain.ProcessedOpportunity) {
+	buf := fingerprintBuffers.Get().(*bytes.Buffer)
+	defer fingerprintBuffers.Put(buf)
+
+	for i := range ads {
+		buf.Reset()
+		ads[i].EncodeFingerprintFields(buf)
+		ads[i].Fingerprint = s.fingerprinter.Fingerprint(buf.Bytes())
+	}
+	for i := range opportunities {
+		buf.Reset()
+		opportunities[i].EncodeFingerprintFields(buf)
+		opportunities[i].Fingerprint = s.fingerprinter.Fingerprint(buf.Bytes())
+	}
+}
+
+// compares the ingested records with their stored versions by natural key
+// and fingerprint. Returns the counts per source and the new and changed
+// records; storing the unchanged ones again would be a no-op.
+func (s *ETLService) detectChanges(ctx context.Context, ads []domain.ProcessedAdData, opportunities []domain.ProcessedOpportunity) (map[string]*domain.ChangeCounts, []domain.ProcessedAdData, []domain.ProcessedOpportunity, error) {
+	changes := make(map[string]*domain.ChangeCounts)
+
+	storedAds, err := s.storedAdFingerprints(ctx, ads)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	changedAds := make([]domain.ProcessedAdData, 0, len(ads))
+	if len(ads) > 0 {
+		counts := &domain.ChangeCounts{}
+		for _, ad := range ads {
+			if counts.Add(ad.Fingerprint, storedAds[ad.RecordKey()]) {
+				changedAds = append(changedAds, ad)
+			}
+		}
+		changes[domain.SourceAds] = counts
+	}
+
+	storedOpps, err := s.storedOpportunityFingerprints(ctx, opportunities)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	changedOpps := make([]domain.ProcessedOpportunity, 0, len(opportunities))
+	if len(opportunities) > 0 {
+		counts := &domain.ChangeCounts{}
+		for _, opp := range opportunities {
+			if counts.Add(opp.Fingerprint, storedOpps[opp.OpportunityID]) {
+				changedOpps = append(changedOpps, opp)
+			}
+		}
+		changes[domain.SourceCRM] = counts
+	}
+
+	for source, counts := range changes {
+		s.metrics.RecordETLRecords(source, "new", tenantOf(ctx), counts.New)
+		s.metrics.RecordETLRecords(source, "changed", tenantOf(ctx), counts.Changed)
+		s.metrics.RecordETLRecords(source, "unchanged", tenantOf(ctx), counts.Unchanged)
+	}
+	return changes, changedAds, changedOpps, nil
+}
+
+// returns the fingerprints of the stored ads with the natural keys of the
+// given ones
+func (s *ETLService) storedAdFingerprints(ctx context.Context, ads []domain.ProcessedAdData) (map[string][]string, error) {
+	if len(ads) == 0 {
+		return nil, nil
+	}
+	from, to := ads[0].Date, ads[0].Date
+	for _, ad := range ads[1:] {
+		if ad.Date.Before(from) {
+			from = ad.Date
+		}
+		if ad.Date.After(to) {
+			to = ad.Date
+		}
+	}
+
+	stored, err := s.adRepo.GetByDateRange(ctx, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stored ads: %w", err)
+	}
+	fingerprints := make(map[string][]string, len(stored))
+	for _, ad := range stored {
+		key := ad.RecordKey()
+		fingerprints[key] = append(fingerprints[key], s.storedFingerprint(ad.Fingerprint, ad.EncodeFingerprintFields))
+	}
+	return fingerprints, nil
+}
+
+// returns the fingerprints of the stored versions of the opportunities
+func (s *ETLService) storedOpportunityFingerprints(ctx context.Context, opportunities []domain.ProcessedOpportunity) (map[string][]string, error) {
+	if len(opportunities) == 0 {
+		return nil, nil
+	}
+	ids := make([]string, 0, len(opportunities))
+	for _, opp := range opportunities {
+		ids = append(ids, opp.OpportunityID)
+	}
+
+	stored, err := s.crmRepo.GetByIDs(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stored opportunities: %w", err)
+	}
+	fingerprints := make(map[string][]string, len(stored))
+	for _, opp := range stored {
+		fingerprints[opp.OpportunityID] = []string{s.storedFingerprint(opp.Fingerprint, opp.EncodeFingerprintFields)}
+	}
+	return fingerprints, nil
+}
+
+// returns the stored fingerprint, computing it for records stored without
+// one. Fingerprints of another algorithm don't match, so records stored
+// before the algorithm changed count as changed once.
+func (s *ETLService) storedFingerprint(fingerprint string, encode func(*bytes.Buffer)) string {
+	if fingerprint != "" {
+		return fingerprint
+	}
+
+	buf := fingerprintBuffers.Get().(*bytes.Buffer)
+	defer fingerprintBuffers.Put(buf)
+	buf.Reset()
+	encode(buf)
+	return s.fingerprinter.Fingerprint(buf.Bytes())
+}
@@ -0,0 +1,196 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"etlgo/internal/domain"
+)
+
+// DetectGaps reports the days of the range without data of each source, or
+// of the given one. The range ends yesterday at the latest, today's data is
+// still arriving.
+func (s *ETLService) DetectGaps(ctx context.Context, from, to time.Time, source string) (*domain.GapReport, error) {
+	sources := s.gapSources()
+	if source != "" {
+		if !slices.Contains(sources, source) {
+			return nil, domain.Errorf(domain.ErrValidation, "unsupported source %q, expected one of: %s", source, strings.Join(sources, ", "))
+		}
+		sources = []string{source}
+	}
+
+	if yesterday := domain.GapDay(s.clock.Now()).AddDate(0, 0, -1); to.After(yesterday) {
+		to = yesterday
+	}
+	report := &domain.GapReport{
+		From:    from.Format("2006-01-02"),
+		To:      to.Format("2006-01-02"),
+		Sources: []domain.SourceGaps{},
+	}
+	if from.After(to) {
+		return report, nil
+	}
+
+	for _, source := range sources {
+		days, err := s.sourceDays(ctx, source, from, to)
+		if err != nil {
+			return nil, err
+		}
+		gaps := domain.FindGaps(source, from, to, days)
+		report.Sources = append(report.Sources, domain.SourceGaps{
+			Source:      source,
+			MissingDays: len(domain.GapDays(gaps)),
+			Gaps:        append([]domain.DataGap{}, gaps...),
+		})
+	}
+	return report, nil
+}
+
+// the sources gaps are looked for in; analytics only once it is configured
+func (s *ETLService) gapSources() []string {
+	sources := []string{domain.SourceAds, domain.SourceCRM}
+	if s.analytics != nil {
+		sources = append(sources, domain.SourceGA4)
+	}
+	return sources
+}
+
+// returns the days of the range with stored data of the source. Zero ad
+// rows inserted for gaps are not data.
+func (s *ETLService) sourceDays(ctx context.Context, source string, from, to time.Time) (map[time.Time]bool, error) {
+	days := make(map[time.Time]bool)
+	switch source {
+	case domain.SourceAds:
+		ads, err := s.adRepo.GetByDateRange(ctx, from, to)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get ads data for gaps: %w", err)
+		}
+		for _, ad := range ads {
+			if !ad.GapFilled() {
+				days[domain.GapDay(ad.Date)] = true
+			}
+		}
+	case domain.SourceCRM:
+		opportunities, err := s.crmRepo.GetByDateRange(ctx, from, to)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get CRM data for gaps: %w", err)
+		}
+		for _, opp := range opportunities {
+			days[domain.GapDay(opp.CreatedAt)] = true
+		}
+	case domain.SourceGA4:
+		rows, err := s.sessions.GetByDateRange(ctx, from, to)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get analytics data for gaps: %w", err)
+		}
+		for _, row := range rows {
+			days[domain.GapDay(row.Date)] = true
+		}
+	}
+	return days, nil
+}
+
+// looks for gaps in the run's sources over its window up to yesterday.
+// Under the zero-fill policy every campaign with ads in the window gets a
+// zero row on each day without ads data, so daily series show the day
+// instead of skipping it.
+func (s *ETLService) handleGaps(ctx context.Context, opts domain.RunOptions) ([]domain.DataGap, error) {
+	if s.gapPolicy == domain.GapIgnore {
+		return nil, nil
+	}
+	from, _ := s.metricsWindow(opts.Since)
+	to := domain.GapDay(s.clock.Now()).AddDate(0, 0, -1)
+	if from.After(to) {
+		return nil, nil
+	}
+
+	var gaps []domain.DataGap
+	for _, source := range s.gapSources() {
+		if !opts.IncludesSource(source) {
+			continue
+		}
+		days, err := s.sourceDays(ctx, source, from, to)
+		if err != nil {
+			return nil, err
+		}
+		sourceGaps := domain.FindGaps(source, from, to, days)
+		if source == domain.SourceAds && s.gapPolicy == domain.GapZeroFill && len(sourceGaps) > 0 {
+			if err := s.fillAdGaps(ctx, from, to, sourceGaps); err != nil {
+				return nil, err
+			}
+		}
+		gaps = append(gaps, sourceGaps...)
+	}
+
+	if len(gaps) > 0 {
+		s.logger.WithContext(ctx).WithFields(map[string]any{
+			"gaps":   len(gaps),
+			"policy": s.gapPolicy,
+		}).Warn("Days without upstream data")
+	}
+	return gaps, nil
+}
+
+// stores a zero row for every campaign with ads in the range on each day of
+// the gaps that has none yet, and marks the gaps filled
+func (s *ETLService) fillAdGaps(ctx context.Context, from, to time.Time, gaps []domain.DataGap) error {
+	ads, err := s.adRepo.GetByDateRange(ctx, from, to)
+	if err != nil {
+		return fmt.Errorf("failed to get ads data for gaps: %w", err)
+	}
+
+	type campaign struct {
+		channel, id string
+		utm         domain.UTMKey
+	}
+	var campaigns []campaign
+	seen := make(map[campaign]bool)
+	filled := make(map[campaign]map[time.Time]bool)
+	for _, ad := range ads {
+		key := campaign{ad.Channel, ad.CampaignID, domain.UTMKey{Campaign: ad.UTMCampaign, Source: ad.UTMSource, Medium: ad.UTMMedium}}
+		if ad.GapFilled() {
+			if filled[key] == nil {
+				filled[key] = make(map[time.Time]bool)
+			}
+			filled[key][domain.GapDay(ad.Date)] = true
+			continue
+		}
+		if !seen[key] {
+			seen[key] = true
+			campaigns = append(campaigns, key)
+		}
+	}
+
+	now := s.clock.Now()
+	var rows []domain.ProcessedAdData
+	for _, day := range domain.GapDays(gaps) {
+		for _, c := range campaigns {
+			if filled[c][day] {
+				continue
+			}
+			rows = append(rows, domain.ProcessedAdData{
+				Date:        day,
+				CampaignID:  c.id,
+				Channel:     c.channel,
+				UTMCampaign: c.utm.Campaign,
+				UTMSource:   c.utm.Source,
+				UTMMedium:   c.utm.Medium,
+				Flags:       []string{domain.FlagGapFill},
+				ProcessedAt: now,
+			})
+		}
+	}
+	if len(rows) > 0 {
+		if err := s.adRepo.Store(ctx, rows); err != nil {
+			return fmt.Errorf("failed to store gap rows: %w", err)
+		}
+	}
+
+	for i := range gaps {
+		gaps[i].Filled = len(campaigns) > 0
+	}
+	return nil
+}
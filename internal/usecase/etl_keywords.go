@@ -0,0 +1,151 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"etlgo/internal/domain"
+)
+
+// processes and normalizes the keyword feed, applying the keywords parse
+// policy. Runs that do not extract keywords process nothing.
+func (s *ETLService) transformKeywordData(ctx context.Context, data *domain.KeywordData, opts domain.RunOptions, summary *domain.RunSummary) ([]domain.ProcessedKeywordData, error) {
+	if !s.extractsKeywords(opts) {
+		return nil, nil
+	}
+	log := s.logger.WithContext(ctx)
+
+	rejects := newRowRejects(domain.SourceKeywords)
+	for _, rejected := range data.Rejected {
+		rejects.add(rejected)
+		s.metrics.RecordETLRecordFailure("keywords", "decode")
+	}
+	processed := s.processKeywordData(data.Rows, opts.Since, rejects)
+	summary.Parsing[rejects.source] = rejects.report
+
+	// Quarantine rejected rows; a storage failure does not fail the run
+	if len(rejects.records) > 0 {
+		if err := s.quarantine.Store(ctx, rejects.records); err != nil {
+			log.WithError(err).Warn("Failed to quarantine rejected rows")
+		}
+	}
+
+	if err := s.parsePolicyFor(ctx, opts, rejects.source).Evaluate(rejects.source, rejects.report); err != nil {
+		log.WithError(err).WithField("source", rejects.source).Error("Parse policy violated")
+		return nil, err
+	}
+
+	s.metrics.RecordETLRecords("keywords", "success", tenantOf(ctx), len(processed))
+	return processed, nil
+}
+
+// processes and normalizes keyword rows. Rows without a keyword or with a
+// match type other than exact, phrase or broad are rejected.
+func (s *ETLService) processKeywordData(rows []domain.KeywordPerformance, since *time.Time, rejects *rowRejects) []domain.ProcessedKeywordData {
+	processed := make([]domain.ProcessedKeywordData, 0, len(rows))
+
+	for _, row := range rows {
+		record := row.CampaignID + "|" + row.Keyword
+		var date time.Time
+		var err error
+		for _, format := range adDateFormats {
+			date, err = time.Parse(format, row.Date)
+			if err == nil {
+				break
+			}
+		}
+		if err != nil {
+			s.metrics.RecordETLRecordFailure("keywords", "date_parse")
+			rejects.reject(record, row, domain.RecordError{
+				Record: record,
+				Field:  "date",
+				Value:  row.Date,
+				Reason: "unrecognized date format",
+			})
+			continue
+		}
+
+		keyword := domain.NormalizeKeyword(row.Keyword)
+		if keyword == "" {
+			s.metrics.RecordETLRecordFailure("keywords", "keyword")
+			rejects.reject(record, row, domain.RecordError{
+				Record: record,
+				Field:  "keyword",
+				Reason: "keyword is empty",
+			})
+			continue
+		}
+		matchType := domain.NormalizeMatchType(row.MatchType)
+		if !slices.Contains(domain.MatchTypes, matchType) {
+			s.metrics.RecordETLRecordFailure("keywords", "match_type")
+			rejects.reject(record, row, domain.RecordError{
+				Record: record,
+				Field:  "match_type",
+				Value:  row.MatchType,
+				Reason: "match type must be one of " + strings.Join(domain.MatchTypes, ", "),
+			})
+			continue
+		}
+		rejects.report.Accept()
+
+		if since != nil && date.Before(*since) {
+			continue
+		}
+
+		processed = append(processed, domain.ProcessedKeywordData{
+			Date:        date,
+			CampaignID:  row.CampaignID,
+			AdGroupID:   row.AdGroupID,
+			Channel:     row.Channel,
+			Keyword:     keyword,
+			MatchType:   matchType,
+			Clicks:      row.Clicks,
+			Cost:        row.Cost,
+			ProcessedAt: s.clock.Now(),
+		})
+	}
+
+	return processed
+}
+
+// reports whether the run extracts the keyword feed: the feed is configured
+// and included in the run
+func (s *ETLService) extractsKeywords(opts domain.RunOptions) bool {
+	return s.keywordFeed != nil && opts.IncludesSource(domain.SourceKeywords)
+}
+
+// GetKeywordMetrics totals the stored keyword rows of the filter's days per
+// keyword, match type and ad group. Callers scoped to UTMs see no keywords,
+// keyword rows carry no UTMs.
+func (s *ETLService) GetKeywordMetrics(ctx context.Context, filter domain.KeywordFilter) ([]domain.KeywordMetrics, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.keywords.GetByDateRange(ctx, filter.From, filter.To)
+	if err != nil {
+		s.logger.WithContext(ctx).WithError(err).Error("Failed to get keyword data")
+		return nil, fmt.Errorf("failed to get keyword data: %w", err)
+	}
+
+	scope := domain.MetricsScopeFromContext(ctx)
+	if err := scope.Check(domain.MetricsFilter{Channel: filter.Channel, CampaignID: filter.CampaignID, AdGroupID: filter.AdGroupID}); err != nil {
+		return nil, err
+	}
+	selected := make([]domain.ProcessedKeywordData, 0, len(rows))
+	for _, row := range rows {
+		allowed := filter.Matches(row)
+		for dimension := range scope {
+			allowed = allowed && scope.AllowsValue(dimension, row.DimensionValue(dimension))
+		}
+		if allowed {
+			selected = append(selected, row)
+		}
+	}
+
+	s.metrics.RecordBusinessMetric("keyword_query")
+	return domain.KeywordMetricsOf(selected), nil
+}
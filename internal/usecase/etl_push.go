@@ -0,0 +1,177 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"etlgo/internal/domain"
+)
+
+// applies records pushed between full runs. They are transformed and stored
+// like a run's rows, then the metrics of the UTMs they touch are
+// recalculated over the latest run's window, as that run would have, so
+// intraday dashboards see them without waiting for the next RunETL.
+// Opportunities pushed again with changes restate the metrics of both
+// versions and are logged as restatements. Pushes wait for the loads of
+// runs and rollbacks, and those for them.
+func (s *ETLService) IngestPush(ctx context.Context, batch domain.PushBatch) (*domain.PushSummary, error) {
+	if s.pushMax > 0 && batch.Len() > s.pushMax {
+		return nil, fmt.Errorf("%w: %d records, limit %d", domain.ErrPushBatchTooLarge, batch.Len(), s.pushMax)
+	}
+
+	if err := s.quotas.CheckRecords(ctx, int64(batch.Len())); err != nil {
+		return nil, err
+	}
+
+	// Reads of the push wait for busy storage like a run's
+	ctx = domain.WithStorageLoad(ctx)
+
+	start := time.Now()
+	opts := domain.RunOptions{Sources: batch.Sources()}
+	summary := &domain.PushSummary{
+		RunSummary: domain.RunSummary{
+			ID:        s.ids.NewID(),
+			Sources:   opts.Sources,
+			Parsing:   make(map[string]*domain.ParseReport),
+			StartedAt: s.clock.Now(),
+		},
+	}
+
+	meter := domain.NewCostMeter()
+	defer func() {
+		cost := meter.Snapshot()
+		summary.Cost = &cost
+		s.quotas.AddCost(ctx, domain.CostKindPush, cost)
+	}()
+	meter.AddRecords(batch.Len())
+
+	log := s.logger.WithContext(ctx)
+
+	adsData := &domain.AdData{}
+	adsData.External.Ads.Performance = batch.Ads
+	crmData := &domain.CRMData{}
+	crmData.External.CRM.Opportunities = batch.Opportunities
+
+	stageStart := time.Now()
+	processedAds, processedCRM, err := s.transformData(ctx, adsData, crmData, opts, &summary.RunSummary, nil)
+	meter.AddStage(domain.StageTransform, time.Since(stageStart))
+	if err != nil {
+		s.metrics.RecordETLJob("failed", "push", tenantOf(ctx), time.Since(start))
+		summary.CompletedAt = s.clock.Now()
+		return summary, fmt.Errorf("failed to transform pushed records: %w", err)
+	}
+	summary.AdsRecords = len(processedAds)
+	summary.CRMRecords = len(processedCRM)
+	s.quotas.AddRecords(ctx, int64(len(processedAds)+len(processedCRM)))
+
+	s.loadMutex.Lock()
+	defer s.loadMutex.Unlock()
+
+	// Records pushed again unchanged are neither stored nor counted again
+	stageStart = time.Now()
+	changes, changedAds, changedCRM, err := s.detectChanges(ctx, processedAds, processedCRM)
+	var restated []restatedOpportunity
+	if err == nil {
+		restated, err = s.findRestatements(ctx, changedCRM)
+	}
+	if err == nil {
+		err = s.appendEvents(ctx, summary.ID, domain.EventOriginPush, processedAds, processedCRM)
+	}
+	if err == nil {
+		err = s.loadData(ctx, changedAds, changedCRM, nil)
+	}
+	meter.AddStage(domain.StageLoad, time.Since(stageStart))
+	if err != nil {
+		s.metrics.RecordETLJob("failed", "push", tenantOf(ctx), time.Since(start))
+		return nil, fmt.Errorf("failed to load pushed records: %w", err)
+	}
+
+	stageStart = time.Now()
+	since, err := s.latestMetricsSince(ctx)
+	from, to := s.metricsWindow(since)
+	var updated []domain.BusinessMetrics
+	join := &domain.JoinReport{}
+	if utms := s.pushedUTMs(changedAds, changedCRM, restated); err == nil && len(utms) > 0 {
+		updated, join, err = s.recalculateUTMs(ctx, from, to, utms)
+	}
+	if err == nil && len(restated) > 0 {
+		err = s.restateMetrics(ctx, restated, since, updated)
+	}
+	meter.AddStage(domain.StageMetrics, time.Since(stageStart))
+	if err != nil {
+		s.metrics.RecordETLJob("failed", "push", tenantOf(ctx), time.Since(start))
+		return nil, fmt.Errorf("failed to update metrics: %w", err)
+	}
+	summary.Changes = changes
+	summary.UpdatedMetrics = make([]domain.MetricKey, 0, len(updated))
+	for _, metric := range updated {
+		summary.UpdatedMetrics = append(summary.UpdatedMetrics, metric.Key())
+		s.metrics.RecordBusinessMetric("incremental")
+	}
+	summary.Deferred = s.uncountedOpportunities(changedCRM, updated, join, from)
+
+	s.recordRestatements(ctx, restated, domain.RestatedByPush, "")
+	summary.Restatements = len(restated)
+
+	s.metrics.RecordETLJob("success", "push", tenantOf(ctx), time.Since(start))
+	log.WithFields(map[string]any{
+		"ads_records":     len(processedAds),
+		"crm_records":     len(processedCRM),
+		"updated_metrics": len(updated),
+		"deferred":        summary.Deferred,
+	}).Info("Pushed records applied")
+
+	summary.CompletedAt = s.clock.Now()
+	return summary, nil
+}
+
+// returns the UTMs whose metrics pushed records change: those of the ads,
+// of the opportunities and the touches credited with their revenue, and of
+// both versions of restated opportunities
+func (s *ETLService) pushedUTMs(ads []domain.ProcessedAdData, opportunities []domain.ProcessedOpportunity, restated []restatedOpportunity) map[domain.UTMKey]bool {
+	utms := make(map[domain.UTMKey]bool)
+	for _, ad := range ads {
+		utms[domain.UTMKey{Campaign: ad.UTMCampaign, Source: ad.UTMSource, Medium: ad.UTMMedium}] = true
+	}
+	for _, opp := range opportunities {
+		utms[domain.UTMKey{Campaign: opp.UTMCampaign, Source: opp.UTMSource, Medium: opp.UTMMedium}] = true
+		for utm := range s.attribution.Credits(opp, nil) {
+			utms[utm] = true
+		}
+	}
+	for _, r := range restated {
+		for utm := range r.utms(s.attribution) {
+			utms[utm] = true
+		}
+	}
+	return utms
+}
+
+// returns how many of the opportunities no recalculated metric counts:
+// those recognized before the window, and those of UTMs without a metric
+// row, e.g. without ads in the window. The next run counting them would
+// have to cover their UTMs' ads.
+func (s *ETLService) uncountedOpportunities(opportunities []domain.ProcessedOpportunity, metrics []domain.BusinessMetrics, join *domain.JoinReport, from time.Time) int {
+	counted := make(map[domain.UTMKey]bool, len(metrics))
+	for _, metric := range metrics {
+		counted[metric.Key().UTM()] = true
+	}
+	// Opportunities of fuzzy matched UTMs are counted under their ad's
+	matched := make(map[domain.UTMKey]domain.UTMKey, len(join.FuzzyMatches))
+	for _, match := range join.FuzzyMatches {
+		matched[domain.UTMKey{Campaign: match.CRM.UTMCampaign, Source: match.CRM.UTMSource, Medium: match.CRM.UTMMedium}] = domain.UTMKey{Campaign: match.Ads.UTMCampaign, Source: match.Ads.UTMSource, Medium: match.Ads.UTMMedium}
+	}
+
+	uncounted := 0
+	for _, opp := range opportunities {
+		utm := domain.UTMKey{Campaign: opp.UTMCampaign, Source: opp.UTMSource, Medium: opp.UTMMedium}
+		if ad, ok := matched[utm]; ok {
+			utm = ad
+		}
+		if opp.RecognizedAt(s.recognition).Before(from) || !counted[utm] {
+			uncounted++
+		}
+	}
+	return uncounted
+}
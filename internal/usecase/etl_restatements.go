@@ -0,0 +1,216 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"etlgo/internal/domain"
+)
+
+// an opportunity ingested again with a change that affects metrics
+type restatedOpportunity struct {
+	restatement domain.Restatement
+	previous    domain.ProcessedOpportunity
+	current     domain.ProcessedOpportunity
+}
+
+// returns the UTMs whose metrics counted either version: their own UTMs and
+// the touches credited with their revenue
+func (r restatedOpportunity) utms(attribution domain.AttributionModel) map[domain.UTMKey]bool {
+	utms := make(map[domain.UTMKey]bool)
+	for _, opp := range []domain.ProcessedOpportunity{r.previous, r.current} {
+		utms[domain.UTMKey{Campaign: opp.UTMCampaign, Source: opp.UTMSource, Medium: opp.UTMMedium}] = true
+		for utm := range attribution.Credits(opp, nil) {
+			utms[utm] = true
+		}
+	}
+	return utms
+}
+
+// compares incoming opportunities with the stored ones of the same ID and
+// returns those whose stage, amount, date or UTMs changed
+func (s *ETLService) findRestatements(ctx context.Context, opportunities []domain.ProcessedOpportunity) ([]restatedOpportunity, error) {
+	ids := make([]string, 0, len(opportunities))
+	for _, opp := range opportunities {
+		ids = append(ids, opp.OpportunityID)
+	}
+	stored, err := s.crmRepo.GetByIDs(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stored opportunities: %w", err)
+	}
+	if len(stored) == 0 {
+		return nil, nil
+	}
+
+	previous := make(map[string]domain.ProcessedOpportunity, len(stored))
+	for _, opp := range stored {
+		previous[opp.OpportunityID] = opp
+	}
+
+	var restated []restatedOpportunity
+	for _, opp := range opportunities {
+		prior, ok := previous[opp.OpportunityID]
+		if !ok {
+			continue
+		}
+		restatement, changed := domain.NewRestatement(prior, opp)
+		if changed {
+			restated = append(restated, restatedOpportunity{restatement: restatement, previous: prior, current: opp})
+		}
+		// A later row with the same ID restates this one
+		previous[opp.OpportunityID] = opp
+	}
+	return restated, nil
+}
+
+// records the restated metric keys on each restatement. Metrics in the
+// run's window were just recalculated; when either version of an
+// opportunity predates the window, the metrics of its UTMs are recalculated
+// over the whole window from its day and replace the UTMs' stored rows of
+// those days, so rows of the earlier days aren't counted beside the
+// window's.
+func (s *ETLService) restateMetrics(ctx context.Context, restated []restatedOpportunity, since *time.Time, calculated []domain.BusinessMetrics) error {
+	from, to := s.metricsWindow(since)
+
+	earliest := from
+	affected := make(map[domain.UTMKey]bool)
+	for _, r := range restated {
+		oldest := r.previous.RecognizedAt(s.recognition)
+		if current := r.current.RecognizedAt(s.recognition); current.Before(oldest) {
+			oldest = current
+		}
+		if !oldest.Before(from) {
+			continue
+		}
+		if day := oldest.Truncate(24 * time.Hour); day.Before(earliest) {
+			earliest = day
+		}
+		for utm := range r.utms(s.attribution) {
+			affected[utm] = true
+		}
+	}
+
+	if len(affected) > 0 {
+		recalculated, _, err := s.recalculateUTMs(ctx, earliest, to, affected)
+		if err != nil {
+			return err
+		}
+		calculated = append(slices.DeleteFunc(slices.Clone(calculated), func(metric domain.BusinessMetrics) bool {
+			return affected[metric.Key().UTM()]
+		}), recalculated...)
+	}
+
+	for i := range restated {
+		restated[i].restatement.Metrics = restatedMetricKeys(restated[i], s.attribution, calculated)
+	}
+	return nil
+}
+
+// returns the keys of the metrics counting the UTMs either version of a
+// restated opportunity counted towards
+func restatedMetricKeys(r restatedOpportunity, attribution domain.AttributionModel, metrics []domain.BusinessMetrics) []domain.MetricKey {
+	utms := r.utms(attribution)
+	seen := make(map[domain.MetricKey]bool)
+	keys := []domain.MetricKey{}
+	for _, metric := range metrics {
+		key := metric.Key()
+		if utms[key.UTM()] && !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// recalculates the metrics of the UTMs over the range and replaces their
+// stored rows of its days, so rows calculated over part of the range
+// aren't counted beside the recalculated ones. UTMs whose opportunities
+// are fuzzy matched to an ad's are recalculated with the ad's, and added
+// to utms. Returns the recalculated metrics and how the range joined.
+func (s *ETLService) recalculateUTMs(ctx context.Context, from, to time.Time, utms map[domain.UTMKey]bool) ([]domain.BusinessMetrics, *domain.JoinReport, error) {
+	recalculated, join, err := s.calculateMetricsBetween(ctx, from, to)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Opportunities of fuzzy matched UTMs are counted under their ad's
+	for _, match := range join.FuzzyMatches {
+		if utms[domain.UTMKey{Campaign: match.CRM.UTMCampaign, Source: match.CRM.UTMSource, Medium: match.CRM.UTMMedium}] {
+			utms[domain.UTMKey{Campaign: match.Ads.UTMCampaign, Source: match.Ads.UTMSource, Medium: match.Ads.UTMMedium}] = true
+		}
+	}
+	isAffected := func(metric domain.BusinessMetrics) bool {
+		return utms[metric.Key().UTM()]
+	}
+	recalculated = slices.DeleteFunc(recalculated, func(metric domain.BusinessMetrics) bool {
+		return !isAffected(metric)
+	})
+	if err := s.replaceUTMMetrics(ctx, from, to, isAffected, recalculated); err != nil {
+		return nil, nil, err
+	}
+	return recalculated, join, nil
+}
+
+// replaces the stored ETL metrics dated in the range that match with the
+// recalculated ones, keeping the others. Promotions replace the same rows,
+// so they wait for the replacement.
+func (s *ETLService) replaceUTMMetrics(ctx context.Context, from, to time.Time, match func(domain.BusinessMetrics) bool, recalculated []domain.BusinessMetrics) error {
+	s.datasetMutex.Lock()
+	defer s.datasetMutex.Unlock()
+
+	stored, err := readMetrics(ctx, s.metricsRepo, domain.MetricsFilter{From: &from, To: &to})
+	if err != nil {
+		return fmt.Errorf("failed to read metrics to recalculate: %w", err)
+	}
+	kept := slices.DeleteFunc(stored, func(metric domain.BusinessMetrics) bool {
+		return metric.Producer != "" || match(metric)
+	})
+	if _, err := s.metricsRepo.Replace(ctx, from, to, append(kept, recalculated...)); err != nil {
+		return fmt.Errorf("failed to store recalculated metrics: %w", err)
+	}
+	return nil
+}
+
+// stores the restatements in the log. Failing to store them does not fail
+// the ingestion that already restated the metrics.
+func (s *ETLService) recordRestatements(ctx context.Context, restated []restatedOpportunity, origin, runID string) {
+	if len(restated) == 0 {
+		return
+	}
+
+	now := s.clock.Now().UTC()
+	restatements := make([]domain.Restatement, 0, len(restated))
+	for _, r := range restated {
+		restatement := r.restatement
+		restatement.ID = s.ids.NewID()
+		restatement.Origin = origin
+		restatement.RunID = runID
+		restatement.RestatedAt = now
+		if restatement.Metrics == nil {
+			restatement.Metrics = []domain.MetricKey{}
+		}
+		restatements = append(restatements, restatement)
+		s.metrics.RecordBusinessMetric("restatement")
+	}
+
+	log := s.logger.WithContext(ctx)
+	if err := s.restated.Store(ctx, restatements); err != nil {
+		log.WithError(err).Error("Failed to store restatements")
+		return
+	}
+	log.WithFields(map[string]any{
+		"origin":       origin,
+		"restatements": len(restatements),
+	}).Info("Restated opportunities")
+}
+
+// returns logged restatements, most recent first, optionally for one
+// opportunity
+func (s *ETLService) ListRestatements(ctx context.Context, opportunityID string, limit int) ([]domain.Restatement, error) {
+	restatements, err := s.restated.List(ctx, opportunityID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list restatements: %w", err)
+	}
+	return restatements, nil
+}
@@ -0,0 +1,76 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"etlgo/internal/domain"
+)
+
+// returns the opportunities metrics over the range count: the ones created
+// in it and, when revenue is recognized on close, the closed won ones by
+// their close date instead
+func (s *ETLService) recognizedOpportunities(ctx context.Context, from, to time.Time) ([]domain.ProcessedOpportunity, error) {
+	opportunities, err := s.crmRepo.GetByDateRange(ctx, from, to)
+	if err != nil || s.recognition != domain.RecognizeOnClose {
+		return opportunities, err
+	}
+
+	won, err := s.crmRepo.GetByStage(ctx, domain.StageClosedWon, from.Add(-domain.RevenueCloseLookback), to)
+	if err != nil {
+		return nil, err
+	}
+	recognized := make([]domain.ProcessedOpportunity, 0, len(opportunities))
+	for _, opp := range opportunities {
+		if !opp.IsClosedWon() {
+			recognized = append(recognized, opp)
+		}
+	}
+	// Whole days, like the opportunities created in the range
+	first, last := from.Format("2006-01-02"), to.Format("2006-01-02")
+	for _, opp := range won {
+		if day := opp.RecognizedAt(s.recognition).Format("2006-01-02"); day >= first && day <= last {
+			recognized = append(recognized, opp)
+		}
+	}
+	return recognized, nil
+}
+
+// GetRevenue returns closed won revenue over time on the date it is
+// recognized on, the configured recognition when the query has none.
+// Callers scoped to channels or campaigns see no revenue, opportunities
+// only carry UTMs.
+func (s *ETLService) GetRevenue(ctx context.Context, query domain.RevenueQuery) (*domain.RevenueReport, error) {
+	if query.Recognition == "" {
+		query.Recognition = s.recognition
+	}
+	if err := query.Validate(); err != nil {
+		return nil, err
+	}
+
+	from := query.From
+	if query.Recognition == domain.RecognizeOnClose {
+		from = from.Add(-domain.RevenueCloseLookback)
+	}
+	won, err := s.crmRepo.GetByStage(ctx, domain.StageClosedWon, from, query.To)
+	if err != nil {
+		s.logger.WithContext(ctx).WithError(err).Error("Failed to get revenue")
+		return nil, fmt.Errorf("failed to get revenue: %w", err)
+	}
+
+	scope := domain.MetricsScopeFromContext(ctx)
+	visible := make([]domain.ProcessedOpportunity, 0, len(won))
+	for _, opp := range won {
+		allowed := true
+		for dimension := range scope {
+			allowed = allowed && scope.AllowsValue(dimension, opp.DimensionValue(dimension))
+		}
+		if allowed {
+			visible = append(visible, opp)
+		}
+	}
+
+	s.metrics.RecordBusinessMetric("revenue_query")
+	return domain.NewRevenueReport(query, visible), nil
+}
@@ -1,8 +1,14 @@
 package usecase
 
 import (
+	"cmp"
 	"context"
+	"encoding/json"
 	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -12,147 +18,842 @@ import (
 )
 
 type ETLService struct {
-	adRepo      domain.AdRepository
-	crmRepo     domain.CRMRepository
-	metricsRepo domain.MetricsRepository
-	apiClient   domain.ExternalAPIClient
-	logger      *logger.Logger
-	metrics     *metrics.Metrics
-	workerPool  int
-	batchSize   int
+	adRepo       domain.AdRepository
+	crmRepo      domain.CRMRepository
+	metricsRepo  domain.MetricsRepository
+	quarantine   domain.QuarantineRepository
+	runs         domain.RunRepository
+	checkpoints  domain.CheckpointRepository
+	objects      domain.SourceObjectRepository
+	restated     domain.RestatementRepository
+	events       domain.EventLogRepository
+	sessions     domain.AnalyticsRepository
+	keywords     domain.KeywordRepository
+	holds        domain.ExportHoldRepository
+	flags        domain.FeatureFlagProvider
+	quotas       *QuotaService
+	notifier     *NotificationService
+	models       *ModelService
+	apiClient    domain.ExternalAPIClient
+	analytics    domain.AnalyticsClient
+	keywordFeed  domain.KeywordClient
+	fileDecoder  domain.FileDecoder
+	logger       *logger.Logger
+	metrics      *metrics.Metrics
+	workerPool   int
+	batchSize    int
+	pushMax      int
+	parsePolicy  domain.ParsePolicy
+	valuePolicy  domain.ValuePolicies
+	datePolicy   domain.DateSkewPolicy
+	attribution  domain.AttributionModel
+	recognition  domain.RevenueRecognition
+	utmMatcher   domain.UTMMatcher
+	crmOnlyKeys  bool
+	funnel       domain.Funnel
+	probability  domain.StageProbabilities
+	fxRates      domain.FXRateRepository
+	baseCurrency string
+	spendPolicy  domain.SpendAnomalyPolicy
+	actionPolicy domain.ActionPolicy
+	gapPolicy    string
+
+	// version of the transform rules, recorded on the metrics calculated
+	// with them
+	transformVersion string
+
+	fingerprinter domain.Fingerprinter
+	shadows       domain.ShadowRepository
+	datasets      domain.DatasetRepository
+	certifier     domain.RunCertifier
+	clock         domain.Clock
+	ids           domain.IDGenerator
+
+	// serializes the loads of runs, pushes and rollbacks with the metrics
+	// they calculate, so none reads stored records another is replacing
+	loadMutex sync.Mutex
+	// serializes promotions and demotions of metric datasets
+	datasetMutex sync.Mutex
+	// guards the parse policy, which can change at runtime
+	policyMutex sync.RWMutex
+
+	// IDs of the suggested actions already notified with the day they cover
+	notifiedActions map[string]time.Time
+	actionsMutex    sync.Mutex
 }
 
 func NewETLService(
 	adRepo domain.AdRepository,
 	crmRepo domain.CRMRepository,
 	metricsRepo domain.MetricsRepository,
+	quarantine domain.QuarantineRepository,
+	runs domain.RunRepository,
+	checkpoints domain.CheckpointRepository,
+	objects domain.SourceObjectRepository,
+	restated domain.RestatementRepository,
+	events domain.EventLogRepository,
+	sessions domain.AnalyticsRepository,
+	keywords domain.KeywordRepository,
+	holds domain.ExportHoldRepository,
+	flags domain.FeatureFlagProvider,
+	quotas *QuotaService,
+	notifier *NotificationService,
+	models *ModelService,
 	apiClient domain.ExternalAPIClient,
+	analytics domain.AnalyticsClient,
+	keywordFeed domain.KeywordClient,
+	fileDecoder domain.FileDecoder,
 	logger *logger.Logger,
 	metrics *metrics.Metrics,
-	workerPool, batchSize int,
+	workerPool, batchSize, pushMax int,
+	parsePolicy domain.ParsePolicy,
+	valuePolicy domain.ValuePolicies,
+	datePolicy domain.DateSkewPolicy,
+	attribution domain.AttributionModel,
+	recognition domain.RevenueRecognition,
+	utmMatcher domain.UTMMatcher,
+	crmOnlyKeys bool,
+	funnel domain.Funnel,
+	probability domain.StageProbabilities,
+	fxRates domain.FXRateRepository,
+	baseCurrency string,
+	spendPolicy domain.SpendAnomalyPolicy,
+	actionPolicy domain.ActionPolicy,
+	gapPolicy string,
+	fingerprinter domain.Fingerprinter,
+	shadows domain.ShadowRepository,
+	datasets domain.DatasetRepository,
+	certifier domain.RunCertifier,
+	clock domain.Clock,
+	ids domain.IDGenerator,
 ) *ETLService {
-	return &ETLService{
-		adRepo:      adRepo,
-		crmRepo:     crmRepo,
-		metricsRepo: metricsRepo,
-		apiClient:   apiClient,
-		logger:      logger,
-		metrics:     metrics,
-		workerPool:  workerPool,
-		batchSize:   batchSize,
+	service := &ETLService{
+		adRepo:       adRepo,
+		crmRepo:      crmRepo,
+		metricsRepo:  metricsRepo,
+		quarantine:   quarantine,
+		runs:         runs,
+		checkpoints:  checkpoints,
+		objects:      objects,
+		restated:     restated,
+		events:       events,
+		sessions:     sessions,
+		keywords:     keywords,
+		holds:        holds,
+		flags:        flags,
+		quotas:       quotas,
+		notifier:     notifier,
+		models:       models,
+		apiClient:    apiClient,
+		analytics:    analytics,
+		keywordFeed:  keywordFeed,
+		fileDecoder:  fileDecoder,
+		logger:       logger,
+		metrics:      metrics,
+		workerPool:   workerPool,
+		batchSize:    batchSize,
+		pushMax:      pushMax,
+		parsePolicy:  parsePolicy,
+		valuePolicy:  valuePolicy,
+		datePolicy:   datePolicy,
+		attribution:  attribution,
+		recognition:  recognition,
+		utmMatcher:   utmMatcher,
+		crmOnlyKeys:  crmOnlyKeys,
+		funnel:       funnel,
+		probability:  probability,
+		fxRates:      fxRates,
+		baseCurrency: baseCurrency,
+		spendPolicy:  spendPolicy,
+		actionPolicy: actionPolicy,
+		gapPolicy:    gapPolicy,
+
+		fingerprinter: fingerprinter,
+		shadows:       shadows,
+		datasets:      datasets,
+		certifier:     certifier,
+		clock:         clock,
+		ids:           ids,
+
+		notifiedActions: make(map[string]time.Time),
 	}
+	service.transformVersion = service.transformConfig().Version()
+	return service
+}
+
+// the rules the active configuration calculates metrics with
+func (s *ETLService) transformConfig() domain.TransformConfig {
+	config := domain.TransformConfig{
+		Attribution:       s.attribution,
+		Recognition:       s.recognition,
+		FuzzyUTMThreshold: s.utmMatcher.Threshold,
+		CRMOnlyKeys:       s.crmOnlyKeys,
+		Funnel:            s.funnel,
+		Probabilities:     s.probability,
+		BaseCurrency:      s.baseCurrency,
+		ValuePolicies:     s.valuePolicy,
+	}
+	// accepting skewed dates leaves the rows as they are
+	if s.datePolicy.Action != domain.DateSkewAccept {
+		config.DateSkew = &s.datePolicy
+	}
+	return config
 }
 
 // Executes the complete ETL pipeline
-func (s *ETLService) RunETL(ctx context.Context, since *time.Time) error {
-	start := time.Now()
+func (s *ETLService) RunETL(ctx context.Context, since *time.Time) (*domain.RunSummary, error) {
+	return s.RunETLWithOptions(ctx, domain.RunOptions{Since: since})
+}
+
+// Executes the ETL pipeline with the given run options. The summary is
+// returned even when the run fails on parse errors so callers can report them.
+// Every admitted run is recorded and notified, whether or not it succeeds.
+func (s *ETLService) RunETLWithOptions(ctx context.Context, opts domain.RunOptions) (*domain.RunSummary, error) {
+	summary := &domain.RunSummary{
+		ID:        s.ids.NewID(),
+		Pipeline:  opts.Pipeline,
+		Tags:      opts.Tags,
+		Since:     opts.Since,
+		Sources:   opts.Sources,
+		Parsing:   make(map[string]*domain.ParseReport),
+		StartedAt: s.clock.Now(),
+	}
+	domain.ProgressTrackerFromContext(ctx).Begin(summary.ID)
+	sources := opts.Sources
+	if len(sources) == 0 {
+		sources = []string{domain.SourceAds, domain.SourceCRM}
+		if s.analytics != nil {
+			sources = append(sources, domain.SourceGA4)
+		}
+		if s.keywordFeed != nil {
+			sources = append(sources, domain.SourceKeywords)
+		}
+	}
+	if err := s.quotas.CheckRun(ctx, sources); err != nil {
+		return nil, err
+	}
+
 	s.metrics.IncETLJobsInProgress()
 	defer s.metrics.DecETLJobsInProgress()
 
+	// Account the run's cost whether or not it succeeds
+	meter := domain.NewCostMeter()
+	result, err := s.runETL(domain.WithStorageLoad(domain.WithCostMeter(ctx, meter)), opts, summary, meter)
+	cost := meter.Snapshot()
+	summary.Cost = &cost
+	s.quotas.AddCost(ctx, domain.CostKindRun, cost)
+
+	s.finishRun(ctx, summary, err)
+	return result, err
+}
+
+// Returns a recorded run by ID
+func (s *ETLService) GetRun(ctx context.Context, id string) (*domain.RunRecord, error) {
+	return s.runs.Get(ctx, id)
+}
+
+// Returns the recorded runs matching the filter, most recently completed
+// first
+func (s *ETLService) ListRuns(ctx context.Context, filter domain.RunFilter) ([]domain.RunRecord, error) {
+	runs, err := s.runs.List(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list runs: %w", err)
+	}
+	return runs, nil
+}
+
+// Returns the certification of a completed run
+func (s *ETLService) GetRunCertification(ctx context.Context, id string) (*domain.RunCertification, error) {
+	run, err := s.runs.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if run.Certification == nil {
+		return nil, domain.Errorf(domain.ErrNotFound, "run %s has no certification, only completed runs are certified", id)
+	}
+	return run.Certification, nil
+}
+
+// Returns how the ads and opportunities in the metrics window of a completed
+// run joined
+func (s *ETLService) GetRunJoinReport(ctx context.Context, id string) (*domain.JoinReport, error) {
+	run, err := s.runs.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if run.Join == nil {
+		return nil, domain.Errorf(domain.ErrNotFound, "run %s has no join report, only runs that calculated metrics report one", id)
+	}
+	return run.Join, nil
+}
+
+// checksums the records the run extracted, per source, and the metrics it
+// calculated
+func runChecksums(opts domain.RunOptions, ads []domain.ProcessedAdData, opportunities []domain.ProcessedOpportunity, sessions []domain.ProcessedAnalyticsRow, keywords []domain.ProcessedKeywordData, calculated []domain.BusinessMetrics) map[string]domain.DataChecksum {
+	checksums := map[string]domain.DataChecksum{
+		domain.DatasetMetrics: domain.ChecksumOf(calculated),
+	}
+	if opts.IncludesSource(domain.SourceAds) {
+		checksums[domain.SourceAds] = domain.ChecksumOf(ads)
+	}
+	if opts.IncludesSource(domain.SourceCRM) {
+		checksums[domain.SourceCRM] = domain.ChecksumOf(opportunities)
+	}
+	if len(sessions) > 0 {
+		checksums[domain.SourceGA4] = domain.ChecksumOf(sessions)
+	}
+	if len(keywords) > 0 {
+		checksums[domain.SourceKeywords] = domain.ChecksumOf(keywords)
+	}
+	return checksums
+}
+
+// Compares run b against run a. Both runs must have extracted the same
+// window, otherwise their differences say nothing about the transform.
+// Scoped callers only see the channels in their scope.
+func (s *ETLService) CompareRuns(ctx context.Context, a, b string) (*domain.RunComparison, error) {
+	runA, err := s.runs.Get(ctx, a)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", err, a)
+	}
+	runB, err := s.runs.Get(ctx, b)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", err, b)
+	}
+	if !domain.SameRunWindow(runA.RunSummary, runB.RunSummary) {
+		return nil, domain.Errorf(domain.ErrValidation, "runs %s and %s cover different windows", a, b)
+	}
+	comparison := domain.CompareRuns(*runA, *runB).InScope(domain.MetricsScopeFromContext(ctx))
+	return &comparison, nil
+}
+
+// records a finished run and notifies the channels subscribed to its outcome
+// and, after successful runs, to newly suggested actions. Successful runs
+// materialize the derived models first.
+func (s *ETLService) finishRun(ctx context.Context, summary *domain.RunSummary, runErr error) {
+	if summary.CompletedAt.IsZero() {
+		summary.CompletedAt = s.clock.Now()
+	}
+	record := domain.RunRecord{
+		RunSummary: *summary,
+		Tenant:     tenantOf(ctx),
+		Status:     domain.RunCompleted,
+		Anomalies:  summary.Anomalies(),
+	}
+	if runErr != nil {
+		record.Status = domain.RunFailed
+		record.Error = runErr.Error()
+	} else {
+		certification, err := s.certifier.Certify(ctx, record)
+		if err != nil {
+			s.logger.WithContext(ctx).WithError(err).WithField("run_id", record.ID).Error("Failed to certify run")
+		}
+		record.Certification = certification
+	}
+
+	if err := s.runs.Save(ctx, record); err != nil {
+		s.logger.WithContext(ctx).WithError(err).WithField("run_id", record.ID).Error("Failed to record run")
+	}
+	if runErr == nil {
+		s.models.Materialize(ctx, record.ID)
+	}
+	s.notifier.Notify(ctx, record)
+	if runErr == nil {
+		s.notifyActions(ctx)
+	}
+}
+
+// extracts, transforms and loads the run's data and calculates its metrics
+func (s *ETLService) runETL(ctx context.Context, opts domain.RunOptions, summary *domain.RunSummary, meter *domain.CostMeter) (*domain.RunSummary, error) {
+	start := summary.StartedAt
+
 	log := s.logger.WithContext(ctx)
 	log.Info("Starting ETL pipeline")
 
-	// Extract data from external APIs
-	adsData, crmData, err := s.extractData(ctx)
+	// Resume the checkpointed sources from their last extraction
+	opts, err := s.resumeFromCheckpoints(ctx, opts)
 	if err != nil {
-		s.metrics.RecordETLJob("failed", "extract", time.Since(start))
-		return fmt.Errorf("failed to extract data: %w", err)
+		s.metrics.RecordETLJob("failed", "extract", tenantOf(ctx), time.Since(start))
+		return nil, err
 	}
+	summary.Watermarks = opts.Watermarks
+	since := opts.MetricsSince()
+
+	progress := domain.ProgressTrackerFromContext(ctx)
 
-	// Transform data
-	processedAds, processedCRM, err := s.transformData(ctx, adsData, crmData, since)
+	// Open the upstream connections the extraction reuses
+	progress.Enter(domain.StagePrewarm)
+	domain.Beat(ctx)
+	stageStart := time.Now()
+	s.prewarm(ctx, opts)
+	meter.AddStage(domain.StagePrewarm, time.Since(stageStart))
+
+	// Extract data from external APIs
+	progress.Enter(domain.StageExtract)
+	domain.Beat(ctx)
+	stageStart = time.Now()
+	extractedAt := s.clock.Now().UTC()
+	adsData, crmData, analyticsData, keywordData, err := s.extractData(ctx, opts)
+	meter.AddStage(domain.StageExtract, time.Since(stageStart))
 	if err != nil {
-		s.metrics.RecordETLJob("failed", "transform", time.Since(start))
-		return fmt.Errorf("failed to transform data: %w", err)
+		s.metrics.RecordETLJob("failed", "extract", tenantOf(ctx), time.Since(start))
+		return nil, fmt.Errorf("failed to extract data: %w", err)
+	}
+	extracted := len(adsData.External.Ads.Performance) + len(adsData.Rejected) +
+		len(crmData.External.CRM.Opportunities) + len(crmData.Rejected) +
+		len(analyticsData.Rows) + len(analyticsData.Rejected) +
+		len(keywordData.Rows) + len(keywordData.Rejected)
+	meter.AddRecords(extracted)
+	progress.AddRecords(extracted)
+	objects := append(slices.Clone(adsData.Objects), crmData.Objects...)
+	summary.Objects = len(objects)
+
+	// Transform data, keeping the decoded rows for the shadow configs
+	progress.Enter(domain.StageTransform)
+	domain.Beat(ctx)
+	stageStart = time.Now()
+	shadows := s.shadowConfigs(ctx)
+	var decoded *decodedRecords
+	if len(shadows) > 0 {
+		decoded = &decodedRecords{}
+	}
+	processedAds, processedCRM, err := s.transformData(ctx, adsData, crmData, opts, summary, decoded)
+	var processedSessions []domain.ProcessedAnalyticsRow
+	if err == nil {
+		processedSessions, err = s.transformAnalyticsData(ctx, analyticsData, opts, summary)
+	}
+	var processedKeywords []domain.ProcessedKeywordData
+	if err == nil {
+		processedKeywords, err = s.transformKeywordData(ctx, keywordData, opts, summary)
+	}
+	meter.AddStage(domain.StageTransform, time.Since(stageStart))
+	if err != nil {
+		s.metrics.RecordETLJob("failed", "transform", tenantOf(ctx), time.Since(start))
+		summary.CompletedAt = s.clock.Now()
+		return summary, fmt.Errorf("failed to transform data: %w", err)
+	}
+	summary.AdsRecords = len(processedAds)
+	summary.CRMRecords = len(processedCRM)
+	summary.SessionRecords = len(processedSessions)
+	summary.KeywordRecords = len(processedKeywords)
+	s.quotas.AddRecords(ctx, int64(len(processedAds)+len(processedCRM)+len(processedSessions)+len(processedKeywords)))
+
+	// Evaluate the shadow configs on the same rows while the active results
+	// load; the run returns once they are stored
+	if len(shadows) > 0 {
+		var wg sync.WaitGroup
+		defer wg.Wait()
+		input := newShadowInput(summary, opts, decoded, processedAds, processedCRM, processedSessions)
+		wg.Go(func() { s.evaluateShadows(ctx, shadows, input) })
 	}
 
-	// Load data into repositories
-	if err := s.loadData(ctx, processedAds, processedCRM); err != nil {
-		s.metrics.RecordETLJob("failed", "load", time.Since(start))
-		return fmt.Errorf("failed to load data: %w", err)
+	// Log the ingested versions, then load the new and changed records into
+	// repositories, overwriting restated opportunities. Pushes and rollbacks
+	// wait until the metrics of the load are calculated. Replaced days, from
+	// ReplaceFrom up to the run, get all their ads, and are only emptied
+	// when the run allows it.
+	progress.Enter(domain.StageLoad)
+	domain.Beat(ctx)
+	stageStart = time.Now()
+	replaceFrom := opts.ReplaceFrom
+	if !opts.IncludesSource(domain.SourceAds) {
+		replaceFrom = nil
+	}
+	s.loadMutex.Lock()
+	changes, changedAds, changedCRM, err := s.detectChanges(ctx, processedAds, processedCRM)
+	if replaceFrom != nil {
+		changedAds = processedAds
+	}
+	if err == nil && replaceFrom != nil && len(processedAds) == 0 && !opts.AllowEmptyReplace {
+		err = domain.ErrEmptyReplacement
+	}
+	var restated []restatedOpportunity
+	if err == nil {
+		restated, err = s.findRestatements(ctx, changedCRM)
+	}
+	if err == nil {
+		err = s.appendEvents(ctx, summary.ID, domain.EventOriginRun, processedAds, processedCRM)
+	}
+	if err == nil {
+		err = s.loadData(ctx, changedAds, changedCRM, replaceFrom)
+	}
+	if err == nil && s.extractsAnalytics(opts) {
+		from, to := s.analyticsWindow(opts.Since)
+		if err = s.sessions.Replace(ctx, from, to, processedSessions); err != nil {
+			err = fmt.Errorf("failed to store analytics data: %w", err)
+		}
+	}
+	if err == nil && len(processedKeywords) > 0 {
+		if err = s.keywords.Store(ctx, processedKeywords); err != nil {
+			err = fmt.Errorf("failed to store keyword data: %w", err)
+		}
+	}
+	if err == nil {
+		summary.Gaps, err = s.handleGaps(ctx, opts)
+	}
+	meter.AddStage(domain.StageLoad, time.Since(stageStart))
+	if err != nil {
+		s.loadMutex.Unlock()
+		s.metrics.RecordETLJob("failed", "load", tenantOf(ctx), time.Since(start))
+		return nil, fmt.Errorf("failed to load data: %w", err)
 	}
 
 	// Calculate and store business metrics
-	if err := s.calculateMetrics(ctx, since); err != nil {
-		s.metrics.RecordETLJob("failed", "metrics", time.Since(start))
-		return fmt.Errorf("failed to calculate metrics: %w", err)
+	progress.Enter(domain.StageMetrics)
+	domain.Beat(ctx)
+	stageStart = time.Now()
+	calculated, join, err := s.calculateMetrics(ctx, since)
+	if err == nil && len(restated) > 0 {
+		err = s.restateMetrics(ctx, restated, since, calculated)
+	}
+	s.loadMutex.Unlock()
+	meter.AddStage(domain.StageMetrics, time.Since(stageStart))
+	if err != nil {
+		s.metrics.RecordETLJob("failed", "metrics", tenantOf(ctx), time.Since(start))
+		return nil, fmt.Errorf("failed to calculate metrics: %w", err)
 	}
+	s.recordRestatements(ctx, restated, domain.RestatedByRun, summary.ID)
+	summary.Changes = changes
+	summary.Channels = domain.ChannelTotalsOf(calculated)
+	summary.Checksums = runChecksums(opts, processedAds, processedCRM, processedSessions, processedKeywords, calculated)
+	summary.Join = join
+	summary.Restatements = len(restated)
+	summary.ReplacedFrom = replaceFrom
+
+	// Hold the exports of days whose spend deviates from their baseline
+	summary.ExportHolds, err = s.holdAnomalousSpend(ctx, processedAds, summary.ID)
+	if err != nil {
+		s.metrics.RecordETLJob("failed", "metrics", tenantOf(ctx), time.Since(start))
+		return nil, err
+	}
+
+	s.saveCheckpoints(ctx, opts, summary.ID, extractedAt)
+	s.saveSourceObjects(ctx, objects, summary.ID)
 
 	duration := time.Since(start)
-	s.metrics.RecordETLJob("success", "complete", duration)
+	s.metrics.RecordETLJob("success", "complete", tenantOf(ctx), duration)
 
 	log.WithFields(map[string]any{
 		"duration":     duration,
 		"ads_records":  len(processedAds),
 		"crm_records":  len(processedCRM),
 		"since_filter": since != nil,
+		"incremental":  len(opts.Watermarks) > 0,
+		"sources":      opts.Sources,
 	}).Info("ETL pipeline completed successfully")
 
-	return nil
+	summary.CompletedAt = s.clock.Now()
+	return summary, nil
+}
+
+// sets the days the checkpointed sources resume extraction from. Runs with
+// a window of their own, or forced to extract everything, ignore the
+// checkpoints, and ads resume no later than the first day the run replaces.
+// Runs extracting from their own client, e.g. uploaded files, do too, and
+// sources whose client tracks their extraction itself are not resumed.
+func (s *ETLService) resumeFromCheckpoints(ctx context.Context, opts domain.RunOptions) (domain.RunOptions, error) {
+	if opts.Since != nil || opts.ForceFull || opts.Extractor != nil {
+		return opts, nil
+	}
+
+	watermarks := make(map[string]time.Time)
+	for _, source := range domain.CheckpointedSources {
+		if !opts.IncludesSource(source) || domain.TracksExtraction(s.extractor(opts), source) {
+			continue
+		}
+		checkpoint, err := s.checkpoints.Get(ctx, tenantOf(ctx), source)
+		if err != nil {
+			return opts, fmt.Errorf("failed to read %s checkpoint: %w", source, err)
+		}
+		if checkpoint == nil {
+			continue
+		}
+		watermark := checkpoint.Watermark()
+		if source == domain.SourceAds && opts.ReplaceFrom != nil && opts.ReplaceFrom.Before(watermark) {
+			watermark = *opts.ReplaceFrom
+		}
+		watermarks[source] = watermark
+	}
+	if len(watermarks) > 0 {
+		opts.Watermarks = watermarks
+	}
+	return opts, nil
+}
+
+// RunWindow returns the first day a run with the options extracts from once
+// it resumes from the checkpoints, or nil when it reads the whole history
+// of a source. Sources whose client tracks their extraction resume on their
+// own and do not widen the window.
+func (s *ETLService) RunWindow(ctx context.Context, opts domain.RunOptions) (*time.Time, error) {
+	opts, err := s.resumeFromCheckpoints(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Since != nil || opts.ForceFull || opts.Extractor != nil {
+		return opts.Since, nil
+	}
+
+	var since *time.Time
+	for _, source := range domain.CheckpointedSources {
+		if !opts.IncludesSource(source) || domain.TracksExtraction(s.extractor(opts), source) {
+			continue
+		}
+		watermark := opts.SinceFor(source)
+		if watermark == nil {
+			return nil, nil
+		}
+		if since == nil || watermark.Before(*since) {
+			since = watermark
+		}
+	}
+	if since == nil {
+		now := s.clock.Now()
+		since = &now
+	}
+	return since, nil
+}
+
+// records the extraction of the run's checkpointed sources, but for those
+// whose client tracks it. A failure to store a checkpoint does not fail
+// the run; the next one extracts more.
+func (s *ETLService) saveCheckpoints(ctx context.Context, opts domain.RunOptions, runID string, extractedAt time.Time) {
+	if opts.Extractor != nil {
+		return
+	}
+	for _, source := range domain.CheckpointedSources {
+		if !opts.IncludesSource(source) || domain.TracksExtraction(s.extractor(opts), source) {
+			continue
+		}
+		checkpoint := domain.Checkpoint{
+			Source:      source,
+			Tenant:      tenantOf(ctx),
+			ExtractedAt: extractedAt,
+			RunID:       runID,
+		}
+		if err := s.checkpoints.Save(ctx, checkpoint); err != nil {
+			s.logger.WithContext(ctx).WithError(err).WithField("source", source).Warn("Failed to store checkpoint")
+		}
+	}
+}
+
+// records the object store files the run read as ingested so later runs
+// skip them. A failure to store them does not fail the run; the next one
+// reads them again and finds their rows unchanged.
+func (s *ETLService) saveSourceObjects(ctx context.Context, objects []domain.SourceObject, runID string) {
+	if len(objects) == 0 {
+		return
+	}
+	ingestedAt := s.clock.Now().UTC()
+	for i := range objects {
+		objects[i].RunID = runID
+		objects[i].IngestedAt = ingestedAt
+	}
+	if err := s.objects.Save(ctx, objects); err != nil {
+		s.logger.WithContext(ctx).WithError(err).WithField("objects", len(objects)).Warn("Failed to record ingested objects")
+	}
+}
+
+// returns the tenant's ingested object store files of the source, or of
+// every source when source is empty
+func (s *ETLService) ListSourceObjects(ctx context.Context, source string) ([]domain.SourceObject, error) {
+	return s.objects.List(ctx, tenantOf(ctx), source)
+}
+
+// returns the connectors runs extract ads and CRM from, in the order their
+// records are extracted
+func (s *ETLService) ListConnectors() []domain.ConnectorInfo {
+	return domain.ListConnectors(s.apiClient)
+}
+
+// returns the tenant's extraction checkpoints
+func (s *ETLService) ListCheckpoints(ctx context.Context) ([]domain.Checkpoint, error) {
+	return s.checkpoints.List(ctx, tenantOf(ctx))
+}
+
+// the client the run extracts ads and CRM from
+func (s *ETLService) extractor(opts domain.RunOptions) domain.ExternalAPIClient {
+	if opts.Extractor != nil {
+		return opts.Extractor
+	}
+	return s.apiClient
+}
+
+// opens connections to the upstreams the run extracts from, concurrently
+func (s *ETLService) prewarm(ctx context.Context, opts domain.RunOptions) {
+	var wg sync.WaitGroup
+	if opts.IncludesSource(domain.SourceAds) || opts.IncludesSource(domain.SourceCRM) {
+		wg.Go(func() { s.extractor(opts).Prewarm(ctx) })
+	}
+	if s.extractsAnalytics(opts) {
+		wg.Go(func() { s.analytics.Prewarm(ctx) })
+	}
+	if s.extractsKeywords(opts) {
+		wg.Go(func() { s.keywordFeed.Prewarm(ctx) })
+	}
+	wg.Wait()
 }
 
 // extractData fetches data from external APIs concurrently
-func (s *ETLService) extractData(ctx context.Context) (*domain.AdData, *domain.CRMData, error) {
+func (s *ETLService) extractData(ctx context.Context, opts domain.RunOptions) (*domain.AdData, *domain.CRMData, *domain.AnalyticsData, *domain.KeywordData, error) {
 	log := s.logger.WithContext(ctx)
 	log.Info("Extracting data from external APIs")
 
-	var adsData *domain.AdData
-	var crmData *domain.CRMData
-	var adsErr, crmErr error
+	// Sources excluded from the run extract nothing
+	adsData := &domain.AdData{}
+	crmData := &domain.CRMData{}
+	analyticsData := &domain.AnalyticsData{}
+	keywordData := &domain.KeywordData{}
+	var adsErr, crmErr, analyticsErr, keywordErr error
+	extractor := s.extractor(opts)
 
 	// fetch data concurrently
 	var wg sync.WaitGroup
-	wg.Add(2)
 
 	// Fetch ads data
-	go func() {
-		defer wg.Done()
-		adsData, adsErr = s.apiClient.FetchAdsData(ctx)
-		if adsErr != nil {
-			log.WithError(adsErr).Error("Failed to fetch ads data")
-		}
-	}()
+	if opts.IncludesSource(domain.SourceAds) {
+		wg.Go(func() {
+			adsData, adsErr = extractor.FetchAdsData(ctx, opts.SinceFor(domain.SourceAds))
+			if adsErr != nil {
+				log.WithError(adsErr).Error("Failed to fetch ads data")
+			}
+		})
+	}
 
 	// Fetch CRM data
-	go func() {
-		defer wg.Done()
-		crmData, crmErr = s.apiClient.FetchCRMData(ctx)
-		if crmErr != nil {
-			log.WithError(crmErr).Error("Failed to fetch CRM data")
-		}
-	}()
+	if opts.IncludesSource(domain.SourceCRM) {
+		wg.Go(func() {
+			crmData, crmErr = extractor.FetchCRMData(ctx, opts.SinceFor(domain.SourceCRM))
+			if crmErr != nil {
+				log.WithError(crmErr).Error("Failed to fetch CRM data")
+			}
+		})
+	}
+
+	// Fetch analytics sessions for the metrics window
+	if s.extractsAnalytics(opts) {
+		wg.Go(func() {
+			from, to := s.analyticsWindow(opts.Since)
+			analyticsData, analyticsErr = s.analytics.FetchAnalyticsData(ctx, from, to)
+			if analyticsErr != nil {
+				log.WithError(analyticsErr).Error("Failed to fetch analytics data")
+			}
+		})
+	}
+
+	// Fetch keyword level ads
+	if s.extractsKeywords(opts) {
+		wg.Go(func() {
+			keywordData, keywordErr = s.keywordFeed.FetchKeywordData(ctx, opts.Since)
+			if keywordErr != nil {
+				log.WithError(keywordErr).Error("Failed to fetch keyword data")
+			}
+		})
+	}
 
 	wg.Wait()
 
 	if adsErr != nil {
-		return nil, nil, fmt.Errorf("ads data extraction failed: %w", adsErr)
+		return nil, nil, nil, nil, fmt.Errorf("ads data extraction failed: %w", adsErr)
 	}
 	if crmErr != nil {
-		return nil, nil, fmt.Errorf("CRM data extraction failed: %w", crmErr)
+		return nil, nil, nil, nil, fmt.Errorf("CRM data extraction failed: %w", crmErr)
+	}
+	if analyticsErr != nil {
+		return nil, nil, nil, nil, fmt.Errorf("analytics data extraction failed: %w", analyticsErr)
+	}
+	if keywordErr != nil {
+		return nil, nil, nil, nil, fmt.Errorf("keyword data extraction failed: %w", keywordErr)
 	}
 
 	log.WithFields(map[string]any{
-		"ads_records": len(adsData.External.Ads.Performance),
-		"crm_records": len(crmData.External.CRM.Opportunities),
+		"ads_records":       len(adsData.External.Ads.Performance),
+		"crm_records":       len(crmData.External.CRM.Opportunities),
+		"analytics_records": len(analyticsData.Rows),
+		"keyword_records":   len(keywordData.Rows),
 	}).Info("Data extraction completed")
 
-	return adsData, crmData, nil
+	return adsData, crmData, analyticsData, keywordData, nil
 }
 
-// processes and normalizes the raw data
-func (s *ETLService) transformData(ctx context.Context, adsData *domain.AdData, crmData *domain.CRMData, since *time.Time) ([]domain.ProcessedAdData, []domain.ProcessedOpportunity, error) {
+// processes and normalizes the raw data, applying each source's parse policy.
+// When decoded is set it receives a copy of the rows before value policies.
+func (s *ETLService) transformData(ctx context.Context, adsData *domain.AdData, crmData *domain.CRMData, opts domain.RunOptions, summary *domain.RunSummary, decoded *decodedRecords) ([]domain.ProcessedAdData, []domain.ProcessedOpportunity, error) {
 	log := s.logger.WithContext(ctx)
 	log.Info("Transforming data")
 
 	// Process ads data
-	processedAds := s.processAdsData(adsData.External.Ads.Performance, since)
+	adsRejects := newRowRejects(domain.SourceAds)
+	for _, rejected := range adsData.Rejected {
+		adsRejects.add(rejected)
+		s.metrics.RecordETLRecordFailure("ads", "decode")
+	}
+	processedAds := s.processAdsData(adsData.External.Ads.Performance, opts.SinceFor(domain.SourceAds), adsRejects)
 
 	// Process CRM data
-	processedCRM := s.processCRMData(crmData.External.CRM.Opportunities, since)
+	crmRejects := newRowRejects(domain.SourceCRM)
+	for _, rejected := range crmData.Rejected {
+		crmRejects.add(rejected)
+		s.metrics.RecordETLRecordFailure("crm", "decode")
+	}
+	processedCRM := s.processCRMData(ctx, crmData.External.CRM.Opportunities, opts.SinceFor(domain.SourceCRM), crmRejects)
+
+	// Detect clock-skewed dates, e.g. future-dated rows of a timezone bug
+	now := s.clock.Now().UTC()
+	summary.Dates = make(map[string]*domain.DateSkewReport)
+	if opts.IncludesSource(domain.SourceAds) {
+		summary.Dates[domain.SourceAds] = &domain.DateSkewReport{}
+		processedAds = applyDateSkewPolicy(processedAds, adDateTarget, s.datePolicy, now, adsRejects, summary.Dates[domain.SourceAds])
+	}
+	if opts.IncludesSource(domain.SourceCRM) {
+		summary.Dates[domain.SourceCRM] = &domain.DateSkewReport{}
+		processedCRM = applyDateSkewPolicy(processedCRM, crmDateTarget, s.datePolicy, now, crmRejects, summary.Dates[domain.SourceCRM])
+	}
+	s.recordDateSkew(ctx, summary.Dates)
+
+	if decoded != nil {
+		*decoded = newDecodedRecords(processedAds, processedCRM, adsRejects.report, crmRejects.report)
+	}
+
+	// Apply negative and outlier value policies
+	if len(s.valuePolicy) > 0 {
+		summary.Values = make(map[string]*domain.ValueReport)
+		processedAds = applyValuePolicies(processedAds, adValueTarget, s.valuePolicy, adsRejects, summary.Values)
+		processedCRM = applyValuePolicies(processedCRM, crmValueTarget, s.valuePolicy, crmRejects, summary.Values)
+		s.recordValuePolicyMetrics(ctx, summary.Values)
+	}
+
+	// Evaluate parse policies; every included source is reported even if
+	// an earlier one already failed the run
+	var policyErr error
+	var quarantined []domain.QuarantinedRecord
+	for _, rejects := range []*rowRejects{adsRejects, crmRejects} {
+		if !opts.IncludesSource(rejects.source) {
+			continue
+		}
+		summary.Parsing[rejects.source] = rejects.report
+		quarantined = append(quarantined, rejects.records...)
+		if err := s.parsePolicyFor(ctx, opts, rejects.source).Evaluate(rejects.source, rejects.report); err != nil {
+			log.WithError(err).WithField("source", rejects.source).Error("Parse policy violated")
+			policyErr = err
+		}
+	}
+
+	// Quarantine rejected rows; a storage failure does not fail the run
+	if len(quarantined) > 0 {
+		if err := s.quarantine.Store(ctx, quarantined); err != nil {
+			log.WithError(err).Warn("Failed to quarantine rejected rows")
+		}
+	}
+
+	if policyErr != nil {
+		return nil, nil, policyErr
+	}
+
+	s.fingerprintRecords(processedAds, processedCRM)
 
 	// Record processing metrics
-	s.metrics.RecordETLRecords("ads", "success", len(processedAds))
-	s.metrics.RecordETLRecords("crm", "success", len(processedCRM))
+	s.metrics.RecordETLRecords("ads", "success", tenantOf(ctx), len(processedAds))
+	s.metrics.RecordETLRecords("crm", "success", tenantOf(ctx), len(processedCRM))
 
 	log.WithFields(map[string]any{
 		"processed_ads": len(processedAds),
@@ -162,23 +863,129 @@ func (s *ETLService) transformData(ctx context.Context, adsData *domain.AdData,
 	return processedAds, processedCRM, nil
 }
 
+// returns quarantined rows, most recent first
+func (s *ETLService) ListQuarantined(ctx context.Context, source string, limit int) ([]domain.QuarantinedRecord, error) {
+	records, err := s.quarantine.List(ctx, source, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list quarantined rows: %w", err)
+	}
+	return records, nil
+}
+
+// returns the run's policy for the source, falling back to strict when the
+// strict_validation flag is on and to the service default otherwise
+func (s *ETLService) parsePolicyFor(ctx context.Context, opts domain.RunOptions, source string) domain.ParsePolicy {
+	if policy, ok := opts.Parsing[source]; ok {
+		return policy
+	}
+	if s.flags.IsEnabled(ctx, domain.FlagStrictValidation) {
+		return domain.ParsePolicy{Mode: domain.ParseModeStrict, MaxErrors: 1}
+	}
+	s.policyMutex.RLock()
+	defer s.policyMutex.RUnlock()
+	return s.parsePolicy
+}
+
+// ParsePolicy returns the parse policy runs use by default
+func (s *ETLService) ParsePolicy() domain.ParsePolicy {
+	s.policyMutex.RLock()
+	defer s.policyMutex.RUnlock()
+	return s.parsePolicy
+}
+
+// SetParsePolicy changes the default parse policy of runs that start
+// afterwards
+func (s *ETLService) SetParsePolicy(policy domain.ParsePolicy) {
+	s.policyMutex.Lock()
+	s.parsePolicy = policy
+	s.policyMutex.Unlock()
+}
+
+// collects the rejected rows of one source for its parse report and the quarantine
+type rowRejects struct {
+	source  string
+	report  *domain.ParseReport
+	records []domain.QuarantinedRecord
+}
+
+func newRowRejects(source string) *rowRejects {
+	return &rowRejects{source: source, report: &domain.ParseReport{}}
+}
+
+// adds a row rejected while decoding
+func (r *rowRejects) add(record domain.QuarantinedRecord) {
+	r.report.Reject(record.Errors...)
+	r.records = append(r.records, record)
+}
+
+// rejects a decoded row that failed normalization
+func (r *rowRejects) reject(record string, row any, errs ...domain.RecordError) {
+	r.report.Reject(errs...)
+	r.quarantine(record, row, errs...)
+}
+
+// quarantines a row without counting it as a parse error
+func (r *rowRejects) quarantine(record string, row any, errs ...domain.RecordError) {
+	payload, _ := json.Marshal(row)
+	r.records = append(r.records, domain.QuarantinedRecord{
+		Source:  r.source,
+		Record:  record,
+		Payload: payload,
+		Errors:  errs,
+	})
+}
+
+// records the skewed dates of each source, warning about them
+func (s *ETLService) recordDateSkew(ctx context.Context, reports map[string]*domain.DateSkewReport) {
+	tenant := tenantOf(ctx)
+	for source, report := range reports {
+		if report.Skewed() == 0 {
+			continue
+		}
+		s.metrics.RecordETLRecords(source, "future_dated", tenant, report.Future)
+		s.metrics.RecordETLRecords(source, "old_dated", tenant, report.Old)
+		s.logger.WithContext(ctx).WithFields(map[string]any{
+			"source":      source,
+			"future":      report.Future,
+			"old":         report.Old,
+			"action":      s.datePolicy.Action,
+			"latest":      report.Latest,
+			"earliest":    report.Earliest,
+			"quarantined": report.Quarantined,
+		}).Warn("Clock-skewed dates detected")
+	}
+}
+
+// records how many rows each value policy touched
+func (s *ETLService) recordValuePolicyMetrics(ctx context.Context, reports map[string]*domain.ValueReport) {
+	tenant := tenantOf(ctx)
+	for key, report := range reports {
+		source, _, _ := strings.Cut(key, ".")
+		s.metrics.RecordETLRecords(source, "clamped", tenant, report.Clamped)
+		s.metrics.RecordETLRecords(source, "quarantined", tenant, report.Quarantined)
+		s.metrics.RecordETLRecords(source, "flagged", tenant, report.Flagged)
+	}
+}
+
+// date formats accepted for ad dates
+var adDateFormats = []string{
+	"2006-01-02", // YYYY-MM-DD
+	"2006/01/02", // YYYY/MM/DD
+	"01/02/2006", // MM/DD/YYYY
+	"02/01/2006", // DD/MM/YYYY
+	time.RFC3339, // 2006-01-02T15:04:05Z07:00
+}
+
 // processes and normalizes ads data
-func (s *ETLService) processAdsData(ads []domain.AdPerformance, since *time.Time) []domain.ProcessedAdData {
-	var processed []domain.ProcessedAdData
+func (s *ETLService) processAdsData(ads []domain.AdPerformance, since *time.Time, rejects *rowRejects) []domain.ProcessedAdData {
+	// Sized for the upstream rows so big runs don't regrow the slice
+	processed := make([]domain.ProcessedAdData, 0, len(ads))
 
 	for _, ad := range ads {
 		// Parse date - try multiple formats
-		dateFormats := []string{
-			"2006-01-02", // YYYY-MM-DD
-			"2006/01/02", // YYYY/MM/DD
-			"01/02/2006", // MM/DD/YYYY
-			"02/01/2006", // DD/MM/YYYY
-			time.RFC3339, // 2006-01-02T15:04:05Z07:00
-		}
-
 		var date time.Time
 		var err error
-		for _, format := range dateFormats {
+		for _, format := range adDateFormats {
 			date, err = time.Parse(format, ad.Date)
 			if err == nil {
 				break
@@ -188,8 +995,25 @@ func (s *ETLService) processAdsData(ads []domain.AdPerformance, since *time.Time
 		if err != nil {
 			s.logger.WithError(err).WithField("date", ad.Date).Warn("Failed to parse ad date, skipping")
 			s.metrics.RecordETLRecordFailure("ads", "date_parse")
+			rejects.reject(ad.CampaignID, ad, domain.RecordError{
+				Record: ad.CampaignID,
+				Field:  "date",
+				Value:  ad.Date,
+				Reason: "unrecognized date format",
+			})
+			continue
+		}
+		if ad.QualityScore != nil && !domain.ValidQualityScore(*ad.QualityScore) {
+			s.metrics.RecordETLRecordFailure("ads", "quality_score")
+			rejects.reject(ad.CampaignID, ad, domain.RecordError{
+				Record: ad.CampaignID,
+				Field:  "quality_score",
+				Value:  strconv.FormatFloat(*ad.QualityScore, 'f', -1, 64),
+				Reason: "quality score must be between 1 and 10",
+			})
 			continue
 		}
+		rejects.report.Accept()
 
 		// Apply date filter if specified
 		if since != nil && date.Before(*since) {
@@ -213,56 +1037,90 @@ func (s *ETLService) processAdsData(ads []domain.AdPerformance, since *time.Time
 		}
 
 		processed = append(processed, domain.ProcessedAdData{
-			Date:        date,
-			CampaignID:  ad.CampaignID,
-			Channel:     ad.Channel,
-			Clicks:      ad.Clicks,
-			Impressions: ad.Impressions,
-			Cost:        ad.Cost,
-			UTMCampaign: utmCampaign,
-			UTMSource:   utmSource,
-			UTMMedium:   utmMedium,
-			ProcessedAt: time.Now(),
+			Date:         date,
+			CampaignID:   ad.CampaignID,
+			Channel:      ad.Channel,
+			Clicks:       ad.Clicks,
+			Impressions:  ad.Impressions,
+			Cost:         ad.Cost,
+			UTMCampaign:  utmCampaign,
+			UTMSource:    utmSource,
+			UTMMedium:    utmMedium,
+			AdGroupID:    ad.AdGroupID,
+			QualityScore: ad.QualityScore,
+			ProcessedAt:  s.clock.Now(),
 		})
 	}
 
 	return processed
 }
 
+// date formats accepted for opportunity and touch times
+var opportunityDateFormats = []string{
+	time.RFC3339,          // 2006-01-02T15:04:05Z07:00
+	"2006-01-02 15:04:05", // YYYY-MM-DD HH:MM:SS
+	"2006-01-02",          // YYYY-MM-DD
+	"2006/01/02 15:04:05", // YYYY/MM/DD HH:MM:SS
+	"2006/01/02",          // YYYY/MM/DD
+}
+
+// parses an opportunity time, trying each accepted format
+func parseOpportunityTime(value string) (time.Time, error) {
+	var t time.Time
+	var err error
+	for _, format := range opportunityDateFormats {
+		t, err = time.Parse(format, value)
+		if err == nil {
+			break
+		}
+	}
+	return t, err
+}
+
 // processes and normalizes CRM data
-func (s *ETLService) processCRMData(opportunities []domain.Opportunity, since *time.Time) []domain.ProcessedOpportunity {
-	var processed []domain.ProcessedOpportunity
+func (s *ETLService) processCRMData(ctx context.Context, opportunities []domain.Opportunity, since *time.Time, rejects *rowRejects) []domain.ProcessedOpportunity {
+	processed := make([]domain.ProcessedOpportunity, 0, len(opportunities))
 
 	for _, opp := range opportunities {
 		// Parse date - try multiple formats
-		dateFormats := []string{
-			time.RFC3339,          // 2006-01-02T15:04:05Z07:00
-			"2006-01-02 15:04:05", // YYYY-MM-DD HH:MM:SS
-			"2006-01-02",          // YYYY-MM-DD
-			"2006/01/02 15:04:05", // YYYY/MM/DD HH:MM:SS
-			"2006/01/02",          // YYYY/MM/DD
-		}
-
-		var createdAt time.Time
-		var err error
-		for _, format := range dateFormats {
-			createdAt, err = time.Parse(format, opp.CreatedAt)
-			if err == nil {
-				break
-			}
-		}
+		createdAt, err := parseOpportunityTime(opp.CreatedAt)
 
 		if err != nil {
 			s.logger.WithError(err).WithField("created_at", opp.CreatedAt).Warn("Failed to parse opportunity date, skipping")
 			s.metrics.RecordETLRecordFailure("crm", "date_parse")
+			rejects.reject(opp.OpportunityID, opp, domain.RecordError{
+				Record: opp.OpportunityID,
+				Field:  "created_at",
+				Value:  opp.CreatedAt,
+				Reason: "unrecognized date format",
+			})
 			continue
 		}
 
-		// Apply date filter if specified
-		if since != nil && createdAt.Before(*since) {
+		if !s.funnel.Allows(opp.Stage) {
+			s.metrics.RecordETLRecordFailure("crm", "unknown_stage")
+			rejects.reject(opp.OpportunityID, opp, domain.RecordError{
+				Record: opp.OpportunityID,
+				Field:  "stage",
+				Value:  string(opp.Stage),
+				Reason: fmt.Sprintf("not a funnel stage, expected one of %v or %s", s.funnel, domain.StageClosedLost),
+			})
 			continue
 		}
 
+		closedAt := s.processClosedAt(opp)
+		valuedAt := createdAt
+		if closedAt != nil {
+			valuedAt = *closedAt
+		}
+		amount, currency, errs := s.dealAmount(ctx, opp, valuedAt)
+		if len(errs) > 0 {
+			s.metrics.RecordETLRecordFailure("crm", "deal_value")
+			rejects.reject(opp.OpportunityID, opp, errs...)
+			continue
+		}
+		rejects.report.Accept()
+
 		// Normalize UTM fields (handle empty values)
 		utmCampaign := opp.UTMCampaign
 		if utmCampaign == "" {
@@ -279,24 +1137,121 @@ func (s *ETLService) processCRMData(opportunities []domain.Opportunity, since *t
 			utmMedium = "unknown"
 		}
 
-		processed = append(processed, domain.ProcessedOpportunity{
+		processedOpp := domain.ProcessedOpportunity{
 			OpportunityID: opp.OpportunityID,
 			ContactEmail:  opp.ContactEmail,
 			Stage:         opp.Stage,
-			Amount:        opp.Amount,
+			Amount:        amount,
 			CreatedAt:     createdAt,
+			ClosedAt:      closedAt,
 			UTMCampaign:   utmCampaign,
 			UTMSource:     utmSource,
 			UTMMedium:     utmMedium,
-			ProcessedAt:   time.Now(),
-		})
+			Touches:       s.processTouches(opp),
+			Probability:   opp.Probability,
+			ProcessedAt:   s.clock.Now(),
+		}
+		if currency != "" {
+			processedOpp.Currency, processedOpp.OriginalAmount = currency, opp.Amount
+		}
+
+		// Apply date filter if specified. Opportunities closed since are
+		// kept when their revenue is recognized on close.
+		if since != nil && processedOpp.RecognizedAt(s.recognition).Before(*since) {
+			continue
+		}
+		processed = append(processed, processedOpp)
 	}
 
 	return processed
 }
 
+// parses the close date of an opportunity. A close date that cannot be
+// parsed is dropped, the opportunity's revenue is then recognized on its
+// creation.
+func (s *ETLService) processClosedAt(opp domain.Opportunity) *time.Time {
+	if opp.ClosedAt == "" {
+		return nil
+	}
+	closedAt, err := parseOpportunityTime(opp.ClosedAt)
+	if err != nil {
+		s.logger.WithFields(map[string]any{
+			"opportunity_id": opp.OpportunityID,
+			"closed_at":      opp.ClosedAt,
+		}).Warn("Failed to parse opportunity close date, dropping it")
+		s.metrics.RecordETLRecordFailure("crm", "closed_at_parse")
+		return nil
+	}
+	return &closedAt
+}
+
+// validates the win probability of an opportunity and returns its amount
+// in the base currency, converted at the rate of the day it was valued on.
+// currency is the reported currency when the amount was converted.
+func (s *ETLService) dealAmount(ctx context.Context, opp domain.Opportunity, valuedAt time.Time) (amount domain.Money, currency string, errs []domain.RecordError) {
+	if opp.Probability != nil && !domain.ValidProbability(*opp.Probability) {
+		errs = append(errs, domain.RecordError{
+			Record: opp.OpportunityID,
+			Field:  "probability",
+			Value:  strconv.FormatFloat(*opp.Probability, 'f', -1, 64),
+			Reason: "probability must be a percentage between 0 and 100",
+		})
+	}
+	if opp.Currency == "" {
+		return opp.Amount, "", errs
+	}
+
+	currency, err := domain.NormalizeCurrency(opp.Currency)
+	if err == nil && currency == s.baseCurrency {
+		return opp.Amount, "", errs
+	}
+	var rate *domain.FXRate
+	if err == nil {
+		rate, err = fxRateOn(ctx, s.fxRates, currency, valuedAt.UTC().Truncate(24*time.Hour))
+	}
+	if err != nil {
+		errs = append(errs, domain.RecordError{
+			Record: opp.OpportunityID,
+			Field:  "currency",
+			Value:  opp.Currency,
+			Reason: err.Error(),
+		})
+		return 0, "", errs
+	}
+	return opp.Amount.Scale(1 / rate.Rate), currency, errs
+}
+
+// normalizes the touches of an opportunity for attribution. Touches with a
+// time that cannot be parsed are dropped; they only affect how revenue is
+// split, not whether the opportunity is kept.
+func (s *ETLService) processTouches(opp domain.Opportunity) []domain.Touchpoint {
+	if len(opp.Touches) == 0 {
+		return nil
+	}
+
+	touches := make([]domain.Touchpoint, 0, len(opp.Touches))
+	for _, touch := range opp.Touches {
+		touchedAt, err := parseOpportunityTime(touch.TouchedAt)
+		if err != nil {
+			s.logger.WithFields(map[string]any{
+				"opportunity_id": opp.OpportunityID,
+				"touched_at":     touch.TouchedAt,
+			}).Warn("Failed to parse touch time, dropping touch")
+			s.metrics.RecordETLRecordFailure("crm", "touch_parse")
+			continue
+		}
+		touches = append(touches, domain.Touchpoint{
+			UTMCampaign: cmp.Or(touch.UTMCampaign, "unknown"),
+			UTMSource:   cmp.Or(touch.UTMSource, "unknown"),
+			UTMMedium:   cmp.Or(touch.UTMMedium, "unknown"),
+			TouchedAt:   touchedAt,
+		})
+	}
+	return touches
+}
+
 // stores the processed data in repositories
-func (s *ETLService) loadData(ctx context.Context, ads []domain.ProcessedAdData, opportunities []domain.ProcessedOpportunity) error {
+func (s *ETLService) loadData(ctx context.Context, ads []domain.ProcessedAdData, opportunities []domain.ProcessedOpportunity, replaceFrom *time.Time) error {
 	log := s.logger.WithContext(ctx)
 	log.Info("Loading data into repositories")
 
@@ -309,7 +1264,11 @@ func (s *ETLService) loadData(ctx context.Context, ads []domain.ProcessedAdData,
 	// Load ads data
 	go func() {
 		defer wg.Done()
-		adsErr = s.adRepo.Store(ctx, ads)
+		if replaceFrom != nil {
+			adsErr = s.adRepo.Replace(ctx, *replaceFrom, s.clock.Now(), ads)
+		} else {
+			adsErr = s.adRepo.Store(ctx, ads)
+		}
 		if adsErr != nil {
 			log.WithError(adsErr).Error("Failed to store ads data")
 		}
@@ -337,44 +1296,84 @@ func (s *ETLService) loadData(ctx context.Context, ads []domain.ProcessedAdData,
 	return nil
 }
 
-// calculates and stores business metrics
-func (s *ETLService) calculateMetrics(ctx context.Context, since *time.Time) error {
+// returns the date range metrics are calculated over
+func (s *ETLService) metricsWindow(since *time.Time) (from, to time.Time) {
+	return metricsWindowAt(since, s.clock.Now())
+}
+
+// returns the date range metrics calculated at the given time covered
+func metricsWindowAt(since *time.Time, at time.Time) (from, to time.Time) {
+	from = at.AddDate(0, 0, -365)
+	to = at.AddDate(0, 0, 30)
+
+	if since != nil {
+		from = *since
+	}
+	return from, to
+}
+
+// calculates and stores business metrics, replacing the stored metrics with
+// the same date, UTM and ad group
+func (s *ETLService) calculateMetrics(ctx context.Context, since *time.Time) ([]domain.BusinessMetrics, *domain.JoinReport, error) {
 	log := s.logger.WithContext(ctx)
 	log.Info("Calculating business metrics")
 
 	// Determine date range for metrics calculation
-	from := time.Now().AddDate(0, 0, -365)
-	to := time.Now().AddDate(0, 0, 30)
+	from, to := s.metricsWindow(since)
 
-	if since != nil {
-		from = *since
+	metrics, join, err := s.calculateMetricsBetween(ctx, from, to)
+	if err != nil {
+		return nil, nil, err
 	}
 
+	// Store metrics
+	if err := s.metricsRepo.Upsert(ctx, metrics); err != nil {
+		return nil, nil, fmt.Errorf("failed to store metrics: %w", err)
+	}
+
+	log.WithFields(map[string]any{
+		"metrics_count":         len(metrics),
+		"transform_version":     s.transformVersion,
+		"unmatched_crm_keys":    join.CRM.UnmatchedKeys,
+		"unmatched_crm_records": join.CRM.UnmatchedRecords,
+	}).Info("Business metrics calculation completed")
+	return metrics, join, nil
+}
+
+// calculates the business metrics of the stored data in the date range and
+// reports how its ads and opportunities joined
+func (s *ETLService) calculateMetricsBetween(ctx context.Context, from, to time.Time) ([]domain.BusinessMetrics, *domain.JoinReport, error) {
 	// Get processed data
 	ads, err := s.adRepo.GetByDateRange(ctx, from, to)
 	if err != nil {
-		return fmt.Errorf("failed to get ads data for metrics: %w", err)
+		return nil, nil, fmt.Errorf("failed to get ads data for metrics: %w", err)
 	}
 
-	opportunities, err := s.crmRepo.GetByDateRange(ctx, from, to)
+	opportunities, err := s.recognizedOpportunities(ctx, from, to)
 	if err != nil {
-		return fmt.Errorf("failed to get CRM data for metrics: %w", err)
+		return nil, nil, fmt.Errorf("failed to get CRM data for metrics: %w", err)
 	}
 
-	// Calculate metrics using worker pool
-	metrics := s.calculateMetricsWithWorkerPool(ctx, ads, opportunities)
-
-	// Store metrics
-	if err := s.metricsRepo.Store(ctx, metrics); err != nil {
-		return fmt.Errorf("failed to store metrics: %w", err)
+	sessions, err := s.sessions.GetByDateRange(ctx, from, to)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get analytics data for metrics: %w", err)
 	}
 
-	log.WithField("metrics_count", len(metrics)).Info("Business metrics calculation completed")
-	return nil
+	// Count opportunities of near identical UTMs under their ad's
+	matched, fuzzy := s.utmMatcher.MatchOpportunities(ads, opportunities)
+
+	// Calculate metrics using worker pool
+	metrics := s.calculateMetricsWithWorkerPool(ctx, ads, matched, sessions, s.attribution)
+	for i := range metrics {
+		metrics[i].TransformVersion = s.transformVersion
+		s.metrics.RecordBusinessMetric("calculated")
+	}
+	return metrics, domain.NewJoinReport(ads, opportunities, fuzzy), nil
 }
 
-// calculates metrics using concurrent processing
-func (s *ETLService) calculateMetricsWithWorkerPool(ctx context.Context, ads []domain.ProcessedAdData, opportunities []domain.ProcessedOpportunity) []domain.BusinessMetrics {
+// calculates metrics using concurrent processing, attributing closed won
+// revenue with the given model
+func (s *ETLService) calculateMetricsWithWorkerPool(ctx context.Context, ads []domain.ProcessedAdData, opportunities []domain.ProcessedOpportunity, sessions []domain.ProcessedAnalyticsRow, attribution domain.AttributionModel) []domain.BusinessMetrics {
 	// Group data by UTM for correlation
 	adsByUTM := make(map[domain.UTMKey][]domain.ProcessedAdData)
 	oppsByUTM := make(map[domain.UTMKey][]domain.ProcessedOpportunity)
@@ -399,18 +1398,60 @@ func (s *ETLService) calculateMetricsWithWorkerPool(ctx context.Context, ads []d
 		oppsByUTM[utm] = append(oppsByUTM[utm], opp)
 	}
 
+	// Total analytics sessions and conversions by UTM
+	sessionsByUTM := make(map[domain.UTMKey]domain.ProcessedAnalyticsRow)
+	for _, row := range sessions {
+		utm := domain.UTMKey{
+			Campaign: row.UTMCampaign,
+			Source:   row.UTMSource,
+			Medium:   row.UTMMedium,
+		}
+		total := sessionsByUTM[utm]
+		total.Sessions += row.Sessions
+		total.Conversions += row.Conversions
+		sessionsByUTM[utm] = total
+	}
+
+	// Split closed won revenue across the touched UTMs with ad data
+	attributed := make(map[domain.UTMKey]domain.Money)
+	hasAds := func(utm domain.UTMKey) bool {
+		_, ok := adsByUTM[utm]
+		return ok
+	}
+	for _, opp := range opportunities {
+		if !opp.IsClosedWon() {
+			continue
+		}
+		for utm, weight := range attribution.Credits(opp, hasAds) {
+			attributed[utm] += opp.Amount.Scale(weight)
+		}
+	}
+
+	// Metrics are calculated for the UTMs with ads, and those with only
+	// opportunities when they are included
+	utms := make([]domain.UTMKey, 0, len(adsByUTM))
+	for utm := range adsByUTM {
+		utms = append(utms, utm)
+	}
+	if s.crmOnlyKeys {
+		for utm := range oppsByUTM {
+			if !hasAds(utm) {
+				utms = append(utms, utm)
+			}
+		}
+	}
+
 	// Create jobs for worker pool
-	jobs := make(chan domain.UTMKey, len(adsByUTM))
-	results := make(chan domain.BusinessMetrics, len(adsByUTM))
+	jobs := make(chan domain.UTMKey, len(utms))
+	results := make(chan []domain.BusinessMetrics, len(utms))
 
 	// Start workers
 	var wg sync.WaitGroup
 	for i := 0; i < s.workerPool; i++ {
 		wg.Go(func() {
 			for utm := range jobs {
-				metric := s.calculateMetricForUTM(adsByUTM[utm], oppsByUTM[utm], utm)
-				if metric != nil {
-					results <- *metric
+				if metrics := s.calculateMetricsForUTM(adsByUTM[utm], oppsByUTM[utm], attributed[utm], sessionsByUTM[utm], utm); len(metrics) > 0 {
+					results <- metrics
 				}
 			}
 		})
@@ -419,7 +1460,7 @@ func (s *ETLService) calculateMetricsWithWorkerPool(ctx context.Context, ads []d
 	// Send jobs
 	go func() {
 		defer close(jobs)
-		for utm := range adsByUTM {
+		for _, utm := range utms {
 			jobs <- utm
 		}
 	}()
@@ -431,42 +1472,103 @@ func (s *ETLService) calculateMetricsWithWorkerPool(ctx context.Context, ads []d
 	}()
 
 	var metrics []domain.BusinessMetrics
-	for metric := range results {
-		metrics = append(metrics, metric)
-		s.metrics.RecordBusinessMetric("calculated")
+	for utmMetrics := range results {
+		domain.Beat(ctx)
+		metrics = append(metrics, utmMetrics...)
 	}
 
 	return metrics
 }
 
-// calculates business metrics for a specific UTM combination
-func (s *ETLService) calculateMetricForUTM(ads []domain.ProcessedAdData, opportunities []domain.ProcessedOpportunity, utm domain.UTMKey) *domain.BusinessMetrics {
-	if len(ads) == 0 {
+// calculates the business metrics of a UTM combination: one row, or when
+// its ads span several ad groups a row per ad group totalling its ads, and
+// a row without one carrying the opportunities, sessions and attributed
+// revenue, which can't be told apart by ad group, with the ads reported
+// without an ad group
+func (s *ETLService) calculateMetricsForUTM(ads []domain.ProcessedAdData, opportunities []domain.ProcessedOpportunity, attributedRevenue domain.Money, sessions domain.ProcessedAnalyticsRow, utm domain.UTMKey) []domain.BusinessMetrics {
+	adsByGroup := make(map[string][]domain.ProcessedAdData)
+	for _, ad := range ads {
+		adsByGroup[ad.AdGroupID] = append(adsByGroup[ad.AdGroupID], ad)
+	}
+	if len(adsByGroup) <= 1 {
+		if metric := s.calculateMetricForUTM(ads, opportunities, attributedRevenue, sessions, utm); metric != nil {
+			return []domain.BusinessMetrics{*metric}
+		}
+		return nil
+	}
+
+	metrics := make([]domain.BusinessMetrics, 0, len(adsByGroup)+1)
+	for _, group := range slices.Sorted(maps.Keys(adsByGroup)) {
+		if group == "" {
+			continue
+		}
+		metrics = append(metrics, *s.calculateMetricForUTM(adsByGroup[group], nil, 0, domain.ProcessedAnalyticsRow{}, utm))
+	}
+
+	// Dated and labelled by the UTM's latest ad like a row of the whole UTM
+	metric := s.calculateMetricForUTM(ads, opportunities, attributedRevenue, sessions, utm)
+	ungrouped := s.calculateMetricForUTM(adsByGroup[""], nil, 0, domain.ProcessedAnalyticsRow{}, utm)
+	metric.AdGroupID = ""
+	metric.Clicks, metric.Impressions, metric.Cost, metric.QualityScore = 0, 0, 0, 0
+	if ungrouped != nil {
+		metric.Clicks, metric.Impressions, metric.Cost, metric.QualityScore = ungrouped.Clicks, ungrouped.Impressions, ungrouped.Cost, ungrouped.QualityScore
+	}
+	deriveMetricRatios(metric, s.funnel)
+	if ungrouped != nil || len(opportunities) > 0 || sessions.Sessions > 0 || sessions.Conversions > 0 || attributedRevenue != 0 {
+		metrics = append(metrics, *metric)
+	}
+	return metrics
+}
+
+// calculates business metrics for a specific UTM combination and ads
+func (s *ETLService) calculateMetricForUTM(ads []domain.ProcessedAdData, opportunities []domain.ProcessedOpportunity, attributedRevenue domain.Money, sessions domain.ProcessedAnalyticsRow, utm domain.UTMKey) *domain.BusinessMetrics {
+	if len(ads) == 0 && (!s.crmOnlyKeys || len(opportunities) == 0) {
 		return nil
 	}
 
 	// Aggregate ads data
 	var totalClicks, totalImpressions int
-	var totalCost float64
+	var totalCost domain.Money
 	var latestDate time.Time
-	var channel, campaignID string
+	var channel, campaignID, adGroupID string
+	var qualityScore domain.QualityScoreAverage
 
 	for _, ad := range ads {
 		totalClicks += ad.Clicks
 		totalImpressions += ad.Impressions
 		totalCost += ad.Cost
-		if ad.Date.After(latestDate) {
+		if ad.QualityScore != nil {
+			qualityScore.Add(*ad.QualityScore, ad.Impressions)
+		}
+		// Zero rows of gaps don't make the data look fresher than it is
+		if !ad.GapFilled() && ad.Date.After(latestDate) {
 			latestDate = ad.Date
 			channel = ad.Channel
 			campaignID = ad.CampaignID
+			adGroupID = ad.AdGroupID
+		}
+	}
+
+	// Without ads the row is dated by its latest opportunity
+	if len(ads) == 0 {
+		channel = domain.ChannelCRMOnly
+		for _, opp := range opportunities {
+			if day := opp.RecognizedAt(s.recognition).Truncate(24 * time.Hour); day.After(latestDate) {
+				latestDate = day
+			}
 		}
 	}
 
 	// Count opportunities by stage
 	var leads, opps, closedWon int
-	var revenue float64
+	var revenue, pipeline, expected domain.Money
+	var stages map[domain.OpportunityStage]int
 
 	for _, opp := range opportunities {
+		if opp.IsOpen() {
+			pipeline += opp.Amount
+			expected += opp.ExpectedRevenue(s.probability)
+		}
 		switch opp.Stage {
 		case domain.StageLead:
 			leads++
@@ -475,6 +1577,13 @@ func (s *ETLService) calculateMetricForUTM(ads []domain.ProcessedAdData, opportu
 		case domain.StageClosedWon:
 			closedWon++
 			revenue += opp.Amount
+		default:
+			if s.funnel.IsConfigured(opp.Stage) {
+				if stages == nil {
+					stages = make(map[domain.OpportunityStage]int)
+				}
+				stages[opp.Stage]++
+			}
 		}
 	}
 
@@ -486,6 +1595,7 @@ func (s *ETLService) calculateMetricForUTM(ads []domain.ProcessedAdData, opportu
 		UTMCampaign: utm.Campaign,
 		UTMSource:   utm.Source,
 		UTMMedium:   utm.Medium,
+		AdGroupID:   adGroupID,
 
 		Clicks:        totalClicks,
 		Impressions:   totalImpressions,
@@ -494,30 +1604,50 @@ func (s *ETLService) calculateMetricForUTM(ads []domain.ProcessedAdData, opportu
 		Opportunities: opps,
 		ClosedWon:     closedWon,
 		Revenue:       revenue,
+		Stages:        stages,
+
+		AttributedRevenue: attributedRevenue,
+
+		PipelineValue:   pipeline,
+		ExpectedRevenue: expected,
+
+		Sessions:    sessions.Sessions,
+		Conversions: sessions.Conversions,
+
+		QualityScore: qualityScore.Value(),
 
-		CalculatedAt: time.Now(),
+		CalculatedAt: s.clock.Now(),
 	}
+	deriveMetricRatios(metric, s.funnel)
 
-	// Calculate derived metrics with division by zero protection
-	if totalClicks > 0 {
-		metric.CPC = totalCost / float64(totalClicks)
+	return metric
+}
+
+// calculates the derived metrics from the totals with division by zero
+// protection, including the conversions between the funnel's stages
+func deriveMetricRatios(metric *domain.BusinessMetrics, funnel domain.Funnel) {
+	metric.CPC, metric.CPA, metric.CVRClickToLead, metric.CVRLeadToOpp, metric.CVROppToWon, metric.ROAS = 0, 0, 0, 0, 0, 0
+
+	if metric.Clicks > 0 {
+		metric.CPC = metric.Cost.Div(metric.Clicks)
+		metric.CVRClickToLead = float64(metric.Leads) / float64(metric.Clicks)
 	}
 
-	if leads > 0 {
-		metric.CPA = totalCost / float64(leads)
+	if metric.Leads > 0 {
+		metric.CPA = metric.Cost.Div(metric.Leads)
 	}
 
-	if leads > 0 {
-		metric.CVRLeadToOpp = float64(opps) / float64(leads)
+	if metric.Leads > 0 {
+		metric.CVRLeadToOpp = float64(metric.Opportunities) / float64(metric.Leads)
 	}
 
-	if opps > 0 {
-		metric.CVROppToWon = float64(closedWon) / float64(opps)
+	if metric.Opportunities > 0 {
+		metric.CVROppToWon = float64(metric.ClosedWon) / float64(metric.Opportunities)
 	}
 
-	if totalCost > 0 {
-		metric.ROAS = revenue / totalCost
+	if metric.Cost > 0 {
+		metric.ROAS = metric.Revenue.Ratio(metric.Cost)
 	}
 
-	return metric
+	metric.StageConversions = funnel.Conversions(metric.StageCount)
 }
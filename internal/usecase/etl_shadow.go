@@ -0,0 +1,228 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"etlgo/internal/domain"
+)
+
+// ads and CRM rows of a run after decoding and normalization, before value
+// policies, with the parse reports of that stage
+type decodedRecords struct {
+	ads       []domain.ProcessedAdData
+	crm       []domain.ProcessedOpportunity
+	adsReport domain.ParseReport
+	crmReport domain.ParseReport
+}
+
+// copies the rows and reports so value policies applied to the originals
+// don't reach them
+func newDecodedRecords(ads []domain.ProcessedAdData, crm []domain.ProcessedOpportunity, adsReport, crmReport *domain.ParseReport) decodedRecords {
+	decoded := decodedRecords{
+		ads:       slices.Clone(ads),
+		crm:       slices.Clone(crm),
+		adsReport: *adsReport,
+		crmReport: *crmReport,
+	}
+	decoded.adsReport.Errors = slices.Clone(adsReport.Errors)
+	decoded.crmReport.Errors = slices.Clone(crmReport.Errors)
+	return decoded
+}
+
+// returns fresh rejects of the source starting from its decoding report
+func (d *decodedRecords) rejects(source string) *rowRejects {
+	report := d.adsReport
+	if source == domain.SourceCRM {
+		report = d.crmReport
+	}
+	report.Errors = slices.Clone(report.Errors)
+	return &rowRejects{source: source, report: &report}
+}
+
+// what shadow configs are evaluated on and compared against: a run's decoded
+// rows and the results of the active configuration on them
+type shadowInput struct {
+	runID          string
+	opts           domain.RunOptions
+	decoded        *decodedRecords
+	sessions       []domain.ProcessedAnalyticsRow
+	activeAds      []domain.ProcessedAdData
+	activeCRM      []domain.ProcessedOpportunity
+	activeRejected map[string]int
+}
+
+func newShadowInput(summary *domain.RunSummary, opts domain.RunOptions, decoded *decodedRecords, ads []domain.ProcessedAdData, crm []domain.ProcessedOpportunity, sessions []domain.ProcessedAnalyticsRow) shadowInput {
+	input := shadowInput{
+		runID:          summary.ID,
+		opts:           opts,
+		decoded:        decoded,
+		sessions:       sessions,
+		activeAds:      ads,
+		activeCRM:      crm,
+		activeRejected: make(map[string]int),
+	}
+	for source, report := range summary.Parsing {
+		input.activeRejected[source] = report.Rejected
+	}
+	return input
+}
+
+// SaveShadow registers a shadow config, replacing the one with the same
+// label. Every following run evaluates it.
+func (s *ETLService) SaveShadow(ctx context.Context, config domain.ShadowConfig, actor string) (*domain.ShadowConfig, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	config.CreatedBy = actor
+	config.CreatedAt = s.clock.Now()
+	if err := s.shadows.SaveConfig(ctx, config); err != nil {
+		return nil, fmt.Errorf("failed to save shadow config: %w", err)
+	}
+
+	s.logger.WithContext(ctx).WithFields(map[string]any{
+		"label": config.Label,
+		"actor": actor,
+	}).Info("Shadow config saved")
+	return &config, nil
+}
+
+// ListShadows returns the registered shadow configs by label
+func (s *ETLService) ListShadows(ctx context.Context) ([]domain.ShadowConfig, error) {
+	return s.shadows.Configs(ctx)
+}
+
+// DeleteShadow stops evaluating a shadow config. Its results are kept.
+func (s *ETLService) DeleteShadow(ctx context.Context, label string) error {
+	if err := s.shadows.DeleteConfig(ctx, label); err != nil {
+		return err
+	}
+	s.logger.WithContext(ctx).WithField("label", label).Info("Shadow config deleted")
+	return nil
+}
+
+// ListShadowResults returns the results of a shadow config, most recent
+// first, without their metrics and restricted to the caller's scope
+func (s *ETLService) ListShadowResults(ctx context.Context, label string, limit int) ([]domain.ShadowResult, error) {
+	results, err := s.shadows.ListResults(ctx, label, limit)
+	if err != nil {
+		return nil, err
+	}
+	scope := domain.MetricsScopeFromContext(ctx)
+	for i := range results {
+		results[i] = results[i].InScope(scope)
+		results[i].Metrics = nil
+	}
+	return results, nil
+}
+
+// GetShadowResult returns a shadow result with its metrics, restricted to
+// the caller's scope
+func (s *ETLService) GetShadowResult(ctx context.Context, label, id string) (*domain.ShadowResult, error) {
+	result, err := s.shadows.GetResult(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if result.Label != label {
+		return nil, domain.ErrShadowResultNotFound
+	}
+	scoped := result.InScope(domain.MetricsScopeFromContext(ctx))
+	return &scoped, nil
+}
+
+// returns the shadow configs a run evaluates. A failure to read them does
+// not affect the run.
+func (s *ETLService) shadowConfigs(ctx context.Context) []domain.ShadowConfig {
+	configs, err := s.shadows.Configs(ctx)
+	if err != nil {
+		s.logger.WithContext(ctx).WithError(err).Warn("Failed to read shadow configs, running without them")
+		return nil
+	}
+	return configs
+}
+
+// evaluates every shadow config on the run's rows and stores the results
+func (s *ETLService) evaluateShadows(ctx context.Context, configs []domain.ShadowConfig, input shadowInput) {
+	log := s.logger.WithContext(ctx)
+
+	active := s.calculateMetricsWithWorkerPool(ctx, input.activeAds, input.activeCRM, input.sessions, s.attribution)
+	activeChannels := domain.ChannelTotalsOf(active)
+
+	for _, config := range configs {
+		result := s.evaluateShadow(ctx, config, input, activeChannels)
+		if err := s.shadows.SaveResult(ctx, result); err != nil {
+			log.WithError(err).WithField("label", config.Label).Warn("Failed to store shadow result")
+			continue
+		}
+		log.WithFields(map[string]any{
+			"label":     config.Label,
+			"result_id": result.ID,
+			"error":     result.Error,
+		}).Info("Shadow config evaluated")
+	}
+}
+
+// applies a shadow config's value policies, parse policies and attribution
+// model to copies of the decoded rows and compares the outcome with the
+// active configuration's
+func (s *ETLService) evaluateShadow(ctx context.Context, config domain.ShadowConfig, input shadowInput, activeChannels map[string]*domain.ChannelTotals) domain.ShadowResult {
+	result := domain.ShadowResult{
+		ID:        s.ids.NewID(),
+		Label:     config.Label,
+		RunID:     input.runID,
+		Since:     input.opts.Since,
+		Config:    config,
+		Parsing:   make(map[string]*domain.ParseReport),
+		CreatedAt: s.clock.Now(),
+	}
+
+	ads := slices.Clone(input.decoded.ads)
+	crm := slices.Clone(input.decoded.crm)
+	adsRejects := input.decoded.rejects(domain.SourceAds)
+	crmRejects := input.decoded.rejects(domain.SourceCRM)
+
+	values := s.valuePolicy
+	if config.Values != nil {
+		values = config.Values
+	}
+	if len(values) > 0 {
+		result.Values = make(map[string]*domain.ValueReport)
+		ads = applyValuePolicies(ads, adValueTarget, values, adsRejects, result.Values)
+		crm = applyValuePolicies(crm, crmValueTarget, values, crmRejects, result.Values)
+	}
+
+	result.Sources = make(map[string]*domain.SourceComparison)
+	records := map[string]int{domain.SourceAds: len(ads), domain.SourceCRM: len(crm)}
+	activeRecords := map[string]int{domain.SourceAds: len(input.activeAds), domain.SourceCRM: len(input.activeCRM)}
+	for _, rejects := range []*rowRejects{adsRejects, crmRejects} {
+		if !input.opts.IncludesSource(rejects.source) {
+			continue
+		}
+		policy, ok := config.Parsing[rejects.source]
+		if !ok {
+			policy = s.parsePolicyFor(ctx, input.opts, rejects.source)
+		}
+		if err := policy.Evaluate(rejects.source, rejects.report); err != nil && result.Error == "" {
+			result.Error = err.Error()
+		}
+		result.Parsing[rejects.source] = rejects.report
+		result.Sources[rejects.source] = domain.CompareShadowSource(
+			activeRecords[rejects.source], input.activeRejected[rejects.source],
+			records[rejects.source], rejects.report.Rejected,
+		)
+	}
+
+	// Validated when the config was saved
+	attribution, _ := config.Attribution(s.attribution)
+	result.Metrics = s.calculateMetricsWithWorkerPool(ctx, ads, crm, input.sessions, attribution)
+	transform := s.transformConfig()
+	transform.Attribution = attribution
+	transform.ValuePolicies = values
+	version := transform.Version()
+	for i := range result.Metrics {
+		result.Metrics[i].TransformVersion = version
+	}
+	result.Channels = domain.CompareChannels(activeChannels, domain.ChannelTotalsOf(result.Metrics))
+	return result
+}
@@ -0,0 +1,91 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"etlgo/internal/domain"
+	"etlgo/internal/infrastructure"
+	"etlgo/pkg/logger"
+	"etlgo/pkg/metrics"
+)
+
+// BenchmarkTransform normalizes and fingerprints n ads rows and n CRM
+// opportunities, each with one touch, per operation: the per-row work of a
+// run between extraction and load.
+func BenchmarkTransform(b *testing.B) {
+	s := &ETLService{
+		logger:  logger.New("error"),
+		metrics: metrics.New(metrics.Options{}),
+		clock:   domain.SystemClock,
+		funnel:  domain.DefaultFunnel,
+	}
+	ctx := context.Background()
+
+	for _, algorithm := range []string{domain.FingerprintSHA256, domain.FingerprintFNV64a} {
+		for _, n := range []int{1_000, 100_000} {
+			b.Run(fmt.Sprintf("%s/%d", algorithm, n), func(b *testing.B) {
+				fingerprinter, err := infrastructure.NewHashFingerprinter(algorithm)
+				if err != nil {
+					b.Fatal(err)
+				}
+				s.fingerprinter = fingerprinter
+				ads, opportunities := benchmarkRawAds(n), benchmarkRawOpportunities(n)
+
+				b.ReportAllocs()
+				for b.Loop() {
+					processedAds := s.processAdsData(ads, nil, newRowRejects(domain.SourceAds))
+					processedCRM := s.processCRMData(ctx, opportunities, nil, newRowRejects(domain.SourceCRM))
+					s.fingerprintRecords(processedAds, processedCRM)
+				}
+			})
+		}
+	}
+}
+
+// returns n distinct ads rows as the ads API reports them
+func benchmarkRawAds(n int) []domain.AdPerformance {
+	ads := make([]domain.AdPerformance, n)
+	for i := range ads {
+		ads[i] = domain.AdPerformance{
+			Date:        fmt.Sprintf("2025-%02d-%02d", 1+i%12, 1+i%28),
+			CampaignID:  fmt.Sprintf("C-%d", i%500),
+			Channel:     "google_ads",
+			Clicks:      i % 1000,
+			Impressions: i % 50000,
+			Cost:        domain.Money(i) * 12_345,
+			UTMCampaign: fmt.Sprintf("campaign_%d", i%500),
+			UTMSource:   "google",
+			UTMMedium:   "cpc",
+			AdGroupID:   fmt.Sprintf("G-%d", i%7),
+		}
+	}
+	return ads
+}
+
+// returns n distinct opportunities with one touch each, as the CRM reports
+// them
+func benchmarkRawOpportunities(n int) []domain.Opportunity {
+	opportunities := make([]domain.Opportunity, n)
+	for i := range opportunities {
+		createdAt := fmt.Sprintf("2025-%02d-%02dT10:00:00Z", 1+i%12, 1+i%28)
+		opportunities[i] = domain.Opportunity{
+			OpportunityID: fmt.Sprintf("O-%d", i),
+			ContactEmail:  fmt.Sprintf("contact%d@example.com", i),
+			Stage:         domain.StageClosedWon,
+			Amount:        domain.Money(i) * 1_000_000,
+			CreatedAt:     createdAt,
+			UTMCampaign:   fmt.Sprintf("campaign_%d", i%500),
+			UTMSource:     "google",
+			UTMMedium:     "cpc",
+			Touches: []domain.Touch{{
+				UTMCampaign: fmt.Sprintf("campaign_%d", i%500),
+				UTMSource:   "google",
+				UTMMedium:   "cpc",
+				TouchedAt:   createdAt,
+			}},
+		}
+	}
+	return opportunities
+}
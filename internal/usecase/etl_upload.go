@@ -0,0 +1,78 @@
+package usecase
+
+import (
+	"context"
+	"io"
+	"maps"
+	"time"
+
+	"etlgo/internal/domain"
+)
+
+// extracts the records decoded from uploaded files, or consumed from the
+// stream
+type uploadedFiles struct {
+	ads *domain.AdData
+	crm *domain.CRMData
+}
+
+func (u uploadedFiles) FetchAdsData(ctx context.Context, since *time.Time) (*domain.AdData, error) {
+	return u.ads, nil
+}
+
+func (u uploadedFiles) FetchCRMData(ctx context.Context, since *time.Time) (*domain.CRMData, error) {
+	return u.crm, nil
+}
+
+func (u uploadedFiles) Prewarm(ctx context.Context) {}
+
+// IngestFiles runs the pipeline on uploaded ads and/or CRM files, keyed by
+// source, instead of the configured upstreams. Only the uploaded sources
+// are extracted; the run is tagged trigger=upload unless tags set a
+// trigger of their own.
+func (s *ETLService) IngestFiles(ctx context.Context, files map[string]io.Reader, tags map[string]string) (*domain.RunSummary, error) {
+	var extracted uploadedFiles
+	var sources []string
+	if file, ok := files[domain.SourceAds]; ok {
+		ads, err := s.fileDecoder.DecodeAds(file)
+		if err != nil {
+			return nil, err
+		}
+		extracted.ads = ads
+		sources = append(sources, domain.SourceAds)
+	}
+	if file, ok := files[domain.SourceCRM]; ok {
+		crm, err := s.fileDecoder.DecodeCRM(file)
+		if err != nil {
+			return nil, err
+		}
+		extracted.crm = crm
+		sources = append(sources, domain.SourceCRM)
+	}
+	if len(sources) == 0 {
+		return nil, domain.Errorf(domain.ErrValidation, "an ads or crm file is required")
+	}
+
+	runTags := map[string]string{domain.RunTagTrigger: domain.RunTriggerUpload}
+	maps.Copy(runTags, tags)
+	return s.RunETLWithOptions(ctx, domain.RunOptions{
+		Sources:   sources,
+		Tags:      runTags,
+		Extractor: extracted,
+	})
+}
+
+// IngestStream runs the pipeline on a batch of records consumed from the
+// stream, extracting only the sources it holds records of. The run is
+// tagged trigger=stream.
+func (s *ETLService) IngestStream(ctx context.Context, batch *domain.StreamBatch) (*domain.RunSummary, error) {
+	sources := batch.Sources()
+	if len(sources) == 0 {
+		return nil, domain.Errorf(domain.ErrValidation, "the stream batch holds no ads or CRM records")
+	}
+	return s.RunETLWithOptions(ctx, domain.RunOptions{
+		Sources:   sources,
+		Tags:      map[string]string{domain.RunTagTrigger: domain.RunTriggerStream},
+		Extractor: uploadedFiles{ads: batch.Ads, crm: batch.CRM},
+	})
+}
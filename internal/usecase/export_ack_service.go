@@ -0,0 +1,208 @@
+package usecase
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"etlgo/internal/domain"
+	"etlgo/pkg/logger"
+	"etlgo/pkg/metrics"
+)
+
+// how often stuck exports are checked for at most
+const maxExportAckCheckInterval = time.Minute
+
+// ExportAckService tracks exports delivered to sinks that process them
+// asynchronously and acknowledge them with a signed callback. Deliveries
+// move from delivered to confirmed or rejected when their ack arrives;
+// those still waiting past the timeout are flagged stuck and notified.
+type ExportAckService struct {
+	deliveries domain.ExportDeliveryRepository
+	timeout    time.Duration
+	secrets    map[string]string // by destination, the sink's under ""
+	notifier   *NotificationService
+	clock      domain.Clock
+	ids        domain.IDGenerator
+	logger     *logger.Logger
+	metrics    *metrics.Metrics
+}
+
+// NewExportAckService creates a new export ack service. Exports await an
+// ack for up to timeout, signed with secret for the sink and with their own
+// secret for destinations; a zero timeout disables tracking.
+func NewExportAckService(
+	deliveries domain.ExportDeliveryRepository,
+	timeout time.Duration,
+	secret string,
+	destinations []domain.ExportDestination,
+	notifier *NotificationService,
+	clock domain.Clock,
+	ids domain.IDGenerator,
+	logger *logger.Logger,
+	metrics *metrics.Metrics,
+) *ExportAckService {
+	secrets := map[string]string{"": secret}
+	for _, destination := range destinations {
+		secrets[destination.Name] = destination.Secret
+	}
+	return &ExportAckService{
+		deliveries: deliveries,
+		timeout:    timeout,
+		secrets:    secrets,
+		notifier:   notifier,
+		clock:      clock,
+		ids:        ids,
+		logger:     logger,
+		metrics:    metrics,
+	}
+}
+
+// Enabled reports whether delivered exports await an ack
+func (s *ExportAckService) Enabled() bool {
+	return s.timeout > 0
+}
+
+// Delivered records an export delivered to the sink, or the named
+// destination, as awaiting its ack. It returns nil when tracking is
+// disabled or the delivery couldn't be recorded, which never fails the
+// export.
+func (s *ExportAckService) Delivered(ctx context.Context, exportID, destination string, records int) *domain.ExportDelivery {
+	if !s.Enabled() {
+		return nil
+	}
+
+	now := s.clock.Now().UTC()
+	delivery := domain.ExportDelivery{
+		ID:          s.ids.NewID(),
+		ExportID:    exportID,
+		Destination: destination,
+		Records:     records,
+		State:       domain.ExportDelivered,
+		DeliveredAt: now,
+		Deadline:    now.Add(s.timeout),
+	}
+	if err := s.deliveries.Record(ctx, delivery); err != nil {
+		s.logger.WithContext(ctx).WithError(err).WithField("export_id", exportID).Warn("Failed to record export delivery")
+		return nil
+	}
+	return &delivery
+}
+
+// Acknowledge applies the ack in payload of the sink, or of the named
+// destination, after checking its signature, the hex HMAC-SHA256 of the
+// payload with the secret of whoever sent it. Only the one an export was
+// delivered to can acknowledge it.
+func (s *ExportAckService) Acknowledge(ctx context.Context, destination string, payload []byte, signature string) (*domain.ExportDelivery, error) {
+	if !s.Enabled() {
+		return nil, domain.ErrExportAcksNotConfigured
+	}
+
+	secret, ok := s.secrets[destination]
+	if !ok || secret == "" {
+		s.metrics.RecordExportAck("invalid_signature")
+		return nil, domain.ErrInvalidAckSignature
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	expected := hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		s.metrics.RecordExportAck("invalid_signature")
+		return nil, domain.ErrInvalidAckSignature
+	}
+
+	var ack domain.ExportAck
+	if err := json.Unmarshal(payload, &ack); err != nil {
+		return nil, domain.Errorf(domain.ErrValidation, "invalid ack: %w", err)
+	}
+	if err := ack.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Acks apply to the export's most recent delivery
+	latest, err := s.deliveries.List(ctx, domain.ExportDeliveryFilter{ExportID: ack.ExportID, Limit: 1})
+	if err != nil {
+		return nil, err
+	}
+	if len(latest) > 0 && latest[0].Destination != destination {
+		s.metrics.RecordExportAck("invalid_signature")
+		s.logger.WithContext(ctx).WithFields(map[string]any{
+			"export_id":   ack.ExportID,
+			"destination": destination,
+		}).Warn("Export ack signed by another destination rejected")
+		return nil, domain.ErrInvalidAckSignature
+	}
+
+	delivery, err := s.deliveries.Acknowledge(ctx, ack, s.clock.Now().UTC())
+	if errors.Is(err, domain.ErrExportDeliveryNotFound) {
+		return nil, domain.Errorf(domain.ErrNotFound, "export %s is not awaiting an ack", ack.ExportID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.metrics.RecordExportAck(delivery.State)
+	log := s.logger.WithContext(ctx).WithFields(map[string]any{
+		"delivery_id": delivery.ID,
+		"export_id":   delivery.ExportID,
+		"state":       delivery.State,
+	})
+	if delivery.State == domain.ExportRejected {
+		log.WithField("reason", delivery.Reason).Warn("Export rejected by its sink")
+	} else {
+		log.Info("Export confirmed by its sink")
+	}
+	return delivery, nil
+}
+
+// GetDelivery returns a tracked export delivery
+func (s *ExportAckService) GetDelivery(ctx context.Context, id string) (*domain.ExportDelivery, error) {
+	return s.deliveries.Get(ctx, id)
+}
+
+// ListDeliveries returns tracked export deliveries, most recent first
+func (s *ExportAckService) ListDeliveries(ctx context.Context, filter domain.ExportDeliveryFilter) ([]domain.ExportDelivery, error) {
+	deliveries, err := s.deliveries.List(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list export deliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+// CheckStuck flags the deliveries not acknowledged by their deadline and
+// notifies them, once each
+func (s *ExportAckService) CheckStuck(ctx context.Context) ([]domain.ExportDelivery, error) {
+	stuck, err := s.deliveries.MarkStuck(ctx, s.clock.Now().UTC())
+	if err != nil || len(stuck) == 0 {
+		return stuck, err
+	}
+
+	for _, delivery := range stuck {
+		s.metrics.RecordExportAck("stuck")
+		s.logger.WithContext(ctx).WithFields(map[string]any{
+			"delivery_id":  delivery.ID,
+			"export_id":    delivery.ExportID,
+			"destination":  delivery.Destination,
+			"delivered_at": delivery.DeliveredAt,
+			"timeout":      s.timeout.String(),
+		}).Error("Export not acknowledged by its sink before its deadline")
+	}
+	s.notifier.NotifyStuckExports(ctx, stuck)
+	return stuck, nil
+}
+
+// WatchStuck checks for stuck exports until ctx is cancelled, a few times
+// within the ack timeout
+func (s *ExportAckService) WatchStuck(ctx context.Context, heartbeat domain.Heartbeat) {
+	interval := min(max(s.timeout/4, time.Second), maxExportAckCheckInterval)
+	for heartbeat.Sleep(ctx, interval) {
+		if _, err := s.CheckStuck(ctx); err != nil {
+			s.logger.WithError(err).Warn("Failed to check for stuck exports")
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+
+	"etlgo/internal/domain"
+	"etlgo/pkg/logger"
+)
+
+// a feature flag with its state for the requesting tenant
+type FeatureFlagState struct {
+	domain.FeatureFlag
+	Active bool `json:"active"`
+}
+
+// FeatureFlagService exposes flag state and reloads to operators
+type FeatureFlagService struct {
+	provider    domain.FeatureFlagProvider
+	environment string
+	logger      *logger.Logger
+}
+
+// NewFeatureFlagService creates a new feature flag service
+func NewFeatureFlagService(provider domain.FeatureFlagProvider, environment string, logger *logger.Logger) *FeatureFlagService {
+	return &FeatureFlagService{
+		provider:    provider,
+		environment: environment,
+		logger:      logger,
+	}
+}
+
+// returns the environment flags are evaluated for
+func (s *FeatureFlagService) Environment() string {
+	return s.environment
+}
+
+// ListFlags returns every flag with its state for the tenant in ctx
+func (s *FeatureFlagService) ListFlags(ctx context.Context) []FeatureFlagState {
+	flags := s.provider.Flags(ctx)
+	states := make([]FeatureFlagState, len(flags))
+	for i, flag := range flags {
+		states[i] = FeatureFlagState{FeatureFlag: flag, Active: s.provider.IsEnabled(ctx, flag.Name)}
+	}
+	return states
+}
+
+// ReloadFlags re-reads the flag configuration
+func (s *FeatureFlagService) ReloadFlags(ctx context.Context) error {
+	if err := s.provider.Reload(); err != nil {
+		return fmt.Errorf("failed to reload feature flags: %w", err)
+	}
+	s.logger.WithContext(ctx).Info("Feature flags reloaded")
+	return nil
+}
@@ -0,0 +1,320 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"etlgo/internal/domain"
+	"etlgo/pkg/logger"
+)
+
+// IngestJobService runs ingest requests in the background so callers are
+// not held until the whole run finishes. Jobs wait in the job queue like
+// synchronous runs, keep the values of the request's context, such as its
+// tenant and request ID, but not its deadline, and are tracked until they
+// succeed or fail.
+type IngestJobService struct {
+	jobs            domain.IngestJobRepository
+	etlService      *ETLService
+	pipelineService *PipelineService
+	jobQueue        *JobQueue
+	trackers        map[string]*domain.ProgressTracker // of running jobs
+	done            map[string]chan struct{}           // of unfinished jobs, closed when they finish
+	ctx             context.Context
+	stop            context.CancelFunc
+	wg              sync.WaitGroup
+	mutex           sync.Mutex
+	submitMutex     sync.Mutex // serializes looking up and saving jobs with run keys
+	baseURL         string
+	logsURL         string // template of the link to a job's logs
+	clock           domain.Clock
+	ids             domain.IDGenerator
+	logger          *logger.Logger
+}
+
+// NewIngestJobService creates a service running ingest jobs through the queue.
+// Jobs link to their status and run under baseURL and, when logsURL is set,
+// to their logs: its {job_id}, {run_id} and {request_id} are replaced with
+// the job's.
+func NewIngestJobService(
+	jobs domain.IngestJobRepository,
+	etlService *ETLService,
+	pipelineService *PipelineService,
+	jobQueue *JobQueue,
+	baseURL string,
+	logsURL string,
+	clock domain.Clock,
+	ids domain.IDGenerator,
+	logger *logger.Logger,
+) *IngestJobService {
+	ctx, stop := context.WithCancel(context.Background())
+	return &IngestJobService{
+		jobs:            jobs,
+		etlService:      etlService,
+		pipelineService: pipelineService,
+		jobQueue:        jobQueue,
+		trackers:        make(map[string]*domain.ProgressTracker),
+		done:            make(map[string]chan struct{}),
+		ctx:             ctx,
+		stop:            stop,
+		baseURL:         baseURL,
+		logsURL:         logsURL,
+		clock:           clock,
+		ids:             ids,
+		logger:          logger,
+	}
+}
+
+// Submit queues the request as a job and returns it without waiting for it
+// to run. Unknown pipelines are rejected up front, and nothing is queued
+// during maintenance mode. A request repeating the run key of a job returns
+// that job instead, with created false, and conflicts when it asks for a
+// different run.
+func (s *IngestJobService) Submit(ctx context.Context, req domain.IngestJobRequest) (*domain.IngestJob, bool, error) {
+	if req.Pipeline != "" {
+		if _, err := s.pipelineService.GetPipeline(ctx, req.Pipeline); err != nil {
+			return nil, false, err
+		}
+	}
+
+	if req.RunKey != "" {
+		s.submitMutex.Lock()
+		defer s.submitMutex.Unlock()
+
+		existing, err := s.jobs.FindByRunKey(ctx, domain.TenantFromContext(ctx), req.RunKey)
+		if err == nil {
+			if !existing.SameRequest(req) {
+				return nil, false, domain.Errorf(domain.ErrConflict, "run key %s was used for a different run by job %s", req.RunKey, existing.ID)
+			}
+			s.withProgress(existing)
+			return existing, false, nil
+		}
+		if !errors.Is(err, domain.ErrIngestJobNotFound) {
+			return nil, false, err
+		}
+	}
+
+	job := domain.IngestJob{
+		ID:        s.ids.NewID(),
+		RunKey:    req.RunKey,
+		State:     domain.IngestJobQueued,
+		Pipeline:  req.Pipeline,
+		Since:     req.Options.Since,
+		ForceFull: req.Options.ForceFull,
+		Tags:      req.Options.Tags,
+		Priority:  req.Priority.String(),
+		Tenant:    domain.TenantFromContext(ctx),
+		CreatedAt: s.clock.Now().UTC(),
+	}
+	if requestID, ok := ctx.Value(logger.RequestIDKey).(string); ok {
+		job.RequestID = requestID
+	}
+	if err := s.jobs.Save(ctx, job); err != nil {
+		return nil, false, err
+	}
+
+	tracker := domain.NewProgressTracker()
+	s.mutex.Lock()
+	s.trackers[job.ID] = tracker
+	s.done[job.ID] = make(chan struct{})
+	s.mutex.Unlock()
+
+	// The job outlives the request and is cancelled on shutdown instead
+	runCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stopCancel := context.AfterFunc(s.ctx, cancel)
+
+	var summary *domain.RunSummary
+	s.wg.Add(1)
+	err := s.jobQueue.Go(runCtx, domain.JobTypeIngest, req.Priority, func(ctx context.Context) error {
+		s.update(ctx, job.ID, func(job *domain.IngestJob) {
+			started := s.clock.Now().UTC()
+			job.State = domain.IngestJobRunning
+			job.StartedAt = &started
+		})
+
+		ctx = domain.WithProgressTracker(ctx, tracker)
+		var err error
+		if req.Pipeline != "" {
+			_, summary, err = s.pipelineService.RunPipeline(ctx, req.Pipeline, req.Options.Tags)
+		} else {
+			summary, err = s.etlService.RunETLWithOptions(ctx, req.Options)
+		}
+		return err
+	}, func(err error) {
+		defer s.wg.Done()
+		stopCancel()
+		cancel()
+		s.finish(runCtx, job.ID, summary, err)
+	})
+	if err != nil {
+		s.wg.Done()
+		stopCancel()
+		cancel()
+		s.finish(ctx, job.ID, nil, err)
+		return nil, false, err
+	}
+
+	s.logger.WithContext(ctx).WithFields(map[string]any{
+		"job_id":   job.ID,
+		"pipeline": job.Pipeline,
+		"priority": job.Priority,
+		"run_key":  job.RunKey,
+	}).Info("Ingest job queued")
+	s.withLinks(&job)
+	return &job, true, nil
+}
+
+// Get returns a job with the progress of its run while it is running
+func (s *IngestJobService) Get(ctx context.Context, id string) (*domain.IngestJob, error) {
+	job, err := s.jobs.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	s.withProgress(job)
+	return job, nil
+}
+
+// Wait returns the job once it has finished, or as it is when the timeout
+// passes or ctx is done first
+func (s *IngestJobService) Wait(ctx context.Context, id string, timeout time.Duration) (*domain.IngestJob, error) {
+	s.mutex.Lock()
+	done := s.done[id]
+	s.mutex.Unlock()
+
+	if done != nil {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		select {
+		case <-done:
+		case <-timer.C:
+		case <-ctx.Done():
+		}
+	}
+	return s.Get(context.WithoutCancel(ctx), id)
+}
+
+// List returns the most recent jobs first
+func (s *IngestJobService) List(ctx context.Context, limit int) ([]domain.IngestJob, error) {
+	jobs, err := s.jobs.List(ctx, limit)
+	if err != nil {
+		return nil, err
+	}
+	for i := range jobs {
+		s.withProgress(&jobs[i])
+	}
+	return jobs, nil
+}
+
+// Shutdown waits for queued and running jobs until ctx is done, then
+// cancels the rest and waits for them to return
+func (s *IngestJobService) Shutdown(ctx context.Context) {
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		s.logger.Warn("Cancelling unfinished ingest jobs")
+		s.stop()
+		<-done
+	}
+}
+
+// fills in the run ID and progress of a running job, and the job's links
+func (s *IngestJobService) withProgress(job *domain.IngestJob) {
+	defer s.withLinks(job)
+	if job.Finished() {
+		return
+	}
+	s.mutex.Lock()
+	tracker := s.trackers[job.ID]
+	s.mutex.Unlock()
+	if tracker == nil || job.State != domain.IngestJobRunning {
+		return
+	}
+	runID, progress := tracker.Snapshot()
+	job.RunID = runID
+	job.Progress = &progress
+}
+
+// records the outcome of a job
+func (s *IngestJobService) finish(ctx context.Context, id string, summary *domain.RunSummary, err error) {
+	s.mutex.Lock()
+	tracker := s.trackers[id]
+	delete(s.trackers, id)
+	done := s.done[id]
+	delete(s.done, id)
+	s.mutex.Unlock()
+	if done != nil {
+		defer close(done)
+	}
+
+	s.update(ctx, id, func(job *domain.IngestJob) {
+		finished := s.clock.Now().UTC()
+		job.FinishedAt = &finished
+		job.Summary = summary
+		job.Progress = nil
+		if tracker != nil {
+			job.RunID, _ = tracker.Snapshot()
+		}
+		if summary != nil {
+			job.RunID = summary.ID
+		}
+
+		job.State = domain.IngestJobSucceeded
+		job.Terminal = true
+		if err != nil {
+			job.State = domain.IngestJobFailed
+			job.Error = err.Error()
+			job.ErrorCode, job.Retryable = domain.IngestJobErrorCode(err)
+		}
+	})
+
+	log := s.logger.WithContext(ctx).WithField("job_id", id)
+	if err != nil {
+		log.WithError(err).Error("Ingest job failed")
+		return
+	}
+	log.Info("Ingest job succeeded")
+}
+
+// fills in the links of the job and, once it has started, of its run
+func (s *IngestJobService) withLinks(job *domain.IngestJob) {
+	links := &domain.IngestJobLinks{Self: s.baseURL + "/api/v1/ingest/jobs/" + job.ID}
+	if job.RunID != "" {
+		runURL := s.baseURL + "/api/v1/ingest/runs/" + job.RunID
+		links.Run = runURL
+		links.JoinReport = runURL + "/join-report"
+		if job.State == domain.IngestJobSucceeded {
+			links.Certification = runURL + "/certification"
+		}
+		links.Events = s.baseURL + "/api/v1/events?ingest_run_id=" + url.QueryEscape(job.RunID)
+	}
+	if s.logsURL != "" {
+		links.Logs = strings.NewReplacer(
+			"{job_id}", url.QueryEscape(job.ID),
+			"{run_id}", url.QueryEscape(job.RunID),
+			"{request_id}", url.QueryEscape(job.RequestID),
+		).Replace(s.logsURL)
+	}
+	job.Links = links
+}
+
+// applies a change to a stored job
+func (s *IngestJobService) update(ctx context.Context, id string, change func(job *domain.IngestJob)) {
+	job, err := s.jobs.Get(ctx, id)
+	if err == nil {
+		change(job)
+		err = s.jobs.Save(ctx, *job)
+	}
+	if err != nil {
+		s.logger.WithContext(ctx).WithError(err).WithField("job_id", id).Error("Failed to update ingest job")
+	}
+}
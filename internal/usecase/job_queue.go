@@ -0,0 +1,255 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"etlgo/internal/domain"
+	"etlgo/pkg/logger"
+	"etlgo/pkg/metrics"
+)
+
+// JobQueue admits ingest and export work by priority. Each job type has its
+// own concurrency limit and all types share a global limit, so when slots
+// free up the highest priority waiting job runs first regardless of type.
+type JobQueue struct {
+	limits         map[domain.JobType]int
+	backfillWindow time.Duration
+	maxActive      int
+	running        map[domain.JobType]int
+	active         int
+	waiting        []*queuedJob
+	seq            uint64
+	paused         bool
+	mutex          sync.Mutex
+	clock          domain.Clock
+	logger         *logger.Logger
+	metrics        *metrics.Metrics
+}
+
+type queuedJob struct {
+	jobType  domain.JobType
+	priority domain.JobPriority
+	seq      uint64
+	ready    chan struct{}
+}
+
+// NewJobQueue creates a job queue with per type and global concurrency limits.
+// Ingest runs reaching further back than backfillWindow of the clock's time
+// are treated as backfills.
+func NewJobQueue(limits map[domain.JobType]int, maxActive int, backfillWindow time.Duration, clock domain.Clock, logger *logger.Logger, metrics *metrics.Metrics) *JobQueue {
+	return &JobQueue{
+		limits:         limits,
+		backfillWindow: backfillWindow,
+		maxActive:      maxActive,
+		running:        make(map[domain.JobType]int),
+		clock:          clock,
+		logger:         logger,
+		metrics:        metrics,
+	}
+}
+
+// Run waits for a free slot and executes fn. It returns ErrJobQueueTimeout
+// if ctx is cancelled before the job is admitted and ErrMaintenance while
+// the queue is paused.
+func (q *JobQueue) Run(ctx context.Context, jobType domain.JobType, priority domain.JobPriority, fn func(ctx context.Context) error) error {
+	enqueued := time.Now()
+	job, err := q.enqueue(jobType, priority)
+	if err != nil {
+		q.metrics.RecordJobQueueWait(string(jobType), "rejected", 0)
+		return err
+	}
+	return q.await(ctx, job, enqueued, fn)
+}
+
+// Go queues fn like Run but returns once it is queued, waiting for a slot
+// and executing it in the background; done receives what Run would have
+// returned. It returns ErrMaintenance without queueing while the queue is
+// paused.
+func (q *JobQueue) Go(ctx context.Context, jobType domain.JobType, priority domain.JobPriority, fn func(ctx context.Context) error, done func(error)) error {
+	enqueued := time.Now()
+	job, err := q.enqueue(jobType, priority)
+	if err != nil {
+		q.metrics.RecordJobQueueWait(string(jobType), "rejected", 0)
+		return err
+	}
+	go func() { done(q.await(ctx, job, enqueued, fn)) }()
+	return nil
+}
+
+// waits until the queued job is admitted and executes fn
+func (q *JobQueue) await(ctx context.Context, job *queuedJob, enqueued time.Time, fn func(ctx context.Context) error) error {
+	jobType, priority := job.jobType, job.priority
+
+	select {
+	case <-job.ready:
+	case <-ctx.Done():
+		if q.cancel(job) {
+			q.metrics.RecordJobQueueWait(string(jobType), "timeout", time.Since(enqueued))
+			return fmt.Errorf("%w: %v", domain.ErrJobQueueTimeout, ctx.Err())
+		}
+		// Admitted concurrently with cancellation, release the slot
+		<-job.ready
+		q.release(jobType)
+		return fmt.Errorf("%w: %v", domain.ErrJobQueueTimeout, ctx.Err())
+	}
+
+	wait := time.Since(enqueued)
+	q.metrics.RecordJobQueueWait(string(jobType), "admitted", wait)
+	q.logger.WithContext(ctx).WithFields(map[string]any{
+		"job_type": jobType,
+		"priority": priority.String(),
+		"wait":     wait,
+	}).Info("Job admitted from queue")
+
+	defer q.release(jobType)
+	return fn(ctx)
+}
+
+// IngestPriority returns the default priority for an ingest run; backfills
+// run at low priority so they don't starve scheduled and interactive work
+func (q *JobQueue) IngestPriority(since *time.Time) domain.JobPriority {
+	if q.IsBackfill(since) {
+		return domain.PriorityLow
+	}
+	return domain.PriorityNormal
+}
+
+// IsBackfill returns true if an ingest run since the date reaches back
+// further than the backfill window. A run without since reads the sources'
+// whole history, so it is one too.
+func (q *JobQueue) IsBackfill(since *time.Time) bool {
+	return since == nil || q.clock.Now().Sub(*since) > q.backfillWindow
+}
+
+// Stats returns the queue state for every configured job type
+func (q *JobQueue) Stats() []domain.JobQueueStats {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	queued := make(map[domain.JobType]int)
+	for _, job := range q.waiting {
+		queued[job.jobType]++
+	}
+
+	stats := make([]domain.JobQueueStats, 0, len(q.limits))
+	for jobType, limit := range q.limits {
+		stats = append(stats, domain.JobQueueStats{
+			Type:        jobType,
+			Running:     q.running[jobType],
+			Queued:      queued[jobType],
+			Concurrency: limit,
+		})
+	}
+
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Type < stats[j].Type })
+	return stats
+}
+
+// Pause rejects new jobs and holds waiting ones; running jobs finish
+func (q *JobQueue) Pause() {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.paused = true
+}
+
+// Resume accepts jobs again and admits the ones held while paused
+func (q *JobQueue) Resume() {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.paused = false
+	q.dispatch()
+}
+
+// Running returns the number of jobs currently executing
+func (q *JobQueue) Running() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	return q.active
+}
+
+func (q *JobQueue) enqueue(jobType domain.JobType, priority domain.JobPriority) (*queuedJob, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if q.paused {
+		return nil, domain.ErrMaintenance
+	}
+
+	q.seq++
+	job := &queuedJob{jobType: jobType, priority: priority, seq: q.seq, ready: make(chan struct{})}
+	q.waiting = append(q.waiting, job)
+	q.metrics.SetJobQueueDepth(string(jobType), q.countWaiting(jobType))
+
+	q.dispatch()
+	return job, nil
+}
+
+// removes a job that is still waiting, returns false if it was already admitted
+func (q *JobQueue) cancel(job *queuedJob) bool {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	for i, waiting := range q.waiting {
+		if waiting == job {
+			q.waiting = append(q.waiting[:i], q.waiting[i+1:]...)
+			q.metrics.SetJobQueueDepth(string(job.jobType), q.countWaiting(job.jobType))
+			return true
+		}
+	}
+	return false
+}
+
+func (q *JobQueue) release(jobType domain.JobType) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.running[jobType]--
+	q.active--
+	q.dispatch()
+}
+
+// admits waiting jobs while capacity allows; must be called with the lock held
+func (q *JobQueue) dispatch() {
+	if q.paused {
+		return
+	}
+
+	// Highest priority first, FIFO within a priority
+	sort.SliceStable(q.waiting, func(i, j int) bool {
+		if q.waiting[i].priority != q.waiting[j].priority {
+			return q.waiting[i].priority > q.waiting[j].priority
+		}
+		return q.waiting[i].seq < q.waiting[j].seq
+	})
+
+	for i := 0; i < len(q.waiting) && (q.maxActive <= 0 || q.active < q.maxActive); {
+		job := q.waiting[i]
+		if limit := q.limits[job.jobType]; limit > 0 && q.running[job.jobType] >= limit {
+			i++
+			continue
+		}
+
+		q.waiting = append(q.waiting[:i], q.waiting[i+1:]...)
+		q.running[job.jobType]++
+		q.active++
+		q.metrics.SetJobQueueDepth(string(job.jobType), q.countWaiting(job.jobType))
+		close(job.ready)
+	}
+}
+
+func (q *JobQueue) countWaiting(jobType domain.JobType) int {
+	count := 0
+	for _, job := range q.waiting {
+		if job.jobType == jobType {
+			count++
+		}
+	}
+	return count
+}
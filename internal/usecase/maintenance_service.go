@@ -0,0 +1,115 @@
+package usecase
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"etlgo/internal/domain"
+	"etlgo/pkg/logger"
+	"etlgo/pkg/metrics"
+)
+
+// MaintenanceService switches maintenance mode, during which the job queue
+// and any registered schedulers are paused while read queries keep working
+type MaintenanceService struct {
+	jobQueue   *JobQueue
+	pausables  []domain.Pausable
+	retryAfter time.Duration
+	status     domain.MaintenanceStatus
+	mutex      sync.RWMutex
+	clock      domain.Clock
+	logger     *logger.Logger
+	metrics    *metrics.Metrics
+}
+
+// NewMaintenanceService creates a maintenance service. retryAfter is the
+// default delay clients are told to wait; schedulers are paused along with
+// the job queue.
+func NewMaintenanceService(
+	jobQueue *JobQueue,
+	retryAfter time.Duration,
+	clock domain.Clock,
+	logger *logger.Logger,
+	metrics *metrics.Metrics,
+	schedulers ...domain.Pausable,
+) *MaintenanceService {
+	return &MaintenanceService{
+		jobQueue:   jobQueue,
+		pausables:  append([]domain.Pausable{jobQueue}, schedulers...),
+		retryAfter: retryAfter,
+		clock:      clock,
+		logger:     logger,
+		metrics:    metrics,
+	}
+}
+
+// Enable turns maintenance mode on. A zero retryAfter uses the default.
+// Enabling again updates the reason and retry delay but keeps the start time.
+func (s *MaintenanceService) Enable(ctx context.Context, reason, actor string, retryAfter time.Duration) domain.MaintenanceStatus {
+	if retryAfter <= 0 {
+		retryAfter = s.retryAfter
+	}
+
+	s.mutex.Lock()
+	if !s.status.Enabled {
+		now := s.clock.Now().UTC()
+		s.status = domain.MaintenanceStatus{Enabled: true, StartedAt: &now}
+		for _, p := range s.pausables {
+			p.Pause()
+		}
+	}
+	s.status.Reason = reason
+	s.status.Actor = actor
+	s.status.RetryAfter = int(retryAfter.Seconds())
+	s.mutex.Unlock()
+
+	s.metrics.SetMaintenanceMode(true)
+	s.logger.WithContext(ctx).WithFields(map[string]any{
+		"reason": reason,
+		"actor":  actor,
+	}).Warn("Maintenance mode enabled")
+
+	return s.Status()
+}
+
+// Disable turns maintenance mode off and resumes paused work
+func (s *MaintenanceService) Disable(ctx context.Context, actor string) domain.MaintenanceStatus {
+	s.mutex.Lock()
+	wasEnabled := s.status.Enabled
+	if wasEnabled {
+		for _, p := range s.pausables {
+			p.Resume()
+		}
+	}
+	s.status = domain.MaintenanceStatus{}
+	s.mutex.Unlock()
+
+	s.metrics.SetMaintenanceMode(false)
+	if wasEnabled {
+		s.logger.WithContext(ctx).WithField("actor", actor).Info("Maintenance mode disabled")
+	}
+
+	return s.Status()
+}
+
+// Status returns the maintenance state with the number of jobs still running
+func (s *MaintenanceService) Status() domain.MaintenanceStatus {
+	s.mutex.RLock()
+	status := s.status
+	s.mutex.RUnlock()
+
+	status.RunningJobs = s.jobQueue.Running()
+	return status
+}
+
+// RetryAfter returns how long clients should wait before retrying a job
+func (s *MaintenanceService) RetryAfter() time.Duration {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if s.status.Enabled && s.status.RetryAfter > 0 {
+		return time.Duration(s.status.RetryAfter) * time.Second
+	}
+	return s.retryAfter
+}
@@ -0,0 +1,71 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"etlgo/internal/domain"
+)
+
+// WriteMetricsBatch stores the pre-computed metrics of another pipeline
+// alongside the ETL's, tagged with producer, the name of the API key
+// writing them. They replace only that producer's earlier rows of the same
+// date, UTM and ad group, and ETL runs leave them alone. Derived ratios are
+// recalculated from the totals. An invalid row rejects the whole batch with
+// a validation error, the invalid rows listed in the result.
+func (s *MetricsService) WriteMetricsBatch(ctx context.Context, producer string, batch domain.MetricsBatch) (*domain.MetricsBatchResult, error) {
+	if s.batchMax > 0 && len(batch.Metrics) > s.batchMax {
+		return nil, fmt.Errorf("%w: %d metrics, limit %d", domain.ErrPushBatchTooLarge, len(batch.Metrics), s.batchMax)
+	}
+
+	now := s.clock.Now()
+	result := &domain.MetricsBatchResult{Producer: producer}
+	if errs := batch.Validate(producer, s.funnel, now.UTC().Truncate(24*time.Hour)); len(errs) > 0 {
+		result.Errors = errs
+		return result, domain.Errorf(domain.ErrValidation, "%d of %d metrics are invalid", invalidRecords(errs), len(batch.Metrics))
+	}
+
+	rows := make([]domain.BusinessMetrics, len(batch.Metrics))
+	var from, to time.Time
+	for i, metric := range batch.Metrics {
+		metric.Date = metric.Date.UTC().Truncate(24 * time.Hour)
+		metric.Producer = producer
+		metric.TransformVersion = ""
+		metric.CalculatedAt = now
+		metric.Hidden = nil
+		deriveMetricRatios(&metric, s.funnel)
+		rows[i] = metric
+
+		if from.IsZero() || metric.Date.Before(from) {
+			from = metric.Date
+		}
+		if metric.Date.After(to) {
+			to = metric.Date
+		}
+	}
+
+	if err := s.metricsRepo.Upsert(ctx, rows); err != nil {
+		return nil, fmt.Errorf("failed to store metrics of %s: %w", producer, err)
+	}
+	s.metrics.RecordMetricsBatch(producer, len(rows))
+
+	result.Accepted = len(rows)
+	result.From, result.To = from.Format("2006-01-02"), to.Format("2006-01-02")
+	s.logger.WithContext(ctx).WithFields(map[string]interface{}{
+		"producer": producer,
+		"metrics":  len(rows),
+		"from":     result.From,
+		"to":       result.To,
+	}).Info("Metrics batch written")
+	return result, nil
+}
+
+// returns the number of distinct records with errors
+func invalidRecords(errs []domain.RecordError) int {
+	records := make(map[string]bool, len(errs))
+	for _, err := range errs {
+		records[err.Record] = true
+	}
+	return len(records)
+}
@@ -0,0 +1,98 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"etlgo/internal/domain"
+)
+
+// PutFXRates validates and stores daily FX rates, replacing stored rates of
+// the same currency and date
+func (s *MetricsService) PutFXRates(ctx context.Context, rates []domain.FXRate) error {
+	for i := range rates {
+		if err := rates[i].Validate(); err != nil {
+			return fmt.Errorf("rate %d: %w", i+1, err)
+		}
+		if rates[i].Currency == s.baseCurrency {
+			return domain.Errorf(domain.ErrValidation, "rate %d: %s is the base currency", i+1, s.baseCurrency)
+		}
+	}
+	if err := s.fxRates.Put(ctx, rates); err != nil {
+		return fmt.Errorf("failed to store FX rates: %w", err)
+	}
+	return nil
+}
+
+// ListFXRates returns the stored rates of a currency in the range, oldest
+// first
+func (s *MetricsService) ListFXRates(ctx context.Context, currency string, from, to time.Time) ([]domain.FXRate, error) {
+	currency, err := domain.NormalizeCurrency(currency)
+	if err != nil {
+		return nil, err
+	}
+	rates, err := s.fxRates.List(ctx, currency, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list FX rates: %w", err)
+	}
+	return rates, nil
+}
+
+// returns a copy of the metrics with their amounts converted from the base
+// currency into the currency, each at the rate of its own date. No
+// conversion is described for an empty currency, and none is needed for the
+// base currency.
+func (s *MetricsService) convertCurrency(ctx context.Context, currency string, metrics []domain.BusinessMetrics) ([]domain.BusinessMetrics, *domain.CurrencyConversion, error) {
+	if currency == "" {
+		return metrics, nil, nil
+	}
+	currency, err := domain.NormalizeCurrency(currency)
+	if err != nil {
+		return nil, nil, err
+	}
+	conversion := &domain.CurrencyConversion{Currency: currency, Base: s.baseCurrency, Rates: []domain.AppliedRate{}}
+	if currency == s.baseCurrency {
+		return metrics, conversion, nil
+	}
+
+	// The repository may hand out its own slice, don't convert it in place
+	converted := slices.Clone(metrics)
+	rates := make(map[time.Time]*domain.FXRate)
+	for i := range converted {
+		day := converted[i].Date.UTC().Truncate(24 * time.Hour)
+		rate, ok := rates[day]
+		if !ok {
+			if rate, err = fxRateOn(ctx, s.fxRates, currency, day); err != nil {
+				return nil, nil, err
+			}
+			rates[day] = rate
+		}
+		converted[i].ConvertCurrency(rate.Rate)
+	}
+
+	applied := make(map[time.Time]float64)
+	for _, rate := range rates {
+		applied[rate.Date] = rate.Rate
+	}
+	for _, date := range slices.SortedFunc(maps.Keys(applied), time.Time.Compare) {
+		conversion.Rates = append(conversion.Rates, domain.AppliedRate{RateDate: date.Format("2006-01-02"), Rate: applied[date]})
+	}
+	return converted, conversion, nil
+}
+
+// returns the rate of the currency for the day, refusing rates older than
+// domain.FXRateMaxAgeDays
+func fxRateOn(ctx context.Context, rates domain.FXRateRepository, currency string, day time.Time) (*domain.FXRate, error) {
+	rate, err := rates.RateOn(ctx, currency, day)
+	if err != nil {
+		return nil, err
+	}
+	if day.Sub(rate.Date) > domain.FXRateMaxAgeDays*24*time.Hour {
+		return nil, fmt.Errorf("%w of %s within %d days before %s, the latest is of %s",
+			domain.ErrNoFXRate, currency, domain.FXRateMaxAgeDays, day.Format("2006-01-02"), rate.Date.Format("2006-01-02"))
+	}
+	return rate, nil
+}
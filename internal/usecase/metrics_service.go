@@ -1,8 +1,12 @@
 package usecase
 
 import (
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
+	"math"
+	"slices"
 	"time"
 
 	"etlgo/internal/domain"
@@ -13,28 +17,85 @@ import (
 // MetricsService handles business metrics operations
 type MetricsService struct {
 	metricsRepo  domain.MetricsRepository
+	runs         domain.RunRepository
+	holds        domain.ExportHoldRepository
+	snapshots    domain.ExportSnapshotRepository
 	exportClient domain.ExportClient
+	destinations map[string]domain.ExportDestination
+	exportMode   string
+	budgets      domain.QuotaLimits
+	budgetMode   string
+	roles        domain.Roles
+	fxRates      domain.FXRateRepository
+	baseCurrency string
+	funnel       domain.Funnel
+	batchMax     int
+	acks         *ExportAckService
+	clock        domain.Clock
 	logger       *logger.Logger
 	metrics      *metrics.Metrics
 }
 
-// NewMetricsService creates a new metrics service
+// NewMetricsService creates a new metrics service. budgets are the rows a
+// query may scan per API key name, * for every other caller; budgetMode is
+// how queries over budget are handled. roles hide columns from the
+// responses and exports of the API keys assigned to them. Stored amounts are in baseCurrency
+// and converted to other currencies with fxRates. Summaries convert between
+// the stages of the funnel. Producers may write up to batchMax metrics per
+// batch, unlimited when zero. Exports may also go to the named destinations,
+// shaped by their transforms, and await their sink's ack through acks.
+// Exports are sent in exportMode unless their destination has its own;
+// snapshots keep what was last sent for diff exports.
 func NewMetricsService(
 	metricsRepo domain.MetricsRepository,
+	runs domain.RunRepository,
+	holds domain.ExportHoldRepository,
+	snapshots domain.ExportSnapshotRepository,
 	exportClient domain.ExportClient,
+	destinations []domain.ExportDestination,
+	exportMode string,
+	budgets domain.QuotaLimits,
+	budgetMode string,
+	roles domain.Roles,
+	fxRates domain.FXRateRepository,
+	baseCurrency string,
+	funnel domain.Funnel,
+	batchMax int,
+	acks *ExportAckService,
+	clock domain.Clock,
 	logger *logger.Logger,
 	metrics *metrics.Metrics,
 ) *MetricsService {
+	byName := make(map[string]domain.ExportDestination, len(destinations))
+	for _, destination := range destinations {
+		byName[destination.Name] = destination
+	}
+
 	return &MetricsService{
 		metricsRepo:  metricsRepo,
+		runs:         runs,
+		holds:        holds,
+		snapshots:    snapshots,
 		exportClient: exportClient,
+		destinations: byName,
+		exportMode:   exportMode,
+		budgets:      budgets,
+		budgetMode:   budgetMode,
+		roles:        roles,
+		fxRates:      fxRates,
+		baseCurrency: baseCurrency,
+		funnel:       funnel,
+		batchMax:     batchMax,
+		acks:         acks,
+		clock:        clock,
 		logger:       logger,
 		metrics:      metrics,
 	}
 }
 
-// GetMetricsByChannel retrieves metrics filtered by channel
-func (s *MetricsService) GetMetricsByChannel(ctx context.Context, channel string, from, to time.Time, limit, offset int) (*domain.MetricsResponse, error) {
+// GetMetricsByChannel retrieves metrics filtered by channel, with amounts in
+// the currency, the base currency when empty
+func (s *MetricsService) GetMetricsByChannel(ctx context.Context, channel string, from, to time.Time, limit, offset int, currency string) (*domain.MetricsResponse, error) {
 	log := s.logger.WithContext(ctx)
 	log.WithFields(map[string]interface{}{
 		"channel": channel,
@@ -51,21 +112,35 @@ func (s *MetricsService) GetMetricsByChannel(ctx context.Context, channel string
 		Limit:   limit,
 		Offset:  offset,
 	}
+	if err := scopeFilter(ctx, &filter); err != nil {
+		return nil, err
+	}
+	cost, err := s.budgetFilter(ctx, &filter)
+	if err != nil {
+		return nil, err
+	}
 
 	response, err := s.metricsRepo.GetByFilter(ctx, filter)
 	if err != nil {
 		log.WithError(err).Error("Failed to get metrics by channel")
 		return nil, fmt.Errorf("failed to get metrics by channel: %w", err)
 	}
+	if response.Data, response.Currency, err = s.convertCurrency(ctx, currency, response.Data); err != nil {
+		return nil, err
+	}
+	s.maskRows(ctx, response.Data)
 
+	response.QueryCost = cost
+	s.addLastRun(ctx, response.Meta)
 	s.metrics.RecordBusinessMetric("channel_query")
 
 	log.WithField("count", len(response.Data)).Info("Retrieved metrics by channel")
 	return response, nil
 }
 
-// GetMetricsByFunnel retrieves metrics filtered by UTM campaign (funnel analysis)
-func (s *MetricsService) GetMetricsByFunnel(ctx context.Context, utmCampaign string, from, to time.Time, limit, offset int) (*domain.MetricsResponse, error) {
+// GetMetricsByFunnel retrieves metrics filtered by UTM campaign (funnel
+// analysis), with amounts in the currency, the base currency when empty
+func (s *MetricsService) GetMetricsByFunnel(ctx context.Context, utmCampaign string, from, to time.Time, limit, offset int, currency string) (*domain.MetricsResponse, error) {
 	log := s.logger.WithContext(ctx)
 	log.WithFields(map[string]interface{}{
 		"utm_campaign": utmCampaign,
@@ -82,13 +157,35 @@ func (s *MetricsService) GetMetricsByFunnel(ctx context.Context, utmCampaign str
 		Limit:       limit,
 		Offset:      offset,
 	}
+	if err := scopeFilter(ctx, &filter); err != nil {
+		return nil, err
+	}
+	cost, err := s.budgetFilter(ctx, &filter)
+	if err != nil {
+		return nil, err
+	}
 
 	response, err := s.metricsRepo.GetByFilter(ctx, filter)
 	if err != nil {
 		log.WithError(err).Error("Failed to get metrics by funnel")
 		return nil, fmt.Errorf("failed to get metrics by funnel: %w", err)
 	}
+	if response.Data, response.Currency, err = s.convertCurrency(ctx, currency, response.Data); err != nil {
+		return nil, err
+	}
+	s.maskRows(ctx, response.Data)
+
+	// The breakdown covers the whole funnel, not just the returned page
+	filter.Limit, filter.Offset = 0, 0
+	all, err := s.readAllMetrics(ctx, filter)
+	if err != nil {
+		log.WithError(err).Error("Failed to get metrics by funnel")
+		return nil, fmt.Errorf("failed to get funnel breakdown: %w", err)
+	}
+	response.Funnel = domain.NewFunnelBreakdown(all)
 
+	response.QueryCost = cost
+	s.addLastRun(ctx, response.Meta)
 	s.metrics.RecordBusinessMetric("funnel_query")
 
 	log.WithField("count", len(response.Data)).Info("Retrieved metrics by funnel")
@@ -106,38 +203,155 @@ func (s *MetricsService) GetMetricsByFilter(ctx context.Context, filter domain.M
 		"utm_campaign": filter.UTMCampaign,
 		"utm_source":   filter.UTMSource,
 		"utm_medium":   filter.UTMMedium,
+		"ad_group_id":  filter.AdGroupID,
 		"limit":        filter.Limit,
 		"offset":       filter.Offset,
 	}).Info("Getting metrics by filter")
 
+	if err := scopeFilter(ctx, &filter); err != nil {
+		return nil, err
+	}
+	cost, err := s.budgetFilter(ctx, &filter)
+	if err != nil {
+		return nil, err
+	}
+
 	response, err := s.metricsRepo.GetByFilter(ctx, filter)
 	if err != nil {
 		log.WithError(err).Error("Failed to get metrics by filter")
 		return nil, fmt.Errorf("failed to get metrics by filter: %w", err)
 	}
+	s.maskRows(ctx, response.Data)
 
+	response.QueryCost = cost
+	s.addLastRun(ctx, response.Meta)
 	s.metrics.RecordBusinessMetric("filter_query")
 
 	log.WithField("count", len(response.Data)).Info("Retrieved metrics by filter")
 	return response, nil
 }
 
-// ExportMetrics exports metrics for a specific date
+// GetTimeSeries returns a metric over time in the caller's scope, bucketed
+// and gap filled for charting
+func (s *MetricsService) GetTimeSeries(ctx context.Context, query domain.TimeSeriesQuery) (*domain.TimeSeries, error) {
+	if err := query.Validate(); err != nil {
+		return nil, err
+	}
+	if err := s.ColumnMask(ctx).Check(query.Metric); err != nil {
+		return nil, err
+	}
+
+	metrics, err := s.readAllMetrics(ctx, domain.MetricsFilter{From: &query.From, To: &query.To})
+	if err != nil {
+		s.logger.WithContext(ctx).WithError(err).Error("Failed to get time series")
+		return nil, fmt.Errorf("failed to get time series: %w", err)
+	}
+
+	s.metrics.RecordBusinessMetric("timeseries_query")
+	return domain.NewTimeSeries(query, metrics), nil
+}
+
+// GetDimensionValues returns the distinct values of a dimension with their row counts
+func (s *MetricsService) GetDimensionValues(ctx context.Context, dimension string, from, to time.Time) ([]domain.DimensionValue, error) {
+	log := s.logger.WithContext(ctx)
+	log.WithFields(map[string]interface{}{
+		"dimension": dimension,
+		"from":      from.Format("2006-01-02"),
+		"to":        to.Format("2006-01-02"),
+	}).Info("Getting dimension values")
+
+	if !domain.IsValidDimension(dimension) {
+		return nil, domain.Errorf(domain.ErrValidation, "unsupported dimension %q", dimension)
+	}
+
+	values, err := s.distinctValues(ctx, dimension, from, to)
+	if err != nil {
+		log.WithError(err).Error("Failed to get dimension values")
+		return nil, fmt.Errorf("failed to get dimension values: %w", err)
+	}
+
+	s.metrics.RecordBusinessMetric("dimension_query")
+
+	log.WithField("count", len(values)).Info("Retrieved dimension values")
+	return values, nil
+}
+
+// ExportMetrics exports metrics for a specific date. Dates with a pending
+// export hold are refused with domain.ErrExportHeld.
 func (s *MetricsService) ExportMetrics(ctx context.Context, date time.Time) error {
+	_, err := s.ExportMetricsInCurrency(ctx, date, "")
+	return err
+}
+
+// ExportMetricsInCurrency exports metrics for a specific date with amounts
+// in the currency, the base currency when empty
+func (s *MetricsService) ExportMetricsInCurrency(ctx context.Context, date time.Time, currency string) (*domain.CurrencyConversion, error) {
+	result, err := s.ExportMetricsTo(ctx, date, currency, "", "")
+	if err != nil {
+		return nil, err
+	}
+	return result.Conversion, nil
+}
+
+// ExportMetricsTo exports metrics for a specific date to the named
+// destination, shaped by its transforms, or to the sink when destination
+// is empty. Scoped callers export only the rows in their scope, and only in
+// full. Diff exports send only the rows changed since the date's last
+// export to the destination; an empty mode uses the destination's. With
+// export acks enabled the result holds the delivery awaiting the sink's
+// ack.
+func (s *MetricsService) ExportMetricsTo(ctx context.Context, date time.Time, currency, destination, mode string) (*domain.ExportResult, error) {
 	log := s.logger.WithContext(ctx)
-	log.WithField("date", date.Format("2006-01-02")).Info("Starting metrics export")
+	log.WithFields(map[string]interface{}{
+		"date":        date.Format("2006-01-02"),
+		"destination": destination,
+		"mode":        mode,
+	}).Info("Starting metrics export")
+
+	target, ok := s.destinations[destination]
+	if destination != "" && !ok {
+		return nil, fmt.Errorf("%w: %s", domain.ErrExportDestinationNotFound, destination)
+	}
+	if err := domain.ValidateExportMode(mode); err != nil {
+		return nil, domain.Errorf(domain.ErrValidation, "%w", err)
+	}
+	mode = cmp.Or(mode, target.Mode, s.exportMode, domain.ExportModeFull)
+
+	day := date.Truncate(24 * time.Hour)
+	held, err := s.holds.List(ctx, domain.ExportHoldFilter{Status: domain.ExportHoldPending, Date: &day, Limit: 1})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get export holds: %w", err)
+	}
+	if len(held) > 0 {
+		log.WithField("hold_id", held[0].ID).Warn("Exports of the date are held")
+		return nil, domain.Errorf(domain.ErrExportHeld, "exports for %s are held pending approval of %s", day.Format("2006-01-02"), held[0].ID)
+	}
+
+	// Diffs compare every row of the date with the last export, so a scoped
+	// subset would read as the other rows being deleted
+	scope := domain.MetricsScopeFromContext(ctx)
+	if len(scope) > 0 && mode == domain.ExportModeDiff {
+		return nil, fmt.Errorf("%w: diff exports need an unscoped API key", domain.ErrOutOfScope)
+	}
 
 	// Get metrics for the specified date
 	metrics, err := s.metricsRepo.GetByDate(ctx, date)
 	if err != nil {
 		log.WithError(err).Error("Failed to get metrics for export")
-		return fmt.Errorf("failed to get metrics for export: %w", err)
+		return nil, fmt.Errorf("failed to get metrics for export: %w", err)
 	}
+	metrics = domain.InScope(scope, metrics)
 
 	if len(metrics) == 0 {
 		log.Warn("No metrics found for export date")
-		return fmt.Errorf("no metrics found for date %s", date.Format("2006-01-02"))
+		return nil, domain.Errorf(domain.ErrNotFound, "no metrics found for date %s", date.Format("2006-01-02"))
 	}
+	metrics, conversion, err := s.convertCurrency(ctx, currency, metrics)
+	if err != nil {
+		return nil, err
+	}
+
+	hidden := s.ColumnMask(ctx)
 
 	// Convert to export format
 	exportData := make([]domain.ExportData, len(metrics))
@@ -159,47 +373,169 @@ func (s *MetricsService) ExportMetrics(ctx context.Context, date time.Time) erro
 			CVROppToWon:   metric.CVROppToWon,
 			ROAS:          metric.ROAS,
 		}
+		if conversion != nil {
+			exportData[i].Currency = conversion.Currency
+		}
 	}
 
 	// Export data
-	if err := s.exportClient.Export(ctx, exportData, date); err != nil {
+	result := &domain.ExportResult{Mode: mode, Records: len(exportData), Conversion: conversion}
+	exportID := "metrics_" + date.Format("2006-01-02")
+	// the sink has no transforms
+	shaped := target.Apply(exportData, hidden)
+	if destination != "" {
+		result.Records = len(shaped)
+		exportID = target.Name + "_" + exportID
+	}
+
+	switch {
+	case mode == domain.ExportModeDiff:
+		snapshot, err := s.snapshots.Get(ctx, destination, date)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get export snapshot: %w", err)
+		}
+		changed, changes := snapshot.Diff(shaped)
+		result.Records = len(changed)
+		result.Changes = &changes
+		if len(changed) == 0 {
+			log.WithField("unchanged", changes.Unchanged).Info("No metrics changed since the last export")
+			return result, nil
+		}
+		// the sink receives diffs in its destination's format
+		err = s.exportClient.ExportTo(ctx, target, exportID, changed)
+	case destination == "" && len(hidden) == 0:
+		err = s.exportClient.Export(ctx, exportData, date)
+	default:
+		err = s.exportClient.ExportTo(ctx, target, exportID, shaped)
+	}
+	if err != nil {
 		log.WithError(err).Error("Failed to export metrics")
-		return fmt.Errorf("failed to export metrics: %w", err)
+		return nil, fmt.Errorf("failed to export metrics: %w", err)
 	}
 
 	s.metrics.RecordBusinessMetric("export")
+	result.Delivery = s.acks.Delivered(ctx, exportID, destination, result.Records)
+	if len(scope) == 0 {
+		// a scoped export leaves out rows the next diff must still compare
+		s.saveSnapshot(ctx, domain.NewExportSnapshot(destination, date, exportID, shaped, s.clock.Now().UTC()))
+	}
 
-	log.WithField("records", len(exportData)).Info("Metrics export completed successfully")
-	return nil
+	log.WithFields(map[string]interface{}{
+		"mode":    mode,
+		"records": result.Records,
+	}).Info("Metrics export completed successfully")
+	return result, nil
 }
 
-// GetMetricsSummary returns a summary of available metrics
-func (s *MetricsService) GetMetricsSummary(ctx context.Context) (map[string]interface{}, error) {
-	log := s.logger.WithContext(ctx)
-	log.Info("Getting metrics summary")
+// records what was exported for later diff exports. Full exports record it
+// too, so a diff after one only sends what changed since. A failure to
+// store it does not fail the export; the next diff sends more.
+func (s *MetricsService) saveSnapshot(ctx context.Context, snapshot domain.ExportSnapshot) {
+	if err := s.snapshots.Save(ctx, snapshot); err != nil {
+		s.logger.WithContext(ctx).WithError(err).WithField("export_id", snapshot.ExportID).Warn("Failed to save export snapshot")
+	}
+}
 
-	// Get metrics for the last 30 days
-	from := time.Now().AddDate(0, 0, -60)
-	to := time.Now()
+// ListExportHolds returns export holds, most recent first
+func (s *MetricsService) ListExportHolds(ctx context.Context, status string, limit int) ([]domain.ExportHold, error) {
+	holds, err := s.holds.List(ctx, domain.ExportHoldFilter{Status: status, Limit: limit})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list export holds: %w", err)
+	}
+	return holds, nil
+}
 
-	filter := domain.MetricsFilter{
-		From: &from,
-		To:   &to,
+// ApproveExportHold releases a pending export hold and exports its date.
+// The hold stays approved when the export fails, so it can be retried with
+// an export run.
+func (s *MetricsService) ApproveExportHold(ctx context.Context, id, actor string) (*domain.ExportHold, error) {
+	hold, err := s.holds.Approve(ctx, id, actor, s.clock.Now().UTC())
+	if err != nil {
+		return nil, err
 	}
 
-	response, err := s.metricsRepo.GetByFilter(ctx, filter)
+	s.metrics.RecordBusinessMetric("export_hold_approved")
+	s.logger.WithContext(ctx).WithFields(map[string]interface{}{
+		"hold_id": hold.ID,
+		"date":    hold.Date.Format("2006-01-02"),
+		"actor":   actor,
+	}).Info("Export hold approved")
+
+	err = s.ExportMetrics(ctx, hold.Date)
+	if errors.Is(err, domain.ErrNotFound) {
+		// nothing left to export for the date
+		return hold, nil
+	}
+	return hold, err
+}
+
+// VerifyDestination sends a synthetic payload to the named export
+// destination, or the sink when name is empty, and reports whether it
+// accepts signed payloads and rejects unsigned ones
+func (s *MetricsService) VerifyDestination(ctx context.Context, name string) (*domain.SinkVerification, error) {
+	var target domain.SinkTarget
+	if name != "" {
+		destination, ok := s.destinations[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown destination %q", domain.ErrInvalidSinkTarget, name)
+		}
+		target = domain.SinkTarget{URL: destination.URL, Secret: destination.Secret, Compression: destination.Compression}
+	}
+
+	verification, err := s.exportClient.VerifySink(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+
+	log := s.logger.WithContext(ctx).WithFields(map[string]interface{}{
+		"url":    verification.URL,
+		"status": verification.Status,
+	})
+	for _, check := range verification.Checks {
+		if !check.Passed {
+			log = log.WithField(check.Name, check.Detail)
+		}
+	}
+	if verification.Passed {
+		log.Info("Sink verification passed")
+	} else {
+		log.Warn("Sink verification failed")
+	}
+	return verification, nil
+}
+
+// GetMetricsSummary returns a summary of available metrics, with amounts in
+// the currency, the base currency when empty
+func (s *MetricsService) GetMetricsSummary(ctx context.Context, currency string) (map[string]interface{}, error) {
+	log := s.logger.WithContext(ctx)
+	log.Info("Getting metrics summary")
+
+	// Summarize the last 60 days
+	from := s.clock.Now().AddDate(0, 0, -60)
+	to := s.clock.Now()
+
+	data, warnings, err := s.readSummaryMetrics(ctx, from, to)
 	if err != nil {
 		log.WithError(err).Error("Failed to get metrics summary")
 		return nil, fmt.Errorf("failed to get metrics summary: %w", err)
 	}
+	if len(warnings) > 0 {
+		log.WithField("warnings", warnings).Warn("Metrics summary is partial")
+		s.metrics.RecordBusinessMetric("summary_partial")
+	}
+	data, conversion, err := s.convertCurrency(ctx, currency, data)
+	if err != nil {
+		return nil, err
+	}
 
 	// Calculate summary statistics
 	var totalClicks, totalImpressions, totalLeads, totalOpportunities, totalClosedWon int
-	var totalCost, totalRevenue float64
+	var totalCost, totalRevenue, totalPipeline, totalExpected domain.Money
+	totalStages := make(map[domain.OpportunityStage]int)
 	channels := make(map[string]bool)
 	campaigns := make(map[string]bool)
 
-	for _, metric := range response.Data {
+	for _, metric := range data {
 		totalClicks += metric.Clicks
 		totalImpressions += metric.Impressions
 		totalCost += metric.Cost
@@ -207,20 +543,27 @@ func (s *MetricsService) GetMetricsSummary(ctx context.Context) (map[string]inte
 		totalOpportunities += metric.Opportunities
 		totalClosedWon += metric.ClosedWon
 		totalRevenue += metric.Revenue
+		totalPipeline += metric.PipelineValue
+		totalExpected += metric.ExpectedRevenue
+		for stage, count := range metric.Stages {
+			totalStages[stage] += count
+		}
 
 		channels[metric.Channel] = true
 		campaigns[metric.CampaignID] = true
 	}
 
 	// Calculate aggregate metrics
-	var avgCPC, avgCPA, avgCVRLeadToOpp, avgCVROppToWon, avgROAS float64
+	var avgCPC, avgCPA domain.Money
+	var avgCVRClickToLead, avgCVRLeadToOpp, avgCVROppToWon, avgROAS float64
 
 	if totalClicks > 0 {
-		avgCPC = totalCost / float64(totalClicks)
+		avgCPC = totalCost.Div(totalClicks)
+		avgCVRClickToLead = float64(totalLeads) / float64(totalClicks)
 	}
 
 	if totalLeads > 0 {
-		avgCPA = totalCost / float64(totalLeads)
+		avgCPA = totalCost.Div(totalLeads)
 	}
 
 	if totalLeads > 0 {
@@ -232,9 +575,15 @@ func (s *MetricsService) GetMetricsSummary(ctx context.Context) (map[string]inte
 	}
 
 	if totalCost > 0 {
-		avgROAS = totalRevenue / totalCost
+		avgROAS = totalRevenue.Ratio(totalCost)
 	}
 
+	totals := domain.BusinessMetrics{Leads: totalLeads, Opportunities: totalOpportunities, ClosedWon: totalClosedWon, Stages: totalStages}
+	stageConversions := s.funnel.Conversions(totals.StageCount)
+
+	meta := domain.NewMetricsMeta(data)
+	s.addLastRun(ctx, meta)
+
 	summary := map[string]interface{}{
 		"period": map[string]interface{}{
 			"from": from.Format("2006-01-02"),
@@ -248,23 +597,303 @@ func (s *MetricsService) GetMetricsSummary(ctx context.Context) (map[string]inte
 			"opportunities": totalOpportunities,
 			"closed_won":    totalClosedWon,
 			"revenue":       totalRevenue,
+
+			"pipeline_value":   totalPipeline,
+			"expected_revenue": totalExpected,
+			"stages":           totalStages,
 		},
 		"averages": map[string]interface{}{
-			"cpc":             avgCPC,
-			"cpa":             avgCPA,
-			"cvr_lead_to_opp": avgCVRLeadToOpp,
-			"cvr_opp_to_won":  avgCVROppToWon,
-			"roas":            avgROAS,
+			"cpc":               avgCPC,
+			"cpa":               avgCPA,
+			"cvr_click_to_lead": avgCVRClickToLead,
+			"cvr_lead_to_opp":   avgCVRLeadToOpp,
+			"cvr_opp_to_won":    avgCVROppToWon,
+			"roas":              avgROAS,
+			"stage_conversions": stageConversions,
 		},
 		"counts": map[string]interface{}{
 			"unique_channels":  len(channels),
 			"unique_campaigns": len(campaigns),
-			"metric_records":   len(response.Data),
+			"metric_records":   len(data),
 		},
+		"meta":     meta,
+		"partial":  len(warnings) > 0,
+		"warnings": warnings,
+	}
+	if conversion != nil {
+		summary["currency"] = conversion
+	}
+	for _, column := range s.ColumnMask(ctx).Columns() {
+		delete(summary["totals"].(map[string]interface{}), column)
+		delete(summary["averages"].(map[string]interface{}), column)
 	}
 
 	s.metrics.RecordBusinessMetric("summary")
 
-	log.WithField("records", len(response.Data)).Info("Metrics summary generated")
+	log.WithField("records", len(data)).Info("Metrics summary generated")
 	return summary, nil
 }
+
+// days of metrics read per query when building the summary
+const summaryChunkDays = 7
+
+// metrics rows read per page when a query needs every matching row
+const metricsPageSize = 1000
+
+// reads the summary window a week at a time so a range whose storage is
+// unavailable is reported as a warning instead of failing the whole
+// summary. It only fails when no range could be read.
+func (s *MetricsService) readSummaryMetrics(ctx context.Context, from, to time.Time) ([]domain.BusinessMetrics, []domain.DataWarning, error) {
+	var data []domain.BusinessMetrics
+	warnings := []domain.DataWarning{}
+	var lastErr error
+	chunks := 0
+
+	for chunkFrom := from; !chunkFrom.After(to); chunkFrom = chunkFrom.AddDate(0, 0, summaryChunkDays) {
+		chunkTo := chunkFrom.AddDate(0, 0, summaryChunkDays-1)
+		if chunkTo.After(to) {
+			chunkTo = to
+		}
+		chunks++
+
+		rows, err := s.readAllMetrics(ctx, domain.MetricsFilter{From: &chunkFrom, To: &chunkTo})
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, nil, err
+			}
+			lastErr = err
+			warnings = append(warnings, domain.DataWarning{
+				Source: "metrics",
+				From:   chunkFrom.Format("2006-01-02"),
+				To:     chunkTo.Format("2006-01-02"),
+				Reason: err.Error(),
+			})
+			continue
+		}
+		data = append(data, rows...)
+	}
+
+	if chunks > 0 && len(warnings) == chunks {
+		return nil, nil, lastErr
+	}
+	return data, warnings, nil
+}
+
+// restricts the filter to the scope of the caller's API key, failing with
+// ErrOutOfScope when it asks for values outside it
+func scopeFilter(ctx context.Context, filter *domain.MetricsFilter) error {
+	scope := domain.MetricsScopeFromContext(ctx)
+	if err := scope.Check(*filter); err != nil {
+		return err
+	}
+	filter.Scope = scope
+	return nil
+}
+
+// holds the query to the caller's row budget. Over budget, the query is
+// rejected or, in downgrade mode, its range is narrowed to the most recent
+// days within budget. Returns the estimated cost, nil without a budget.
+func (s *MetricsService) budgetFilter(ctx context.Context, filter *domain.MetricsFilter) (*domain.QueryCost, error) {
+	var caller string
+	if key := domain.APIKeyFromContext(ctx); key != nil {
+		caller = key.Name
+	}
+	budget := s.budgets.For(caller)
+	if budget <= 0 || filter.From == nil || filter.To == nil {
+		return nil, nil
+	}
+
+	cost, err := s.estimateCost(ctx, *filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to estimate query cost: %w", err)
+	}
+	cost.Budget = budget
+	if cost.EstimatedRows <= budget {
+		return cost, nil
+	}
+
+	days := cost.DaysWithinBudget()
+	if s.budgetMode != domain.QueryBudgetDowngrade || days < 1 {
+		s.metrics.RecordBusinessMetric("query_over_budget_rejected")
+		return nil, fmt.Errorf("%w: it would scan about %d rows over %d days, more than the budget of %d; narrow the date range, filter by channel, campaign or UTM, or use the summary or dimension values for totals",
+			domain.ErrQueryTooExpensive, cost.EstimatedRows, cost.Days, budget)
+	}
+
+	from := filter.To.AddDate(0, 0, 1-days)
+	cost.Downgraded = true
+	cost.RequestedFrom = filter.From.Format("2006-01-02")
+	cost.From = from.Format("2006-01-02")
+	cost.Days = days
+	cost.EstimatedRows = int64(math.Ceil(cost.RowsPerDay * float64(days)))
+	filter.From = &from
+	s.metrics.RecordBusinessMetric("query_over_budget_downgraded")
+	s.logger.WithContext(ctx).WithFields(map[string]any{
+		"requested_from": cost.RequestedFrom,
+		"from":           cost.From,
+		"budget":         budget,
+	}).Info("Query range narrowed to its row budget")
+	return cost, nil
+}
+
+// estimates the rows the query scans from a count of the rows stored in its
+// range, which the repository takes on a reader without reading them: the
+// days of the range times the rows stored per day. Filters narrow what is
+// returned, not what is scanned, so they don't lower the estimate.
+func (s *MetricsService) estimateCost(ctx context.Context, filter domain.MetricsFilter) (*domain.QueryCost, error) {
+	from, to := *filter.From, *filter.To
+	cost := &domain.QueryCost{}
+	if from.After(to) {
+		return cost, nil
+	}
+	cost.Days = int(to.Sub(from)/(24*time.Hour)) + 1
+
+	stored, err := s.metricsRepo.Count(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+	cost.RowsPerDay = float64(stored) / float64(cost.Days)
+	cost.EstimatedRows = stored
+	return cost, nil
+}
+
+// returns the distinct values of a dimension. Scoped callers only count the
+// rows in their scope, which the repository can't do from its partitions.
+func (s *MetricsService) distinctValues(ctx context.Context, dimension string, from, to time.Time) ([]domain.DimensionValue, error) {
+	if len(domain.MetricsScopeFromContext(ctx)) == 0 {
+		return s.metricsRepo.GetDistinctValues(ctx, dimension, from, to)
+	}
+
+	metrics, err := s.readAllMetrics(ctx, domain.MetricsFilter{From: &from, To: &to})
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int)
+	for _, metric := range metrics {
+		counts[metric.DimensionValue(dimension)]++
+	}
+	return domain.SortedDimensionValues(counts), nil
+}
+
+// reads every metrics row matching the filter in the caller's scope, page by
+// page
+func (s *MetricsService) readAllMetrics(ctx context.Context, filter domain.MetricsFilter) ([]domain.BusinessMetrics, error) {
+	if err := scopeFilter(ctx, &filter); err != nil {
+		return nil, err
+	}
+	return readMetrics(ctx, s.metricsRepo, filter)
+}
+
+// reads every metrics row matching the filter, page by page
+func readMetrics(ctx context.Context, repo domain.MetricsRepository, filter domain.MetricsFilter) ([]domain.BusinessMetrics, error) {
+	var data []domain.BusinessMetrics
+	filter.Limit = metricsPageSize
+	for {
+		response, err := repo.GetByFilter(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, response.Data...)
+		if !response.HasMore || len(response.Data) == 0 {
+			return data, nil
+		}
+		filter.Offset += len(response.Data)
+	}
+}
+
+// sets when the last ETL run completed on the freshness metadata. Metrics
+// are still served when the run history cannot be read.
+func (s *MetricsService) addLastRun(ctx context.Context, meta *domain.MetricsMeta) {
+	if meta == nil {
+		return
+	}
+	run, err := s.runs.Latest(ctx, domain.RunCompleted)
+	if errors.Is(err, domain.ErrRunNotFound) {
+		return
+	}
+	if err != nil {
+		s.logger.WithContext(ctx).WithError(err).Warn("Failed to read the last ETL run")
+		return
+	}
+	completedAt := run.CompletedAt
+	meta.LastETLRunAt = &completedAt
+}
+
+// FlatReport returns a page of the metrics in the date range as flat rows,
+// starting after the cursor. An empty cursor starts at the first row.
+func (s *MetricsService) FlatReport(ctx context.Context, from, to time.Time, cursor string, limit int) (*domain.FlatReport, error) {
+	var after *domain.ReportCursor
+	if cursor != "" {
+		decoded, err := domain.DecodeReportCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		after = &decoded
+	}
+
+	filter := domain.MetricsFilter{From: &from, To: &to}
+	if err := scopeFilter(ctx, &filter); err != nil {
+		return nil, err
+	}
+	cost, err := s.budgetFilter(ctx, &filter)
+	if err != nil {
+		return nil, err
+	}
+
+	metrics, err := s.readAllMetrics(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metrics: %w", err)
+	}
+
+	hidden := s.ColumnMask(ctx)
+	rows := make([]domain.FlatRow, 0, len(metrics))
+	for _, metric := range metrics {
+		row := domain.FlatRowOf(metric)
+		row.Hidden = hidden
+		if after == nil || row.Cursor().Compare(*after) > 0 {
+			rows = append(rows, row)
+		}
+	}
+	slices.SortFunc(rows, func(a, b domain.FlatRow) int {
+		return a.Cursor().Compare(b.Cursor())
+	})
+
+	report := &domain.FlatReport{Columns: hidden.ReportColumns(domain.FlatColumns), Rows: rows, QueryCost: cost}
+	if len(rows) > limit {
+		report.Rows = rows[:limit]
+		report.NextCursor = rows[limit-1].Cursor().Encode()
+	}
+	return report, nil
+}
+
+// MetricCatalog returns the definitions of the metrics the service
+// computes, without those hidden from the caller's role
+func (s *MetricsService) MetricCatalog(ctx context.Context) []domain.MetricDefinition {
+	hidden := s.ColumnMask(ctx)
+	return slices.DeleteFunc(domain.MetricCatalog(), func(definition domain.MetricDefinition) bool {
+		return hidden[definition.Name]
+	})
+}
+
+// ColumnMask returns the columns hidden from the role of the API key
+// carried by the context, none without a key or role. Requests reach the
+// service without a key only while no key has a role, see
+// APIKeys.Restricted; internal callers like scheduled exports see every
+// column.
+func (s *MetricsService) ColumnMask(ctx context.Context) domain.ColumnMask {
+	key := domain.APIKeyFromContext(ctx)
+	if key == nil || key.Role == "" {
+		return nil
+	}
+	return s.roles[key.Role].Mask()
+}
+
+// hides the columns of the caller's role from the rows
+func (s *MetricsService) maskRows(ctx context.Context, rows []domain.BusinessMetrics) {
+	hidden := s.ColumnMask(ctx)
+	if len(hidden) == 0 {
+		return
+	}
+	for i := range rows {
+		rows[i].Hidden = hidden
+	}
+}
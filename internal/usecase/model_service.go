@@ -0,0 +1,191 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+
+	"etlgo/internal/domain"
+	"etlgo/pkg/logger"
+	"etlgo/pkg/metrics"
+)
+
+// ModelService materializes the derived models over the stored metrics and
+// serves and exports their rows
+type ModelService struct {
+	models       []domain.DerivedModel
+	metricsRepo  domain.MetricsRepository
+	results      domain.DerivedModelRepository
+	exportClient domain.ExportClient
+	destinations map[string]domain.ExportDestination
+	acks         *ExportAckService
+	clock        domain.Clock
+	logger       *logger.Logger
+	metrics      *metrics.Metrics
+}
+
+// NewModelService creates a new model service. The models must have been
+// validated. Model rows may be exported to the sink or to the destinations
+// and await their sink's ack through acks.
+func NewModelService(
+	models []domain.DerivedModel,
+	metricsRepo domain.MetricsRepository,
+	results domain.DerivedModelRepository,
+	exportClient domain.ExportClient,
+	destinations []domain.ExportDestination,
+	acks *ExportAckService,
+	clock domain.Clock,
+	logger *logger.Logger,
+	metrics *metrics.Metrics,
+) *ModelService {
+	byName := make(map[string]domain.ExportDestination, len(destinations))
+	for _, destination := range destinations {
+		byName[destination.Name] = destination
+	}
+
+	return &ModelService{
+		models:       models,
+		metricsRepo:  metricsRepo,
+		results:      results,
+		exportClient: exportClient,
+		destinations: byName,
+		acks:         acks,
+		clock:        clock,
+		logger:       logger,
+		metrics:      metrics,
+	}
+}
+
+// Materialize recalculates every model from the stored metrics of its window
+// and stores its rows as of the run. A model that fails keeps its previous
+// rows.
+func (s *ModelService) Materialize(ctx context.Context, runID string) {
+	for _, model := range s.models {
+		log := s.logger.WithContext(ctx).WithField("model", model.Name)
+		result, err := s.materialize(ctx, model, runID)
+		if err != nil {
+			log.WithError(err).Error("Failed to materialize derived model")
+			continue
+		}
+		log.WithField("rows", len(result.Rows)).Info("Materialized derived model")
+	}
+}
+
+func (s *ModelService) materialize(ctx context.Context, model domain.DerivedModel, runID string) (*domain.DerivedModelResult, error) {
+	now := s.clock.Now().UTC()
+	from, to := model.Window(now)
+	metrics, err := readMetrics(ctx, s.metricsRepo, domain.MetricsFilter{From: &from, To: &to})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metrics: %w", err)
+	}
+
+	result := domain.DerivedModelResult{
+		Model:          model.Name,
+		RunID:          runID,
+		From:           from,
+		To:             to,
+		Rows:           model.Materialize(metrics),
+		MaterializedAt: now,
+	}
+	if err := s.results.Save(ctx, result); err != nil {
+		return nil, fmt.Errorf("failed to store derived model: %w", err)
+	}
+
+	s.metrics.RecordBusinessMetric("model_materialized")
+	return &result, nil
+}
+
+// ListModels returns the configured models with their latest
+// materialization
+func (s *ModelService) ListModels(ctx context.Context) ([]domain.DerivedModelStatus, error) {
+	statuses := make([]domain.DerivedModelStatus, len(s.models))
+	for i, model := range s.models {
+		statuses[i].DerivedModel = model
+		result, err := s.results.Get(ctx, model.Name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get derived model %s: %w", model.Name, err)
+		}
+		if result != nil {
+			statuses[i].RunID = result.RunID
+			statuses[i].Rows = len(result.Rows)
+			statuses[i].MaterializedAt = &result.MaterializedAt
+		}
+	}
+	return statuses, nil
+}
+
+// GetModel returns the model's latest rows in the caller's scope,
+// materializing the model first when no run has yet
+func (s *ModelService) GetModel(ctx context.Context, name string) (*domain.DerivedModelResult, error) {
+	model, result, err := s.latest(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := domain.MetricsScopeFromContext(ctx).FilterRows(*model, result.Rows)
+	if err != nil {
+		return nil, err
+	}
+	result.Rows = rows
+
+	s.metrics.RecordBusinessMetric("model_query")
+	return result, nil
+}
+
+// ExportModel delivers the model's latest rows to the named destination, or
+// to the sink when destination is empty. Rows are sent as the model shapes
+// them; the destination's transforms only apply to metric exports. Scoped
+// callers export only the rows in their scope.
+func (s *ModelService) ExportModel(ctx context.Context, name, destination string) (*domain.DerivedModelResult, error) {
+	target, ok := s.destinations[destination]
+	if destination != "" && !ok {
+		return nil, fmt.Errorf("%w: %s", domain.ErrExportDestinationNotFound, destination)
+	}
+
+	model, result, err := s.latest(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := domain.MetricsScopeFromContext(ctx).FilterRows(*model, result.Rows)
+	if err != nil {
+		return nil, err
+	}
+	result.Rows = rows
+
+	records := make([]domain.ExportRecord, len(result.Rows))
+	for i, row := range result.Rows {
+		records[i] = row.Record()
+	}
+	exportID := "model_" + name + "_" + result.MaterializedAt.Format("2006-01-02")
+	if err := s.exportClient.ExportTo(ctx, target, exportID, records); err != nil {
+		s.logger.WithContext(ctx).WithError(err).WithField("model", name).Error("Failed to export derived model")
+		return nil, fmt.Errorf("failed to export derived model: %w", err)
+	}
+
+	s.metrics.RecordBusinessMetric("model_export")
+	s.acks.Delivered(ctx, exportID, destination, len(records))
+	return result, nil
+}
+
+// returns the model and its latest result, materializing it when it has
+// none
+func (s *ModelService) latest(ctx context.Context, name string) (*domain.DerivedModel, *domain.DerivedModelResult, error) {
+	for i := range s.models {
+		model := &s.models[i]
+		if model.Name != name {
+			continue
+		}
+
+		result, err := s.results.Get(ctx, name)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get derived model: %w", err)
+		}
+		if result == nil {
+			result, err = s.materialize(ctx, *model, "")
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		return model, result, nil
+	}
+	return nil, nil, fmt.Errorf("%w: %s", domain.ErrDerivedModelNotFound, name)
+}
@@ -0,0 +1,360 @@
+package usecase
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"text/template"
+
+	"etlgo/internal/domain"
+	"etlgo/pkg/logger"
+	"etlgo/pkg/metrics"
+)
+
+// default templates per channel type, used when a channel sets none
+var (
+	defaultNotificationTemplates = map[string]string{
+		domain.ChannelSlack: `{{if eq .Event "run_failed"}}:x: ETL run failed{{else}}:white_check_mark: ETL run completed{{end}}{{with .Run.Pipeline}} for pipeline *{{.}}*{{end}}
+{{.Run.AdsRecords}} ads and {{.Run.CRMRecords}} CRM records{{with .Run.Cost}} in {{printf "%.1f" .WallTimeSeconds}}s{{end}}
+{{- with .Run.Error}}
+Error: {{.}}{{end}}
+{{- range .Run.Anomalies}}
+• {{.Count}} {{.Kind}} in {{.Field}}{{with .Detail}} ({{.}}){{end}}{{end}}
+<{{.RunURL}}|Run details>`,
+		domain.ChannelWebhook: `{{json .}}`,
+		domain.ChannelEmail: `ETL run {{.Run.ID}} {{.Run.Status}}{{with .Run.Pipeline}} for pipeline {{.}}{{end}}.
+
+Ads records: {{.Run.AdsRecords}}
+CRM records: {{.Run.CRMRecords}}
+{{- with .Run.Cost}}
+Wall time: {{printf "%.1f" .WallTimeSeconds}}s{{end}}
+{{- with .Run.Error}}
+Error: {{.}}{{end}}
+{{- if .Run.Anomalies}}
+
+Anomalies:
+{{- range .Run.Anomalies}}
+- {{.Count}} {{.Kind}} in {{.Field}}{{with .Detail}} ({{.}}){{end}}{{end}}{{end}}
+
+Run details: {{.RunURL}}
+`,
+	}
+	defaultNotificationSubject = `ETL run {{.Run.Status}}{{with .Run.Pipeline}}: {{.}}{{end}}`
+
+	// default templates of approval events
+	defaultApprovalTemplates = map[string]string{
+		domain.ChannelSlack: `:hourglass: Approval needed: {{.Approval.Description}}
+Requested by {{.Approval.RequestedBy}}. A second operator must approve or reject it.
+<{{.ApprovalURL}}|Approval request>`,
+		domain.ChannelWebhook: `{{json .}}`,
+		domain.ChannelEmail: `{{.Approval.Description}} was requested by {{.Approval.RequestedBy}} and needs a second operator's approval.
+
+Approve: POST {{.ApprovalURL}}/approve
+Reject:  POST {{.ApprovalURL}}/reject
+`,
+	}
+	defaultApprovalSubject = `Approval needed: {{.Approval.Description}}`
+
+	// default templates of suggested actions
+	defaultActionTemplates = map[string]string{
+		domain.ChannelSlack: `:double_vertical_bar: {{len .Actions}} suggested action(s)
+{{- range .Actions}}
+• {{.Type}} {{.Channel}} campaign *{{.UTMCampaign}}* ({{.UTMSource}}/{{.UTMMedium}}): {{.Reason}} {{.Cap}} exceeded for {{len .Days}} day(s) through {{.Through.Format "2006-01-02"}}{{end}}
+<{{.ActionsURL}}|Action feed>`,
+		domain.ChannelWebhook: `{{json .}}`,
+		domain.ChannelEmail: `{{len .Actions}} campaign action(s) were suggested:
+{{range .Actions}}
+- {{.Type}} {{.Channel}} campaign {{.UTMCampaign}} ({{.UTMSource}}/{{.UTMMedium}}): {{.Reason}} of {{.Cap}} exceeded for {{len .Days}} day(s) through {{.Through.Format "2006-01-02"}}{{end}}
+
+Action feed: {{.ActionsURL}}
+`,
+	}
+	defaultActionSubject = `{{len .Actions}} suggested campaign action(s)`
+
+	// default templates of stuck exports
+	defaultExportTemplates = map[string]string{
+		domain.ChannelSlack: `:warning: {{len .Exports}} export(s) not acknowledged by their sink
+{{- range .Exports}}
+• *{{.ExportID}}*{{with .Destination}} to {{.}}{{end}}, delivered {{.DeliveredAt.Format "2006-01-02 15:04:05"}} UTC{{end}}
+<{{.ExportsURL}}|Stuck exports>`,
+		domain.ChannelWebhook: `{{json .}}`,
+		domain.ChannelEmail: `{{len .Exports}} export(s) were delivered but not acknowledged by their sink before their deadline:
+{{range .Exports}}
+- {{.ExportID}}{{with .Destination}} to {{.}}{{end}}: {{.Records}} records delivered {{.DeliveredAt.Format "2006-01-02 15:04:05"}} UTC, due {{.Deadline.Format "2006-01-02 15:04:05"}} UTC{{end}}
+
+Stuck exports: {{.ExportsURL}}
+`,
+	}
+	defaultExportSubject = `{{len .Exports}} export(s) not acknowledged`
+)
+
+// functions available to notification templates in addition to the
+// text/template builtins
+var notificationFuncs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		raw, err := json.Marshal(v)
+		return string(raw), err
+	},
+	"join": strings.Join,
+}
+
+// NotificationService notifies channels of finished runs, rendering each
+// channel's templates with the run's stats, anomalies and a link to its
+// detail endpoint. Deliveries happen in the background and failures are
+// logged, never failing the run.
+type NotificationService struct {
+	channels []notificationChannel
+	sender   domain.NotificationSender
+	baseURL  string
+	wg       sync.WaitGroup
+	logger   *logger.Logger
+	metrics  *metrics.Metrics
+}
+
+// a configured channel with its parsed templates. Channels without their
+// own template use separate defaults for approval, action and stuck export
+// events.
+type notificationChannel struct {
+	config          domain.NotificationChannel
+	subject         *template.Template
+	body            *template.Template
+	approvalSubject *template.Template
+	approvalBody    *template.Template
+	actionSubject   *template.Template
+	actionBody      *template.Template
+	exportSubject   *template.Template
+	exportBody      *template.Template
+}
+
+// NewNotificationService parses the templates of the channels. baseURL is
+// the service's externally reachable address that run links point at.
+func NewNotificationService(
+	channels []domain.NotificationChannel,
+	sender domain.NotificationSender,
+	baseURL string,
+	logger *logger.Logger,
+	metrics *metrics.Metrics,
+) (*NotificationService, error) {
+	s := &NotificationService{
+		sender:  sender,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		logger:  logger,
+		metrics: metrics,
+	}
+
+	for _, config := range channels {
+		channel := notificationChannel{config: config}
+		var err error
+		channel.body, channel.subject, err = parseNotificationTemplates(config, defaultNotificationTemplates[config.Type], defaultNotificationSubject)
+		if err != nil {
+			return nil, err
+		}
+		channel.approvalBody, channel.approvalSubject, err = parseNotificationTemplates(config, defaultApprovalTemplates[config.Type], defaultApprovalSubject)
+		if err != nil {
+			return nil, err
+		}
+		channel.actionBody, channel.actionSubject, err = parseNotificationTemplates(config, defaultActionTemplates[config.Type], defaultActionSubject)
+		if err != nil {
+			return nil, err
+		}
+		channel.exportBody, channel.exportSubject, err = parseNotificationTemplates(config, defaultExportTemplates[config.Type], defaultExportSubject)
+		if err != nil {
+			return nil, err
+		}
+		s.channels = append(s.channels, channel)
+	}
+
+	return s, nil
+}
+
+// parses the channel's body and, for email, subject templates, falling back
+// to the given defaults
+func parseNotificationTemplates(config domain.NotificationChannel, defaultBody, defaultSubject string) (body, subject *template.Template, err error) {
+	source := cmp.Or(config.Template, defaultBody)
+	body, err = template.New(config.Name).Funcs(notificationFuncs).Parse(source)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: invalid template: %w", config.Name, err)
+	}
+
+	if config.Type == domain.ChannelEmail {
+		source = cmp.Or(config.Subject, defaultSubject)
+		subject, err = template.New(config.Name + " subject").Funcs(notificationFuncs).Parse(source)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: invalid subject template: %w", config.Name, err)
+		}
+	}
+	return body, subject, nil
+}
+
+// Notify sends the run to every channel subscribed to its outcome
+func (s *NotificationService) Notify(ctx context.Context, run domain.RunRecord) {
+	event := runEvent(run)
+
+	// Deliveries outlive the request that finished the run
+	ctx = context.WithoutCancel(ctx)
+	for _, channel := range s.channels {
+		if !channel.config.Wants(event, &run) {
+			continue
+		}
+		s.wg.Go(func() { s.deliver(ctx, channel, event, run) })
+	}
+}
+
+// NotifyApproval sends a pending approval request to every channel
+// subscribed to approval_pending
+func (s *NotificationService) NotifyApproval(ctx context.Context, request domain.ApprovalRequest) {
+	ctx = context.WithoutCancel(ctx)
+	for _, channel := range s.channels {
+		if !channel.config.Wants(domain.EventApprovalPending, nil) {
+			continue
+		}
+		s.wg.Go(func() {
+			data := domain.Notification{
+				Event:       domain.EventApprovalPending,
+				Channel:     channel.config.Name,
+				Approval:    &request,
+				ApprovalURL: s.baseURL + "/api/v1/approvals/" + request.ID,
+			}
+			s.send(ctx, channel, channel.approvalBody, channel.approvalSubject, data, map[string]any{
+				"channel":     channel.config.Name,
+				"event":       domain.EventApprovalPending,
+				"approval_id": request.ID,
+			})
+		})
+	}
+}
+
+// NotifyActions sends newly suggested actions to every channel subscribed to
+// actions_suggested
+func (s *NotificationService) NotifyActions(ctx context.Context, actions []domain.Action) {
+	ctx = context.WithoutCancel(ctx)
+	for _, channel := range s.channels {
+		if !channel.config.Wants(domain.EventActions, nil) {
+			continue
+		}
+		s.wg.Go(func() {
+			data := domain.Notification{
+				Event:      domain.EventActions,
+				Channel:    channel.config.Name,
+				Actions:    actions,
+				ActionsURL: s.baseURL + "/api/v1/actions",
+			}
+			s.send(ctx, channel, channel.actionBody, channel.actionSubject, data, map[string]any{
+				"channel": channel.config.Name,
+				"event":   domain.EventActions,
+				"actions": len(actions),
+			})
+		})
+	}
+}
+
+// NotifyStuckExports sends exports not acknowledged by their deadline to
+// every channel subscribed to export_stuck
+func (s *NotificationService) NotifyStuckExports(ctx context.Context, deliveries []domain.ExportDelivery) {
+	ctx = context.WithoutCancel(ctx)
+	for _, channel := range s.channels {
+		if !channel.config.Wants(domain.EventExportStuck, nil) {
+			continue
+		}
+		s.wg.Go(func() {
+			data := domain.Notification{
+				Event:      domain.EventExportStuck,
+				Channel:    channel.config.Name,
+				Exports:    deliveries,
+				ExportsURL: s.baseURL + "/api/v1/export/deliveries?stuck=true",
+			}
+			s.send(ctx, channel, channel.exportBody, channel.exportSubject, data, map[string]any{
+				"channel": channel.config.Name,
+				"event":   domain.EventExportStuck,
+				"exports": len(deliveries),
+			})
+		})
+	}
+}
+
+// Render returns the message the channel would send for the run's outcome
+func (s *NotificationService) Render(channelName string, run domain.RunRecord) (*domain.NotificationMessage, error) {
+	for _, channel := range s.channels {
+		if channel.config.Name == channelName {
+			return s.render(channel, runEvent(run), run)
+		}
+	}
+	return nil, domain.ErrNotificationChannelNotFound
+}
+
+// Wait blocks until pending deliveries have finished
+func (s *NotificationService) Wait() {
+	s.wg.Wait()
+}
+
+func (s *NotificationService) deliver(ctx context.Context, channel notificationChannel, event string, run domain.RunRecord) {
+	s.send(ctx, channel, channel.body, channel.subject, s.runNotification(channel, event, run), map[string]any{
+		"channel": channel.config.Name,
+		"event":   event,
+		"run_id":  run.ID,
+	})
+}
+
+// renders the notification with the templates and sends it to the channel
+func (s *NotificationService) send(ctx context.Context, channel notificationChannel, body, subject *template.Template, data domain.Notification, fields map[string]any) {
+	log := s.logger.WithContext(ctx).WithFields(fields)
+	message, err := renderNotification(body, subject, data)
+	if err != nil {
+		s.metrics.RecordNotification(channel.config.Name, "template_error")
+		log.WithError(err).Error("Failed to render notification")
+		return
+	}
+	if err := s.sender.Send(ctx, channel.config, *message); err != nil {
+		s.metrics.RecordNotification(channel.config.Name, "failed")
+		log.WithError(err).Error("Failed to send notification")
+		return
+	}
+
+	s.metrics.RecordNotification(channel.config.Name, "sent")
+	log.Info("Notification sent")
+}
+
+func (s *NotificationService) render(channel notificationChannel, event string, run domain.RunRecord) (*domain.NotificationMessage, error) {
+	return renderNotification(channel.body, channel.subject, s.runNotification(channel, event, run))
+}
+
+// returns the template data of a run event
+func (s *NotificationService) runNotification(channel notificationChannel, event string, run domain.RunRecord) domain.Notification {
+	return domain.Notification{
+		Event:   event,
+		Channel: channel.config.Name,
+		Run:     &run,
+		RunURL:  s.baseURL + "/api/v1/ingest/runs/" + run.ID,
+	}
+}
+
+// executes the body and, when set, subject templates with the data
+func renderNotification(body, subject *template.Template, data domain.Notification) (*domain.NotificationMessage, error) {
+	var message domain.NotificationMessage
+	var buf bytes.Buffer
+	if err := body.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("failed to render template: %w", err)
+	}
+	message.Body = buf.String()
+
+	if subject != nil {
+		buf.Reset()
+		if err := subject.Execute(&buf, data); err != nil {
+			return nil, fmt.Errorf("failed to render subject template: %w", err)
+		}
+		message.Subject = strings.TrimSpace(buf.String())
+	}
+	return &message, nil
+}
+
+// returns the event a finished run notifies
+func runEvent(run domain.RunRecord) string {
+	if run.Status == domain.RunFailed {
+		return domain.EventRunFailed
+	}
+	return domain.EventRunCompleted
+}
@@ -0,0 +1,381 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"etlgo/internal/domain"
+	"etlgo/pkg/logger"
+	"etlgo/pkg/metrics"
+)
+
+// PipelineScheduler runs pipelines on their cron schedules through the job
+// queue. Runs that fall on a holiday of the pipeline's calendar, or on a
+// weekend for business-days-only pipelines, are skipped, as are runs due
+// while the previous run of the same pipeline is still going. Every due run
+// is recorded in the history, which is used on startup to catch up on runs
+// missed while the service was down. Running runs are watched by the
+// watchdog, their stages beating their heartbeat.
+type PipelineScheduler struct {
+	pipelineService *PipelineService
+	jobQueue        *JobQueue
+	watchdog        *Watchdog
+	history         domain.ScheduleHistoryRepository
+	catchUpLookback time.Duration
+	running         map[string]bool
+	started         bool
+	paused          bool
+	held            bool // paused by an operator, apart from maintenance mode
+	mutex           sync.Mutex
+	wg              sync.WaitGroup
+	clock           domain.Clock
+	logger          *logger.Logger
+	metrics         *metrics.Metrics
+}
+
+// NewPipelineScheduler creates a scheduler for the pipelines of the service.
+// Missed runs due within catchUpLookback before startup are caught up; zero
+// disables catch-up.
+func NewPipelineScheduler(
+	pipelineService *PipelineService,
+	jobQueue *JobQueue,
+	watchdog *Watchdog,
+	history domain.ScheduleHistoryRepository,
+	catchUpLookback time.Duration,
+	clock domain.Clock,
+	logger *logger.Logger,
+	metrics *metrics.Metrics,
+) *PipelineScheduler {
+	return &PipelineScheduler{
+		pipelineService: pipelineService,
+		jobQueue:        jobQueue,
+		watchdog:        watchdog,
+		history:         history,
+		catchUpLookback: catchUpLookback,
+		running:         make(map[string]bool),
+		clock:           clock,
+		logger:          logger,
+		metrics:         metrics,
+	}
+}
+
+// Start catches up on missed runs, then checks the schedules at every
+// minute boundary until ctx is cancelled and waits for started runs to
+// return. It beats while waiting for the next minute; runs it started are
+// cancelled with ctx, so restarting a wedged scheduler cancels them too.
+func (s *PipelineScheduler) Start(ctx context.Context, heartbeat domain.Heartbeat) {
+	s.logger.WithField("timezone", s.pipelineService.Location().String()).Info("Pipeline scheduler started")
+	defer s.wg.Wait()
+
+	s.mutex.Lock()
+	s.started = true
+	s.mutex.Unlock()
+
+	if s.catchUpLookback > 0 {
+		s.catchUp(ctx, s.clock.Now())
+	}
+
+	// A clock standing still keeps naming the same minute; it is ticked once
+	var last time.Time
+	for {
+		now := s.clock.Now()
+		next := now.Truncate(time.Minute).Add(time.Minute)
+		if !heartbeat.Sleep(ctx, next.Sub(now)) {
+			return
+		}
+		if !next.After(last) {
+			continue
+		}
+		last = next
+		s.tick(ctx, next.In(s.pipelineService.Location()))
+	}
+}
+
+// Pause stops starting scheduled runs; runs due while paused are skipped
+func (s *PipelineScheduler) Pause() {
+	s.mutex.Lock()
+	s.paused = true
+	s.mutex.Unlock()
+}
+
+// Resume starts scheduled runs again
+func (s *PipelineScheduler) Resume() {
+	s.mutex.Lock()
+	s.paused = false
+	s.mutex.Unlock()
+}
+
+// SetHeld holds scheduled runs until released again. Unlike Pause it is
+// not undone when maintenance mode ends; runs due while held are skipped.
+func (s *PipelineScheduler) SetHeld(held bool) {
+	s.mutex.Lock()
+	s.held = held
+	s.mutex.Unlock()
+}
+
+// Held reports whether scheduled runs are held
+func (s *PipelineScheduler) Held() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.held
+}
+
+// History returns the pipeline's scheduled runs, most recent first
+func (s *PipelineScheduler) History(ctx context.Context, name string, limit int) ([]domain.ScheduleRecord, error) {
+	if _, err := s.pipelineService.GetPipeline(ctx, name); err != nil {
+		return nil, err
+	}
+	return s.history.List(ctx, name, limit)
+}
+
+// Active reports whether the scheduler is started and neither paused nor
+// held
+func (s *PipelineScheduler) Active() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.started && !s.paused && !s.held
+}
+
+// scheduled runs looked through for the next one that is not skipped, two
+// days of a schedule firing every minute
+const nextRunSearch = 2 * 24 * 60
+
+// Schedules returns every scheduled pipeline with the time of its next run
+// after now and its last recorded run
+func (s *PipelineScheduler) Schedules(ctx context.Context, now time.Time) ([]domain.ScheduleStatus, error) {
+	pipelines, err := s.pipelineService.ListPipelines(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]domain.ScheduleStatus, 0, len(pipelines))
+	for _, pipeline := range pipelines {
+		if pipeline.Schedule == "" {
+			continue
+		}
+		status := domain.ScheduleStatus{Pipeline: pipeline.Name, Schedule: pipeline.Schedule}
+
+		s.mutex.Lock()
+		status.Running = s.running[pipeline.Name]
+		s.mutex.Unlock()
+
+		if schedule, err := domain.ParseCronSchedule(pipeline.Schedule); err == nil {
+			t := now.In(s.pipelineService.Location())
+			for range nextRunSearch {
+				if t = schedule.Next(t); t.IsZero() {
+					break
+				}
+				if reason, _ := s.pipelineService.ScheduleSkip(pipeline, t); reason == "" {
+					status.NextRunAt = &t
+					break
+				}
+			}
+		}
+
+		records, err := s.history.List(ctx, pipeline.Name, 1)
+		if err != nil {
+			return nil, err
+		}
+		if len(records) > 0 {
+			status.LastRun = &records[0]
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// starts the pipelines due at now
+func (s *PipelineScheduler) tick(ctx context.Context, now time.Time) {
+	pipelines, err := s.pipelineService.ListPipelines(ctx)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to list pipelines for scheduling")
+		return
+	}
+
+	for _, pipeline := range pipelines {
+		if pipeline.Schedule == "" {
+			continue
+		}
+		schedule, err := domain.ParseCronSchedule(pipeline.Schedule)
+		if err != nil || !schedule.Matches(now) {
+			continue
+		}
+
+		if reason, detail := s.pipelineService.ScheduleSkip(pipeline, now); reason != "" {
+			s.record(ctx, domain.ScheduleRecord{
+				Pipeline: pipeline.Name,
+				DueAt:    now,
+				Outcome:  "skipped_" + reason,
+				Detail:   detail,
+			})
+			continue
+		}
+
+		if outcome := s.claim(pipeline.Name); outcome != "" {
+			s.record(ctx, domain.ScheduleRecord{Pipeline: pipeline.Name, DueAt: now, Outcome: outcome})
+			continue
+		}
+
+		s.wg.Go(func() {
+			s.run(ctx, domain.ScheduleRecord{Pipeline: pipeline.Name, DueAt: now}, now, domain.PriorityNormal)
+		})
+	}
+}
+
+// finds runs that were due between each pipeline's last recorded run and
+// now but never fired, and starts one low priority catch-up run per
+// pipeline whose lookback window reaches back to the earliest of them.
+// Pipelines without history are not caught up.
+func (s *PipelineScheduler) catchUp(ctx context.Context, now time.Time) {
+	pipelines, err := s.pipelineService.ListPipelines(ctx)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to list pipelines for catch-up")
+		return
+	}
+
+	horizon := now.Add(-s.catchUpLookback)
+	for _, pipeline := range pipelines {
+		if pipeline.Schedule == "" {
+			continue
+		}
+		schedule, err := domain.ParseCronSchedule(pipeline.Schedule)
+		if err != nil {
+			continue
+		}
+		lastDue, err := s.history.LastDue(ctx, pipeline.Name)
+		if err != nil {
+			s.logger.WithError(err).WithField("pipeline", pipeline.Name).Error("Failed to read schedule history")
+			continue
+		}
+		if lastDue == nil {
+			continue
+		}
+
+		// Runs due before the horizon are not caught up, so a pipeline last
+		// due long ago is looked through from the horizon rather than from
+		// its last run; one due right at the horizon still is
+		from := lastDue.In(s.pipelineService.Location())
+		expired := false
+		if from.Before(horizon) {
+			expired = schedule.Next(from).Before(horizon)
+			from = horizon.Add(-time.Nanosecond).In(s.pipelineService.Location())
+		}
+
+		var missed []time.Time
+		for t := schedule.Next(from); !t.IsZero() && t.Before(now); t = schedule.Next(t) {
+			if reason, _ := s.pipelineService.ScheduleSkip(pipeline, t); reason != "" {
+				continue
+			}
+			missed = append(missed, t)
+		}
+
+		log := s.logger.WithFields(map[string]any{
+			"pipeline": pipeline.Name,
+			"last_due": *lastDue,
+		})
+		if expired {
+			log.WithField("lookback", s.catchUpLookback.String()).Warn("Missed scheduled runs older than the catch-up lookback are not caught up")
+		}
+		if len(missed) == 0 || s.claim(pipeline.Name) != "" {
+			continue
+		}
+
+		log.WithFields(map[string]any{
+			"missed":       len(missed),
+			"first_missed": missed[0],
+		}).Warn("Catching up on missed scheduled runs")
+		record := domain.ScheduleRecord{
+			Pipeline: pipeline.Name,
+			DueAt:    missed[len(missed)-1],
+			CatchUp:  true,
+			Missed:   len(missed),
+		}
+		s.wg.Go(func() { s.run(ctx, record, missed[0], domain.PriorityLow) })
+	}
+}
+
+// marks the pipeline as running, returning the skip outcome when it cannot
+// start
+func (s *PipelineScheduler) claim(name string) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	switch {
+	case s.paused, s.held:
+		return domain.ScheduleSkippedPaused
+	case s.running[name]:
+		return domain.ScheduleSkippedOverlap
+	}
+	s.running[name] = true
+	return ""
+}
+
+// runs a claimed pipeline with its window starting from asOf and records
+// the outcome
+func (s *PipelineScheduler) run(ctx context.Context, record domain.ScheduleRecord, asOf time.Time, priority domain.JobPriority) {
+	defer func() {
+		s.mutex.Lock()
+		delete(s.running, record.Pipeline)
+		s.mutex.Unlock()
+	}()
+
+	s.logger.WithFields(map[string]any{
+		"pipeline": record.Pipeline,
+		"catch_up": record.CatchUp,
+	}).Info("Starting scheduled pipeline run")
+
+	err := s.jobQueue.Run(ctx, domain.JobTypeIngest, priority, func(ctx context.Context) error {
+		ctx, done := s.watchdog.Watch(ctx, domain.BackgroundJobScheduledRun+record.Pipeline)
+		defer done()
+
+		started := s.clock.Now().UTC()
+		record.StartedAt = &started
+		tags := map[string]string{domain.RunTagTrigger: domain.RunTriggerSchedule}
+		_, _, err := s.pipelineService.RunPipelineAsOf(ctx, record.Pipeline, asOf, tags)
+		return err
+	})
+	completed := s.clock.Now().UTC()
+	record.CompletedAt = &completed
+
+	switch {
+	case errors.Is(err, domain.ErrMaintenance):
+		record.Outcome = domain.ScheduleSkippedPaused
+	case errors.Is(err, domain.ErrQuotaExceeded):
+		record.Outcome = domain.ScheduleSkippedQuota
+		record.Detail = err.Error()
+	case err != nil:
+		record.Outcome = domain.ScheduleFailed
+		record.Detail = err.Error()
+	default:
+		record.Outcome = domain.ScheduleCompleted
+	}
+	s.record(ctx, record)
+}
+
+// stores a scheduled run in the history and counts its outcome
+func (s *PipelineScheduler) record(ctx context.Context, record domain.ScheduleRecord) {
+	s.metrics.RecordScheduledRun(record.Pipeline, record.Outcome)
+
+	log := s.logger.WithFields(map[string]any{
+		"pipeline": record.Pipeline,
+		"due_at":   record.DueAt,
+		"outcome":  record.Outcome,
+		"detail":   record.Detail,
+	})
+	switch record.Outcome {
+	case domain.ScheduleCompleted:
+		log.Info("Scheduled pipeline run completed")
+	case domain.ScheduleFailed:
+		log.Error("Scheduled pipeline run failed")
+	case domain.ScheduleSkippedWeekend, domain.ScheduleSkippedHoliday:
+		log.Info("Scheduled pipeline run skipped")
+	default:
+		log.Warn("Scheduled pipeline run skipped")
+	}
+
+	if err := s.history.Record(ctx, record); err != nil {
+		s.logger.WithError(err).WithField("pipeline", record.Pipeline).Error("Failed to record scheduled run")
+	}
+}
@@ -0,0 +1,276 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"etlgo/internal/domain"
+	"etlgo/pkg/logger"
+	"etlgo/pkg/metrics"
+)
+
+// PipelineService manages named pipeline presets and runs them
+type PipelineService struct {
+	pipelineRepo   domain.PipelineRepository
+	metricsRepo    domain.MetricsRepository
+	etlService     *ETLService
+	metricsService *MetricsService
+	calendars      map[string]domain.HolidayCalendar
+	location       *time.Location
+	lateDataDays   int
+	clock          domain.Clock
+	logger         *logger.Logger
+	metrics        *metrics.Metrics
+}
+
+// NewPipelineService creates a new pipeline service. Schedules are evaluated
+// in location against the named holiday calendars. Pipeline runs re-extract
+// the trailing lateDataDays and replace the ads stored for them; zero
+// disables it.
+func NewPipelineService(
+	pipelineRepo domain.PipelineRepository,
+	metricsRepo domain.MetricsRepository,
+	etlService *ETLService,
+	metricsService *MetricsService,
+	calendars map[string]domain.HolidayCalendar,
+	location *time.Location,
+	lateDataDays int,
+	clock domain.Clock,
+	logger *logger.Logger,
+	metrics *metrics.Metrics,
+) *PipelineService {
+	return &PipelineService{
+		pipelineRepo:   pipelineRepo,
+		metricsRepo:    metricsRepo,
+		etlService:     etlService,
+		metricsService: metricsService,
+		calendars:      calendars,
+		location:       location,
+		lateDataDays:   lateDataDays,
+		clock:          clock,
+		logger:         logger,
+		metrics:        metrics,
+	}
+}
+
+// CreatePipeline validates and stores a new pipeline preset
+func (s *PipelineService) CreatePipeline(ctx context.Context, pipeline domain.Pipeline, actor string) (*domain.Pipeline, error) {
+	if err := s.validate(&pipeline); err != nil {
+		return nil, err
+	}
+
+	now := s.clock.Now()
+	pipeline.CreatedBy, pipeline.CreatedAt = actor, now
+	pipeline.UpdatedBy, pipeline.UpdatedAt = actor, now
+
+	if err := s.pipelineRepo.Create(ctx, pipeline); err != nil {
+		return nil, fmt.Errorf("failed to create pipeline: %w", err)
+	}
+
+	return s.pipelineRepo.Get(ctx, pipeline.Name)
+}
+
+// UpdatePipeline replaces an existing pipeline preset
+func (s *PipelineService) UpdatePipeline(ctx context.Context, pipeline domain.Pipeline, actor string) (*domain.Pipeline, error) {
+	if err := s.validate(&pipeline); err != nil {
+		return nil, err
+	}
+
+	pipeline.UpdatedBy, pipeline.UpdatedAt = actor, s.clock.Now()
+
+	if err := s.pipelineRepo.Update(ctx, pipeline); err != nil {
+		return nil, fmt.Errorf("failed to update pipeline: %w", err)
+	}
+
+	return s.pipelineRepo.Get(ctx, pipeline.Name)
+}
+
+// validates the pipeline and checks that its holiday calendar is configured
+func (s *PipelineService) validate(pipeline *domain.Pipeline) error {
+	if err := pipeline.Validate(); err != nil {
+		return err
+	}
+	if pipeline.HolidayCalendar != "" {
+		if _, ok := s.calendars[pipeline.HolidayCalendar]; !ok {
+			return fmt.Errorf("%w: unknown holiday calendar %q", domain.ErrInvalidPipeline, pipeline.HolidayCalendar)
+		}
+	}
+	return nil
+}
+
+// DeletePipeline removes a pipeline preset, keeping its history
+func (s *PipelineService) DeletePipeline(ctx context.Context, name, actor string) error {
+	if err := s.pipelineRepo.Delete(ctx, name, actor); err != nil {
+		return fmt.Errorf("failed to delete pipeline: %w", err)
+	}
+	return nil
+}
+
+// GetPipeline returns a pipeline preset by name
+func (s *PipelineService) GetPipeline(ctx context.Context, name string) (*domain.Pipeline, error) {
+	return s.pipelineRepo.Get(ctx, name)
+}
+
+// ListPipelines returns all pipeline presets
+func (s *PipelineService) ListPipelines(ctx context.Context) ([]domain.Pipeline, error) {
+	return s.pipelineRepo.List(ctx)
+}
+
+// GetPipelineHistory returns the audit trail of a pipeline preset
+func (s *PipelineService) GetPipelineHistory(ctx context.Context, name string) ([]domain.PipelineRevision, error) {
+	return s.pipelineRepo.History(ctx, name)
+}
+
+// Location returns the time zone schedules are evaluated in
+func (s *PipelineService) Location() *time.Location {
+	return s.location
+}
+
+// ScheduleSkip reports why the pipeline's scheduled run at t is skipped,
+// or an empty reason when it runs
+func (s *PipelineService) ScheduleSkip(pipeline domain.Pipeline, t time.Time) (reason, detail string) {
+	var calendar *domain.HolidayCalendar
+	if c, ok := s.calendars[pipeline.HolidayCalendar]; ok {
+		calendar = &c
+	}
+	return pipeline.ScheduleSkip(t.In(s.location), calendar)
+}
+
+// UpcomingRuns returns the pipeline's next count scheduled runs after from,
+// including the ones its calendar skips
+func (s *PipelineService) UpcomingRuns(ctx context.Context, name string, from time.Time, count int) (*domain.Pipeline, []domain.ScheduledRun, error) {
+	pipeline, err := s.pipelineRepo.Get(ctx, name)
+	if err != nil {
+		return nil, nil, err
+	}
+	if pipeline.Schedule == "" {
+		return pipeline, nil, fmt.Errorf("%w: pipeline %q has no schedule", domain.ErrInvalidPipeline, name)
+	}
+	schedule, err := domain.ParseCronSchedule(pipeline.Schedule)
+	if err != nil {
+		return pipeline, nil, fmt.Errorf("%w: schedule: %v", domain.ErrInvalidPipeline, err)
+	}
+
+	runs := make([]domain.ScheduledRun, 0, count)
+	for t := from.In(s.location); len(runs) < count; {
+		t = schedule.Next(t)
+		if t.IsZero() {
+			break
+		}
+		reason, detail := s.ScheduleSkip(*pipeline, t)
+		runs = append(runs, domain.ScheduledRun{At: t, Skip: reason, SkipDetail: detail})
+	}
+	return pipeline, runs, nil
+}
+
+// RunPipeline runs the ETL with the preset's configuration and exports the
+// resulting metrics to its destinations. The run is recorded with the tags.
+func (s *PipelineService) RunPipeline(ctx context.Context, name string, tags map[string]string) (*domain.Pipeline, *domain.RunSummary, error) {
+	return s.RunPipelineAsOf(ctx, name, s.clock.Now(), tags)
+}
+
+// RunPipelineAsOf runs the pipeline with its lookback window starting from
+// asOf instead of now, so a catch-up run also covers the windows of the runs
+// it replaces
+func (s *PipelineService) RunPipelineAsOf(ctx context.Context, name string, asOf time.Time, tags map[string]string) (*domain.Pipeline, *domain.RunSummary, error) {
+	pipeline, err := s.pipelineRepo.Get(ctx, name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	log := s.logger.WithContext(ctx).WithFields(map[string]any{
+		"pipeline": pipeline.Name,
+		"version":  pipeline.Version,
+	})
+	log.Info("Running pipeline")
+
+	opts := s.runOptions(pipeline, asOf, tags)
+	summary, err := s.etlService.RunETLWithOptions(ctx, opts)
+	if err != nil {
+		return pipeline, summary, err
+	}
+
+	for _, destination := range pipeline.Destinations {
+		if destination == domain.DestinationSink {
+			if err := s.exportWindow(ctx, opts.Since); err != nil {
+				return pipeline, summary, err
+			}
+		}
+	}
+
+	log.Info("Pipeline run completed")
+	return pipeline, summary, nil
+}
+
+// PipelineRunOptions returns the options the pipeline would run with now
+func (s *PipelineService) PipelineRunOptions(ctx context.Context, name string, tags map[string]string) (domain.RunOptions, error) {
+	pipeline, err := s.pipelineRepo.Get(ctx, name)
+	if err != nil {
+		return domain.RunOptions{}, err
+	}
+	return s.runOptions(pipeline, s.clock.Now(), tags), nil
+}
+
+// returns the options of a run of the pipeline with its lookback window
+// starting from asOf
+func (s *PipelineService) runOptions(pipeline *domain.Pipeline, asOf time.Time, tags map[string]string) domain.RunOptions {
+	opts := domain.RunOptions{
+		Pipeline:          pipeline.Name,
+		Sources:           pipeline.Sources,
+		Parsing:           pipeline.Parsing,
+		Tags:              tags,
+		AllowEmptyReplace: pipeline.AllowEmptyReplace,
+	}
+	if pipeline.SinceDays > 0 {
+		since := asOf.AddDate(0, 0, -pipeline.SinceDays).Truncate(24 * time.Hour)
+		opts.Since = &since
+	}
+
+	// Ad platforms restate spend for days after the fact, so the trailing
+	// days are extracted again and replace what was stored for them. A
+	// longer window is replaced as a whole.
+	if s.lateDataDays > 0 {
+		replaceFrom := asOf.AddDate(0, 0, -s.lateDataDays).Truncate(24 * time.Hour)
+		switch {
+		case opts.Since == nil:
+			opts.ReplaceFrom = &replaceFrom
+		case opts.Since.After(replaceFrom):
+			opts.Since = &replaceFrom
+			opts.ReplaceFrom = &replaceFrom
+		default:
+			opts.ReplaceFrom = opts.Since
+		}
+	}
+	return opts
+}
+
+// exports metrics for every date in the run window that has metrics. Dates
+// whose exports are held are skipped until the hold is approved.
+func (s *PipelineService) exportWindow(ctx context.Context, since *time.Time) error {
+	from := s.clock.Now().AddDate(0, 0, -1)
+	if since != nil {
+		from = *since
+	}
+
+	for date := from; !date.After(s.clock.Now()); date = date.AddDate(0, 0, 1) {
+		metrics, err := s.metricsRepo.GetByDate(ctx, date)
+		if err != nil {
+			return fmt.Errorf("failed to get metrics for export: %w", err)
+		}
+		if len(metrics) == 0 {
+			continue
+		}
+		err = s.metricsService.ExportMetrics(ctx, date)
+		if errors.Is(err, domain.ErrExportHeld) {
+			s.logger.WithContext(ctx).WithError(err).Warn("Skipping held export")
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,358 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"etlgo/internal/domain"
+	"etlgo/pkg/logger"
+	"etlgo/pkg/metrics"
+)
+
+// QuotaService accounts upstream API requests per source and day and
+// ingested records per tenant and month, rejecting work once a limit is
+// reached. Periods are UTC calendar days and months. The tenant is the one
+// of the API key the request authenticated with, never the X-Tenant-ID
+// header clients set freely.
+type QuotaService struct {
+	repo           domain.QuotaRepository
+	upstreamLimits domain.QuotaLimits
+	recordLimits   domain.QuotaLimits
+	clock          domain.Clock
+	logger         *logger.Logger
+	metrics        *metrics.Metrics
+}
+
+// NewQuotaService creates a quota service with per-source daily call limits
+// and per-tenant monthly record limits
+func NewQuotaService(
+	repo domain.QuotaRepository,
+	upstreamLimits, recordLimits domain.QuotaLimits,
+	clock domain.Clock,
+	logger *logger.Logger,
+	metrics *metrics.Metrics,
+) *QuotaService {
+	return &QuotaService{
+		repo:           repo,
+		upstreamLimits: upstreamLimits,
+		recordLimits:   recordLimits,
+		clock:          clock,
+		logger:         logger,
+		metrics:        metrics,
+	}
+}
+
+// AnalyticsClient wraps an analytics client so every request it sends
+// consumes the ga4 daily call quota
+func (s *QuotaService) AnalyticsClient(next domain.AnalyticsClient) domain.AnalyticsClient {
+	return &quotaAnalyticsClient{next: next, quotas: s}
+}
+
+// KeywordClient wraps the keyword feed so every request it sends consumes
+// the keywords daily call quota
+func (s *QuotaService) KeywordClient(next domain.KeywordClient) domain.KeywordClient {
+	return &quotaKeywordClient{next: next, quotas: s}
+}
+
+// Client wraps an API client so every request it sends, each page of a
+// fetch included, consumes its source's daily call quota and is refused
+// once the quota is exhausted
+func (s *QuotaService) Client(next domain.ExternalAPIClient) domain.ExternalAPIClient {
+	return &quotaClient{next: next, quotas: s}
+}
+
+// CheckRun rejects a run when a source it extracts has no calls left today
+// or the tenant has no records left this month
+func (s *QuotaService) CheckRun(ctx context.Context, sources []string) error {
+	for _, source := range sources {
+		if err := s.check(ctx, domain.QuotaUpstreamCalls, source, 1); err != nil {
+			return err
+		}
+	}
+	return s.CheckRecords(ctx, 1)
+}
+
+// CheckRecords rejects ingesting n records when the tenant does not have
+// that many left this month
+func (s *QuotaService) CheckRecords(ctx context.Context, n int64) error {
+	return s.check(ctx, domain.QuotaRecordsIngested, quotaTenant(ctx), n)
+}
+
+// AddRecords accounts records ingested for the tenant. It never fails: a
+// run admitted within the quota may finish above it.
+func (s *QuotaService) AddRecords(ctx context.Context, n int64) {
+	key := quotaKey(domain.QuotaRecordsIngested, quotaTenant(ctx), s.clock.Now())
+	if _, err := s.repo.Consume(ctx, key, n, 0); err != nil {
+		s.logger.WithContext(ctx).WithError(err).Warn("Failed to account ingested records")
+	}
+}
+
+// AddCost accounts the resources a run or push consumed to the tenant's
+// current month. Like AddRecords it never fails.
+func (s *QuotaService) AddCost(ctx context.Context, kind string, cost domain.RunCost) {
+	tenant := quotaTenant(ctx)
+	period := s.clock.Now().UTC().Format("2006-01")
+
+	counters := map[string]int64{
+		costQuotaKind + kind: 1,
+		costQuotaWallTime:    int64(cost.WallTimeSeconds * 1e6),
+		costQuotaBytes:       cost.BytesDownloaded,
+		costQuotaAPICalls:    cost.APICalls,
+		costQuotaRecords:     cost.RecordsProcessed,
+	}
+	for stage, seconds := range cost.StageSeconds {
+		counters[costQuotaStage+stage] = int64(seconds * 1e6)
+	}
+
+	for quota, n := range counters {
+		key := domain.QuotaKey{Quota: quota, Subject: tenant, Period: period}
+		if _, err := s.repo.Consume(ctx, key, n, 0); err != nil {
+			s.logger.WithContext(ctx).WithError(err).WithField("counter", quota).Warn("Failed to account run cost")
+		}
+	}
+}
+
+// CostUsage returns the resources accounted to the tenant in month, given as
+// YYYY-MM
+func (s *QuotaService) CostUsage(ctx context.Context, month string) (*domain.CostUsage, error) {
+	tenant := quotaTenant(ctx)
+	get := func(quota string) (int64, error) {
+		return s.repo.Get(ctx, domain.QuotaKey{Quota: quota, Subject: tenant, Period: month})
+	}
+
+	usage := &domain.CostUsage{
+		Tenant:       tenant,
+		Period:       month,
+		StageSeconds: make(map[string]float64, len(domain.CostStages)),
+	}
+	counters := []struct {
+		quota string
+		value *int64
+	}{
+		{costQuotaKind + domain.CostKindRun, &usage.Runs},
+		{costQuotaKind + domain.CostKindPush, &usage.Pushes},
+		{costQuotaBytes, &usage.BytesDownloaded},
+		{costQuotaAPICalls, &usage.APICalls},
+		{costQuotaRecords, &usage.RecordsProcessed},
+	}
+	for _, counter := range counters {
+		n, err := get(counter.quota)
+		if err != nil {
+			return nil, err
+		}
+		*counter.value = n
+	}
+
+	wallMicros, err := get(costQuotaWallTime)
+	if err != nil {
+		return nil, err
+	}
+	usage.WallTimeSeconds = float64(wallMicros) / 1e6
+	for _, stage := range domain.CostStages {
+		micros, err := get(costQuotaStage + stage)
+		if err != nil {
+			return nil, err
+		}
+		usage.StageSeconds[stage] = float64(micros) / 1e6
+	}
+	return usage, nil
+}
+
+// Usage returns the tenant's record quota and the call quota of every
+// upstream source in their current periods
+func (s *QuotaService) Usage(ctx context.Context) ([]domain.QuotaUsage, error) {
+	now := s.clock.Now()
+	var usages []domain.QuotaUsage
+
+	record, err := s.usage(ctx, domain.QuotaRecordsIngested, quotaTenant(ctx), now)
+	if err != nil {
+		return nil, err
+	}
+	usages = append(usages, record)
+
+	for _, source := range []string{domain.SourceAds, domain.SourceCRM, domain.SourceGA4, domain.SourceKeywords} {
+		upstream, err := s.usage(ctx, domain.QuotaUpstreamCalls, source, now)
+		if err != nil {
+			return nil, err
+		}
+		usages = append(usages, upstream)
+	}
+	return usages, nil
+}
+
+// returns a context whose upstream requests consume calls of their source's
+// daily quota
+func (s *QuotaService) metered(ctx context.Context) context.Context {
+	return domain.WithUpstreamCalls(ctx, s.consumeCall)
+}
+
+// consumes one call from the source's daily quota
+func (s *QuotaService) consumeCall(ctx context.Context, source string) error {
+	key := quotaKey(domain.QuotaUpstreamCalls, source, s.clock.Now())
+	_, err := s.repo.Consume(ctx, key, 1, s.upstreamLimits.For(source))
+	if errors.Is(err, domain.ErrQuotaExceeded) {
+		return s.exceeded(ctx, domain.QuotaUpstreamCalls, source, 1)
+	}
+	return err
+}
+
+func (s *QuotaService) check(ctx context.Context, quota, subject string, n int64) error {
+	usage, err := s.usage(ctx, quota, subject, s.clock.Now())
+	if err != nil {
+		return err
+	}
+	if usage.Limit > 0 && usage.Used+n > usage.Limit {
+		return s.exceeded(ctx, quota, subject, n)
+	}
+	return nil
+}
+
+// returns the quota error for a rejected request and counts the rejection
+func (s *QuotaService) exceeded(ctx context.Context, quota, subject string, n int64) error {
+	usage, err := s.usage(ctx, quota, subject, s.clock.Now())
+	if err != nil {
+		return err
+	}
+	if quota == domain.QuotaRecordsIngested {
+		s.metrics.RecordTenantQuotaRejection(quota, subject)
+	} else {
+		s.metrics.RecordQuotaRejection(quota, subject)
+	}
+	s.logger.WithContext(ctx).WithFields(map[string]any{
+		"quota":     quota,
+		"subject":   subject,
+		"used":      usage.Used,
+		"limit":     usage.Limit,
+		"requested": n,
+	}).Warn("Quota exhausted")
+	return &domain.QuotaError{Usage: usage, Requested: n}
+}
+
+func (s *QuotaService) usage(ctx context.Context, quota, subject string, now time.Time) (domain.QuotaUsage, error) {
+	key := quotaKey(quota, subject, now)
+	used, err := s.repo.Get(ctx, key)
+	if err != nil {
+		return domain.QuotaUsage{}, err
+	}
+
+	limits := s.upstreamLimits
+	if quota == domain.QuotaRecordsIngested {
+		limits = s.recordLimits
+	}
+
+	usage := domain.QuotaUsage{
+		Quota:    quota,
+		Subject:  subject,
+		Period:   key.Period,
+		Used:     used,
+		Limit:    limits.For(subject),
+		ResetsAt: quotaReset(quota, now),
+	}
+	if usage.Limit > 0 {
+		remaining := max(usage.Limit-used, 0)
+		usage.Remaining = &remaining
+	}
+	return usage, nil
+}
+
+// unlimited counters cost accounting keeps per tenant and month, durations
+// in microseconds
+const (
+	costQuotaKind     = "cost_"
+	costQuotaWallTime = "cost_wall_time_us"
+	costQuotaStage    = "cost_stage_us:"
+	costQuotaBytes    = "cost_bytes_downloaded"
+	costQuotaAPICalls = "cost_api_calls"
+	costQuotaRecords  = "cost_records_processed"
+)
+
+// returns the counter key of the quota's period containing now
+func quotaKey(quota, subject string, now time.Time) domain.QuotaKey {
+	layout := "2006-01-02"
+	if quota == domain.QuotaRecordsIngested {
+		layout = "2006-01"
+	}
+	return domain.QuotaKey{Quota: quota, Subject: subject, Period: now.UTC().Format(layout)}
+}
+
+// returns when the quota's current period ends
+func quotaReset(quota string, now time.Time) time.Time {
+	now = now.UTC()
+	if quota == domain.QuotaRecordsIngested {
+		return time.Date(now.Year(), now.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	return time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+}
+
+// returns the tenant quota usage in ctx is accounted to: the tenant of the
+// request's API key, or the default tenant without one
+func quotaTenant(ctx context.Context) string {
+	if key := domain.APIKeyFromContext(ctx); key != nil && key.Tenant != "" {
+		return key.Tenant
+	}
+	return domain.DefaultTenant
+}
+
+// returns the tenant usage in ctx is accounted to
+func tenantOf(ctx context.Context) string {
+	if tenant := domain.TenantFromContext(ctx); tenant != "" {
+		return tenant
+	}
+	return domain.DefaultTenant
+}
+
+// consumes the source's call quota before each request of a fetch
+type quotaClient struct {
+	next   domain.ExternalAPIClient
+	quotas *QuotaService
+}
+
+func (c *quotaClient) FetchAdsData(ctx context.Context, since *time.Time) (*domain.AdData, error) {
+	return c.next.FetchAdsData(c.quotas.metered(ctx), since)
+}
+
+func (c *quotaClient) FetchCRMData(ctx context.Context, since *time.Time) (*domain.CRMData, error) {
+	return c.next.FetchCRMData(c.quotas.metered(ctx), since)
+}
+
+// opening connections doesn't count as a call
+func (c *quotaClient) Prewarm(ctx context.Context) {
+	c.next.Prewarm(ctx)
+}
+
+func (c *quotaClient) TracksExtraction(source string) bool {
+	return domain.TracksExtraction(c.next, source)
+}
+
+func (c *quotaClient) Connectors() []domain.ConnectorInfo {
+	return domain.ListConnectors(c.next)
+}
+
+// consumes the ga4 call quota before each request of a fetch
+type quotaAnalyticsClient struct {
+	next   domain.AnalyticsClient
+	quotas *QuotaService
+}
+
+func (c *quotaAnalyticsClient) FetchAnalyticsData(ctx context.Context, from, to time.Time) (*domain.AnalyticsData, error) {
+	return c.next.FetchAnalyticsData(c.quotas.metered(ctx), from, to)
+}
+
+func (c *quotaAnalyticsClient) Prewarm(ctx context.Context) {
+	c.next.Prewarm(ctx)
+}
+
+// consumes the keywords call quota before each request of a fetch
+type quotaKeywordClient struct {
+	next   domain.KeywordClient
+	quotas *QuotaService
+}
+
+func (c *quotaKeywordClient) FetchKeywordData(ctx context.Context, since *time.Time) (*domain.KeywordData, error) {
+	return c.next.FetchKeywordData(c.quotas.metered(ctx), since)
+}
+
+func (c *quotaKeywordClient) Prewarm(ctx context.Context) {
+	c.next.Prewarm(ctx)
+}
@@ -0,0 +1,130 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"etlgo/internal/domain"
+	"etlgo/pkg/logger"
+	"etlgo/pkg/metrics"
+)
+
+// feature flags gating export destinations that are still being rolled out
+var destinationFlags = map[string]string{
+	domain.RawExportDestinationSink: domain.FlagSinkExport,
+	domain.RawExportDestinationS3:   domain.FlagS3Export,
+}
+
+// RawExportService exports the underlying processed records for auditing
+type RawExportService struct {
+	adRepo       domain.AdRepository
+	crmRepo      domain.CRMRepository
+	exportClient domain.RawExportClient
+	flags        domain.FeatureFlagProvider
+	logger       *logger.Logger
+	metrics      *metrics.Metrics
+}
+
+// NewRawExportService creates a new raw export service
+func NewRawExportService(
+	adRepo domain.AdRepository,
+	crmRepo domain.CRMRepository,
+	exportClient domain.RawExportClient,
+	flags domain.FeatureFlagProvider,
+	logger *logger.Logger,
+	metrics *metrics.Metrics,
+) *RawExportService {
+	return &RawExportService{
+		adRepo:       adRepo,
+		crmRepo:      crmRepo,
+		exportClient: exportClient,
+		flags:        flags,
+		logger:       logger,
+		metrics:      metrics,
+	}
+}
+
+// ExportRaw dumps processed ads and CRM records for the requested date range
+func (s *RawExportService) ExportRaw(ctx context.Context, req domain.RawExportRequest) ([]domain.RawExportResult, error) {
+	if flag, ok := destinationFlags[req.Destination]; ok && !s.flags.IsEnabled(ctx, flag) {
+		return nil, fmt.Errorf("%w: %s exports are turned off by the %s flag", domain.ErrFeatureDisabled, req.Destination, flag)
+	}
+
+	log := s.logger.WithContext(ctx)
+	log.WithFields(map[string]interface{}{
+		"from":        req.From.Format("2006-01-02"),
+		"to":          req.To.Format("2006-01-02"),
+		"format":      req.Format,
+		"destination": req.Destination,
+		"datasets":    req.Datasets,
+	}).Info("Starting raw data export")
+
+	// Scoped callers export only the records in their scope
+	scope := domain.MetricsScopeFromContext(ctx)
+	var results []domain.RawExportResult
+
+	for _, dataset := range req.Datasets {
+		var result *domain.RawExportResult
+
+		switch dataset {
+		case domain.RawDatasetAds:
+			ads, err := s.adRepo.GetByDateRange(ctx, req.From, req.To)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get ads data for export: %w", err)
+			}
+			result, err = s.exportClient.ExportAds(ctx, domain.InScope(scope, ads), req)
+			if err != nil {
+				log.WithError(err).Error("Failed to export raw ads data")
+				return nil, fmt.Errorf("failed to export ads data: %w", err)
+			}
+
+		case domain.RawDatasetCRM:
+			opportunities, err := s.crmRepo.GetByDateRange(ctx, req.From, req.To)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get CRM data for export: %w", err)
+			}
+			result, err = s.exportClient.ExportOpportunities(ctx, maskOpportunities(domain.InScope(scope, opportunities)), req)
+			if err != nil {
+				log.WithError(err).Error("Failed to export raw CRM data")
+				return nil, fmt.Errorf("failed to export CRM data: %w", err)
+			}
+
+		default:
+			return nil, domain.Errorf(domain.ErrValidation, "unsupported dataset %q", dataset)
+		}
+
+		results = append(results, *result)
+		s.metrics.RecordETLRecords(dataset, "raw_exported", tenantOf(ctx), result.Records)
+	}
+
+	s.metrics.RecordBusinessMetric("raw_export")
+
+	log.WithField("files", len(results)).Info("Raw data export completed successfully")
+	return results, nil
+}
+
+// maskOpportunities returns copies of the opportunities with PII masked
+func maskOpportunities(opportunities []domain.ProcessedOpportunity) []domain.ProcessedOpportunity {
+	masked := make([]domain.ProcessedOpportunity, len(opportunities))
+	for i, opp := range opportunities {
+		opp.ContactEmail = maskEmail(opp.ContactEmail)
+		masked[i] = opp
+	}
+	return masked
+}
+
+// maskEmail keeps the first character of the local part, a whole rune
+// even when it is multi-byte, and the domain
+func maskEmail(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at <= 0 {
+		if email == "" {
+			return ""
+		}
+		return "***"
+	}
+	_, size := utf8.DecodeRuneInString(email)
+	return email[:size] + "***" + email[at:]
+}
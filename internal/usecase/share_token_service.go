@@ -0,0 +1,139 @@
+package usecase
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"etlgo/internal/domain"
+	"etlgo/pkg/logger"
+	"etlgo/pkg/metrics"
+)
+
+// ShareTokenService issues, verifies and revokes share tokens, signed
+// grants of read-only access to one metrics view that expire on their own
+type ShareTokenService struct {
+	secret      string
+	maxTTL      time.Duration
+	roles       domain.Roles
+	revocations domain.ShareTokenRevocationRepository
+	clock       domain.Clock
+	ids         domain.IDGenerator
+	logger      *logger.Logger
+	metrics     *metrics.Metrics
+}
+
+// NewShareTokenService creates a new share token service. Tokens are
+// signed with secret and valid for up to maxTTL; an empty secret disables
+// them. Tokens may hide the columns of one of roles; revoked ones are kept
+// in revocations until they would have expired.
+func NewShareTokenService(
+	secret string,
+	maxTTL time.Duration,
+	roles domain.Roles,
+	revocations domain.ShareTokenRevocationRepository,
+	clock domain.Clock,
+	ids domain.IDGenerator,
+	logger *logger.Logger,
+	metrics *metrics.Metrics,
+) *ShareTokenService {
+	return &ShareTokenService{
+		secret:      secret,
+		maxTTL:      maxTTL,
+		roles:       roles,
+		revocations: revocations,
+		clock:       clock,
+		ids:         ids,
+		logger:      logger,
+		metrics:     metrics,
+	}
+}
+
+// Enabled reports whether share tokens are issued and accepted
+func (s *ShareTokenService) Enabled() bool {
+	return s.secret != ""
+}
+
+// Issue signs a token granting the view for ttl, the longest allowed when
+// zero. It returns the signed token with its grant.
+func (s *ShareTokenService) Issue(ctx context.Context, grant domain.ShareToken, ttl time.Duration) (string, *domain.ShareToken, error) {
+	if !s.Enabled() {
+		return "", nil, domain.ErrShareTokensNotConfigured
+	}
+	if err := grant.Validate(); err != nil {
+		return "", nil, err
+	}
+	if _, ok := s.roles[grant.Role]; grant.Role != "" && !ok {
+		return "", nil, domain.Errorf(domain.ErrValidation, "unknown role %q", grant.Role)
+	}
+	if ttl < 0 || ttl > s.maxTTL {
+		return "", nil, domain.Errorf(domain.ErrValidation, "expires_in must be between 0 and %s", s.maxTTL)
+	}
+	if ttl == 0 {
+		ttl = s.maxTTL
+	}
+
+	grant.ID = s.ids.NewID()
+	grant.ExpiresAt = s.clock.Now().UTC().Add(ttl).Truncate(time.Second)
+	signed, err := grant.Sign(s.secret)
+	if err != nil {
+		return "", nil, err
+	}
+
+	s.metrics.RecordBusinessMetric("share_token_issued")
+	s.logger.WithContext(ctx).WithFields(map[string]any{
+		"share_token": grant.ID,
+		"name":        grant.Name,
+		"expires_at":  grant.ExpiresAt,
+	}).Info("Share token issued")
+	return signed, &grant, nil
+}
+
+// Verify returns the grant of a signed token that hasn't expired or been
+// revoked
+func (s *ShareTokenService) Verify(ctx context.Context, signed string) (*domain.ShareToken, error) {
+	token, err := domain.ParseShareToken(signed, s.secret, s.clock.Now())
+	if err != nil {
+		return nil, err
+	}
+	revoked, err := s.revocations.IsRevoked(ctx, token.ID)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, domain.ErrShareTokenRevoked
+	}
+	return token, nil
+}
+
+// Revoke revokes the token issued with the grant ID, whether or not this
+// instance issued it. Revoking a token again returns its first revocation.
+// Revocations older than the longest a token lives are dropped meanwhile.
+func (s *ShareTokenService) Revoke(ctx context.Context, id string) (*domain.ShareTokenRevocation, error) {
+	if !s.Enabled() {
+		return nil, domain.ErrShareTokensNotConfigured
+	}
+	if strings.TrimSpace(id) == "" {
+		return nil, domain.Errorf(domain.ErrValidation, "share token ID is required")
+	}
+
+	now := s.clock.Now().UTC()
+	if err := s.revocations.Prune(ctx, now.Add(-s.maxTTL)); err != nil {
+		s.logger.WithContext(ctx).WithError(err).Warn("Failed to prune share token revocations")
+	}
+	revocation := domain.ShareTokenRevocation{ID: id, RevokedAt: now}
+	if key := domain.APIKeyFromContext(ctx); key != nil {
+		revocation.RevokedBy = key.Name
+	}
+	stored, err := s.revocations.Revoke(ctx, revocation)
+	if err != nil {
+		return nil, err
+	}
+
+	s.metrics.RecordBusinessMetric("share_token_revoked")
+	s.logger.WithContext(ctx).WithFields(map[string]any{
+		"share_token": id,
+		"revoked_by":  stored.RevokedBy,
+	}).Info("Share token revoked")
+	return stored, nil
+}
@@ -0,0 +1,59 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+
+	"etlgo/internal/domain"
+	"etlgo/pkg/logger"
+)
+
+// StorageService reports on and migrates the schema of the storage backend
+type StorageService struct {
+	storage *domain.Storage
+	logger  *logger.Logger
+}
+
+// NewStorageService creates a new storage service
+func NewStorageService(storage *domain.Storage, logger *logger.Logger) *StorageService {
+	return &StorageService{
+		storage: storage,
+		logger:  logger,
+	}
+}
+
+// Status returns the backend name and, for SQL backends, the schema version
+func (s *StorageService) Status(ctx context.Context) (domain.StorageStatus, error) {
+	status := domain.StorageStatus{Backend: s.storage.Backend}
+	if s.storage.Schema == nil {
+		return status, nil
+	}
+
+	schema, err := s.storage.Schema.Status(ctx)
+	if err != nil {
+		return status, fmt.Errorf("failed to read schema status: %w", err)
+	}
+	status.Schema = &schema
+	return status, nil
+}
+
+// Migrate applies pending schema migrations. Backends without a schema are
+// left untouched.
+func (s *StorageService) Migrate(ctx context.Context) (domain.StorageStatus, error) {
+	status := domain.StorageStatus{Backend: s.storage.Backend}
+	if s.storage.Schema == nil {
+		return status, nil
+	}
+
+	schema, err := s.storage.Schema.Migrate(ctx)
+	if err != nil {
+		return status, fmt.Errorf("failed to migrate %s schema: %w", s.storage.Backend, err)
+	}
+	status.Schema = &schema
+
+	s.logger.WithContext(ctx).WithFields(map[string]any{
+		"backend": s.storage.Backend,
+		"version": schema.Version,
+	}).Info("Storage schema is up to date")
+	return status, nil
+}
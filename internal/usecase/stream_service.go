@@ -0,0 +1,232 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"etlgo/internal/domain"
+	"etlgo/pkg/logger"
+	"etlgo/pkg/metrics"
+)
+
+// StreamService ingests the ads and CRM topics of a stream continuously.
+// Records are collected into batches of up to batchSize messages, or what
+// arrived within batchWait of the first one, and each batch is run through
+// the pipeline like an ingestion. Offsets are only committed once the run
+// loaded the batch; a failed batch is rewound and retried after
+// retryBackoff, so every record is loaded at least once.
+type StreamService struct {
+	consumer     domain.StreamConsumer
+	etlService   *ETLService
+	jobQueue     *JobQueue
+	batchSize    int
+	batchWait    time.Duration
+	retryBackoff time.Duration
+	status       domain.StreamStatus
+	mutex        sync.RWMutex
+	wg           sync.WaitGroup
+	clock        domain.Clock
+	ids          domain.IDGenerator
+	logger       *logger.Logger
+	metrics      *metrics.Metrics
+}
+
+// NewStreamService creates a stream ingestion service. A nil consumer
+// leaves streaming disabled.
+func NewStreamService(
+	consumer domain.StreamConsumer,
+	etlService *ETLService,
+	jobQueue *JobQueue,
+	group string,
+	batchSize int,
+	batchWait time.Duration,
+	retryBackoff time.Duration,
+	clock domain.Clock,
+	ids domain.IDGenerator,
+	logger *logger.Logger,
+	metrics *metrics.Metrics,
+) *StreamService {
+	s := &StreamService{
+		consumer:     consumer,
+		etlService:   etlService,
+		jobQueue:     jobQueue,
+		batchSize:    max(batchSize, 1),
+		batchWait:    batchWait,
+		retryBackoff: retryBackoff,
+		clock:        clock,
+		ids:          ids,
+		logger:       logger,
+		metrics:      metrics,
+	}
+	if consumer != nil {
+		s.status = domain.StreamStatus{Enabled: true, Group: group, Topics: consumer.Topics()}
+	}
+	return s
+}
+
+// Enabled reports whether a stream is configured
+func (s *StreamService) Enabled() bool {
+	return s.consumer != nil
+}
+
+// Status returns the state of streaming ingestion
+func (s *StreamService) Status() domain.StreamStatus {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	status := s.status
+	status.Committed = slices.Clone(s.status.Committed)
+	return status
+}
+
+// Consume loads batches from the stream until ctx is cancelled, then
+// leaves the consumer group. It runs as a background job.
+func (s *StreamService) Consume(ctx context.Context, heartbeat domain.Heartbeat) {
+	s.wg.Add(1)
+	defer s.wg.Done()
+	defer func() {
+		closeCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := s.consumer.Close(closeCtx); err != nil {
+			s.logger.WithError(err).Warn("Failed to leave the stream consumer group")
+		}
+	}()
+
+	for ctx.Err() == nil {
+		heartbeat.Beat()
+		batch, err := s.collect(ctx, heartbeat)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			s.fail(err)
+			s.logger.WithError(err).Warn("Failed to poll the stream")
+			heartbeat.Sleep(ctx, s.retryBackoff)
+			continue
+		}
+		if batch == nil {
+			continue
+		}
+
+		if err := s.load(ctx, batch); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			s.fail(err)
+			s.logger.WithError(err).WithField("messages", batch.Messages).Warn("Failed to load stream batch, retrying")
+			if err := s.consumer.Rewind(ctx, batch); err != nil {
+				s.logger.WithError(err).Warn("Failed to rewind the stream")
+			}
+			heartbeat.Sleep(ctx, s.retryBackoff)
+		}
+	}
+}
+
+// Wait blocks until consuming stopped and the consumer left its group
+func (s *StreamService) Wait() {
+	s.wg.Wait()
+}
+
+// polls until the batch is full or batchWait passed since its first
+// records. Returns nil when no records arrived.
+func (s *StreamService) collect(ctx context.Context, heartbeat domain.Heartbeat) (*domain.StreamBatch, error) {
+	var batch *domain.StreamBatch
+	var deadline time.Time
+	for ctx.Err() == nil {
+		heartbeat.Beat()
+		next, err := s.consumer.Poll(ctx)
+		if err != nil {
+			if batch != nil {
+				// the records polled so far were not committed, so they
+				// are read again
+				_ = s.consumer.Rewind(ctx, batch)
+			}
+			return nil, err
+		}
+		if next != nil {
+			if batch == nil {
+				batch = next
+				deadline = s.clock.Now().Add(s.batchWait)
+			} else {
+				batch.Append(next)
+			}
+		}
+
+		switch {
+		case batch == nil:
+			return nil, nil
+		case batch.Messages >= s.batchSize, !s.clock.Now().Before(deadline):
+			return batch, nil
+		}
+	}
+	return batch, ctx.Err()
+}
+
+// runs the batch through the pipeline and commits its offsets. Batches
+// of tombstones only are committed as they are.
+func (s *StreamService) load(ctx context.Context, batch *domain.StreamBatch) error {
+	ctx = context.WithValue(ctx, logger.RequestIDKey, s.ids.NewID())
+
+	var summary *domain.RunSummary
+	if len(batch.Sources()) > 0 {
+		err := s.jobQueue.Run(ctx, domain.JobTypeIngest, domain.PriorityNormal, func(ctx context.Context) error {
+			var err error
+			summary, err = s.etlService.IngestStream(ctx, batch)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := s.consumer.Commit(ctx, batch); err != nil {
+		// the batch was loaded; it is loaded again once redelivered
+		s.logger.WithContext(ctx).WithError(err).Warn("Failed to commit stream offsets")
+		s.fail(err)
+		return nil
+	}
+
+	now := s.clock.Now().UTC()
+	s.mutex.Lock()
+	s.status.Batches++
+	s.status.Messages += batch.Messages
+	s.status.LastLoadAt = &now
+	s.status.LastError = ""
+	if summary != nil {
+		s.status.LastRunID = summary.ID
+	}
+	for _, partition := range batch.Partitions {
+		i := slices.IndexFunc(s.status.Committed, func(committed domain.StreamPartition) bool {
+			return committed.Topic == partition.Topic && committed.Partition == partition.Partition
+		})
+		if i < 0 {
+			s.status.Committed = append(s.status.Committed, partition)
+		} else {
+			s.status.Committed[i] = partition
+		}
+	}
+	s.mutex.Unlock()
+
+	s.metrics.RecordBusinessMetric("stream_batch")
+	s.logger.WithContext(ctx).WithFields(map[string]any{
+		"messages":   batch.Messages,
+		"partitions": len(batch.Partitions),
+	}).Info("Stream batch loaded")
+	return nil
+}
+
+// counts a failed poll, load or commit
+func (s *StreamService) fail(err error) {
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	s.metrics.RecordBusinessMetric("stream_batch_failed")
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.status.Failures++
+	s.status.LastError = err.Error()
+}
@@ -0,0 +1,196 @@
+package usecase
+
+import (
+	"fmt"
+	"math"
+	"slices"
+
+	"etlgo/internal/domain"
+)
+
+// scales the median absolute deviation to a standard deviation for normal data
+const madScale = 1.4826
+
+// a numeric field value policies apply to
+type valueField[T any] struct {
+	name string
+	get  func(*T) float64
+	set  func(*T, float64)
+}
+
+// describes how value policies reach into one processed record type
+type valueTarget[T any] struct {
+	source string
+	id     func(*T) string
+	flag   func(*T, string)
+	fields []valueField[T]
+}
+
+var adValueTarget = valueTarget[domain.ProcessedAdData]{
+	source: domain.SourceAds,
+	id:     func(ad *domain.ProcessedAdData) string { return ad.CampaignID },
+	flag:   func(ad *domain.ProcessedAdData, flag string) { ad.Flags = append(ad.Flags, flag) },
+	fields: []valueField[domain.ProcessedAdData]{
+		{
+			name: "cost",
+			get:  func(ad *domain.ProcessedAdData) float64 { return ad.Cost.Float64() },
+			set:  func(ad *domain.ProcessedAdData, v float64) { ad.Cost = domain.MoneyFromFloat(v) },
+		},
+		{
+			name: "clicks",
+			get:  func(ad *domain.ProcessedAdData) float64 { return float64(ad.Clicks) },
+			set:  func(ad *domain.ProcessedAdData, v float64) { ad.Clicks = int(v) },
+		},
+		{
+			name: "impressions",
+			get:  func(ad *domain.ProcessedAdData) float64 { return float64(ad.Impressions) },
+			set:  func(ad *domain.ProcessedAdData, v float64) { ad.Impressions = int(v) },
+		},
+	},
+}
+
+var crmValueTarget = valueTarget[domain.ProcessedOpportunity]{
+	source: domain.SourceCRM,
+	id:     func(opp *domain.ProcessedOpportunity) string { return opp.OpportunityID },
+	flag:   func(opp *domain.ProcessedOpportunity, flag string) { opp.Flags = append(opp.Flags, flag) },
+	fields: []valueField[domain.ProcessedOpportunity]{
+		{
+			name: "amount",
+			get:  func(opp *domain.ProcessedOpportunity) float64 { return opp.Amount.Float64() },
+			set:  func(opp *domain.ProcessedOpportunity, v float64) { opp.Amount = domain.MoneyFromFloat(v) },
+		},
+	},
+}
+
+// applies the configured value policies to the rows of one source. Rows
+// quarantined by a policy are handed to rejects and left out of the result;
+// per-field counts are added to reports.
+func applyValuePolicies[T any](rows []T, target valueTarget[T], policies domain.ValuePolicies, rejects *rowRejects, reports map[string]*domain.ValueReport) []T {
+	type activePolicy struct {
+		field     valueField[T]
+		key       string
+		policy    domain.ValuePolicy
+		threshold float64
+		report    *domain.ValueReport
+	}
+
+	var active []activePolicy
+	for _, field := range target.fields {
+		key := target.source + "." + field.name
+		policy, ok := policies[key]
+		if !ok || (policy.Negative == domain.ValueAllow && policy.Outlier == domain.ValueAllow) {
+			continue
+		}
+
+		report := &domain.ValueReport{}
+		reports[key] = report
+		threshold := outlierThreshold(rows, field, policy)
+		if !math.IsInf(threshold, 1) {
+			report.Threshold = threshold
+		}
+		active = append(active, activePolicy{field: field, key: key, policy: policy, threshold: threshold, report: report})
+	}
+	if len(active) == 0 {
+		return rows
+	}
+
+	kept := rows[:0]
+	for i := range rows {
+		row := &rows[i]
+		var errs []domain.RecordError
+		var quarantinedBy []*domain.ValueReport
+
+		for _, p := range active {
+			value := p.field.get(row)
+
+			var action domain.ValueAction
+			var reason string
+			var clamped float64
+			switch {
+			case value < 0:
+				p.report.Negative++
+				action, reason, clamped = p.policy.Negative, "negative value", 0
+			case value > p.threshold:
+				p.report.Outliers++
+				action, reason, clamped = p.policy.Outlier, fmt.Sprintf("outlier above %g", p.threshold), p.threshold
+			default:
+				continue
+			}
+
+			switch action {
+			case domain.ValueClamp:
+				p.field.set(row, clamped)
+				p.report.Clamped++
+			case domain.ValueFlag:
+				target.flag(row, p.field.name+"_"+flagSuffix(value))
+				p.report.Flagged++
+			case domain.ValueQuarantine:
+				errs = append(errs, domain.RecordError{
+					Record: target.id(row),
+					Field:  p.field.name,
+					Value:  fmt.Sprint(value),
+					Reason: reason,
+				})
+				quarantinedBy = append(quarantinedBy, p.report)
+			}
+		}
+
+		if len(errs) > 0 {
+			for _, report := range quarantinedBy {
+				report.Quarantined++
+			}
+			rejects.quarantine(target.id(row), *row, errs...)
+			continue
+		}
+		kept = append(kept, *row)
+	}
+
+	return kept
+}
+
+func flagSuffix(value float64) string {
+	if value < 0 {
+		return "negative"
+	}
+	return "outlier"
+}
+
+// returns the lowest of the absolute and deviation based thresholds, or
+// +Inf when the policy has no outlier check
+func outlierThreshold[T any](rows []T, field valueField[T], policy domain.ValuePolicy) float64 {
+	threshold := math.Inf(1)
+	if policy.Max > 0 {
+		threshold = policy.Max
+	}
+
+	if policy.MaxDeviation > 0 && len(rows) > 0 {
+		values := make([]float64, len(rows))
+		for i := range rows {
+			values[i] = field.get(&rows[i])
+		}
+		median := medianOf(values)
+
+		deviations := make([]float64, len(values))
+		for i, v := range values {
+			deviations[i] = math.Abs(v - median)
+		}
+		mad := medianOf(deviations)
+
+		// with no spread every value is identical, so nothing is an outlier
+		if mad > 0 {
+			threshold = math.Min(threshold, median+policy.MaxDeviation*madScale*mad)
+		}
+	}
+
+	return threshold
+}
+
+func medianOf(values []float64) float64 {
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
@@ -0,0 +1,360 @@
+package usecase
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"etlgo/internal/domain"
+	"etlgo/pkg/logger"
+	"etlgo/pkg/metrics"
+)
+
+// how often within the deadline idle jobs beat and the watchdog checks them
+const watchdogChecksPerDeadline = 4
+
+// Watchdog supervises long-running background jobs. A job that doesn't beat
+// for longer than the deadline is considered wedged: its context is
+// cancelled, an incident is recorded and a new instance is started. Jobs
+// that run once, such as scheduled runs, are only watched and alerted on.
+type Watchdog struct {
+	deadline  time.Duration
+	incidents domain.JobIncidentRepository
+	jobs      map[string]*supervisedJob
+	mutex     sync.RWMutex
+	ids       domain.IDGenerator
+	logger    *logger.Logger
+	metrics   *metrics.Metrics
+}
+
+type supervisedJob struct {
+	name string
+	run  func(ctx context.Context, heartbeat domain.Heartbeat)
+	// current instance; beats of instances replaced after a restart are
+	// ignored
+	instance atomic.Int64
+	lastBeat atomic.Int64 // Unix nanoseconds
+
+	// guarded by Watchdog.mutex
+	running   bool
+	startedAt time.Time
+	restarts  int
+}
+
+// NewWatchdog creates a watchdog restarting jobs that miss their heartbeat
+// for longer than deadline. With a zero deadline heartbeats are only
+// tracked and jobs are never restarted.
+func NewWatchdog(deadline time.Duration, incidents domain.JobIncidentRepository, ids domain.IDGenerator, logger *logger.Logger, metrics *metrics.Metrics) *Watchdog {
+	return &Watchdog{
+		deadline:  deadline,
+		incidents: incidents,
+		jobs:      make(map[string]*supervisedJob),
+		ids:       ids,
+		logger:    logger,
+		metrics:   metrics,
+	}
+}
+
+// Go runs the job in the background under supervision until ctx is
+// cancelled. A job returning on its own isn't restarted.
+func (w *Watchdog) Go(ctx context.Context, name string, run func(ctx context.Context, heartbeat domain.Heartbeat)) {
+	job := &supervisedJob{name: name, run: run}
+	w.mutex.Lock()
+	w.jobs[name] = job
+	w.mutex.Unlock()
+
+	go w.supervise(ctx, job)
+}
+
+// Watch tracks a job that runs once, such as a scheduled run, under name
+// until the returned done is called. The job is listed with the background
+// jobs and exports its heartbeat like them; the returned context carries
+// the heartbeat for the job's stages to beat with domain.Beat. A job going
+// longer than the deadline without a beat is recorded as a stale incident
+// and alerted on, once per missed beat, but neither cancelled nor
+// restarted: it may be holding the dataset's loads.
+func (w *Watchdog) Watch(ctx context.Context, name string) (context.Context, func()) {
+	w.mutex.Lock()
+	job, ok := w.jobs[name]
+	if !ok {
+		job = &supervisedJob{name: name}
+		w.jobs[name] = job
+	}
+	w.mutex.Unlock()
+
+	heartbeat := w.start(job)
+	done := make(chan struct{})
+	go w.alert(ctx, job, done)
+	return domain.WithHeartbeat(ctx, heartbeat), func() {
+		close(done)
+		w.stopped(job)
+	}
+}
+
+// Deadline returns how long a job may go without a heartbeat
+func (w *Watchdog) Deadline() time.Duration {
+	return w.deadline
+}
+
+// Jobs returns the status of the supervised jobs by name
+func (w *Watchdog) Jobs() []domain.BackgroundJobStatus {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+
+	statuses := make([]domain.BackgroundJobStatus, 0, len(w.jobs))
+	for _, job := range w.jobs {
+		statuses = append(statuses, domain.BackgroundJobStatus{
+			Name:          job.name,
+			Running:       job.running,
+			StartedAt:     job.startedAt,
+			LastHeartbeat: job.lastHeartbeat(),
+			Restarts:      job.restarts,
+		})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+// Incidents returns the recorded incidents of the job, or of every job when
+// job is empty, most recent first
+func (w *Watchdog) Incidents(ctx context.Context, job string, limit int) ([]domain.JobIncident, error) {
+	if job != "" {
+		w.mutex.RLock()
+		_, ok := w.jobs[job]
+		w.mutex.RUnlock()
+		if !ok {
+			return nil, domain.ErrBackgroundJobNotFound
+		}
+	}
+	return w.incidents.List(ctx, job, limit)
+}
+
+// runs instances of the job until ctx is cancelled or one returns on its
+// own, replacing instances that miss the deadline
+func (w *Watchdog) supervise(ctx context.Context, job *supervisedJob) {
+	defer w.stopped(job)
+
+	for {
+		instanceCtx, cancel := context.WithCancel(ctx)
+		done := make(chan struct{})
+		heartbeat := w.start(job)
+		go func() {
+			defer close(done)
+			job.run(instanceCtx, heartbeat)
+		}()
+
+		lastBeat, wedged := w.watch(ctx, job, done)
+		cancel()
+		if !wedged {
+			<-done
+			return
+		}
+
+		w.restart(ctx, job, lastBeat, done)
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// waits until the instance returns, ctx is cancelled or the job misses the
+// deadline, reporting the last heartbeat when it did
+func (w *Watchdog) watch(ctx context.Context, job *supervisedJob, done <-chan struct{}) (time.Time, bool) {
+	if w.deadline <= 0 {
+		select {
+		case <-done:
+		case <-ctx.Done():
+		}
+		return time.Time{}, false
+	}
+
+	ticker := time.NewTicker(w.deadline / watchdogChecksPerDeadline)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return time.Time{}, false
+		case <-ctx.Done():
+			return time.Time{}, false
+		case <-ticker.C:
+			if lastBeat := job.lastHeartbeat(); time.Since(lastBeat) > w.deadline {
+				return lastBeat, true
+			}
+		}
+	}
+}
+
+// records an incident each time the watched job misses the deadline, until
+// done is closed or ctx is cancelled
+func (w *Watchdog) alert(ctx context.Context, job *supervisedJob, done <-chan struct{}) {
+	if w.deadline <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(w.deadline / watchdogChecksPerDeadline)
+	defer ticker.Stop()
+
+	var alerted time.Time // last heartbeat already alerted on
+	for {
+		select {
+		case <-done:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			lastBeat := job.lastHeartbeat()
+			if time.Since(lastBeat) <= w.deadline || lastBeat.Equal(alerted) {
+				continue
+			}
+			alerted = lastBeat
+			w.stale(ctx, job, lastBeat)
+		}
+	}
+}
+
+// records the incident of a watched job past the deadline
+func (w *Watchdog) stale(ctx context.Context, job *supervisedJob, lastBeat time.Time) {
+	w.mutex.RLock()
+	startedAt := job.startedAt
+	w.mutex.RUnlock()
+
+	incident := domain.JobIncident{
+		ID:            w.ids.NewID(),
+		Job:           job.name,
+		StartedAt:     startedAt,
+		LastHeartbeat: lastBeat,
+		DetectedAt:    time.Now(),
+		Deadline:      w.deadline.String(),
+		Stale:         true,
+	}
+	if err := w.incidents.Record(ctx, incident); err != nil {
+		w.logger.WithError(err).Warn("Failed to record background job incident")
+	}
+	w.metrics.RecordBackgroundJobStale(job.name)
+
+	w.logger.WithFields(map[string]any{
+		"job":            job.name,
+		"incident_id":    incident.ID,
+		"last_heartbeat": lastBeat,
+		"deadline":       w.deadline.String(),
+	}).Error("Watched job missed its heartbeat deadline")
+}
+
+// marks a new instance of the job as started and returns its heartbeat
+func (w *Watchdog) start(job *supervisedJob) *jobHeartbeat {
+	w.mutex.Lock()
+	job.running = true
+	job.startedAt = time.Now()
+	w.mutex.Unlock()
+
+	heartbeat := &jobHeartbeat{
+		job:      job,
+		instance: job.instance.Add(1),
+		interval: w.deadline / watchdogChecksPerDeadline,
+		metrics:  w.metrics,
+	}
+	heartbeat.Beat()
+	w.metrics.SetBackgroundJobRunning(job.name, true)
+	return heartbeat
+}
+
+// records the incident of a wedged, already cancelled instance. The
+// instance is given as long as the deadline to return before it is
+// abandoned.
+func (w *Watchdog) restart(ctx context.Context, job *supervisedJob, lastBeat time.Time, done <-chan struct{}) {
+	detectedAt := time.Now()
+
+	abandoned := false
+	timer := time.NewTimer(w.deadline)
+	select {
+	case <-done:
+	case <-timer.C:
+		abandoned = true
+	}
+	timer.Stop()
+
+	w.mutex.Lock()
+	job.restarts++
+	startedAt := job.startedAt
+	w.mutex.Unlock()
+
+	incident := domain.JobIncident{
+		ID:            w.ids.NewID(),
+		Job:           job.name,
+		StartedAt:     startedAt,
+		LastHeartbeat: lastBeat,
+		DetectedAt:    detectedAt,
+		Deadline:      w.deadline.String(),
+		Abandoned:     abandoned,
+	}
+	if err := w.incidents.Record(ctx, incident); err != nil {
+		w.logger.WithError(err).Warn("Failed to record background job incident")
+	}
+	w.metrics.RecordBackgroundJobRestart(job.name, abandoned)
+
+	w.logger.WithFields(map[string]any{
+		"job":            job.name,
+		"incident_id":    incident.ID,
+		"last_heartbeat": lastBeat,
+		"deadline":       w.deadline.String(),
+		"abandoned":      abandoned,
+	}).Error("Background job missed its heartbeat deadline, restarting it")
+}
+
+func (w *Watchdog) stopped(job *supervisedJob) {
+	w.mutex.Lock()
+	job.running = false
+	w.mutex.Unlock()
+	w.metrics.SetBackgroundJobRunning(job.name, false)
+}
+
+func (j *supervisedJob) lastHeartbeat() time.Time {
+	return time.Unix(0, j.lastBeat.Load())
+}
+
+// implements domain.Heartbeat for one instance of a supervised job
+type jobHeartbeat struct {
+	job      *supervisedJob
+	instance int64
+	interval time.Duration
+	metrics  *metrics.Metrics
+}
+
+func (h *jobHeartbeat) Beat() {
+	if h.job.instance.Load() != h.instance {
+		return
+	}
+	now := time.Now()
+	h.job.lastBeat.Store(now.UnixNano())
+	h.metrics.SetBackgroundJobHeartbeat(h.job.name, now)
+}
+
+func (h *jobHeartbeat) Sleep(ctx context.Context, d time.Duration) bool {
+	h.Beat()
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	// Without a deadline there is nothing to keep up with while idle
+	var beats <-chan time.Time
+	if h.interval > 0 {
+		ticker := time.NewTicker(h.interval)
+		defer ticker.Stop()
+		beats = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-timer.C:
+			h.Beat()
+			return true
+		case <-beats:
+			h.Beat()
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package usecase
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+
+	"etlgo/internal/domain"
+	"etlgo/pkg/logger"
+	"etlgo/pkg/metrics"
+)
+
+// WebhookService receives records pushed by upstream systems to the webhook
+// of their source. Bodies are signed with the source's secret together with
+// the time they were sent at and applied like pushed batches: transformed,
+// stored and added to the metrics they touch right away.
+type WebhookService struct {
+	etlService *ETLService
+	secrets    map[string]string // by source
+	tolerance  time.Duration
+	logger     *logger.Logger
+	metrics    *metrics.Metrics
+}
+
+// NewWebhookService creates a new webhook service. A source without a
+// secret has no webhook. Deliveries sent more than tolerance before or
+// after the service's time are rejected, so captured ones can't be
+// replayed later.
+func NewWebhookService(
+	etlService *ETLService,
+	adsSecret string,
+	crmSecret string,
+	tolerance time.Duration,
+	logger *logger.Logger,
+	metrics *metrics.Metrics,
+) *WebhookService {
+	secrets := make(map[string]string, 2)
+	if adsSecret != "" {
+		secrets[domain.SourceAds] = adsSecret
+	}
+	if crmSecret != "" {
+		secrets[domain.SourceCRM] = crmSecret
+	}
+	return &WebhookService{
+		etlService: etlService,
+		secrets:    secrets,
+		tolerance:  tolerance,
+		logger:     logger,
+		metrics:    metrics,
+	}
+}
+
+// Enabled reports whether the source has a webhook
+func (s *WebhookService) Enabled(source string) bool {
+	return s.secrets[source] != ""
+}
+
+// Tolerance returns how far from now the signed timestamp of a delivery may
+// be
+func (s *WebhookService) Tolerance() time.Duration {
+	return s.tolerance
+}
+
+// Receive applies the records in payload after checking its signature, the
+// hex HMAC-SHA256 with the source's secret of the timestamp, a dot and the
+// payload, optionally prefixed sha256=, and that the timestamp, in Unix
+// seconds, is within the tolerance of now. Records delivered again
+// unchanged are not counted twice, so upstreams may retry their deliveries.
+func (s *WebhookService) Receive(ctx context.Context, source string, payload []byte, timestamp, signature string) (*domain.PushSummary, error) {
+	secret, ok := s.secrets[source]
+	if !ok {
+		return nil, domain.ErrWebhookNotConfigured
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(payload)
+	expected := hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(expected), []byte(strings.TrimPrefix(signature, "sha256="))) {
+		s.metrics.RecordBusinessMetric("webhook_invalid_signature")
+		s.logger.WithContext(ctx).WithField("source", source).Warn("Webhook signature rejected")
+		return nil, domain.ErrInvalidWebhookSignature
+	}
+
+	// Signed timestamps are measured on the system clock, like the
+	// upstream's
+	sentAt, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || time.Since(time.Unix(sentAt, 0)).Abs() > s.tolerance {
+		s.metrics.RecordBusinessMetric("webhook_stale")
+		s.logger.WithContext(ctx).WithFields(map[string]any{
+			"source":    source,
+			"timestamp": timestamp,
+		}).Warn("Webhook outside its delivery window rejected")
+		return nil, domain.ErrStaleWebhook
+	}
+
+	batch, err := domain.DecodeWebhookBatch(source, payload)
+	if err != nil {
+		return nil, err
+	}
+	summary, err := s.etlService.IngestPush(ctx, batch)
+	if err != nil {
+		return summary, err
+	}
+
+	s.metrics.RecordBusinessMetric("webhook_" + source)
+	return summary, nil
+}
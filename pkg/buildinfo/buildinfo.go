@@ -0,0 +1,62 @@
+package buildinfo
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// Set at compile time, e.g.
+//
+//	go build -ldflags "-X etlgo/pkg/buildinfo.Version=1.2.0 \
+//	  -X etlgo/pkg/buildinfo.Commit=$(git rev-parse HEAD) \
+//	  -X etlgo/pkg/buildinfo.BuildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+var (
+	Version   = "1.0.0"
+	Commit    = ""
+	BuildDate = ""
+)
+
+// Info describes the running build
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"build_date"`
+	GoVersion string `json:"go_version"`
+	Modified  bool   `json:"modified,omitempty"` // built from a checkout with uncommitted changes
+}
+
+// Get returns the build information. Values not injected through ldflags
+// fall back to the VCS stamp Go embeds when building from a checkout, and
+// finally to "unknown".
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		Commit:    Commit,
+		BuildDate: BuildDate,
+		GoVersion: runtime.Version(),
+	}
+
+	// the VCS stamp only describes the build when ldflags did not override it
+	if bi, ok := debug.ReadBuildInfo(); ok && Commit == "" {
+		for _, setting := range bi.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				info.Commit = setting.Value
+			case "vcs.time":
+				if info.BuildDate == "" {
+					info.BuildDate = setting.Value
+				}
+			case "vcs.modified":
+				info.Modified = setting.Value == "true"
+			}
+		}
+	}
+
+	if info.Commit == "" {
+		info.Commit = "unknown"
+	}
+	if info.BuildDate == "" {
+		info.BuildDate = "unknown"
+	}
+	return info
+}
@@ -1,22 +1,55 @@
 package config
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
 // Application settings
 type Config struct {
-	Server   ServerConfig
-	Logging  LoggingConfig
-	ETL      ETLConfig
-	External ExternalConfig
+	Server    ServerConfig
+	Logging   LoggingConfig
+	ETL       ETLConfig
+	External  ExternalConfig
+	Export    ExportConfig
+	Jobs      JobsConfig
+	Flags     FlagsConfig
+	Storage   StorageConfig
+	Schedule  ScheduleConfig
+	Quota     QuotaConfig
+	Notify    NotifyConfig
+	Actions   ActionsConfig
+	Reporting ReportingConfig
+	Faults    FaultConfig
+	Admin     AdminConfig
+	Telemetry TelemetryConfig
+
+	Certification CertificationConfig
 }
 
 // Server settings
 type ServerConfig struct {
-	Port string
+	Port        string
+	Environment string
+	// pins the service clock to an RFC 3339 time, for tests
+	ClockFrozenAt string
+	// shifts the service clock, e.g. -720h to backfill as of a month ago
+	ClockOffset time.Duration
+	// format of run and ingest job IDs, uuid or the time-sortable ulid
+	IDFormat string
+
+	// load shedding of expensive requests; zero thresholds disable it
+	LoadShedMaxInFlight int
+	LoadShedMaxP99      time.Duration
+	LoadShedWindow      time.Duration
+	LoadShedRetryAfter  time.Duration
+	// date ranges longer than this make unaggregated queries expensive
+	LoadShedLongRange time.Duration
 }
 
 type ETLConfig struct {
@@ -26,6 +59,42 @@ type ETLConfig struct {
 	MaxRetries         int
 	RetryBackoff       time.Duration
 	RateLimitPerSecond int
+	RateLimitBurst     int
+	// per API overrides of the rate limit and burst, as api=limit lists
+	UpstreamRateLimits string
+	UpstreamRateBursts string
+
+	ParseMode            string
+	ParseMaxErrors       int
+	ParseMaxErrorPercent float64
+
+	QuarantineMaxRecords int
+	ValuePolicyFile      string
+	PushMaxRecords       int
+	// what to do with records dated more than DateMaxFuture ahead of the
+	// clock or more than DateMaxAge behind it: accept, clamp or quarantine
+	DateSkewPolicy string
+	DateMaxFuture  time.Duration
+	DateMaxAge     time.Duration
+
+	AttributionMode     string
+	AttributionHalfLife time.Duration
+	RevenueRecognition  string
+	FuzzyUTMThreshold   float64
+	CRMOnlyMetrics      bool
+	FunnelStages        string
+	StageProbabilities  string
+
+	EventLogRetention       time.Duration
+	EventLogCompactInterval time.Duration
+
+	FingerprintAlgorithm string
+
+	// how runs handle days without upstream data: ignore, mark or zero_fill
+	GapPolicy string
+
+	// file the extraction checkpoints are kept in; empty keeps them in memory
+	CheckpointFile string
 }
 
 type ExternalConfig struct {
@@ -33,6 +102,270 @@ type ExternalConfig struct {
 	CRMAPIURL  string
 	SinkURL    string
 	SinkSecret string
+
+	// optional keyword level ads feed
+	KeywordsAPIURL string
+
+	// query parameter the ads, CRM and keyword APIs take the first day to
+	// return in
+	SinceParam string
+
+	FieldMappingFile string
+
+	// where ads and CRM are extracted from: the APIs, CSV files at the
+	// paths or files under the prefixes of the object store. Each source
+	// defaults to Extractor.
+	Extractor    string
+	AdsExtractor string
+	CRMExtractor string
+	AdsCSVPath   string
+	CRMCSVPath   string
+	// JSON array of further sources extracted alongside ads and CRM
+	ConnectorsFile string
+	// largest multipart upload of ads and CRM files accepted, and largest
+	// webhook body
+	UploadMaxBytes int
+	// secrets the webhook bodies of each source are signed with; a source
+	// without one has no webhook
+	WebhookAdsSecret string
+	WebhookCRMSecret string
+	// how far from now the signed timestamp of a webhook delivery may be
+	WebhookTolerance time.Duration
+
+	// object store the object extractor lists source files from: s3, or
+	// gcs through its S3 compatible API with HMAC keys
+	ObjectStoreProvider  string
+	ObjectStoreBucket    string
+	ObjectStoreRegion    string
+	ObjectStoreEndpoint  string
+	ObjectStoreAccessKey string
+	ObjectStoreSecretKey string
+	AdsObjectPrefix      string
+	CRMObjectPrefix      string
+	// JSON file recording the ingested objects across restarts
+	IngestedObjectsFile string
+
+	// Kafka REST proxy the stream consumer reads the ads and CRM topics
+	// through; empty disables streaming ingestion
+	KafkaRESTURL       string
+	KafkaGroup         string
+	KafkaAdsTopic      string
+	KafkaCRMTopic      string
+	KafkaUsername      string
+	KafkaPassword      string
+	KafkaPollTimeout   time.Duration
+	KafkaMaxBytes      int
+	StreamBatchSize    int
+	StreamBatchWait    time.Duration
+	StreamRetryBackoff time.Duration
+
+	CassetteMode string
+	CassetteDir  string
+
+	GA4PropertyID       string
+	GA4CredentialsFile  string
+	GA4APIURL           string
+	GA4ConversionMetric string
+
+	// Meta (Facebook) Marketing API; an ad account registers the meta ads
+	// connector
+	MetaAdAccountID     string
+	MetaAccessToken     string
+	MetaAppID           string
+	MetaAppSecret       string
+	MetaAPIURL          string
+	MetaTokenFile       string
+	MetaThrottlePercent float64
+	MetaMaxBackoff      time.Duration
+
+	// Salesforce REST API; a connected app client id registers the
+	// salesforce CRM connector
+	SalesforceClientID       string
+	SalesforceUsername       string
+	SalesforcePrivateKeyFile string
+	SalesforceLoginURL       string
+	SalesforceAPIVersion     string
+	SalesforceQuery          string
+	SalesforceFields         string
+	SalesforceStageMap       string
+
+	// upstream connections
+	HTTP2               bool
+	KeepAlive           time.Duration
+	IdleConnTimeout     time.Duration
+	MaxIdleConnsPerHost int
+	Prewarm             bool
+	HedgeDelay          time.Duration
+}
+
+// Export settings
+type ExportConfig struct {
+	RawExportDir        string
+	SinkCompression     string
+	SinkChunkSize       int
+	SinkUnorderedChunks bool
+
+	// JSON array of named destinations with their output transforms
+	DestinationsFile string
+
+	// how exports are sent, full or diff, unless their destination says
+	Mode string
+	// file the snapshots of the last exports are kept in for diff exports;
+	// empty keeps them in memory
+	SnapshotFile string
+
+	S3Bucket    string
+	S3Region    string
+	S3Endpoint  string
+	S3Prefix    string
+	S3AccessKey string
+	S3SecretKey string
+
+	EncryptionKey   string
+	EncryptionKeyID string
+
+	HoldSpendFactor  float64
+	HoldBaselineDays int
+
+	// how long exports await their sink's signed ack, 0 disables tracking
+	AckTimeout time.Duration
+	AckSecret  string
+}
+
+// Job queue settings
+type JobsConfig struct {
+	IngestConcurrency     int
+	ExportConcurrency     int
+	MaxConcurrency        int
+	BackfillDays          int
+	MaintenanceRetryAfter time.Duration
+
+	// actions that need a second operator's approval
+	ApprovalRequiredActions []string
+
+	// how long a background job may go without a heartbeat before it is
+	// restarted, 0 disables restarts
+	WatchdogDeadline time.Duration
+}
+
+// Storage backend settings
+type StorageConfig struct {
+	Backend       string
+	DSN           string
+	ReadDSNs      []string
+	MaxReplicaLag time.Duration
+	AutoMigrate   bool
+
+	// bulkhead limits of concurrent repository operations
+	MaxConcurrentReads  int
+	ReadQueue           int
+	ReadQueueTimeout    time.Duration
+	MaxConcurrentWrites int
+
+	// connection pool limits of SQL backends, per database
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+}
+
+// Pipeline scheduler settings
+type ScheduleConfig struct {
+	Enabled bool
+	// cron expression running the full ETL, which starts the scheduler
+	ETLSchedule      string
+	Timezone         string
+	PipelinesFile    string
+	HolidayCalendars string
+	HistoryFile      string
+	CatchUpLookback  time.Duration
+	LateDataDays     int
+}
+
+// Usage quota settings, as subject=limit lists
+type QuotaConfig struct {
+	UpstreamCallsPerDay string
+	RecordsPerMonth     string
+	QueryRowBudgets     string
+	// JSON file quota usage is kept in across restarts, empty for memory only
+	UsageFile string
+	// what happens to metrics queries over budget: reject or downgrade
+	QueryBudgetMode string
+}
+
+// Run certification settings
+type CertificationConfig struct {
+	// base64 Ed25519 seed certifications are signed with; unsigned when empty
+	SigningKey string
+	KeyID      string
+}
+
+// Fault injection settings for resilience testing, refused in production
+type FaultConfig struct {
+	Enabled      bool
+	FailPercent  float64
+	DelayPercent float64
+	MaxDelay     time.Duration
+	// upstream and/or storage, both when empty
+	Targets []string
+}
+
+// Reporting endpoint settings
+type ReportingConfig struct {
+	// name=key pairs of the API keys BI connectors authenticate with
+	APIKeys string
+	// JSON array of API keys with the metrics scopes they are restricted to
+	APIKeysFile string
+	// JSON array of the roles of API keys with the metric columns they hide
+	RolesFile string
+	// secret share tokens are signed with, and how long they may last
+	ShareTokenSecret string
+	ShareTokenMaxTTL time.Duration
+	// JSON file revoked share tokens are kept in, empty for memory only
+	ShareTokenRevocationsFile string
+	// the currency stored amounts are in
+	BaseCurrency string
+	// JSON array of daily FX rates from the base currency loaded at startup
+	FXRatesFile string
+	// JSON array of derived models materialized after every run
+	ModelsFile string
+	// name=key pairs of the pipelines writing metrics through the batch
+	// API, the name tagging the rows they write
+	ProducerKeys string
+}
+
+// Admin endpoint settings
+type AdminConfig struct {
+	// name=key pairs of the API keys operators change runtime settings with
+	APIKeys string
+}
+
+// Suggested action settings
+type ActionsConfig struct {
+	CPACap        float64
+	DailySpendCap float64
+	CapDays       int
+}
+
+// Run notification settings
+type NotifyConfig struct {
+	ChannelsFile string
+	BaseURL      string
+
+	// link from ingest jobs to their logs, with {job_id}, {run_id} and
+	// {request_id} placeholders
+	LogsURL string
+
+	SMTPAddr     string
+	SMTPFrom     string
+	SMTPUsername string
+	SMTPPassword string
+}
+
+// Feature flag settings
+type FlagsConfig struct {
+	File           string
+	Overrides      string
+	ReloadInterval time.Duration
 }
 
 // Logging settings
@@ -40,33 +373,332 @@ type LoggingConfig struct {
 	Level string
 }
 
+// Prometheus label settings
+type TelemetryConfig struct {
+	// tenants labeled individually at most, beyond them "other"
+	TenantLabelLimit int
+	// tenants that always get their own label
+	TopTenants []string
+}
+
 func Load() (*Config, error) {
 	config := &Config{
 		Server: ServerConfig{
-			Port: getEnv("PORT", "8080"),
+			Port:        getEnv("PORT", "8080"),
+			Environment: getEnv("ENVIRONMENT", "development"),
+
+			ClockFrozenAt: getEnv("CLOCK_FROZEN_AT", ""),
+			ClockOffset:   getDurationEnv("CLOCK_OFFSET", "0"),
+			IDFormat:      getEnv("ID_FORMAT", "uuid"),
+
+			LoadShedMaxInFlight: getIntEnv("LOAD_SHED_MAX_IN_FLIGHT", 0),
+			LoadShedMaxP99:      getDurationEnv("LOAD_SHED_MAX_P99", "0"),
+			LoadShedWindow:      getDurationEnv("LOAD_SHED_WINDOW", "30s"),
+			LoadShedRetryAfter:  getDurationEnv("LOAD_SHED_RETRY_AFTER", "5s"),
+			LoadShedLongRange:   getDurationEnv("LOAD_SHED_LONG_RANGE", "2160h"),
 		},
 		ETL: ETLConfig{
-			WorkerPoolSize:     getIntEnv("WORKER_POOL_SIZE", 10),
+			WorkerPoolSize:     getIntEnv("WORKER_POOL_SIZE", 0),
 			BatchSize:          getIntEnv("BATCH_SIZE", 100),
 			RequestTimeout:     getDurationEnv("REQUEST_TIMEOUT", "30s"),
 			MaxRetries:         getIntEnv("MAX_RETRIES", 3),
 			RetryBackoff:       getDurationEnv("RETRY_BACKOFF", "2s"),
 			RateLimitPerSecond: getIntEnv("RATE_LIMIT_PER_SECOND", 100),
+			RateLimitBurst:     getIntEnv("RATE_LIMIT_BURST", 10),
+			UpstreamRateLimits: getEnv("UPSTREAM_RATE_LIMITS", ""),
+			UpstreamRateBursts: getEnv("UPSTREAM_RATE_BURSTS", ""),
+
+			ParseMode:            getEnv("PARSE_MODE", "lenient"),
+			ParseMaxErrors:       getIntEnv("PARSE_MAX_ERRORS", 1),
+			ParseMaxErrorPercent: getFloatEnv("PARSE_MAX_ERROR_PERCENT", 5),
+			QuarantineMaxRecords: getIntEnv("QUARANTINE_MAX_RECORDS", 10000),
+			ValuePolicyFile:      getEnv("VALUE_POLICY_FILE", ""),
+			PushMaxRecords:       getIntEnv("PUSH_MAX_RECORDS", 1000),
+			DateSkewPolicy:       getEnv("DATE_SKEW_POLICY", "accept"),
+			DateMaxFuture:        getDurationEnv("DATE_MAX_FUTURE", "24h"),
+			DateMaxAge:           getDurationEnv("DATE_MAX_AGE", "87600h"),
+
+			AttributionMode:     getEnv("ATTRIBUTION_MODE", "single_key"),
+			AttributionHalfLife: getDurationEnv("ATTRIBUTION_HALF_LIFE", "168h"),
+			RevenueRecognition:  getEnv("REVENUE_RECOGNITION", "created"),
+			FuzzyUTMThreshold:   getFloatEnv("UTM_FUZZY_MATCH_THRESHOLD", 0),
+			CRMOnlyMetrics:      getBoolEnv("METRICS_INCLUDE_CRM_ONLY", false),
+			FunnelStages:        getEnv("FUNNEL_STAGES", ""),
+			StageProbabilities:  getEnv("PIPELINE_STAGE_PROBABILITIES", ""),
+
+			EventLogRetention:       getDurationEnv("EVENT_LOG_RETENTION", "720h"),
+			EventLogCompactInterval: getDurationEnv("EVENT_LOG_COMPACT_INTERVAL", "1h"),
+
+			FingerprintAlgorithm: getEnv("FINGERPRINT_ALGORITHM", "sha256"),
+
+			GapPolicy: getEnv("GAP_POLICY", "ignore"),
+
+			CheckpointFile: getEnv("CHECKPOINT_FILE", ""),
 		},
 		External: ExternalConfig{
 			AdsAPIURL:  getEnv("ADS_API_URL", ""),
 			CRMAPIURL:  getEnv("CRM_API_URL", ""),
 			SinkURL:    getEnv("SINK_URL", ""),
 			SinkSecret: getEnv("SINK_SECRET", ""),
+
+			KeywordsAPIURL: getEnv("KEYWORDS_API_URL", ""),
+			SinceParam:     getEnv("UPSTREAM_SINCE_PARAM", ""),
+
+			FieldMappingFile: getEnv("FIELD_MAPPING_FILE", ""),
+
+			Extractor:      getEnv("EXTRACTOR", "api"),
+			AdsExtractor:   getEnv("ADS_EXTRACTOR", getEnv("EXTRACTOR", "api")),
+			CRMExtractor:   getEnv("CRM_EXTRACTOR", getEnv("EXTRACTOR", "api")),
+			AdsCSVPath:     getEnv("ADS_CSV_PATH", ""),
+			CRMCSVPath:     getEnv("CRM_CSV_PATH", ""),
+			ConnectorsFile: getEnv("CONNECTORS_FILE", ""),
+			UploadMaxBytes: getIntEnv("UPLOAD_MAX_BYTES", 32<<20),
+
+			WebhookAdsSecret: getEnv("WEBHOOK_ADS_SECRET", ""),
+			WebhookCRMSecret: getEnv("WEBHOOK_CRM_SECRET", ""),
+			WebhookTolerance: getDurationEnv("WEBHOOK_TOLERANCE", "5m"),
+
+			ObjectStoreProvider:  getEnv("OBJECT_STORE_PROVIDER", "s3"),
+			ObjectStoreBucket:    getEnv("OBJECT_STORE_BUCKET", ""),
+			ObjectStoreRegion:    getEnv("OBJECT_STORE_REGION", ""),
+			ObjectStoreEndpoint:  getEnv("OBJECT_STORE_ENDPOINT", ""),
+			ObjectStoreAccessKey: getEnv("OBJECT_STORE_ACCESS_KEY", getEnv("AWS_ACCESS_KEY_ID", "")),
+			ObjectStoreSecretKey: getEnv("OBJECT_STORE_SECRET_KEY", getEnv("AWS_SECRET_ACCESS_KEY", "")),
+			AdsObjectPrefix:      getEnv("ADS_OBJECT_PREFIX", ""),
+			CRMObjectPrefix:      getEnv("CRM_OBJECT_PREFIX", ""),
+			IngestedObjectsFile:  getEnv("INGESTED_OBJECTS_FILE", ""),
+
+			KafkaRESTURL:       getEnv("KAFKA_REST_URL", ""),
+			KafkaGroup:         getEnv("KAFKA_GROUP", "etlgo"),
+			KafkaAdsTopic:      getEnv("KAFKA_ADS_TOPIC", ""),
+			KafkaCRMTopic:      getEnv("KAFKA_CRM_TOPIC", ""),
+			KafkaUsername:      getEnv("KAFKA_USERNAME", ""),
+			KafkaPassword:      getEnv("KAFKA_PASSWORD", ""),
+			KafkaPollTimeout:   getDurationEnv("KAFKA_POLL_TIMEOUT", "1s"),
+			KafkaMaxBytes:      getIntEnv("KAFKA_MAX_BYTES", 1<<20),
+			StreamBatchSize:    getIntEnv("STREAM_BATCH_SIZE", 500),
+			StreamBatchWait:    getDurationEnv("STREAM_BATCH_WAIT", "5s"),
+			StreamRetryBackoff: getDurationEnv("STREAM_RETRY_BACKOFF", "10s"),
+
+			CassetteMode: getEnv("UPSTREAM_CASSETTE_MODE", "off"),
+			CassetteDir:  getEnv("UPSTREAM_CASSETTE_DIR", "cassettes"),
+
+			GA4PropertyID:       getEnv("GA4_PROPERTY_ID", ""),
+			GA4CredentialsFile:  getEnv("GA4_CREDENTIALS_FILE", ""),
+			GA4APIURL:           getEnv("GA4_API_URL", "https://analyticsdata.googleapis.com/v1beta"),
+			GA4ConversionMetric: getEnv("GA4_CONVERSION_METRIC", "keyEvents"),
+
+			MetaAdAccountID:     getEnv("META_AD_ACCOUNT_ID", ""),
+			MetaAccessToken:     getEnv("META_ACCESS_TOKEN", ""),
+			MetaAppID:           getEnv("META_APP_ID", ""),
+			MetaAppSecret:       getEnv("META_APP_SECRET", ""),
+			MetaAPIURL:          getEnv("META_API_URL", "https://graph.facebook.com/v21.0"),
+			MetaTokenFile:       getEnv("META_TOKEN_FILE", ""),
+			MetaThrottlePercent: getFloatEnv("META_THROTTLE_PERCENT", 75),
+			MetaMaxBackoff:      getDurationEnv("META_MAX_BACKOFF", "5m"),
+
+			SalesforceClientID:       getEnv("SALESFORCE_CLIENT_ID", ""),
+			SalesforceUsername:       getEnv("SALESFORCE_USERNAME", ""),
+			SalesforcePrivateKeyFile: getEnv("SALESFORCE_PRIVATE_KEY_FILE", ""),
+			SalesforceLoginURL:       getEnv("SALESFORCE_LOGIN_URL", "https://login.salesforce.com"),
+			SalesforceAPIVersion:     getEnv("SALESFORCE_API_VERSION", "v61.0"),
+			SalesforceQuery:          getEnv("SALESFORCE_SOQL", "SELECT Id, StageName, Amount, Probability, CreatedDate, CloseDate, IsClosed, IsWon FROM Opportunity"),
+			SalesforceFields:         getEnv("SALESFORCE_FIELDS", ""),
+			SalesforceStageMap: getEnv("SALESFORCE_STAGE_MAP", "Prospecting=lead,Qualification=lead,Needs Analysis=opportunity,Value Proposition=opportunity,"+
+				"Id. Decision Makers=opportunity,Perception Analysis=opportunity,Proposal/Price Quote=opportunity,Negotiation/Review=opportunity,"+
+				"Closed Won=closed_won,Closed Lost=closed_lost"),
+
+			HTTP2:               getBoolEnv("UPSTREAM_HTTP2", true),
+			KeepAlive:           getDurationEnv("UPSTREAM_KEEPALIVE", "30s"),
+			IdleConnTimeout:     getDurationEnv("UPSTREAM_IDLE_CONN_TIMEOUT", "90s"),
+			MaxIdleConnsPerHost: getIntEnv("UPSTREAM_MAX_IDLE_CONNS_PER_HOST", 10),
+			Prewarm:             getBoolEnv("UPSTREAM_PREWARM", true),
+			HedgeDelay:          getDurationEnv("UPSTREAM_HEDGE_DELAY", "0s"),
+		},
+		Export: ExportConfig{
+			RawExportDir:        getEnv("RAW_EXPORT_DIR", "exports"),
+			SinkCompression:     getEnv("SINK_COMPRESSION", "none"),
+			SinkChunkSize:       getIntEnv("SINK_CHUNK_SIZE", 0),
+			SinkUnorderedChunks: getBoolEnv("SINK_UNORDERED_CHUNKS", false),
+			DestinationsFile:    getEnv("EXPORT_DESTINATIONS_FILE", ""),
+			Mode:                getEnv("EXPORT_MODE", "full"),
+			SnapshotFile:        getEnv("EXPORT_SNAPSHOT_FILE", ""),
+			S3Bucket:            getEnv("EXPORT_S3_BUCKET", ""),
+			S3Region:            getEnv("EXPORT_S3_REGION", "us-east-1"),
+			S3Endpoint:          getEnv("EXPORT_S3_ENDPOINT", ""),
+			S3Prefix:            getEnv("EXPORT_S3_PREFIX", ""),
+			S3AccessKey:         getEnv("AWS_ACCESS_KEY_ID", ""),
+			S3SecretKey:         getEnv("AWS_SECRET_ACCESS_KEY", ""),
+			EncryptionKey:       getEnv("EXPORT_ENCRYPTION_KEY", ""),
+			EncryptionKeyID:     getEnv("EXPORT_ENCRYPTION_KEY_ID", ""),
+
+			HoldSpendFactor:  getFloatEnv("EXPORT_HOLD_SPEND_FACTOR", 0),
+			HoldBaselineDays: getIntEnv("EXPORT_HOLD_BASELINE_DAYS", 28),
+
+			AckTimeout: getDurationEnv("EXPORT_ACK_TIMEOUT", "0s"),
+			AckSecret:  getEnv("EXPORT_ACK_SECRET", getEnv("SINK_SECRET", "")),
+		},
+		Jobs: JobsConfig{
+			IngestConcurrency: getIntEnv("JOB_CONCURRENCY_INGEST", 1),
+			ExportConcurrency: getIntEnv("JOB_CONCURRENCY_EXPORT", 2),
+			MaxConcurrency:    getIntEnv("JOB_MAX_CONCURRENCY", 2),
+			BackfillDays:      getIntEnv("JOB_BACKFILL_DAYS", 30),
+
+			MaintenanceRetryAfter: getDurationEnv("MAINTENANCE_RETRY_AFTER", "5m"),
+
+			ApprovalRequiredActions: getListEnv("APPROVAL_REQUIRED_ACTIONS"),
+
+			WatchdogDeadline: getDurationEnv("WATCHDOG_DEADLINE", "5m"),
+		},
+		Storage: StorageConfig{
+			Backend: getEnv("STORAGE_BACKEND", "memory"),
+			DSN:     getEnv("STORAGE_DSN", ""),
+
+			ReadDSNs:      getListEnv("STORAGE_READ_DSNS"),
+			MaxReplicaLag: getDurationEnv("STORAGE_MAX_REPLICA_LAG", "30s"),
+
+			AutoMigrate: getBoolEnv("STORAGE_AUTO_MIGRATE", true),
+
+			MaxConcurrentReads:  getIntEnv("STORAGE_MAX_CONCURRENT_READS", 16),
+			ReadQueue:           getIntEnv("STORAGE_READ_QUEUE", 16),
+			ReadQueueTimeout:    getDurationEnv("STORAGE_READ_QUEUE_TIMEOUT", "2s"),
+			MaxConcurrentWrites: getIntEnv("STORAGE_MAX_CONCURRENT_WRITES", 4),
+
+			MaxOpenConns:    getIntEnv("STORAGE_MAX_OPEN_CONNS", 20),
+			MaxIdleConns:    getIntEnv("STORAGE_MAX_IDLE_CONNS", 5),
+			ConnMaxLifetime: getDurationEnv("STORAGE_CONN_MAX_LIFETIME", "30m"),
+		},
+		Schedule: ScheduleConfig{
+			Enabled:          getBoolEnv("SCHEDULER_ENABLED", false),
+			ETLSchedule:      getEnv("ETL_SCHEDULE", ""),
+			Timezone:         getEnv("SCHEDULER_TIMEZONE", "UTC"),
+			PipelinesFile:    getEnv("PIPELINES_FILE", ""),
+			HolidayCalendars: getEnv("HOLIDAY_CALENDAR_FILE", ""),
+			HistoryFile:      getEnv("SCHEDULE_HISTORY_FILE", ""),
+			CatchUpLookback:  getDurationEnv("SCHEDULER_CATCHUP_LOOKBACK", "24h"),
+			LateDataDays:     getIntEnv("SCHEDULER_LATE_DATA_DAYS", 7),
+		},
+		Quota: QuotaConfig{
+			UpstreamCallsPerDay: getEnv("QUOTA_UPSTREAM_CALLS_PER_DAY", ""),
+			RecordsPerMonth:     getEnv("QUOTA_RECORDS_PER_MONTH", ""),
+			QueryRowBudgets:     getEnv("QUERY_ROW_BUDGETS", ""),
+			UsageFile:           getEnv("QUOTA_USAGE_FILE", ""),
+			QueryBudgetMode:     getEnv("QUERY_BUDGET_MODE", "reject"),
+		},
+		Notify: NotifyConfig{
+			ChannelsFile: getEnv("NOTIFICATION_CHANNELS_FILE", ""),
+			BaseURL:      getEnv("PUBLIC_BASE_URL", ""),
+
+			LogsURL: getEnv("LOGS_URL_TEMPLATE", ""),
+
+			SMTPAddr:     getEnv("SMTP_ADDR", ""),
+			SMTPFrom:     getEnv("SMTP_FROM", ""),
+			SMTPUsername: getEnv("SMTP_USERNAME", ""),
+			SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+		},
+		Actions: ActionsConfig{
+			CPACap:        getFloatEnv("ACTION_CPA_CAP", 0),
+			DailySpendCap: getFloatEnv("ACTION_DAILY_SPEND_CAP", 0),
+			CapDays:       getIntEnv("ACTION_CAP_DAYS", 3),
+		},
+		Reporting: ReportingConfig{
+			APIKeys:                   getEnv("REPORTING_API_KEYS", ""),
+			APIKeysFile:               getEnv("API_KEYS_FILE", ""),
+			RolesFile:                 getEnv("ROLES_FILE", ""),
+			ShareTokenSecret:          getEnv("SHARE_TOKEN_SECRET", ""),
+			ShareTokenMaxTTL:          getDurationEnv("SHARE_TOKEN_MAX_TTL", "720h"),
+			ShareTokenRevocationsFile: getEnv("SHARE_TOKEN_REVOCATIONS_FILE", ""),
+			BaseCurrency:              getEnv("BASE_CURRENCY", "USD"),
+			FXRatesFile:               getEnv("FX_RATES_FILE", ""),
+			ModelsFile:                getEnv("DERIVED_MODELS_FILE", ""),
+			ProducerKeys:              getEnv("METRICS_PRODUCER_KEYS", ""),
+		},
+		Faults: FaultConfig{
+			Enabled:      getBoolEnv("FAULT_INJECTION_ENABLED", false),
+			FailPercent:  getFloatEnv("FAULT_FAIL_PERCENT", 0),
+			DelayPercent: getFloatEnv("FAULT_DELAY_PERCENT", 0),
+			MaxDelay:     getDurationEnv("FAULT_MAX_DELAY", "2s"),
+			Targets:      getListEnv("FAULT_TARGETS"),
+		},
+		Admin: AdminConfig{
+			APIKeys: getEnv("ADMIN_API_KEYS", ""),
+		},
+		Certification: CertificationConfig{
+			SigningKey: getEnv("CERTIFICATION_SIGNING_KEY", ""),
+			KeyID:      getEnv("CERTIFICATION_KEY_ID", ""),
+		},
+		Flags: FlagsConfig{
+			File:           getEnv("FEATURE_FLAGS_FILE", ""),
+			Overrides:      getEnv("FEATURE_FLAGS", ""),
+			ReloadInterval: getDurationEnv("FEATURE_FLAGS_RELOAD_INTERVAL", "0s"),
 		},
 		Logging: LoggingConfig{
 			Level: getEnv("LOG_LEVEL", "info"),
 		},
+		Telemetry: TelemetryConfig{
+			TenantLabelLimit: getIntEnv("PROMETHEUS_TENANT_LABEL_LIMIT", 20),
+			TopTenants:       getListEnv("PROMETHEUS_TOP_TENANTS"),
+		},
 	}
 
 	return config, nil
 }
 
+// returns a SHA-256 of the settings, leaving out secrets, so runs can
+// record which configuration they ran with
+func (c Config) Hash() string {
+	encoded, _ := json.Marshal(c.withSecrets(""))
+	sum := sha256.Sum256(encoded)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// returns the settings with every configured secret masked, for showing
+// the effective configuration
+func (c Config) Redacted() Config {
+	return c.withSecrets("[redacted]")
+}
+
+// replaces the configured secrets with mask, clearing them all when mask
+// is empty
+func (c Config) withSecrets(mask string) Config {
+	secret := func(value string) string {
+		if value == "" {
+			return ""
+		}
+		return mask
+	}
+	c.External.SinkSecret = secret(c.External.SinkSecret)
+	c.External.ObjectStoreAccessKey = secret(c.External.ObjectStoreAccessKey)
+	c.External.ObjectStoreSecretKey = secret(c.External.ObjectStoreSecretKey)
+	c.External.KafkaPassword = secret(c.External.KafkaPassword)
+	c.External.MetaAccessToken = secret(c.External.MetaAccessToken)
+	c.External.MetaAppSecret = secret(c.External.MetaAppSecret)
+	c.External.WebhookAdsSecret = secret(c.External.WebhookAdsSecret)
+	c.External.WebhookCRMSecret = secret(c.External.WebhookCRMSecret)
+	c.Export.S3AccessKey = secret(c.Export.S3AccessKey)
+	c.Export.S3SecretKey = secret(c.Export.S3SecretKey)
+	c.Export.EncryptionKey = secret(c.Export.EncryptionKey)
+	c.Export.AckSecret = secret(c.Export.AckSecret)
+	c.Storage.DSN = secret(c.Storage.DSN)
+	c.Notify.SMTPPassword = secret(c.Notify.SMTPPassword)
+	c.Reporting.APIKeys = secret(c.Reporting.APIKeys)
+	c.Reporting.ShareTokenSecret = secret(c.Reporting.ShareTokenSecret)
+	c.Reporting.ProducerKeys = secret(c.Reporting.ProducerKeys)
+	c.Admin.APIKeys = secret(c.Admin.APIKeys)
+	c.Certification.SigningKey = secret(c.Certification.SigningKey)
+
+	if mask == "" {
+		c.Storage.ReadDSNs = nil
+	} else {
+		dsns := make([]string, len(c.Storage.ReadDSNs))
+		for i := range dsns {
+			dsns[i] = mask
+		}
+		c.Storage.ReadDSNs = dsns
+	}
+	return c
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -83,6 +715,35 @@ func getIntEnv(key string, defaultValue int) int {
 	return defaultValue
 }
 
+// splits a comma separated list, dropping empty entries
+func getListEnv(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func getBoolEnv(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return defaultValue
+}
+
+func getFloatEnv(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatValue
+		}
+	}
+	return defaultValue
+}
+
 func getDurationEnv(key, defaultValue string) time.Duration {
 	if value := os.Getenv(key); value != "" {
 		if duration, err := time.ParseDuration(value); err == nil {
@@ -0,0 +1,146 @@
+package cpulimit
+
+import (
+	"bufio"
+	"math"
+	"os"
+	"path"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// where the cgroup hierarchies are mounted
+const cgroupRoot = "/sys/fs/cgroup"
+
+// how GOMAXPROCS was chosen
+const (
+	SourceEnv    = "env"    // the GOMAXPROCS environment variable
+	SourceCgroup = "cgroup" // the container CPU limit
+	SourceCPUs   = "cpus"   // the machine's logical CPUs
+)
+
+// Limit describes the CPUs the process can use
+type Limit struct {
+	NumCPU     int     `json:"num_cpu"`   // logical CPUs of the machine
+	Quota      float64 `json:"cpu_quota"` // cgroup CPU limit in CPUs, 0 when unlimited
+	GOMAXPROCS int     `json:"gomaxprocs"`
+	Source     string  `json:"gomaxprocs_source"`
+}
+
+// Detect returns the CPUs available to the process. The runtime already
+// lowers GOMAXPROCS to a container's CPU limit, rounded up, unless the
+// GOMAXPROCS environment variable overrides it; the limit is read here to
+// report where the effective value comes from and to size worker pools.
+func Detect() Limit {
+	limit := Limit{
+		NumCPU:     runtime.NumCPU(),
+		Quota:      cgroupQuota(),
+		GOMAXPROCS: runtime.GOMAXPROCS(0),
+		Source:     SourceCPUs,
+	}
+
+	switch {
+	case os.Getenv("GOMAXPROCS") != "":
+		limit.Source = SourceEnv
+	case limit.Quota > 0 && float64(limit.NumCPU) > limit.Quota:
+		limit.Source = SourceCgroup
+	}
+	return limit
+}
+
+// returns the CPU limit of the process' cgroup in CPUs, or 0 if there is
+// none or it can't be read. With cgroup v2 the lowest limit of the cgroup
+// and its ancestors applies.
+func cgroupQuota() float64 {
+	file, err := os.Open("/proc/self/cgroup")
+	if err != nil {
+		return 0
+	}
+	defer file.Close()
+
+	var unified, v1Controllers, v1Path string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// hierarchy-ID:controller-list:cgroup-path
+		fields := strings.SplitN(scanner.Text(), ":", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		if fields[0] == "0" && fields[1] == "" {
+			unified = fields[2]
+			continue
+		}
+		for _, controller := range strings.Split(fields[1], ",") {
+			if controller == "cpu" {
+				v1Controllers, v1Path = fields[1], fields[2]
+			}
+		}
+	}
+
+	// cgroup v1 takes precedence in hybrid setups, where the cpu
+	// controller isn't available in the unified hierarchy
+	if v1Controllers != "" {
+		for _, mount := range []string{v1Controllers, "cpu"} {
+			if quota, ok := v1Quota(path.Join(cgroupRoot, mount, v1Path)); ok {
+				return quota
+			}
+			if quota, ok := v1Quota(path.Join(cgroupRoot, mount)); ok {
+				return quota
+			}
+		}
+		return 0
+	}
+	if unified == "" {
+		return 0
+	}
+
+	var lowest float64
+	for dir := path.Join(cgroupRoot, unified); ; dir = path.Dir(dir) {
+		if quota, ok := v2Quota(dir); ok && (lowest == 0 || quota < lowest) {
+			lowest = quota
+		}
+		if dir == cgroupRoot || dir == "/" {
+			return lowest
+		}
+	}
+}
+
+// reads cpu.max ("$MAX $PERIOD", MAX may be "max") of a cgroup v2 directory
+func v2Quota(dir string) (float64, bool) {
+	raw, err := os.ReadFile(path.Join(dir, "cpu.max"))
+	if err != nil {
+		return 0, false
+	}
+	fields := strings.Fields(string(raw))
+	if len(fields) != 2 || fields[0] == "max" {
+		return 0, false
+	}
+	return quotaRatio(fields[0], fields[1])
+}
+
+// reads cpu.cfs_quota_us and cpu.cfs_period_us of a cgroup v1 directory;
+// a quota of -1 means unlimited
+func v1Quota(dir string) (float64, bool) {
+	quota, err := os.ReadFile(path.Join(dir, "cpu.cfs_quota_us"))
+	if err != nil {
+		return 0, false
+	}
+	period, err := os.ReadFile(path.Join(dir, "cpu.cfs_period_us"))
+	if err != nil {
+		return 0, false
+	}
+	return quotaRatio(strings.TrimSpace(string(quota)), strings.TrimSpace(string(period)))
+}
+
+func quotaRatio(quota, period string) (float64, bool) {
+	q, err := strconv.ParseFloat(quota, 64)
+	if err != nil || q <= 0 {
+		return 0, false
+	}
+	p, err := strconv.ParseFloat(period, 64)
+	if err != nil || p <= 0 {
+		return 0, false
+	}
+	return math.Round(q/p*100) / 100, true
+}
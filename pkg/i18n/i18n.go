@@ -0,0 +1,110 @@
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// DefaultLanguage is used when the client accepts none of the supported languages
+const DefaultLanguage = "en"
+
+// an error title and message template for one error code
+type entry struct {
+	Error   string `json:"error"`
+	Message string `json:"message"`
+}
+
+//go:embed locales/*.json
+var localeFiles embed.FS
+
+// message catalogs keyed by language, then error code
+var catalogs = mustLoadCatalogs()
+
+func mustLoadCatalogs() map[string]map[string]entry {
+	files, err := localeFiles.ReadDir("locales")
+	if err != nil {
+		panic(err)
+	}
+
+	loaded := make(map[string]map[string]entry, len(files))
+	for _, file := range files {
+		data, err := localeFiles.ReadFile("locales/" + file.Name())
+		if err != nil {
+			panic(err)
+		}
+		var catalog map[string]entry
+		if err := json.Unmarshal(data, &catalog); err != nil {
+			panic(fmt.Sprintf("invalid message catalog %s: %v", file.Name(), err))
+		}
+		loaded[strings.TrimSuffix(file.Name(), path.Ext(file.Name()))] = catalog
+	}
+	return loaded
+}
+
+// returns the languages with a message catalog, sorted
+func Languages() []string {
+	languages := make([]string, 0, len(catalogs))
+	for lang := range catalogs {
+		languages = append(languages, lang)
+	}
+	slices.Sort(languages)
+	return languages
+}
+
+// picks the best supported language for an Accept-Language header such as
+// "es-MX,es;q=0.9,en;q=0.5". Region subtags fall back to their base
+// language; anything unsupported falls back to DefaultLanguage.
+func Negotiate(acceptLanguage string) string {
+	best, bestQ := DefaultLanguage, 0.0
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+
+		lang := strings.ToLower(strings.TrimSpace(tag))
+		if _, ok := catalogs[lang]; !ok {
+			lang, _, _ = strings.Cut(lang, "-")
+		}
+		if _, ok := catalogs[lang]; ok && q > bestQ {
+			best, bestQ = lang, q
+		}
+	}
+	return best
+}
+
+// returns the localized error title for a code
+func Error(lang, code string) string {
+	return lookup(lang, code).Error
+}
+
+// returns the localized message for a code, formatting args into its template
+func Message(lang, code string, args ...any) string {
+	template := lookup(lang, code).Message
+	if len(args) == 0 {
+		return template
+	}
+	return fmt.Sprintf(template, args...)
+}
+
+// finds the entry in the language's catalog, falling back to the default
+// language and finally to the code itself
+func lookup(lang, code string) entry {
+	if e, ok := catalogs[lang][code]; ok {
+		return e
+	}
+	if e, ok := catalogs[DefaultLanguage][code]; ok {
+		return e
+	}
+	return entry{Error: code, Message: code}
+}
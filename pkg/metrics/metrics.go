@@ -1,6 +1,7 @@
 package metrics
 
 import (
+	"strconv"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -25,12 +26,71 @@ type Metrics struct {
 	ExternalAPIDuration *prometheus.HistogramVec
 	ExternalAPIFailures *prometheus.CounterVec
 
+	// Upstream connection metrics
+	UpstreamConnections     *prometheus.CounterVec
+	UpstreamConnectionSetup *prometheus.HistogramVec
+	UpstreamPrewarm         *prometheus.HistogramVec
+	UpstreamRateLimitWait   *prometheus.HistogramVec
+	UpstreamHedges          *prometheus.CounterVec
+
 	// Business metrics
 	BusinessMetricsCalculated *prometheus.CounterVec
+	MetricsBatchRows          *prometheus.CounterVec
+
+	// Job queue metrics
+	JobQueueDepth    *prometheus.GaugeVec
+	JobQueueWaitTime *prometheus.HistogramVec
+
+	// Maintenance mode
+	MaintenanceMode prometheus.Gauge
+
+	// Scheduler metrics
+	ScheduledRunsTotal *prometheus.CounterVec
+
+	// Quota metrics
+	QuotaRejections *prometheus.CounterVec
+
+	// Notification metrics
+	NotificationsTotal *prometheus.CounterVec
+
+	// Export acknowledgement metrics
+	ExportAcks *prometheus.CounterVec
+
+	// Background job metrics
+	BackgroundJobHeartbeat *prometheus.GaugeVec
+	BackgroundJobRunning   *prometheus.GaugeVec
+	BackgroundJobRestarts  *prometheus.CounterVec
+	BackgroundJobStale     *prometheus.CounterVec
+
+	// Fault injection metrics
+	InjectedFaults *prometheus.CounterVec
+
+	// Runtime configuration metrics
+	ConfigChanges *prometheus.CounterVec
+
+	// Storage bulkhead metrics
+	StorageInUse      *prometheus.GaugeVec
+	StorageQueued     *prometheus.GaugeVec
+	StorageQueueWait  *prometheus.HistogramVec
+	StorageRejections *prometheus.CounterVec
+
+	// Load shedding metrics
+	ServerInFlight prometheus.Gauge
+	LatencyP99     prometheus.Gauge
+	LoadShed       *prometheus.CounterVec
+
+	// Tenant metrics
+	TenantHTTPRequests  *prometheus.CounterVec
+	TenantLabels        prometheus.Gauge
+	TenantLabelOverflow prometheus.Counter
+
+	tenants *tenantGuard
 }
 
-func New() *Metrics {
+func New(opts Options) *Metrics {
 	return &Metrics{
+		tenants: newTenantGuard(opts),
+
 		HTTPRequestsTotal: promauto.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "http_requests_total",
@@ -60,7 +120,7 @@ func New() *Metrics {
 				Name: "etl_jobs_total",
 				Help: "Total number of ETL jobs",
 			},
-			[]string{"status", "source"},
+			[]string{"status", "source", "tenant"},
 		),
 
 		ETLJobDuration: promauto.NewHistogramVec(
@@ -84,7 +144,7 @@ func New() *Metrics {
 				Name: "etl_records_processed_total",
 				Help: "Total number of records processed by ETL",
 			},
-			[]string{"source", "status"},
+			[]string{"source", "status", "tenant"},
 		),
 
 		ETLRecordsFailed: promauto.NewCounterVec(
@@ -120,6 +180,49 @@ func New() *Metrics {
 			[]string{"api", "error_type"},
 		),
 
+		UpstreamConnections: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "upstream_connections_total",
+				Help: "Connections upstream requests got, by whether they reused a pooled one",
+			},
+			[]string{"api", "reused"},
+		),
+
+		UpstreamConnectionSetup: promauto.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "upstream_connection_setup_seconds",
+				Help:    "Time spent setting up new upstream connections, by phase (dns, connect, tls)",
+				Buckets: []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5},
+			},
+			[]string{"api", "phase"},
+		),
+
+		UpstreamPrewarm: promauto.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "upstream_prewarm_seconds",
+				Help:    "Time spent prewarming upstream connections before extraction",
+				Buckets: []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5},
+			},
+			[]string{"api", "outcome"},
+		),
+
+		UpstreamRateLimitWait: promauto.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "upstream_rate_limit_wait_seconds",
+				Help:    "Time upstream requests waited for their API's rate limiter",
+				Buckets: []float64{0.001, 0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+			},
+			[]string{"api", "outcome"},
+		),
+
+		UpstreamHedges: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "upstream_hedged_requests_total",
+				Help: "Hedged upstream requests by outcome (won, lost, failed, throttled)",
+			},
+			[]string{"api", "outcome"},
+		),
+
 		BusinessMetricsCalculated: promauto.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "business_metrics_calculated_total",
@@ -127,7 +230,216 @@ func New() *Metrics {
 			},
 			[]string{"metric_type"},
 		),
+		MetricsBatchRows: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "metrics_batch_rows_total",
+				Help: "Pre-computed metric rows written through the batch API, by producer",
+			},
+			[]string{"producer"},
+		),
+
+		JobQueueDepth: promauto.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "job_queue_depth",
+				Help: "Number of jobs waiting in the job queue",
+			},
+			[]string{"job_type"},
+		),
+
+		JobQueueWaitTime: promauto.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "job_queue_wait_seconds",
+				Help:    "Time jobs spent waiting in the job queue",
+				Buckets: []float64{0.01, 0.1, 0.5, 1, 5, 10, 30, 60},
+			},
+			[]string{"job_type", "outcome"},
+		),
+
+		MaintenanceMode: promauto.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "maintenance_mode",
+				Help: "1 while maintenance mode is on",
+			},
+		),
+
+		ScheduledRunsTotal: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "scheduled_runs_total",
+				Help: "Scheduled pipeline runs by outcome, including skipped ones",
+			},
+			[]string{"pipeline", "outcome"},
+		),
+
+		QuotaRejections: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "quota_rejections_total",
+				Help: "Work rejected because a usage quota was exhausted",
+			},
+			[]string{"quota", "subject"},
+		),
+
+		NotificationsTotal: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "notifications_total",
+				Help: "Run notifications by channel and delivery outcome",
+			},
+			[]string{"channel", "outcome"},
+		),
+
+		InjectedFaults: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "injected_faults_total",
+				Help: "Faults injected for resilience testing by target and kind (delay, failure)",
+			},
+			[]string{"target", "kind"},
+		),
+
+		ExportAcks: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "export_acks_total",
+				Help: "Exports acknowledged by their sink, and flagged stuck without an ack, by outcome (confirmed, rejected, stuck, invalid_signature)",
+			},
+			[]string{"outcome"},
+		),
+
+		BackgroundJobHeartbeat: promauto.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "background_job_last_heartbeat_timestamp_seconds",
+				Help: "Unix time of the last heartbeat of each background job",
+			},
+			[]string{"job"},
+		),
+
+		BackgroundJobRunning: promauto.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "background_job_running",
+				Help: "1 while the background job is running",
+			},
+			[]string{"job"},
+		),
+
+		BackgroundJobRestarts: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "background_job_restarts_total",
+				Help: "Background jobs restarted by the watchdog after missing their heartbeat deadline",
+			},
+			[]string{"job", "abandoned"},
+		),
+
+		BackgroundJobStale: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "background_job_stale_total",
+				Help: "Watched jobs, such as scheduled runs, that missed their heartbeat deadline",
+			},
+			[]string{"job"},
+		),
+
+		ConfigChanges: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "runtime_config_changes_total",
+				Help: "Runtime configuration settings changed through the admin API",
+			},
+			[]string{"setting"},
+		),
+
+		StorageInUse: promauto.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "storage_bulkhead_in_use",
+				Help: "Repository operations running, by lane (read, write)",
+			},
+			[]string{"lane"},
+		),
+
+		StorageQueued: promauto.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "storage_bulkhead_queued",
+				Help: "Repository operations waiting for a slot, by lane",
+			},
+			[]string{"lane"},
+		),
+
+		StorageQueueWait: promauto.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "storage_bulkhead_wait_seconds",
+				Help:    "Time repository operations waited for a slot, by lane",
+				Buckets: []float64{0.001, 0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+			},
+			[]string{"lane"},
+		),
+
+		StorageRejections: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "storage_bulkhead_rejections_total",
+				Help: "Repository reads rejected because the storage was busy, by reason (queue_full, timeout)",
+			},
+			[]string{"lane", "reason"},
+		),
+
+		ServerInFlight: promauto.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "http_server_requests_in_flight",
+				Help: "HTTP requests in flight on every route, as load shedding counts them; drains to zero on shutdown",
+			},
+		),
+
+		LatencyP99: promauto.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "http_request_latency_p99_seconds",
+				Help: "p99 latency of recent HTTP requests, as load shedding judges it",
+			},
+		),
+
+		LoadShed: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "http_requests_shed_total",
+				Help: "Expensive HTTP requests rejected under load, by class and reason (in_flight, latency)",
+			},
+			[]string{"class", "reason"},
+		),
+
+		TenantHTTPRequests: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "tenant_http_requests_total",
+				Help: "HTTP requests by tenant (X-Tenant-ID) and status code",
+			},
+			[]string{"tenant", "status_code"},
+		),
+
+		TenantLabels: promauto.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "tenant_labels",
+				Help: "Tenants with their own metric label",
+			},
+		),
+
+		TenantLabelOverflow: promauto.NewCounter(
+			prometheus.CounterOpts{
+				Name: "tenant_label_overflow_total",
+				Help: "Observations labeled \"other\" because the tenant is unknown or the tenant label limit was reached",
+			},
+		),
+	}
+}
+
+// RegisterTenants makes the tenants eligible for their own label, e.g. the
+// tenants configured with a quota. Any other tenant is labeled OtherTenant.
+func (m *Metrics) RegisterTenants(tenants ...string) {
+	m.tenants.register(tenants)
+}
+
+// returns the label value of the tenant within the cardinality limit
+func (m *Metrics) tenantLabel(tenant string) string {
+	label, labeled := m.tenants.label(tenant)
+	m.TenantLabels.Set(float64(labeled))
+	if label != tenant {
+		m.TenantLabelOverflow.Inc()
 	}
+	return label
+}
+
+// HTTP requests by tenant
+func (m *Metrics) RecordTenantHTTPRequest(tenant, statusCode string) {
+	m.TenantHTTPRequests.WithLabelValues(m.tenantLabel(tenant), statusCode).Inc()
 }
 
 // HTTP request metrics
@@ -137,14 +449,14 @@ func (m *Metrics) RecordHTTPRequest(method, endpoint, statusCode string, duratio
 }
 
 // ETL job metrics
-func (m *Metrics) RecordETLJob(status, source string, duration time.Duration) {
-	m.ETLJobsTotal.WithLabelValues(status, source).Inc()
+func (m *Metrics) RecordETLJob(status, source, tenant string, duration time.Duration) {
+	m.ETLJobsTotal.WithLabelValues(status, source, m.tenantLabel(tenant)).Inc()
 	m.ETLJobDuration.WithLabelValues(source).Observe(duration.Seconds())
 }
 
 // ETL record processing metrics
-func (m *Metrics) RecordETLRecords(source, status string, count int) {
-	m.ETLRecordsProcessed.WithLabelValues(source, status).Add(float64(count))
+func (m *Metrics) RecordETLRecords(source, status, tenant string, count int) {
+	m.ETLRecordsProcessed.WithLabelValues(source, status, m.tenantLabel(tenant)).Add(float64(count))
 }
 
 // ETL record failure metrics
@@ -168,6 +480,11 @@ func (m *Metrics) RecordBusinessMetric(metricType string) {
 	m.BusinessMetricsCalculated.WithLabelValues(metricType).Inc()
 }
 
+// RecordMetricsBatch counts the rows a producer wrote through the batch API
+func (m *Metrics) RecordMetricsBatch(producer string, rows int) {
+	m.MetricsBatchRows.WithLabelValues(producer).Add(float64(rows))
+}
+
 // ETL jobs in progress counter
 func (m *Metrics) IncETLJobsInProgress() {
 	m.ETLJobsInProgress.Inc()
@@ -187,3 +504,141 @@ func (m *Metrics) IncHTTPRequestsInFlight() {
 func (m *Metrics) DecHTTPRequestsInFlight() {
 	m.HTTPRequestsInFlight.Dec()
 }
+
+// Upstream connection reuse
+func (m *Metrics) RecordUpstreamConnection(api string, reused bool) {
+	m.UpstreamConnections.WithLabelValues(api, strconv.FormatBool(reused)).Inc()
+}
+
+// Upstream connection setup time by phase
+func (m *Metrics) RecordUpstreamConnectionSetup(api, phase string, duration time.Duration) {
+	m.UpstreamConnectionSetup.WithLabelValues(api, phase).Observe(duration.Seconds())
+}
+
+// Upstream connection prewarm time
+func (m *Metrics) RecordUpstreamPrewarm(api, outcome string, duration time.Duration) {
+	m.UpstreamPrewarm.WithLabelValues(api, outcome).Observe(duration.Seconds())
+}
+
+// Upstream rate limiter wait time
+func (m *Metrics) RecordRateLimitWait(api, outcome string, duration time.Duration) {
+	m.UpstreamRateLimitWait.WithLabelValues(api, outcome).Observe(duration.Seconds())
+}
+
+// Hedged upstream request outcome
+func (m *Metrics) RecordUpstreamHedge(api, outcome string) {
+	m.UpstreamHedges.WithLabelValues(api, outcome).Inc()
+}
+
+// Job queue depth gauge
+func (m *Metrics) SetJobQueueDepth(jobType string, depth int) {
+	m.JobQueueDepth.WithLabelValues(jobType).Set(float64(depth))
+}
+
+// Job queue wait time
+func (m *Metrics) RecordJobQueueWait(jobType, outcome string, duration time.Duration) {
+	m.JobQueueWaitTime.WithLabelValues(jobType, outcome).Observe(duration.Seconds())
+}
+
+// Maintenance mode gauge
+func (m *Metrics) SetMaintenanceMode(enabled bool) {
+	if enabled {
+		m.MaintenanceMode.Set(1)
+		return
+	}
+	m.MaintenanceMode.Set(0)
+}
+
+// Scheduled pipeline run outcome
+func (m *Metrics) RecordScheduledRun(pipeline, outcome string) {
+	m.ScheduledRunsTotal.WithLabelValues(pipeline, outcome).Inc()
+}
+
+// Quota rejection counter of a bounded subject, e.g. an upstream source
+func (m *Metrics) RecordQuotaRejection(quota, subject string) {
+	m.QuotaRejections.WithLabelValues(quota, subject).Inc()
+}
+
+// Quota rejection counter of a tenant's quota
+func (m *Metrics) RecordTenantQuotaRejection(quota, tenant string) {
+	m.QuotaRejections.WithLabelValues(quota, m.tenantLabel(tenant)).Inc()
+}
+
+// Run notification delivery outcome
+func (m *Metrics) RecordNotification(channel, outcome string) {
+	m.NotificationsTotal.WithLabelValues(channel, outcome).Inc()
+}
+
+// Injected fault
+func (m *Metrics) RecordInjectedFault(target, kind string) {
+	m.InjectedFaults.WithLabelValues(target, kind).Inc()
+}
+
+// Background job heartbeat gauge
+func (m *Metrics) SetBackgroundJobHeartbeat(job string, at time.Time) {
+	m.BackgroundJobHeartbeat.WithLabelValues(job).Set(float64(at.UnixNano()) / 1e9)
+}
+
+// Background job running gauge
+func (m *Metrics) SetBackgroundJobRunning(job string, running bool) {
+	if running {
+		m.BackgroundJobRunning.WithLabelValues(job).Set(1)
+		return
+	}
+	m.BackgroundJobRunning.WithLabelValues(job).Set(0)
+}
+
+// Background job restart by the watchdog
+func (m *Metrics) RecordBackgroundJobRestart(job string, abandoned bool) {
+	m.BackgroundJobRestarts.WithLabelValues(job, strconv.FormatBool(abandoned)).Inc()
+}
+
+// Watched background job past its heartbeat deadline
+func (m *Metrics) RecordBackgroundJobStale(job string) {
+	m.BackgroundJobStale.WithLabelValues(job).Inc()
+}
+
+// Runtime configuration setting changed
+func (m *Metrics) RecordConfigChange(setting string) {
+	m.ConfigChanges.WithLabelValues(setting).Inc()
+}
+
+// Export acknowledged by its sink, flagged stuck, or ack refused
+func (m *Metrics) RecordExportAck(outcome string) {
+	m.ExportAcks.WithLabelValues(outcome).Inc()
+}
+
+// Storage bulkhead operations running
+func (m *Metrics) AddStorageInUse(lane string, delta float64) {
+	m.StorageInUse.WithLabelValues(lane).Add(delta)
+}
+
+// Storage bulkhead operations waiting
+func (m *Metrics) AddStorageQueued(lane string, delta float64) {
+	m.StorageQueued.WithLabelValues(lane).Add(delta)
+}
+
+// Storage bulkhead wait time
+func (m *Metrics) RecordStorageQueueWait(lane string, duration time.Duration) {
+	m.StorageQueueWait.WithLabelValues(lane).Observe(duration.Seconds())
+}
+
+// Storage bulkhead rejection
+func (m *Metrics) RecordStorageRejection(lane, reason string) {
+	m.StorageRejections.WithLabelValues(lane, reason).Inc()
+}
+
+// HTTP requests in flight on every route
+func (m *Metrics) SetServerInFlight(requests int64) {
+	m.ServerInFlight.Set(float64(requests))
+}
+
+// p99 latency load shedding judges by
+func (m *Metrics) SetLatencyP99(latency time.Duration) {
+	m.LatencyP99.Set(latency.Seconds())
+}
+
+// Request shed under load
+func (m *Metrics) RecordLoadShed(class, reason string) {
+	m.LoadShed.WithLabelValues(class, reason).Inc()
+}
@@ -0,0 +1,65 @@
+package metrics
+
+import "sync"
+
+// OtherTenant labels the work of unknown tenants and of tenants beyond the
+// label limit
+const OtherTenant = "other"
+
+// Options bounds the cardinality of the tenant labels. TopTenants, e.g. the
+// largest customers, always get their own label. Tenants made known with
+// RegisterTenants get one as they first appear, until TenantLimit tenants
+// are labeled; the rest, and every tenant never registered, share the
+// OtherTenant label, so a client naming arbitrary tenants cannot take the
+// slots. A label, once given, is kept for the life of the process so series
+// are not split.
+type Options struct {
+	TenantLimit int
+	TopTenants  []string
+}
+
+// assigns tenant label values within the limit
+type tenantGuard struct {
+	limit   int
+	known   map[string]bool
+	labeled map[string]bool
+	mutex   sync.Mutex
+}
+
+func newTenantGuard(opts Options) *tenantGuard {
+	g := &tenantGuard{
+		limit:   opts.TenantLimit,
+		known:   make(map[string]bool),
+		labeled: make(map[string]bool),
+	}
+	for _, tenant := range opts.TopTenants {
+		g.known[tenant] = true
+		g.labeled[tenant] = true
+	}
+	return g
+}
+
+// makes the tenants eligible for a label of their own
+func (g *tenantGuard) register(tenants []string) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	for _, tenant := range tenants {
+		g.known[tenant] = true
+	}
+}
+
+// returns the label value of the tenant's work and how many tenants are
+// labeled
+func (g *tenantGuard) label(tenant string) (string, int) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if !g.labeled[tenant] {
+		if !g.known[tenant] || len(g.labeled) >= g.limit {
+			return OtherTenant, len(g.labeled)
+		}
+		g.labeled[tenant] = true
+	}
+	return tenant, len(g.labeled)
+}